@@ -0,0 +1,51 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides a command that generates the Prometheus alerting
+// rules selected by the alerts settings of a redismasking processor
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/spf13/pflag"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor to generate rules for")
+	pflag.Parse()
+
+	if err := run(os.Stdout, *configPath, *processorID); err != nil {
+		log.Fatalf("Generating alerting rules failed: %v", err)
+	}
+}
+
+// run writes the rule file of the configured processor to w
+func run(w io.Writer, configPath, processorID string) error {
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return err
+	}
+
+	data, err := cfg.Alerts.MarshalRuleFile()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
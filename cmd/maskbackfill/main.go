@@ -0,0 +1,127 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides a command that masks historical log files with the
+// configuration and token store of a redismasking processor
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/backfill"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor to apply")
+	inputLocation := pflag.String("input", "", "the directory, s3://bucket/prefix, or gs://bucket/prefix containing OTLP/JSON (.json) or JSONL (.jsonl) files, optionally gzipped")
+	outputLocation := pflag.String("output", "", "the directory, s3://bucket/prefix, or gs://bucket/prefix masked files are written to")
+	prefix := pflag.String("prefix", "", "only mask files whose relative path starts with this prefix")
+	workers := pflag.Int("workers", 1, "the number of files masked concurrently")
+	compress := pflag.Bool("compress", false, "gzip masked files whose input was not compressed")
+	checkpointPath := pflag.String("checkpoint", "", "a local file used to resume an interrupted backfill")
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to set up logger: %v", err)
+	}
+
+	if *inputLocation == "" || *outputLocation == "" {
+		logger.Fatal("Both --input and --output are required")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	opts := backfill.Options{
+		Workers:  *workers,
+		Compress: *compress,
+	}
+	if *checkpointPath != "" {
+		opts.Checkpoint, err = backfill.LoadCheckpoint(*checkpointPath)
+		if err != nil {
+			logger.Fatal("Failed to load checkpoint", zap.Error(err))
+		}
+	}
+
+	if err := run(ctx, logger, *configPath, *processorID, *inputLocation, *outputLocation, *prefix, opts); err != nil {
+		logger.Fatal("Backfill failed", zap.Error(err))
+	}
+}
+
+// run masks every file in input with the configured processor and writes the results to output
+func run(ctx context.Context, logger *zap.Logger, configPath, processorID, inputLocation, outputLocation, prefix string, opts backfill.Options) error {
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return err
+	}
+
+	input, err := backfill.OpenBucket(ctx, inputLocation)
+	if err != nil {
+		return err
+	}
+
+	output, err := backfill.OpenBucket(ctx, outputLocation)
+	if err != nil {
+		return err
+	}
+
+	// The mappings go to the store of the processor, so backfilled tokens can
+	// be unmasked. Only the memory store is a throwaway copy, like the one of
+	// the collector.
+	var store masker.Store
+	switch {
+	case cfg.RedisStoreEnabled():
+		store, err = masker.NewRedisStore(ctx, cfg)
+	case cfg.StoreEnabled():
+		store, err = cfg.NewStore(logger)
+	}
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		defer store.Close()
+	}
+
+	m, err := masker.New(cfg, store, logger)
+	if err != nil {
+		return err
+	}
+	if err := m.CheckSalt(ctx); err != nil {
+		return err
+	}
+
+	stats, err := backfill.NewRunner(m, opts, logger).Run(ctx, input, output, prefix)
+	if err != nil {
+		return err
+	}
+	if err := m.FlushWrites(ctx); err != nil {
+		return err
+	}
+
+	logger.Info("Backfill complete",
+		zap.Int("files", stats.Files),
+		zap.Int("skipped", stats.Skipped),
+		zap.Int("resumed", stats.Resumed),
+		zap.Int("records", stats.Records),
+	)
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides a command that benchmarks a redismasking processor
+// configuration against a synthetic log corpus
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/loadgen"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor to benchmark")
+	records := pflag.Int("records", 100000, "the number of log records in the corpus")
+	bodySize := pflag.Int("body-size", 512, "the approximate size of every log body in bytes")
+	density := pflag.Float64("pii-density", 0.3, "the average number of sensitive values per record")
+	uniqueRatio := pflag.Float64("unique-ratio", 0.1, "the fraction of sensitive values not seen before in the corpus")
+	kinds := pflag.StringSlice("kinds", nil, "the kinds of sensitive values: ipv4, email, phone, ssn, credit_card (default all)")
+	seed := pflag.Int64("seed", 1, "the seed of the corpus")
+	workers := pflag.Int("workers", 4, "the number of batches masked concurrently")
+	batchSize := pflag.Int("batch-size", 100, "the number of records per batch")
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to set up logger: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	corpusCfg := loadgen.CorpusConfig{
+		Records:     *records,
+		BodySize:    *bodySize,
+		PIIDensity:  *density,
+		UniqueRatio: *uniqueRatio,
+		Kinds:       *kinds,
+		Seed:        *seed,
+	}
+	opts := loadgen.Options{
+		Workers:   *workers,
+		BatchSize: *batchSize,
+	}
+	if err := run(ctx, os.Stdout, logger, *configPath, *processorID, corpusCfg, opts); err != nil {
+		logger.Fatal("Benchmark failed", zap.Error(err))
+	}
+}
+
+// run masks a generated corpus with the configured processor and its store,
+// and writes the results to w
+func run(ctx context.Context, w io.Writer, logger *zap.Logger, configPath, processorID string, corpusCfg loadgen.CorpusConfig, opts loadgen.Options) error {
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return err
+	}
+
+	corpus, err := loadgen.Generate(corpusCfg)
+	if err != nil {
+		return err
+	}
+
+	var store masker.Store
+	switch {
+	case cfg.RedisStoreEnabled():
+		store, err = masker.NewRedisStore(ctx, cfg)
+	case cfg.StoreEnabled():
+		store, err = cfg.NewStore(logger)
+	}
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		defer store.Close()
+		if pinger, ok := store.(masker.Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				return err
+			}
+		}
+		// The local cache is part of the latency a collector sees
+		if cfg.LocalCacheSize > 0 {
+			store = masker.NewCachedStore(store, cfg.LocalCacheSize, time.Duration(cfg.TokenTTL)*time.Second)
+		}
+	}
+
+	m, err := masker.New(cfg, store, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Masking corpus", zap.Int("records", len(corpus.Records)), zap.Int("values", corpus.Values), zap.Int("unique", corpus.Unique))
+	result, err := loadgen.Run(ctx, m, corpus, opts)
+	if err != nil {
+		return err
+	}
+	if err := m.FlushWrites(ctx); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "records\t%d\n", result.Records)
+	fmt.Fprintf(tw, "bytes\t%d\n", result.Bytes)
+	fmt.Fprintf(tw, "duration\t%s\n", result.Duration.Round(time.Millisecond))
+	fmt.Fprintf(tw, "records/s\t%.0f\n", result.RecordsPerSecond())
+	fmt.Fprintf(tw, "MB/s\t%.2f\n", result.BytesPerSecond()/1e6)
+	fmt.Fprintf(tw, "batch p50\t%s\n", result.P50.Round(time.Microsecond))
+	fmt.Fprintf(tw, "batch p99\t%s\n", result.P99.Round(time.Microsecond))
+	fmt.Fprintf(tw, "batch max\t%s\n", result.Max.Round(time.Microsecond))
+	fmt.Fprintf(tw, "values\t%d (%d unique)\n", result.Values, corpus.Unique)
+	fmt.Fprintf(tw, "leaked\t%d\n", result.Leaked)
+
+	leakedKinds := make([]string, 0, len(result.LeakedByKind))
+	for kind := range result.LeakedByKind {
+		leakedKinds = append(leakedKinds, kind)
+	}
+	sort.Strings(leakedKinds)
+	for _, kind := range leakedKinds {
+		fmt.Fprintf(tw, "  %s\t%d\n", kind, result.LeakedByKind[kind])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,112 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides a command that migrates the mappings of a redismasking
+// processor to new categories, e.g. after a category was renamed between releases
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor whose mappings are migrated")
+	categories := pflag.StringArray("category", nil, "a category to migrate as old=new, e.g. ip=ipv4 or ipv4=vendor_x/ipv4 (repeatable)")
+	move := pflag.Bool("move", false, "delete the old keys instead of keeping them for agents of the old release")
+	rate := pflag.Int("rate", 1000, "the maximum number of keys migrated per second, 0 for unlimited")
+	dryRun := pflag.Bool("dry-run", false, "only count the keys that would be migrated")
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to set up logger: %v", err)
+	}
+
+	rules, err := parseRules(*categories)
+	if err != nil {
+		logger.Fatal("Invalid --category", zap.Error(err))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	opts := masker.MigrationOptions{Move: *move, Rate: *rate, DryRun: *dryRun}
+	if err := run(ctx, logger, *configPath, *processorID, rules, opts); err != nil {
+		logger.Fatal("Migration failed", zap.Error(err))
+	}
+}
+
+// parseRules parses old=new category pairs
+func parseRules(categories []string) ([]masker.MigrationRule, error) {
+	rules := make([]masker.MigrationRule, 0, len(categories))
+	for _, category := range categories {
+		from, to, ok := strings.Cut(category, "=")
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not of the form old=new", category)
+		}
+		rules = append(rules, masker.MigrationRule{From: from, To: to})
+	}
+	return rules, masker.ValidateMigrationRules(rules)
+}
+
+// run migrates the mappings of the configured processor
+func run(ctx context.Context, logger *zap.Logger, configPath, processorID string, rules []masker.MigrationRule, opts masker.MigrationOptions) error {
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.StoreEnabled() {
+		return errors.New("the processor does not store mappings")
+	}
+	if !cfg.RedisStoreEnabled() {
+		return errors.New("the processor does not store mappings in Redis")
+	}
+
+	store, err := masker.NewRedisStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	migrator, ok := store.(masker.Migrator)
+	if !ok {
+		return errors.New("the token store does not support migrations")
+	}
+
+	stats, err := migrator.Migrate(ctx, rules, opts, func(stats masker.MigrationStats) {
+		logger.Debug("Migrated batch", zap.Int64("migrated", stats.Migrated), zap.Int64("conflicts", stats.Conflicts))
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Migration complete",
+		zap.Bool("dry_run", opts.DryRun),
+		zap.Int64("migrated", stats.Migrated),
+		zap.Int64("conflicts", stats.Conflicts),
+	)
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides a command that rebuilds a redismasking token store
+// from the mappings replicated to Kafka by another region
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/replication"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor whose store is rebuilt")
+	group := pflag.String("group", "redismasking-replicator", "the Kafka consumer group used to track progress")
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to set up logger: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, logger, *configPath, *processorID, *group); err != nil && !errors.Is(err, context.Canceled) {
+		logger.Fatal("Replication failed", zap.Error(err))
+	}
+}
+
+// run consumes the replication topic of the configured processor into its token store
+func run(ctx context.Context, logger *zap.Logger, configPath, processorID, group string) error {
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Replication.Enabled() {
+		return errors.New("replication is not configured for the processor")
+	}
+
+	if !cfg.StoreEnabled() {
+		return errors.New("the processor does not store mappings")
+	}
+
+	var store masker.Store
+	if cfg.RedisStoreEnabled() {
+		store, err = masker.NewRedisStore(ctx, cfg)
+	} else {
+		store, err = cfg.NewStore(logger)
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	consumer, err := replication.NewConsumer(&cfg.Replication, group, store, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Replicating mappings", zap.String("topic", cfg.Replication.Topic))
+	return consumer.Run(ctx)
+}
@@ -0,0 +1,90 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides a command that reports the tokens most frequently
+// masked by a redismasking processor with access tracking enabled
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor to report on")
+	top := pflag.Int("top", 20, "the number of tokens to report")
+	pflag.Parse()
+
+	if err := run(context.Background(), os.Stdout, *configPath, *processorID, *top); err != nil {
+		log.Fatalf("Report failed: %v", err)
+	}
+}
+
+// run writes the top tokens of the configured processor to w
+func run(ctx context.Context, w io.Writer, configPath, processorID string, top int) error {
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return err
+	}
+
+	if !cfg.AccessTrackingEnabled() {
+		return errors.New("access tracking is not enabled for the processor")
+	}
+	if !cfg.RedisStoreEnabled() {
+		return errors.New("the processor does not store mappings in Redis")
+	}
+
+	store, err := masker.NewRedisStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	tracker, ok := store.(masker.AccessTracker)
+	if !ok {
+		return errors.New("the token store does not track access")
+	}
+
+	m, err := masker.New(cfg, store, zap.NewNop(), masker.WithAccessTracker(tracker))
+	if err != nil {
+		return err
+	}
+
+	tokens, err := m.TopTokens(ctx, top)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CATEGORY\tTOKEN\tCOUNT\tLAST SEEN")
+	for _, token := range tokens {
+		lastSeen := "-"
+		if !token.LastSeen.IsZero() {
+			lastSeen = token.LastSeen.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", token.Category, token.Token, token.Count, lastSeen)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,231 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides a command that exports the mappings of a redismasking
+// processor to object storage and imports them again, e.g. to back up the
+// token store or to seed the store of a new region
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/backfill"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/replication"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+const (
+	// snapshotPrefix starts the name of every snapshot
+	snapshotPrefix = "mappings-"
+
+	// snapshotSuffix ends the name of every snapshot
+	snapshotSuffix = ".jsonl.gz"
+
+	// snapshotTimeFormat orders snapshot names by their export time
+	snapshotTimeFormat = "20060102T150405Z"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor whose mappings are exported or imported")
+	location := pflag.String("location", "", "the directory, s3://bucket/prefix, or gs://bucket/prefix holding the snapshots")
+	name := pflag.String("name", "", "the snapshot to import, by default the latest one")
+	interval := pflag.Duration("interval", 0, "export a snapshot every interval until interrupted, 0 to export once")
+	pflag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] export|import\n", os.Args[0])
+		pflag.PrintDefaults()
+	}
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to set up logger: %v", err)
+	}
+
+	if pflag.NArg() != 1 {
+		pflag.Usage()
+		os.Exit(2)
+	}
+	if *location == "" {
+		logger.Fatal("--location is required")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	switch command := pflag.Arg(0); command {
+	case "export":
+		err = runExport(ctx, logger, *configPath, *processorID, *location, *interval)
+	case "import":
+		err = runImport(ctx, logger, *configPath, *processorID, *location, *name)
+	default:
+		logger.Fatal("Unknown command", zap.String("command", command))
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Fatal("Snapshot failed", zap.Error(err))
+	}
+}
+
+// open returns the token store of the processor, the snapshot codec, and the
+// bucket at location
+func open(ctx context.Context, logger *zap.Logger, configPath, processorID, location string) (masker.Store, *replication.Snapshot, backfill.Bucket, error) {
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	snapshot, err := replication.NewSnapshot(&cfg.Replication)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	bucket, err := backfill.OpenBucket(ctx, location)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var store masker.Store
+	switch {
+	case cfg.RedisStoreEnabled():
+		store, err = masker.NewRedisStore(ctx, cfg)
+	case cfg.StoreEnabled() && !cfg.LocalStoreEnabled():
+		store, err = cfg.NewStore(logger)
+	default:
+		err = errors.New("the processor does not keep mappings in a shared store")
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return store, snapshot, bucket, nil
+}
+
+// runExport exports the mappings of the configured processor once, or every
+// interval until ctx is canceled
+func runExport(ctx context.Context, logger *zap.Logger, configPath, processorID, location string, interval time.Duration) error {
+	store, snapshot, bucket, err := open(ctx, logger, configPath, processorID, location)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	scanner, ok := store.(masker.MappingScanner)
+	if !ok {
+		return errors.New("the token store does not support snapshots")
+	}
+
+	if interval <= 0 {
+		return export(ctx, logger, snapshot, scanner, bucket)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		// A failed export is retried at the next interval
+		if err := export(ctx, logger, snapshot, scanner, bucket); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Error("Failed to export snapshot", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// export writes a snapshot named by the current time to bucket
+func export(ctx context.Context, logger *zap.Logger, snapshot *replication.Snapshot, scanner masker.MappingScanner, bucket backfill.Bucket) error {
+	name := snapshotPrefix + time.Now().UTC().Format(snapshotTimeFormat) + snapshotSuffix
+
+	w, err := bucket.Create(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// A failed export is discarded rather than left as a partial snapshot
+	exported, err := snapshot.Export(ctx, scanner, w)
+	if err != nil {
+		_ = backfill.Abort(w)
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	logger.Info("Snapshot exported", zap.String("name", name), zap.Int64("mappings", exported))
+	return nil
+}
+
+// runImport applies the named or the latest snapshot to the token store of
+// the configured processor
+func runImport(ctx context.Context, logger *zap.Logger, configPath, processorID, location, name string) error {
+	store, snapshot, bucket, err := open(ctx, logger, configPath, processorID, location)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if name == "" {
+		name, err = latest(ctx, bucket)
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err := bucket.Open(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stats, err := snapshot.Import(ctx, store, r)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Snapshot imported",
+		zap.String("name", name),
+		zap.Int64("applied", stats.Applied),
+		zap.Int64("expired", stats.Expired),
+	)
+	return nil
+}
+
+// latest returns the name of the most recent snapshot in bucket
+func latest(ctx context.Context, bucket backfill.Bucket) (string, error) {
+	names, err := bucket.List(ctx, snapshotPrefix)
+	if err != nil {
+		return "", err
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return strings.Contains(name, "/") || !strings.HasSuffix(name, snapshotSuffix)
+	})
+	if len(names) == 0 {
+		return "", errors.New("no snapshot found")
+	}
+	return slices.Max(names), nil
+}
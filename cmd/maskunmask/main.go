@@ -0,0 +1,131 @@
+// Copyright  observIQ, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package main provides the unmask API of a redismasking processor. Analysts
+// reverse tokens with single-use grants issued by an administrator.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/unmask"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+func main() {
+	configPath := pflag.String("config", "./config.yaml", "the collector config containing the processor")
+	processorID := pflag.String("processor", "redismasking", "the ID of the redismasking processor whose tokens are reversed")
+	listenAddr := pflag.String("listen", "localhost:8443", "the address the API listens on")
+	adminKeyFile := pflag.String("admin-key-file", "", "a file containing the key required to issue grants")
+	tlsCert := pflag.String("tls-cert", "", "the TLS certificate file, plain HTTP is served when empty")
+	tlsKey := pflag.String("tls-key", "", "the TLS key file")
+	var audit unmask.AuditConfig
+	pflag.StringVar(&audit.Stream, "audit-stream", "", "the Redis stream receiving compressed batches of audit records, audit records are only logged when empty")
+	pflag.DurationVar(&audit.FlushInterval, "audit-flush-interval", unmask.DefaultAuditFlushInterval, "how often buffered audit records are written to the audit stream")
+	pflag.IntVar(&audit.MaxBatchSize, "audit-max-batch-size", unmask.DefaultAuditMaxBatchSize, "the number of audit records written to the audit stream at once")
+	pflag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to set up logger: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := run(ctx, logger, *configPath, *processorID, *listenAddr, *adminKeyFile, *tlsCert, *tlsKey, audit); err != nil {
+		logger.Fatal("Unmask API failed", zap.Error(err))
+	}
+}
+
+// run serves the unmask API until ctx is canceled
+func run(ctx context.Context, logger *zap.Logger, configPath, processorID, listenAddr, adminKeyFile, tlsCert, tlsKey string, audit unmask.AuditConfig) error {
+	if adminKeyFile == "" {
+		return errors.New("--admin-key-file is required")
+	}
+
+	// #nosec G304 -- the key file is chosen by the operator
+	adminKey, err := os.ReadFile(adminKeyFile)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := masker.LoadConfig(configPath, processorID)
+	if err != nil {
+		return err
+	}
+	if !cfg.RedisStoreEnabled() {
+		return errors.New("the processor does not store mappings in Redis")
+	}
+
+	store, err := masker.NewRedisStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	policy, err := cfg.EffectivePolicy()
+	if err != nil {
+		return err
+	}
+
+	grants, err := unmask.NewRedisGrants(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer grants.Close()
+
+	var opts []unmask.ServerOption
+	if audit.Stream != "" {
+		emitter, err := unmask.NewRedisAuditEmitter(ctx, cfg, audit, logger)
+		if err != nil {
+			return err
+		}
+		defer emitter.Close()
+		opts = append(opts, unmask.WithAuditEmitter(emitter))
+	}
+
+	server := &http.Server{
+		Addr:              listenAddr,
+		Handler:           unmask.NewServer(store, grants, strings.TrimSpace(string(adminKey)), policy, logger, opts...).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Serving unmask API", zap.String("addr", listenAddr))
+	if tlsCert != "" {
+		err = server.ListenAndServeTLS(tlsCert, tlsKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
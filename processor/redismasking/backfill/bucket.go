@@ -0,0 +1,141 @@
+// Package backfill masks historical telemetry files with the same rules and
+// token store used by the redismasking processor in live pipelines.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Bucket is a flat namespace of objects that the runner reads from or writes to
+type Bucket interface {
+	// List returns the names of all objects starting with prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Open opens the named object for reading
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// Create creates or truncates the named object for writing
+	Create(ctx context.Context, name string) (io.WriteCloser, error)
+}
+
+// errAborted stops the upload of an aborted object
+var errAborted = errors.New("upload aborted")
+
+// aborter is implemented by object writers that can discard a partial object
+type aborter interface {
+	// Abort discards the object instead of finalizing it
+	Abort() error
+}
+
+// Abort discards the partial object written to w after a failure, so readers
+// of the bucket never see a truncated object. Writers that cannot discard
+// their object are closed.
+func Abort(w io.WriteCloser) error {
+	if a, ok := w.(aborter); ok {
+		return a.Abort()
+	}
+	return w.Close()
+}
+
+// dirBucket is a Bucket backed by a local directory
+type dirBucket struct {
+	root string
+}
+
+// NewDirBucket returns a Bucket rooted at the local directory root
+func NewDirBucket(root string) Bucket {
+	return &dirBucket{root: root}
+}
+
+// List walks the directory and returns slash-separated relative file names
+func (b *dirBucket) List(_ context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(b.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(b.root, path)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// Open opens the named file
+func (b *dirBucket) Open(_ context.Context, name string) (io.ReadCloser, error) {
+	// #nosec G304 -- path is confined to the bucket root
+	return os.Open(b.path(name))
+}
+
+// Create creates the named file and any missing parent directories
+func (b *dirBucket) Create(_ context.Context, name string) (io.WriteCloser, error) {
+	path := b.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	// #nosec G304 -- path is confined to the bucket root
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &dirObject{File: file}, nil
+}
+
+// dirObject is a file being written to a dirBucket
+type dirObject struct {
+	*os.File
+}
+
+// Abort closes and removes the file
+func (o *dirObject) Abort() error {
+	return errors.Join(o.Close(), os.Remove(o.Name()))
+}
+
+// path converts an object name to a path under the bucket root
+func (b *dirBucket) path(name string) string {
+	return filepath.Join(b.root, filepath.FromSlash(filepath.Clean("/"+name)))
+}
+
+// OpenBucket returns the Bucket described by location, which is either an
+// s3://bucket/prefix or gs://bucket/prefix URL or a local directory
+func OpenBucket(ctx context.Context, location string) (Bucket, error) {
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		return NewDirBucket(location), nil
+	}
+
+	bucket, root, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("missing bucket name in '%s'", location)
+	}
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+
+	switch scheme {
+	case "s3":
+		return NewS3Bucket(ctx, bucket, root)
+	case "gs":
+		return NewGCSBucket(ctx, bucket, root)
+	default:
+		return nil, fmt.Errorf("unsupported bucket scheme '%s'", scheme)
+	}
+}
@@ -0,0 +1,85 @@
+package backfill
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Checkpoint records which objects a backfill has finished so an interrupted
+// run can resume without reprocessing them
+type Checkpoint struct {
+	path string
+
+	mu        sync.Mutex
+	completed map[string]struct{}
+}
+
+// checkpointFile is the on-disk representation of a Checkpoint
+type checkpointFile struct {
+	Completed []string `json:"completed"`
+}
+
+// LoadCheckpoint reads the checkpoint at path, starting empty if it does not exist
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	c := &Checkpoint{
+		path:      path,
+		completed: make(map[string]struct{}),
+	}
+
+	// #nosec G304 -- the checkpoint path is supplied by the operator
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return c, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var file checkpointFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+
+	for _, name := range file.Completed {
+		c.completed[name] = struct{}{}
+	}
+	return c, nil
+}
+
+// Done reports whether name was already completed
+func (c *Checkpoint) Done(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.completed[name]
+	return ok
+}
+
+// Complete marks name as completed and persists the checkpoint
+func (c *Checkpoint) Complete(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.completed[name] = struct{}{}
+
+	file := checkpointFile{Completed: slices.Sorted(maps.Keys(c.completed))}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated checkpoint
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(tmp, filepath.Clean(c.path))
+}
@@ -0,0 +1,81 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// gcsBucket is a Bucket backed by a Google Cloud Storage bucket under an object prefix
+type gcsBucket struct {
+	bucket *storage.BucketHandle
+	root   string
+}
+
+// NewGCSBucket returns a Bucket for the GCS bucket using application default credentials
+func NewGCSBucket(ctx context.Context, bucket, root string) (Bucket, error) {
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewClient: %w", err)
+	}
+
+	return &gcsBucket{
+		bucket: client.Bucket(bucket),
+		root:   root,
+	}, nil
+}
+
+// List iterates every object under the bucket root starting with prefix
+func (b *gcsBucket) List(ctx context.Context, prefix string) ([]string, error) {
+	it := b.bucket.Objects(ctx, &storage.Query{Prefix: b.root + prefix})
+
+	var names []string
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("list objects: %w", err)
+		}
+		names = append(names, attrs.Name[len(b.root):])
+	}
+}
+
+// Open streams the named object
+func (b *gcsBucket) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	reader, err := b.bucket.Object(b.root + name).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read object: %w", err)
+	}
+	return reader, nil
+}
+
+// Create returns a writer that uploads the object in chunks as it is written
+func (b *gcsBucket) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &gcsObject{Writer: b.bucket.Object(b.root + name).NewWriter(ctx), cancel: cancel}, nil
+}
+
+// gcsObject is an object being uploaded to a gcsBucket
+type gcsObject struct {
+	*storage.Writer
+	cancel context.CancelFunc
+}
+
+// Close finalizes the object
+func (o *gcsObject) Close() error {
+	defer o.cancel()
+	return o.Writer.Close()
+}
+
+// Abort cancels the upload before it is closed, which discards the object
+func (o *gcsObject) Abort() error {
+	o.cancel()
+	_ = o.Writer.Close()
+	return nil
+}
@@ -0,0 +1,291 @@
+package backfill
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// Format is the encoding of a backfill input file
+type Format int
+
+const (
+	// FormatUnknown is used for files the runner does not recognize
+	FormatUnknown Format = iota
+
+	// FormatOTLPJSON is a single OTLP/JSON logs payload per file
+	FormatOTLPJSON
+
+	// FormatJSONL is one OTLP/JSON logs payload per line
+	FormatJSONL
+)
+
+// maxLineSize bounds the size of a single JSONL line
+const maxLineSize = 64 * 1024 * 1024
+
+// gzipExt is the extension of gzip compressed objects
+const gzipExt = ".gz"
+
+// isCompressed reports whether name refers to a gzip compressed object
+func isCompressed(name string) bool {
+	return strings.EqualFold(path.Ext(name), gzipExt)
+}
+
+// DetectFormat returns the Format implied by the file extension of name,
+// ignoring a trailing .gz compression extension
+func DetectFormat(name string) Format {
+	if isCompressed(name) {
+		name = name[:len(name)-len(gzipExt)]
+	}
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return FormatOTLPJSON
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return FormatUnknown
+	}
+}
+
+// Stats summarizes a backfill run
+type Stats struct {
+	// Files is the number of files masked
+	Files int
+
+	// Skipped is the number of files ignored because of an unknown format
+	Skipped int
+
+	// Resumed is the number of files skipped because a checkpoint marked them complete
+	Resumed int
+
+	// Records is the number of log records masked
+	Records int
+}
+
+// Options tune how a Runner processes a backfill
+type Options struct {
+	// Workers is the number of objects masked concurrently (defaults to 1)
+	Workers int
+
+	// Compress gzips outputs whose input was not already compressed
+	Compress bool
+
+	// Checkpoint, when set, skips objects completed by a previous run and records new completions
+	Checkpoint *Checkpoint
+}
+
+// Runner masks historical log files using a Masker
+type Runner struct {
+	masker *masker.Masker
+	opts   Options
+	logger *zap.Logger
+}
+
+// NewRunner creates a Runner that masks with m
+func NewRunner(m *masker.Masker, opts Options, logger *zap.Logger) *Runner {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	return &Runner{
+		masker: m,
+		opts:   opts,
+		logger: logger,
+	}
+}
+
+// Run masks every recognized object in input whose name starts with prefix and
+// writes the result under the same name in output
+func (r *Runner) Run(ctx context.Context, input, output Bucket, prefix string) (Stats, error) {
+	var (
+		stats   Stats
+		statsMu sync.Mutex
+	)
+
+	names, err := input.List(ctx, prefix)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list input: %w", err)
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(r.opts.Workers)
+
+	for _, name := range names {
+		format := DetectFormat(name)
+		if format == FormatUnknown {
+			r.logger.Debug("Skipping file with unknown format", zap.String("name", name))
+			stats.Skipped++
+			continue
+		}
+
+		if r.opts.Checkpoint != nil && r.opts.Checkpoint.Done(name) {
+			r.logger.Debug("Skipping file completed by a previous run", zap.String("name", name))
+			stats.Resumed++
+			continue
+		}
+
+		group.Go(func() error {
+			if err := groupCtx.Err(); err != nil {
+				return err
+			}
+
+			records, err := r.maskObject(groupCtx, input, output, name, format)
+			if err != nil {
+				return fmt.Errorf("failed to mask %s: %w", name, err)
+			}
+
+			if r.opts.Checkpoint != nil {
+				if err := r.opts.Checkpoint.Complete(name); err != nil {
+					return err
+				}
+			}
+
+			statsMu.Lock()
+			stats.Files++
+			stats.Records += records
+			statsMu.Unlock()
+
+			r.logger.Info("Masked file", zap.String("name", name), zap.Int("records", records))
+			return nil
+		})
+	}
+
+	err = group.Wait()
+	return stats, err
+}
+
+// maskObject masks a single object from input into output
+func (r *Runner) maskObject(ctx context.Context, input, output Bucket, name string, format Format) (records int, err error) {
+	in, err := input.Open(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	compressed := isCompressed(name)
+	outName := name
+	if r.opts.Compress && !compressed {
+		outName += gzipExt
+	}
+
+	out, err := output.Create(ctx, outName)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		// A failed object is discarded rather than finalized with partial contents
+		if err != nil {
+			err = errors.Join(err, Abort(out))
+			return
+		}
+		err = out.Close()
+	}()
+
+	var reader io.Reader = in
+	if compressed {
+		gzipReader, err := gzip.NewReader(in)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decompress: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	if !compressed && !r.opts.Compress {
+		return r.Mask(ctx, reader, out, format)
+	}
+
+	gzipWriter := gzip.NewWriter(out)
+	records, err = r.Mask(ctx, reader, gzipWriter, format)
+	if err != nil {
+		return records, err
+	}
+	return records, gzipWriter.Close()
+}
+
+// Mask reads logs encoded as format from in, masks them, and writes them to out
+// in the same format. It returns the number of log records masked.
+func (r *Runner) Mask(ctx context.Context, in io.Reader, out io.Writer, format Format) (int, error) {
+	switch format {
+	case FormatOTLPJSON:
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return 0, err
+		}
+
+		masked, records, err := r.maskPayload(ctx, data)
+		if err != nil {
+			return 0, err
+		}
+		_, err = out.Write(masked)
+		return records, err
+	case FormatJSONL:
+		return r.maskLines(ctx, in, out)
+	default:
+		return 0, errors.New("unsupported format")
+	}
+}
+
+// maskLines masks every non-empty line of in as an OTLP/JSON payload
+func (r *Runner) maskLines(ctx context.Context, in io.Reader, out io.Writer) (int, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	writer := bufio.NewWriter(out)
+
+	total := 0
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		masked, records, err := r.maskPayload(ctx, data)
+		if err != nil {
+			return total, fmt.Errorf("line %d: %w", line, err)
+		}
+		total += records
+
+		if _, err := writer.Write(masked); err != nil {
+			return total, err
+		}
+		if err := writer.WriteByte('\n'); err != nil {
+			return total, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return total, err
+	}
+	return total, writer.Flush()
+}
+
+// maskPayload masks a single OTLP/JSON logs payload
+func (r *Runner) maskPayload(ctx context.Context, data []byte) ([]byte, int, error) {
+	unmarshaler := &plog.JSONUnmarshaler{}
+	ld, err := unmarshaler.UnmarshalLogs(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode OTLP/JSON logs: %w", err)
+	}
+
+	r.masker.MaskLogs(ctx, ld)
+
+	marshaler := &plog.JSONMarshaler{}
+	masked, err := marshaler.MarshalLogs(ld)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to encode OTLP/JSON logs: %w", err)
+	}
+	return masked, ld.LogRecordCount(), nil
+}
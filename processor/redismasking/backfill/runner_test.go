@@ -0,0 +1,210 @@
+package backfill
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+// newTestRunner creates a Runner with a lightweight masker that needs no store
+func newTestRunner(t *testing.T) *Runner {
+	return newTestRunnerWithOptions(t, Options{})
+}
+
+// newTestRunnerWithOptions creates a Runner with a lightweight masker and the given options
+func newTestRunnerWithOptions(t *testing.T, opts Options) *Runner {
+	t.Helper()
+
+	cfg := masker.NewDefaultConfig()
+	cfg.Mode = "lightweight"
+	cfg.HMACKey = "secret"
+	cfg.FieldsToMask = []string{"username"}
+
+	m, err := masker.New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+	return NewRunner(m, opts, zap.NewNop())
+}
+
+// testPayload returns an OTLP/JSON payload with a single sensitive record
+func testPayload(t *testing.T, username string) []byte {
+	t.Helper()
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Body().SetStr("login from 192.168.1.1")
+	lr.Attributes().PutStr("username", username)
+
+	marshaler := &plog.JSONMarshaler{}
+	data, err := marshaler.MarshalLogs(ld)
+	require.NoError(t, err)
+	return data
+}
+
+// decodeRecord returns the first log record of an OTLP/JSON payload
+func decodeRecord(t *testing.T, data []byte) plog.LogRecord {
+	t.Helper()
+
+	unmarshaler := &plog.JSONUnmarshaler{}
+	ld, err := unmarshaler.UnmarshalLogs(data)
+	require.NoError(t, err)
+	return ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+}
+
+func TestDetectFormat(t *testing.T) {
+	require.Equal(t, FormatOTLPJSON, DetectFormat("logs/2024/01.json"))
+	require.Equal(t, FormatJSONL, DetectFormat("logs/2024/01.JSONL"))
+	require.Equal(t, FormatJSONL, DetectFormat("01.ndjson"))
+	require.Equal(t, FormatJSONL, DetectFormat("01.jsonl.gz"))
+	require.Equal(t, FormatUnknown, DetectFormat("01.csv"))
+	require.Equal(t, FormatUnknown, DetectFormat("01.gz"))
+}
+
+func TestMaskJSONL(t *testing.T) {
+	runner := newTestRunner(t)
+
+	var in bytes.Buffer
+	in.Write(testPayload(t, "alice"))
+	in.WriteString("\n\n")
+	in.Write(testPayload(t, "bob"))
+	in.WriteString("\n")
+
+	var out bytes.Buffer
+	records, err := runner.Mask(context.Background(), &in, &out, FormatJSONL)
+	require.NoError(t, err)
+	require.Equal(t, 2, records)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		lr := decodeRecord(t, []byte(line))
+		require.NotContains(t, lr.Body().Str(), "192.168.1.1")
+		username, _ := lr.Attributes().Get("username")
+		require.True(t, strings.HasPrefix(username.Str(), "username-"))
+	}
+}
+
+func TestMaskInvalidPayload(t *testing.T) {
+	runner := newTestRunner(t)
+
+	var out bytes.Buffer
+	_, err := runner.Mask(context.Background(), strings.NewReader("{}\nnot json\n"), &out, FormatJSONL)
+	require.ErrorContains(t, err, "line 2")
+}
+
+func TestRun(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(inputDir, "2024"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "2024", "a.json"), testPayload(t, "alice"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "2024", "b.jsonl"), append(testPayload(t, "bob"), '\n'), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "2024", "notes.txt"), []byte("ignored"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "other.json"), testPayload(t, "carol"), 0o600))
+
+	runner := newTestRunner(t)
+	stats, err := runner.Run(context.Background(), NewDirBucket(inputDir), NewDirBucket(outputDir), "2024/")
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 2, Skipped: 1, Records: 2}, stats)
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "2024", "a.json"))
+	require.NoError(t, err)
+	lr := decodeRecord(t, data)
+	require.NotContains(t, lr.Body().Str(), "192.168.1.1")
+
+	require.FileExists(t, filepath.Join(outputDir, "2024", "b.jsonl"))
+	require.NoFileExists(t, filepath.Join(outputDir, "other.json"))
+}
+
+func TestRunDiscardsFailedObject(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "a.jsonl"), append(append(testPayload(t, "alice"), '\n'), "not json\n"...), 0o600))
+
+	runner := newTestRunner(t)
+	_, err := runner.Run(context.Background(), NewDirBucket(inputDir), NewDirBucket(outputDir), "")
+	require.ErrorContains(t, err, "line 2")
+
+	// The records masked before the failure are not left behind
+	require.NoFileExists(t, filepath.Join(outputDir, "a.jsonl"))
+}
+
+func TestRunCompressed(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err := gzipWriter.Write(testPayload(t, "alice"))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "a.json.gz"), compressed.Bytes(), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "b.json"), testPayload(t, "bob"), 0o600))
+
+	runner := newTestRunnerWithOptions(t, Options{Workers: 2, Compress: true})
+	stats, err := runner.Run(context.Background(), NewDirBucket(inputDir), NewDirBucket(outputDir), "")
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 2, Records: 2}, stats)
+
+	// Both outputs are compressed, keeping the input name when it already was
+	for _, name := range []string{"a.json.gz", "b.json.gz"} {
+		file, err := os.Open(filepath.Join(outputDir, name))
+		require.NoError(t, err)
+		gzipReader, err := gzip.NewReader(file)
+		require.NoError(t, err)
+		data, err := io.ReadAll(gzipReader)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		lr := decodeRecord(t, data)
+		require.NotContains(t, lr.Body().Str(), "192.168.1.1")
+	}
+}
+
+func TestRunCheckpoint(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "a.json"), testPayload(t, "alice"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "b.json"), testPayload(t, "bob"), 0o600))
+
+	checkpoint, err := LoadCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	require.NoError(t, checkpoint.Complete("a.json"))
+
+	// A reloaded checkpoint resumes where the previous run stopped
+	checkpoint, err = LoadCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	require.True(t, checkpoint.Done("a.json"))
+
+	runner := newTestRunnerWithOptions(t, Options{Checkpoint: checkpoint})
+	stats, err := runner.Run(context.Background(), NewDirBucket(inputDir), NewDirBucket(outputDir), "")
+	require.NoError(t, err)
+	require.Equal(t, Stats{Files: 1, Resumed: 1, Records: 1}, stats)
+	require.NoFileExists(t, filepath.Join(outputDir, "a.json"))
+	require.FileExists(t, filepath.Join(outputDir, "b.json"))
+	require.True(t, checkpoint.Done("b.json"))
+}
+
+func TestOpenBucket(t *testing.T) {
+	dir := t.TempDir()
+	bucket, err := OpenBucket(context.Background(), dir)
+	require.NoError(t, err)
+	require.Equal(t, NewDirBucket(dir), bucket)
+
+	_, err = OpenBucket(context.Background(), "ftp://bucket/prefix")
+	require.EqualError(t, err, "unsupported bucket scheme 'ftp'")
+
+	_, err = OpenBucket(context.Background(), "s3:///prefix")
+	require.EqualError(t, err, "missing bucket name in 's3:///prefix'")
+}
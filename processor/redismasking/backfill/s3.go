@@ -0,0 +1,117 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Bucket is a Bucket backed by an AWS S3 bucket under a key prefix
+type s3Bucket struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	bucket   string
+	root     string
+}
+
+// NewS3Bucket returns a Bucket for the S3 bucket using the default AWS credential chain
+func NewS3Bucket(ctx context.Context, bucket, root string) (Bucket, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("aws load default config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg)
+	return &s3Bucket{
+		client:   client,
+		uploader: manager.NewUploader(client),
+		bucket:   bucket,
+		root:     root,
+	}, nil
+}
+
+// List pages through every key under the bucket root starting with prefix
+func (b *s3Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(b.root + prefix),
+	})
+
+	var names []string
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list objects: %w", err)
+		}
+		for _, object := range output.Contents {
+			if object.Key != nil {
+				names = append(names, (*object.Key)[len(b.root):])
+			}
+		}
+	}
+	return names, nil
+}
+
+// Open streams the named object
+func (b *s3Bucket) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	output, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.root + name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get object: %w", err)
+	}
+	return output.Body, nil
+}
+
+// Create returns a writer that streams the object to S3 as a multipart upload
+func (b *s3Bucket) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	reader, writer := io.Pipe()
+	upload := &pipeUpload{
+		PipeWriter: writer,
+		done:       make(chan error, 1),
+	}
+
+	go func() {
+		_, err := b.uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(b.bucket),
+			Key:    aws.String(b.root + name),
+			Body:   reader,
+		})
+		// Unblock the writer if the upload stopped early
+		_ = reader.CloseWithError(err)
+		upload.done <- err
+	}()
+
+	return upload, nil
+}
+
+// pipeUpload is a writer feeding a background upload through a pipe
+type pipeUpload struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// Close finishes the stream and waits for the upload to complete
+func (u *pipeUpload) Close() error {
+	if err := u.PipeWriter.Close(); err != nil {
+		return err
+	}
+	if err := <-u.done; err != nil {
+		return fmt.Errorf("upload object: %w", err)
+	}
+	return nil
+}
+
+// Abort fails the stream, so the upload stops without creating the object and
+// the parts of a multipart upload are discarded
+func (u *pipeUpload) Abort() error {
+	_ = u.CloseWithError(errAborted)
+	<-u.done
+	return nil
+}
@@ -1,51 +1,104 @@
 package redismasking
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
 	"go.opentelemetry.io/collector/component"
 )
 
 // Config defines configuration for the redis masking processor
 type Config struct {
-	// Redis connection settings
-	RedisAddr     string `mapstructure:"redis_addr"`
-	RedisPassword string `mapstructure:"redis_password"`
-	RedisDB       int    `mapstructure:"redis_db"`
-	
-	// TTL for cached tokens in seconds (0 = no expiration)
-	TokenTTL int `mapstructure:"token_ttl"`
-	
-	// Fields to mask - supports log attributes and body
-	FieldsToMask []string `mapstructure:"fields_to_mask"`
-	
-	// Patterns to detect sensitive data in log body
-	Patterns []PatternConfig `mapstructure:"patterns"`
+	// Masking engine settings, shared with other tools built on the masker package
+	masker.Config `mapstructure:",squash"`
+
+	// StoreExtension references a redismasking_store extension whose store and local
+	// cache are shared with other processors instead of connecting to Redis directly
+	StoreExtension *component.ID `mapstructure:"store_extension"`
+
+	// Storage references a collector storage extension, such as file_storage,
+	// that persists the mappings of the processor instead of Redis
+	Storage *component.ID `mapstructure:"storage"`
+
+	// LazyConnect starts the processor while Redis is unreachable and connects in
+	// the background instead of failing the collector start
+	LazyConnect LazyConnectConfig `mapstructure:"lazy_connect"`
 }
 
-// PatternConfig defines a pattern to detect and mask
-type PatternConfig struct {
-	// Name of the pattern (e.g., "ip_address", "hostname")
-	Name string `mapstructure:"name"`
-	
-	// Regex pattern to match
-	Regex string `mapstructure:"regex"`
-	
-	// Prefix for masked values (e.g., "IP-", "HOST-")
-	MaskedPrefix string `mapstructure:"masked_prefix"`
+// LazyConnectConfig defines how the processor starts while Redis is unreachable
+type LazyConnectConfig struct {
+	// Enabled starts the processor in a degraded state when Redis is unreachable
+	Enabled bool `mapstructure:"enabled"`
+
+	// RetryInterval is how often the connection is retried in the background
+	RetryInterval time.Duration `mapstructure:"retry_interval"`
 }
 
 var _ component.Config = (*Config)(nil)
 
 // Validate checks if the processor configuration is valid
 func (cfg *Config) Validate() error {
-	if cfg.RedisAddr == "" {
-		cfg.RedisAddr = "localhost:6379"
+	if err := cfg.Config.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.LazyConnect.Enabled && cfg.LazyConnect.RetryInterval <= 0 {
+		return errors.New("lazy_connect retry_interval must be positive")
+	}
+
+	if cfg.StoreEnabled() && !cfg.RedisStoreEnabled() {
+		if cfg.StoreExtension != nil {
+			return fmt.Errorf("store_extension is not used with the %s store", cfg.Store)
+		}
+		if cfg.LazyConnect.Enabled {
+			return fmt.Errorf("lazy_connect is not used with the %s store", cfg.Store)
+		}
+	}
+
+	if err := cfg.validateStorage(); err != nil {
+		return err
 	}
-	
-	if cfg.TokenTTL < 0 {
-		return component.NewConfigError("token_ttl must be non-negative")
+
+	if cfg.StoreExtension == nil {
+		return nil
+	}
+
+	if !cfg.StoreEnabled() {
+		return errors.New("store_extension is not used in lightweight mode")
+	}
+
+	if cfg.LocalCacheSize > 0 {
+		return errors.New("local_cache_size must be configured on the store extension when store_extension is set")
+	}
+
+	if cfg.LazyConnect.Enabled {
+		return errors.New("lazy_connect is not used with store_extension")
 	}
-	
 	return nil
 }
 
-
+// validateStorage checks that the storage extension replaces the store and is not
+// combined with features of the Redis store
+func (cfg *Config) validateStorage() error {
+	switch {
+	case cfg.Storage == nil:
+		return nil
+	case !cfg.StoreEnabled():
+		return errors.New("storage is not used in lightweight mode")
+	case cfg.StoreExtension != nil:
+		return errors.New("storage and store_extension are mutually exclusive")
+	case !cfg.RedisStoreEnabled():
+		return fmt.Errorf("storage is not used with the %s store", cfg.Store)
+	case cfg.LazyConnect.Enabled:
+		return errors.New("lazy_connect is not used with storage")
+	case cfg.AccessTrackingEnabled():
+		return errors.New("track_access and warmup_top_n require the redis store")
+	case cfg.Watchlist.Enabled:
+		return errors.New("watchlist requires the redis store")
+	case cfg.LocalCacheInvalidation:
+		return errors.New("local_cache_invalidation requires the redis store")
+	}
+	return nil
+}
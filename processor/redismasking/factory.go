@@ -2,11 +2,18 @@ package redismasking
 
 import (
 	"context"
+	"time"
 
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/xconsumer"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/collector/pipeline/xpipeline"
 	"go.opentelemetry.io/collector/processor"
 	"go.opentelemetry.io/collector/processor/processorhelper"
+	"go.opentelemetry.io/collector/processor/processorhelper/xprocessorhelper"
+	"go.opentelemetry.io/collector/processor/xprocessor"
 )
 
 const (
@@ -16,32 +23,22 @@ const (
 
 // NewFactory creates a new processor factory
 func NewFactory() processor.Factory {
-	return processor.NewFactory(
+	return xprocessor.NewFactory(
 		component.MustNewType(typeStr),
 		createDefaultConfig,
-		processor.WithLogs(createLogsProcessor, stability),
+		xprocessor.WithLogs(createLogsProcessor, stability),
+		xprocessor.WithMetrics(createMetricsProcessor, stability),
+		xprocessor.WithTraces(createTracesProcessor, stability),
+		xprocessor.WithProfiles(createProfilesProcessor, component.StabilityLevelDevelopment),
 	)
 }
 
 // createDefaultConfig creates the default configuration
 func createDefaultConfig() component.Config {
 	return &Config{
-		RedisAddr:    "localhost:6379",
-		RedisPassword: "",
-		RedisDB:      0,
-		TokenTTL:     0, // No expiration by default
-		FieldsToMask: []string{},
-		Patterns: []PatternConfig{
-			{
-				Name:         "ipv4",
-				Regex:        `\b(?:\d{1,3}\.){3}\d{1,3}\b`,
-				MaskedPrefix: "IP-",
-			},
-			{
-				Name:         "hostname",
-				Regex:        `\b[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\b`,
-				MaskedPrefix: "HOST-",
-			},
+		Config: masker.NewDefaultConfig(),
+		LazyConnect: LazyConnectConfig{
+			RetryInterval: 5 * time.Second,
 		},
 	}
 }
@@ -54,21 +51,90 @@ func createLogsProcessor(
 	nextConsumer consumer.Logs,
 ) (processor.Logs, error) {
 	processorCfg := cfg.(*Config)
-	
-	mp, err := newMaskingProcessor(processorCfg, set.Logger)
-	if err != nil {
-		return nil, err
-	}
-	
-	return processorhelper.NewLogsProcessor(
+
+	mp := newMaskingProcessor(processorCfg, set.TelemetrySettings)
+	mp.id, mp.signal = set.ID, pipeline.SignalLogs
+
+	return processorhelper.NewLogs(
 		ctx,
 		set,
 		cfg,
 		nextConsumer,
 		mp.processLogs,
-		processorhelper.WithCapabilities(consumer.Capabilities{MutatesData: true}),
+		processorhelper.WithCapabilities(consumer.Capabilities{MutatesData: processorCfg.MutatesData()}),
 		processorhelper.WithStart(mp.start),
 		processorhelper.WithShutdown(mp.shutdown),
 	)
 }
 
+// createMetricsProcessor creates a metrics processor
+func createMetricsProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Metrics,
+) (processor.Metrics, error) {
+	processorCfg := cfg.(*Config)
+
+	mp := newMaskingProcessor(processorCfg, set.TelemetrySettings)
+	mp.id, mp.signal = set.ID, pipeline.SignalMetrics
+
+	return processorhelper.NewMetrics(
+		ctx,
+		set,
+		cfg,
+		nextConsumer,
+		mp.processMetrics,
+		processorhelper.WithCapabilities(consumer.Capabilities{MutatesData: processorCfg.MutatesData()}),
+		processorhelper.WithStart(mp.start),
+		processorhelper.WithShutdown(mp.shutdown),
+	)
+}
+
+// createTracesProcessor creates a traces processor
+func createTracesProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	nextConsumer consumer.Traces,
+) (processor.Traces, error) {
+	processorCfg := cfg.(*Config)
+
+	mp := newMaskingProcessor(processorCfg, set.TelemetrySettings)
+	mp.id, mp.signal = set.ID, pipeline.SignalTraces
+
+	return processorhelper.NewTraces(
+		ctx,
+		set,
+		cfg,
+		nextConsumer,
+		mp.processTraces,
+		processorhelper.WithCapabilities(consumer.Capabilities{MutatesData: processorCfg.MutatesData()}),
+		processorhelper.WithStart(mp.start),
+		processorhelper.WithShutdown(mp.shutdown),
+	)
+}
+
+// createProfilesProcessor creates a profiles processor
+func createProfilesProcessor(
+	ctx context.Context,
+	set processor.Settings,
+	cfg component.Config,
+	nextConsumer xconsumer.Profiles,
+) (xprocessor.Profiles, error) {
+	processorCfg := cfg.(*Config)
+
+	mp := newMaskingProcessor(processorCfg, set.TelemetrySettings)
+	mp.id, mp.signal = set.ID, xpipeline.SignalProfiles
+
+	return xprocessorhelper.NewProfiles(
+		ctx,
+		set,
+		cfg,
+		nextConsumer,
+		mp.processProfiles,
+		xprocessorhelper.WithCapabilities(consumer.Capabilities{MutatesData: processorCfg.MutatesData()}),
+		xprocessorhelper.WithStart(mp.start),
+		xprocessorhelper.WithShutdown(mp.shutdown),
+	)
+}
@@ -0,0 +1,58 @@
+package redismasking
+
+import (
+	"context"
+	"testing"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/processor/processortest"
+	"go.opentelemetry.io/collector/processor/xprocessor"
+)
+
+func TestNewFactory(t *testing.T) {
+	factory := NewFactory()
+	require.Equal(t, typeStr, factory.Type().String())
+
+	cfg := factory.CreateDefaultConfig().(*Config)
+	require.Equal(t, masker.NewDefaultConfig(), cfg.Config)
+	require.Equal(t, stability, factory.LogsStability())
+	require.Equal(t, stability, factory.MetricsStability())
+	require.Equal(t, stability, factory.TracesStability())
+	require.Equal(t, component.StabilityLevelDevelopment, factory.(xprocessor.Factory).ProfilesStability())
+}
+
+func TestUnmarshalConfig(t *testing.T) {
+	conf := confmap.NewFromStringMap(map[string]any{
+		"redis_addr":     "redis:6379",
+		"fields_to_mask": []any{"username"},
+	})
+
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, conf.Unmarshal(cfg))
+	require.Equal(t, "redis:6379", cfg.RedisAddr)
+	require.Equal(t, []string{"username"}, cfg.FieldsToMask)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestCapabilities(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	set := processortest.NewNopSettings(factory.Type())
+
+	logs, err := factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	require.True(t, logs.Capabilities().MutatesData)
+
+	// Detection without annotations leaves data unchanged
+	cfg.DetectOnly = masker.DetectOnlyConfig{Enabled: true}
+	logs, err = factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	require.False(t, logs.Capabilities().MutatesData)
+	traces, err := factory.CreateTraces(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	require.False(t, traces.Capabilities().MutatesData)
+}
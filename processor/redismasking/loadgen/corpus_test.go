@@ -0,0 +1,92 @@
+package loadgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := CorpusConfig{Records: 1000, BodySize: 200, PIIDensity: 1.5, UniqueRatio: 0.2, Seed: 1}
+	corpus, err := Generate(cfg)
+	require.NoError(t, err)
+	require.Len(t, corpus.Records, 1000)
+
+	values := 0
+	for _, record := range corpus.Records {
+		require.GreaterOrEqual(t, len(record.Body), cfg.BodySize)
+		require.Less(t, len(record.Body), cfg.BodySize+100)
+		require.Contains(t, []int{1, 2}, len(record.Values))
+		for _, value := range record.Values {
+			require.Contains(t, record.Body, value.Text)
+		}
+		values += len(record.Values)
+	}
+	require.Equal(t, values, corpus.Values)
+	require.InDelta(t, 1500, corpus.Values, 100)
+	// Every unique value is generated once, and the first value of a kind is always new
+	require.InDelta(t, float64(corpus.Values)*cfg.UniqueRatio, corpus.Unique, 60)
+
+	// The same seed generates the same corpus
+	again, err := Generate(cfg)
+	require.NoError(t, err)
+	require.Equal(t, corpus, again)
+}
+
+func TestGenerateKinds(t *testing.T) {
+	corpus, err := Generate(CorpusConfig{Records: 100, BodySize: 50, PIIDensity: 1, UniqueRatio: 1, Kinds: []string{KindCreditCard}})
+	require.NoError(t, err)
+	require.Equal(t, 100, corpus.Unique)
+
+	for _, record := range corpus.Records {
+		require.Len(t, record.Values, 1)
+		require.Equal(t, KindCreditCard, record.Values[0].Kind)
+		require.True(t, luhnValid(record.Values[0].Text), record.Values[0].Text)
+	}
+}
+
+func TestCorpusConfigValidate(t *testing.T) {
+	valid := CorpusConfig{Records: 1, BodySize: 1}
+	testCases := []struct {
+		name        string
+		modify      func(cfg *CorpusConfig)
+		expectedErr string
+	}{
+		{name: "valid", modify: func(*CorpusConfig) {}},
+		{name: "no records", modify: func(cfg *CorpusConfig) { cfg.Records = 0 }, expectedErr: "records must be positive"},
+		{name: "no body", modify: func(cfg *CorpusConfig) { cfg.BodySize = 0 }, expectedErr: "body size must be positive"},
+		{name: "negative density", modify: func(cfg *CorpusConfig) { cfg.PIIDensity = -1 }, expectedErr: "pii density must be non-negative"},
+		{name: "unique ratio above 1", modify: func(cfg *CorpusConfig) { cfg.UniqueRatio = 1.5 }, expectedErr: "unique ratio must be between 0 and 1"},
+		{name: "unsupported kind", modify: func(cfg *CorpusConfig) { cfg.Kinds = []string{"iban"} }, expectedErr: "unsupported kind 'iban'"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid
+			tc.modify(&cfg)
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+// luhnValid reports whether the digits of number pass the Luhn check
+func luhnValid(number string) bool {
+	sum := 0
+	for i, r := range strings.Split(number, "") {
+		d := int(r[0] - '0')
+		if (len(number)-i)%2 == 0 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,159 @@
+package loadgen
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"golang.org/x/sync/errgroup"
+)
+
+// recordAttribute holds the index of a record in the corpus, so masked records
+// are matched with their values even when records are dropped
+const recordAttribute = "loadgen.record"
+
+// Options control how a corpus is masked
+type Options struct {
+	// Workers is the number of batches masked concurrently
+	Workers int
+
+	// BatchSize is the number of records per batch, like the batches of a
+	// batch processor in front of the masking processor
+	BatchSize int
+}
+
+// Validate checks that the workers and batches are positive
+func (o *Options) Validate() error {
+	if o.Workers <= 0 {
+		return errors.New("workers must be positive")
+	}
+	if o.BatchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	return nil
+}
+
+// Result summarizes a run
+type Result struct {
+	Records int
+	Bytes   int64
+
+	// Values is the number of embedded values, and Leaked the number of them
+	// found unchanged in the masked bodies
+	Values int
+	Leaked int
+
+	// LeakedByKind counts the leaked values per kind
+	LeakedByKind map[string]int
+
+	// Duration is the wall time of masking every batch
+	Duration time.Duration
+
+	// Latencies of masking a batch
+	P50 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// RecordsPerSecond is the masking throughput in records
+func (r *Result) RecordsPerSecond() float64 {
+	return float64(r.Records) / r.Duration.Seconds()
+}
+
+// BytesPerSecond is the masking throughput in body bytes
+func (r *Result) BytesPerSecond() float64 {
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+// Run masks corpus with m in batches and measures the throughput, the batch
+// latencies, and how many embedded values were left unmasked. The corpus is
+// not modified.
+func Run(ctx context.Context, m *masker.Masker, corpus *Corpus, opts Options) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Batches are built up front so only masking is measured
+	var batches []plog.Logs
+	for start := 0; start < len(corpus.Records); start += opts.BatchSize {
+		end := min(start+opts.BatchSize, len(corpus.Records))
+		ld := plog.NewLogs()
+		records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+		for i, record := range corpus.Records[start:end] {
+			lr := records.AppendEmpty()
+			lr.Body().SetStr(record.Body)
+			lr.Attributes().PutInt(recordAttribute, int64(start+i))
+		}
+		batches = append(batches, ld)
+	}
+
+	latencies := make([]time.Duration, len(batches))
+	next := make(chan int)
+
+	g, gctx := errgroup.WithContext(ctx)
+	started := time.Now()
+	for w := 0; w < opts.Workers; w++ {
+		g.Go(func() error {
+			for i := range next {
+				batchStart := time.Now()
+				m.MaskLogs(gctx, batches[i])
+				latencies[i] = time.Since(batchStart)
+			}
+			return nil
+		})
+	}
+	g.Go(func() error {
+		defer close(next)
+		for i := range batches {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			select {
+			case next <- i:
+			case <-gctx.Done():
+				return gctx.Err()
+			}
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Records:      len(corpus.Records),
+		Bytes:        corpus.Bytes,
+		Values:       corpus.Values,
+		LeakedByKind: make(map[string]int),
+		Duration:     time.Since(started),
+	}
+	slices.Sort(latencies)
+	if n := len(latencies); n > 0 {
+		result.P50 = latencies[n/2]
+		result.P99 = latencies[min(n*99/100, n-1)]
+		result.Max = latencies[n-1]
+	}
+
+	// Records dropped by a policy leak nothing
+	for _, ld := range batches {
+		records := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		for j := 0; j < records.Len(); j++ {
+			index, ok := records.At(j).Attributes().Get(recordAttribute)
+			if !ok {
+				continue
+			}
+			body := records.At(j).Body().AsString()
+			for _, value := range corpus.Records[index.Int()].Values {
+				if strings.Contains(body, value.Text) {
+					result.Leaked++
+					result.LeakedByKind[value.Kind]++
+				}
+			}
+		}
+	}
+	return result, nil
+}
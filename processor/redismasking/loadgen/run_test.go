@@ -0,0 +1,62 @@
+package loadgen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRun(t *testing.T) {
+	cfg := masker.NewDefaultConfig()
+	cfg.Store = "memory"
+	cfg.HMACKey = "secret"
+	cfg.Patterns = []masker.PatternConfig{{Name: "ipv4", Regex: `\b(?:\d{1,3}\.){3}\d{1,3}\b`}}
+	require.NoError(t, cfg.Validate())
+	store, err := masker.NewMemoryStore(&cfg.MemoryStore)
+	require.NoError(t, err)
+	defer store.Close()
+	m, err := masker.New(&cfg, store, zap.NewNop())
+	require.NoError(t, err)
+
+	corpus, err := Generate(CorpusConfig{Records: 500, BodySize: 120, PIIDensity: 1, UniqueRatio: 0.5, Kinds: []string{KindIPv4, KindEmail}})
+	require.NoError(t, err)
+	body := corpus.Records[0].Body
+
+	result, err := Run(context.Background(), m, corpus, Options{Workers: 4, BatchSize: 64})
+	require.NoError(t, err)
+	require.Equal(t, 500, result.Records)
+	require.Equal(t, corpus.Bytes, result.Bytes)
+	require.Positive(t, result.Duration)
+	require.LessOrEqual(t, result.P50, result.P99)
+	require.LessOrEqual(t, result.P99, result.Max)
+	require.Positive(t, result.RecordsPerSecond())
+
+	// Only the emails are left unmasked, since no pattern matches them
+	require.Zero(t, result.LeakedByKind[KindIPv4])
+	require.Positive(t, result.LeakedByKind[KindEmail])
+	require.Equal(t, result.LeakedByKind[KindEmail], result.Leaked)
+
+	// The corpus can be masked again
+	require.Equal(t, body, corpus.Records[0].Body)
+}
+
+func TestRunCanceled(t *testing.T) {
+	cfg := masker.NewDefaultConfig()
+	cfg.Mode = "lightweight"
+	cfg.HMACKey = "secret"
+	m, err := masker.New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	corpus, err := Generate(CorpusConfig{Records: 10, BodySize: 10})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Run(ctx, m, corpus, Options{Workers: 1, BatchSize: 1})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = Run(context.Background(), m, corpus, Options{Workers: 0, BatchSize: 1})
+	require.EqualError(t, err, "workers must be positive")
+}
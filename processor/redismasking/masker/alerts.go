@@ -0,0 +1,182 @@
+package masker
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Alerting rules generated from the internal telemetry
+const (
+	alertFallbacks          = "fallbacks"
+	alertCacheMissSpike     = "cache_miss_spike"
+	alertTokenCreationSurge = "token_creation_surge"
+	alertCardholderData     = "cardholder_data"
+)
+
+// alertRules are the supported alerting rules in the order they are generated
+var alertRules = []string{alertFallbacks, alertCacheMissSpike, alertTokenCreationSurge, alertCardholderData}
+
+// AlertsConfig selects and tunes the Prometheus alerting rules generated for
+// the internal telemetry of the processor, e.g. by the maskalerts command
+type AlertsConfig struct {
+	// Rules are the generated rules. Empty generates every rule.
+	Rules []string `mapstructure:"rules"`
+
+	// MetricPrefix is prepended to the metric names, matching how the collector
+	// exports its internal telemetry to Prometheus
+	MetricPrefix string `mapstructure:"metric_prefix"`
+
+	// For is how long a condition must hold before the alert fires
+	For time.Duration `mapstructure:"for"`
+
+	// CacheMissRatio is the share of token lookups missing the store above which
+	// cache_miss_spike fires
+	CacheMissRatio float64 `mapstructure:"cache_miss_ratio"`
+
+	// TokenCreationFactor is how many times the usual rate new tokens must be
+	// created at for token_creation_surge to fire
+	TokenCreationFactor float64 `mapstructure:"token_creation_factor"`
+
+	// Labels are added to every rule, e.g. to route the alerts
+	Labels map[string]string `mapstructure:"labels"`
+}
+
+// Validate checks the selected rules and their thresholds
+func (cfg *AlertsConfig) Validate() error {
+	for _, rule := range cfg.Rules {
+		if !slices.Contains(alertRules, rule) {
+			return fmt.Errorf("unsupported alerts rule '%s'", rule)
+		}
+	}
+	if cfg.For < 0 {
+		return errors.New("alerts for must be non-negative")
+	}
+	if cfg.CacheMissRatio <= 0 || cfg.CacheMissRatio > 1 {
+		return errors.New("alerts cache_miss_ratio must be between 0 and 1")
+	}
+	if cfg.TokenCreationFactor <= 1 {
+		return errors.New("alerts token_creation_factor must be greater than 1")
+	}
+	return nil
+}
+
+// AlertRuleFile is a Prometheus rule file
+type AlertRuleFile struct {
+	Groups []AlertRuleGroup `yaml:"groups"`
+}
+
+// AlertRuleGroup is a group of rules of a Prometheus rule file
+type AlertRuleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []AlertRule `yaml:"rules"`
+}
+
+// AlertRule is a Prometheus alerting rule
+type AlertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// RuleFile generates the selected rules. Rates are aggregated per scrape target,
+// so every collector alerts on its own.
+func (cfg *AlertsConfig) RuleFile() *AlertRuleFile {
+	metric := func(name string) string {
+		return cfg.MetricPrefix + name
+	}
+	fallbacks := metric("redismasking_fallbacks_total")
+	lookups := metric("redismasking_token_lookups_total")
+	created := metric("redismasking_token_created_total")
+	cardholderDropped := metric("redismasking_log_cardholder_data_dropped_total")
+
+	group := AlertRuleGroup{Name: "redismasking"}
+	for _, name := range alertRules {
+		if len(cfg.Rules) > 0 && !slices.Contains(cfg.Rules, name) {
+			continue
+		}
+
+		var rule AlertRule
+		switch name {
+		case alertFallbacks:
+			rule = newAlertRule("RedisMaskingFallbacks",
+				fmt.Sprintf("sum by (job, instance, fallback, cause) (rate(%s[5m])) > 0", fallbacks),
+				cfg.For, "warning",
+				"Values are not masked as configured",
+				"{{ $labels.instance }} takes the {{ $labels.fallback }} fallback because of {{ $labels.cause }}.")
+		case alertCacheMissSpike:
+			rule = newAlertRule("RedisMaskingCacheMissSpike",
+				fmt.Sprintf(`sum by (job, instance) (rate(%s{result="miss"}[10m])) / sum by (job, instance) (rate(%s[10m])) > %g`,
+					lookups, lookups, cfg.CacheMissRatio),
+				cfg.For, "warning",
+				"Most token lookups miss the store",
+				"{{ $labels.instance }} misses {{ $value | humanizePercentage }} of its token lookups, e.g. after mappings expired or were flushed.")
+		case alertTokenCreationSurge:
+			// Rates below one token per second are not a surge, e.g. right after a deployment
+			rule = newAlertRule("RedisMaskingTokenCreationSurge",
+				fmt.Sprintf("sum by (job, instance) (rate(%s[10m])) > %g * sum by (job, instance) (rate(%s[6h] offset 10m)) and sum by (job, instance) (rate(%s[10m])) > 1",
+					created, cfg.TokenCreationFactor, created, created),
+				cfg.For, "warning",
+				"New tokens are created much faster than usual",
+				"{{ $labels.instance }} creates {{ $value | humanize }} tokens per second, e.g. because of a new source or a high-cardinality field.")
+		case alertCardholderData:
+			// A single record is an incident, so the alert fires right away
+			rule = newAlertRule("RedisMaskingCardholderData",
+				fmt.Sprintf("sum by (job, instance) (increase(%s[5m])) > 0", cardholderDropped),
+				0, "critical",
+				"Log records held cardholder data",
+				"{{ $labels.instance }} dropped {{ $value | humanize }} log records holding a card number next to its expiry date or CVV.")
+		}
+
+		for key, value := range cfg.Labels {
+			rule.Labels[key] = value
+		}
+		group.Rules = append(group.Rules, rule)
+	}
+	return &AlertRuleFile{Groups: []AlertRuleGroup{group}}
+}
+
+// newAlertRule returns a rule firing once expr holds for forDuration
+func newAlertRule(alert, expr string, forDuration time.Duration, severity, summary, description string) AlertRule {
+	return AlertRule{
+		Alert:  alert,
+		Expr:   expr,
+		For:    promDuration(forDuration),
+		Labels: map[string]string{"severity": severity},
+		Annotations: map[string]string{
+			"summary":     summary,
+			"description": description,
+		},
+	}
+}
+
+// MarshalRuleFile generates the selected rules as a Prometheus rule file
+func (cfg *AlertsConfig) MarshalRuleFile() ([]byte, error) {
+	data, err := yaml.Marshal(cfg.RuleFile())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal alerting rules: %w", err)
+	}
+	return data, nil
+}
+
+// promDuration formats d as a Prometheus duration, e.g. "1h30m", leaving out
+// zero units. A zero duration is empty.
+func promDuration(d time.Duration) string {
+	var b strings.Builder
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}, {"ms", time.Millisecond}} {
+		if n := d / unit.size; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+			d -= n * unit.size
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package masker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAlertsRuleFile(t *testing.T) {
+	cfg := NewDefaultConfig().Alerts
+	require.NoError(t, cfg.Validate())
+
+	file := cfg.RuleFile()
+	require.Len(t, file.Groups, 1)
+	alerts := []string{}
+	for _, rule := range file.Groups[0].Rules {
+		alerts = append(alerts, rule.Alert)
+	}
+	assert.Equal(t, []string{"RedisMaskingFallbacks", "RedisMaskingCacheMissSpike", "RedisMaskingTokenCreationSurge", "RedisMaskingCardholderData"}, alerts)
+
+	cfg.Rules = []string{alertCacheMissSpike}
+	cfg.MetricPrefix = ""
+	cfg.For = 90 * time.Minute
+	cfg.CacheMissRatio = 0.8
+	cfg.Labels = map[string]string{"team": "privacy"}
+	data, err := cfg.MarshalRuleFile()
+	require.NoError(t, err)
+
+	var parsed AlertRuleFile
+	require.NoError(t, yaml.Unmarshal(data, &parsed))
+	require.Len(t, parsed.Groups[0].Rules, 1)
+	rule := parsed.Groups[0].Rules[0]
+	assert.Equal(t, `sum by (job, instance) (rate(redismasking_token_lookups_total{result="miss"}[10m])) / sum by (job, instance) (rate(redismasking_token_lookups_total[10m])) > 0.8`, rule.Expr)
+	assert.Equal(t, "1h30m", rule.For)
+	assert.Equal(t, map[string]string{"severity": "warning", "team": "privacy"}, rule.Labels)
+}
+
+func TestAlertsValidate(t *testing.T) {
+	valid := NewDefaultConfig().Alerts
+
+	cfg := valid
+	cfg.Rules = []string{"disk_full"}
+	require.EqualError(t, cfg.Validate(), "unsupported alerts rule 'disk_full'")
+
+	cfg = valid
+	cfg.For = -time.Minute
+	require.EqualError(t, cfg.Validate(), "alerts for must be non-negative")
+
+	cfg = valid
+	cfg.CacheMissRatio = 1.5
+	require.EqualError(t, cfg.Validate(), "alerts cache_miss_ratio must be between 0 and 1")
+
+	cfg = valid
+	cfg.TokenCreationFactor = 1
+	require.EqualError(t, cfg.Validate(), "alerts token_creation_factor must be greater than 1")
+}
+
+func TestPromDuration(t *testing.T) {
+	assert.Equal(t, "", promDuration(0))
+	assert.Equal(t, "10m", promDuration(10*time.Minute))
+	assert.Equal(t, "1h30s", promDuration(time.Hour+30*time.Second))
+}
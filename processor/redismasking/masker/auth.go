@@ -0,0 +1,154 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Supported Redis auth providers
+const (
+	authProviderPassword = ""
+	authProviderAWSIAM   = "aws_iam"
+	authProviderEntra    = "azure_entra"
+)
+
+// AuthConfig selects how the Redis client authenticates. Token providers
+// replace the static redis_password with short-lived tokens that are refreshed
+// before they expire, so no password has to be distributed to the agents.
+type AuthConfig struct {
+	// Provider is "" (redis_password), "aws_iam", or "azure_entra"
+	Provider string `mapstructure:"provider"`
+
+	// AWSIAM generates IAM auth tokens for ElastiCache and MemoryDB
+	AWSIAM AWSIAMAuthConfig `mapstructure:"aws_iam"`
+
+	// AzureEntra requests Microsoft Entra ID tokens for Azure Cache for Redis
+	AzureEntra AzureEntraAuthConfig `mapstructure:"azure_entra"`
+}
+
+// Validate checks the provider and its settings
+func (cfg *AuthConfig) Validate() error {
+	switch cfg.Provider {
+	case authProviderPassword:
+		return nil
+	case authProviderAWSIAM:
+		return cfg.AWSIAM.Validate()
+	case authProviderEntra:
+		return nil
+	default:
+		return fmt.Errorf("unsupported redis_auth provider '%s'", cfg.Provider)
+	}
+}
+
+// Enabled reports whether a token provider is configured
+func (cfg *AuthConfig) Enabled() bool {
+	return cfg.Provider != authProviderPassword
+}
+
+// ValidateRedisAuth checks that a token provider is neither combined with a
+// static password nor used without TLS, which would expose the tokens
+func ValidateRedisAuth(cfg *AuthConfig, password string, tlsConfig *TLSConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if !cfg.Enabled() {
+		return nil
+	}
+	if password != "" {
+		return errors.New("redis_password is not used with a redis_auth provider")
+	}
+	if !tlsConfig.Enabled {
+		return errors.New("redis_auth provider requires tls to be enabled")
+	}
+	return nil
+}
+
+// apply sets the credentials of the configured token provider on options
+func (cfg *AuthConfig) apply(options *redis.Options) error {
+	switch cfg.Provider {
+	case authProviderAWSIAM:
+		source, err := cfg.AWSIAM.tokenSource()
+		if err != nil {
+			return err
+		}
+		credentials := newTokenCredentials(source, awsIAMRefreshBefore)
+		options.CredentialsProviderContext = credentials.credentials
+		limitConnAge(options, awsIAMMaxConnAge)
+	case authProviderEntra:
+		source, err := cfg.AzureEntra.tokenSource()
+		if err != nil {
+			return err
+		}
+		credentials := newTokenCredentials(source, entraRefreshBefore)
+		options.CredentialsProviderContext = credentials.credentials
+		limitConnAge(options, entraMaxConnAge)
+	}
+	return nil
+}
+
+// limitConnAge replaces connections before the server closes them, when the
+// pool does not already replace them sooner
+func limitConnAge(options *redis.Options, maxAge time.Duration) {
+	if options.ConnMaxLifetime <= 0 || options.ConnMaxLifetime > maxAge {
+		options.ConnMaxLifetime = maxAge
+	}
+}
+
+// authToken is a short-lived credential of a token provider
+type authToken struct {
+	username string
+	password string
+	expiry   time.Time
+}
+
+// tokenSource returns a new token
+type tokenSource func(ctx context.Context) (authToken, error)
+
+// tokenCredentials authenticates new connections with the token of a source.
+// The token is shared by every connection until less than refreshBefore of its
+// lifetime is left. Open connections are not re-authenticated, so the pool
+// replaces them before the server closes them.
+type tokenCredentials struct {
+	source        tokenSource
+	refreshBefore time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	current authToken
+}
+
+func newTokenCredentials(source tokenSource, refreshBefore time.Duration) *tokenCredentials {
+	return &tokenCredentials{
+		source:        source,
+		refreshBefore: refreshBefore,
+		now:           time.Now,
+	}
+}
+
+// credentials returns the credentials of a new connection, refreshing the token
+// when it is about to expire. A token that fails to refresh is used as long as
+// it is still valid.
+func (c *tokenCredentials) credentials(ctx context.Context) (string, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.current.password != "" && now.Add(c.refreshBefore).Before(c.current.expiry) {
+		return c.current.username, c.current.password, nil
+	}
+
+	token, err := c.source(ctx)
+	if err != nil {
+		if c.current.password != "" && now.Before(c.current.expiry) {
+			return c.current.username, c.current.password, nil
+		}
+		return "", "", fmt.Errorf("failed to fetch Redis auth token: %w", err)
+	}
+	c.current = token
+	return c.current.username, c.current.password, nil
+}
@@ -0,0 +1,256 @@
+package masker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRedisAuth(t *testing.T) {
+	awsIAM := AuthConfig{Provider: "aws_iam", AWSIAM: AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens"}}
+	enabledTLS := &TLSConfig{Enabled: true}
+
+	testCases := []struct {
+		name        string
+		auth        AuthConfig
+		password    string
+		tls         *TLSConfig
+		expectedErr string
+	}{
+		{name: "password", password: "secret", tls: &TLSConfig{}},
+		{name: "aws_iam", auth: awsIAM, tls: enabledTLS},
+		{name: "azure_entra", auth: AuthConfig{Provider: "azure_entra"}, tls: enabledTLS},
+		{
+			name:        "unsupported provider",
+			auth:        AuthConfig{Provider: "kerberos"},
+			tls:         enabledTLS,
+			expectedErr: "unsupported redis_auth provider 'kerberos'",
+		},
+		{
+			name:        "with password",
+			auth:        awsIAM,
+			password:    "secret",
+			tls:         enabledTLS,
+			expectedErr: "redis_password is not used with a redis_auth provider",
+		},
+		{
+			name:        "without tls",
+			auth:        awsIAM,
+			tls:         &TLSConfig{},
+			expectedErr: "redis_auth provider requires tls to be enabled",
+		},
+		{
+			name:        "missing user",
+			auth:        AuthConfig{Provider: "aws_iam", AWSIAM: AWSIAMAuthConfig{CacheName: "tokens"}},
+			tls:         enabledTLS,
+			expectedErr: "redis_auth aws_iam user_id and cache_name are required",
+		},
+		{
+			name:        "unsupported service",
+			auth:        AuthConfig{Provider: "aws_iam", AWSIAM: AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens", Service: "dynamodb"}},
+			tls:         enabledTLS,
+			expectedErr: "unsupported redis_auth aws_iam service 'dynamodb'",
+		},
+		{
+			name:        "serverless memorydb",
+			auth:        AuthConfig{Provider: "aws_iam", AWSIAM: AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens", Service: "memorydb", Serverless: true}},
+			tls:         enabledTLS,
+			expectedErr: "redis_auth aws_iam serverless is only supported by elasticache",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRedisAuth(&tc.auth, tc.password, tc.tls)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestTokenCredentials(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	fetches := 0
+	var fetchErr error
+	credentials := newTokenCredentials(func(context.Context) (authToken, error) {
+		if fetchErr != nil {
+			return authToken{}, fetchErr
+		}
+		fetches++
+		return authToken{username: "masking", password: fmt.Sprintf("token-%d", fetches), expiry: now.Add(15 * time.Minute)}, nil
+	}, 3*time.Minute)
+	credentials.now = func() time.Time { return now }
+
+	username, password, err := credentials.credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "masking", username)
+	assert.Equal(t, "token-1", password)
+
+	// New connections share the token until it is about to expire
+	now = now.Add(11 * time.Minute)
+	_, password, err = credentials.credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", password)
+
+	now = now.Add(time.Minute)
+	_, password, err = credentials.credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", password)
+
+	// A failed refresh keeps the valid token in use
+	fetchErr = errors.New("metadata service unavailable")
+	now = now.Add(13 * time.Minute)
+	_, password, err = credentials.credentials(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", password)
+
+	now = now.Add(2 * time.Minute)
+	_, _, err = credentials.credentials(context.Background())
+	require.EqualError(t, err, "failed to fetch Redis auth token: metadata service unavailable")
+}
+
+func TestTokenCredentialsConnect(t *testing.T) {
+	server := miniredis.RunT(t)
+	require.NoError(t, server.Set("key", "value"))
+	server.RequireUserAuth("masking", "token-1")
+
+	credentials := newTokenCredentials(func(context.Context) (authToken, error) {
+		return authToken{username: "masking", password: "token-1", expiry: time.Now().Add(time.Hour)}, nil
+	}, time.Minute)
+	client := redis.NewClient(&redis.Options{
+		Addr:                       server.Addr(),
+		CredentialsProviderContext: credentials.credentials,
+	})
+	t.Cleanup(func() { client.Close() })
+
+	value, err := client.Get(context.Background(), "key").Result()
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestAWSIAMToken(t *testing.T) {
+	provider := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cfg := AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens"}
+	token, err := cfg.token(context.Background(), v4.NewSigner(), provider, "us-east-1", now)
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(token, "tokens/?"), token)
+	query, err := url.ParseQuery(strings.TrimPrefix(token, "tokens/?"))
+	require.NoError(t, err)
+	assert.Equal(t, "connect", query.Get("Action"))
+	assert.Equal(t, "masking", query.Get("User"))
+	assert.Equal(t, "900", query.Get("X-Amz-Expires"))
+	assert.Equal(t, "AKIDEXAMPLE/20260102/us-east-1/elasticache/aws4_request", query.Get("X-Amz-Credential"))
+	assert.Equal(t, "20260102T030405Z", query.Get("X-Amz-Date"))
+	assert.NotEmpty(t, query.Get("X-Amz-Signature"))
+	assert.Empty(t, query.Get("ResourceType"))
+
+	cfg = AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens", Serverless: true}
+	token, err = cfg.token(context.Background(), v4.NewSigner(), provider, "us-east-1", now)
+	require.NoError(t, err)
+	query, err = url.ParseQuery(strings.TrimPrefix(token, "tokens/?"))
+	require.NoError(t, err)
+	assert.Equal(t, "ServerlessCache", query.Get("ResourceType"))
+
+	cfg = AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens", Service: "memorydb"}
+	token, err = cfg.token(context.Background(), v4.NewSigner(), provider, "eu-west-1", now)
+	require.NoError(t, err)
+	query, err = url.ParseQuery(strings.TrimPrefix(token, "tokens/?"))
+	require.NoError(t, err)
+	assert.Equal(t, "AKIDEXAMPLE/20260102/eu-west-1/memorydb/aws4_request", query.Get("X-Amz-Credential"))
+}
+
+// testCredential is an Azure credential issuing fixed tokens
+type testCredential struct {
+	token  azcore.AccessToken
+	scopes []string
+}
+
+func (c *testCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.scopes = options.Scopes
+	return c.token, nil
+}
+
+// testJWT returns an unsigned JWT with claims
+func testJWT(t *testing.T, claims map[string]any) string {
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestAzureEntraToken(t *testing.T) {
+	expiry := time.Date(2026, 1, 2, 4, 4, 5, 0, time.UTC)
+	jwt := testJWT(t, map[string]any{"oid": "7d4f1c2e-object-id", "aud": "https://redis.azure.com"})
+	credential := &testCredential{token: azcore.AccessToken{Token: jwt, ExpiresOn: expiry}}
+
+	// The user defaults to the object ID of the identity
+	cfg := AzureEntraAuthConfig{}
+	token, err := cfg.credentialSource(credential)(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, authToken{username: "7d4f1c2e-object-id", password: jwt, expiry: expiry}, token)
+	assert.Equal(t, []string{"https://redis.azure.com/.default"}, credential.scopes)
+
+	cfg = AzureEntraAuthConfig{Username: "masking", Scope: "https://redis.example/.default"}
+	token, err = cfg.credentialSource(credential)(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "masking", token.username)
+	assert.Equal(t, []string{"https://redis.example/.default"}, credential.scopes)
+
+	credential.token.Token = testJWT(t, map[string]any{"aud": "https://redis.azure.com"})
+	_, err = (&AzureEntraAuthConfig{}).credentialSource(credential)(context.Background())
+	require.EqualError(t, err, "invalid Entra ID token: no oid claim, set username")
+
+	credential.token.Token = "opaque"
+	_, err = (&AzureEntraAuthConfig{}).credentialSource(credential)(context.Background())
+	require.EqualError(t, err, "invalid Entra ID token: not a JWT")
+}
+
+func TestRedisAuthOptions(t *testing.T) {
+	cfg := NewDefaultConfig()
+	options, err := cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Nil(t, options.CredentialsProviderContext)
+	assert.Zero(t, options.ConnMaxLifetime)
+
+	// Connections are replaced before the server closes them
+	cfg.RedisAuth = AuthConfig{Provider: "aws_iam", AWSIAM: AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens", Region: "us-east-1"}}
+	options, err = cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.NotNil(t, options.CredentialsProviderContext)
+	assert.Equal(t, 11*time.Hour, options.ConnMaxLifetime)
+
+	// A shorter connection age of the pool is kept
+	cfg.RedisPool.MaxConnAge = time.Hour
+	options, err = cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, options.ConnMaxLifetime)
+
+	// Connections are replaced before their Entra ID token expires
+	cfg.RedisAuth = AuthConfig{Provider: "azure_entra", AzureEntra: AzureEntraAuthConfig{ClientID: "4e2a9d17-client-id"}}
+	options, err = cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.NotNil(t, options.CredentialsProviderContext)
+	assert.Equal(t, 4*time.Minute, options.ConnMaxLifetime)
+}
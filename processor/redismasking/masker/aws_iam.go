@@ -0,0 +1,132 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Services accepting IAM auth tokens
+const (
+	awsServiceElastiCache = "elasticache"
+	awsServiceMemoryDB    = "memorydb"
+)
+
+const (
+	// awsIAMTokenLifetime is how long an IAM auth token can open connections
+	awsIAMTokenLifetime = 15 * time.Minute
+
+	// awsIAMRefreshBefore is how long before its expiry a token is replaced
+	awsIAMRefreshBefore = 3 * time.Minute
+
+	// awsIAMMaxConnAge replaces connections before the server closes IAM
+	// authenticated connections after 12 hours
+	awsIAMMaxConnAge = 11 * time.Hour
+
+	// emptyPayloadHash is the SHA-256 hash of the empty body of the signed request
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// AWSIAMAuthConfig defines the IAM auth tokens of an ElastiCache or MemoryDB
+// user. Tokens are signed with the credentials of the default AWS credential
+// chain, e.g. the instance profile or the web identity of the pod.
+type AWSIAMAuthConfig struct {
+	// UserID is the IAM enabled user the tokens authenticate as
+	UserID string `mapstructure:"user_id"`
+
+	// CacheName is the replication group, serverless cache, or MemoryDB cluster
+	CacheName string `mapstructure:"cache_name"`
+
+	// Region of the cache. The region of the default AWS configuration is used when empty.
+	Region string `mapstructure:"region"`
+
+	// Service is "elasticache" (default) or "memorydb"
+	Service string `mapstructure:"service"`
+
+	// Serverless marks CacheName as an ElastiCache Serverless cache
+	Serverless bool `mapstructure:"serverless"`
+}
+
+// Validate checks that the user and cache are set and that the service is supported
+func (cfg *AWSIAMAuthConfig) Validate() error {
+	if cfg.UserID == "" || cfg.CacheName == "" {
+		return errors.New("redis_auth aws_iam user_id and cache_name are required")
+	}
+	switch cfg.Service {
+	case "", awsServiceElastiCache:
+	case awsServiceMemoryDB:
+		if cfg.Serverless {
+			return errors.New("redis_auth aws_iam serverless is only supported by elasticache")
+		}
+	default:
+		return fmt.Errorf("unsupported redis_auth aws_iam service '%s'", cfg.Service)
+	}
+	return nil
+}
+
+// tokenSource returns the source of the IAM auth tokens, signed with the
+// credentials of the default AWS configuration
+func (cfg *AWSIAMAuthConfig) tokenSource() (tokenSource, error) {
+	options := []func(*config.LoadOptions) error{}
+	if cfg.Region != "" {
+		options = append(options, config.WithRegion(cfg.Region))
+	}
+	// Credentials are only resolved when the first token is signed
+	awsConfig, err := config.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if awsConfig.Region == "" {
+		return nil, errors.New("redis_auth aws_iam region is required")
+	}
+
+	signer := v4.NewSigner()
+	return func(ctx context.Context) (authToken, error) {
+		now := time.Now()
+		token, err := cfg.token(ctx, signer, awsConfig.Credentials, awsConfig.Region, now)
+		if err != nil {
+			return authToken{}, err
+		}
+		return authToken{username: cfg.UserID, password: token, expiry: now.Add(awsIAMTokenLifetime)}, nil
+	}, nil
+}
+
+// token signs an IAM auth token: a presigned connect request to the cache,
+// without its scheme
+func (cfg *AWSIAMAuthConfig) token(ctx context.Context, signer *v4.Signer, provider aws.CredentialsProvider, region string, now time.Time) (string, error) {
+	credentials, err := provider.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+
+	query := url.Values{
+		"Action":        {"connect"},
+		"User":          {cfg.UserID},
+		"X-Amz-Expires": {fmt.Sprint(int(awsIAMTokenLifetime.Seconds()))},
+	}
+	if cfg.Serverless {
+		query.Set("ResourceType", "ServerlessCache")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+cfg.CacheName+"/?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create IAM auth request: %w", err)
+	}
+
+	service := cfg.Service
+	if service == "" {
+		service = awsServiceElastiCache
+	}
+	uri, _, err := signer.PresignHTTP(ctx, credentials, req, emptyPayloadHash, service, region, now.UTC())
+	if err != nil {
+		return "", fmt.Errorf("failed to sign IAM auth token: %w", err)
+	}
+	return strings.TrimPrefix(uri, "http://"), nil
+}
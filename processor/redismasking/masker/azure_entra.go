@@ -0,0 +1,109 @@
+package masker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+const (
+	// entraDefaultScope is the scope of the tokens accepted by Azure Cache for
+	// Redis and Azure Managed Redis
+	entraDefaultScope = "https://redis.azure.com/.default"
+
+	// entraRefreshBefore is how long before its expiry a token is replaced. The
+	// credential serves a cached token until 5 minutes before it expires.
+	entraRefreshBefore = 5 * time.Minute
+
+	// entraMaxConnAge replaces connections before the token they were
+	// authenticated with expires, when the server closes them
+	entraMaxConnAge = 4 * time.Minute
+)
+
+// AzureEntraAuthConfig defines the Microsoft Entra ID tokens of an Azure Cache
+// for Redis or Azure Managed Redis user. Tokens are requested with the default
+// Azure credential chain, e.g. the environment, the workload identity of the
+// pod, or the managed identity of the VM.
+type AzureEntraAuthConfig struct {
+	// ClientID selects a user-assigned managed identity instead of the default
+	// credential chain
+	ClientID string `mapstructure:"client_id"`
+
+	// Username is the user of the access policy assignment. The object ID of the
+	// identity, read from the token, is used when empty.
+	Username string `mapstructure:"username"`
+
+	// Scope of the requested tokens
+	Scope string `mapstructure:"scope"`
+}
+
+// tokenSource returns the source of the Entra ID tokens, requested with the
+// configured managed identity or the default Azure credential chain
+func (cfg *AzureEntraAuthConfig) tokenSource() (tokenSource, error) {
+	var credential azcore.TokenCredential
+	var err error
+	if cfg.ClientID != "" {
+		credential, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ID: azidentity.ClientID(cfg.ClientID),
+		})
+	} else {
+		credential, err = azidentity.NewDefaultAzureCredential(nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure credential: %w", err)
+	}
+	return cfg.credentialSource(credential), nil
+}
+
+// credentialSource returns the source of the tokens of credential
+func (cfg *AzureEntraAuthConfig) credentialSource(credential azcore.TokenCredential) tokenSource {
+	scope := cfg.Scope
+	if scope == "" {
+		scope = entraDefaultScope
+	}
+	return func(ctx context.Context) (authToken, error) {
+		token, err := credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{scope}})
+		if err != nil {
+			return authToken{}, fmt.Errorf("failed to get Entra ID token: %w", err)
+		}
+
+		username := cfg.Username
+		if username == "" {
+			if username, err = objectID(token.Token); err != nil {
+				return authToken{}, err
+			}
+		}
+		return authToken{username: username, password: token.Token, expiry: token.ExpiresOn}, nil
+	}
+}
+
+// objectID reads the oid claim of a JWT. The signature is not verified, since
+// the token was just issued to the collector itself.
+func objectID(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("invalid Entra ID token: not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("failed to decode Entra ID token: %w", err)
+	}
+	var claims struct {
+		OID string `json:"oid"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("failed to decode Entra ID token: %w", err)
+	}
+	if claims.OID == "" {
+		return "", errors.New("invalid Entra ID token: no oid claim, set username")
+	}
+	return claims.OID, nil
+}
@@ -0,0 +1,73 @@
+package masker
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// maskListMembers masks the member values of a W3C baggage or tracestate header,
+// e.g. "userid=alice,tenant=acme". Only the values of members are replaced, so
+// keys, baggage properties, and member order can still be used to debug
+// propagation. When members is empty every value is masked. Text without any
+// key=value member is not a header and is pattern-scanned instead.
+func (m *Masker) maskListMembers(ctx context.Context, text string, members []string, properties bool, onMask func(category, token string)) string {
+	entries := strings.Split(text, ",")
+	parsed := false
+	for i, entry := range entries {
+		// Baggage members may carry properties after the value, e.g. "k=v;ttl=60"
+		pair, rest := entry, ""
+		if properties {
+			if j := strings.IndexByte(entry, ';'); j >= 0 {
+				pair, rest = entry[:j], entry[j:]
+			}
+		}
+
+		key, value, ok := strings.Cut(pair, "=")
+		name := strings.TrimSpace(key)
+		if !ok || name == "" {
+			continue
+		}
+		parsed = true
+
+		value = strings.TrimSpace(value)
+		if value == "" || (len(members) > 0 && !slices.Contains(members, name)) {
+			continue
+		}
+
+		entries[i] = key + "=" + m.memberToken(ctx, name, value, onMask) + rest
+	}
+
+	if !parsed {
+		return m.maskString(ctx, text, onMask)
+	}
+	return strings.Join(entries, ",")
+}
+
+// memberToken returns the token of a header member value. Baggage values are
+// percent-encoded, so they are decoded first to map the same value consistently.
+func (m *Masker) memberToken(ctx context.Context, name, value string, onMask func(category, token string)) string {
+	original := value
+	if decoded, err := url.PathUnescape(value); err == nil {
+		original = decoded
+	}
+
+	category := memberCategory(name)
+	token, err := m.MaskValue(ctx, original, category)
+	if err != nil {
+		m.logError("Failed to mask header member", err, zap.String("member", name))
+		return value
+	}
+	if onMask != nil {
+		onMask(category, token)
+	}
+	return token
+}
+
+// memberCategory returns the category used for a masked baggage or tracestate member
+func memberCategory(name string) string {
+	return "member_" + name
+}
@@ -0,0 +1,61 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestBaggageStructuredFields(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.StructuredFields = []StructuredFieldConfig{
+		{
+			Strategy: strategyBaggage,
+			Keys:     []string{"http.request.header.baggage"},
+			Members:  []string{"userid", "email"},
+		},
+		{
+			Strategy: strategyTraceState,
+			Keys:     []string{"http.request.header.tracestate"},
+		},
+	}
+	m, _ := newTestMasker(t, &cfg)
+	token := func(name, value string) string {
+		return m.generateMaskedValue(value, memberCategory(name))
+	}
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("http.request.header.baggage", "userid=alice, email=alice%40example.com;ttl=60,tenant=acme")
+	lr.Attributes().PutStr("http.request.header.tracestate", "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// Only configured members are masked and properties are kept
+	baggage, _ := lr.Attributes().Get("http.request.header.baggage")
+	assert.Equal(t, "userid="+token("userid", "alice")+", email="+token("email", "alice@example.com")+";ttl=60,tenant=acme", baggage.Str())
+
+	// Without members every value is masked
+	traceState, _ := lr.Attributes().Get("http.request.header.tracestate")
+	assert.Equal(t, "rojo="+token("rojo", "00f067aa0ba902b7")+",congo="+token("congo", "t61rcWkgMzE"), traceState.Str())
+}
+
+func TestBaggageFallsBackToPatterns(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.StructuredFields = []StructuredFieldConfig{
+		{Strategy: strategyBaggage, Keys: []string{bodyKey}},
+	}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Body().SetStr("request from 192.168.1.1")
+
+	m.MaskLogs(context.Background(), ld)
+
+	assert.Equal(t, "request from "+m.generateMaskedValue("192.168.1.1", "ipv4"), lr.Body().Str())
+}
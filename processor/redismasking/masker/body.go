@@ -0,0 +1,94 @@
+package masker
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// maskMapBody masks a structured log body in place, e.g. one parsed from JSON by
+// the filelog receiver. Members are handled like record attributes of the same
+// key, except that every string is pattern-scanned and nested maps and slices are
+// traversed. A configured field holding a map or slice is masked whole.
+func (m *Masker) maskMapBody(ctx context.Context, body pcommon.Map, onMask func(category, token string)) {
+	m.maskBodyMembers(ctx, "", body, onMask)
+}
+
+// maskBodyMembers masks the members of members, whose dotted path from the log
+// body is prefix
+func (m *Masker) maskBodyMembers(ctx context.Context, prefix string, members pcommon.Map, onMask func(category, token string)) {
+	members.RemoveIf(func(k string, _ pcommon.Value) bool {
+		field, ok := m.bodyField(memberPath(prefix, k), k)
+		return ok && m.drops(m.fieldsToMask, field)
+	})
+	members.Range(func(k string, v pcommon.Value) bool {
+		m.maskBodyMember(ctx, memberPath(prefix, k), k, v, onMask)
+		return true
+	})
+}
+
+// memberPath returns the dotted path of member k of the members at prefix
+func memberPath(prefix, k string) string {
+	if prefix == "" {
+		return k
+	}
+	return prefix + "." + k
+}
+
+// maskBodyMember masks the value v of member k of a structured body at path
+func (m *Masker) maskBodyMember(ctx context.Context, path, k string, v pcommon.Value, onMask func(category, token string)) {
+	if field, ok := m.bodyField(path, k); ok {
+		m.maskAttribute(ctx, field, v, true, onMask)
+		return
+	}
+
+	switch v.Type() {
+	case pcommon.ValueTypeMap:
+		m.maskBodyMembers(ctx, path, v.Map(), onMask)
+	case pcommon.ValueTypeSlice:
+		// Elements are masked like the member holding the slice
+		for i := 0; i < v.Slice().Len(); i++ {
+			m.maskBodyMember(ctx, path, k, v.Slice().At(i), onMask)
+		}
+	default:
+		m.maskAttribute(ctx, k, v, true, onMask)
+	}
+}
+
+// bodyField returns the configured field matching the member k at path: with
+// body_keys set to "path" its dotted path, which is more specific, or else the
+// key itself. Flat members with dotted keys, e.g. from fluent-bit, and nested
+// members then share a field.
+func (m *Masker) bodyField(path, k string) (string, bool) {
+	if m.config.BodyKeys == bodyKeysPath && m.isConfiguredField(path) {
+		return path, true
+	}
+	if m.isConfiguredField(k) {
+		return k, true
+	}
+	return "", false
+}
+
+// isConfiguredField reports whether key is in fields_to_mask or structured_fields
+func (m *Masker) isConfiguredField(key string) bool {
+	_, structured := m.structuredFields[key]
+	return structured || slices.Contains(m.fieldsToMask, key)
+}
+
+// maskSliceBody masks the elements of a slice log body in place. Strings are
+// pattern-scanned and maps are masked like map bodies.
+func (m *Masker) maskSliceBody(ctx context.Context, body pcommon.Slice, onMask func(category, token string)) {
+	for i := 0; i < body.Len(); i++ {
+		switch element := body.At(i); element.Type() {
+		case pcommon.ValueTypeMap:
+			m.maskMapBody(ctx, element.Map(), onMask)
+		case pcommon.ValueTypeSlice:
+			m.maskSliceBody(ctx, element.Slice(), onMask)
+		case pcommon.ValueTypeStr:
+			if masked := m.maskString(ctx, element.Str(), onMask); masked != element.Str() {
+				element.SetStr(masked)
+			}
+		}
+	}
+}
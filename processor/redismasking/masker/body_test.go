@@ -0,0 +1,118 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestMaskMapBody(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"user", "card"}
+	cfg.ExcludeKeys = []string{"gateway"}
+	m, _ := newTestMasker(t, &cfg)
+
+	lr := plog.NewLogRecord()
+	body := lr.Body().SetEmptyMap()
+	body.PutStr("message", "request from 192.168.1.1")
+	body.PutStr("gateway", "192.168.1.1")
+	body.PutInt("status", 200)
+	request := body.PutEmptyMap("request")
+	request.PutStr("user", "alice")
+	request.PutEmptySlice("peers").AppendEmpty().SetStr("192.168.1.1")
+	card := body.PutEmptyMap("card")
+	card.PutStr("number", "4111111111111111")
+	body.PutEmptySlice("users").AppendEmpty().SetEmptyMap().PutStr("user", "alice")
+
+	m.MaskLogRecord(context.Background(), lr)
+
+	ipToken := m.generateMaskedValue("192.168.1.1", "ipv4")
+	userToken := m.generateMaskedValue("alice", attributeCategory("user"))
+	assert.Equal(t, map[string]any{
+		"message": "request from " + ipToken,
+		"gateway": "192.168.1.1",
+		"status":  int64(200),
+		"request": map[string]any{
+			"user":  userToken,
+			"peers": []any{ipToken},
+		},
+		// A configured field holding a map is masked whole
+		"card":  m.generateMaskedValue(`{"number":"4111111111111111"}`, attributeCategory("card")),
+		"users": []any{map[string]any{"user": userToken}},
+	}, body.AsRaw())
+}
+
+func TestMaskMapBodyKeys(t *testing.T) {
+	newBodies := func() (plog.LogRecord, plog.LogRecord) {
+		// The same record from fluent-bit with flattened keys and from the filelog receiver
+		flat := plog.NewLogRecord()
+		flat.Body().SetEmptyMap().PutStr("user.email", "alice@example.com")
+		nested := plog.NewLogRecord()
+		nested.Body().SetEmptyMap().PutEmptyMap("user").PutStr("email", "alice@example.com")
+		return flat, nested
+	}
+
+	t.Run("exact", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Patterns = []PatternConfig{}
+		cfg.FieldsToMask = []string{"user.email"}
+		m, _ := newTestMasker(t, &cfg)
+
+		flat, nested := newBodies()
+		m.MaskLogRecord(context.Background(), flat)
+		m.MaskLogRecord(context.Background(), nested)
+
+		token := m.generateMaskedValue("alice@example.com", attributeCategory("user.email"))
+		assert.Equal(t, map[string]any{"user.email": token}, flat.Body().AsRaw())
+		assert.Equal(t, map[string]any{"user": map[string]any{"email": "alice@example.com"}}, nested.Body().AsRaw())
+	})
+
+	t.Run("path", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Patterns = []PatternConfig{}
+		cfg.FieldsToMask = []string{"user.email", "email"}
+		cfg.BodyKeys = bodyKeysPath
+		m, _ := newTestMasker(t, &cfg)
+
+		flat, nested := newBodies()
+		nested.Body().Map().PutEmptySlice("contacts").AppendEmpty().SetEmptyMap().PutStr("email", "bob@example.com")
+		m.MaskLogRecord(context.Background(), flat)
+		m.MaskLogRecord(context.Background(), nested)
+
+		// Both shapes get the token of the configured field
+		token := m.generateMaskedValue("alice@example.com", attributeCategory("user.email"))
+		assert.Equal(t, map[string]any{"user.email": token}, flat.Body().AsRaw())
+		assert.Equal(t, map[string]any{
+			"user": map[string]any{"email": token},
+			// Paths take precedence, and keys still match at any depth
+			"contacts": []any{map[string]any{"email": m.generateMaskedValue("bob@example.com", attributeCategory("email"))}},
+		}, nested.Body().AsRaw())
+	})
+}
+
+func TestMaskSliceBody(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"user"}
+	m, _ := newTestMasker(t, &cfg)
+
+	lr := plog.NewLogRecord()
+	body := lr.Body().SetEmptySlice()
+	body.AppendEmpty().SetStr("from 192.168.1.1")
+	body.AppendEmpty().SetEmptyMap().PutStr("user", "alice")
+	body.AppendEmpty().SetEmptySlice().AppendEmpty().SetStr("192.168.1.1")
+	body.AppendEmpty().SetBool(true)
+
+	m.MaskLogRecord(context.Background(), lr)
+
+	ipToken := m.generateMaskedValue("192.168.1.1", "ipv4")
+	assert.Equal(t, []any{
+		"from " + ipToken,
+		map[string]any{"user": m.generateMaskedValue("alice", attributeCategory("user"))},
+		[]any{ipToken},
+		true,
+	}, body.AsRaw())
+}
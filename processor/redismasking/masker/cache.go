@@ -0,0 +1,125 @@
+package masker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"golang.org/x/sync/singleflight"
+)
+
+// cachedStore is a Store that keeps recently used entries in a local LRU in
+// front of another Store. Writes go through to the next store before they are
+// cached, so the local LRU never holds a mapping the next store lacks.
+type cachedStore struct {
+	next  Store
+	cache *expirable.LRU[string, string]
+
+	// loads coalesces concurrent reads of an uncached key into one read of the
+	// next store, e.g. when batches repeating a hot value are masked at once
+	loads singleflight.Group
+
+	// mu orders invalidations and additions. pending holds the keys being read
+	// or written. An invalidation of a key increments its generation, so values
+	// read or written before it are not cached, while other keys stay unaffected.
+	mu      sync.Mutex
+	pending map[string]*pendingKey
+
+	// stopWatch ends the invalidation of changed keys, see NewWatchedCachedStore
+	stopWatch func()
+}
+
+// NewCachedStore wraps next with a local LRU holding up to size entries.
+// Entries expire from the LRU after ttl, or are only evicted by size when ttl is 0.
+func NewCachedStore(next Store, size int, ttl time.Duration) Store {
+	return newCachedStore(next, size, ttl)
+}
+
+func newCachedStore(next Store, size int, ttl time.Duration) *cachedStore {
+	return &cachedStore{
+		next:    next,
+		cache:   expirable.NewLRU[string, string](size, nil, ttl),
+		pending: make(map[string]*pendingKey),
+	}
+}
+
+// pendingKey tracks the reads and writes of a key in flight
+type pendingKey struct {
+	// inFlight is the number of reads and writes in flight
+	inFlight int
+
+	// generation counts the invalidations of the key while they are in flight
+	generation uint64
+}
+
+// cachedLoad is the result of a read of the next store
+type cachedLoad struct {
+	value string
+	found bool
+}
+
+// Get returns the locally cached value or loads it from the next store.
+// Callers reading the same key at once share the load of the first one.
+func (s *cachedStore) Get(ctx context.Context, key string) (string, bool, error) {
+	if value, ok := s.cache.Get(key); ok {
+		return value, true, nil
+	}
+
+	result, err, _ := s.loads.Do(key, func() (any, error) {
+		generation := s.begin(key)
+		value, found, err := s.next.Get(ctx, key)
+		s.finish(key, value, generation, err == nil && found)
+		return cachedLoad{value: value, found: found}, err
+	})
+	load := result.(cachedLoad)
+	return load.value, load.found, err
+}
+
+// Set stores value in the next store and caches it locally once written
+func (s *cachedStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	generation := s.begin(key)
+	err := s.next.Set(ctx, key, value, ttl)
+	s.finish(key, value, generation, err == nil)
+	return err
+}
+
+// Close purges the local cache and closes the next store
+func (s *cachedStore) Close() error {
+	if s.stopWatch != nil {
+		s.stopWatch()
+	}
+	s.cache.Purge()
+	return s.next.Close()
+}
+
+// begin registers a read or write of key and returns the generation of the
+// key it started at
+func (s *cachedStore) begin(key string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, ok := s.pending[key]
+	if !ok {
+		pending = &pendingKey{}
+		s.pending[key] = pending
+	}
+	pending.inFlight++
+	return pending.generation
+}
+
+// finish ends a read or write of key started at generation. With cache set,
+// value is cached unless key was invalidated since.
+func (s *cachedStore) finish(key, value string, generation uint64, cache bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending[key]
+	if cache && pending.generation == generation {
+		s.cache.Add(key, value)
+	}
+	pending.inFlight--
+	if pending.inFlight == 0 {
+		delete(s.pending, key)
+	}
+}
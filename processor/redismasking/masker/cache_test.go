@@ -0,0 +1,119 @@
+package masker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore is an in-memory Store that counts reads
+type countingStore struct {
+	data  map[string]string
+	reads int
+}
+
+func (s *countingStore) Get(_ context.Context, key string) (string, bool, error) {
+	s.reads++
+	value, ok := s.data[key]
+	return value, ok, nil
+}
+
+func (s *countingStore) Set(_ context.Context, key, value string, _ time.Duration) error {
+	s.data[key] = value
+	return nil
+}
+
+func (s *countingStore) Close() error {
+	return nil
+}
+
+func TestCachedStore(t *testing.T) {
+	next := &countingStore{data: map[string]string{"a": "1", "b": "2"}}
+	store := NewCachedStore(next, 1, 0)
+	ctx := context.Background()
+
+	value, found, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "1", value)
+
+	// Repeated reads are served locally
+	_, _, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, 1, next.reads)
+
+	// Misses are not cached
+	_, found, err = store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+	_, _, err = store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.Equal(t, 3, next.reads)
+
+	// Writes go through and evict the least recently used entry
+	require.NoError(t, store.Set(ctx, "c", "3", 0))
+	require.Equal(t, "3", next.data["c"])
+	_, _, err = store.Get(ctx, "c")
+	require.NoError(t, err)
+	require.Equal(t, 3, next.reads)
+	_, _, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, 4, next.reads)
+}
+
+// blockingStore is a Store whose reads wait until release is closed
+type blockingStore struct {
+	countingStore
+	mu      sync.Mutex
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) Get(ctx context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	if s.reads == 0 {
+		close(s.started)
+	}
+	s.mu.Unlock()
+	<-s.release
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.countingStore.Get(ctx, key)
+}
+
+func TestCachedStoreCoalescesLoads(t *testing.T) {
+	next := &blockingStore{
+		countingStore: countingStore{data: map[string]string{"a": "1"}},
+		started:       make(chan struct{}),
+		release:       make(chan struct{}),
+	}
+	store := NewCachedStore(next, 10, 0)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	values := make([]string, 5)
+	for i := range values {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, found, err := store.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			values[i] = value
+		}()
+	}
+
+	// Readers arriving while the first load is in flight share it
+	<-next.started
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	require.Equal(t, []string{"1", "1", "1", "1", "1"}, values)
+	require.Equal(t, 1, next.reads)
+}
@@ -0,0 +1,78 @@
+package masker
+
+import "strconv"
+
+const (
+	// paymentCardPattern is the name of the card number pattern of the
+	// payment_cards pack, whose tokens are surrogate card numbers
+	paymentCardPattern = "payment_card"
+
+	// cardBINLength is the number of leading digits identifying the issuer of a
+	// card, which surrogate card numbers keep
+	cardBINLength = 6
+)
+
+// surrogatesCards reports whether the tokens of category are surrogate card
+// numbers. Formats that replace the shape of every token take precedence.
+func (m *Masker) surrogatesCards(category string) bool {
+	if category != paymentCardPattern {
+		return false
+	}
+	switch m.tokenFormat(category) {
+	case tokenFormatUUID, tokenFormatEnvelope:
+		return false
+	default:
+		return true
+	}
+}
+
+// cardToken derives a surrogate card number of originalValue. The BIN, the
+// length, and the separators of the card number are kept, the account digits
+// are drawn from the hash of seed, and the check digit is computed
+// again, so the surrogate passes the Luhn check and the brand checks of
+// downstream validation. Values that are not 13 to 19 digits long are reported
+// as such.
+func (m *Masker) cardToken(originalValue, seed string) (string, bool) {
+	var digits []byte
+	for i := 0; i < len(originalValue); i++ {
+		if c := originalValue[i]; c >= '0' && c <= '9' {
+			digits = append(digits, c-'0')
+		}
+	}
+	if len(digits) < luhnMinDigits || len(digits) > luhnMaxDigits {
+		return "", false
+	}
+
+	// A surrogate equal to the card number is derived again with a counter suffix
+	var token string
+	for n := 0; n <= maxReservedRetries; n++ {
+		hash := m.digest(seed)
+		if n > 0 {
+			hash = m.digest(seed + "#" + strconv.Itoa(n))
+		}
+
+		payload := make([]byte, cardBINLength, len(digits)-1)
+		copy(payload, digits[:cardBINLength])
+		payload = append(payload, m.hashDigits(hash, len(digits)-1-cardBINLength)...)
+		if token = m.fpeFormat(originalValue, payload, true); token != originalValue {
+			break
+		}
+	}
+	return token, true
+}
+
+// hashDigits draws n uniformly distributed decimal digits from hash. Bytes of
+// 250 and above are skipped, since taking them modulo 10 would favor the low
+// digits, and the hash is hashed again when it runs out of bytes.
+func (m *Masker) hashDigits(hash []byte, n int) []byte {
+	digits := make([]byte, 0, n)
+	for len(digits) < n {
+		for _, b := range hash {
+			if b < 250 && len(digits) < n {
+				digits = append(digits, b%10)
+			}
+		}
+		hash = m.digest(string(hash))
+	}
+	return digits
+}
@@ -0,0 +1,88 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPaymentCardSurrogates(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{patternPackPaymentCards}
+	cfg.Patterns = []PatternConfig{}
+	require.NoError(t, cfg.Validate())
+	m, _ := newTestMasker(t, &cfg)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name  string
+		card  string
+		shape string
+	}{
+		{name: "visa", card: "4111111111111111", shape: `^411111\d{10}$`},
+		{name: "grouped", card: "5555 5555 5555 4444", shape: `^5555 55\d{2} \d{4} \d{4}$`},
+		{name: "amex", card: "3782-822463-10005", shape: `^3782-82\d{4}-\d{5}$`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			masked := m.MaskString(ctx, "charged "+tc.card+" today")
+			token := masked[len("charged ") : len(masked)-len(" today")]
+			assert.Regexp(t, tc.shape, token)
+			assert.True(t, validLuhn(token))
+			assert.NotEqual(t, tc.card, token)
+			assert.Equal(t, masked, m.MaskString(ctx, "charged "+tc.card+" today"))
+		})
+	}
+
+	// Numbers failing the Luhn check are not card numbers
+	assert.Equal(t, "order 4111111111111112", m.MaskString(ctx, "order 4111111111111112"))
+}
+
+func TestPaymentCardTokenFormat(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{patternPackPaymentCards}
+	cfg.TokenFormat = tokenFormatUUID
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// Formats replacing the shape of every token win over surrogates
+	assert.Regexp(t, `^[0-9a-f]{8}-`, m.generateMaskedValue("4111111111111111", paymentCardPattern))
+
+	// Values of other lengths get a hashed token
+	cfg.TokenFormat = tokenFormatDefault
+	assert.Regexp(t, `^[0-9a-f]{12}$`, m.generateMaskedValue("4111", paymentCardPattern))
+}
+
+func TestPaymentCardCollision(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{patternPackPaymentCards}
+	m, server := newTestMasker(t, &cfg)
+
+	// Another card number already holds the surrogate of the card number
+	taken := m.generateMaskedValue("4111111111111111", paymentCardPattern)
+	require.NoError(t, server.Set(UnmaskKey(paymentCardPattern, taken), "4111112222222228"))
+
+	token, err := m.MaskValue(context.Background(), "4111111111111111", paymentCardPattern)
+	require.NoError(t, err)
+	assert.NotEqual(t, taken, token)
+	assert.Regexp(t, `^411111\d{10}$`, token)
+	assert.True(t, validLuhn(token))
+	original, err := server.Get(UnmaskKey(paymentCardPattern, taken))
+	require.NoError(t, err)
+	assert.Equal(t, "4111112222222228", original)
+}
+
+func TestHashDigits(t *testing.T) {
+	m := &Masker{config: &Config{}, logger: zap.NewNop()}
+
+	// Bytes that would bias the digits are skipped
+	assert.Equal(t, []byte{3, 7}, m.hashDigits([]byte{255, 3, 251, 17, 42}, 2))
+
+	// The hash is hashed again when it runs out of bytes
+	digits := m.hashDigits([]byte{250}, 3)
+	assert.Len(t, digits, 3)
+	assert.Equal(t, digits, m.hashDigits([]byte{250}, 3))
+}
@@ -0,0 +1,126 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+var (
+	// panRegex matches card numbers of 13 to 19 digits, optionally grouped with
+	// spaces or dashes
+	panRegex = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+	// expiryRegex matches labelled expiry dates, and MM/YY or MM/YYYY dates
+	expiryRegex = regexp.MustCompile(`(?i)\b(?:exp(?:iry|iration)?(?: date)?|valid thru)\W{0,3}(?:0[1-9]|1[0-2])\s?[/-]?\s?(?:20)?\d{2}\b|\b(?:0[1-9]|1[0-2])/(?:20)?\d{2}\b`)
+
+	// cvvRegex matches labelled card verification values
+	cvvRegex = regexp.MustCompile(`(?i)\b(?:cvv2?|cvc2?|cid|csc|security code)\W{0,3}\d{3,4}\b`)
+
+	// errCardholderData is logged for every record dropped by the cardholder data rule
+	errCardholderData = errors.New("card number found next to its expiry date or CVV")
+)
+
+// CardholderDataConfig drops log records whose body holds a card number close
+// to its expiry date or CVV. Together they are enough to use the card, so such
+// records are a PCI DSS incident and are not forwarded, even masked.
+type CardholderDataConfig struct {
+	// Enabled turns on the rule
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+
+	// Distance is how many characters may separate a card number from its
+	// expiry date or CVV
+	Distance int `mapstructure:"distance" json:"distance"`
+
+	// RequireAll only drops records where both the expiry date and the CVV are
+	// close to the card number
+	RequireAll bool `mapstructure:"require_all" json:"require_all"`
+}
+
+// Validate checks the distance
+func (cfg *CardholderDataConfig) Validate() error {
+	if cfg.Enabled && cfg.Distance <= 0 {
+		return errors.New("cardholder_data distance must be positive")
+	}
+	return nil
+}
+
+// holdsCardholderData reports whether s holds a card number that passes the
+// Luhn check within distance characters of an expiry date or CVV
+func (cfg *CardholderDataConfig) holdsCardholderData(s string) bool {
+	pans := panRegex.FindAllStringIndex(s, -1)
+	if len(pans) == 0 {
+		return false
+	}
+	expiries := expiryRegex.FindAllStringIndex(s, -1)
+	cvvs := cvvRegex.FindAllStringIndex(s, -1)
+
+	for _, pan := range pans {
+		if !validLuhn(s[pan[0]:pan[1]]) {
+			continue
+		}
+		nearExpiry := near(pan, expiries, cfg.Distance)
+		nearCVV := near(pan, cvvs, cfg.Distance)
+		matched := nearExpiry || nearCVV
+		if cfg.RequireAll {
+			matched = nearExpiry && nearCVV
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// near reports whether one of spans is at most distance characters away from span
+func near(span []int, spans [][]int, distance int) bool {
+	for _, other := range spans {
+		if other[0]-span[1] <= distance && span[0]-other[1] <= distance {
+			return true
+		}
+	}
+	return false
+}
+
+// validLuhn reports whether the digits of number, ignoring separators, end
+// with their Luhn check digit
+func validLuhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			continue
+		}
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// filterLogRecord drops lr when its body holds cardholder data, and otherwise
+// applies the policy or masks it. It reports whether lr must be dropped.
+func (m *Masker) filterLogRecord(ctx context.Context, resource pcommon.Resource, lr plog.LogRecord) bool {
+	if m.config.CardholderData.Enabled && lr.Body().Type() == pcommon.ValueTypeStr &&
+		m.config.CardholderData.holdsCardholderData(lr.Body().Str()) {
+		// The values are not logged, since they are the incident
+		m.logWarn("Dropped log record holding cardholder data", errCardholderData)
+		m.telemetry.recordCardholderDataDropped(ctx)
+		return true
+	}
+
+	if m.policy != nil {
+		return m.shouldDrop(ctx, resource, lr)
+	}
+	m.MaskLogRecord(ctx, lr)
+	return false
+}
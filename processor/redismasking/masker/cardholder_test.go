@@ -0,0 +1,72 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestHoldsCardholderData(t *testing.T) {
+	cfg := CardholderDataConfig{Enabled: true, Distance: 20}
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected bool
+	}{
+		{name: "card and expiry", body: "card=4111 1111 1111 1111 exp=12/27", expected: true},
+		{name: "card and cvv", body: "pan 4111-1111-1111-1111 cvv: 123", expected: true},
+		{name: "labelled expiry", body: "4111111111111111 expiry 1227", expected: true},
+		{name: "expiry before card", body: "valid thru 12/2027, number 4111111111111111", expected: true},
+		{name: "card only", body: "charged 4111 1111 1111 1111", expected: false},
+		{name: "invalid check digit", body: "card=4111 1111 1111 1112 exp=12/27", expected: false},
+		{name: "too far apart", body: "card=4111111111111111 and much later in the message exp=12/27", expected: false},
+		{name: "unlabelled digits", body: "card=4111111111111111 qty 123", expected: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, cfg.holdsCardholderData(tc.body))
+		})
+	}
+
+	cfg.RequireAll = true
+	assert.False(t, cfg.holdsCardholderData("card=4111111111111111 exp=12/27"))
+	assert.True(t, cfg.holdsCardholderData("card=4111111111111111 exp=12/27 cvc 123"))
+}
+
+func TestCardholderDataDrop(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.CardholderData.Enabled = true
+	base, _ := newTestMasker(t, &cfg)
+	m, err := New(&cfg, base.store, zap.NewNop(), WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	records.AppendEmpty().Body().SetStr("payment from 192.168.1.1 card=4111 1111 1111 1111 exp=12/27")
+	records.AppendEmpty().Body().SetStr("connection from 192.168.1.1")
+	m.MaskLogs(context.Background(), ld)
+
+	// The record with cardholder data is dropped, the other is masked
+	require.Equal(t, 1, records.Len())
+	assert.NotContains(t, records.At(0).Body().Str(), "192.168.1.1")
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	found := false
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		if metric.Name == "redismasking.log.cardholder_data_dropped" {
+			found = true
+			assert.Equal(t, int64(1), metric.Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+		}
+	}
+	assert.True(t, found)
+}
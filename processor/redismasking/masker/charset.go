@@ -0,0 +1,169 @@
+package masker
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// charsetUTF16LE is little-endian UTF-16, as written by Windows
+	charsetUTF16LE = "utf-16le"
+
+	// charsetUTF16BE is big-endian UTF-16
+	charsetUTF16BE = "utf-16be"
+
+	// charsetLatin1 is ISO-8859-1. Every byte sequence is valid latin-1, so it is
+	// detected last.
+	charsetLatin1 = "latin1"
+)
+
+// supportedCharsets are the charsets accepted in body_charsets, in detection order
+var supportedCharsets = []string{charsetUTF16LE, charsetUTF16BE, charsetLatin1}
+
+// validateCharsets checks that every entry of body_charsets is supported
+func validateCharsets(charsets []string) error {
+	for _, charset := range charsets {
+		if !slices.Contains(supportedCharsets, charset) {
+			return fmt.Errorf("unsupported body_charsets entry '%s'", charset)
+		}
+	}
+	return nil
+}
+
+// maskEncodedBody masks a string body held in one of the configured charsets and
+// returns it in its original encoding. It reports false for UTF-8 bodies.
+func (m *Masker) maskEncodedBody(ctx context.Context, body string, onMask func(category, token string)) (string, bool) {
+	encoding, ok := detectEncoding([]byte(body), m.config.BodyCharsets)
+	if !ok {
+		return "", false
+	}
+	return string(encoding.encode(m.maskBody(ctx, encoding.decode([]byte(body)), onMask))), true
+}
+
+// bodyEncoding is the detected encoding of a log body
+type bodyEncoding struct {
+	charset string
+
+	// bom is set when the body started with a byte order mark
+	bom bool
+}
+
+// detectEncoding returns the encoding of body among charsets. It reports false
+// for UTF-8 bodies, which are masked as they are.
+func detectEncoding(body []byte, charsets []string) (bodyEncoding, bool) {
+	if len(charsets) == 0 {
+		return bodyEncoding{}, false
+	}
+
+	// A byte order mark is conclusive
+	if len(body) >= 2 && len(body)%2 == 0 {
+		switch {
+		case body[0] == 0xff && body[1] == 0xfe && slices.Contains(charsets, charsetUTF16LE):
+			return bodyEncoding{charset: charsetUTF16LE, bom: true}, true
+		case body[0] == 0xfe && body[1] == 0xff && slices.Contains(charsets, charsetUTF16BE):
+			return bodyEncoding{charset: charsetUTF16BE, bom: true}, true
+		}
+	}
+
+	// UTF-16 text that is mostly ASCII is valid UTF-8, so NUL bytes in every other
+	// position are checked before UTF-8 validity
+	for _, charset := range charsets {
+		if (charset == charsetUTF16LE || charset == charsetUTF16BE) && looksUTF16(body, charset == charsetUTF16LE) {
+			return bodyEncoding{charset: charset}, true
+		}
+	}
+
+	if utf8.Valid(body) {
+		return bodyEncoding{}, false
+	}
+	if slices.Contains(charsets, charsetLatin1) {
+		return bodyEncoding{charset: charsetLatin1}, true
+	}
+	return bodyEncoding{}, false
+}
+
+// looksUTF16 reports whether body looks like UTF-16 text in the given byte
+// order: at least half of its code units hold a NUL high byte and none of them
+// is NUL altogether.
+func looksUTF16(body []byte, littleEndian bool) bool {
+	if len(body) < 2 || len(body)%2 != 0 {
+		return false
+	}
+
+	high, low := 1, 0
+	if !littleEndian {
+		high, low = 0, 1
+	}
+	nulHigh := 0
+	for i := 0; i < len(body); i += 2 {
+		if body[i+high] == 0 {
+			if body[i+low] == 0 {
+				return false
+			}
+			nulHigh++
+		}
+	}
+	return nulHigh*2 >= len(body)/2
+}
+
+// decode returns body as UTF-8 text
+func (e bodyEncoding) decode(body []byte) string {
+	switch e.charset {
+	case charsetUTF16LE, charsetUTF16BE:
+		if e.bom {
+			body = body[2:]
+		}
+		units := make([]uint16, len(body)/2)
+		for i := range units {
+			units[i] = e.byteOrder().Uint16(body[2*i:])
+		}
+		return string(utf16.Decode(units))
+	default:
+		runes := make([]rune, len(body))
+		for i, b := range body {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+}
+
+// encode returns text in the encoding of the original body, including its byte
+// order mark. Characters that latin-1 cannot represent are replaced with '?'.
+func (e bodyEncoding) encode(text string) []byte {
+	switch e.charset {
+	case charsetUTF16LE, charsetUTF16BE:
+		units := utf16.Encode([]rune(text))
+		var encoded []byte
+		if e.bom {
+			encoded = e.byteOrder().AppendUint16(encoded, 0xfeff)
+		}
+		for _, unit := range units {
+			encoded = e.byteOrder().AppendUint16(encoded, unit)
+		}
+		return encoded
+	default:
+		encoded := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xff {
+				r = '?'
+			}
+			encoded = append(encoded, byte(r))
+		}
+		return encoded
+	}
+}
+
+// byteOrder returns the byte order of a UTF-16 encoding
+func (e bodyEncoding) byteOrder() interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+} {
+	if e.charset == charsetUTF16BE {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
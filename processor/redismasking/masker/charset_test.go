@@ -0,0 +1,95 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+// encodeUTF16 encodes text as UTF-16 with an optional byte order mark
+func encodeUTF16(text string, littleEndian, bom bool) string {
+	units := utf16.Encode([]rune(text))
+	if bom {
+		units = append([]uint16{0xfeff}, units...)
+	}
+	encoded := make([]byte, 0, 2*len(units))
+	for _, unit := range units {
+		if littleEndian {
+			encoded = append(encoded, byte(unit), byte(unit>>8))
+		} else {
+			encoded = append(encoded, byte(unit>>8), byte(unit))
+		}
+	}
+	return string(encoded)
+}
+
+func TestDetectEncoding(t *testing.T) {
+	all := []string{charsetUTF16LE, charsetUTF16BE, charsetLatin1}
+
+	testCases := []struct {
+		name     string
+		body     string
+		charsets []string
+		expected bodyEncoding
+		detected bool
+	}{
+		{name: "utf-8", body: "login from 192.168.1.1", charsets: all},
+		{name: "not configured", body: encodeUTF16("login", true, false)},
+		{name: "utf-16le", body: encodeUTF16("login", true, false), charsets: all, expected: bodyEncoding{charset: charsetUTF16LE}, detected: true},
+		{name: "utf-16be", body: encodeUTF16("login", false, false), charsets: all, expected: bodyEncoding{charset: charsetUTF16BE}, detected: true},
+		{name: "utf-16le bom", body: encodeUTF16("Jürgen", true, true), charsets: all, expected: bodyEncoding{charset: charsetUTF16LE, bom: true}, detected: true},
+		{name: "utf-16be bom", body: encodeUTF16("Jürgen", false, true), charsets: []string{charsetUTF16BE}, expected: bodyEncoding{charset: charsetUTF16BE, bom: true}, detected: true},
+		{name: "utf-16 not configured", body: encodeUTF16("login", true, false), charsets: []string{charsetLatin1}},
+		{name: "latin1", body: "Jos\xe9 logged in", charsets: all, expected: bodyEncoding{charset: charsetLatin1}, detected: true},
+		{name: "latin1 not configured", body: "Jos\xe9 logged in", charsets: []string{charsetUTF16LE}},
+		{name: "nul code units", body: "\x00\x00a\x00", charsets: []string{charsetUTF16LE}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoding, detected := detectEncoding([]byte(tc.body), tc.charsets)
+			assert.Equal(t, tc.detected, detected)
+			assert.Equal(t, tc.expected, encoding)
+		})
+	}
+}
+
+func TestMaskEncodedBody(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Mode = modeLightweight
+	cfg.HMACKey = "secret"
+	cfg.Patterns = ipv4Patterns()
+	cfg.BodyCharsets = []string{charsetUTF16LE, charsetUTF16BE, charsetLatin1}
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	token := m.generateMaskedValue("192.168.1.1", "ipv4")
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{name: "utf-16le", body: encodeUTF16("Jürgen from 192.168.1.1", true, false), expected: encodeUTF16("Jürgen from "+token, true, false)},
+		{name: "utf-16le bom", body: encodeUTF16("Jürgen from 192.168.1.1", true, true), expected: encodeUTF16("Jürgen from "+token, true, true)},
+		{name: "utf-16be", body: encodeUTF16("login from 192.168.1.1", false, false), expected: encodeUTF16("login from "+token, false, false)},
+		{name: "latin1", body: "Jos\xe9 from 192.168.1.1", expected: "Jos\xe9 from " + token},
+		{name: "utf-8", body: "José from 192.168.1.1", expected: "José from " + token},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lr := plog.NewLogRecord()
+			lr.Body().SetStr(tc.body)
+			m.MaskLogRecord(context.Background(), lr)
+			assert.Equal(t, tc.expected, lr.Body().Str())
+		})
+	}
+}
+
+func TestEncodeLatin1Replacement(t *testing.T) {
+	assert.Equal(t, []byte("a?\xe9"), bodyEncoding{charset: charsetLatin1}.encode("a€é"))
+}
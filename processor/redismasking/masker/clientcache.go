@@ -0,0 +1,179 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// clientCacheKeyPrefix is the prefix of the keys cached on the client
+	clientCacheKeyPrefix = "mask:"
+
+	// invalidationChannel receives the invalidations of tracked keys on RESP2
+	// connections
+	invalidationChannel = "__redis__:invalidate"
+)
+
+// ClientCacheConfig enables client-side caching of the mask keys with
+// server-assisted invalidation. Redis notifies the client of every change of a
+// mask key, including deletes and expired TTLs, so cached tokens stay consistent.
+type ClientCacheConfig struct {
+	// Size is the maximum number of cached mask keys. 0 disables the cache.
+	Size int `mapstructure:"size"`
+
+	// TTL bounds how long an entry is cached. 0 keeps it until it is invalidated
+	// or evicted by size.
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// Enabled reports whether client-side caching is configured
+func (cfg *ClientCacheConfig) Enabled() bool {
+	return cfg.Size > 0
+}
+
+// ValidateClientCache checks the client cache settings. Replicas may apply a
+// change after its invalidation was sent, so their reads could cache stale
+// tokens until the next change.
+func ValidateClientCache(cfg *ClientCacheConfig, replicas *ReplicaConfig) error {
+	if cfg.Size < 0 {
+		return errors.New("redis_client_cache size must be non-negative")
+	}
+	if cfg.TTL < 0 {
+		return errors.New("redis_client_cache ttl must be non-negative")
+	}
+	if cfg.Enabled() && len(replicas.Addrs) > 0 {
+		return errors.New("redis_client_cache cannot be combined with redis_replicas")
+	}
+	return nil
+}
+
+// clientCache caches mask keys while a dedicated connection receives their
+// invalidations. go-redis cannot read RESP3 push messages on pooled
+// connections, so the invalidations of every mask key are broadcast to a RESP2
+// connection subscribed to the invalidation channel instead. Entries are only
+// served while that connection is up and are dropped whenever it fails.
+type clientCache struct {
+	client  *redis.Client
+	entries *expirable.LRU[string, string]
+
+	// connected is set while invalidations are received
+	connected atomic.Bool
+
+	// mu orders invalidations and additions. generation is incremented by every
+	// invalidation, so values read before one are not cached.
+	mu         sync.Mutex
+	generation atomic.Uint64
+
+	// subscriber receives the invalidations. Flushes fail it, since their
+	// invalidation has no keys.
+	subscriber *subscriber
+}
+
+// newClientCache starts receiving invalidations on a connection with the options
+// and password file of the store
+func newClientCache(cfg *ClientCacheConfig, options *redis.Options, passwords *passwordFile) *clientCache {
+	invalidationOptions := *options
+	invalidationOptions.Protocol = 2
+	invalidationOptions.OnConnect = enableTracking
+
+	c := &clientCache{
+		client:  newRedisClient(&invalidationOptions, passwords),
+		entries: expirable.NewLRU[string, string](cfg.Size, nil, cfg.TTL),
+	}
+	c.subscriber = startSubscriber(func(ctx context.Context) *redis.PubSub {
+		return c.client.Subscribe(ctx, invalidationChannel)
+	}, c.handle, c.disconnect)
+	return c
+}
+
+// enableTracking redirects the invalidations of every mask key to the new
+// connection itself
+func enableTracking(ctx context.Context, cn *redis.Conn) error {
+	id, err := cn.ClientID(ctx).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get client ID: %w", err)
+	}
+	if err := cn.Do(ctx, "CLIENT", "TRACKING", "ON", "REDIRECT", id, "BCAST", "PREFIX", clientCacheKeyPrefix).Err(); err != nil {
+		return fmt.Errorf("failed to enable client tracking: %w", err)
+	}
+	return nil
+}
+
+// handle applies a message of the invalidation connection
+func (c *clientCache) handle(msg any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case *redis.Subscription:
+		// Changes before the subscription were not tracked
+		c.generation.Add(1)
+		c.entries.Purge()
+		c.connected.Store(true)
+	case *redis.Message:
+		c.generation.Add(1)
+		for _, key := range msg.PayloadSlice {
+			c.entries.Remove(key)
+		}
+		if msg.Payload != "" {
+			c.entries.Remove(msg.Payload)
+		}
+	}
+}
+
+// disconnect stops serving entries, since their invalidations may be missed
+func (c *clientCache) disconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.connected.Store(false)
+	c.generation.Add(1)
+	c.entries.Purge()
+}
+
+// get returns the cached value of key
+func (c *clientCache) get(key string) (string, bool) {
+	if !c.connected.Load() {
+		return "", false
+	}
+	return c.entries.Get(key)
+}
+
+// add caches the value of key read at generation, unless an invalidation
+// arrived since
+func (c *clientCache) add(key, value string, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.connected.Load() && c.generation.Load() == generation {
+		c.entries.Add(key, value)
+	}
+}
+
+// close stops receiving invalidations and drops the cache
+func (c *clientCache) close() error {
+	c.subscriber.close()
+	return c.client.Close()
+}
+
+// cachedGet returns the value of a mask key from the client cache, or reads it
+// from the primary and caches it
+func (s *redisStore) cachedGet(ctx context.Context, key string) (string, bool, error) {
+	if value, ok := s.clientCache.get(key); ok {
+		return value, true, nil
+	}
+
+	generation := s.clientCache.generation.Load()
+	value, found, err := s.primaryGet(ctx, key)
+	if err == nil && found {
+		s.clientCache.add(key, value, generation)
+	}
+	return value, found, err
+}
@@ -0,0 +1,81 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateClientCache(t *testing.T) {
+	replicas := &ReplicaConfig{Addrs: []string{"replica-1:6379"}}
+
+	require.NoError(t, ValidateClientCache(&ClientCacheConfig{Size: 1000}, &ReplicaConfig{}))
+	require.NoError(t, ValidateClientCache(&ClientCacheConfig{}, replicas))
+	require.EqualError(t, ValidateClientCache(&ClientCacheConfig{Size: -1}, &ReplicaConfig{}), "redis_client_cache size must be non-negative")
+	require.EqualError(t, ValidateClientCache(&ClientCacheConfig{Size: 1000, TTL: -1}, &ReplicaConfig{}), "redis_client_cache ttl must be non-negative")
+	require.EqualError(t, ValidateClientCache(&ClientCacheConfig{Size: 1000}, replicas), "redis_client_cache cannot be combined with redis_replicas")
+}
+
+func TestClientCacheInvalidation(t *testing.T) {
+	c := &clientCache{entries: expirable.NewLRU[string, string](10, nil, 0)}
+	key := MaskKey("ipv4", "10.0.0.1")
+
+	// Nothing is cached before invalidations are received
+	c.add(key, "10.1.2.3", c.generation.Load())
+	_, ok := c.get(key)
+	assert.False(t, ok)
+
+	c.handle(&redis.Subscription{Kind: "subscribe", Channel: invalidationChannel, Count: 1})
+	c.add(key, "10.1.2.3", c.generation.Load())
+	value, ok := c.get(key)
+	require.True(t, ok)
+	assert.Equal(t, "10.1.2.3", value)
+
+	// Deleted and expired keys are invalidated
+	c.handle(&redis.Message{Channel: invalidationChannel, PayloadSlice: []string{key}})
+	_, ok = c.get(key)
+	assert.False(t, ok)
+
+	// A value read before an invalidation is not cached
+	generation := c.generation.Load()
+	c.handle(&redis.Message{Channel: invalidationChannel, PayloadSlice: []string{MaskKey("ipv4", "10.0.0.2")}})
+	c.add(key, "10.1.2.3", generation)
+	_, ok = c.get(key)
+	assert.False(t, ok)
+
+	// The cache is dropped with the invalidation connection
+	c.add(key, "10.1.2.3", c.generation.Load())
+	c.disconnect()
+	_, ok = c.get(key)
+	assert.False(t, ok)
+	assert.Zero(t, c.entries.Len())
+}
+
+func TestClientCacheWithoutTracking(t *testing.T) {
+	// miniredis does not support client tracking, so nothing may be cached
+	server := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	cfg.RedisClientCache = ClientCacheConfig{Size: 100}
+
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	key := MaskKey("ipv4", "10.0.0.1")
+	require.NoError(t, server.Set(key, "10.1.2.3"))
+	value, ok, err := store.Get(context.Background(), key)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "10.1.2.3", value)
+
+	require.NoError(t, server.Set(key, "10.4.5.6"))
+	value, _, err = store.Get(context.Background(), key)
+	require.NoError(t, err)
+	assert.Equal(t, "10.4.5.6", value)
+}
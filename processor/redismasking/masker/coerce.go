@@ -0,0 +1,63 @@
+package masker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// Types of masked replacements, see Config.MaskedFieldTypes
+const (
+	// maskedTypeString replaces a value with its token
+	maskedTypeString = "string"
+
+	// maskedTypeInt replaces a value with an integer surrogate of its token
+	maskedTypeInt = "int"
+
+	// maskedTypeBool replaces a value with false
+	maskedTypeBool = "bool"
+
+	// maskedTypeMap replaces a value with an empty map
+	maskedTypeMap = "map"
+)
+
+// validateMaskedFieldTypes checks that every type is supported and applies to a
+// field that is masked whole
+func (cfg *Config) validateMaskedFieldTypes() error {
+	fields := append(cfg.effectiveFieldsToMask(), cfg.ResourceFieldsToMask...)
+	for field, maskedType := range cfg.MaskedFieldTypes {
+		switch maskedType {
+		case maskedTypeString, maskedTypeInt, maskedTypeBool, maskedTypeMap:
+		default:
+			return fmt.Errorf("unsupported masked_field_types type '%s' for '%s'", maskedType, field)
+		}
+		if !slices.Contains(fields, field) {
+			return fmt.Errorf("masked_field_types key '%s' is not in fields_to_mask or resource_fields_to_mask", field)
+		}
+	}
+	return nil
+}
+
+// setMasked replaces the value v of field k with token, typed as configured in
+// masked_field_types, so strictly typed downstream schemas keep accepting the
+// records after masking
+func (m *Masker) setMasked(k string, v pcommon.Value, token string) {
+	switch m.config.MaskedFieldTypes[k] {
+	case maskedTypeInt:
+		v.SetInt(m.intSurrogate(token))
+	case maskedTypeBool:
+		v.SetBool(false)
+	case maskedTypeMap:
+		v.SetEmptyMap()
+	default:
+		v.SetStr(token)
+	}
+}
+
+// intSurrogate derives a non-negative integer from token, so equal values still
+// get equal surrogates and can be joined on. Surrogates cannot be unmasked.
+func (m *Masker) intSurrogate(token string) int64 {
+	return int64(binary.BigEndian.Uint64(m.digest(token)) >> 1)
+}
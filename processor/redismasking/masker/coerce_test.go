@@ -0,0 +1,57 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestMaskedFieldTypes(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{}
+	cfg.FieldsToMask = []string{"user_id", "is_admin", "profile", "email"}
+	cfg.ResourceFieldsToMask = []string{"tenant_id"}
+	cfg.MaskedFieldTypes = map[string]string{
+		"user_id":   maskedTypeInt,
+		"is_admin":  maskedTypeBool,
+		"profile":   maskedTypeMap,
+		"email":     maskedTypeString,
+		"tenant_id": maskedTypeInt,
+	}
+	cfg.EnrichWithLookupURL = "https://unmask.example.com/"
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutInt("tenant_id", 42)
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	first := records.AppendEmpty()
+	first.Attributes().PutInt("user_id", 1001)
+	first.Attributes().PutBool("is_admin", true)
+	first.Attributes().PutEmptyMap("profile").PutStr("name", "Jane Doe")
+	first.Attributes().PutStr("email", "jane@example.com")
+	second := records.AppendEmpty()
+	second.Body().SetEmptyMap().PutInt("user_id", 1001)
+
+	m.MaskLogs(context.Background(), ld)
+
+	userToken, _ := m.MaskValue(context.Background(), "1001", attributeCategory("user_id"))
+	emailToken, _ := m.MaskValue(context.Background(), "jane@example.com", attributeCategory("email"))
+	assert.Equal(t, map[string]any{
+		"user_id":  m.intSurrogate(userToken),
+		"is_admin": false,
+		"profile":  map[string]any{},
+		"email":    emailToken,
+		// Only string replacements hold a token to look up
+		"email.lookup_url": m.lookupURL(attributeCategory("email"), emailToken),
+	}, first.Attributes().AsRaw())
+
+	// Equal values get equal surrogates wherever they appear
+	assert.Equal(t, map[string]any{"user_id": m.intSurrogate(userToken)}, second.Body().AsRaw())
+	assert.GreaterOrEqual(t, m.intSurrogate(userToken), int64(0))
+
+	tenantToken, _ := m.MaskValue(context.Background(), "42", attributeCategory("tenant_id"))
+	assert.Equal(t, map[string]any{"tenant_id": m.intSurrogate(tenantToken)}, rl.Resource().Attributes().AsRaw())
+}
@@ -0,0 +1,1192 @@
+// Package masker provides the detection and tokenization engine used by the
+// redismasking processor. It can be embedded in other tools that need to apply
+// exactly the same rules and token store outside of a collector pipeline.
+package masker
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config defines configuration for the masking engine
+type Config struct {
+	// Redis connection settings
+	RedisAddr     string `mapstructure:"redis_addr"`
+	RedisPassword string `mapstructure:"redis_password"`
+	RedisDB       int    `mapstructure:"redis_db"`
+
+	// RedisPasswordFile is a file holding the password instead of RedisPassword,
+	// e.g. a mounted secret. It is read again when Redis rejects the password, so
+	// a rotated password is used without a restart.
+	RedisPasswordFile string `mapstructure:"redis_password_file"`
+
+	// RedisNetwork is "tcp" or "unix". With "unix", RedisAddr is the path of the
+	// socket, e.g. of a sidecar Redis.
+	RedisNetwork string `mapstructure:"redis_network"`
+
+	// RedisPool tunes the connection pool of the Redis client
+	RedisPool PoolConfig `mapstructure:"redis_pool"`
+
+	// RedisTimeouts bounds the dial, read, and write of Redis operations
+	RedisTimeouts TimeoutConfig `mapstructure:"redis_timeouts"`
+
+	// RedisRetry retries Redis commands that fail with a transient error
+	RedisRetry RetryConfig `mapstructure:"redis_retry"`
+
+	// RedisAuth authenticates with short-lived tokens instead of RedisPassword
+	RedisAuth AuthConfig `mapstructure:"redis_auth"`
+
+	// RedisReplicas routes lookups to read replicas
+	RedisReplicas ReplicaConfig `mapstructure:"redis_replicas"`
+
+	// RedisClientCache caches mask keys in memory with server-assisted invalidation
+	RedisClientCache ClientCacheConfig `mapstructure:"redis_client_cache"`
+
+	// TLS secures the Redis connection
+	TLS TLSConfig `mapstructure:"tls"`
+
+	// Store is the token store backend: redis (default), memory, file, memcached,
+	// dynamodb, sql, firestore, or etcd
+	Store string `mapstructure:"store"`
+
+	// MemoryStore bounds the memory store
+	MemoryStore MemoryStoreConfig `mapstructure:"memory_store"`
+
+	// FileStore locates and bounds the file store
+	FileStore FileStoreConfig `mapstructure:"file_store"`
+
+	// Memcached lists the servers of the memcached store
+	Memcached MemcachedConfig `mapstructure:"memcached"`
+
+	// DynamoDB locates the table of the dynamodb store
+	DynamoDB DynamoDBConfig `mapstructure:"dynamodb"`
+
+	// SQLStore locates the database of the sql store
+	SQLStore SQLStoreConfig `mapstructure:"sql_store"`
+
+	// Firestore locates the collection of the firestore store
+	Firestore FirestoreConfig `mapstructure:"firestore"`
+
+	// Etcd locates the cluster of the etcd store
+	Etcd EtcdConfig `mapstructure:"etcd"`
+
+	// TTL for cached tokens in seconds (0 = no expiration)
+	TokenTTL int `mapstructure:"token_ttl"`
+
+	// Fields to mask - supports log attributes and body
+	FieldsToMask []string `mapstructure:"fields_to_mask"`
+
+	// SemconvFieldsEnabled also masks the OpenTelemetry semantic convention attributes
+	// that commonly hold personal data, e.g. enduser.id, client.address, and user.email
+	SemconvFieldsEnabled bool `mapstructure:"semconv_fields"`
+
+	// ScanAllAttributes applies the patterns to every string attribute value,
+	// not only the log body
+	ScanAllAttributes bool `mapstructure:"scan_all_attributes"`
+
+	// ExcludeKeys are attribute keys skipped when scan_all_attributes or
+	// scan_resource_attributes is enabled
+	ExcludeKeys []string `mapstructure:"exclude_keys"`
+
+	// ResourceFieldsToMask are resource attributes whose whole value is masked, e.g.
+	// host.name, so every record of a resource carries the same token
+	ResourceFieldsToMask []string `mapstructure:"resource_fields_to_mask"`
+
+	// ScanResourceAttributes applies the patterns to every other string resource
+	// attribute. Keys in exclude_keys are skipped.
+	ScanResourceAttributes bool `mapstructure:"scan_resource_attributes"`
+
+	// AccessLogFields caps and scans access log attributes that proxies often fill
+	// with upstream error messages
+	AccessLogFields AccessLogConfig `mapstructure:"access_log_fields"`
+
+	// Spans defines which attributes attached to spans are masked
+	Spans SpanConfig `mapstructure:"spans"`
+
+	// Metrics defines which data attached to metric datapoints is masked
+	Metrics MetricConfig `mapstructure:"metrics"`
+
+	// StructuredFields parse values of a known format, e.g. GraphQL documents, and
+	// tokenize only their sensitive parts
+	StructuredFields []StructuredFieldConfig `mapstructure:"structured_fields"`
+
+	// BodyMetadataDelimiters separate a trailing JSON metadata segment from the free
+	// text of string log bodies, e.g. "|meta=". The segment is masked member by member
+	// and the text before it is pattern-scanned.
+	BodyMetadataDelimiters []string `mapstructure:"body_metadata_delimiters"`
+
+	// BodyCharsets are the charsets detected in log bodies: "utf-16le", "utf-16be",
+	// or "latin1". Detected bodies are transcoded to UTF-8 for matching and
+	// written back in their original encoding.
+	BodyCharsets []string `mapstructure:"body_charsets"`
+
+	// BodyKeys is how the members of map bodies are matched against fields_to_mask
+	// and structured_fields: "exact" matches their keys, while "path" also matches
+	// their dotted path, so "user.email" matches both a flat "user.email" key and
+	// an "email" member nested in "user"
+	BodyKeys string `mapstructure:"body_keys"`
+
+	// RecordFlags only processes the log records whose flags match, e.g. sampled
+	// records. Other records are passed through unchanged.
+	RecordFlags RecordFlagsConfig `mapstructure:"record_flags"`
+
+	// Patterns to detect sensitive data in log body
+	Patterns []PatternConfig `mapstructure:"patterns"`
+
+	// MatchFilter trims pattern matches and skips short ones before they are masked
+	MatchFilter MatchFilterConfig `mapstructure:"match_filter"`
+
+	// ShortCircuit stops scanning values once enough patterns were ruled out by
+	// their literal prefix while nothing was masked
+	ShortCircuit ShortCircuitConfig `mapstructure:"short_circuit"`
+
+	// SecretKeys masks the values of JSON and form-encoded keys that name passwords
+	// or secrets, which have no detectable value shape
+	SecretKeys SecretKeysConfig `mapstructure:"secret_keys"`
+
+	// PatternPacks add built-in detector sets evaluated before Patterns, e.g. "api_keys"
+	PatternPacks []string `mapstructure:"pattern_packs"`
+
+	// LicensePlates selects the license plate formats of the vehicles pattern pack
+	LicensePlates LicensePlateConfig `mapstructure:"license_plates"`
+
+	// Healthcare configures the healthcare pattern pack
+	Healthcare HealthcareConfig `mapstructure:"healthcare"`
+
+	// CardholderData drops log records holding a card number next to its expiry
+	// date or CVV instead of masking them
+	CardholderData CardholderDataConfig `mapstructure:"cardholder_data"`
+
+	// TokenNamespace gives the destination of this processor its own token aliases,
+	// e.g. "vendor_x". Mappings are stored per namespace and remain resolvable.
+	TokenNamespace string `mapstructure:"token_namespace"`
+
+	// TokenFormat is the default token format: "default", "uuid", "envelope", "fpe",
+	// or "fake".
+	// Patterns can override it with their own token_format.
+	TokenFormat string `mapstructure:"token_format"`
+
+	// FakeKinds sets the kind of fake value of the fake token format per pattern
+	// name or field key: "name", "email", "address", "phone", or "username"
+	FakeKinds map[string]string `mapstructure:"fake_kinds"`
+
+	// PreserveIPPrefix keeps the first 16 or 24 bits of IPv4 addresses matched by
+	// the ipv4 pattern and tokenizes only the host part (0 = disabled)
+	PreserveIPPrefix int `mapstructure:"preserve_ip_prefix"`
+
+	// FPEKey is a base64 encoded 16, 24, or 32 byte AES key enciphering the digits
+	// of values with FF3-1 (required with the fpe token format)
+	FPEKey string `mapstructure:"fpe_key"`
+
+	// ReservedNamespaces are networks and domains of real assets. Synthetic IPv4 and
+	// hostname tokens falling inside them are regenerated, so masked data never
+	// points at a real asset.
+	ReservedNamespaces ReservedNamespacesConfig `mapstructure:"reserved_namespaces"`
+
+	// Mode selects the processing preset: "standard", "lightweight", "active_active",
+	// or "read_only". Lightweight mode never connects to Redis and derives tokens
+	// with HMAC only. Active-active mode derives tokens with HMAC and uses Redis
+	// only as a cache of the reverse mappings, so independent regions always agree
+	// on tokens. Read-only mode only looks up tokens from a curated mapping.
+	Mode string `mapstructure:"mode"`
+
+	// ReadOnly defines how read_only mode masks values without a mapping
+	ReadOnly ReadOnlyConfig `mapstructure:"read_only"`
+
+	// HMACKey keys token derivation with HMAC-SHA256 when set (required in lightweight
+	// and active_active modes, and for the hash fallback of read_only mode)
+	HMACKey string `mapstructure:"hmac_key"`
+
+	// HMACKeyFile is a file holding the key instead of HMACKey, e.g. a mounted
+	// secret. It is read at startup.
+	HMACKeyFile string `mapstructure:"hmac_key_file"`
+
+	// Salt is mixed into token derivation, so deployments masking the same value
+	// with different salts get different tokens
+	Salt string `mapstructure:"salt"`
+
+	// AllowSaltChange accepts a salt that differs from the one recorded in the
+	// store at startup, and records the new salt
+	AllowSaltChange bool `mapstructure:"allow_salt_change"`
+
+	// HashAlgorithm derives tokens, keyed with HMAC when a key is set: "sha256",
+	// "sha512", or "blake2b". Changing it changes every derived token.
+	HashAlgorithm string `mapstructure:"hash_algorithm"`
+
+	// TokenLength is the number of hex characters of hashed tokens. Patterns can
+	// override it with their own token_length.
+	TokenLength int `mapstructure:"token_length"`
+
+	// MaxScanBytes limits how many bytes of each value are pattern-scanned (0 = unlimited)
+	MaxScanBytes int `mapstructure:"max_scan_bytes"`
+
+	// ScanOverflow is what happens to the unscanned tail of a value longer than
+	// max_scan_bytes: "pass" keeps it as is, "truncate" drops it
+	ScanOverflow string `mapstructure:"scan_overflow"`
+
+	// RoutingKeyAttribute, when set, names a record attribute that receives a hash of the
+	// record's first sensitive value. Gateways can route on it (e.g. via the loadbalancing
+	// exporter or routing connector) so repeated values land on the same collector.
+	RoutingKeyAttribute string `mapstructure:"routing_key_attribute"`
+
+	// EnrichWithLookupURL is the base URL of the unmask API. When set, masked values get
+	// companion attributes linking to the lookup of their token.
+	EnrichWithLookupURL string `mapstructure:"enrich_with_lookup_url"`
+
+	// LocalCacheSize is the number of store entries kept in a local LRU in front of
+	// Redis (0 = disabled)
+	LocalCacheSize int `mapstructure:"local_cache_size"`
+
+	// LocalCacheInvalidation drops locally cached mappings as soon as they change
+	// in Redis, using keyspace notifications
+	LocalCacheInvalidation bool `mapstructure:"local_cache_invalidation"`
+
+	// WarmupTopN preloads the local cache at startup with the N most frequently used
+	// mappings. It enables access tracking (0 = disabled).
+	WarmupTopN int `mapstructure:"warmup_top_n"`
+
+	// TrackAccess records per-mapping access counts and last seen times in Redis
+	TrackAccess bool `mapstructure:"track_access"`
+
+	// LatencyBudget degrades masking step by step when batches persistently take
+	// longer than the budget, and recovers once there is headroom again
+	LatencyBudget LatencyBudgetConfig `mapstructure:"latency_budget"`
+
+	// WriteBehind persists new mappings asynchronously from a bounded queue
+	WriteBehind WriteBehindConfig `mapstructure:"write_behind"`
+
+	// Discovery suggests additional fields_to_mask from the observed attributes
+	Discovery DiscoveryConfig `mapstructure:"discovery"`
+
+	// Provenance tags every processed record with the applied masking policy
+	Provenance ProvenanceConfig `mapstructure:"provenance"`
+
+	// FingerprintAttribute, when set, names a resource attribute of every processed
+	// metric that receives the configuration fingerprint, so drift between agents can
+	// be detected by comparing the fingerprints they report
+	FingerprintAttribute string `mapstructure:"fingerprint_attribute"`
+
+	// OPA consults a Rego policy to decide how each record is handled
+	OPA OPAConfig `mapstructure:"opa"`
+
+	// Replication publishes new mappings to Kafka for disaster recovery
+	Replication ReplicationConfig `mapstructure:"replication"`
+
+	// ErrorLogInterval aggregates repeated masking errors: the first occurrence of
+	// an error is logged, and further ones are counted and summarized once per
+	// interval (0 = log every error)
+	ErrorLogInterval time.Duration `mapstructure:"error_log_interval"`
+
+	// Watchlist renews the mappings of tokens flagged through the unmask API and
+	// reports whenever they are seen again
+	Watchlist WatchlistConfig `mapstructure:"watchlist"`
+
+	// DistinctCounts reports differentially private counts of the distinct values
+	// masked per category and interval
+	DistinctCounts DistinctCountConfig `mapstructure:"distinct_counts"`
+
+	// Alerts selects the Prometheus alerting rules generated for the internal
+	// telemetry, see AlertsConfig.RuleFile
+	Alerts AlertsConfig `mapstructure:"alerts"`
+
+	// DetectOnly leaves telemetry unmasked and only detects sensitive data
+	DetectOnly DetectOnlyConfig `mapstructure:"detect_only"`
+
+	// MaskedFieldTypes types the masked replacement of fields masked whole by
+	// their key: "string" (the token), "int" (an integer surrogate of the token),
+	// "bool" (false), or "map" (an empty map)
+	MaskedFieldTypes map[string]string `mapstructure:"masked_field_types"`
+
+	// FieldStrategies masks fields masked whole by their key with another
+	// strategy than their token, e.g. partial masking
+	FieldStrategies map[string]StrategyConfig `mapstructure:"field_strategies"`
+}
+
+// AccessLogConfig defines the handling of access log attributes such as response
+// bodies and gRPC status messages. Their values are truncated and pattern-scanned in
+// full, even when scan_all_attributes is disabled.
+type AccessLogConfig struct {
+	// Enabled turns on the handling of Keys
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+
+	// Keys are the attributes handled as access log fields
+	Keys []string `mapstructure:"keys" json:"keys"`
+
+	// MaxBytes truncates values longer than this (0 = no truncation)
+	MaxBytes int `mapstructure:"max_bytes" json:"max_bytes"`
+}
+
+// SecretKeysConfig defines the key based heuristic for passwords in JSON and
+// form-encoded payloads
+type SecretKeysConfig struct {
+	// Enabled turns on the heuristic
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+
+	// KeyRegex matches the keys whose values are masked
+	KeyRegex string `mapstructure:"key_regex" json:"key_regex"`
+}
+
+// SpanConfig defines the masking of attributes attached to spans rather than
+// the spans themselves
+type SpanConfig struct {
+	// Events masks the attributes of span events
+	Events bool `mapstructure:"events" json:"events"`
+
+	// Links masks the attributes of span links
+	Links bool `mapstructure:"links" json:"links"`
+
+	// ScanAll scans every string value of event and link attributes for patterns,
+	// even when scan_all_attributes is disabled
+	ScanAll bool `mapstructure:"scan_all" json:"scan_all"`
+}
+
+// MetricConfig defines the masking of data attached to metric datapoints rather
+// than the datapoints themselves
+type MetricConfig struct {
+	// Exemplars masks the filtered attributes of exemplars
+	Exemplars bool `mapstructure:"exemplars" json:"exemplars"`
+
+	// RemoveExemplarTraceContext clears the trace and span IDs of exemplars, so
+	// metrics cannot be joined to the traces of individual requests
+	RemoveExemplarTraceContext bool `mapstructure:"remove_exemplar_trace_context" json:"remove_exemplar_trace_context"`
+}
+
+// StructuredFieldConfig applies a format aware strategy to attribute values or the
+// log body. Values the strategy cannot parse are pattern-scanned instead.
+type StructuredFieldConfig struct {
+	// Strategy is the format of the values: "graphql", "baggage", "tracestate", "cookie",
+	// or "identity"
+	Strategy string `mapstructure:"strategy" json:"strategy"`
+
+	// Keys are the attributes the strategy applies to. "body" applies it to the log body.
+	Keys []string `mapstructure:"keys" json:"keys"`
+
+	// Members are the baggage or tracestate keys, or cookie names, whose values are
+	// masked (empty = all)
+	Members []string `mapstructure:"members" json:"members"`
+}
+
+// LatencyBudgetConfig defines the per-batch latency budget and the order in which
+// masking is degraded while it is exceeded
+type LatencyBudgetConfig struct {
+	// Budget is the target processing time of a batch (0 = disabled)
+	Budget time.Duration `mapstructure:"budget"`
+
+	// DegradeAfter is how many consecutive batches over budget apply the next step
+	DegradeAfter int `mapstructure:"degrade_after"`
+
+	// RecoverAfter is how many consecutive batches under half the budget revert the last step
+	RecoverAfter int `mapstructure:"recover_after"`
+
+	// Steps are applied in order: "disable_low_priority_patterns", "deterministic", and "detect_only"
+	Steps []string `mapstructure:"steps"`
+}
+
+// Enabled reports whether a latency budget is configured
+func (cfg *LatencyBudgetConfig) Enabled() bool {
+	return cfg.Budget > 0
+}
+
+// Validate checks the latency budget configuration
+func (cfg *LatencyBudgetConfig) Validate() error {
+	if cfg.Budget < 0 {
+		return errors.New("latency_budget budget must be non-negative")
+	}
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.DegradeAfter <= 0 || cfg.RecoverAfter <= 0 {
+		return errors.New("latency_budget degrade_after and recover_after must be positive")
+	}
+
+	for i, step := range cfg.Steps {
+		switch step {
+		case stepDisableLowPriority, stepDeterministic, stepDetectOnly:
+		default:
+			return fmt.Errorf("unsupported latency_budget step '%s'", step)
+		}
+		if slices.Contains(cfg.Steps[:i], step) {
+			return fmt.Errorf("latency_budget step '%s' is listed more than once", step)
+		}
+	}
+	return nil
+}
+
+// DiscoveryConfig defines a learning mode that observes which attribute values
+// match the patterns and periodically logs a suggested fields_to_mask configuration
+type DiscoveryConfig struct {
+	// Enabled turns on discovery
+	Enabled bool `mapstructure:"enabled"`
+
+	// Window is how long attributes are observed before each suggestion is logged
+	Window time.Duration `mapstructure:"window"`
+}
+
+// ProvenanceConfig defines the attributes recording which masking policy was applied,
+// so downstream policy engines can verify records were sanitized before export
+type ProvenanceConfig struct {
+	// Policy is the policy name and version, e.g. "pci-v3". Tagging is disabled when empty.
+	Policy string `mapstructure:"policy" json:"policy"`
+
+	// Profile optionally names the profile of the policy that was applied
+	Profile string `mapstructure:"profile" json:"profile"`
+
+	// Attribute receives the policy. The profile is written to Attribute + ".profile".
+	Attribute string `mapstructure:"attribute" json:"attribute"`
+}
+
+// Enabled reports whether records are tagged
+func (cfg *ProvenanceConfig) Enabled() bool {
+	return cfg.Policy != ""
+}
+
+// OPAConfig defines an embedded OPA policy deciding whether each record is
+// masked, passed through unchanged, or dropped
+type OPAConfig struct {
+	// PolicyFile is the Rego file evaluated for every record. The hook is disabled when empty.
+	PolicyFile string `mapstructure:"policy_file"`
+
+	// Query is the rule the decision is read from: "mask", "skip", or "drop"
+	Query string `mapstructure:"query"`
+
+	// Destination is passed to the policy as a hint of where records are exported
+	Destination string `mapstructure:"destination"`
+
+	// AuthAttributes are the attributes of the authenticated caller passed to the
+	// policy, e.g. "subject" or "tenant"
+	AuthAttributes []string `mapstructure:"auth_attributes"`
+
+	// MetadataKeys are the client metadata keys passed to the policy. Receivers
+	// only propagate metadata with include_metadata enabled.
+	MetadataKeys []string `mapstructure:"metadata_keys"`
+}
+
+// Enabled reports whether the policy hook is configured
+func (cfg *OPAConfig) Enabled() bool {
+	return cfg.PolicyFile != ""
+}
+
+// ReplicationConfig defines how new mappings are published to Kafka
+type ReplicationConfig struct {
+	// Brokers are the Kafka bootstrap servers. Replication is disabled when empty.
+	Brokers []string `mapstructure:"brokers"`
+
+	// Topic receives one record per new mapping
+	Topic string `mapstructure:"topic"`
+
+	// EncryptionKey is a base64 encoded 16, 24, or 32 byte AES key used to encrypt original values
+	EncryptionKey string `mapstructure:"encryption_key"`
+}
+
+// Enabled reports whether replication is configured
+func (cfg *ReplicationConfig) Enabled() bool {
+	return len(cfg.Brokers) > 0
+}
+
+// Key decodes the encryption key
+func (cfg *ReplicationConfig) Key() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("replication encryption_key is not valid base64: %w", err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, errors.New("replication encryption_key must decode to 16, 24, or 32 bytes")
+	}
+}
+
+// Validate checks the replication configuration
+func (cfg *ReplicationConfig) Validate() error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.Topic == "" {
+		return errors.New("replication topic is required")
+	}
+
+	_, err := cfg.Key()
+	return err
+}
+
+const (
+	// modeStandard backs every token with a Redis mapping
+	modeStandard = "standard"
+
+	// modeLightweight is intended for resource-constrained edge agents
+	modeLightweight = "lightweight"
+
+	// modeActiveActive derives tokens deterministically and treats Redis as a cache
+	modeActiveActive = "active_active"
+
+	// modeReadOnly looks up tokens from a mapping populated offline
+	modeReadOnly = "read_only"
+
+	// tokenFormatDefault keeps the category specific token shapes
+	tokenFormatDefault = "default"
+
+	// tokenFormatUUID formats tokens as deterministic version 4 UUIDs
+	tokenFormatUUID = "uuid"
+
+	// tokenFormatEnvelope formats tokens as verifiable envelopes, see ParseToken
+	tokenFormatEnvelope = "envelope"
+
+	// tokenFormatFPE enciphers the digits of values with FF3-1, keeping their format
+	tokenFormatFPE = "fpe"
+
+	// tokenFormatFake formats tokens as realistic fake values, see fake_kinds
+	tokenFormatFake = "fake"
+
+	// strategyGraphQL tokenizes the literals of GraphQL documents and variables
+	strategyGraphQL = "graphql"
+
+	// strategyBaggage masks member values of W3C baggage headers
+	strategyBaggage = "baggage"
+
+	// strategyTraceState masks member values of W3C tracestate headers
+	strategyTraceState = "tracestate"
+
+	// strategyCookie masks cookie values of Cookie and Set-Cookie headers
+	strategyCookie = "cookie"
+
+	// strategyIdentity gives every form of a user name the same identity token
+	strategyIdentity = "identity"
+
+	// bodyKey selects the log body in structured_fields keys
+	bodyKey = "body"
+
+	// bodyKeysExact matches map body members by their key
+	bodyKeysExact = "exact"
+
+	// bodyKeysPath also matches map body members by their dotted path
+	bodyKeysPath = "path"
+
+	// scanOverflowPass keeps the unscanned tail of long values
+	scanOverflowPass = "pass"
+
+	// scanOverflowTruncate drops the unscanned tail of long values
+	scanOverflowTruncate = "truncate"
+
+	// stepDisableLowPriority skips patterns with the low priority
+	stepDisableLowPriority = "disable_low_priority_patterns"
+
+	// stepDeterministic derives tokens without the store
+	stepDeterministic = "deterministic"
+
+	// stepDetectOnly leaves values unchanged and only flags records holding sensitive data
+	stepDetectOnly = "detect_only"
+
+	// priorityLow marks patterns that are skipped first under latency pressure
+	priorityLow = "low"
+
+	// lightweightMaxPatterns caps the number of patterns evaluated in lightweight mode
+	lightweightMaxPatterns = 8
+
+	// lightweightMaxScanBytes is the default scan limit in lightweight mode
+	lightweightMaxScanBytes = 16 * 1024
+)
+
+// PatternConfig defines a pattern to detect and mask
+type PatternConfig struct {
+	// Name of the pattern (e.g., "ip_address", "hostname")
+	Name string `mapstructure:"name" json:"name"`
+
+	// Regex pattern to match
+	Regex string `mapstructure:"regex" json:"regex"`
+
+	// Prefix for masked values (e.g., "IP-", "HOST-")
+	MaskedPrefix string `mapstructure:"masked_prefix" json:"masked_prefix"`
+
+	// TokenFormat overrides the default token format for this pattern
+	TokenFormat string `mapstructure:"token_format" json:"token_format"`
+
+	// TokenLength overrides the default token length for this pattern
+	TokenLength int `mapstructure:"token_length" json:"token_length"`
+
+	// Strategy is "tokenize" (the default), "email", "partial", or "redact", see
+	// StrategyConfig
+	Strategy string `mapstructure:"strategy" json:"strategy"`
+
+	// Partial defines the characters kept by the partial strategy
+	Partial PartialConfig `mapstructure:"partial" json:"partial"`
+
+	// Redaction replaces the matches of the redact strategy
+	Redaction string `mapstructure:"redaction" json:"redaction"`
+
+	// Priority is "low" for patterns that may be skipped to meet the latency budget
+	Priority string `mapstructure:"priority" json:"priority"`
+
+	// valid, when set, rejects matches that fail a check digit of the built-in pattern
+	valid func(string) bool
+
+	// namesFile, when set, limits the matches to the words of a names file
+	namesFile string
+}
+
+// NewDefaultConfig returns the default engine configuration
+func NewDefaultConfig() Config {
+	return Config{
+		RedisAddr:     "localhost:6379",
+		RedisPassword: "",
+		RedisDB:       0,
+		Store:         storeRedis,
+		FileStore: FileStoreConfig{
+			CompactionInterval: time.Hour,
+		},
+		Memcached: MemcachedConfig{
+			Timeout:      500 * time.Millisecond,
+			MaxIdleConns: 10,
+		},
+		DynamoDB: DynamoDBConfig{
+			Timeout: 2 * time.Second,
+		},
+		SQLStore: SQLStoreConfig{
+			Table:        "redismasking_mappings",
+			Migrate:      true,
+			MaxOpenConns: 10,
+			Timeout:      2 * time.Second,
+		},
+		Firestore: FirestoreConfig{
+			Database:   "(default)",
+			Collection: "redismasking_mappings",
+			Timeout:    2 * time.Second,
+		},
+		Etcd: EtcdConfig{
+			Prefix:      "redismasking/",
+			DialTimeout: 5 * time.Second,
+			Timeout:     2 * time.Second,
+		},
+		TokenTTL:             0, // No expiration by default
+		FieldsToMask:         []string{},
+		ExcludeKeys:          []string{},
+		ResourceFieldsToMask: []string{},
+		Patterns:             DefaultPatterns(),
+		Mode:                 modeStandard,
+		BodyKeys:             bodyKeysExact,
+		ScanOverflow:         scanOverflowPass,
+		HashAlgorithm:        hashAlgorithmSHA256,
+		TokenLength:          defaultTokenLength,
+		ReadOnly: ReadOnlyConfig{
+			Fallback: readOnlyFallbackHash,
+		},
+		Provenance: ProvenanceConfig{
+			Attribute: "masking.policy",
+		},
+		AccessLogFields: AccessLogConfig{
+			Keys: []string{
+				"http.request.body",
+				"http.response.body",
+				"grpc.status_description",
+				"grpc.status_message",
+			},
+			MaxBytes: 4096,
+		},
+		Spans: SpanConfig{
+			Events: true,
+		},
+		Metrics: MetricConfig{
+			Exemplars: true,
+		},
+		SecretKeys: SecretKeysConfig{
+			KeyRegex: `(?i)pass(word)?|pwd|secret`,
+		},
+		LatencyBudget: LatencyBudgetConfig{
+			DegradeAfter: 5,
+			RecoverAfter: 20,
+			Steps:        []string{stepDisableLowPriority, stepDeterministic, stepDetectOnly},
+		},
+		WriteBehind: WriteBehindConfig{
+			QueueSize: 10000,
+			Workers:   4,
+		},
+		Discovery: DiscoveryConfig{
+			Window: time.Hour,
+		},
+		OPA: OPAConfig{
+			Query: "data.redismasking.decision",
+		},
+		ErrorLogInterval: 10 * time.Second,
+		Watchlist: WatchlistConfig{
+			RefreshInterval: 30 * time.Second,
+		},
+		DistinctCounts: DistinctCountConfig{
+			Interval: time.Hour,
+			Epsilon:  1,
+		},
+		Alerts: AlertsConfig{
+			MetricPrefix:        "otelcol_",
+			For:                 10 * time.Minute,
+			CacheMissRatio:      0.5,
+			TokenCreationFactor: 3,
+		},
+		DetectOnly: DetectOnlyConfig{
+			Annotate: true,
+		},
+		CardholderData: CardholderDataConfig{
+			Distance: 64,
+		},
+	}
+}
+
+// DefaultPatterns returns the built-in patterns
+func DefaultPatterns() []PatternConfig {
+	return []PatternConfig{
+		{
+			Name:         "ipv6",
+			Regex:        ipv6Regex,
+			MaskedPrefix: "IP-",
+		},
+		{
+			Name:         "ipv4",
+			Regex:        `\b(?:\d{1,3}\.){3}\d{1,3}\b`,
+			MaskedPrefix: "IP-",
+		},
+		{
+			Name:         macPattern,
+			Regex:        macRegex,
+			MaskedPrefix: "MAC-",
+		},
+		{
+			Name:         "hostname",
+			Regex:        `\b[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*\b`,
+			MaskedPrefix: "HOST-",
+		},
+	}
+}
+
+// lightweightPatterns returns the reduced pattern set used in lightweight mode.
+// The greedy hostname pattern is omitted because it matches nearly every word.
+func lightweightPatterns() []PatternConfig {
+	return []PatternConfig{
+		{
+			Name:         "ipv4",
+			Regex:        `\b(?:\d{1,3}\.){3}\d{1,3}\b`,
+			MaskedPrefix: "IP-",
+		},
+	}
+}
+
+// Validate checks if the engine configuration is valid
+func (cfg *Config) Validate() error {
+	if err := ValidateRedisNetwork(cfg.RedisNetwork, cfg.RedisAddr); err != nil {
+		return err
+	}
+	if cfg.RedisAddr == "" {
+		cfg.RedisAddr = "localhost:6379"
+	}
+
+	if err := cfg.RedisPool.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RedisTimeouts.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RedisRetry.Validate(); err != nil {
+		return err
+	}
+
+	if err := ValidateRedisAuth(&cfg.RedisAuth, cfg.RedisPassword, &cfg.TLS); err != nil {
+		return err
+	}
+
+	if err := ValidateRedisPasswordFile(cfg.RedisPasswordFile, cfg.RedisPassword, &cfg.RedisAuth); err != nil {
+		return err
+	}
+
+	if err := cfg.RedisReplicas.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.validateHMACKey(); err != nil {
+		return err
+	}
+
+	if err := ValidateClientCache(&cfg.RedisClientCache, &cfg.RedisReplicas); err != nil {
+		return err
+	}
+
+	if cfg.TokenTTL < 0 {
+		return errors.New("token_ttl must be non-negative")
+	}
+
+	if err := cfg.validateStore(); err != nil {
+		return err
+	}
+
+	if _, err := cfg.TLS.Load(); err != nil {
+		return err
+	}
+
+	if err := validateTokenFormat(cfg.TokenFormat); err != nil {
+		return err
+	}
+	if err := cfg.validateTokenLengths(); err != nil {
+		return err
+	}
+	if err := cfg.validatePreserveIPPrefix(); err != nil {
+		return err
+	}
+	for key, kind := range cfg.FakeKinds {
+		if err := validateFakeKind(kind); err != nil {
+			return fmt.Errorf("%w for '%s'", err, key)
+		}
+	}
+	if cfg.FPEKey != "" {
+		if _, err := cfg.fpeKey(); err != nil {
+			return err
+		}
+	} else if cfg.usesFPE() {
+		return errors.New("fpe_key is required with the fpe token format")
+	}
+
+	for _, pattern := range cfg.Patterns {
+		if _, err := regexp.Compile(pattern.Regex); err != nil {
+			return fmt.Errorf("failed to compile regex pattern '%s': %w", pattern.Name, err)
+		}
+		if err := validateTokenFormat(pattern.TokenFormat); err != nil {
+			return fmt.Errorf("pattern '%s': %w", pattern.Name, err)
+		}
+		strategy := pattern.strategy()
+		if err := strategy.Validate(); err != nil {
+			return fmt.Errorf("pattern '%s': %w", pattern.Name, err)
+		}
+		if strategy.Strategy == maskingStrategyDrop {
+			return fmt.Errorf("pattern '%s': the drop strategy only applies to field_strategies", pattern.Name)
+		}
+		if pattern.Priority != "" && pattern.Priority != priorityLow {
+			return fmt.Errorf("pattern '%s': unsupported priority '%s'", pattern.Name, pattern.Priority)
+		}
+	}
+
+	if err := cfg.MatchFilter.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.ShortCircuit.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.SecretKeys.Enabled {
+		if _, err := regexp.Compile(cfg.SecretKeys.KeyRegex); err != nil {
+			return fmt.Errorf("failed to compile secret_keys key_regex: %w", err)
+		}
+	}
+
+	for _, pack := range cfg.PatternPacks {
+		if _, ok := patternPacks[pack]; !ok {
+			return fmt.Errorf("unsupported pattern pack '%s'", pack)
+		}
+	}
+
+	if err := cfg.LicensePlates.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Healthcare.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.CardholderData.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RecordFlags.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.AccessLogFields.MaxBytes < 0 {
+		return errors.New("access_log_fields max_bytes must be non-negative")
+	}
+
+	if err := cfg.validateStructuredFields(); err != nil {
+		return err
+	}
+
+	if slices.Contains(cfg.BodyMetadataDelimiters, "") {
+		return errors.New("body_metadata_delimiters must not be empty")
+	}
+
+	if err := validateCharsets(cfg.BodyCharsets); err != nil {
+		return err
+	}
+
+	if err := cfg.validateMaskedFieldTypes(); err != nil {
+		return err
+	}
+	if err := cfg.validateFieldStrategies(); err != nil {
+		return err
+	}
+
+	switch cfg.BodyKeys {
+	case "", bodyKeysExact, bodyKeysPath:
+	default:
+		return fmt.Errorf("unsupported body_keys '%s'", cfg.BodyKeys)
+	}
+
+	if cfg.MaxScanBytes < 0 {
+		return errors.New("max_scan_bytes must be non-negative")
+	}
+
+	switch cfg.ScanOverflow {
+	case "", scanOverflowPass, scanOverflowTruncate:
+	default:
+		return fmt.Errorf("unsupported scan_overflow '%s'", cfg.ScanOverflow)
+	}
+
+	if cfg.LocalCacheSize < 0 {
+		return errors.New("local_cache_size must be non-negative")
+	}
+
+	if cfg.WarmupTopN < 0 {
+		return errors.New("warmup_top_n must be non-negative")
+	}
+
+	if cfg.WarmupTopN > 0 && cfg.LocalCacheSize == 0 {
+		return errors.New("warmup_top_n requires local_cache_size")
+	}
+
+	if cfg.LocalCacheInvalidation && cfg.LocalCacheSize == 0 {
+		return errors.New("local_cache_invalidation requires local_cache_size")
+	}
+
+	if _, err := cfg.ReservedNamespaces.parse(); err != nil {
+		return err
+	}
+
+	if strings.ContainsAny(cfg.TokenNamespace, ":/") {
+		return errors.New("token_namespace must not contain ':' or '/'")
+	}
+
+	if cfg.EnrichWithLookupURL != "" {
+		if u, err := url.Parse(cfg.EnrichWithLookupURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("enrich_with_lookup_url must be an absolute http or https URL")
+		}
+	}
+
+	if err := cfg.LatencyBudget.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.WriteBehind.Validate(); err != nil {
+		return err
+	}
+	if cfg.WriteBehind.Enabled {
+		if cfg.Mode != "" && cfg.Mode != modeStandard {
+			return fmt.Errorf("write_behind is not used in %s mode", cfg.Mode)
+		}
+		if !cfg.hasHMACKey() {
+			return errors.New("hmac_key is required with write_behind")
+		}
+		// Colliding fake values are derived again, which needs the store
+		// before the token is handed out
+		if cfg.usesFake() {
+			return errors.New("the fake token format is not used with write_behind")
+		}
+	}
+
+	if cfg.ErrorLogInterval < 0 {
+		return errors.New("error_log_interval must be non-negative")
+	}
+
+	if err := cfg.Watchlist.Validate(); err != nil {
+		return err
+	}
+	if cfg.Watchlist.Enabled && cfg.isLightweight() {
+		return errors.New("watchlist requires Redis and cannot be used in lightweight mode")
+	}
+
+	if err := cfg.DistinctCounts.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.Alerts.Validate(); err != nil {
+		return err
+	}
+
+	if cfg.Discovery.Enabled && cfg.Discovery.Window <= 0 {
+		return errors.New("discovery window must be positive")
+	}
+
+	if cfg.Provenance.Enabled() && cfg.Provenance.Attribute == "" {
+		return errors.New("provenance attribute is required")
+	}
+
+	if cfg.OPA.Enabled() && cfg.OPA.Query == "" {
+		return errors.New("opa query is required")
+	}
+
+	if err := cfg.Replication.Validate(); err != nil {
+		return err
+	}
+
+	switch cfg.Mode {
+	case "", modeStandard:
+	case modeLightweight:
+		if !cfg.hasHMACKey() {
+			return errors.New("hmac_key is required in lightweight mode")
+		}
+		if len(cfg.effectivePatterns()) > lightweightMaxPatterns {
+			return fmt.Errorf("lightweight mode supports at most %d patterns", lightweightMaxPatterns)
+		}
+		if cfg.AllowSaltChange {
+			return errors.New("allow_salt_change is not used in lightweight mode")
+		}
+	case modeActiveActive:
+		if !cfg.hasHMACKey() {
+			return errors.New("hmac_key is required in active_active mode")
+		}
+		if cfg.usesFake() {
+			return errors.New("the fake token format is not used in active_active mode")
+		}
+	case modeReadOnly:
+		if err := cfg.ReadOnly.Validate(); err != nil {
+			return err
+		}
+		if cfg.ReadOnly.Fallback == readOnlyFallbackHash && !cfg.hasHMACKey() {
+			return errors.New("hmac_key is required with the read_only hash fallback")
+		}
+		if cfg.Replication.Enabled() {
+			return errors.New("replication is not used in read_only mode")
+		}
+		if cfg.Watchlist.Enabled {
+			return errors.New("watchlist cannot be used in read_only mode")
+		}
+		if cfg.TrackAccess {
+			return errors.New("track_access is not used in read_only mode")
+		}
+		if cfg.AllowSaltChange {
+			return errors.New("allow_salt_change is not used in read_only mode")
+		}
+	default:
+		return fmt.Errorf("unsupported mode '%s'", cfg.Mode)
+	}
+
+	return nil
+}
+
+// validateTokenFormat checks that format is a supported token format
+func validateTokenFormat(format string) error {
+	switch format {
+	case "", tokenFormatDefault, tokenFormatUUID, tokenFormatEnvelope, tokenFormatFPE, tokenFormatFake:
+		return nil
+	default:
+		return fmt.Errorf("unsupported token_format '%s'", format)
+	}
+}
+
+// validateStructuredFields checks that every structured field uses a supported
+// strategy and that each key is handled by at most one strategy
+func (cfg *Config) validateStructuredFields() error {
+	seen := map[string]bool{}
+	for _, field := range cfg.StructuredFields {
+		switch field.Strategy {
+		case strategyGraphQL, strategyIdentity:
+			if len(field.Members) > 0 {
+				return fmt.Errorf("structured_fields strategy '%s' does not support members", field.Strategy)
+			}
+		case strategyBaggage, strategyTraceState, strategyCookie:
+		default:
+			return fmt.Errorf("unsupported structured_fields strategy '%s'", field.Strategy)
+		}
+
+		if len(field.Keys) == 0 {
+			return fmt.Errorf("structured_fields strategy '%s' requires keys", field.Strategy)
+		}
+
+		for _, key := range field.Keys {
+			if seen[key] {
+				return fmt.Errorf("structured_fields key '%s' has more than one strategy", key)
+			}
+			seen[key] = true
+		}
+	}
+	return nil
+}
+
+// structuredFieldsByKey returns the structured field handling each key
+func (cfg *Config) structuredFieldsByKey() map[string]StructuredFieldConfig {
+	fields := map[string]StructuredFieldConfig{}
+	for _, field := range cfg.StructuredFields {
+		for _, key := range field.Keys {
+			fields[key] = field
+		}
+	}
+	return fields
+}
+
+// isLightweight reports whether the lightweight preset is enabled
+func (cfg *Config) isLightweight() bool {
+	return cfg.Mode == modeLightweight
+}
+
+// isActiveActive reports whether tokens are derived independently of the store
+func (cfg *Config) isActiveActive() bool {
+	return cfg.Mode == modeActiveActive
+}
+
+// isReadOnly reports whether tokens are only looked up from the store
+func (cfg *Config) isReadOnly() bool {
+	return cfg.Mode == modeReadOnly
+}
+
+// StoreEnabled reports whether the configuration requires a token store.
+// Lightweight mode derives every token deterministically without one.
+func (cfg *Config) StoreEnabled() bool {
+	return !cfg.isLightweight()
+}
+
+// RedisStoreEnabled reports whether mappings are kept in Redis
+func (cfg *Config) RedisStoreEnabled() bool {
+	return cfg.StoreEnabled() && (cfg.Store == "" || cfg.Store == storeRedis)
+}
+
+// LocalStoreEnabled reports whether mappings are kept by the collector itself
+// instead of Redis
+func (cfg *Config) LocalStoreEnabled() bool {
+	return cfg.StoreEnabled() && (cfg.Store == storeMemory || cfg.Store == storeFile)
+}
+
+// AccessTrackingEnabled reports whether mapping accesses are recorded
+func (cfg *Config) AccessTrackingEnabled() bool {
+	return cfg.StoreEnabled() && (cfg.TrackAccess || cfg.WarmupTopN > 0)
+}
+
+// effectivePatterns returns the patterns to compile for the configured mode,
+// preceded by the patterns of the enabled packs. Lightweight mode swaps the
+// default pattern set for a smaller, faster one.
+func (cfg *Config) effectivePatterns() []PatternConfig {
+	patterns := cfg.Patterns
+	if cfg.isLightweight() && reflect.DeepEqual(cfg.Patterns, DefaultPatterns()) {
+		patterns = lightweightPatterns()
+	}
+	return append(cfg.packPatterns(), patterns...)
+}
+
+// effectiveMaxScanBytes returns the scan limit for the configured mode
+func (cfg *Config) effectiveMaxScanBytes() int {
+	if cfg.isLightweight() && cfg.MaxScanBytes == 0 {
+		return lightweightMaxScanBytes
+	}
+	return cfg.MaxScanBytes
+}
+
+// scanOverflow returns the handling of unscanned tails, passed through by default
+func (cfg *Config) scanOverflow() string {
+	if cfg.ScanOverflow == "" {
+		return scanOverflowPass
+	}
+	return cfg.ScanOverflow
+}
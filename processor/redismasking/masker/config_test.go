@@ -0,0 +1,749 @@
+package masker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		modify      func(cfg *Config)
+		expectedErr string
+	}{
+		{
+			name:   "default config",
+			modify: func(*Config) {},
+		},
+		{
+			name:        "unsupported redis network",
+			modify:      func(cfg *Config) { cfg.RedisNetwork = "udp" },
+			expectedErr: "unsupported redis_network 'udp'",
+		},
+		{
+			name: "unix socket without path",
+			modify: func(cfg *Config) {
+				cfg.RedisNetwork = "unix"
+				cfg.RedisAddr = ""
+			},
+			expectedErr: "redis_addr must be the socket path when redis_network is unix",
+		},
+		{
+			name:        "negative redis pool size",
+			modify:      func(cfg *Config) { cfg.RedisPool.Size = -1 },
+			expectedErr: "redis_pool settings must be non-negative",
+		},
+		{
+			name: "redis pool idle connections exceed size",
+			modify: func(cfg *Config) {
+				cfg.RedisPool = PoolConfig{Size: 4, MinIdleConns: 8}
+			},
+			expectedErr: "redis_pool min_idle_conns must not exceed size",
+		},
+		{
+			name:        "negative redis read timeout",
+			modify:      func(cfg *Config) { cfg.RedisTimeouts.Read = -time.Second },
+			expectedErr: "redis_timeouts must be non-negative",
+		},
+		{
+			name: "redis retry intervals out of order",
+			modify: func(cfg *Config) {
+				cfg.RedisRetry = RetryConfig{InitialInterval: time.Second, MaxInterval: time.Millisecond}
+			},
+			expectedErr: "redis_retry initial_interval must not exceed max_interval",
+		},
+		{
+			name: "redis auth without tls",
+			modify: func(cfg *Config) {
+				cfg.RedisAuth = AuthConfig{Provider: "aws_iam", AWSIAM: AWSIAMAuthConfig{UserID: "masking", CacheName: "tokens"}}
+			},
+			expectedErr: "redis_auth provider requires tls to be enabled",
+		},
+		{
+			name: "redis password and password file",
+			modify: func(cfg *Config) {
+				cfg.RedisPassword = "secret"
+				cfg.RedisPasswordFile = "/run/secrets/redis"
+			},
+			expectedErr: "redis_password and redis_password_file are mutually exclusive",
+		},
+		{
+			name:        "empty redis replica",
+			modify:      func(cfg *Config) { cfg.RedisReplicas.Addrs = []string{"replica-1:6379", ""} },
+			expectedErr: "redis_replicas addrs must not be empty",
+		},
+		{
+			name: "redis client cache with replicas",
+			modify: func(cfg *Config) {
+				cfg.RedisReplicas.Addrs = []string{"replica-1:6379"}
+				cfg.RedisClientCache.Size = 1000
+			},
+			expectedErr: "redis_client_cache cannot be combined with redis_replicas",
+		},
+		{
+			name:        "negative token ttl",
+			modify:      func(cfg *Config) { cfg.TokenTTL = -1 },
+			expectedErr: "token_ttl must be non-negative",
+		},
+		{
+			name:        "invalid pattern",
+			modify:      func(cfg *Config) { cfg.Patterns = []PatternConfig{{Name: "broken", Regex: "("}} },
+			expectedErr: "failed to compile regex pattern 'broken': error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:   "envelope token format",
+			modify: func(cfg *Config) { cfg.TokenFormat = tokenFormatEnvelope },
+		},
+		{
+			name: "fpe token format without key",
+			modify: func(cfg *Config) {
+				cfg.Patterns = []PatternConfig{{Name: "ssn", Regex: `\b\d{3}-\d{2}-\d{4}\b`, TokenFormat: tokenFormatFPE}}
+			},
+			expectedErr: "fpe_key is required with the fpe token format",
+		},
+		{
+			name: "fpe key of invalid length",
+			modify: func(cfg *Config) {
+				cfg.TokenFormat = tokenFormatFPE
+				cfg.FPEKey = "c2hvcnQ="
+			},
+			expectedErr: "fpe_key must decode to 16, 24, or 32 bytes",
+		},
+		{
+			name: "valid fpe token format",
+			modify: func(cfg *Config) {
+				cfg.TokenFormat = tokenFormatFPE
+				cfg.FPEKey = "MDEyMzQ1Njc4OWFiY2RlZg=="
+			},
+		},
+		{
+			name:        "unsupported body charset",
+			modify:      func(cfg *Config) { cfg.BodyCharsets = []string{"ebcdic"} },
+			expectedErr: "unsupported body_charsets entry 'ebcdic'",
+		},
+		{
+			name: "unsupported masked field type",
+			modify: func(cfg *Config) {
+				cfg.FieldsToMask = []string{"user_id"}
+				cfg.MaskedFieldTypes = map[string]string{"user_id": "float"}
+			},
+			expectedErr: "unsupported masked_field_types type 'float' for 'user_id'",
+		},
+		{
+			name:        "masked field type of unmasked field",
+			modify:      func(cfg *Config) { cfg.MaskedFieldTypes = map[string]string{"user_id": "int"} },
+			expectedErr: "masked_field_types key 'user_id' is not in fields_to_mask or resource_fields_to_mask",
+		},
+		{
+			name: "unsupported field strategy",
+			modify: func(cfg *Config) {
+				cfg.FieldsToMask = []string{"user_id"}
+				cfg.FieldStrategies = map[string]StrategyConfig{"user_id": {Strategy: "shuffle"}}
+			},
+			expectedErr: "field_strategies 'user_id': unsupported strategy 'shuffle'",
+		},
+		{
+			name:        "field strategy of unmasked field",
+			modify:      func(cfg *Config) { cfg.FieldStrategies = map[string]StrategyConfig{"user_id": {}} },
+			expectedErr: "field_strategies key 'user_id' is not in fields_to_mask or resource_fields_to_mask",
+		},
+		{
+			name: "masked field type of partially masked field",
+			modify: func(cfg *Config) {
+				cfg.FieldsToMask = []string{"user_id"}
+				cfg.MaskedFieldTypes = map[string]string{"user_id": maskedTypeInt}
+				cfg.FieldStrategies = map[string]StrategyConfig{"user_id": {Strategy: maskingStrategyPartial, Partial: PartialConfig{KeepLast: 4}}}
+			},
+			expectedErr: "masked_field_types key 'user_id' only applies to tokenized fields",
+		},
+		{
+			name: "partial pattern without kept characters",
+			modify: func(cfg *Config) {
+				cfg.Patterns = []PatternConfig{{Name: "phone", Regex: `\+\d{11}`, Strategy: maskingStrategyPartial}}
+			},
+			expectedErr: "pattern 'phone': partial requires keep_first or keep_last",
+		},
+		{
+			name: "drop pattern",
+			modify: func(cfg *Config) {
+				cfg.Patterns = []PatternConfig{{Name: "ssn", Regex: `\b\d{3}-\d{2}-\d{4}\b`, Strategy: maskingStrategyDrop}}
+			},
+			expectedErr: "pattern 'ssn': the drop strategy only applies to field_strategies",
+		},
+		{
+			name: "partial mask char of several characters",
+			modify: func(cfg *Config) {
+				cfg.Patterns = []PatternConfig{{Name: "phone", Regex: `\+\d{11}`, Strategy: maskingStrategyPartial, Partial: PartialConfig{KeepLast: 4, MaskChar: "xx"}}}
+			},
+			expectedErr: "pattern 'phone': partial mask_char must be a single character",
+		},
+		{
+			name:        "unsupported body keys",
+			modify:      func(cfg *Config) { cfg.BodyKeys = "glob" },
+			expectedErr: "unsupported body_keys 'glob'",
+		},
+		{
+			name:        "negative error log interval",
+			modify:      func(cfg *Config) { cfg.ErrorLogInterval = -time.Second },
+			expectedErr: "error_log_interval must be non-negative",
+		},
+		{
+			name: "watchlist in lightweight mode",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeLightweight
+				cfg.HMACKey = "secret"
+				cfg.Watchlist.Enabled = true
+			},
+			expectedErr: "watchlist requires Redis and cannot be used in lightweight mode",
+		},
+		{
+			name: "distinct counts without epsilon",
+			modify: func(cfg *Config) {
+				cfg.DistinctCounts.Enabled = true
+				cfg.DistinctCounts.Epsilon = 0
+			},
+			expectedErr: "distinct_counts epsilon must be a positive number",
+		},
+		{
+			name: "distinct counts without interval",
+			modify: func(cfg *Config) {
+				cfg.DistinctCounts.Enabled = true
+				cfg.DistinctCounts.Interval = 0
+			},
+			expectedErr: "distinct_counts interval must be positive",
+		},
+		{
+			name:        "unsupported ip prefix",
+			modify:      func(cfg *Config) { cfg.PreserveIPPrefix = 8 },
+			expectedErr: "preserve_ip_prefix must be 0, 16, or 24",
+		},
+		{
+			name:        "unsupported fake kind",
+			modify:      func(cfg *Config) { cfg.FakeKinds = map[string]string{"email": "credit_card"} },
+			expectedErr: "unsupported fake_kinds kind 'credit_card' for 'email'",
+		},
+		{
+			name:        "unsupported hash algorithm",
+			modify:      func(cfg *Config) { cfg.HashAlgorithm = "md5" },
+			expectedErr: "unsupported hash_algorithm 'md5'",
+		},
+		{
+			name:        "token length longer than the digest",
+			modify:      func(cfg *Config) { cfg.TokenLength = 65 },
+			expectedErr: "token_length must be between 1 and 64 for hash_algorithm 'sha256'",
+		},
+		{
+			name: "pattern token length longer than the digest",
+			modify: func(cfg *Config) {
+				cfg.HashAlgorithm = hashAlgorithmBLAKE2b
+				cfg.Patterns = []PatternConfig{{Name: "email", Regex: `\S+@\S+`, TokenLength: 129}}
+			},
+			expectedErr: "pattern 'email': token_length must be between 1 and 128 for hash_algorithm 'blake2b'",
+		},
+		{
+			name: "valid sha512 token length",
+			modify: func(cfg *Config) {
+				cfg.HashAlgorithm = hashAlgorithmSHA512
+				cfg.TokenLength = 128
+			},
+		},
+		{
+			name:        "unsupported token format",
+			modify:      func(cfg *Config) { cfg.TokenFormat = "ulid" },
+			expectedErr: "unsupported token_format 'ulid'",
+		},
+		{
+			name: "unsupported pattern token format",
+			modify: func(cfg *Config) {
+				cfg.Patterns = []PatternConfig{{Name: "email", Regex: `\S+@\S+`, TokenFormat: "ulid"}}
+			},
+			expectedErr: "pattern 'email': unsupported token_format 'ulid'",
+		},
+		{
+			name: "invalid secret key regex",
+			modify: func(cfg *Config) {
+				cfg.SecretKeys.Enabled = true
+				cfg.SecretKeys.KeyRegex = "("
+			},
+			expectedErr: "failed to compile secret_keys key_regex: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:        "empty body metadata delimiter",
+			modify:      func(cfg *Config) { cfg.BodyMetadataDelimiters = []string{"|meta=", ""} },
+			expectedErr: "body_metadata_delimiters must not be empty",
+		},
+		{
+			name:        "unsupported pattern pack",
+			modify:      func(cfg *Config) { cfg.PatternPacks = []string{"crypto_wallets"} },
+			expectedErr: "unsupported pattern pack 'crypto_wallets'",
+		},
+		{
+			name: "unsupported pattern priority",
+			modify: func(cfg *Config) {
+				cfg.Patterns = []PatternConfig{{Name: "email", Regex: `\S+@\S+`, Priority: "high"}}
+			},
+			expectedErr: "pattern 'email': unsupported priority 'high'",
+		},
+		{
+			name:        "negative access log max bytes",
+			modify:      func(cfg *Config) { cfg.AccessLogFields.MaxBytes = -1 },
+			expectedErr: "access_log_fields max_bytes must be non-negative",
+		},
+		{
+			name: "unsupported structured field strategy",
+			modify: func(cfg *Config) {
+				cfg.StructuredFields = []StructuredFieldConfig{{Strategy: "xml", Keys: []string{"body"}}}
+			},
+			expectedErr: "unsupported structured_fields strategy 'xml'",
+		},
+		{
+			name: "structured field without keys",
+			modify: func(cfg *Config) {
+				cfg.StructuredFields = []StructuredFieldConfig{{Strategy: strategyGraphQL}}
+			},
+			expectedErr: "structured_fields strategy 'graphql' requires keys",
+		},
+		{
+			name: "graphql structured field with members",
+			modify: func(cfg *Config) {
+				cfg.StructuredFields = []StructuredFieldConfig{{Strategy: strategyGraphQL, Keys: []string{"body"}, Members: []string{"userid"}}}
+			},
+			expectedErr: "structured_fields strategy 'graphql' does not support members",
+		},
+		{
+			name: "structured field key with two strategies",
+			modify: func(cfg *Config) {
+				cfg.StructuredFields = []StructuredFieldConfig{
+					{Strategy: strategyGraphQL, Keys: []string{"graphql.document"}},
+					{Strategy: strategyGraphQL, Keys: []string{"graphql.document"}},
+				}
+			},
+			expectedErr: "structured_fields key 'graphql.document' has more than one strategy",
+		},
+		{
+			name:        "negative max scan bytes",
+			modify:      func(cfg *Config) { cfg.MaxScanBytes = -1 },
+			expectedErr: "max_scan_bytes must be non-negative",
+		},
+		{
+			name:        "unsupported scan overflow",
+			modify:      func(cfg *Config) { cfg.ScanOverflow = "redact" },
+			expectedErr: "unsupported scan_overflow 'redact'",
+		},
+		{
+			name:        "negative local cache size",
+			modify:      func(cfg *Config) { cfg.LocalCacheSize = -1 },
+			expectedErr: "local_cache_size must be non-negative",
+		},
+		{
+			name:        "local cache invalidation without local cache",
+			modify:      func(cfg *Config) { cfg.LocalCacheInvalidation = true },
+			expectedErr: "local_cache_invalidation requires local_cache_size",
+		},
+		{
+			name:        "cardholder data without distance",
+			modify:      func(cfg *Config) { cfg.CardholderData = CardholderDataConfig{Enabled: true} },
+			expectedErr: "cardholder_data distance must be positive",
+		},
+		{
+			name:        "unsupported alert rule",
+			modify:      func(cfg *Config) { cfg.Alerts.Rules = []string{"disk_full"} },
+			expectedErr: "unsupported alerts rule 'disk_full'",
+		},
+		{
+			name:        "record flags value outside mask",
+			modify:      func(cfg *Config) { cfg.RecordFlags = RecordFlagsConfig{Mask: 0x100, Value: 0x1} },
+			expectedErr: "record_flags value must only set bits of mask",
+		},
+		{
+			name:        "unsupported store",
+			modify:      func(cfg *Config) { cfg.Store = "cassandra" },
+			expectedErr: "unsupported store 'cassandra'",
+		},
+		{
+			name:        "negative memory store entries",
+			modify:      func(cfg *Config) { cfg.Store = storeMemory; cfg.MemoryStore.MaxEntries = -1 },
+			expectedErr: "memory_store max_entries must be non-negative",
+		},
+		{
+			name:        "local cache with memory store",
+			modify:      func(cfg *Config) { cfg.Store = storeMemory; cfg.LocalCacheSize = 100 },
+			expectedErr: "local_cache_size is not used with the memory store",
+		},
+		{
+			name:        "file store without path",
+			modify:      func(cfg *Config) { cfg.Store = storeFile },
+			expectedErr: "file_store path is required",
+		},
+		{
+			name:        "local cache with file store",
+			modify:      func(cfg *Config) { cfg.Store = storeFile; cfg.FileStore.Path = "tokens.db"; cfg.LocalCacheSize = 100 },
+			expectedErr: "local_cache_size is not used with the file store",
+		},
+		{
+			name:        "watchlist with memory store",
+			modify:      func(cfg *Config) { cfg.Store = storeMemory; cfg.Watchlist.Enabled = true },
+			expectedErr: "watchlist requires the redis store",
+		},
+		{
+			name:        "negative match filter length",
+			modify:      func(cfg *Config) { cfg.MatchFilter.MinLength = -1 },
+			expectedErr: "match_filter min_length must be non-negative",
+		},
+		{
+			name:        "negative short circuit categories",
+			modify:      func(cfg *Config) { cfg.ShortCircuit.AbsentCategories = -1 },
+			expectedErr: "short_circuit absent_categories must be non-negative",
+		},
+		{
+			name:        "memcached store without servers",
+			modify:      func(cfg *Config) { cfg.Store = storeMemcached },
+			expectedErr: "memcached servers are required",
+		},
+		{
+			name: "memcached store with access tracking",
+			modify: func(cfg *Config) {
+				cfg.Store = storeMemcached
+				cfg.Memcached.Servers = []string{"localhost:11211"}
+				cfg.TrackAccess = true
+			},
+			expectedErr: "track_access and warmup_top_n require the redis store",
+		},
+		{
+			name:        "dynamodb store without table",
+			modify:      func(cfg *Config) { cfg.Store = storeDynamoDB },
+			expectedErr: "dynamodb table is required",
+		},
+		{
+			name:        "sql store without driver",
+			modify:      func(cfg *Config) { cfg.Store = storeSQL },
+			expectedErr: "sql_store driver is required",
+		},
+		{
+			name: "sql store with unsafe table",
+			modify: func(cfg *Config) {
+				cfg.Store = storeSQL
+				cfg.SQLStore.Driver = "postgres"
+				cfg.SQLStore.DSN = "postgres://localhost/tokens"
+				cfg.SQLStore.Table = "tokens; DROP TABLE users"
+			},
+			expectedErr: "sql_store table 'tokens; DROP TABLE users' must be a plain identifier of at most 48 characters",
+		},
+		{
+			name: "firestore store without collection",
+			modify: func(cfg *Config) {
+				cfg.Store = storeFirestore
+				cfg.Firestore.Collection = ""
+			},
+			expectedErr: "firestore collection is required",
+		},
+		{
+			name: "firestore store with watchlist",
+			modify: func(cfg *Config) {
+				cfg.Store = storeFirestore
+				cfg.Watchlist.Enabled = true
+			},
+			expectedErr: "watchlist requires the redis store",
+		},
+		{
+			name:        "etcd store without endpoints",
+			modify:      func(cfg *Config) { cfg.Store = storeEtcd },
+			expectedErr: "etcd endpoints are required",
+		},
+		{
+			name:        "negative warmup",
+			modify:      func(cfg *Config) { cfg.WarmupTopN = -1 },
+			expectedErr: "warmup_top_n must be non-negative",
+		},
+		{
+			name:        "warmup without local cache",
+			modify:      func(cfg *Config) { cfg.WarmupTopN = 100 },
+			expectedErr: "warmup_top_n requires local_cache_size",
+		},
+		{
+			name: "valid warmup",
+			modify: func(cfg *Config) {
+				cfg.LocalCacheSize = 1000
+				cfg.WarmupTopN = 100
+			},
+		},
+		{
+			name: "write-behind without workers",
+			modify: func(cfg *Config) {
+				cfg.HMACKey = "secret"
+				cfg.WriteBehind = WriteBehindConfig{Enabled: true, QueueSize: 100}
+			},
+			expectedErr: "write_behind workers must be positive",
+		},
+		{
+			name:        "write-behind without hmac key",
+			modify:      func(cfg *Config) { cfg.WriteBehind.Enabled = true },
+			expectedErr: "hmac_key is required with write_behind",
+		},
+		{
+			name: "write-behind in lightweight mode",
+			modify: func(cfg *Config) {
+				cfg.HMACKey = "secret"
+				cfg.Mode = modeLightweight
+				cfg.WriteBehind.Enabled = true
+			},
+			expectedErr: "write_behind is not used in lightweight mode",
+		},
+		{
+			name: "write-behind with fake tokens",
+			modify: func(cfg *Config) {
+				cfg.HMACKey = "secret"
+				cfg.WriteBehind.Enabled = true
+				cfg.Patterns = []PatternConfig{{Name: "person", Regex: `[A-Z][a-z]+ [A-Z][a-z]+`, TokenFormat: tokenFormatFake}}
+			},
+			expectedErr: "the fake token format is not used with write_behind",
+		},
+		{
+			name: "valid write-behind",
+			modify: func(cfg *Config) {
+				cfg.HMACKey = "secret"
+				cfg.WriteBehind.Enabled = true
+			},
+		},
+		{
+			name:        "invalid token namespace",
+			modify:      func(cfg *Config) { cfg.TokenNamespace = "vendor:x" },
+			expectedErr: "token_namespace must not contain ':' or '/'",
+		},
+		{
+			name:        "relative lookup url",
+			modify:      func(cfg *Config) { cfg.EnrichWithLookupURL = "/unmask" },
+			expectedErr: "enrich_with_lookup_url must be an absolute http or https URL",
+		},
+		{
+			name:   "valid lookup url",
+			modify: func(cfg *Config) { cfg.EnrichWithLookupURL = "https://unmask.example.com" },
+		},
+		{
+			name: "discovery without window",
+			modify: func(cfg *Config) {
+				cfg.Discovery = DiscoveryConfig{Enabled: true}
+			},
+			expectedErr: "discovery window must be positive",
+		},
+		{
+			name:   "valid discovery",
+			modify: func(cfg *Config) { cfg.Discovery.Enabled = true },
+		},
+		{
+			name: "provenance without attribute",
+			modify: func(cfg *Config) {
+				cfg.Provenance = ProvenanceConfig{Policy: "pci-v3"}
+			},
+			expectedErr: "provenance attribute is required",
+		},
+		{
+			name:   "valid provenance",
+			modify: func(cfg *Config) { cfg.Provenance.Policy = "pci-v3" },
+		},
+		{
+			name: "opa without query",
+			modify: func(cfg *Config) {
+				cfg.OPA = OPAConfig{PolicyFile: "policy.rego"}
+			},
+			expectedErr: "opa query is required",
+		},
+		{
+			name:        "replication without topic",
+			modify:      func(cfg *Config) { cfg.Replication.Brokers = []string{"localhost:9092"} },
+			expectedErr: "replication topic is required",
+		},
+		{
+			name: "replication with invalid key",
+			modify: func(cfg *Config) {
+				cfg.Replication = ReplicationConfig{Brokers: []string{"localhost:9092"}, Topic: "mappings", EncryptionKey: "c2hvcnQ="}
+			},
+			expectedErr: "replication encryption_key must decode to 16, 24, or 32 bytes",
+		},
+		{
+			name: "valid replication",
+			modify: func(cfg *Config) {
+				cfg.Replication = ReplicationConfig{Brokers: []string{"localhost:9092"}, Topic: "mappings", EncryptionKey: "MDEyMzQ1Njc4OWFiY2RlZg=="}
+			},
+		},
+		{
+			name:        "unsupported mode",
+			modify:      func(cfg *Config) { cfg.Mode = "turbo" },
+			expectedErr: "unsupported mode 'turbo'",
+		},
+		{
+			name:        "lightweight without hmac key",
+			modify:      func(cfg *Config) { cfg.Mode = modeLightweight },
+			expectedErr: "hmac_key is required in lightweight mode",
+		},
+		{
+			name: "lightweight with too many patterns",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeLightweight
+				cfg.HMACKey = "secret"
+				cfg.Patterns = make([]PatternConfig, lightweightMaxPatterns+1)
+			},
+			expectedErr: "lightweight mode supports at most 8 patterns",
+		},
+		{
+			name: "lightweight with allow salt change",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeLightweight
+				cfg.HMACKey = "secret"
+				cfg.Salt = "customer-a"
+				cfg.AllowSaltChange = true
+			},
+			expectedErr: "allow_salt_change is not used in lightweight mode",
+		},
+		{
+			name: "valid lightweight",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeLightweight
+				cfg.HMACKey = "secret"
+			},
+		},
+		{
+			name:        "active active without hmac key",
+			modify:      func(cfg *Config) { cfg.Mode = modeActiveActive },
+			expectedErr: "hmac_key is required in active_active mode",
+		},
+		{
+			name: "active active with fake tokens",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeActiveActive
+				cfg.HMACKey = "secret"
+				cfg.TokenFormat = tokenFormatFake
+			},
+			expectedErr: "the fake token format is not used in active_active mode",
+		},
+		{
+			name: "valid active active",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeActiveActive
+				cfg.HMACKey = "secret"
+			},
+		},
+		{
+			name: "both hmac key sources",
+			modify: func(cfg *Config) {
+				cfg.HMACKey = "secret"
+				cfg.HMACKeyFile = "/run/secrets/hmac-key"
+			},
+			expectedErr: "hmac_key and hmac_key_file are mutually exclusive",
+		},
+		{
+			name: "active active with hmac key file",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeActiveActive
+				cfg.HMACKeyFile = "/run/secrets/hmac-key"
+			},
+		},
+		{
+			name:        "read only without hmac key",
+			modify:      func(cfg *Config) { cfg.Mode = modeReadOnly },
+			expectedErr: "hmac_key is required with the read_only hash fallback",
+		},
+		{
+			name: "read only with unsupported fallback",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeReadOnly
+				cfg.ReadOnly.Fallback = "keep"
+			},
+			expectedErr: "unsupported read_only fallback 'keep'",
+		},
+		{
+			name: "read only with replication",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeReadOnly
+				cfg.ReadOnly.Fallback = readOnlyFallbackDrop
+				cfg.Replication = ReplicationConfig{Brokers: []string{"localhost:9092"}, Topic: "mappings", EncryptionKey: "MDEyMzQ1Njc4OWFiY2RlZg=="}
+			},
+			expectedErr: "replication is not used in read_only mode",
+		},
+		{
+			name: "read only with access tracking",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeReadOnly
+				cfg.ReadOnly.Fallback = readOnlyFallbackDrop
+				cfg.TrackAccess = true
+			},
+			expectedErr: "track_access is not used in read_only mode",
+		},
+		{
+			name: "read only with salt change",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeReadOnly
+				cfg.ReadOnly.Fallback = readOnlyFallbackDrop
+				cfg.AllowSaltChange = true
+			},
+			expectedErr: "allow_salt_change is not used in read_only mode",
+		},
+		{
+			name: "valid read only drop",
+			modify: func(cfg *Config) {
+				cfg.Mode = modeReadOnly
+				cfg.ReadOnly.Fallback = readOnlyFallbackDrop
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			tc.modify(&cfg)
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestAccessTrackingEnabled(t *testing.T) {
+	cfg := NewDefaultConfig()
+	require.False(t, cfg.AccessTrackingEnabled())
+
+	cfg.TrackAccess = true
+	require.True(t, cfg.AccessTrackingEnabled())
+
+	cfg.TrackAccess = false
+	cfg.WarmupTopN = 10
+	require.True(t, cfg.AccessTrackingEnabled())
+
+	// Nothing is tracked without a store
+	cfg.Mode = modeLightweight
+	require.False(t, cfg.AccessTrackingEnabled())
+}
+
+func TestSemconvFields(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.FieldsToMask = []string{"username", "user.email"}
+	require.Empty(t, cfg.SemconvFields())
+	require.Equal(t, []string{"username", "user.email"}, cfg.effectiveFieldsToMask())
+
+	// Fields that are configured explicitly are not reported as auto-covered
+	cfg.SemconvFieldsEnabled = true
+	require.Contains(t, cfg.SemconvFields(), "enduser.id")
+	require.NotContains(t, cfg.SemconvFields(), "user.email")
+	require.Equal(t, append([]string{"username", "user.email"}, cfg.SemconvFields()...), cfg.effectiveFieldsToMask())
+}
+
+func TestEffectivePatterns(t *testing.T) {
+	cfg := NewDefaultConfig()
+	require.Equal(t, DefaultPatterns(), cfg.effectivePatterns())
+	require.Equal(t, 0, cfg.effectiveMaxScanBytes())
+
+	cfg.Mode = modeLightweight
+	require.Equal(t, lightweightPatterns(), cfg.effectivePatterns())
+	require.Equal(t, lightweightMaxScanBytes, cfg.effectiveMaxScanBytes())
+
+	// Custom patterns are kept as configured
+	custom := []PatternConfig{{Name: "email", Regex: `\S+@\S+`}}
+	cfg.Patterns = custom
+	require.Equal(t, custom, cfg.effectivePatterns())
+}
@@ -0,0 +1,110 @@
+package masker
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// cookieAttributes are the Set-Cookie attribute names, which are kept with their values
+var cookieAttributes = []string{
+	"domain",
+	"expires",
+	"httponly",
+	"max-age",
+	"partitioned",
+	"path",
+	"priority",
+	"samesite",
+	"secure",
+}
+
+// cookieHeaderRegex matches Cookie and Set-Cookie header lines in captured
+// requests and responses. The second group is the header value.
+var cookieHeaderRegex = regexp.MustCompile(`(?im)^((?:set-)?cookie:[ \t]*)([^\r\n]*)`)
+
+// maskCookies masks the cookie values in text. Text holding Cookie or
+// Set-Cookie header lines, e.g. a captured request, has the values on those
+// lines masked and the remaining lines pattern-scanned. Any other text is
+// handled as the value of a single header.
+func (m *Masker) maskCookies(ctx context.Context, text string, names []string, onMask func(category, token string)) string {
+	matches := cookieHeaderRegex.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		if masked, ok := m.maskCookieHeader(ctx, text, names, onMask); ok {
+			return masked
+		}
+		return m.maskString(ctx, text, onMask)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		valueStart, valueEnd := match[4], match[5]
+		b.WriteString(m.maskString(ctx, text[last:valueStart], onMask))
+
+		header := text[valueStart:valueEnd]
+		if masked, ok := m.maskCookieHeader(ctx, header, names, onMask); ok {
+			b.WriteString(masked)
+		} else {
+			b.WriteString(m.maskString(ctx, header, onMask))
+		}
+		last = valueEnd
+	}
+	b.WriteString(m.maskString(ctx, text[last:], onMask))
+	return b.String()
+}
+
+// maskCookieHeader masks the cookie values of a Cookie or Set-Cookie header value.
+// Cookie names, Set-Cookie attributes such as Path and Expires, and flags such as
+// HttpOnly are kept. It reports false when the value holds no cookie.
+func (m *Masker) maskCookieHeader(ctx context.Context, header string, names []string, onMask func(category, token string)) (string, bool) {
+	parts := strings.Split(header, ";")
+	parsed := false
+	for i, part := range parts {
+		key, value, ok := strings.Cut(part, "=")
+		name := strings.TrimSpace(key)
+		if !ok || name == "" || slices.Contains(cookieAttributes, strings.ToLower(name)) {
+			continue
+		}
+		parsed = true
+
+		// Quoted values keep their quotes around the token
+		value = strings.TrimSpace(value)
+		quoted := len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`)
+		if quoted {
+			value = value[1 : len(value)-1]
+		}
+		if value == "" || (len(names) > 0 && !slices.Contains(names, name)) {
+			continue
+		}
+
+		token := m.cookieToken(ctx, name, value, onMask)
+		if quoted {
+			token = `"` + token + `"`
+		}
+		parts[i] = key + "=" + token
+	}
+	return strings.Join(parts, ";"), parsed
+}
+
+// cookieToken returns the token of a cookie value
+func (m *Masker) cookieToken(ctx context.Context, name, value string, onMask func(category, token string)) string {
+	category := cookieCategory(name)
+	token, err := m.MaskValue(ctx, value, category)
+	if err != nil {
+		m.logError("Failed to mask cookie", err, zap.String("cookie", name))
+		return value
+	}
+	if onMask != nil {
+		onMask(category, token)
+	}
+	return token
+}
+
+// cookieCategory returns the category used for a masked cookie value
+func cookieCategory(name string) string {
+	return "cookie_" + name
+}
@@ -0,0 +1,64 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestCookieStructuredFields(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.StructuredFields = []StructuredFieldConfig{
+		{
+			Strategy: strategyCookie,
+			Keys:     []string{"http.request.header.cookie", "http.response.header.set-cookie", bodyKey},
+		},
+	}
+	m, _ := newTestMasker(t, &cfg)
+	token := func(name, value string) string {
+		return m.generateMaskedValue(value, cookieCategory(name))
+	}
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("http.request.header.cookie", `session=abc123; theme="dark"`)
+	setCookies := lr.Attributes().PutEmptySlice("http.response.header.set-cookie")
+	setCookies.AppendEmpty().SetStr("session=xyz789; Path=/; Expires=Wed, 21 Oct 2026 07:28:00 GMT; Secure; HttpOnly")
+	setCookies.AppendEmpty().SetStr("csrf=tok; SameSite=Strict")
+	lr.Body().SetStr("GET / HTTP/1.1\r\nHost: 192.168.1.1\r\nCookie: session=abc123\r\n")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// Names and quotes are kept
+	cookie, _ := lr.Attributes().Get("http.request.header.cookie")
+	assert.Equal(t, `session=`+token("session", "abc123")+`; theme="`+token("theme", "dark")+`"`, cookie.Str())
+
+	// Attributes and flags are kept for every header in a slice
+	setCookie, _ := lr.Attributes().Get("http.response.header.set-cookie")
+	assert.Equal(t, "session="+token("session", "xyz789")+"; Path=/; Expires=Wed, 21 Oct 2026 07:28:00 GMT; Secure; HttpOnly", setCookie.Slice().At(0).Str())
+	assert.Equal(t, "csrf="+token("csrf", "tok")+"; SameSite=Strict", setCookie.Slice().At(1).Str())
+
+	// Header lines in bodies are masked and the rest is pattern-scanned
+	assert.Equal(t, "GET / HTTP/1.1\r\nHost: "+m.generateMaskedValue("192.168.1.1", "ipv4")+"\r\nCookie: session="+token("session", "abc123")+"\r\n", lr.Body().Str())
+}
+
+func TestCookieNames(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.StructuredFields = []StructuredFieldConfig{
+		{Strategy: strategyCookie, Keys: []string{"http.request.header.cookie"}, Members: []string{"session"}},
+	}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("http.request.header.cookie", "session=abc123; theme=dark")
+
+	m.MaskLogs(context.Background(), ld)
+
+	cookie, _ := lr.Attributes().Get("http.request.header.cookie")
+	assert.Equal(t, "session="+m.generateMaskedValue("abc123", cookieCategory("session"))+"; theme=dark", cookie.Str())
+}
@@ -0,0 +1,210 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MappingCreator is implemented by stores that create mappings atomically
+type MappingCreator interface {
+	// CreateMapping stores token under maskKey and original under unmaskKey,
+	// unless maskKey already holds a token. It returns the token held by maskKey
+	// and whether this call created the mapping, or errTokenTaken when unmaskKey
+	// holds another value. Stores that cannot create mappings atomically return
+	// errors.ErrUnsupported.
+	CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error)
+}
+
+var _ MappingCreator = (*redisStore)(nil)
+
+// errTokenTaken is returned for new mappings whose reverse mapping holds
+// another value, so their token is derived again
+var errTokenTaken = errors.New("token holds another value")
+
+// maxCollisionRetries caps how often the token of a new mapping is derived
+// again because its reverse mapping holds another value
+const maxCollisionRetries = 32
+
+// createMappingScript sets both directions of a mapping unless the mask key
+// exists, so collectors creating the same mapping at once never write
+// divergent reverse mappings. It returns the token that won, or -1 when the
+// unmask key holds another value.
+var createMappingScript = redis.NewScript(`
+local token = redis.call('GET', KEYS[1])
+if token then
+	return {0, token}
+end
+local holder = redis.call('GET', KEYS[2])
+if holder and holder ~= ARGV[2] then
+	return {-1, holder}
+end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ttl)
+	redis.call('SET', KEYS[2], ARGV[2], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[1])
+	redis.call('SET', KEYS[2], ARGV[2])
+end
+return {1, ARGV[1]}
+`)
+
+// CreateMapping creates both directions of a mapping in one script. Cluster
+// deployments, e.g. ElastiCache Serverless or MemoryDB, reject the script since
+// the two keys hash to different slots, and fall back to createMappingNX.
+func (s *redisStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	if s.crossSlot.Load() {
+		return s.createMappingNX(ctx, maskKey, unmaskKey, original, token, ttl)
+	}
+
+	result, err := createMappingScript.Run(ctx, s.client, []string{maskKey, unmaskKey}, token, original, ttl.Milliseconds()).Slice()
+	if err != nil && strings.HasPrefix(err.Error(), "CROSSSLOT") {
+		s.crossSlot.Store(true)
+		return s.createMappingNX(ctx, maskKey, unmaskKey, original, token, ttl)
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("redis create mapping error: %w", err)
+	}
+	created, _ := result[0].(int64)
+	if created == -1 {
+		return "", false, errTokenTaken
+	}
+	winner, _ := result[1].(string)
+	return winner, created == 1, nil
+}
+
+// createMappingNX claims the mask key with SET NX and then the unmask key,
+// releasing the mask key again when the unmask key holds another value. The
+// first writer still wins, but a collector failing between the writes leaves
+// a token that cannot be reversed.
+func (s *redisStore) createMappingNX(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	claimed, err := s.client.SetNX(ctx, maskKey, token, ttl).Result()
+	if err != nil {
+		return "", false, fmt.Errorf("redis setnx error: %w", err)
+	}
+	if !claimed {
+		winner, err := s.client.Get(ctx, maskKey).Result()
+		if err != nil {
+			return "", false, fmt.Errorf("redis get error: %w", err)
+		}
+		return winner, false, nil
+	}
+
+	claimed, err = s.client.SetNX(ctx, unmaskKey, original, ttl).Result()
+	if err != nil {
+		return "", false, fmt.Errorf("redis setnx error: %w", err)
+	}
+	if !claimed {
+		holder, err := s.client.Get(ctx, unmaskKey).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return "", false, fmt.Errorf("redis get error: %w", err)
+		}
+		if holder != original {
+			if err := s.client.Del(ctx, maskKey).Err(); err != nil {
+				return "", false, fmt.Errorf("redis del error: %w", err)
+			}
+			return "", false, errTokenTaken
+		}
+		// A leftover reverse mapping of the same value gets the new TTL
+		if err := s.client.Set(ctx, unmaskKey, original, ttl).Err(); err != nil {
+			return "", false, fmt.Errorf("redis set error: %w", err)
+		}
+	}
+	return token, true, nil
+}
+
+// CreateMapping creates the mapping in the next store and caches the token that won
+func (s *cachedStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	creator, ok := s.next.(MappingCreator)
+	if !ok {
+		return "", false, errors.ErrUnsupported
+	}
+	maskGeneration, unmaskGeneration := s.begin(maskKey), s.begin(unmaskKey)
+	winner, created, err := creator.CreateMapping(ctx, maskKey, unmaskKey, original, token, ttl)
+	s.finish(maskKey, winner, maskGeneration, err == nil)
+	s.finish(unmaskKey, original, unmaskGeneration, err == nil && created)
+	if err != nil {
+		return "", false, err
+	}
+	return winner, created, nil
+}
+
+// freeToken derives the token of a new mapping of originalValue. Some token
+// formats draw from spaces small enough for distinct values to collide, e.g.
+// fake names, so a token whose reverse mapping already holds another value is
+// derived again with a counter suffix instead of overwriting it, which would
+// unmask the token to the wrong value. Stores refuse tokens taken after the
+// check with errTokenTaken.
+func (m *Masker) freeToken(ctx context.Context, originalValue, category string) (string, error) {
+	storeCategory := m.namespaced(category)
+	previous := ""
+	for attempt := 0; attempt <= maxCollisionRetries; attempt++ {
+		token := m.generateAttempt(originalValue, category, attempt)
+		if token == previous {
+			// Formats that ignore the attempt, e.g. fpe, never collide
+			return token, nil
+		}
+		holder, found, err := m.store.Get(ctx, UnmaskKey(storeCategory, token))
+		if err != nil {
+			return "", err
+		}
+		if !found || holder == originalValue {
+			return token, nil
+		}
+		previous = token
+	}
+	return "", errCollisionsExhausted(storeCategory)
+}
+
+// errCollisionsExhausted is returned for values whose every derived token
+// holds another value
+func errCollisionsExhausted(category string) error {
+	return fmt.Errorf("every token derived for a value of category '%s' holds another value", category)
+}
+
+// createMapping stores a new mapping and returns the token of the value. When
+// another collector created a mapping of the value first, its token is
+// returned instead of maskedValue, and errTokenTaken when the reverse mapping
+// of maskedValue holds another value. Stores without atomic creation fall back
+// to writing both directions, where the last writer wins.
+func (m *Masker) createMapping(ctx context.Context, originalValue, category, maskedValue string) (string, error) {
+	creator, ok := m.store.(MappingCreator)
+	if !ok {
+		return m.writeMapping(ctx, originalValue, category, maskedValue)
+	}
+
+	ttl := m.mappingTTL()
+	winner, created, err := creator.CreateMapping(ctx, MaskKey(category, originalValue), UnmaskKey(category, maskedValue), originalValue, maskedValue, ttl)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		return m.writeMapping(ctx, originalValue, category, maskedValue)
+	case errors.Is(err, errTokenTaken):
+		return "", err
+	case err != nil:
+		m.logError("Failed to store masked value", err)
+		m.recordFallback(ctx, fallbackMappingNotStored, err)
+		// Continue anyway, we'll use the generated value
+		return maskedValue, nil
+	case !created:
+		return winner, nil
+	}
+
+	m.publishMapping(ctx, originalValue, category, maskedValue, ttl)
+	m.telemetry.recordTokenCreated(ctx)
+	return maskedValue, nil
+}
+
+// writeMapping writes both directions of a new mapping to a store without
+// atomic creation
+func (m *Masker) writeMapping(ctx context.Context, originalValue, category, maskedValue string) (string, error) {
+	if err := m.storeMapping(ctx, originalValue, category, maskedValue); err != nil {
+		return "", err
+	}
+	m.telemetry.recordTokenCreated(ctx)
+	return maskedValue, nil
+}
@@ -0,0 +1,213 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCreateMapping(t *testing.T) {
+	cfg := NewDefaultConfig()
+	_, server := newTestMasker(t, &cfg)
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	creator := store.(MappingCreator)
+	ctx := context.Background()
+
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+	winner, created, err := creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "10.1.2.3", winner)
+	assert.Equal(t, time.Hour, server.TTL(maskKey))
+	assert.Equal(t, time.Hour, server.TTL(UnmaskKey("ipv4", "10.1.2.3")))
+
+	// The first writer wins and the loser's reverse mapping is never written
+	winner, created, err = creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", 0)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "10.1.2.3", winner)
+	assert.False(t, server.Exists(UnmaskKey("ipv4", "10.4.5.6")))
+
+	// A token held by another value is refused
+	_, _, err = creator.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", 0)
+	require.ErrorIs(t, err, errTokenTaken)
+	assert.False(t, server.Exists(MaskKey("ipv4", "192.168.1.9")))
+
+	// Mappings without a TTL are kept forever
+	_, created, err = creator.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.2"), UnmaskKey("ipv4", "10.7.8.9"), "192.168.1.2", "10.7.8.9", 0)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Zero(t, server.TTL(MaskKey("ipv4", "192.168.1.2")))
+}
+
+// crossSlotHook rejects scripts like a Redis cluster whose keys hash to
+// different slots
+type crossSlotHook struct{}
+
+func (crossSlotHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (crossSlotHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if name := cmd.Name(); name == "eval" || name == "evalsha" {
+			cmd.SetErr(errors.New("CROSSSLOT Keys in request don't hash to the same slot"))
+			return cmd.Err()
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (crossSlotHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestCreateMappingCrossSlot(t *testing.T) {
+	cfg := NewDefaultConfig()
+	_, server := newTestMasker(t, &cfg)
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	store.(*redisStore).client.AddHook(crossSlotHook{})
+	creator := store.(MappingCreator)
+	ctx := context.Background()
+
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+	winner, created, err := creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "10.1.2.3", winner)
+	assert.True(t, store.(*redisStore).crossSlot.Load())
+	assert.Equal(t, time.Hour, server.TTL(maskKey))
+	original, err := server.Get(UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", original)
+	assert.Equal(t, time.Hour, server.TTL(UnmaskKey("ipv4", "10.1.2.3")))
+
+	// The first writer still wins
+	winner, created, err = creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", 0)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, "10.1.2.3", winner)
+	assert.False(t, server.Exists(UnmaskKey("ipv4", "10.4.5.6")))
+
+	// A token held by another value releases the claimed mask key
+	_, _, err = creator.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", 0)
+	require.ErrorIs(t, err, errTokenTaken)
+	assert.False(t, server.Exists(MaskKey("ipv4", "192.168.1.9")))
+	original, err = server.Get(UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", original)
+}
+
+// racingStore hides existing mappings from lookups, like a collector that
+// missed a mapping created at the same time by another one
+type racingStore struct {
+	Store
+}
+
+func (s *racingStore) Get(context.Context, string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (s *racingStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	return s.Store.(MappingCreator).CreateMapping(ctx, maskKey, unmaskKey, original, token, ttl)
+}
+
+func TestMaskValueFirstWriterWins(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.HMACKey = "first"
+	first, server := newTestMasker(t, &cfg)
+	token, err := first.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+
+	// A collector deriving another token adopts the one created first
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	secondCfg := cfg
+	secondCfg.HMACKey = "second"
+	second, err := New(&secondCfg, NewCachedStore(&racingStore{store}, 10, 0), zap.NewNop())
+	require.NoError(t, err)
+	require.NotEqual(t, token, second.generateMaskedValue("192.168.1.1", "ipv4"))
+
+	secondToken, err := second.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, token, secondToken)
+	assert.False(t, server.Exists(UnmaskKey("ipv4", second.generateMaskedValue("192.168.1.1", "ipv4"))))
+}
+
+// collidingStore reports every reverse mapping as held by another value
+type collidingStore struct {
+	Store
+}
+
+func (s *collidingStore) Get(ctx context.Context, key string) (string, bool, error) {
+	if strings.HasPrefix(key, "unmask:") {
+		return "another value", true, nil
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func TestMaskValueCollisionsExhausted(t *testing.T) {
+	cfg := NewDefaultConfig()
+	_, server := newTestMasker(t, &cfg)
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	m, err := New(&cfg, &collidingStore{store}, zap.NewNop())
+	require.NoError(t, err)
+
+	// Values are left to the fallback rather than overwriting another mapping
+	_, err = m.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.EqualError(t, err, "every token derived for a value of category 'ipv4' holds another value")
+	assert.False(t, server.Exists(MaskKey("ipv4", "192.168.1.1")))
+}
+
+// staleStore misses the first lookup of a reverse mapping, like a collector
+// checking a token just before another one creates a mapping with it
+type staleStore struct {
+	Store
+	missed bool
+}
+
+func (s *staleStore) Get(ctx context.Context, key string) (string, bool, error) {
+	if strings.HasPrefix(key, "unmask:") && !s.missed {
+		s.missed = true
+		return "", false, nil
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func (s *staleStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	return s.Store.(MappingCreator).CreateMapping(ctx, maskKey, unmaskKey, original, token, ttl)
+}
+
+func TestMaskValueTokenTakenConcurrently(t *testing.T) {
+	cfg := NewDefaultConfig()
+	_, server := newTestMasker(t, &cfg)
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	m, err := New(&cfg, &staleStore{Store: store}, zap.NewNop())
+	require.NoError(t, err)
+
+	// The mapping is refused and created with the next token instead
+	taken := m.generateMaskedValue("192.168.1.1", "ipv4")
+	server.Set(UnmaskKey("ipv4", taken), "192.168.1.9")
+	token, err := m.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, m.generateAttempt("192.168.1.1", "ipv4", 1), token)
+	original, err := server.Get(UnmaskKey("ipv4", taken))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.9", original)
+}
@@ -0,0 +1,105 @@
+package masker
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// degradationAttribute receives the last applied degradation step on records
+// masked while the latency budget is exceeded
+const degradationAttribute = "masking.degradation"
+
+// detectedAttribute flags records that hold sensitive data in detect_only mode,
+// whether configured or applied as a degradation step
+const detectedAttribute = "masking.detected"
+
+// degradation tracks batch latencies against the budget and applies the
+// configured steps one at a time. A step is applied after DegradeAfter
+// consecutive batches over budget and reverted after RecoverAfter consecutive
+// batches under half of it, so a single slow batch never changes behavior.
+type degradation struct {
+	cfg    *LatencyBudgetConfig
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	over  int
+	under int
+
+	// level is the number of applied steps, read without the lock on the hot path
+	level atomic.Int32
+}
+
+func newDegradation(cfg *LatencyBudgetConfig, logger *zap.Logger) *degradation {
+	return &degradation{
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// observe records the processing time of a batch and applies or reverts a step
+func (d *degradation) observe(elapsed time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	level := int(d.level.Load())
+	switch {
+	case elapsed > d.cfg.Budget:
+		d.under = 0
+		d.over++
+		if d.over >= d.cfg.DegradeAfter && level < len(d.cfg.Steps) {
+			d.over = 0
+			d.level.Store(int32(level + 1))
+			d.logger.Warn("Latency budget exceeded, degrading masking",
+				zap.String("step", d.cfg.Steps[level]),
+				zap.Duration("elapsed", elapsed),
+				zap.Duration("budget", d.cfg.Budget))
+		}
+	case elapsed < d.cfg.Budget/2:
+		d.over = 0
+		d.under++
+		if d.under >= d.cfg.RecoverAfter && level > 0 {
+			d.under = 0
+			d.level.Store(int32(level - 1))
+			d.logger.Info("Latency headroom returned, reverting degradation step",
+				zap.String("step", d.cfg.Steps[level-1]),
+				zap.Duration("elapsed", elapsed),
+				zap.Duration("budget", d.cfg.Budget))
+		}
+	default:
+		d.over = 0
+		d.under = 0
+	}
+}
+
+// active reports whether step is currently applied. A nil degradation applies no steps.
+func (d *degradation) active(step string) bool {
+	if d == nil {
+		return false
+	}
+	return slices.Contains(d.cfg.Steps[:d.level.Load()], step)
+}
+
+// current returns the last applied step, or "" when masking is not degraded
+func (d *degradation) current() string {
+	if d == nil {
+		return ""
+	}
+	if level := d.level.Load(); level > 0 {
+		return d.cfg.Steps[level-1]
+	}
+	return ""
+}
+
+// observeSince records the processing time of a batch started at start and
+// summarizes the masking errors of ended intervals
+func (m *Masker) observeSince(start time.Time) {
+	if m.degradation != nil {
+		m.degradation.observe(time.Since(start))
+	}
+	m.errLog.flush()
+	m.reportDistinct()
+}
@@ -0,0 +1,117 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+func TestDegradationSteps(t *testing.T) {
+	cfg := NewDefaultConfig().LatencyBudget
+	cfg.Budget = 100 * time.Millisecond
+	cfg.DegradeAfter = 2
+	cfg.RecoverAfter = 3
+	d := newDegradation(&cfg, zap.NewNop())
+
+	observe := func(elapsed time.Duration, n int) {
+		for i := 0; i < n; i++ {
+			d.observe(elapsed)
+		}
+	}
+
+	// A single slow batch does not degrade
+	observe(time.Second, 1)
+	observe(80*time.Millisecond, 1)
+	observe(time.Second, 1)
+	assert.Equal(t, "", d.current())
+
+	// Persistent slowness applies the steps in order
+	observe(time.Second, 2)
+	assert.Equal(t, stepDisableLowPriority, d.current())
+	observe(time.Second, 2)
+	assert.Equal(t, stepDeterministic, d.current())
+	assert.True(t, d.active(stepDisableLowPriority))
+	assert.False(t, d.active(stepDetectOnly))
+	observe(time.Second, 4)
+	assert.Equal(t, stepDetectOnly, d.current())
+
+	// Headroom reverts one step at a time
+	observe(10*time.Millisecond, 3)
+	assert.Equal(t, stepDeterministic, d.current())
+	observe(10*time.Millisecond, 6)
+	assert.Equal(t, "", d.current())
+	assert.False(t, d.active(stepDisableLowPriority))
+}
+
+func TestNilDegradation(t *testing.T) {
+	var d *degradation
+	assert.False(t, d.active(stepDetectOnly))
+	assert.Equal(t, "", d.current())
+}
+
+func TestMaskLogsDegraded(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{
+		{Name: "ipv4", Regex: `\b(?:\d{1,3}\.){3}\d{1,3}\b`},
+		{Name: "ticket", Regex: `\bTCK-\d+\b`, Priority: priorityLow},
+	}
+	cfg.LatencyBudget.Budget = time.Hour
+	cfg.LatencyBudget.DegradeAfter = 1
+	m, server := newTestMasker(t, &cfg)
+
+	mask := func(body string) plog.LogRecord {
+		ld := plog.NewLogs()
+		lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		lr.Body().SetStr(body)
+		m.MaskLogs(context.Background(), ld)
+		return lr
+	}
+
+	// Low priority patterns are skipped first
+	m.degradation.observe(2 * time.Hour)
+	lr := mask("TCK-42 from 192.168.1.1")
+	assert.Equal(t, "TCK-42 from "+m.generateMaskedValue("192.168.1.1", "ipv4"), lr.Body().Str())
+	step, _ := lr.Attributes().Get(degradationAttribute)
+	assert.Equal(t, stepDisableLowPriority, step.Str())
+
+	// Deterministic tokens are not written to the store
+	m.degradation.observe(2 * time.Hour)
+	lr = mask("from 192.168.1.2")
+	assert.Equal(t, "from "+m.generateMaskedValue("192.168.1.2", "ipv4"), lr.Body().Str())
+	assert.False(t, server.Exists(MaskKey("ipv4", "192.168.1.2")))
+
+	// Detect only leaves values unchanged and flags them
+	m.degradation.observe(2 * time.Hour)
+	lr = mask("from 192.168.1.3")
+	assert.Equal(t, "from 192.168.1.3", lr.Body().Str())
+	detected, _ := lr.Attributes().Get(detectedAttribute)
+	assert.True(t, detected.Bool())
+	step, _ = lr.Attributes().Get(degradationAttribute)
+	assert.Equal(t, stepDetectOnly, step.Str())
+}
+
+func TestLatencyBudgetValidate(t *testing.T) {
+	cfg := NewDefaultConfig().LatencyBudget
+	require.NoError(t, cfg.Validate())
+
+	cfg.Budget = time.Second
+	require.NoError(t, cfg.Validate())
+
+	cfg.Steps = []string{stepDetectOnly, "sample"}
+	require.EqualError(t, cfg.Validate(), "unsupported latency_budget step 'sample'")
+
+	cfg.Steps = []string{stepDetectOnly, stepDetectOnly}
+	require.EqualError(t, cfg.Validate(), "latency_budget step 'detect_only' is listed more than once")
+
+	cfg.Steps = nil
+	cfg.RecoverAfter = 0
+	require.EqualError(t, cfg.Validate(), "latency_budget degrade_after and recover_after must be positive")
+
+	cfg.Budget = -time.Second
+	require.EqualError(t, cfg.Validate(), "latency_budget budget must be non-negative")
+}
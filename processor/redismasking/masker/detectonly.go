@@ -0,0 +1,47 @@
+package masker
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// DetectOnlyConfig leaves telemetry unmasked and only detects sensitive data,
+// e.g. to measure the exposure of a pipeline before masking is rolled out
+type DetectOnlyConfig struct {
+	// Enabled detects sensitive data without masking it
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+
+	// Annotate flags every log record with whether it holds sensitive data.
+	// Without annotations no data is modified.
+	Annotate bool `mapstructure:"annotate" json:"annotate"`
+}
+
+// MutatesData reports whether the processor may modify the telemetry it is
+// given. Only detect_only without annotations, and without other attributes
+// being added or records being dropped, leaves it unchanged, so the collector
+// can skip defensive copies in fan-out pipelines.
+func (cfg *Config) MutatesData() bool {
+	if !cfg.DetectOnly.Enabled {
+		return true
+	}
+	return cfg.DetectOnly.Annotate || cfg.OPA.Enabled() || cfg.CardholderData.Enabled || cfg.RoutingKeyAttribute != "" || cfg.FingerprintAttribute != ""
+}
+
+// detectOnly reports whether values are left unchanged, either by detect_only
+// or under the detect_only degradation step
+func (m *Masker) detectOnly() bool {
+	return m.config.DetectOnly.Enabled || m.degradation.active(stepDetectOnly)
+}
+
+// detectLogRecord counts lr when it holds sensitive data and flags it when
+// annotations are enabled, leaving its values unchanged
+func (m *Masker) detectLogRecord(ctx context.Context, lr plog.LogRecord) {
+	_, detected := m.routingKey(lr)
+	if detected {
+		m.telemetry.recordDetected(ctx)
+	}
+	if m.config.DetectOnly.Annotate {
+		lr.Attributes().PutBool(detectedAttribute, detected)
+	}
+}
@@ -0,0 +1,93 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestDetectOnly(t *testing.T) {
+	newLogs := func() plog.Logs {
+		ld := plog.NewLogs()
+		records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+		records.AppendEmpty().Body().SetStr("connection from 192.168.1.1")
+		records.AppendEmpty().Attributes().PutStr("user_id", "jdoe")
+		records.AppendEmpty().Body().SetStr("no addresses")
+		return ld
+	}
+
+	t.Run("annotate", func(t *testing.T) {
+		reader := sdkmetric.NewManualReader()
+		cfg := NewDefaultConfig()
+		cfg.Patterns = ipv4Patterns()
+		cfg.FieldsToMask = []string{"user_id"}
+		cfg.DetectOnly.Enabled = true
+		m, err := New(&cfg, nil, zap.NewNop(), WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+		require.NoError(t, err)
+
+		ld := newLogs()
+		m.MaskLogs(context.Background(), ld)
+
+		records := ld.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		assert.Equal(t, "connection from 192.168.1.1", records.At(0).Body().Str())
+		assert.Equal(t, map[string]any{detectedAttribute: true}, records.At(0).Attributes().AsRaw())
+		assert.Equal(t, map[string]any{"user_id": "jdoe", detectedAttribute: true}, records.At(1).Attributes().AsRaw())
+		assert.Equal(t, map[string]any{detectedAttribute: false}, records.At(2).Attributes().AsRaw())
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		require.Len(t, rm.ScopeMetrics, 1)
+		require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+		detected := rm.ScopeMetrics[0].Metrics[0]
+		assert.Equal(t, "redismasking.log.detected", detected.Name)
+		assert.Equal(t, int64(2), detected.Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+	})
+
+	t.Run("read only", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Patterns = ipv4Patterns()
+		cfg.FieldsToMask = []string{"user_id"}
+		cfg.ScanAllAttributes = true
+		cfg.DetectOnly = DetectOnlyConfig{Enabled: true}
+		m, err := New(&cfg, nil, zap.NewNop())
+		require.NoError(t, err)
+
+		ld := newLogs()
+		expectedLogs := plog.NewLogs()
+		ld.CopyTo(expectedLogs)
+		m.MaskLogs(context.Background(), ld)
+		assert.Equal(t, expectedLogs, ld)
+
+		td := ptrace.NewTraces()
+		td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty().Attributes().PutStr("user_id", "jdoe")
+		expectedTraces := ptrace.NewTraces()
+		td.CopyTo(expectedTraces)
+		m.MaskTraces(context.Background(), td)
+		assert.Equal(t, expectedTraces, td)
+	})
+}
+
+func TestMutatesData(t *testing.T) {
+	cfg := NewDefaultConfig()
+	assert.True(t, cfg.MutatesData())
+
+	cfg.DetectOnly.Enabled = true
+	assert.True(t, cfg.MutatesData(), "annotations modify records")
+
+	cfg.DetectOnly.Annotate = false
+	assert.False(t, cfg.MutatesData())
+
+	cfg.CardholderData.Enabled = true
+	assert.True(t, cfg.MutatesData(), "records holding cardholder data are dropped")
+	cfg.CardholderData.Enabled = false
+
+	cfg.RoutingKeyAttribute = "routing_key"
+	assert.True(t, cfg.MutatesData(), "the routing key is added to records")
+}
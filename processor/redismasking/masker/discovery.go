@@ -0,0 +1,110 @@
+package masker
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+// discovery observes which attributes hold values matching the patterns and
+// periodically suggests them for fields_to_mask
+type discovery struct {
+	window time.Duration
+	now    func() time.Time
+	logger *zap.Logger
+
+	mu          sync.Mutex
+	windowStart time.Time
+	// matches counts pattern matches per attribute key and pattern name
+	matches map[string]map[string]int
+}
+
+// newDiscovery creates a discovery whose first window starts now
+func newDiscovery(window time.Duration, logger *zap.Logger) *discovery {
+	d := &discovery{
+		window: window,
+		now:    time.Now,
+		logger: logger,
+	}
+	d.reset(d.now())
+	return d
+}
+
+func (d *discovery) reset(now time.Time) {
+	d.windowStart = now
+	d.matches = map[string]map[string]int{}
+}
+
+// observe records the attributes of lr whose values match a pattern. It must
+// be called before lr is masked. Patterns are matched without holding the lock,
+// so concurrent batches only serialize on merging the matches.
+func (d *discovery) observe(lr plog.LogRecord, fieldsToMask []string, patterns []*compiledPattern) {
+	var found [][2]string
+	lr.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if v.Type() != pcommon.ValueTypeStr || slices.Contains(fieldsToMask, k) {
+			return true
+		}
+		for _, pattern := range patterns {
+			if pattern.matches(v.Str()) {
+				found = append(found, [2]string{k, pattern.name})
+			}
+		}
+		return true
+	})
+
+	d.mu.Lock()
+	for _, match := range found {
+		if d.matches[match[0]] == nil {
+			d.matches[match[0]] = map[string]int{}
+		}
+		d.matches[match[0]][match[1]]++
+	}
+	now := d.now()
+	if now.Sub(d.windowStart) < d.window {
+		d.mu.Unlock()
+		return
+	}
+	matches := d.matches
+	d.reset(now)
+	d.mu.Unlock()
+
+	d.report(matches)
+}
+
+// report logs the attributes observed in a window as a suggested
+// fields_to_mask configuration
+func (d *discovery) report(matches map[string]map[string]int) {
+	if len(matches) == 0 {
+		d.logger.Info("Discovery found no additional fields to mask", zap.Duration("window", d.window))
+		return
+	}
+
+	fields := make([]string, 0, len(matches))
+	for k := range matches {
+		fields = append(fields, k)
+	}
+	slices.Sort(fields)
+
+	var suggestion strings.Builder
+	suggestion.WriteString("fields_to_mask:\n")
+	for _, field := range fields {
+		suggestion.WriteString("  - " + field + "\n")
+	}
+
+	details := make([]zap.Field, 0, len(fields))
+	for _, field := range fields {
+		details = append(details, zap.Any(field, matches[field]))
+	}
+
+	d.logger.Info("Discovery suggests additional fields to mask",
+		zap.Duration("window", d.window),
+		zap.Strings("fields", fields),
+		zap.Dict("matches", details...),
+		zap.String("suggested_config", suggestion.String()),
+	)
+}
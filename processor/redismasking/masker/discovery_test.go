@@ -0,0 +1,96 @@
+package masker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDiscovery(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"username"}
+	cfg.Discovery = DiscoveryConfig{Enabled: true, Window: time.Minute}
+	m, err := New(&cfg, nil, zap.New(core))
+	require.NoError(t, err)
+
+	now := time.Now()
+	m.discovery.now = func() time.Time { return now }
+	m.discovery.reset(now)
+
+	newRecord := func() plog.LogRecord {
+		lr := plog.NewLogRecord()
+		lr.Attributes().PutStr("username", "10.0.0.1")
+		lr.Attributes().PutStr("peer", "192.168.1.1")
+		lr.Attributes().PutStr("path", "/login")
+		return lr
+	}
+
+	// Nothing is reported until the window ends
+	m.discovery.observe(newRecord(), m.fieldsToMask, m.compiledPatterns)
+	require.Zero(t, logs.Len())
+
+	now = now.Add(time.Minute)
+	m.discovery.observe(newRecord(), m.fieldsToMask, m.compiledPatterns)
+	require.Equal(t, 1, logs.Len())
+
+	entry := logs.All()[0]
+	assert.Equal(t, "Discovery suggests additional fields to mask", entry.Message)
+	assert.Equal(t, []any{"peer"}, entry.ContextMap()["fields"])
+	assert.Equal(t, "fields_to_mask:\n  - peer\n", entry.ContextMap()["suggested_config"])
+	assert.Equal(t, map[string]any{"peer": map[string]int{"ipv4": 2}}, entry.ContextMap()["matches"])
+
+	// A new window starts after each report
+	now = now.Add(time.Minute)
+	m.discovery.observe(plog.NewLogRecord(), m.fieldsToMask, m.compiledPatterns)
+	require.Equal(t, 2, logs.Len())
+	assert.Equal(t, "Discovery found no additional fields to mask", logs.All()[1].Message)
+}
+
+func TestDiscoveryObservesBeforeMasking(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.ScanAllAttributes = true
+	cfg.Discovery = DiscoveryConfig{Enabled: true, Window: time.Hour}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("peer", "192.168.1.1")
+	m.MaskLogs(context.Background(), ld)
+
+	assert.Equal(t, map[string]map[string]int{"peer": {"ipv4": 1}}, m.discovery.matches)
+}
+
+func TestDiscoveryConcurrentObserve(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.Discovery = DiscoveryConfig{Enabled: true, Window: time.Hour}
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				lr := plog.NewLogRecord()
+				lr.Attributes().PutStr("peer", "192.168.1.1")
+				m.discovery.observe(lr, m.fieldsToMask, m.compiledPatterns)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]map[string]int{"peer": {"ipv4": 800}}, m.discovery.matches)
+}
@@ -0,0 +1,163 @@
+package masker
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DistinctCountConfig defines the reporting of how many distinct values were
+// masked per category and interval, e.g. how many unique users appear in logs.
+// Laplace noise is added to every count so the reported trend does not become a
+// new sensitive dataset.
+type DistinctCountConfig struct {
+	// Enabled reports the noised distinct counts
+	Enabled bool `mapstructure:"enabled"`
+
+	// Interval is the period over which distinct values are counted
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Epsilon is the privacy budget spent per category and interval. Smaller
+	// values add more noise.
+	Epsilon float64 `mapstructure:"epsilon"`
+
+	// Categories limits the counting to these categories (empty = every
+	// category). Configured categories are reported even when no value was seen.
+	Categories []string `mapstructure:"categories"`
+}
+
+// Validate checks the interval and privacy budget
+func (cfg *DistinctCountConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Interval <= 0 {
+		return errors.New("distinct_counts interval must be positive")
+	}
+	if cfg.Epsilon <= 0 || math.IsInf(cfg.Epsilon, 0) || math.IsNaN(cfg.Epsilon) {
+		return errors.New("distinct_counts epsilon must be a positive number")
+	}
+	return nil
+}
+
+// distinctCounter counts the distinct values of each category within the
+// current interval. Only truncated digests of the values are kept, and only
+// until the interval ends.
+type distinctCounter struct {
+	cfg    *DistinctCountConfig
+	now    func() time.Time
+	random func() float64
+
+	mu    sync.Mutex
+	start time.Time
+	seen  map[string]map[uint64]struct{}
+}
+
+func newDistinctCounter(cfg *DistinctCountConfig) *distinctCounter {
+	return &distinctCounter{
+		cfg:    cfg,
+		now:    time.Now,
+		random: cryptoFloat64,
+		seen:   map[string]map[uint64]struct{}{},
+	}
+}
+
+// observe counts the value with digest in category, when category is counted
+func (c *distinctCounter) observe(category string, digest []byte) {
+	if len(c.cfg.Categories) > 0 && !slices.Contains(c.cfg.Categories, category) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.start.IsZero() {
+		c.start = c.now()
+	}
+	values, ok := c.seen[category]
+	if !ok {
+		values = map[uint64]struct{}{}
+		c.seen[category] = values
+	}
+	values[binary.BigEndian.Uint64(digest)] = struct{}{}
+}
+
+// flush returns the noised counts per category when the interval has ended and
+// starts the next one. It returns nil while the interval is running.
+func (c *distinctCounter) flush() map[string]int64 {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.start.IsZero() {
+		// Nothing was seen yet, so the first interval starts now
+		c.start = now
+		return nil
+	}
+	if now.Sub(c.start) < c.cfg.Interval {
+		return nil
+	}
+
+	counts := make(map[string]int64, len(c.seen)+len(c.cfg.Categories))
+	for _, category := range c.cfg.Categories {
+		counts[category] = c.noised(0)
+	}
+	for category, values := range c.seen {
+		counts[category] = c.noised(len(values))
+	}
+	c.start = now
+	c.seen = map[string]map[uint64]struct{}{}
+	return counts
+}
+
+// noised adds Laplace noise to count. Every value changes a count by at most
+// one, so a scale of 1/epsilon gives epsilon-differential privacy. Rounding and
+// clamping at zero are post-processing and keep that guarantee.
+func (c *distinctCounter) noised(count int) int64 {
+	noisy := float64(count) + laplace(1/c.cfg.Epsilon, c.random())
+	return max(0, int64(math.Round(noisy)))
+}
+
+// laplace maps u, uniform in [0, 1), to a sample of the zero-centered Laplace
+// distribution with scale
+func laplace(scale, u float64) float64 {
+	u -= 0.5
+	if u == -0.5 {
+		// The open end of the inverse CDF. It is hit with probability 2^-53.
+		u = math.Nextafter(-0.5, 0)
+	}
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
+
+// cryptoFloat64 returns a uniform float in [0, 1) from crypto/rand. A
+// predictable noise source would let the noise be subtracted again.
+func cryptoFloat64() float64 {
+	var b [8]byte
+	// Read never returns an error and crashes the program instead
+	_, _ = rand.Read(b[:])
+	return float64(binary.BigEndian.Uint64(b[:])>>11) / (1 << 53)
+}
+
+// observeDistinct counts originalValue towards the distinct values of category
+func (m *Masker) observeDistinct(originalValue, category string) {
+	if m.distinct == nil {
+		return
+	}
+	m.distinct.observe(category, m.digest(category+"\x00"+originalValue))
+}
+
+// reportDistinct records the noised distinct counts once their interval has ended
+func (m *Masker) reportDistinct() {
+	if m.distinct == nil {
+		return
+	}
+	for category, count := range m.distinct.flush() {
+		m.telemetry.recordDistinct(category, count)
+	}
+}
@@ -0,0 +1,125 @@
+package masker
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestLaplace(t *testing.T) {
+	assert.Equal(t, 0.0, laplace(2, 0.5))
+	assert.InDelta(t, 2*math.Ln2, laplace(2, 0.75), 1e-9)
+	assert.InDelta(t, -2*math.Ln2, laplace(2, 0.25), 1e-9)
+	assert.False(t, math.IsInf(laplace(2, 0), 0))
+
+	// The mean absolute deviation of the Laplace distribution is its scale
+	const samples = 100000
+	var sum, abs float64
+	for range samples {
+		noise := laplace(2, cryptoFloat64())
+		sum += noise
+		abs += math.Abs(noise)
+	}
+	assert.InDelta(t, 0, sum/samples, 0.1)
+	assert.InDelta(t, 2, abs/samples, 0.1)
+}
+
+func TestDistinctCounter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newDistinctCounter(&DistinctCountConfig{
+		Enabled:    true,
+		Interval:   time.Hour,
+		Epsilon:    1,
+		Categories: []string{"ipv4", "email"},
+	})
+	c.now = func() time.Time { return now }
+	c.random = func() float64 { return 0.5 }
+
+	c.observe("ipv4", []byte("aaaaaaaa"))
+	c.observe("ipv4", []byte("aaaaaaaa"))
+	c.observe("ipv4", []byte("bbbbbbbb"))
+	c.observe("ssn", []byte("cccccccc"))
+	assert.Nil(t, c.flush())
+
+	// Configured categories are reported even without values, others not at all
+	now = now.Add(time.Hour)
+	assert.Equal(t, map[string]int64{"ipv4": 2, "email": 0}, c.flush())
+
+	// The next interval starts empty
+	c.observe("email", []byte("aaaaaaaa"))
+	now = now.Add(time.Hour)
+	assert.Equal(t, map[string]int64{"ipv4": 0, "email": 1}, c.flush())
+}
+
+func TestDistinctCounterNoise(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newDistinctCounter(&DistinctCountConfig{Enabled: true, Interval: time.Hour, Epsilon: 0.5})
+	c.now = func() time.Time { return now }
+
+	c.observe("ipv4", []byte("aaaaaaaa"))
+	now = now.Add(time.Hour)
+
+	// Noise of scale 2 pushing the count below zero is clamped
+	c.random = func() float64 { return 0.01 }
+	assert.Equal(t, map[string]int64{"ipv4": 0}, c.flush())
+
+	c.observe("ipv4", []byte("aaaaaaaa"))
+	now = now.Add(time.Hour)
+	c.random = func() float64 { return 0.99 }
+	assert.Equal(t, map[string]int64{"ipv4": 9}, c.flush())
+}
+
+func TestDistinctCountsReported(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	cfg := NewDefaultConfig()
+	cfg.Mode = modeLightweight
+	cfg.HMACKey = "secret"
+	cfg.FieldsToMask = []string{"user_id"}
+	cfg.Patterns = ipv4Patterns()
+	cfg.DistinctCounts.Enabled = true
+	m, err := New(&cfg, nil, zap.NewNop(), WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+
+	now := time.Now()
+	m.distinct.now = func() time.Time { return now }
+	m.distinct.random = func() float64 { return 0.5 }
+
+	maskLogs := func(user, body string) {
+		ld := plog.NewLogs()
+		lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		lr.Body().SetStr(body)
+		lr.Attributes().PutStr("user_id", user)
+		m.MaskLogs(context.Background(), ld)
+	}
+	maskLogs("jdoe", "connection from 192.168.1.1 to 192.168.1.2")
+	maskLogs("jdoe", "connection from 192.168.1.1 to 192.168.1.3")
+	now = now.Add(time.Hour)
+	maskLogs("asmith", "no addresses")
+	maskLogs("bnguyen", "no addresses")
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := map[string]int64{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		if metric.Name != "redismasking.distinct_identities" {
+			continue
+		}
+		for _, dp := range metric.Data.(metricdata.Gauge[int64]).DataPoints {
+			category, _ := dp.Attributes.Value(attribute.Key("category"))
+			counts[category.AsString()] = dp.Value
+		}
+	}
+
+	// The interval ends with the batch that was masked after it expired, so the
+	// last batch is counted towards the next interval
+	assert.Equal(t, map[string]int64{attributeCategory("user_id"): 2, "ipv4": 3}, counts)
+}
@@ -0,0 +1,250 @@
+package masker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Attributes of the items of the dynamodb store
+const (
+	dynamoDBKeyAttribute     = "key"
+	dynamoDBValueAttribute   = "value"
+	dynamoDBExpiresAttribute = "expires_at"
+)
+
+// dynamoDBMaxKeyBytes is the largest partition key DynamoDB accepts
+const dynamoDBMaxKeyBytes = 2048
+
+// DynamoDBConfig locates the table of the dynamodb store. Requests are signed
+// with the credentials of the default AWS credential chain, e.g. the instance
+// profile or the web identity of the pod.
+type DynamoDBConfig struct {
+	// Table is the name of the table. Its partition key must be the string
+	// attribute "key".
+	Table string `mapstructure:"table"`
+
+	// Region of the table. The region of the default AWS configuration is used when empty.
+	Region string `mapstructure:"region"`
+
+	// Endpoint replaces the regional endpoint, e.g. for DynamoDB Local
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Timeout bounds every request, including its retries
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks that the table is set and the timeout is positive
+func (cfg *DynamoDBConfig) Validate() error {
+	if cfg.Table == "" {
+		return errors.New("dynamodb table is required")
+	}
+	if cfg.Timeout <= 0 {
+		return errors.New("dynamodb timeout must be positive")
+	}
+	return nil
+}
+
+// dynamoDBClient is the part of the DynamoDB API used by the store
+type dynamoDBClient interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+}
+
+// dynamoDBStore is a Store that keeps mappings in a DynamoDB table. Every entry
+// is an item holding the value and, with a TTL, its expiry in seconds since the
+// epoch, so the table's time to live can delete expired items.
+type dynamoDBStore struct {
+	client  dynamoDBClient
+	table   string
+	timeout time.Duration
+	now     func() time.Time
+}
+
+var (
+	_ Store          = (*dynamoDBStore)(nil)
+	_ MappingCreator = (*dynamoDBStore)(nil)
+	_ Pinger         = (*dynamoDBStore)(nil)
+)
+
+// NewDynamoDBStore creates a store on the table of cfg. Credentials are only
+// resolved when the first request is signed.
+func NewDynamoDBStore(cfg *DynamoDBConfig) (Store, error) {
+	options := []func(*config.LoadOptions) error{}
+	if cfg.Region != "" {
+		options = append(options, config.WithRegion(cfg.Region))
+	}
+	awsConfig, err := config.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if awsConfig.Region == "" {
+		return nil, errors.New("dynamodb region is required")
+	}
+
+	client := dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+	return &dynamoDBStore{client: client, table: cfg.Table, timeout: cfg.Timeout, now: time.Now}, nil
+}
+
+// Ping checks that the table exists and is keyed by the key attribute
+func (s *dynamoDBStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	out, err := s.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(s.table)})
+	if err != nil {
+		return fmt.Errorf("failed to describe DynamoDB table '%s': %w", s.table, err)
+	}
+	for _, key := range out.Table.KeySchema {
+		if key.KeyType == types.KeyTypeHash && aws.ToString(key.AttributeName) == dynamoDBKeyAttribute {
+			return nil
+		}
+	}
+	return fmt.Errorf("the partition key of DynamoDB table '%s' must be '%s'", s.table, dynamoDBKeyAttribute)
+}
+
+// Get returns the value stored under key. Expired items are not found, even
+// before the table's time to live deletes them.
+func (s *dynamoDBStore) Get(ctx context.Context, key string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.table),
+		Key:       map[string]types.AttributeValue{dynamoDBKeyAttribute: &types.AttributeValueMemberS{Value: dynamoDBKey(key)}},
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("dynamodb get error: %w", err)
+	}
+	value, found := s.value(out.Item)
+	return value, found, nil
+}
+
+// Set stores value under key
+func (s *dynamoDBStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      s.item(key, value, ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("dynamodb set error: %w", err)
+	}
+	return nil
+}
+
+// CreateMapping writes the token under maskKey and the original value under
+// unmaskKey in one transaction, on the condition that maskKey holds no live
+// token and unmaskKey no live value but original. When the condition on
+// maskKey fails, the token of the first collector wins.
+func (s *dynamoDBStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	now := &types.AttributeValueMemberN{Value: strconv.FormatInt(s.now().Unix(), 10)}
+	_, err := s.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(s.table),
+				Item:                s.item(maskKey, token, ttl),
+				ConditionExpression: aws.String("attribute_not_exists(#key) OR #expires_at <= :now"),
+				ExpressionAttributeNames: map[string]string{
+					"#key":        dynamoDBKeyAttribute,
+					"#expires_at": dynamoDBExpiresAttribute,
+				},
+				ExpressionAttributeValues:           map[string]types.AttributeValue{":now": now},
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String(s.table),
+				Item:                s.item(unmaskKey, original, ttl),
+				ConditionExpression: aws.String("attribute_not_exists(#key) OR #expires_at <= :now OR #value = :original"),
+				ExpressionAttributeNames: map[string]string{
+					"#key":        dynamoDBKeyAttribute,
+					"#expires_at": dynamoDBExpiresAttribute,
+					"#value":      dynamoDBValueAttribute,
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":now":      now,
+					":original": &types.AttributeValueMemberS{Value: original},
+				},
+			}},
+		},
+	})
+
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) && len(canceled.CancellationReasons) > 1 {
+		if aws.ToString(canceled.CancellationReasons[0].Code) == "ConditionalCheckFailed" {
+			if winner, found := s.value(canceled.CancellationReasons[0].Item); found {
+				return winner, false, nil
+			}
+		}
+		if aws.ToString(canceled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+			return "", false, errTokenTaken
+		}
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("dynamodb create mapping error: %w", err)
+	}
+	return token, true, nil
+}
+
+// Close releases nothing, since requests share the HTTP client of the SDK
+func (s *dynamoDBStore) Close() error {
+	return nil
+}
+
+// item returns the item storing value under key until ttl has passed
+func (s *dynamoDBStore) item(key, value string, ttl time.Duration) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		dynamoDBKeyAttribute:   &types.AttributeValueMemberS{Value: dynamoDBKey(key)},
+		dynamoDBValueAttribute: &types.AttributeValueMemberS{Value: value},
+	}
+	if ttl > 0 {
+		expiresAt := s.now().Add(ttl + time.Second - 1).Unix()
+		item[dynamoDBExpiresAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt, 10)}
+	}
+	return item
+}
+
+// dynamoDBKey returns key, or its hash when it is too long for a partition
+// key, e.g. because the original value it holds is long
+func dynamoDBKey(key string) string {
+	if len(key) <= dynamoDBMaxKeyBytes {
+		return key
+	}
+	hash := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// value returns the value of item unless it is missing or expired
+func (s *dynamoDBStore) value(item map[string]types.AttributeValue) (string, bool) {
+	value, ok := item[dynamoDBValueAttribute].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", false
+	}
+	if expires, ok := item[dynamoDBExpiresAttribute].(*types.AttributeValueMemberN); ok {
+		expiresAt, err := strconv.ParseInt(expires.Value, 10, 64)
+		if err == nil && expiresAt <= s.now().Unix() {
+			return "", false
+		}
+	}
+	return value.Value, true
+}
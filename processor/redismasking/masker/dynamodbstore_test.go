@@ -0,0 +1,225 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDynamoDB is a table that evaluates the condition of the store's
+// conditional writes
+type fakeDynamoDB struct {
+	mu        sync.Mutex
+	items     map[string]map[string]types.AttributeValue
+	keySchema string
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue), keySchema: dynamoDBKeyAttribute}
+}
+
+func (f *fakeDynamoDB) DescribeTable(_ context.Context, params *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if aws.ToString(params.TableName) != "tokens" {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("table not found")}
+	}
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+		KeySchema: []types.KeySchemaElement{{AttributeName: aws.String(f.keySchema), KeyType: types.KeyTypeHash}},
+	}}, nil
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: f.items[fakeDynamoDBKey(params.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[fakeDynamoDBKey(params.Item)] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) TransactWriteItems(_ context.Context, params *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reasons := make([]types.CancellationReason, len(params.TransactItems))
+	canceled := false
+	for i, item := range params.TransactItems {
+		reasons[i].Code = aws.String("None")
+		if item.Put.ConditionExpression == nil {
+			continue
+		}
+		existing, exists := f.items[fakeDynamoDBKey(item.Put.Item)]
+		if !exists {
+			continue
+		}
+		// attribute_not_exists(#key) OR #expires_at <= :now [OR #value = :original]
+		if original, ok := item.Put.ExpressionAttributeValues[":original"].(*types.AttributeValueMemberS); ok {
+			if value, ok := existing[dynamoDBValueAttribute].(*types.AttributeValueMemberS); ok && value.Value == original.Value {
+				continue
+			}
+		}
+		now, _ := strconv.ParseInt(item.Put.ExpressionAttributeValues[":now"].(*types.AttributeValueMemberN).Value, 10, 64)
+		if expires, ok := existing[dynamoDBExpiresAttribute].(*types.AttributeValueMemberN); ok {
+			if expiresAt, _ := strconv.ParseInt(expires.Value, 10, 64); expiresAt <= now {
+				continue
+			}
+		}
+		reasons[i] = types.CancellationReason{Code: aws.String("ConditionalCheckFailed"), Item: existing}
+		canceled = true
+	}
+	if canceled {
+		return nil, &types.TransactionCanceledException{Message: aws.String("transaction canceled"), CancellationReasons: reasons}
+	}
+
+	for _, item := range params.TransactItems {
+		f.items[fakeDynamoDBKey(item.Put.Item)] = item.Put.Item
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func fakeDynamoDBKey(item map[string]types.AttributeValue) string {
+	return item[dynamoDBKeyAttribute].(*types.AttributeValueMemberS).Value
+}
+
+// newTestDynamoDBStore returns a store on a fake table and a clock driving it
+func newTestDynamoDBStore(client dynamoDBClient) (*dynamoDBStore, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	store := &dynamoDBStore{client: client, table: "tokens", timeout: time.Second, now: func() time.Time { return now }}
+	return store, &now
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	store, now := newTestDynamoDBStore(newFakeDynamoDB())
+	ctx := context.Background()
+	require.NoError(t, store.Ping(ctx))
+
+	require.NoError(t, store.Set(ctx, "a", "1", 0))
+	require.NoError(t, store.Set(ctx, "b", "2", time.Minute))
+	for key, expected := range map[string]string{"a": "1", "b": "2"} {
+		value, found, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		require.True(t, found, key)
+		require.Equal(t, expected, value)
+	}
+
+	_, found, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Expired items are not found before the table deletes them
+	*now = now.Add(2 * time.Minute)
+	_, found, err = store.Get(ctx, "b")
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func TestDynamoDBStoreLongKey(t *testing.T) {
+	fake := newFakeDynamoDB()
+	store, _ := newTestDynamoDBStore(fake)
+	ctx := context.Background()
+
+	// A mapping of a long original fits the partition key
+	maskKey := MaskKey("attribute_username", strings.Repeat("x", 4096))
+	_, created, err := store.CreateMapping(ctx, maskKey, UnmaskKey("attribute_username", "abc"), strings.Repeat("x", 4096), "abc", 0)
+	require.NoError(t, err)
+	require.True(t, created)
+	for key := range fake.items {
+		require.LessOrEqual(t, len(key), dynamoDBMaxKeyBytes)
+	}
+
+	value, found, err := store.Get(ctx, maskKey)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "abc", value)
+}
+
+func TestDynamoDBKey(t *testing.T) {
+	require.Equal(t, "mask:ipv4:abc", dynamoDBKey("mask:ipv4:abc"))
+	require.Equal(t, strings.Repeat("x", dynamoDBMaxKeyBytes), dynamoDBKey(strings.Repeat("x", dynamoDBMaxKeyBytes)))
+
+	hashed := dynamoDBKey(strings.Repeat("x", dynamoDBMaxKeyBytes+1))
+	require.True(t, strings.HasPrefix(hashed, "sha256:"))
+	require.Len(t, hashed, len("sha256:")+64)
+}
+
+func TestDynamoDBStoreCreateMapping(t *testing.T) {
+	store, now := newTestDynamoDBStore(newFakeDynamoDB())
+	ctx := context.Background()
+
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+	winner, created, err := store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", time.Hour)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	// The first token wins, and the reverse mapping of the loser is not written
+	winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", time.Hour)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+	_, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.4.5.6"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+
+	// A token held by another value is refused
+	_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", time.Hour)
+	require.ErrorIs(t, err, errTokenTaken)
+	_, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.9"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// An expired token is replaced
+	*now = now.Add(2 * time.Hour)
+	winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", time.Hour)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.4.5.6", winner)
+}
+
+func TestDynamoDBStorePing(t *testing.T) {
+	client := newFakeDynamoDB()
+	store, _ := newTestDynamoDBStore(client)
+	ctx := context.Background()
+
+	client.keySchema = "id"
+	require.ErrorContains(t, store.Ping(ctx), "the partition key of DynamoDB table 'tokens' must be 'key'")
+
+	store.table = "missing"
+	err := store.Ping(ctx)
+	require.ErrorContains(t, err, "failed to describe DynamoDB table 'missing'")
+	var notFound *types.ResourceNotFoundException
+	require.True(t, errors.As(err, &notFound))
+}
+
+func TestNewDynamoDBStore(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+
+	_, err := NewDynamoDBStore(&DynamoDBConfig{Table: "tokens", Timeout: time.Second})
+	require.ErrorContains(t, err, "dynamodb region is required")
+
+	store, err := NewDynamoDBStore(&DynamoDBConfig{Table: "tokens", Region: "us-east-1", Timeout: time.Second})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+}
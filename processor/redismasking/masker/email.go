@@ -0,0 +1,44 @@
+package masker
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// defaultEmailPrefix starts the local part of the tokens of the email strategy
+const defaultEmailPrefix = "user-"
+
+// strategyOf returns the strategy of category, a pattern name or the category
+// of a field
+func (m *Masker) strategyOf(category string) StrategyConfig {
+	for _, pattern := range m.compiledPatterns {
+		if pattern.name == category {
+			return pattern.strategy
+		}
+	}
+	if key, ok := strings.CutPrefix(category, "attribute_"); ok {
+		return m.config.FieldStrategies[key]
+	}
+	return StrategyConfig{}
+}
+
+// emailToken derives a token of the email address originalValue that keeps its
+// domain, e.g. user-a1b2c3d4e5f6@acme.com, so per-domain analytics still work.
+// The local part is derived from seed, which holds the whole address, so equal
+// local parts at different domains get different tokens. Values without a domain are reported
+// as such.
+func (m *Masker) emailToken(originalValue, seed, category string) (string, bool) {
+	at := strings.LastIndexByte(originalValue, '@')
+	if at <= 0 || at == len(originalValue)-1 {
+		return "", false
+	}
+
+	prefix := defaultEmailPrefix
+	for _, pattern := range m.compiledPatterns {
+		if pattern.name == category && pattern.maskedPrefix != "" {
+			prefix = pattern.maskedPrefix
+		}
+	}
+	hashStr := hex.EncodeToString(m.digest(seed))
+	return prefix + hashStr[:m.tokenLength(category, m.config.TokenLength)] + originalValue[at:], true
+}
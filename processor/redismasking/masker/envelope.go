@@ -0,0 +1,108 @@
+package masker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Envelope tokens have the form tk<version>_<category code>_<payload><check>:
+//
+//   - version is the scheme version, currently 1
+//   - category code is the first 8 hex digits of the SHA-256 hash of the category
+//     the mapping is stored under, so anyone knowing the category can compute it
+//   - payload is 24 hex digits derived from the value
+//   - check is the first 4 hex digits of the SHA-256 hash of everything before it,
+//     so random strings of the same shape are rejected
+//
+// All hex digits are lowercase.
+const (
+	// envelopeVersion is the scheme version of newly created envelope tokens
+	envelopeVersion = 1
+
+	// envelopeCodeLen is the length of the category code
+	envelopeCodeLen = 8
+
+	// envelopePayloadLen is the length of the payload
+	envelopePayloadLen = 24
+
+	// envelopeCheckLen is the length of the check digits
+	envelopeCheckLen = 4
+)
+
+// Token is a parsed envelope token
+type Token struct {
+	// Version is the scheme version
+	Version int
+
+	// CategoryCode identifies the category of the token, see CategoryCode
+	CategoryCode string
+
+	// Payload is the part derived from the original value
+	Payload string
+}
+
+// CategoryCode returns the code identifying category in envelope tokens. For
+// namespaced mappings the category includes the namespace, e.g. "vendor_x/ipv4".
+func CategoryCode(category string) string {
+	hash := sha256.Sum256([]byte(category))
+	return hex.EncodeToString(hash[:])[:envelopeCodeLen]
+}
+
+// envelopeToken formats hash as an envelope token of category
+func envelopeToken(hash []byte, category string) string {
+	body := fmt.Sprintf("tk%d_%s_%s", envelopeVersion, CategoryCode(category), hex.EncodeToString(hash)[:envelopePayloadLen])
+	return body + envelopeCheck(body)
+}
+
+// envelopeCheck returns the check digits of the token body preceding them
+func envelopeCheck(body string) string {
+	hash := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(hash[:])[:envelopeCheckLen]
+}
+
+// ParseToken parses an envelope token and verifies its check digits. Downstream
+// systems can use it to tell whether a field value is a token.
+func ParseToken(value string) (Token, error) {
+	version, rest, ok := strings.Cut(value, "_")
+	if !ok || version != fmt.Sprintf("tk%d", envelopeVersion) {
+		if ok && strings.HasPrefix(version, "tk") {
+			return Token{}, fmt.Errorf("unsupported token scheme version '%s'", strings.TrimPrefix(version, "tk"))
+		}
+		return Token{}, errors.New("not an envelope token")
+	}
+
+	code, payload, ok := strings.Cut(rest, "_")
+	if !ok || len(code) != envelopeCodeLen || len(payload) != envelopePayloadLen+envelopeCheckLen || !isLowerHex(code) || !isLowerHex(payload) {
+		return Token{}, errors.New("not an envelope token")
+	}
+
+	body, check := value[:len(value)-envelopeCheckLen], value[len(value)-envelopeCheckLen:]
+	if envelopeCheck(body) != check {
+		return Token{}, errors.New("envelope token check digits do not match")
+	}
+
+	return Token{
+		Version:      envelopeVersion,
+		CategoryCode: code,
+		Payload:      payload[:envelopePayloadLen],
+	}, nil
+}
+
+// IsToken reports whether value is a valid envelope token
+func IsToken(value string) bool {
+	_, err := ParseToken(value)
+	return err == nil
+}
+
+// isLowerHex reports whether s only holds lowercase hex digits
+func isLowerHex(s string) bool {
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
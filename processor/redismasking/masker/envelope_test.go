@@ -0,0 +1,66 @@
+package masker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestEnvelopeTokenFormat(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenFormat = tokenFormatEnvelope
+	cfg.TokenNamespace = "vendor_x"
+	cfg.Patterns = ipv4Patterns()
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	token := m.generateMaskedValue("192.168.1.1", "ipv4")
+	assert.Regexp(t, `^tk1_[0-9a-f]{8}_[0-9a-f]{28}$`, token)
+	assert.Equal(t, token, m.generateMaskedValue("192.168.1.1", "ipv4"))
+	assert.NotEqual(t, token, m.generateMaskedValue("192.168.1.2", "ipv4"))
+
+	parsed, err := ParseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, 1, parsed.Version)
+	assert.Equal(t, CategoryCode("vendor_x/ipv4"), parsed.CategoryCode)
+	assert.NotEqual(t, CategoryCode("ipv4"), parsed.CategoryCode)
+	assert.Len(t, parsed.Payload, 24)
+	assert.True(t, IsToken(token))
+}
+
+func TestParseToken(t *testing.T) {
+	valid := envelopeToken(make([]byte, 32), "ipv4")
+	require.True(t, IsToken(valid))
+
+	// Flip the last payload digit, so only the check digits are wrong
+	last := valid[len(valid)-envelopeCheckLen-1]
+	flipped := byte('1')
+	if last == '1' {
+		flipped = '2'
+	}
+	tampered := valid[:len(valid)-envelopeCheckLen-1] + string(flipped) + valid[len(valid)-envelopeCheckLen:]
+
+	testCases := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{name: "plain value", value: "192.168.1.1", err: "not an envelope token"},
+		{name: "empty", value: "", err: "not an envelope token"},
+		{name: "unsupported version", value: "tk2" + strings.TrimPrefix(valid, "tk1"), err: "unsupported token scheme version '2'"},
+		{name: "short payload", value: valid[:len(valid)-1], err: "not an envelope token"},
+		{name: "uppercase", value: strings.ToUpper(valid[:3]) + valid[3:], err: "not an envelope token"},
+		{name: "non hex", value: valid[:4] + "z" + valid[5:], err: "not an envelope token"},
+		{name: "invalid check digits", value: tampered, err: "envelope token check digits do not match"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseToken(tc.value)
+			require.ErrorContains(t, err, tc.err)
+			assert.False(t, IsToken(tc.value))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package masker
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// errorLog keeps agent logs usable when every match fails, e.g. during a Redis
+// outage. Errors are classed by their message: the first occurrence of a class
+// is logged in full, and further occurrences within the interval are only
+// counted and then summarized in a single entry.
+type errorLog struct {
+	logger   *zap.Logger
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	classes map[string]*errorClass
+}
+
+// errorClass aggregates the occurrences of an error class within the current interval
+type errorClass struct {
+	level      zapcore.Level
+	start      time.Time
+	suppressed int64
+	first      time.Time
+	last       time.Time
+	lastErr    error
+}
+
+func newErrorLog(logger *zap.Logger, interval time.Duration) *errorLog {
+	return &errorLog{
+		logger:   logger,
+		interval: interval,
+		now:      time.Now,
+		classes:  map[string]*errorClass{},
+	}
+}
+
+// log logs err under msg, or counts it when msg was already logged in the
+// current interval. An interval of 0 logs every error.
+func (l *errorLog) log(level zapcore.Level, msg string, err error, fields ...zap.Field) {
+	if l.interval == 0 {
+		l.logger.Log(level, msg, append(fields, zap.Error(err))...)
+		return
+	}
+
+	now := l.now()
+	l.mu.Lock()
+	l.flushLocked(now)
+	class, seen := l.classes[msg]
+	if !seen {
+		l.classes[msg] = &errorClass{level: level, start: now}
+	} else {
+		if class.suppressed == 0 {
+			class.first = now
+		}
+		class.suppressed++
+		class.last = now
+		class.lastErr = err
+	}
+	l.mu.Unlock()
+
+	if !seen {
+		l.logger.Log(level, msg, append(fields, zap.Error(err))...)
+	}
+}
+
+// flush summarizes the classes whose interval has ended. A nil errorLog holds nothing.
+func (l *errorLog) flush() {
+	if l == nil || l.interval == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushLocked(l.now())
+}
+
+// flushAll summarizes every class regardless of its interval, e.g. on shutdown
+func (l *errorLog) flushAll() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for msg, class := range l.classes {
+		l.summarize(msg, class)
+		delete(l.classes, msg)
+	}
+}
+
+// flushLocked summarizes and forgets the classes whose interval ended before
+// now, so their next occurrence is logged in full again. The caller holds l.mu.
+func (l *errorLog) flushLocked(now time.Time) {
+	for msg, class := range l.classes {
+		if now.Sub(class.start) >= l.interval {
+			l.summarize(msg, class)
+			delete(l.classes, msg)
+		}
+	}
+}
+
+// summarize logs the occurrences of class that were not logged individually
+func (l *errorLog) summarize(msg string, class *errorClass) {
+	if class.suppressed == 0 {
+		return
+	}
+	l.logger.Log(class.level, "Suppressed repeated masking errors",
+		zap.String("error_class", msg),
+		zap.Int64("count", class.suppressed),
+		zap.Time("first", class.first),
+		zap.Time("last", class.last),
+		zap.NamedError("last_error", class.lastErr))
+}
+
+// logError logs a masking error through the error aggregation
+func (m *Masker) logError(msg string, err error, fields ...zap.Field) {
+	m.logAggregated(zapcore.ErrorLevel, msg, err, fields...)
+}
+
+// logWarn logs a masking warning through the error aggregation
+func (m *Masker) logWarn(msg string, err error, fields ...zap.Field) {
+	m.logAggregated(zapcore.WarnLevel, msg, err, fields...)
+}
+
+// logAggregated logs through the error aggregation, or directly when the Masker
+// was not created by New
+func (m *Masker) logAggregated(level zapcore.Level, msg string, err error, fields ...zap.Field) {
+	if m.errLog == nil {
+		m.logger.Log(level, msg, append(fields, zap.Error(err))...)
+		return
+	}
+	m.errLog.log(level, msg, err, fields...)
+}
+
+// FlushErrors logs the summary of every error suppressed so far. It is called
+// on shutdown so that no counts are lost.
+func (m *Masker) FlushErrors() {
+	m.errLog.flushAll()
+}
@@ -0,0 +1,96 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestErrorLog(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	errLog := newErrorLog(zap.New(core), time.Minute)
+	now := time.Now().Round(0)
+	errLog.now = func() time.Time { return now }
+
+	// Only the first error of a class is logged in full
+	for i := 0; i < 3; i++ {
+		errLog.log(zapcore.ErrorLevel, "Failed to mask value", errors.New("connection refused"), zap.String("pattern", "ipv4"))
+		now = now.Add(time.Second)
+	}
+	errLog.log(zapcore.WarnLevel, "Failed to read cached mapping", errors.New("timeout"))
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Failed to mask value", entries[0].Message)
+	assert.Equal(t, "ipv4", entries[0].ContextMap()["pattern"])
+	assert.Equal(t, "Failed to read cached mapping", entries[1].Message)
+
+	// Nothing is summarized before the interval ends
+	errLog.flush()
+	require.Zero(t, logs.Len())
+
+	first := now.Add(-2 * time.Second)
+	last := now.Add(-time.Second)
+	now = now.Add(time.Minute)
+	errLog.flush()
+	entries = logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zapcore.ErrorLevel, entries[0].Level)
+	assert.Equal(t, "Suppressed repeated masking errors", entries[0].Message)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "Failed to mask value", fields["error_class"])
+	assert.Equal(t, int64(2), fields["count"])
+	assert.Equal(t, first, fields["first"])
+	assert.Equal(t, last, fields["last"])
+	assert.Equal(t, "connection refused", fields["last_error"])
+
+	// The next occurrence after a summary is logged in full again
+	errLog.log(zapcore.ErrorLevel, "Failed to mask value", errors.New("connection refused"))
+	errLog.log(zapcore.ErrorLevel, "Failed to mask value", errors.New("connection reset"))
+	require.Equal(t, 1, logs.Len())
+	errLog.flushAll()
+	entries = logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "connection reset", entries[1].ContextMap()["last_error"])
+}
+
+func TestErrorLogDisabled(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	errLog := newErrorLog(zap.New(core), 0)
+	for i := 0; i < 3; i++ {
+		errLog.log(zapcore.ErrorLevel, "Failed to mask value", errors.New("connection refused"))
+	}
+	errLog.flushAll()
+	assert.Equal(t, 3, logs.Len())
+}
+
+func TestMaskingErrorsAreAggregated(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	cfg.Patterns = ipv4Patterns()
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	core, logs := observer.New(zap.InfoLevel)
+	m, err := New(&cfg, store, zap.New(core))
+	require.NoError(t, err)
+
+	// Every match fails during an outage
+	server.Close()
+	m.MaskString(context.Background(), "from 192.168.1.1 to 192.168.1.2 via 192.168.1.3")
+	require.Equal(t, 1, logs.FilterMessage("Failed to mask value").Len())
+
+	m.FlushErrors()
+	summaries := logs.FilterMessage("Suppressed repeated masking errors").All()
+	require.Len(t, summaries, 1)
+	assert.Equal(t, int64(2), summaries[0].ContextMap()["count"])
+}
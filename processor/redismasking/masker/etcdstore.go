@@ -0,0 +1,250 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+// EtcdConfig locates the cluster of the etcd store
+type EtcdConfig struct {
+	// Endpoints are the client URLs of the etcd members, e.g. https://etcd-0:2379
+	Endpoints []string `mapstructure:"endpoints"`
+
+	// Prefix is prepended to every key, so the store can share a cluster
+	Prefix string `mapstructure:"prefix"`
+
+	// Username and Password authenticate with etcd when auth is enabled
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+
+	// TLS secures the connection to etcd
+	TLS TLSConfig `mapstructure:"tls"`
+
+	// DialTimeout bounds establishing the connection
+	DialTimeout time.Duration `mapstructure:"dial_timeout"`
+
+	// Timeout bounds every request
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks that endpoints are listed and the timeouts are positive
+func (cfg *EtcdConfig) Validate() error {
+	if len(cfg.Endpoints) == 0 {
+		return errors.New("etcd endpoints are required")
+	}
+	if slices.Contains(cfg.Endpoints, "") {
+		return errors.New("etcd endpoints must not be empty")
+	}
+	if cfg.DialTimeout <= 0 {
+		return errors.New("etcd dial_timeout must be positive")
+	}
+	if cfg.Timeout <= 0 {
+		return errors.New("etcd timeout must be positive")
+	}
+	return nil
+}
+
+// etcdPingKey is counted to check the connection. It is never written.
+const etcdPingKey = "ping"
+
+// etcdLeaseWindow is how long a lease is shared by the entries written with the
+// same TTL. Leases are granted for the TTL plus the window, so entries expire
+// at most a window late but never early, and a collector grants one lease per
+// TTL and window instead of one per mapping.
+const etcdLeaseWindow = time.Minute
+
+// etcdLease is a lease shared by new entries until it is replaced
+type etcdLease struct {
+	id    clientv3.LeaseID
+	until time.Time
+}
+
+// etcdStore is a Store that keeps mappings in etcd. Entries with a TTL are
+// attached to a lease, so etcd deletes them once it expires. Mappings are
+// created in a transaction, so the first collector to map a value wins.
+type etcdStore struct {
+	client  *clientv3.Client
+	prefix  string
+	timeout time.Duration
+	now     func() time.Time
+
+	// mu guards leases, the current lease of every TTL
+	mu     sync.Mutex
+	leases map[time.Duration]etcdLease
+}
+
+var (
+	_ Store          = (*etcdStore)(nil)
+	_ MappingCreator = (*etcdStore)(nil)
+	_ Pinger         = (*etcdStore)(nil)
+)
+
+// NewEtcdStore creates a store on the cluster of cfg. The connection is
+// established in the background.
+func NewEtcdStore(cfg *EtcdConfig, logger *zap.Logger) (Store, error) {
+	tlsConfig, err := cfg.TLS.Load()
+	if err != nil {
+		return nil, err
+	}
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   cfg.Endpoints,
+		Username:    cfg.Username,
+		Password:    cfg.Password,
+		TLS:         tlsConfig,
+		DialTimeout: cfg.DialTimeout,
+		Logger:      logger.Named("etcd"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create etcd client: %w", err)
+	}
+	return &etcdStore{
+		client:  client,
+		prefix:  cfg.Prefix,
+		timeout: cfg.Timeout,
+		now:     time.Now,
+		leases:  make(map[time.Duration]etcdLease),
+	}, nil
+}
+
+// Ping checks that the cluster serves reads
+func (s *etcdStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.client.Get(ctx, s.prefix+etcdPingKey, clientv3.WithCountOnly()); err != nil {
+		return fmt.Errorf("failed to connect to etcd: %w", err)
+	}
+	return nil
+}
+
+// Get returns the value stored under key
+func (s *etcdStore) Get(ctx context.Context, key string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	resp, err := s.client.Get(ctx, s.prefix+key)
+	if err != nil {
+		return "", false, fmt.Errorf("etcd get error: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", false, nil
+	}
+	return string(resp.Kvs[0].Value), true, nil
+}
+
+// Set stores value under key
+func (s *etcdStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	lease, err := s.lease(ctx, ttl)
+	if err != nil {
+		return fmt.Errorf("etcd set error: %w", err)
+	}
+	if _, err := s.client.Put(ctx, s.prefix+key, value, clientv3.WithLease(lease)); err != nil {
+		s.dropLease(ttl, lease)
+		return fmt.Errorf("etcd set error: %w", err)
+	}
+	return nil
+}
+
+// CreateMapping writes the token under maskKey and the original value under
+// unmaskKey in a transaction on the condition that maskKey does not exist and
+// unmaskKey is unchanged since it was checked for another value. When maskKey
+// exists, the transaction returns the token that won instead.
+func (s *etcdStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	unmaskPath := s.prefix + unmaskKey
+	holder, err := s.client.Get(ctx, unmaskPath)
+	if err != nil {
+		return "", false, fmt.Errorf("etcd create mapping error: %w", err)
+	}
+	// Missing keys compare with a revision of 0
+	var unmaskRevision int64
+	if len(holder.Kvs) > 0 {
+		if string(holder.Kvs[0].Value) != original {
+			return "", false, errTokenTaken
+		}
+		unmaskRevision = holder.Kvs[0].ModRevision
+	}
+
+	lease, err := s.lease(ctx, ttl)
+	if err != nil {
+		return "", false, fmt.Errorf("etcd create mapping error: %w", err)
+	}
+	maskPath := s.prefix + maskKey
+	resp, err := s.client.Txn(ctx).
+		If(
+			clientv3.Compare(clientv3.CreateRevision(maskPath), "=", 0),
+			clientv3.Compare(clientv3.ModRevision(unmaskPath), "=", unmaskRevision),
+		).
+		Then(
+			clientv3.OpPut(maskPath, token, clientv3.WithLease(lease)),
+			clientv3.OpPut(unmaskPath, original, clientv3.WithLease(lease)),
+		).
+		Else(clientv3.OpGet(maskPath)).
+		Commit()
+	if err != nil {
+		s.dropLease(ttl, lease)
+		return "", false, fmt.Errorf("etcd create mapping error: %w", err)
+	}
+	if resp.Succeeded {
+		return token, true, nil
+	}
+
+	// The comparison and the read happen at the same revision, so a missing
+	// maskKey means unmaskKey was written since it was checked
+	kvs := resp.Responses[0].GetResponseRange().GetKvs()
+	if len(kvs) == 0 {
+		return "", false, errTokenTaken
+	}
+	return string(kvs[0].Value), false, nil
+}
+
+// Close closes the connection. Leases are left to expire.
+func (s *etcdStore) Close() error {
+	return s.client.Close()
+}
+
+// lease returns the lease of new entries with ttl, granting a new one once the
+// current one is older than a window. Entries without a TTL have no lease.
+func (s *etcdStore) lease(ctx context.Context, ttl time.Duration) (clientv3.LeaseID, error) {
+	if ttl <= 0 {
+		return clientv3.NoLease, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if lease, ok := s.leases[ttl]; ok && now.Before(lease.until) {
+		return lease.id, nil
+	}
+	seconds := int64((ttl + etcdLeaseWindow + time.Second - 1) / time.Second)
+	resp, err := s.client.Grant(ctx, seconds)
+	if err != nil {
+		return clientv3.NoLease, fmt.Errorf("failed to grant lease: %w", err)
+	}
+	s.leases[ttl] = etcdLease{id: resp.ID, until: now.Add(etcdLeaseWindow)}
+	return resp.ID, nil
+}
+
+// dropLease forgets the lease of ttl after a failed write, e.g. because the
+// lease was revoked, so the next write grants a new one
+func (s *etcdStore) dropLease(ttl time.Duration, id clientv3.LeaseID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lease, ok := s.leases[ttl]; ok && lease.id == id {
+		delete(s.leases, ttl)
+	}
+}
@@ -0,0 +1,210 @@
+package masker
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// fakeEtcd is an in-memory etcd server serving single key reads, writes,
+// transactions comparing create or mod revisions, and leases
+type fakeEtcd struct {
+	etcdserverpb.UnimplementedKVServer
+	etcdserverpb.UnimplementedLeaseServer
+
+	mu       sync.Mutex
+	revision int64
+	kvs      map[string]*mvccpb.KeyValue
+	leases   map[int64]int64
+}
+
+func (f *fakeEtcd) Range(_ context.Context, req *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rangeLocked(req), nil
+}
+
+func (f *fakeEtcd) Put(_ context.Context, req *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.putLocked(req)
+	return &etcdserverpb.PutResponse{}, nil
+}
+
+func (f *fakeEtcd) Txn(_ context.Context, req *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	succeeded := true
+	for _, cmp := range req.Compare {
+		var createRevision, modRevision int64
+		if kv, ok := f.kvs[string(cmp.Key)]; ok {
+			createRevision, modRevision = kv.CreateRevision, kv.ModRevision
+		}
+		switch cmp.Target {
+		case etcdserverpb.Compare_CREATE:
+			succeeded = succeeded && createRevision == cmp.GetCreateRevision()
+		case etcdserverpb.Compare_MOD:
+			succeeded = succeeded && modRevision == cmp.GetModRevision()
+		default:
+			succeeded = false
+		}
+		succeeded = succeeded && cmp.Result == etcdserverpb.Compare_EQUAL
+	}
+	ops := req.Success
+	if !succeeded {
+		ops = req.Failure
+	}
+
+	resp := &etcdserverpb.TxnResponse{Succeeded: succeeded}
+	for _, op := range ops {
+		if put := op.GetRequestPut(); put != nil {
+			f.putLocked(put)
+			resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+				Response: &etcdserverpb.ResponseOp_ResponsePut{ResponsePut: &etcdserverpb.PutResponse{}},
+			})
+		} else {
+			resp.Responses = append(resp.Responses, &etcdserverpb.ResponseOp{
+				Response: &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: f.rangeLocked(op.GetRequestRange())},
+			})
+		}
+	}
+	return resp, nil
+}
+
+func (f *fakeEtcd) LeaseGrant(_ context.Context, req *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	id := int64(len(f.leases) + 1)
+	f.leases[id] = req.TTL
+	return &etcdserverpb.LeaseGrantResponse{ID: id, TTL: req.TTL}, nil
+}
+
+// expire deletes the keys attached to lease
+func (f *fakeEtcd) expire(lease int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, kv := range f.kvs {
+		if kv.Lease == lease {
+			delete(f.kvs, key)
+		}
+	}
+}
+
+func (f *fakeEtcd) rangeLocked(req *etcdserverpb.RangeRequest) *etcdserverpb.RangeResponse {
+	resp := &etcdserverpb.RangeResponse{}
+	if kv, ok := f.kvs[string(req.Key)]; ok {
+		resp.Count = 1
+		if !req.CountOnly {
+			resp.Kvs = []*mvccpb.KeyValue{kv}
+		}
+	}
+	return resp
+}
+
+func (f *fakeEtcd) putLocked(req *etcdserverpb.PutRequest) {
+	f.revision++
+	kv := &mvccpb.KeyValue{Key: req.Key, Value: req.Value, Lease: req.Lease, CreateRevision: f.revision, ModRevision: f.revision}
+	if existing, ok := f.kvs[string(req.Key)]; ok {
+		kv.CreateRevision = existing.CreateRevision
+	}
+	f.kvs[string(req.Key)] = kv
+}
+
+// newTestEtcdStore returns a store on a fake etcd server and a settable clock
+func newTestEtcdStore(t *testing.T) (*etcdStore, *fakeEtcd, *time.Time) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	fake := &fakeEtcd{kvs: make(map[string]*mvccpb.KeyValue), leases: make(map[int64]int64)}
+	server := grpc.NewServer()
+	etcdserverpb.RegisterKVServer(server, fake)
+	etcdserverpb.RegisterLeaseServer(server, fake)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	cfg := NewDefaultConfig().Etcd
+	cfg.Endpoints = []string{listener.Addr().String()}
+	store, err := NewEtcdStore(&cfg, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	s := store.(*etcdStore)
+	now := time.Unix(1_700_000_000, 0)
+	s.now = func() time.Time { return now }
+	return s, fake, &now
+}
+
+func TestEtcdStore(t *testing.T) {
+	store, fake, _ := newTestEtcdStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Ping(ctx))
+
+	_, found, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, store.Set(ctx, "forever", "value", 0))
+	require.NoError(t, store.Set(ctx, "minute", "value", time.Minute))
+	require.Zero(t, fake.kvs["redismasking/forever"].Lease)
+	require.Equal(t, int64(120), fake.leases[fake.kvs["redismasking/minute"].Lease])
+
+	value, found, err := store.Get(ctx, "forever")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", value)
+
+	fake.expire(fake.kvs["redismasking/minute"].Lease)
+	_, found, err = store.Get(ctx, "minute")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestEtcdStoreCreateMapping(t *testing.T) {
+	store, fake, now := newTestEtcdStore(t)
+	ctx := context.Background()
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+
+	winner, created, err := store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", time.Hour)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+
+	// An existing mapping keeps its token
+	winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", time.Hour)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	// A token held by another value is refused
+	_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", time.Hour)
+	require.ErrorIs(t, err, errTokenTaken)
+	_, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.9"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Mappings created within a window share a lease
+	_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.2"), UnmaskKey("ipv4", "10.7.8.9"), "192.168.1.2", "10.7.8.9", time.Hour)
+	require.NoError(t, err)
+	require.Len(t, fake.leases, 1)
+
+	*now = now.Add(etcdLeaseWindow)
+	_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.3"), UnmaskKey("ipv4", "10.10.11.12"), "192.168.1.3", "10.10.11.12", time.Hour)
+	require.NoError(t, err)
+	require.Len(t, fake.leases, 2)
+}
@@ -0,0 +1,148 @@
+package masker
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	// fakeKindName fakes full names, e.g. "Olivia R. Martinez"
+	fakeKindName = "name"
+
+	// fakeKindEmail fakes email addresses under the reserved example domains
+	fakeKindEmail = "email"
+
+	// fakeKindAddress fakes street addresses, e.g. "4821 Maple Ave"
+	fakeKindAddress = "address"
+
+	// fakeKindPhone fakes phone numbers in the fictional 555-0100 to 555-0199 range
+	fakeKindPhone = "phone"
+
+	// fakeKindUsername fakes user names, e.g. "quietfalcon42"
+	fakeKindUsername = "username"
+)
+
+// defaultFakeKinds are the kinds of fake values of the built-in categories and
+// semantic convention fields, unless fake_kinds sets another
+var defaultFakeKinds = map[string]string{
+	patientNamePattern: fakeKindName,
+	"user.email":       fakeKindEmail,
+	"user.full_name":   fakeKindName,
+	"user.name":        fakeKindUsername,
+}
+
+var (
+	fakeFirstNames = []string{
+		"Olivia", "Liam", "Emma", "Noah", "Amelia", "Oliver", "Sophia", "Elijah",
+		"Charlotte", "James", "Ava", "William", "Isabella", "Benjamin", "Mia", "Lucas",
+		"Evelyn", "Henry", "Harper", "Theodore", "Luna", "Jack", "Camila", "Levi",
+		"Gianna", "Alexander", "Elizabeth", "Jackson", "Eleanor", "Mateo", "Ella", "Daniel",
+		"Abigail", "Michael", "Sofia", "Mason", "Avery", "Sebastian", "Scarlett", "Ethan",
+		"Emily", "Logan", "Aria", "Owen", "Penelope", "Samuel", "Chloe", "Jacob",
+		"Layla", "Asher", "Mila", "Aiden", "Nora", "John", "Hazel", "Joseph",
+		"Madison", "Wyatt", "Ellie", "David", "Lily", "Leo", "Nova", "Luke",
+	}
+	fakeLastNames = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis",
+		"Rodriguez", "Martinez", "Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas",
+		"Taylor", "Moore", "Jackson", "Martin", "Lee", "Perez", "Thompson", "White",
+		"Harris", "Sanchez", "Clark", "Ramirez", "Lewis", "Robinson", "Walker", "Young",
+		"Allen", "King", "Wright", "Scott", "Torres", "Nguyen", "Hill", "Flores",
+		"Green", "Adams", "Nelson", "Baker", "Hall", "Rivera", "Campbell", "Mitchell",
+		"Carter", "Roberts", "Gomez", "Phillips", "Evans", "Turner", "Diaz", "Parker",
+		"Cruz", "Edwards", "Collins", "Reyes", "Stewart", "Morris", "Morales", "Murphy",
+	}
+	fakeStreets = []string{
+		"Maple", "Oak", "Pine", "Cedar", "Elm", "Willow", "Birch", "Walnut",
+		"Chestnut", "Spruce", "Hickory", "Magnolia", "Sycamore", "Aspen", "Juniper", "Laurel",
+		"Main", "Park", "Lake", "Hill", "River", "Church", "Mill", "Spring",
+		"Washington", "Lincoln", "Jefferson", "Madison", "Franklin", "Highland", "Meadow", "Sunset",
+	}
+	fakeStreetSuffixes = []string{"St", "Ave", "Rd", "Blvd", "Ln", "Dr", "Ct", "Way"}
+	fakeEmailDomains   = []string{"example.com", "example.net", "example.org"}
+	fakeAdjectives     = []string{
+		"quiet", "brave", "lucky", "swift", "bright", "calm", "clever", "eager",
+		"gentle", "happy", "jolly", "kind", "lively", "proud", "silly", "witty",
+		"bold", "cosmic", "dusty", "fuzzy", "golden", "hidden", "icy", "misty",
+		"noble", "rapid", "rusty", "shiny", "sunny", "tidy", "wild", "zesty",
+	}
+	fakeNouns = []string{
+		"falcon", "otter", "panda", "tiger", "badger", "beaver", "condor", "dolphin",
+		"eagle", "ferret", "gecko", "heron", "ibis", "jaguar", "koala", "lemur",
+		"marten", "narwhal", "ocelot", "pelican", "quokka", "raven", "salmon", "toucan",
+		"walrus", "yak", "zebra", "comet", "meteor", "canyon", "glacier", "harbor",
+	}
+)
+
+// validateFakeKind checks that kind is a supported kind of fake value
+func validateFakeKind(kind string) error {
+	switch kind {
+	case fakeKindName, fakeKindEmail, fakeKindAddress, fakeKindPhone, fakeKindUsername:
+		return nil
+	default:
+		return fmt.Errorf("unsupported fake_kinds kind '%s'", kind)
+	}
+}
+
+// usesFake reports whether the default or a pattern's token format is fake
+func (cfg *Config) usesFake() bool {
+	if cfg.TokenFormat == tokenFormatFake {
+		return true
+	}
+	for _, pattern := range cfg.Patterns {
+		if pattern.TokenFormat == tokenFormatFake {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeKind returns the kind of fake value of category, a pattern name or the
+// category of a field
+func (m *Masker) fakeKind(category string) (string, bool) {
+	key := strings.TrimPrefix(category, "attribute_")
+	if kind, ok := m.config.FakeKinds[key]; ok {
+		return kind, true
+	}
+	kind, ok := defaultFakeKinds[key]
+	return kind, ok
+}
+
+// fakeSource draws deterministic choices from the bytes of a hash
+type fakeSource struct {
+	hash []byte
+	pos  int
+}
+
+// intn returns a number in [0, n) drawn from the next two bytes of the hash
+func (s *fakeSource) intn(n int) int {
+	v := binary.BigEndian.Uint16(s.hash[s.pos%len(s.hash):])
+	s.pos += 2
+	return int(v) % n
+}
+
+// pick returns an element of values drawn from the hash
+func (s *fakeSource) pick(values []string) string {
+	return values[s.intn(len(values))]
+}
+
+// fakeToken formats hash as a realistic value of kind. Fake values are drawn
+// from a limited space, so a number is mixed into each to keep distinct values
+// apart, except that names keep only a middle initial.
+func fakeToken(hash []byte, kind string) string {
+	src := &fakeSource{hash: hash}
+	switch kind {
+	case fakeKindName:
+		return fmt.Sprintf("%s %c. %s", src.pick(fakeFirstNames), 'A'+rune(src.intn(26)), src.pick(fakeLastNames))
+	case fakeKindEmail:
+		first, last := src.pick(fakeFirstNames), src.pick(fakeLastNames)
+		return strings.ToLower(fmt.Sprintf("%s.%s%d@%s", first, last, src.intn(1000), src.pick(fakeEmailDomains)))
+	case fakeKindAddress:
+		return fmt.Sprintf("%d %s %s", 1+src.intn(9999), src.pick(fakeStreets), src.pick(fakeStreetSuffixes))
+	case fakeKindPhone:
+		return fmt.Sprintf("+1-%03d-555-01%02d", 200+src.intn(800), src.intn(100))
+	default:
+		return fmt.Sprintf("%s%s%d", src.pick(fakeAdjectives), src.pick(fakeNouns), src.intn(100))
+	}
+}
@@ -0,0 +1,81 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFakeTokenFormat(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenFormat = tokenFormatFake
+	cfg.Patterns = []PatternConfig{
+		{Name: "email", Regex: `\S+@\S+`},
+		{Name: "ssn", Regex: `\b\d{3}-\d{2}-\d{4}\b`, MaskedPrefix: "SSN-"},
+		{Name: "ipv4", Regex: `\b(?:\d{1,3}\.){3}\d{1,3}\b`},
+	}
+	cfg.FakeKinds = map[string]string{
+		"email":           fakeKindEmail,
+		"shipping_street": fakeKindAddress,
+		"phone":           fakeKindPhone,
+	}
+	require.NoError(t, cfg.Validate())
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		category string
+		value    string
+		shape    string
+	}{
+		{category: "email", value: "jdoe@acme.com", shape: `^[a-z]+\.[a-z]+\d{1,3}@example\.(com|net|org)$`},
+		{category: "attribute_shipping_street", value: "1 Infinite Loop", shape: `^\d{1,4} [A-Z][a-z]+ (St|Ave|Rd|Blvd|Ln|Dr|Ct|Way)$`},
+		{category: "attribute_phone", value: "+1 415 555 2671", shape: `^\+1-\d{3}-555-01\d{2}$`},
+		{category: "attribute_user.full_name", value: "Jane Doe", shape: `^[A-Z][a-z]+ [A-Z]\. [A-Z][a-z]+$`},
+		{category: "attribute_user.name", value: "jdoe", shape: `^[a-z]+\d{1,2}$`},
+		// Categories without a kind keep their default shape
+		{category: "ssn", value: "123-45-6789", shape: `^SSN-[0-9a-f]{12}$`},
+		{category: "ipv4", value: "192.168.1.1", shape: `^10\.\d+\.\d+\.\d+$`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.category, func(t *testing.T) {
+			token := m.generateMaskedValue(tc.value, tc.category)
+			assert.Regexp(t, tc.shape, token)
+			assert.Equal(t, token, m.generateMaskedValue(tc.value, tc.category))
+			assert.NotEqual(t, token, m.generateMaskedValue(tc.value+"x", tc.category))
+		})
+	}
+}
+
+func TestFakeTokenCollision(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenFormat = tokenFormatFake
+	m, server := newTestMasker(t, &cfg)
+	ctx := context.Background()
+	category := attributeCategory("user.full_name")
+
+	// Another value already holds the fake name of the value
+	taken := m.generateMaskedValue("Jane Doe", category)
+	require.NoError(t, server.Set(UnmaskKey(category, taken), "John Roe"))
+
+	token, err := m.MaskValue(ctx, "Jane Doe", category)
+	require.NoError(t, err)
+	assert.NotEqual(t, taken, token)
+	assert.Equal(t, m.generateAttempt("Jane Doe", category, 1), token)
+	assert.Regexp(t, `^[A-Z][a-z]+ [A-Z]\. [A-Z][a-z]+$`, token)
+
+	// The reverse mapping of the other value is kept
+	original, err := server.Get(UnmaskKey(category, taken))
+	require.NoError(t, err)
+	assert.Equal(t, "John Roe", original)
+	original, err = server.Get(UnmaskKey(category, token))
+	require.NoError(t, err)
+	assert.Equal(t, "Jane Doe", original)
+
+	again, err := m.MaskValue(ctx, "Jane Doe", category)
+	require.NoError(t, err)
+	assert.Equal(t, token, again)
+}
@@ -0,0 +1,70 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fallback paths taken instead of masking a value as configured
+const (
+	// fallbackUnmasked leaves a value unmasked because its token could not be looked up
+	fallbackUnmasked = "unmasked"
+
+	// fallbackDerivedToken returns the derived token when the cached mapping cannot be read
+	fallbackDerivedToken = "derived_token"
+
+	// fallbackMappingNotStored keeps a new token whose mapping could not be
+	// stored, so it cannot be reversed
+	fallbackMappingNotStored = "mapping_not_stored"
+
+	// fallbackDeterministic derives tokens without the store under the latency budget
+	fallbackDeterministic = "deterministic"
+
+	// fallbackStoreUnavailable derives tokens without the store while it is not yet connected
+	fallbackStoreUnavailable = "store_unavailable"
+
+	// fallbackPolicyMask masks a record because the policy could not decide
+	fallbackPolicyMask = "policy_mask"
+
+	// fallbackUnscanned passes the tail of a value past max_scan_bytes through unscanned
+	fallbackUnscanned = "unscanned"
+)
+
+// Causes of fallbacks
+const (
+	causeTimeout           = "timeout"
+	causeConnectionRefused = "connection_refused"
+	causeCircuitOpen       = "circuit_open"
+	causeValidationError   = "validation_error"
+	causeScanLimit         = "scan_limit"
+	causeOther             = "other"
+)
+
+// errUnsupportedDecision is returned for policy decisions other than mask, skip, and drop
+var errUnsupportedDecision = errors.New("unsupported OPA decision")
+
+// fallbackCause classifies err, so on-call can tell an unreachable Redis from a
+// slow one or a broken policy
+func fallbackCause(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, redis.ErrPoolTimeout),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return causeTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return causeConnectionRefused
+	case errors.Is(err, errUnsupportedDecision):
+		return causeValidationError
+	default:
+		return causeOther
+	}
+}
+
+// recordFallback counts a fallback caused by err
+func (m *Masker) recordFallback(ctx context.Context, fallback string, err error) {
+	m.telemetry.recordFallback(ctx, fallback, fallbackCause(err))
+}
@@ -0,0 +1,119 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestFallbackCause(t *testing.T) {
+	testCases := []struct {
+		name  string
+		err   error
+		cause string
+	}{
+		{
+			name:  "context deadline",
+			err:   fmt.Errorf("redis get error: %w", context.DeadlineExceeded),
+			cause: causeTimeout,
+		},
+		{
+			name:  "read timeout",
+			err:   fmt.Errorf("redis get error: %w", &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}),
+			cause: causeTimeout,
+		},
+		{
+			name:  "pool timeout",
+			err:   fmt.Errorf("redis set error: %w", redis.ErrPoolTimeout),
+			cause: causeTimeout,
+		},
+		{
+			name:  "connection refused",
+			err:   fmt.Errorf("redis get error: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+			cause: causeConnectionRefused,
+		},
+		{
+			name:  "unsupported policy decision",
+			err:   fmt.Errorf("%w '%v'", errUnsupportedDecision, 42),
+			cause: causeValidationError,
+		},
+		{
+			name:  "other",
+			err:   errors.New("token store is not initialized"),
+			cause: causeOther,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.cause, fallbackCause(tc.err))
+		})
+	}
+}
+
+// readOnlyStore is a countingStore whose writes time out
+type readOnlyStore struct {
+	countingStore
+}
+
+func (s *readOnlyStore) Set(context.Context, string, string, time.Duration) error {
+	return fmt.Errorf("redis set error: %w", context.DeadlineExceeded)
+}
+
+func TestFallbackMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	cfg := NewDefaultConfig()
+
+	// New tokens whose mapping cannot be written are kept without it
+	m, err := New(&cfg, &readOnlyStore{countingStore{data: map[string]string{}}}, zap.NewNop(), WithMeterProvider(mp))
+	require.NoError(t, err)
+	_, err = m.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+
+	// An unreachable Redis leaves values unmasked
+	server := miniredis.RunT(t)
+	cfg.RedisAddr = server.Addr()
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	defer store.Close()
+	m, err = New(&cfg, store, zap.NewNop(), WithMeterProvider(mp))
+	require.NoError(t, err)
+	server.Close()
+	for range 2 {
+		_, err = m.MaskValue(context.Background(), "192.168.1.2", "ipv4")
+		require.Error(t, err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	fallbacks := map[[2]string]int64{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		if metric.Name != "redismasking.fallbacks" {
+			continue
+		}
+		for _, dp := range metric.Data.(metricdata.Sum[int64]).DataPoints {
+			fallback, _ := dp.Attributes.Value(attribute.Key("fallback"))
+			cause, _ := dp.Attributes.Value(attribute.Key("cause"))
+			fallbacks[[2]string{fallback.AsString(), cause.AsString()}] = dp.Value
+		}
+	}
+	assert.Equal(t, map[[2]string]int64{
+		{fallbackMappingNotStored, causeTimeout}:   1,
+		{fallbackUnmasked, causeConnectionRefused}: 2,
+	}, fallbacks)
+}
@@ -0,0 +1,120 @@
+package masker
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"math/big"
+	"slices"
+)
+
+const (
+	// ff3Radix is the radix of the numerals enciphered by ff3Cipher
+	ff3Radix = 10
+
+	// ff3MinLen is the fewest decimal numerals FF3-1 accepts, so that at least
+	// a million values can be enciphered
+	ff3MinLen = 6
+
+	// ff3MaxLen is the most decimal numerals FF3-1 accepts, 2*floor(96/log2(10))
+	ff3MaxLen = 56
+)
+
+// errFF3Length is returned for numeral strings FF3-1 cannot encipher
+var errFF3Length = errors.New("ff3 numeral string length is out of range")
+
+// ff3Cipher enciphers strings of decimal numerals with FF3-1, the format
+// preserving encryption of NIST SP 800-38G Revision 1
+type ff3Cipher struct {
+	block cipher.Block
+}
+
+// newFF3Cipher creates a cipher with a 16, 24, or 32 byte AES key
+func newFF3Cipher(key []byte) (*ff3Cipher, error) {
+	// FF3-1 applies AES with the bytes of the key reversed
+	block, err := aes.NewCipher(reverseBytes(key))
+	if err != nil {
+		return nil, err
+	}
+	return &ff3Cipher{block: block}, nil
+}
+
+// encrypt enciphers the decimal numerals x, each between 0 and 9, under the
+// 56-bit tweak
+func (c *ff3Cipher) encrypt(x []byte, tweak [7]byte) ([]byte, error) {
+	// The 56-bit tweak is split into two 32-bit halves
+	tl := [4]byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xf0}
+	tr := [4]byte{tweak[4], tweak[5], tweak[6], tweak[3] << 4}
+	return c.encryptHalves(x, tl, tr)
+}
+
+// encryptHalves runs the eight Feistel rounds of FF3 with the tweak halves
+func (c *ff3Cipher) encryptHalves(x []byte, tl, tr [4]byte) ([]byte, error) {
+	n := len(x)
+	if n < ff3MinLen || n > ff3MaxLen {
+		return nil, errFF3Length
+	}
+	u := (n + 1) / 2
+	a, b := slices.Clone(x[:u]), slices.Clone(x[u:])
+
+	radix := big.NewInt(ff3Radix)
+	modU := new(big.Int).Exp(radix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(radix, big.NewInt(int64(n-u)), nil)
+
+	var p [16]byte
+	for i := byte(0); i < 8; i++ {
+		w, mod, m := tr, modU, u
+		if i%2 == 1 {
+			w, mod, m = tl, modV, n-u
+		}
+
+		// P is W xor the round number, followed by the reversed B as a 96-bit number
+		copy(p[:4], w[:])
+		p[3] ^= i
+		clear(p[4:])
+		numReversed(b).FillBytes(p[4:])
+
+		// S = REVB(CIPH(REVB(P)))
+		slices.Reverse(p[:])
+		c.block.Encrypt(p[:], p[:])
+		slices.Reverse(p[:])
+
+		y := new(big.Int).SetBytes(p[:])
+		y.Add(y, numReversed(a))
+		y.Mod(y, mod)
+		a, b = b, strReversed(y, m)
+	}
+	return append(a, b...), nil
+}
+
+// numReversed returns the number whose decimal numerals, least significant
+// first, are x
+func numReversed(x []byte) *big.Int {
+	n := new(big.Int)
+	radix := big.NewInt(ff3Radix)
+	for i := len(x) - 1; i >= 0; i-- {
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(x[i])))
+	}
+	return n
+}
+
+// strReversed returns the m decimal numerals of n, least significant first
+func strReversed(n *big.Int, m int) []byte {
+	x := make([]byte, m)
+	n = new(big.Int).Set(n)
+	radix := big.NewInt(ff3Radix)
+	digit := new(big.Int)
+	for i := range x {
+		n.DivMod(n, radix, digit)
+		x[i] = byte(digit.Int64())
+	}
+	return x
+}
+
+// reverseBytes returns a reversed copy of b
+func reverseBytes(b []byte) []byte {
+	r := slices.Clone(b)
+	slices.Reverse(r)
+	return r
+}
@@ -0,0 +1,37 @@
+package masker
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// numerals converts a string of decimal digits to numerals
+func numerals(s string) []byte {
+	x := []byte(s)
+	for i := range x {
+		x[i] -= '0'
+	}
+	return x
+}
+
+func TestFF3Cipher(t *testing.T) {
+	key, err := hex.DecodeString("EF4359D8D580AA4F7F036D6F04FC6A94")
+	require.NoError(t, err)
+	c, err := newFF3Cipher(key)
+	require.NoError(t, err)
+
+	// The FF3 sample of NIST with its 64-bit tweak split into halves
+	x, err := c.encryptHalves(numerals("890121234567890000"), [4]byte{0xD8, 0xE7, 0x92, 0x0A}, [4]byte{0xFA, 0x33, 0x0A, 0x73})
+	require.NoError(t, err)
+	require.Equal(t, numerals("750918814058654607"), x)
+
+	// The same sample under FF3-1 with the 56-bit tweak
+	x, err = c.encrypt(numerals("890121234567890000"), [7]byte{0xD8, 0xE7, 0x92, 0x0A, 0xFA, 0x33, 0x0A})
+	require.NoError(t, err)
+	require.Equal(t, numerals("477064185124354662"), x)
+
+	_, err = c.encrypt(numerals("12345"), [7]byte{})
+	require.ErrorIs(t, err, errFF3Length)
+}
@@ -0,0 +1,386 @@
+package masker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// FileStoreConfig locates and bounds the file store
+type FileStoreConfig struct {
+	// Path is the database file, created when missing
+	Path string `mapstructure:"path"`
+
+	// MaxEntries is the number of store entries kept, evicting the oldest ones.
+	// Each mapping takes two entries. 0 is unlimited.
+	MaxEntries int `mapstructure:"max_entries"`
+
+	// CompactionInterval is how often expired entries are removed and the file
+	// is rewritten to release the space of removed entries (0 = never)
+	CompactionInterval time.Duration `mapstructure:"compaction_interval"`
+}
+
+// Validate checks that the file is set and the bounds are non-negative
+func (cfg *FileStoreConfig) Validate() error {
+	if cfg.Path == "" {
+		return errors.New("file_store path is required")
+	}
+	if cfg.MaxEntries < 0 {
+		return errors.New("file_store max_entries must be non-negative")
+	}
+	if cfg.CompactionInterval < 0 {
+		return errors.New("file_store compaction_interval must be non-negative")
+	}
+	return nil
+}
+
+var (
+	// fileEntriesBucket maps keys to their expiry, sequence, and value
+	fileEntriesBucket = []byte("entries")
+
+	// fileOrderBucket maps sequences to keys, oldest first
+	fileOrderBucket = []byte("order")
+
+	// fileMetaBucket holds the entry count
+	fileMetaBucket = []byte("meta")
+	fileCountKey   = []byte("count")
+)
+
+// fileEntryHeader is the size of the expiry and sequence preceding entry values
+const fileEntryHeader = 16
+
+// fileStore is a Store that keeps mappings in a local database file, so edge
+// agents keep their mappings across restarts without Redis
+type fileStore struct {
+	config *FileStoreConfig
+	logger *zap.Logger
+	now    func() time.Time
+
+	// mu is held exclusively while the file is rewritten
+	mu sync.RWMutex
+	db *bbolt.DB
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var (
+	_ Store          = (*fileStore)(nil)
+	_ MappingCreator = (*fileStore)(nil)
+)
+
+// NewFileStore opens the file store described by cfg, and compacts it in the
+// background every compaction interval
+func NewFileStore(cfg *FileStoreConfig, logger *zap.Logger) (Store, error) {
+	db, err := openFileStore(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &fileStore{
+		config: cfg,
+		logger: logger,
+		now:    time.Now,
+		db:     db,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if cfg.CompactionInterval > 0 {
+		go s.compactLoop()
+	} else {
+		close(s.done)
+	}
+	return s, nil
+}
+
+// openFileStore opens the database at path and creates its buckets
+func openFileStore(path string) (*bbolt.DB, error) {
+	// Another collector holding the file fails the start instead of blocking it
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file store: %w", err)
+	}
+	// Writes of concurrent pipelines are committed together, while a single
+	// pipeline only waits briefly for others to join
+	db.MaxBatchDelay = time.Millisecond
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{fileEntriesBucket, fileOrderBucket, fileMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize file store: %w", err)
+	}
+	return db, nil
+}
+
+// Get returns the value stored under key, unless it expired
+func (s *fileStore) Get(_ context.Context, key string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var value string
+	var found bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		value, found = s.get(tx, key)
+		return nil
+	})
+	return value, found, err
+}
+
+// Set stores value under key. Concurrent writes are committed together.
+func (s *fileStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.db.Batch(func(tx *bbolt.Tx) error {
+		return s.put(tx, key, value, ttl)
+	})
+}
+
+// CreateMapping stores both directions of a mapping unless maskKey holds a token
+func (s *fileStore) CreateMapping(_ context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var winner string
+	var created bool
+	err := s.db.Batch(func(tx *bbolt.Tx) error {
+		if existing, ok := s.get(tx, maskKey); ok {
+			winner, created = existing, false
+			return nil
+		}
+		if holder, ok := s.get(tx, unmaskKey); ok && holder != original {
+			return errTokenTaken
+		}
+		if err := s.put(tx, maskKey, token, ttl); err != nil {
+			return err
+		}
+		if err := s.put(tx, unmaskKey, original, ttl); err != nil {
+			return err
+		}
+		winner, created = token, true
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return winner, created, nil
+}
+
+// Close stops the compaction and closes the file
+func (s *fileStore) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Close()
+}
+
+// get returns the value of key unless it expired. Expired entries are removed
+// by the compaction.
+func (s *fileStore) get(tx *bbolt.Tx, key string) (string, bool) {
+	data := tx.Bucket(fileEntriesBucket).Get([]byte(key))
+	if data == nil || s.expired(data) {
+		return "", false
+	}
+	return string(data[fileEntryHeader:]), true
+}
+
+// expired reports whether the entry data has expired
+func (s *fileStore) expired(data []byte) bool {
+	expiry := int64(binary.BigEndian.Uint64(data))
+	return expiry != 0 && s.now().UnixNano() >= expiry
+}
+
+// put stores value under key until ttl has passed, and evicts the oldest
+// entries beyond the max entries
+func (s *fileStore) put(tx *bbolt.Tx, key, value string, ttl time.Duration) error {
+	entries := tx.Bucket(fileEntriesBucket)
+	order := tx.Bucket(fileOrderBucket)
+
+	added := uint64(1)
+	if old := entries.Get([]byte(key)); old != nil {
+		if err := order.Delete(old[8:fileEntryHeader]); err != nil {
+			return err
+		}
+		added = 0
+	}
+
+	seq, err := order.NextSequence()
+	if err != nil {
+		return err
+	}
+	var expiry int64
+	if ttl > 0 {
+		expiry = s.now().Add(ttl).UnixNano()
+	}
+	data := make([]byte, fileEntryHeader+len(value))
+	binary.BigEndian.PutUint64(data, uint64(expiry))
+	binary.BigEndian.PutUint64(data[8:], seq)
+	copy(data[fileEntryHeader:], value)
+
+	if err := entries.Put([]byte(key), data); err != nil {
+		return err
+	}
+	if err := order.Put(data[8:fileEntryHeader], []byte(key)); err != nil {
+		return err
+	}
+
+	count := fileCount(tx) + added
+	if s.config.MaxEntries > 0 {
+		for count > uint64(s.config.MaxEntries) {
+			// The cursor is moved to the first entry again after every deletion
+			oldest, oldestKey := order.Cursor().First()
+			if oldest == nil {
+				break
+			}
+			if err := entries.Delete(oldestKey); err != nil {
+				return err
+			}
+			if err := order.Delete(oldest); err != nil {
+				return err
+			}
+			count--
+		}
+	}
+	return setFileCount(tx, count)
+}
+
+// fileCount returns the number of entries
+func fileCount(tx *bbolt.Tx) uint64 {
+	data := tx.Bucket(fileMetaBucket).Get(fileCountKey)
+	if data == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// setFileCount stores the number of entries
+func setFileCount(tx *bbolt.Tx, count uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, count)
+	return tx.Bucket(fileMetaBucket).Put(fileCountKey, data)
+}
+
+// compactLoop compacts the file every compaction interval until the store is closed
+func (s *fileStore) compactLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.CompactionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.compact(); err != nil {
+				s.logger.Warn("Failed to compact file store", zap.Error(err))
+			}
+		}
+	}
+}
+
+// compact removes the expired entries, and rewrites the file once at least
+// half of it is free pages, since the file never shrinks otherwise
+func (s *fileStore) compact() error {
+	s.mu.RLock()
+	err := s.removeExpired()
+	rewrite := err == nil && s.fragmented()
+	s.mu.RUnlock()
+
+	if err != nil || !rewrite {
+		return err
+	}
+	return s.rewrite()
+}
+
+// removeExpired deletes every expired entry
+func (s *fileStore) removeExpired() error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		entries := tx.Bucket(fileEntriesBucket)
+		order := tx.Bucket(fileOrderBucket)
+
+		// Keys are collected first, since deleting moves the cursor
+		var expired [][]byte
+		err := entries.ForEach(func(key, data []byte) error {
+			if s.expired(data) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		if err != nil || len(expired) == 0 {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := order.Delete(entries.Get(key)[8:fileEntryHeader]); err != nil {
+				return err
+			}
+			if err := entries.Delete(key); err != nil {
+				return err
+			}
+		}
+		return setFileCount(tx, fileCount(tx)-uint64(len(expired)))
+	})
+}
+
+// fragmented reports whether at least half of the file is free pages
+func (s *fileStore) fragmented() bool {
+	info, err := os.Stat(s.config.Path)
+	if err != nil {
+		return false
+	}
+	stats := s.db.Stats()
+	free := int64(stats.FreePageN+stats.PendingPageN) * int64(s.db.Info().PageSize)
+	return free*2 >= info.Size()
+}
+
+// rewrite copies the live entries to a new file that replaces the current one
+func (s *fileStore) rewrite() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	compactPath := s.config.Path + ".compact"
+	dst, err := bbolt.Open(compactPath, 0o600, nil)
+	if err != nil {
+		return fmt.Errorf("failed to compact file store: %w", err)
+	}
+	if err := bbolt.Compact(dst, s.db, 0); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(compactPath)
+		return fmt.Errorf("failed to compact file store: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(compactPath)
+		return fmt.Errorf("failed to compact file store: %w", err)
+	}
+
+	if err := s.db.Close(); err != nil {
+		return fmt.Errorf("failed to compact file store: %w", err)
+	}
+	if err := os.Rename(compactPath, s.config.Path); err != nil {
+		// The current file is still complete, so it is used again
+		_ = os.Remove(compactPath)
+		err = fmt.Errorf("failed to compact file store: %w", err)
+	}
+	db, openErr := openFileStore(s.config.Path)
+	if openErr != nil {
+		return openErr
+	}
+	s.db = db
+	return err
+}
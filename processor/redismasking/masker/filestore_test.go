@@ -0,0 +1,140 @@
+package masker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// newTestFileStore opens a file store in a temporary directory
+func newTestFileStore(t *testing.T, cfg *FileStoreConfig) *fileStore {
+	t.Helper()
+
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "tokens.db")
+	}
+	store, err := NewFileStore(cfg, zap.NewNop())
+	require.NoError(t, err)
+	return store.(*fileStore)
+}
+
+func TestFileStore(t *testing.T) {
+	cfg := &FileStoreConfig{}
+	store := newTestFileStore(t, cfg)
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "a", "1", 0))
+	value, found, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "1", value)
+
+	_, found, err = store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Entries are kept across restarts
+	require.NoError(t, store.Close())
+	store = newTestFileStore(t, cfg)
+	defer store.Close()
+	value, found, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "1", value)
+}
+
+func TestFileStoreMaxEntries(t *testing.T) {
+	store := newTestFileStore(t, &FileStoreConfig{MaxEntries: 2})
+	defer store.Close()
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "a", "1", 0))
+	require.NoError(t, store.Set(ctx, "b", "2", 0))
+	// Overwriting an entry does not add one, and makes it the newest
+	require.NoError(t, store.Set(ctx, "a", "3", 0))
+	require.NoError(t, store.Set(ctx, "c", "4", 0))
+
+	_, found, err := store.Get(ctx, "b")
+	require.NoError(t, err)
+	require.False(t, found)
+	for key, expected := range map[string]string{"a": "3", "c": "4"} {
+		value, found, err := store.Get(ctx, key)
+		require.NoError(t, err)
+		require.True(t, found, key)
+		require.Equal(t, expected, value)
+	}
+}
+
+func TestFileStoreCreateMapping(t *testing.T) {
+	store := newTestFileStore(t, &FileStoreConfig{})
+	defer store.Close()
+	ctx := context.Background()
+
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+	winner, created, err := store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", 0)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	// The first token wins
+	winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", 0)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+
+	// A token held by another value is refused
+	_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", 0)
+	require.ErrorIs(t, err, errTokenTaken)
+	_, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.9"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	cfg := &FileStoreConfig{}
+	store := newTestFileStore(t, cfg)
+	defer store.Close()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	value := string(make([]byte, 4096))
+	for i := 0; i < 200; i++ {
+		require.NoError(t, store.Set(ctx, fmt.Sprintf("expiring-%d", i), value, time.Minute))
+	}
+	require.NoError(t, store.Set(ctx, "kept", "1", 0))
+	before, err := os.Stat(cfg.Path)
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	_, found, err := store.Get(ctx, "expiring-0")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Expired entries are removed and their space is released
+	require.NoError(t, store.compact())
+	after, err := os.Stat(cfg.Path)
+	require.NoError(t, err)
+	require.Less(t, after.Size(), before.Size()/2)
+
+	value, found, err = store.Get(ctx, "kept")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "1", value)
+	require.NoError(t, store.db.View(func(tx *bbolt.Tx) error {
+		require.Equal(t, uint64(1), fileCount(tx))
+		return nil
+	}))
+}
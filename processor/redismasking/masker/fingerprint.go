@@ -0,0 +1,227 @@
+package masker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// EffectivePolicy is the fully resolved configuration deciding what is masked
+// and how: semantic convention fields are added to the masked fields, pattern
+// packs are expanded, and mode defaults are applied. Connection, cache, and
+// telemetry settings are left out so agents with the same policy share a
+// fingerprint regardless of where they run, and the HMAC key is left out so
+// neither the policy nor its fingerprint reveals anything about it.
+type EffectivePolicy struct {
+	// Fingerprint is a hex encoded SHA-256 hash of the rest of the policy
+	Fingerprint string `json:"fingerprint"`
+
+	FieldsToMask           []string                  `json:"fields_to_mask"`
+	ScanAllAttributes      bool                      `json:"scan_all_attributes"`
+	ExcludeKeys            []string                  `json:"exclude_keys"`
+	ResourceFieldsToMask   []string                  `json:"resource_fields_to_mask"`
+	ScanResourceAttributes bool                      `json:"scan_resource_attributes"`
+	AccessLogFields        AccessLogConfig           `json:"access_log_fields"`
+	Spans                  SpanConfig                `json:"spans"`
+	Metrics                MetricConfig              `json:"metrics"`
+	StructuredFields       []StructuredFieldConfig   `json:"structured_fields"`
+	BodyMetadataDelimiters []string                  `json:"body_metadata_delimiters"`
+	BodyCharsets           []string                  `json:"body_charsets"`
+	BodyKeys               string                    `json:"body_keys"`
+	MaskedFieldTypes       map[string]string         `json:"masked_field_types"`
+	FieldStrategies        map[string]StrategyConfig `json:"field_strategies"`
+	Patterns               []PatternConfig           `json:"patterns"`
+	MatchFilter            MatchFilterConfig         `json:"match_filter"`
+	ShortCircuit           ShortCircuitConfig        `json:"short_circuit"`
+	PatientNamesSHA256     string                    `json:"patient_names_sha256"`
+	SecretKeys             SecretKeysConfig          `json:"secret_keys"`
+	TokenNamespace         string                    `json:"token_namespace"`
+	TokenFormat            string                    `json:"token_format"`
+	FakeKinds              map[string]string         `json:"fake_kinds"`
+	PreserveIPPrefix       int                       `json:"preserve_ip_prefix"`
+	SaltCheck              string                    `json:"salt_check"`
+	HMACKeyCheck           string                    `json:"hmac_key_check"`
+	FPEKeyCheck            string                    `json:"fpe_key_check"`
+	HashAlgorithm          string                    `json:"hash_algorithm"`
+	TokenLength            int                       `json:"token_length"`
+	Mode                   string                    `json:"mode"`
+	ReservedNamespaces     ReservedNamespacesConfig  `json:"reserved_namespaces"`
+	MaxScanBytes           int                       `json:"max_scan_bytes"`
+	ScanOverflow           string                    `json:"scan_overflow"`
+	LatencyBudget          EffectiveLatencyBudget    `json:"latency_budget"`
+	Provenance             ProvenanceConfig          `json:"provenance"`
+	OPA                    EffectiveOPA              `json:"opa"`
+	DetectOnly             DetectOnlyConfig          `json:"detect_only"`
+	CardholderData         CardholderDataConfig      `json:"cardholder_data"`
+	RecordFlags            RecordFlagsConfig         `json:"record_flags"`
+}
+
+// EffectiveLatencyBudget is the latency budget of an EffectivePolicy
+type EffectiveLatencyBudget struct {
+	Budget       string   `json:"budget"`
+	DegradeAfter int      `json:"degrade_after"`
+	RecoverAfter int      `json:"recover_after"`
+	Steps        []string `json:"steps"`
+}
+
+// EffectiveOPA is the policy hook of an EffectivePolicy
+type EffectiveOPA struct {
+	PolicyFile     string   `json:"policy_file"`
+	PolicySHA256   string   `json:"policy_sha256"`
+	Query          string   `json:"query"`
+	Destination    string   `json:"destination"`
+	AuthAttributes []string `json:"auth_attributes"`
+	MetadataKeys   []string `json:"metadata_keys"`
+}
+
+// EffectivePolicy resolves the configuration into the policy that is applied.
+// Disabled sections are left empty. Patterns are listed in evaluation order,
+// including the patterns of the enabled packs, while lists whose order has no
+// effect are sorted.
+func (cfg *Config) EffectivePolicy() (*EffectivePolicy, error) {
+	policy := &EffectivePolicy{
+		FieldsToMask:           sorted(cfg.effectiveFieldsToMask()),
+		ScanAllAttributes:      cfg.ScanAllAttributes,
+		ExcludeKeys:            sorted(cfg.ExcludeKeys),
+		ResourceFieldsToMask:   sorted(cfg.ResourceFieldsToMask),
+		ScanResourceAttributes: cfg.ScanResourceAttributes,
+		Spans:                  cfg.Spans,
+		Metrics:                cfg.Metrics,
+		StructuredFields:       make([]StructuredFieldConfig, 0, len(cfg.StructuredFields)),
+		BodyMetadataDelimiters: append([]string{}, cfg.BodyMetadataDelimiters...),
+		BodyCharsets:           append([]string{}, cfg.BodyCharsets...),
+		BodyKeys:               cfg.BodyKeys,
+		MaskedFieldTypes:       cfg.MaskedFieldTypes,
+		FieldStrategies:        cfg.FieldStrategies,
+		Patterns:               append([]PatternConfig{}, cfg.effectivePatterns()...),
+		TokenNamespace:         cfg.TokenNamespace,
+		TokenFormat:            cfg.TokenFormat,
+		FakeKinds:              cfg.FakeKinds,
+		PreserveIPPrefix:       cfg.PreserveIPPrefix,
+		SaltCheck:              saltCheck(cfg.Salt),
+		FPEKeyCheck:            checkValue("fpe key", cfg.FPEKey),
+		HashAlgorithm:          cfg.hashAlgorithm(),
+		TokenLength:            cfg.TokenLength,
+		Mode:                   cfg.Mode,
+		ReservedNamespaces: ReservedNamespacesConfig{
+			CIDRs:   sorted(cfg.ReservedNamespaces.CIDRs),
+			Domains: sorted(cfg.ReservedNamespaces.Domains),
+		},
+		MaxScanBytes: cfg.effectiveMaxScanBytes(),
+		ScanOverflow: cfg.scanOverflow(),
+	}
+	for _, field := range cfg.StructuredFields {
+		policy.StructuredFields = append(policy.StructuredFields, StructuredFieldConfig{
+			Strategy: field.Strategy,
+			Keys:     sorted(field.Keys),
+			Members:  sorted(field.Members),
+		})
+	}
+	if cfg.AccessLogFields.Enabled {
+		policy.AccessLogFields = AccessLogConfig{
+			Enabled:  true,
+			Keys:     sorted(cfg.AccessLogFields.Keys),
+			MaxBytes: cfg.AccessLogFields.MaxBytes,
+		}
+	}
+	if cfg.SecretKeys.Enabled {
+		policy.SecretKeys = cfg.SecretKeys
+	}
+	if cfg.LatencyBudget.Enabled() {
+		policy.LatencyBudget = EffectiveLatencyBudget{
+			Budget:       cfg.LatencyBudget.Budget.String(),
+			DegradeAfter: cfg.LatencyBudget.DegradeAfter,
+			RecoverAfter: cfg.LatencyBudget.RecoverAfter,
+			Steps:        cfg.LatencyBudget.Steps,
+		}
+	}
+	if cfg.Provenance.Enabled() {
+		policy.Provenance = cfg.Provenance
+	}
+	if cfg.DetectOnly.Enabled {
+		policy.DetectOnly = cfg.DetectOnly
+	}
+	if cfg.CardholderData.Enabled {
+		policy.CardholderData = cfg.CardholderData
+	}
+	if cfg.RecordFlags.Enabled() {
+		policy.RecordFlags = cfg.RecordFlags
+	}
+	if cfg.MatchFilter.Enabled() {
+		policy.MatchFilter = cfg.MatchFilter
+	}
+	if cfg.ShortCircuit.Enabled() {
+		policy.ShortCircuit = cfg.ShortCircuit
+	}
+
+	for _, pattern := range policy.Patterns {
+		if pattern.namesFile == "" {
+			continue
+		}
+		// The names are hashed so the policy never lists them
+		// #nosec G304 -- the names file is provided by the collector configuration
+		names, err := os.ReadFile(pattern.namesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read patient names: %w", err)
+		}
+		sum := sha256.Sum256(names)
+		policy.PatientNamesSHA256 = hex.EncodeToString(sum[:])
+	}
+
+	// The key file is read so agents with different keys in their files differ too
+	hmacKey, err := cfg.loadHMACKey()
+	if err != nil {
+		return nil, err
+	}
+	policy.HMACKeyCheck = checkValue("hmac key", string(hmacKey))
+
+	if cfg.OPA.Enabled() {
+		// #nosec G304 -- the policy file is provided by the collector configuration
+		module, err := os.ReadFile(cfg.OPA.PolicyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OPA policy: %w", err)
+		}
+		sum := sha256.Sum256(module)
+		policy.OPA = EffectiveOPA{
+			PolicyFile:     cfg.OPA.PolicyFile,
+			PolicySHA256:   hex.EncodeToString(sum[:]),
+			Query:          cfg.OPA.Query,
+			Destination:    cfg.OPA.Destination,
+			AuthAttributes: sorted(cfg.OPA.AuthAttributes),
+			MetadataKeys:   sorted(cfg.OPA.MetadataKeys),
+		}
+	}
+
+	// The OPA policy is hashed by content rather than by path
+	hashed := *policy
+	hashed.OPA.PolicyFile = ""
+	data, err := json.Marshal(hashed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode fingerprint: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	policy.Fingerprint = hex.EncodeToString(sum[:])
+	return policy, nil
+}
+
+// Fingerprint returns the fingerprint of the effective policy. Agents masking
+// the same way report the same fingerprint, so comparing fingerprints across a
+// fleet reveals configuration drift.
+func (cfg *Config) Fingerprint() (string, error) {
+	policy, err := cfg.EffectivePolicy()
+	if err != nil {
+		return "", err
+	}
+	return policy.Fingerprint, nil
+}
+
+// sorted returns a sorted copy of values that is never nil, so unset and
+// empty lists hash the same
+func sorted(values []string) []string {
+	values = append([]string{}, values...)
+	slices.Sort(values)
+	return values
+}
@@ -0,0 +1,163 @@
+package masker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(cfg Config) string {
+		t.Helper()
+		fp, err := cfg.Fingerprint()
+		require.NoError(t, err)
+		return fp
+	}
+
+	base := NewDefaultConfig()
+	base.FieldsToMask = []string{"user.id", "password"}
+	baseline := fingerprint(base)
+	assert.Len(t, baseline, 64)
+
+	// Settings that do not change what is masked keep the fingerprint
+	same := NewDefaultConfig()
+	same.FieldsToMask = []string{"password", "user.id"}
+	same.RedisAddr = "redis.internal:6379"
+	same.LocalCacheSize = 1000
+	same.SecretKeys.KeyRegex = "(?i)token"
+	assert.Equal(t, baseline, fingerprint(same))
+
+	changed := NewDefaultConfig()
+	changed.FieldsToMask = []string{"user.id", "password"}
+	changed.PatternPacks = []string{"api_keys"}
+	assert.NotEqual(t, baseline, fingerprint(changed))
+
+	// Pattern order decides which pattern matches first
+	reordered := NewDefaultConfig()
+	reordered.FieldsToMask = []string{"user.id", "password"}
+	reordered.Patterns = []PatternConfig{base.Patterns[1], base.Patterns[0]}
+	assert.NotEqual(t, baseline, fingerprint(reordered))
+
+	// Keys change tokens, so they are covered by check values
+	for _, modify := range []func(cfg *Config){
+		func(cfg *Config) { cfg.HMACKey = "secret" },
+		func(cfg *Config) { cfg.FPEKey = "MDEyMzQ1Njc4OWFiY2RlZg==" },
+	} {
+		keyed := NewDefaultConfig()
+		keyed.FieldsToMask = []string{"user.id", "password"}
+		modify(&keyed)
+		assert.NotEqual(t, baseline, fingerprint(keyed))
+	}
+
+	truncated := NewDefaultConfig()
+	truncated.FieldsToMask = []string{"user.id", "password"}
+	truncated.ScanOverflow = scanOverflowTruncate
+	assert.NotEqual(t, baseline, fingerprint(truncated))
+}
+
+func TestFingerprintOPAPolicy(t *testing.T) {
+	policy := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policy, []byte("package redismasking\n\ndecision := \"mask\"\n"), 0o600))
+
+	cfg := NewDefaultConfig()
+	cfg.OPA.PolicyFile = policy
+	before, err := cfg.Fingerprint()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(policy, []byte("package redismasking\n\ndecision := \"skip\"\n"), 0o600))
+	after, err := cfg.Fingerprint()
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+
+	cfg.OPA.PolicyFile = filepath.Join(t.TempDir(), "missing.rego")
+	_, err = cfg.Fingerprint()
+	require.ErrorContains(t, err, "failed to read OPA policy")
+}
+
+func TestMaskMetricsFingerprintAttribute(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FingerprintAttribute = "masking.fingerprint"
+	m, _ := newTestMasker(t, &cfg)
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty()
+
+	m.MaskMetrics(context.Background(), md)
+
+	fingerprint, ok := rm.Resource().Attributes().Get("masking.fingerprint")
+	require.True(t, ok)
+	expected, err := cfg.Fingerprint()
+	require.NoError(t, err)
+	assert.Equal(t, expected, fingerprint.Str())
+	assert.Equal(t, expected, m.Fingerprint())
+}
+
+func TestEffectivePolicy(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policyFile, []byte("package redismasking\n\ndecision := \"mask\"\n"), 0o600))
+
+	cfg := NewDefaultConfig()
+	cfg.FieldsToMask = []string{"user.id"}
+	cfg.SemconvFieldsEnabled = true
+	cfg.PatternPacks = []string{"api_keys"}
+	cfg.LatencyBudget.Budget = 50 * time.Millisecond
+	cfg.OPA.PolicyFile = policyFile
+
+	policy, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.Contains(t, policy.FieldsToMask, "user.id")
+	assert.Contains(t, policy.FieldsToMask, "enduser.id")
+	assert.Equal(t, "stripe_secret_key", policy.Patterns[0].Name)
+	assert.Equal(t, "hostname", policy.Patterns[len(policy.Patterns)-1].Name)
+	assert.Equal(t, "50ms", policy.LatencyBudget.Budget)
+	assert.Equal(t, policyFile, policy.OPA.PolicyFile)
+	assert.Len(t, policy.OPA.PolicySHA256, 64)
+
+	// Disabled sections are left empty
+	assert.Equal(t, AccessLogConfig{}, policy.AccessLogFields)
+	assert.Equal(t, ProvenanceConfig{}, policy.Provenance)
+
+	// The same policy at another path has the same fingerprint
+	moved := filepath.Join(t.TempDir(), "moved.rego")
+	require.NoError(t, os.Rename(policyFile, moved))
+	cfg.OPA.PolicyFile = moved
+	fingerprint, err := cfg.Fingerprint()
+	require.NoError(t, err)
+	assert.Equal(t, policy.Fingerprint, fingerprint)
+}
+
+func TestEffectivePolicyKeyChecks(t *testing.T) {
+	cfg := NewDefaultConfig()
+	policy, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.Equal(t, "none", policy.HMACKeyCheck)
+	assert.Equal(t, "none", policy.FPEKeyCheck)
+
+	// A key file holding the same key has the same check value
+	cfg.HMACKey = "secret"
+	inline, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.NotEqual(t, "none", inline.HMACKeyCheck)
+	assert.NotContains(t, inline.HMACKeyCheck, "secret")
+
+	path := filepath.Join(t.TempDir(), "hmac_key")
+	require.NoError(t, os.WriteFile(path, []byte("secret\n"), 0o600))
+	cfg.HMACKey = ""
+	cfg.HMACKeyFile = path
+	file, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.Equal(t, inline.HMACKeyCheck, file.HMACKeyCheck)
+	assert.Equal(t, inline.Fingerprint, file.Fingerprint)
+
+	cfg.HMACKeyFile = filepath.Join(t.TempDir(), "missing")
+	_, err = cfg.EffectivePolicy()
+	require.ErrorContains(t, err, "failed to read hmac_key_file")
+}
@@ -0,0 +1,220 @@
+package masker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// firestorePingDocument is read to check the connection. Entries are stored
+// under hashes, so it never exists.
+const firestorePingDocument = "ping"
+
+// FirestoreConfig locates the collection of the firestore store. Requests are
+// authorized with the Application Default Credentials, e.g. the workload
+// identity of the pod on GKE.
+type FirestoreConfig struct {
+	// ProjectID is the project of the database. It is detected from the
+	// credentials when empty.
+	ProjectID string `mapstructure:"project_id"`
+
+	// Database is the ID of the database
+	Database string `mapstructure:"database"`
+
+	// Collection is the collection holding the entries
+	Collection string `mapstructure:"collection"`
+
+	// Timeout bounds every request, including its retries
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks that the database and collection are set and the timeout is positive
+func (cfg *FirestoreConfig) Validate() error {
+	if cfg.Database == "" {
+		return errors.New("firestore database is required")
+	}
+	if cfg.Collection == "" {
+		return errors.New("firestore collection is required")
+	}
+	if cfg.Timeout <= 0 {
+		return errors.New("firestore timeout must be positive")
+	}
+	return nil
+}
+
+// firestoreStore is a Store that keeps mappings in a Firestore collection.
+// Every entry is a document named by the SHA-256 hash of its key, since keys
+// may hold characters that document IDs cannot, and holds the value and, with
+// a TTL, its expiry, so a TTL policy on expires_at can delete expired entries.
+type firestoreStore struct {
+	client     *firestore.Client
+	collection *firestore.CollectionRef
+	timeout    time.Duration
+	now        func() time.Time
+}
+
+// firestoreEntry is a document of the firestore store
+type firestoreEntry struct {
+	Value     string     `firestore:"value"`
+	ExpiresAt *time.Time `firestore:"expires_at,omitempty"`
+}
+
+var (
+	_ Store          = (*firestoreStore)(nil)
+	_ MappingCreator = (*firestoreStore)(nil)
+	_ Pinger         = (*firestoreStore)(nil)
+)
+
+// NewFirestoreStore creates a store on the collection of cfg. The client
+// connects to the emulator named by FIRESTORE_EMULATOR_HOST when it is set.
+func NewFirestoreStore(cfg *FirestoreConfig) (Store, error) {
+	projectID := cfg.ProjectID
+	if projectID == "" {
+		projectID = firestore.DetectProjectID
+	}
+	client, err := firestore.NewClientWithDatabase(context.Background(), projectID, cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Firestore client: %w", err)
+	}
+	return &firestoreStore{
+		client:     client,
+		collection: client.Collection(cfg.Collection),
+		timeout:    cfg.Timeout,
+		now:        time.Now,
+	}, nil
+}
+
+// Ping checks that the collection can be read with the credentials
+func (s *firestoreStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.collection.Doc(firestorePingDocument).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to read Firestore collection '%s': %w", s.collection.ID, err)
+	}
+	return nil
+}
+
+// Get returns the value stored under key. Expired documents are not found,
+// even before a TTL policy deletes them.
+func (s *firestoreStore) Get(ctx context.Context, key string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	snapshot, err := s.doc(key).Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return "", false, fmt.Errorf("firestore get error: %w", err)
+	}
+	return s.value(snapshot)
+}
+
+// Set stores value under key
+func (s *firestoreStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.doc(key).Set(ctx, s.entry(value, ttl)); err != nil {
+		return fmt.Errorf("firestore set error: %w", err)
+	}
+	return nil
+}
+
+// CreateMapping writes the token under maskKey and the original value under
+// unmaskKey in a transaction that first reads both keys, so the token of the
+// first collector wins and the reverse mapping of another value is never
+// replaced. Firestore retries the transaction when another collector writes
+// either key first.
+func (s *firestoreStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var winner string
+	var created bool
+	err := s.client.RunTransaction(ctx, func(_ context.Context, tx *firestore.Transaction) error {
+		maskDoc := s.doc(maskKey)
+		snapshot, err := tx.Get(maskDoc)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		value, found, err := s.value(snapshot)
+		if err != nil {
+			return err
+		}
+		if found {
+			winner, created = value, false
+			return nil
+		}
+		unmaskDoc := s.doc(unmaskKey)
+		snapshot, err = tx.Get(unmaskDoc)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		holder, found, err := s.value(snapshot)
+		if err != nil {
+			return err
+		}
+		if found && holder != original {
+			return errTokenTaken
+		}
+
+		if err := tx.Set(maskDoc, s.entry(token, ttl)); err != nil {
+			return err
+		}
+		if err := tx.Set(unmaskDoc, s.entry(original, ttl)); err != nil {
+			return err
+		}
+		winner, created = token, true
+		return nil
+	})
+	if errors.Is(err, errTokenTaken) {
+		return "", false, err
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("firestore create mapping error: %w", err)
+	}
+	return winner, created, nil
+}
+
+// Close closes the connection of the client
+func (s *firestoreStore) Close() error {
+	return s.client.Close()
+}
+
+// doc returns the document of key
+func (s *firestoreStore) doc(key string) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(key))
+	return s.collection.Doc(hex.EncodeToString(sum[:]))
+}
+
+// entry returns the document storing value until ttl has passed
+func (s *firestoreStore) entry(value string, ttl time.Duration) firestoreEntry {
+	entry := firestoreEntry{Value: value}
+	if ttl > 0 {
+		expiresAt := s.now().Add(ttl)
+		entry.ExpiresAt = &expiresAt
+	}
+	return entry
+}
+
+// value returns the value of snapshot unless it is missing or expired
+func (s *firestoreStore) value(snapshot *firestore.DocumentSnapshot) (string, bool, error) {
+	if snapshot == nil || !snapshot.Exists() {
+		return "", false, nil
+	}
+	var entry firestoreEntry
+	if err := snapshot.DataTo(&entry); err != nil {
+		return "", false, fmt.Errorf("failed to decode Firestore document '%s': %w", snapshot.Ref.ID, err)
+	}
+	if entry.ExpiresAt != nil && !s.now().Before(*entry.ExpiresAt) {
+		return "", false, nil
+	}
+	return entry.Value, true, nil
+}
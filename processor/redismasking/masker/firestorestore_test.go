@@ -0,0 +1,146 @@
+package masker
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeFirestore is an in-memory Firestore server serving document reads,
+// writes, and transactions
+type fakeFirestore struct {
+	firestorepb.UnimplementedFirestoreServer
+
+	mu        sync.Mutex
+	documents map[string]*firestorepb.Document
+}
+
+func (f *fakeFirestore) BatchGetDocuments(req *firestorepb.BatchGetDocumentsRequest, stream firestorepb.Firestore_BatchGetDocumentsServer) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, name := range req.Documents {
+		resp := &firestorepb.BatchGetDocumentsResponse{ReadTime: timestamppb.Now()}
+		if doc, ok := f.documents[name]; ok {
+			resp.Result = &firestorepb.BatchGetDocumentsResponse_Found{Found: doc}
+		} else {
+			resp.Result = &firestorepb.BatchGetDocumentsResponse_Missing{Missing: name}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeFirestore) BeginTransaction(context.Context, *firestorepb.BeginTransactionRequest) (*firestorepb.BeginTransactionResponse, error) {
+	return &firestorepb.BeginTransactionResponse{Transaction: []byte("tx")}, nil
+}
+
+func (f *fakeFirestore) Rollback(context.Context, *firestorepb.RollbackRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func (f *fakeFirestore) Commit(_ context.Context, req *firestorepb.CommitRequest) (*firestorepb.CommitResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := timestamppb.Now()
+	resp := &firestorepb.CommitResponse{CommitTime: now}
+	for _, write := range req.Writes {
+		doc := write.GetUpdate()
+		f.documents[doc.Name] = &firestorepb.Document{Name: doc.Name, Fields: doc.Fields, CreateTime: now, UpdateTime: now}
+		resp.WriteResults = append(resp.WriteResults, &firestorepb.WriteResult{UpdateTime: now})
+	}
+	return resp, nil
+}
+
+// newTestFirestoreStore returns a store on a fake Firestore server and a fixed clock
+func newTestFirestoreStore(t *testing.T) (*firestoreStore, *fakeFirestore) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	fake := &fakeFirestore{documents: make(map[string]*firestorepb.Document)}
+	server := grpc.NewServer()
+	firestorepb.RegisterFirestoreServer(server, fake)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	t.Setenv("FIRESTORE_EMULATOR_HOST", listener.Addr().String())
+	cfg := NewDefaultConfig().Firestore
+	cfg.ProjectID = "test"
+	store, err := NewFirestoreStore(&cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	s := store.(*firestoreStore)
+	s.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	return s, fake
+}
+
+func TestFirestoreStore(t *testing.T) {
+	store, fake := newTestFirestoreStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, store.Ping(ctx))
+
+	_, found, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, store.Set(ctx, "mask:ipv4:192.168.1.1/24", "10.1.2.3", time.Minute))
+	value, found, err := store.Get(ctx, "mask:ipv4:192.168.1.1/24")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "10.1.2.3", value)
+
+	// Documents are named by the hash of their key
+	for name, doc := range fake.documents {
+		require.NotContains(t, name, "192.168.1.1")
+		require.Equal(t, int64(1_700_000_060), doc.Fields["expires_at"].GetTimestampValue().GetSeconds())
+	}
+
+	store.now = func() time.Time { return time.Unix(1_700_000_060, 0) }
+	_, found, err = store.Get(ctx, "mask:ipv4:192.168.1.1/24")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestFirestoreStoreCreateMapping(t *testing.T) {
+	store, fake := newTestFirestoreStore(t)
+	ctx := context.Background()
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+
+	winner, created, err := store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", 0)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+	require.Len(t, fake.documents, 2)
+
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+
+	// A live mapping keeps its token and writes nothing
+	winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", 0)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+	require.Len(t, fake.documents, 2)
+
+	// A token held by another value is refused
+	_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", 0)
+	require.ErrorIs(t, err, errTokenTaken)
+	_, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.9"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
@@ -0,0 +1,116 @@
+package masker
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+const (
+	// luhnMinDigits and luhnMaxDigits bound the lengths of card numbers, whose
+	// check digit is kept valid
+	luhnMinDigits = 13
+	luhnMaxDigits = 19
+)
+
+// fpeKey decodes the fpe_key
+func (cfg *Config) fpeKey() ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(cfg.FPEKey)
+	if err != nil {
+		return nil, fmt.Errorf("fpe_key is not valid base64: %w", err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, errors.New("fpe_key must decode to 16, 24, or 32 bytes")
+	}
+}
+
+// usesFPE reports whether the default or a pattern's token format is fpe
+func (cfg *Config) usesFPE() bool {
+	if cfg.TokenFormat == tokenFormatFPE {
+		return true
+	}
+	for _, pattern := range cfg.Patterns {
+		if pattern.TokenFormat == tokenFormatFPE {
+			return true
+		}
+	}
+	return false
+}
+
+// fpeToken enciphers the digits of originalValue and keeps every other
+// character in place, so an SSN stays ###-##-#### and a card number keeps its
+// length and separators. Card numbers passing the Luhn check get a valid
+// check digit again. Values with fewer than 6 or more than 56 digits cannot be
+// enciphered and are reported as such.
+func (m *Masker) fpeToken(originalValue, category string) (string, bool) {
+	var digits []byte
+	for i := 0; i < len(originalValue); i++ {
+		if c := originalValue[i]; c >= '0' && c <= '9' {
+			digits = append(digits, c-'0')
+		}
+	}
+
+	// The check digit is recomputed instead of enciphered
+	luhn := len(digits) >= luhnMinDigits && len(digits) <= luhnMaxDigits && validLuhn(originalValue)
+	payload := digits
+	if luhn {
+		payload = digits[:len(digits)-1]
+	}
+
+	// Every namespaced category and salt enciphers under its own tweak, like the
+	// seed of derived tokens
+	sum := sha256.Sum256([]byte(m.salted(m.namespaced(category))))
+	var tweak [7]byte
+	copy(tweak[:], sum[:])
+
+	enciphered, err := m.fpe.encrypt(payload, tweak)
+	if err != nil {
+		return "", false
+	}
+	token := m.fpeFormat(originalValue, enciphered, luhn)
+
+	// Enciphering again walks the cycle of the value until it leaves the
+	// reserved namespaces
+	for n := 1; n <= maxReservedRetries && m.reserved.contains(token); n++ {
+		enciphered, _ = m.fpe.encrypt(enciphered, tweak)
+		token = m.fpeFormat(originalValue, enciphered, luhn)
+	}
+	return token, true
+}
+
+// fpeFormat puts the enciphered digits in place of the digits of originalValue,
+// followed by the Luhn check digit when luhn is set
+func (m *Masker) fpeFormat(originalValue string, enciphered []byte, luhn bool) string {
+	token := []byte(originalValue)
+	next := 0
+	last := -1
+	for i, c := range token {
+		if c < '0' || c > '9' {
+			continue
+		}
+		if next < len(enciphered) {
+			token[i] = enciphered[next] + '0'
+			next++
+		}
+		last = i
+	}
+	if luhn {
+		token[last] = luhnCheckDigit(string(token[:last]))
+	}
+	return string(token)
+}
+
+// luhnCheckDigit returns the check digit completing the digits of number
+func luhnCheckDigit(number string) byte {
+	for digit := byte('0'); digit <= '9'; digit++ {
+		if validLuhn(number + string(digit)) {
+			return digit
+		}
+	}
+	return '0'
+}
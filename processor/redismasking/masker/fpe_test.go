@@ -0,0 +1,33 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFPETokenFormat(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenFormat = tokenFormatFPE
+	cfg.FPEKey = "MDEyMzQ1Njc4OWFiY2RlZg=="
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// Separators stay in place
+	ssn := m.generateMaskedValue("123-45-6789", "ssn")
+	assert.Regexp(t, `^\d{3}-\d{2}-\d{4}$`, ssn)
+	assert.NotEqual(t, "123-45-6789", ssn)
+	assert.Equal(t, ssn, m.generateMaskedValue("123-45-6789", "ssn"))
+	assert.NotEqual(t, ssn, m.generateMaskedValue("123-45-6789", "phone"))
+
+	// Card numbers keep their length and pass the Luhn check
+	card := m.generateMaskedValue("4111 1111 1111 1111", "card")
+	assert.Regexp(t, `^\d{4} \d{4} \d{4} \d{4}$`, card)
+	assert.True(t, validLuhn(card))
+	assert.NotEqual(t, "4111 1111 1111 1111", card)
+
+	// Values with too few digits get a token of the default format
+	assert.Equal(t, m.deriveToken("ab-12"+"code", "code"), m.generateMaskedValue("ab-12", "code"))
+}
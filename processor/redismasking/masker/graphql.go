@@ -0,0 +1,181 @@
+package masker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+// graphqlCategory is the category of tokenized GraphQL literals and variables
+const graphqlCategory = "graphql"
+
+// maskGraphQL tokenizes the literal values of a GraphQL document, a variables
+// JSON object, or a JSON request holding both. Operation names, fields, and
+// variable names are kept, so requests still group by query shape in APM
+// analytics. Values that are none of these are pattern-scanned.
+func (m *Masker) maskGraphQL(ctx context.Context, text string, onMask func(category, token string)) string {
+	if object, ok := decodeJSONObject(text); ok {
+		if query, ok := object["query"].(string); ok {
+			object["query"] = m.maskGraphQLQuery(ctx, query, onMask)
+			if variables, ok := object["variables"]; ok {
+				object["variables"] = m.maskJSONValue(ctx, variables, onMask)
+			}
+		} else {
+			object = m.maskJSONValue(ctx, object, onMask).(map[string]any)
+		}
+
+		if masked, err := encodeJSON(object); err == nil {
+			return masked
+		}
+	}
+
+	return m.maskGraphQLQuery(ctx, text, onMask)
+}
+
+// maskGraphQLQuery tokenizes the literals of a GraphQL document and returns it
+// on a single line. Comments are dropped since they may hold anything.
+func (m *Masker) maskGraphQLQuery(ctx context.Context, query string, onMask func(category, token string)) string {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		return m.maskString(ctx, query, onMask)
+	}
+
+	mask := func(value *ast.Value) {
+		m.maskGraphQLValue(ctx, value, onMask)
+	}
+	for _, operation := range doc.Operations {
+		for _, variable := range operation.VariableDefinitions {
+			mask(variable.DefaultValue)
+			walkGraphQLDirectives(variable.Directives, mask)
+		}
+		walkGraphQLDirectives(operation.Directives, mask)
+		walkGraphQLSelections(operation.SelectionSet, mask)
+	}
+	for _, fragment := range doc.Fragments {
+		walkGraphQLDirectives(fragment.Directives, mask)
+		walkGraphQLSelections(fragment.SelectionSet, mask)
+	}
+
+	var buf strings.Builder
+	formatter.NewFormatter(&buf, formatter.WithIndent("")).FormatQueryDocument(doc)
+
+	// String literals are quoted with escapes, so every newline is layout
+	lines := strings.FieldsFunc(buf.String(), func(r rune) bool { return r == '\n' })
+	return strings.Join(lines, " ")
+}
+
+// maskGraphQLValue replaces the scalar literals in value with string tokens.
+// Booleans, enums, nulls, and variable references carry no data and are kept.
+func (m *Masker) maskGraphQLValue(ctx context.Context, value *ast.Value, onMask func(category, token string)) {
+	if value == nil {
+		return
+	}
+
+	switch value.Kind {
+	case ast.IntValue, ast.FloatValue, ast.StringValue, ast.BlockValue:
+		value.Raw = m.graphqlToken(ctx, value.Raw, onMask)
+		value.Kind = ast.StringValue
+	case ast.ListValue, ast.ObjectValue:
+		for _, child := range value.Children {
+			m.maskGraphQLValue(ctx, child.Value, onMask)
+		}
+	}
+}
+
+// maskJSONValue replaces the strings and numbers in a decoded JSON value with
+// string tokens, keeping object keys and the nesting of the value
+func (m *Masker) maskJSONValue(ctx context.Context, value any, onMask func(category, token string)) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			v[key] = m.maskJSONValue(ctx, child, onMask)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = m.maskJSONValue(ctx, child, onMask)
+		}
+		return v
+	case string:
+		return m.graphqlToken(ctx, v, onMask)
+	case json.Number:
+		return m.graphqlToken(ctx, v.String(), onMask)
+	default:
+		return v
+	}
+}
+
+// graphqlToken returns the token of a GraphQL literal or variable value
+func (m *Masker) graphqlToken(ctx context.Context, value string, onMask func(category, token string)) string {
+	token, err := m.MaskValue(ctx, value, graphqlCategory)
+	if err != nil {
+		m.logError("Failed to mask GraphQL value", err)
+		return value
+	}
+	if onMask != nil {
+		onMask(graphqlCategory, token)
+	}
+	return token
+}
+
+// walkGraphQLSelections calls fn with every argument and directive value in set
+func walkGraphQLSelections(set ast.SelectionSet, fn func(*ast.Value)) {
+	for _, selection := range set {
+		switch s := selection.(type) {
+		case *ast.Field:
+			for _, argument := range s.Arguments {
+				fn(argument.Value)
+			}
+			walkGraphQLDirectives(s.Directives, fn)
+			walkGraphQLSelections(s.SelectionSet, fn)
+		case *ast.InlineFragment:
+			walkGraphQLDirectives(s.Directives, fn)
+			walkGraphQLSelections(s.SelectionSet, fn)
+		case *ast.FragmentSpread:
+			walkGraphQLDirectives(s.Directives, fn)
+		}
+	}
+}
+
+// walkGraphQLDirectives calls fn with every argument value of directives
+func walkGraphQLDirectives(directives ast.DirectiveList, fn func(*ast.Value)) {
+	for _, directive := range directives {
+		for _, argument := range directive.Arguments {
+			fn(argument.Value)
+		}
+	}
+}
+
+// decodeJSONObject decodes text when it is exactly one JSON object.
+// Numbers are kept as json.Number so they are tokenized verbatim.
+func decodeJSONObject(text string) (map[string]any, bool) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, false
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, false
+	}
+	return object, true
+}
+
+// encodeJSON encodes value without escaping HTML characters
+func encodeJSON(value any) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,66 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestGraphQLStructuredFields(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.StructuredFields = []StructuredFieldConfig{
+		{
+			Strategy: strategyGraphQL,
+			Keys:     []string{"graphql.document", "graphql.variables", bodyKey},
+		},
+	}
+	m, _ := newTestMasker(t, &cfg)
+	token := func(value string) string {
+		return m.generateMaskedValue(value, graphqlCategory)
+	}
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("graphql.document", `query GetUser($id: ID!, $limit: Int = 10) {
+  # looked up by alice@example.com
+  user(id: $id, email: "alice@example.com") @include(if: true) {
+    name
+    orders(first: 5, status: SHIPPED) { total }
+  }
+}`)
+	lr.Attributes().PutStr("graphql.variables", `{"id":"u-123","filter":{"zip":90210,"tags":["vip"],"active":true}}`)
+	lr.Body().SetStr(`{"operationName":"GetUser","query":"{ user(id: \"u-123\") { name } }","variables":{"id":"u-123"}}`)
+
+	m.MaskLogs(context.Background(), ld)
+
+	// The operation shape is kept while literals are tokenized
+	document, _ := lr.Attributes().Get("graphql.document")
+	assert.Equal(t, `query GetUser ($id: ID!, $limit: Int = "`+token("10")+`") { user(id: $id, email: "`+token("alice@example.com")+`") @include(if: true) { name orders(first: "`+token("5")+`", status: SHIPPED) { total } } }`, document.Str())
+
+	variables, _ := lr.Attributes().Get("graphql.variables")
+	assert.Equal(t, `{"filter":{"active":true,"tags":["`+token("vip")+`"],"zip":"`+token("90210")+`"},"id":"`+token("u-123")+`"}`, variables.Str())
+
+	// Logged requests have both their query and variables masked
+	assert.Equal(t, `{"operationName":"GetUser","query":"query { user(id: \"`+token("u-123")+`\") { name } }","variables":{"id":"`+token("u-123")+`"}}`, lr.Body().Str())
+}
+
+func TestGraphQLFallsBackToPatterns(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.StructuredFields = []StructuredFieldConfig{
+		{Strategy: strategyGraphQL, Keys: []string{"graphql.document"}},
+	}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("graphql.document", "not graphql { from 192.168.1.1")
+
+	m.MaskLogs(context.Background(), ld)
+
+	document, _ := lr.Attributes().Get("graphql.document")
+	assert.Equal(t, "not graphql { from "+m.generateMaskedValue("192.168.1.1", "ipv4"), document.Str())
+}
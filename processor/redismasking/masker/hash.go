@@ -0,0 +1,91 @@
+package masker
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// hashAlgorithmSHA256 derives tokens with SHA-256
+	hashAlgorithmSHA256 = "sha256"
+
+	// hashAlgorithmSHA512 derives tokens with SHA-512
+	hashAlgorithmSHA512 = "sha512"
+
+	// hashAlgorithmBLAKE2b derives tokens with BLAKE2b-512
+	hashAlgorithmBLAKE2b = "blake2b"
+
+	// defaultTokenLength is the number of hex characters of hashed tokens
+	defaultTokenLength = 12
+
+	// hostTokenLength is the number of hex characters of synthetic hostnames
+	hostTokenLength = 8
+)
+
+// hashFunc returns the constructor of the configured hash algorithm
+func (cfg *Config) hashFunc() (func() hash.Hash, error) {
+	switch cfg.HashAlgorithm {
+	case "", hashAlgorithmSHA256:
+		return sha256.New, nil
+	case hashAlgorithmSHA512:
+		return sha512.New, nil
+	case hashAlgorithmBLAKE2b:
+		return newBLAKE2b, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash_algorithm '%s'", cfg.HashAlgorithm)
+	}
+}
+
+// newBLAKE2b creates an unkeyed BLAKE2b-512 hash, which is keyed through HMAC
+// like the other algorithms
+func newBLAKE2b() hash.Hash {
+	// New512 only fails for keys longer than 64 bytes
+	h, _ := blake2b.New512(nil)
+	return h
+}
+
+// validateTokenLengths checks that the default and each pattern's token_length
+// fit in the hex digest of the hash algorithm
+func (cfg *Config) validateTokenLengths() error {
+	newHash, err := cfg.hashFunc()
+	if err != nil {
+		return err
+	}
+	limit := 2 * newHash().Size()
+
+	if cfg.TokenLength <= 0 || cfg.TokenLength > limit {
+		return fmt.Errorf("token_length must be between 1 and %d for hash_algorithm '%s'", limit, cfg.hashAlgorithm())
+	}
+	for _, pattern := range cfg.Patterns {
+		if pattern.TokenLength < 0 || pattern.TokenLength > limit {
+			return fmt.Errorf("pattern '%s': token_length must be between 1 and %d for hash_algorithm '%s'", pattern.Name, limit, cfg.hashAlgorithm())
+		}
+	}
+	return nil
+}
+
+// hashAlgorithm returns the configured hash algorithm, SHA-256 by default
+func (cfg *Config) hashAlgorithm() string {
+	if cfg.HashAlgorithm == "" {
+		return hashAlgorithmSHA256
+	}
+	return cfg.HashAlgorithm
+}
+
+// tokenLength returns the number of hex characters of hashed tokens of
+// category. A pattern's own length takes precedence over fallback.
+func (m *Masker) tokenLength(category string, fallback int) int {
+	for _, pattern := range m.compiledPatterns {
+		if pattern.name == category && pattern.tokenLength > 0 {
+			return pattern.tokenLength
+		}
+	}
+	if fallback <= 0 {
+		return defaultTokenLength
+	}
+	return fallback
+}
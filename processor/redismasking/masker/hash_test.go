@@ -0,0 +1,62 @@
+package masker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHashAlgorithms(t *testing.T) {
+	tokens := map[string]string{}
+	for _, algorithm := range []string{hashAlgorithmSHA256, hashAlgorithmSHA512, hashAlgorithmBLAKE2b} {
+		t.Run(algorithm, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.HashAlgorithm = algorithm
+			cfg.HMACKey = "secret"
+			require.NoError(t, cfg.Validate())
+			m, err := New(&cfg, nil, zap.NewNop())
+			require.NoError(t, err)
+
+			token := m.generateMaskedValue("jdoe", "attribute_username")
+			assert.Regexp(t, `^username-[0-9a-f]{12}$`, token)
+			assert.Equal(t, token, m.generateMaskedValue("jdoe", "attribute_username"))
+			tokens[algorithm] = token
+		})
+	}
+	assert.Len(t, tokens, 3)
+	assert.NotEqual(t, tokens[hashAlgorithmSHA256], tokens[hashAlgorithmSHA512])
+	assert.NotEqual(t, tokens[hashAlgorithmSHA256], tokens[hashAlgorithmBLAKE2b])
+	assert.NotEqual(t, tokens[hashAlgorithmSHA512], tokens[hashAlgorithmBLAKE2b])
+}
+
+func TestTokenLength(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenLength = 20
+	cfg.Patterns = []PatternConfig{
+		{Name: "email", Regex: `\S+@\S+`, MaskedPrefix: "EMAIL-", TokenLength: 32},
+		{Name: "hostname", Regex: `\bweb-\d+\b`, TokenLength: 16},
+		{Name: "ssn", Regex: `\b\d{3}-\d{2}-\d{4}\b`, MaskedPrefix: "SSN-"},
+	}
+	require.NoError(t, cfg.Validate())
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	email := m.generateMaskedValue("jdoe@example.com", "email")
+	assert.Regexp(t, `^EMAIL-[0-9a-f]{32}$`, email)
+	assert.Regexp(t, `^SSN-[0-9a-f]{20}$`, m.generateMaskedValue("123-45-6789", "ssn"))
+	assert.Regexp(t, `^username-[0-9a-f]{20}$`, m.generateMaskedValue("jdoe", "attribute_username"))
+	assert.Regexp(t, `^host-[0-9a-f]{16}\.masked\.local$`, m.generateMaskedValue("web-01", "hostname"))
+
+	// Lengths only truncate the same digest
+	cfg.Patterns[0].TokenLength = 0
+	shorter, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, email[:len("EMAIL-")+20], shorter.generateMaskedValue("jdoe@example.com", "email"))
+
+	// Masker zero values keep the historical shapes
+	plain := &Masker{config: &Config{}, logger: zap.NewNop()}
+	assert.Regexp(t, `^username-[0-9a-f]{12}$`, plain.generateMaskedValue("jdoe", "attribute_username"))
+	assert.Regexp(t, `^host-[0-9a-f]{8}\.masked\.local$`, plain.generateMaskedValue("web-01", "hostname"))
+}
@@ -0,0 +1,100 @@
+package masker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// mrnPattern is the name of the medical record number pattern of the
+	// healthcare pack, whose regex is built from the configured formats
+	mrnPattern = "mrn"
+
+	// patientNamePattern is the name of the patient name pattern of the
+	// healthcare pack, whose matches are looked up in the patient names file
+	patientNamePattern = "patient_name"
+
+	// patientNameRegex matches every word that may be a name
+	patientNameRegex = `\p{L}+(?:['’-]\p{L}+)*`
+)
+
+// HealthcareConfig configures the healthcare pattern pack
+type HealthcareConfig struct {
+	// MRNFormats are regular expressions of the medical record numbers of the
+	// organization, e.g. `MRN-[0-9]{8}`
+	MRNFormats []string `mapstructure:"mrn_formats"`
+
+	// PatientNamesFile is a file of patient names, one per line, whose
+	// occurrences in free text are masked
+	PatientNamesFile string `mapstructure:"patient_names_file"`
+}
+
+// Validate checks that the medical record number formats compile
+func (cfg *HealthcareConfig) Validate() error {
+	for _, format := range cfg.MRNFormats {
+		if _, err := regexp.Compile(format); err != nil {
+			return fmt.Errorf("failed to compile healthcare mrn_formats format: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadNames reads a file of names, one per line, into a set of lowercase
+// words. Names of several words are matched word by word.
+func loadNames(path string) (map[string]struct{}, error) {
+	// #nosec G304 -- the names file is provided by the collector configuration
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patient names: %w", err)
+	}
+	defer file.Close()
+
+	names := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		for _, word := range strings.Fields(scanner.Text()) {
+			names[strings.ToLower(word)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patient names: %w", err)
+	}
+	return names, nil
+}
+
+// patientNameValidator returns a validation accepting the words of the names file
+func patientNameValidator(path string) (func(string) bool, error) {
+	names, err := loadNames(path)
+	if err != nil {
+		return nil, err
+	}
+	return func(word string) bool {
+		_, ok := names[strings.ToLower(word)]
+		return ok
+	}, nil
+}
+
+// validNPI reports whether the last digit of a 10 digit National Provider
+// Identifier is its Luhn check digit, computed with the 80840 prefix of the
+// health industry
+func validNPI(npi string) bool {
+	if len(npi) != 10 {
+		return false
+	}
+	// The prefix 80840 adds 24 to the sum of the doubled digits
+	sum := 24
+	for i := 8; i >= 0; i-- {
+		digit := int(npi[i] - '0')
+		if (8-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return int(npi[9]-'0') == (10-sum%10)%10
+}
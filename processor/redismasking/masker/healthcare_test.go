@@ -0,0 +1,97 @@
+package masker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidNPI(t *testing.T) {
+	assert.True(t, validNPI("1234567893"))
+	assert.True(t, validNPI("1245319599"))
+	assert.False(t, validNPI("1234567890"))
+	assert.False(t, validNPI("123456789"))
+}
+
+// writeNames writes a patient names file and returns its path
+func writeNames(t *testing.T, names ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "patients.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(names, "\n")+"\n"), 0o600))
+	return path
+}
+
+func TestHealthcarePatternPack(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = nil
+	cfg.PatternPacks = []string{patternPackHealthcare}
+	cfg.Healthcare = HealthcareConfig{
+		MRNFormats:       []string{`MRN[0-9]{8}`},
+		PatientNamesFile: writeNames(t, "Ann Smith", "José"),
+	}
+	m, _ := newTestMasker(t, &cfg)
+
+	mrn := m.generateMaskedValue("MRN00123456", mrnPattern)
+	assert.True(t, strings.HasPrefix(mrn, "MRN-"), mrn)
+	npi := m.generateMaskedValue("1234567893", "npi")
+	assert.True(t, strings.HasPrefix(npi, "NPI-"), npi)
+	icd := m.generateMaskedValue("E11.65", "icd10")
+	assert.True(t, strings.HasPrefix(icd, "ICD-"), icd)
+	ann := m.generateMaskedValue("Ann", patientNamePattern)
+	assert.True(t, strings.HasPrefix(ann, "PATIENT-"), ann)
+	smith := m.generateMaskedValue("SMITH", patientNamePattern)
+	jose := m.generateMaskedValue("José", patientNamePattern)
+
+	masked := m.MaskString(context.Background(), "patient Ann SMITH (MRN00123456) seen by 1234567893 for E11.65, José waiting")
+	assert.Equal(t, "patient "+ann+" "+smith+" ("+mrn+") seen by "+npi+" for "+icd+", "+jose+" waiting", masked)
+
+	// Names are not masked inside other words, and invalid NPIs are left alone
+	assert.Equal(t, ann+" filed the Annual report by 1234567890", m.MaskString(context.Background(), "Ann filed the Annual report by 1234567890"))
+}
+
+func TestHealthcarePatternPackDefaults(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{patternPackHealthcare}
+
+	names := []string{}
+	for _, pattern := range cfg.packPatterns() {
+		names = append(names, pattern.Name)
+	}
+	assert.Equal(t, []string{"npi", "icd10"}, names)
+}
+
+func TestHealthcareConfig(t *testing.T) {
+	require.ErrorContains(t, (&HealthcareConfig{MRNFormats: []string{"[0-9"}}).Validate(), "failed to compile healthcare mrn_formats format")
+
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{patternPackHealthcare}
+	cfg.Healthcare.PatientNamesFile = filepath.Join(t.TempDir(), "missing.txt")
+	_, err := New(&cfg, nil, zap.NewNop())
+	require.ErrorContains(t, err, "failed to read patient names")
+}
+
+func TestPatientNamesFingerprint(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{patternPackHealthcare}
+	cfg.Healthcare.PatientNamesFile = writeNames(t, "Ann")
+
+	policy, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.Len(t, policy.PatientNamesSHA256, 64)
+	for _, pattern := range policy.Patterns {
+		assert.NotContains(t, pattern.Regex, "Ann")
+	}
+
+	// Changing the names changes the fingerprint
+	before := policy.Fingerprint
+	cfg.Healthcare.PatientNamesFile = writeNames(t, "Ann", "Smith")
+	policy, err = cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.NotEqual(t, before, policy.Fingerprint)
+}
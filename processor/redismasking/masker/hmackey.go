@@ -0,0 +1,43 @@
+package masker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// hasHMACKey reports whether tokens are derived with a key from hmac_key or hmac_key_file
+func (cfg *Config) hasHMACKey() bool {
+	return cfg.HMACKey != "" || cfg.HMACKeyFile != ""
+}
+
+// validateHMACKey checks that the key is configured once
+func (cfg *Config) validateHMACKey() error {
+	if cfg.HMACKey != "" && cfg.HMACKeyFile != "" {
+		return errors.New("hmac_key and hmac_key_file are mutually exclusive")
+	}
+	return nil
+}
+
+// loadHMACKey returns the key of token derivation, reading hmac_key_file when
+// it is set, or nil without a key
+func (cfg *Config) loadHMACKey() ([]byte, error) {
+	if cfg.HMACKeyFile == "" {
+		if cfg.HMACKey == "" {
+			return nil, nil
+		}
+		return []byte(cfg.HMACKey), nil
+	}
+
+	// #nosec G304 -- the key file is provided by the collector configuration
+	data, err := os.ReadFile(cfg.HMACKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hmac_key_file: %w", err)
+	}
+	key := strings.TrimRight(string(data), "\r\n")
+	if key == "" {
+		return nil, errors.New("hmac_key_file is empty")
+	}
+	return []byte(key), nil
+}
@@ -0,0 +1,41 @@
+package masker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestHMACKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hmac-key")
+	require.NoError(t, os.WriteFile(path, []byte("secret\n"), 0o600))
+
+	inline := NewDefaultConfig()
+	inline.HMACKey = "secret"
+	fromFile := NewDefaultConfig()
+	fromFile.HMACKeyFile = path
+	unkeyed := NewDefaultConfig()
+
+	tokens := make([]string, 0, 3)
+	for _, cfg := range []*Config{&inline, &fromFile, &unkeyed} {
+		m, err := New(cfg, nil, zap.NewNop())
+		require.NoError(t, err)
+		tokens = append(tokens, m.generateMaskedValue("192.168.1.1", "ipv4"))
+	}
+
+	// The trailing newline is not part of the key
+	assert.Equal(t, tokens[0], tokens[1])
+	assert.NotEqual(t, tokens[0], tokens[2])
+
+	require.NoError(t, os.WriteFile(path, []byte("\n"), 0o600))
+	_, err := New(&fromFile, nil, zap.NewNop())
+	require.EqualError(t, err, "hmac_key_file is empty")
+
+	fromFile.HMACKeyFile = filepath.Join(t.TempDir(), "missing")
+	_, err = New(&fromFile, nil, zap.NewNop())
+	require.ErrorContains(t, err, "failed to read hmac_key_file")
+}
@@ -0,0 +1,155 @@
+package masker
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// licensePlateFormats are the license plate formats of the supported regions.
+// Only the current national formats are covered, older and special plates need
+// a custom format.
+var licensePlateFormats = map[string]string{
+	"uk": `[A-Z]{2}[0-9]{2} ?[A-Z]{3}`,
+	"de": `[A-Z]{1,3}-[A-Z]{1,2} ?[0-9]{1,4}[EH]?`,
+	"fr": `[A-Z]{2}-[0-9]{3}-[A-Z]{2}`,
+	"it": `[A-Z]{2} ?[0-9]{3} ?[A-Z]{2}`,
+	"es": `[0-9]{4} ?[B-DF-HJ-NP-TV-Z]{3}`,
+}
+
+// LicensePlateConfig selects the license plate formats detected by the
+// vehicles pattern pack
+type LicensePlateConfig struct {
+	// Regions are the regions whose national formats are detected, e.g. "uk" or "de"
+	Regions []string `mapstructure:"regions"`
+
+	// Formats are additional regular expressions of plates, e.g. of US states
+	Formats []string `mapstructure:"formats"`
+}
+
+// Validate checks that the regions are supported and the formats compile
+func (cfg *LicensePlateConfig) Validate() error {
+	for _, region := range cfg.Regions {
+		if _, ok := licensePlateFormats[region]; !ok {
+			return fmt.Errorf("unsupported license_plates region '%s'", region)
+		}
+	}
+	for _, format := range cfg.Formats {
+		if _, err := regexp.Compile(format); err != nil {
+			return fmt.Errorf("failed to compile license_plates format: %w", err)
+		}
+	}
+	return nil
+}
+
+// regex returns one regular expression matching every configured format, or
+// "" when none is configured
+func (cfg *LicensePlateConfig) regex() string {
+	var formats []string
+	for _, region := range cfg.Regions {
+		formats = append(formats, licensePlateFormats[region])
+	}
+	return wordAlternation(append(formats, cfg.Formats...))
+}
+
+// wordAlternation returns a regular expression matching any of formats as a
+// whole word, or "" when there are none
+func wordAlternation(formats []string) string {
+	if len(formats) == 0 {
+		return ""
+	}
+	return `\b(?:` + strings.Join(slices.Compact(formats), "|") + `)\b`
+}
+
+// vinValues are the values of the letters of a VIN in its check digit
+var vinValues = map[rune]int{
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+}
+
+// vinWeights are the weights of the positions of a VIN in its check digit
+var vinWeights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// validVIN reports whether the ninth character of a 17 character VIN is its
+// ISO 3779 check digit. Other 17 character identifiers rarely pass, so serial
+// numbers and hashes are not mistaken for VINs.
+func validVIN(vin string) bool {
+	if len(vin) != len(vinWeights) {
+		return false
+	}
+	sum := 0
+	for i, c := range vin {
+		value, ok := vinValues[c]
+		if c >= '0' && c <= '9' {
+			value, ok = int(c-'0'), true
+		}
+		if !ok {
+			return false
+		}
+		sum += value * vinWeights[i]
+	}
+	check := byte('0' + sum%11)
+	if sum%11 == 10 {
+		check = 'X'
+	}
+	return vin[8] == check
+}
+
+// validUPSTracking reports whether the last digit of a 1Z tracking number is
+// its UPS check digit
+func validUPSTracking(number string) bool {
+	if len(number) != 18 {
+		return false
+	}
+	sum := 0
+	for i, c := range number[2:17] {
+		value := int(c - '0')
+		if c >= 'A' && c <= 'Z' {
+			value = int(c-'A'+2) % 10
+		}
+		if i%2 == 1 {
+			value *= 2
+		}
+		sum += value
+	}
+	return int(number[17]-'0') == (10-sum%10)%10
+}
+
+// validUSPSTracking reports whether the last digit of a USPS tracking number is
+// its mod 10 check digit
+func validUSPSTracking(number string) bool {
+	sum := 0
+	for i := len(number) - 2; i >= 0; i-- {
+		value := int(number[i] - '0')
+		if (len(number)-2-i)%2 == 0 {
+			value *= 3
+		}
+		sum += value
+	}
+	return int(number[len(number)-1]-'0') == (10-sum%10)%10
+}
+
+// s10Weights are the weights of the serial number digits of a UPU S10 item
+var s10Weights = [8]int{8, 6, 4, 2, 3, 5, 9, 7}
+
+// validS10Tracking reports whether the ninth digit of an international UPU S10
+// item identifier, e.g. RR123456785GB, is its check digit
+func validS10Tracking(number string) bool {
+	if len(number) != 13 {
+		return false
+	}
+	sum := 0
+	for i, weight := range s10Weights {
+		sum += int(number[2+i]-'0') * weight
+	}
+	check := 11 - sum%11
+	switch check {
+	case 10:
+		check = 0
+	case 11:
+		check = 5
+	}
+	return int(number[10]-'0') == check
+}
@@ -0,0 +1,100 @@
+package masker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentifierCheckDigits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		valid    func(string) bool
+		number   string
+		expected bool
+	}{
+		{name: "vin", valid: validVIN, number: "1M8GDM9AXKP042788", expected: true},
+		{name: "vin digit check", valid: validVIN, number: "1HGCM82633A004352", expected: true},
+		{name: "vin wrong check digit", valid: validVIN, number: "1M8GDM9A1KP042788"},
+		{name: "vin too short", valid: validVIN, number: "1M8GDM9AXKP04278"},
+		{name: "ups", valid: validUPSTracking, number: "1Z999AA10123456784", expected: true},
+		{name: "ups wrong check digit", valid: validUPSTracking, number: "1Z999AA10123456785"},
+		{name: "usps", valid: validUSPSTracking, number: "9400111899223100000000", expected: true},
+		{name: "usps wrong check digit", valid: validUSPSTracking, number: "9400111899223100000001"},
+		{name: "s10", valid: validS10Tracking, number: "RR123456785GB", expected: true},
+		{name: "s10 wrong check digit", valid: validS10Tracking, number: "RR123456784GB"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.valid(tc.number))
+		})
+	}
+}
+
+func TestVehiclesPatternPack(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = nil
+	cfg.PatternPacks = []string{patternPackVehicles}
+	cfg.LicensePlates = LicensePlateConfig{Regions: []string{"uk", "fr"}, Formats: []string{`[0-9][A-Z]{3}[0-9]{3}`}}
+	m, _ := newTestMasker(t, &cfg)
+
+	vin := m.generateMaskedValue("1M8GDM9AXKP042788", "vin")
+	assert.True(t, strings.HasPrefix(vin, "VIN-"), vin)
+	uk := m.generateMaskedValue("AB12 CDE", licensePlatePattern)
+	assert.True(t, strings.HasPrefix(uk, "PLATE-"), uk)
+	fr := m.generateMaskedValue("AB-123-CD", licensePlatePattern)
+	custom := m.generateMaskedValue("7ABC123", licensePlatePattern)
+
+	masked := m.MaskString(context.Background(), "vin=1M8GDM9AXKP042788 plate=AB12 CDE plate=AB-123-CD plate=7ABC123")
+	assert.Equal(t, "vin="+vin+" plate="+uk+" plate="+fr+" plate="+custom, masked)
+
+	// Serial numbers failing the check digit are not VINs
+	assert.Equal(t, "serial=1M8GDM9A1KP042788", m.MaskString(context.Background(), "serial=1M8GDM9A1KP042788"))
+}
+
+func TestVehiclesPatternPackWithoutPlates(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{patternPackVehicles}
+
+	for _, pattern := range cfg.effectivePatterns() {
+		assert.NotEqual(t, licensePlatePattern, pattern.Name)
+	}
+}
+
+func TestShippingPatternPack(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = nil
+	cfg.PatternPacks = []string{patternPackShipping}
+	m, _ := newTestMasker(t, &cfg)
+
+	testCases := []struct {
+		name   string
+		number string
+		prefix string
+	}{
+		{name: "ups_tracking", number: "1Z999AA10123456784", prefix: "1ZMASKED"},
+		{name: "usps_tracking", number: "9400111899223100000000", prefix: "USPS-"},
+		{name: "s10_tracking", number: "RR123456785GB", prefix: "S10-"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token := m.generateMaskedValue(tc.number, tc.name)
+			assert.True(t, strings.HasPrefix(token, tc.prefix), token)
+			assert.Equal(t, "parcel "+token+" shipped", m.MaskString(context.Background(), "parcel "+tc.number+" shipped"))
+		})
+	}
+
+	// Numbers failing the check digit are left alone
+	assert.Equal(t, "order 1Z999AA10123456785", m.MaskString(context.Background(), "order 1Z999AA10123456785"))
+}
+
+func TestLicensePlateConfigValidate(t *testing.T) {
+	require.NoError(t, (&LicensePlateConfig{Regions: []string{"de", "es", "it"}}).Validate())
+	require.EqualError(t, (&LicensePlateConfig{Regions: []string{"us"}}).Validate(), "unsupported license_plates region 'us'")
+	require.ErrorContains(t, (&LicensePlateConfig{Formats: []string{"[A-Z"}}).Validate(), "failed to compile license_plates format")
+}
@@ -0,0 +1,52 @@
+package masker
+
+import (
+	"context"
+	"strings"
+)
+
+// identityCategory is the category of identity tokens
+const identityCategory = "identity"
+
+// maskIdentity masks a user name in the DOMAIN\user, user@domain (email or UPN),
+// or bare user form. The token is derived from the case-folded user alone, so
+// every form of the same account gets the same identity token, and it is
+// rendered in the original form, e.g. CORP\alice becomes CORP\<token>.
+// Values that are not a user name are pattern-scanned instead.
+func (m *Masker) maskIdentity(ctx context.Context, text string, onMask func(category, token string)) string {
+	prefix, user, suffix, ok := parseIdentity(text)
+	if !ok {
+		return m.maskString(ctx, text, onMask)
+	}
+
+	token, err := m.MaskValue(ctx, strings.ToLower(user), identityCategory)
+	if err != nil {
+		m.logError("Failed to mask identity", err)
+		return text
+	}
+	if onMask != nil {
+		onMask(identityCategory, token)
+	}
+	return prefix + token + suffix
+}
+
+// parseIdentity splits a user name into the user and the text around it,
+// e.g. "CORP\" and "" for CORP\alice, or "" and "@corp.com" for alice@corp.com
+func parseIdentity(text string) (prefix, user, suffix string, ok bool) {
+	if text == "" || strings.ContainsAny(text, " \t\r\n") {
+		return "", "", "", false
+	}
+
+	if i := strings.IndexByte(text, '\\'); i >= 0 {
+		prefix, user = text[:i+1], text[i+1:]
+	} else if i := strings.LastIndexByte(text, '@'); i >= 0 {
+		user, suffix = text[:i], text[i:]
+	} else {
+		user = text
+	}
+
+	if user == "" || strings.ContainsAny(user, `\@`) {
+		return "", "", "", false
+	}
+	return prefix, user, suffix, true
+}
@@ -0,0 +1,62 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestIdentityStructuredFields(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.StructuredFields = []StructuredFieldConfig{
+		{Strategy: strategyIdentity, Keys: []string{"user.name", "user.email", "winlog.user"}},
+	}
+	m, _ := newTestMasker(t, &cfg)
+	token := m.generateMaskedValue("alice", identityCategory)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("winlog.user", `CORP\Alice`)
+	lr.Attributes().PutStr("user.email", "alice@corp.com")
+	lr.Attributes().PutStr("user.name", "alice")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// Every form maps to the same token and keeps its shape
+	winlogUser, _ := lr.Attributes().Get("winlog.user")
+	assert.Equal(t, `CORP\`+token, winlogUser.Str())
+	email, _ := lr.Attributes().Get("user.email")
+	assert.Equal(t, token+"@corp.com", email.Str())
+	name, _ := lr.Attributes().Get("user.name")
+	assert.Equal(t, token, name.Str())
+}
+
+func TestParseIdentity(t *testing.T) {
+	testCases := []struct {
+		input  string
+		prefix string
+		user   string
+		suffix string
+		ok     bool
+	}{
+		{input: `CORP\alice`, prefix: `CORP\`, user: "alice", ok: true},
+		{input: "alice@corp.com", user: "alice", suffix: "@corp.com", ok: true},
+		{input: "alice", user: "alice", ok: true},
+		{input: `CORP\`},
+		{input: "@corp.com"},
+		{input: "login failed for 10.0.0.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			prefix, user, suffix, ok := parseIdentity(tc.input)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.prefix, prefix)
+			assert.Equal(t, tc.user, user)
+			assert.Equal(t, tc.suffix, suffix)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package masker
+
+import "net/netip"
+
+// ipv6Regex matches candidate IPv6 addresses, including compressed and
+// IPv4-suffixed forms. Patterns with this regex keep only candidates that parse
+// as IPv6, so times like 12:30:45 and MAC addresses are not matched.
+const ipv6Regex = `(?i)(?:\b[0-9a-f]{1,4}:|::)[0-9a-f:]*(?:[0-9a-f]\b|::)(?:(?:\.\d{1,3}){3}\b)?`
+
+// validIPv6 reports whether value is an IPv6 address. Zones are never matched,
+// so the zone of a link-local address is kept after its token.
+func validIPv6(value string) bool {
+	addr, err := netip.ParseAddr(value)
+	return err == nil && addr.Is6()
+}
+
+// ipv6Token formats hash as an address in syntheticIPv6Prefix, the IPv6
+// documentation range. The address is printed in its canonical compressed form.
+func ipv6Token(hash []byte) string {
+	var addr [16]byte
+	prefix := syntheticIPv6Prefix.Addr().As16()
+	copy(addr[:], prefix[:syntheticIPv6Prefix.Bits()/8])
+	copy(addr[syntheticIPv6Prefix.Bits()/8:], hash)
+	return netip.AddrFrom16(addr).String()
+}
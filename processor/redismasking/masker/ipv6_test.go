@@ -0,0 +1,82 @@
+package masker
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIPv6Surrogates(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{DefaultPatterns()[0]}
+	require.Equal(t, "ipv6", cfg.Patterns[0].Name)
+	m, _ := newTestMasker(t, &cfg)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name  string
+		value string
+	}{
+		{name: "full", value: "2600:1f18:4a2b:0c00:8e3d:92ff:fe11:7a01"},
+		{name: "compressed", value: "fe80::1ff:fe23:4567:890a"},
+		{name: "loopback", value: "::1"},
+		{name: "uppercase", value: "2A00:1450:4001:81C::200E"},
+		{name: "ipv4 suffix", value: "::ffff:192.168.1.1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			masked := m.MaskString(ctx, "peer "+tc.value+" closed")
+			token := strings.TrimSuffix(strings.TrimPrefix(masked, "peer "), " closed")
+			addr, err := netip.ParseAddr(token)
+			require.NoError(t, err)
+			assert.True(t, syntheticIPv6Prefix.Contains(addr))
+			assert.Equal(t, addr.String(), token)
+			assert.Equal(t, masked, m.MaskString(ctx, "peer "+tc.value+" closed"))
+		})
+	}
+
+	// Candidates that are not IPv6 addresses are kept
+	for _, text := range []string{"at 12:30:45", "mac 00:1a:2b:3c:4d:5e", "ratio 1:2"} {
+		assert.Equal(t, text, m.MaskString(ctx, text))
+	}
+
+	// The zone of a link-local address is kept
+	assert.Regexp(t, `^via 2001:db8:[0-9a-f:]+%eth0$`, m.MaskString(ctx, "via fe80::1%eth0"))
+}
+
+func TestIPv6ReservedNamespaces(t *testing.T) {
+	plain := &Masker{config: &Config{}, logger: zap.NewNop()}
+	token := plain.generateMaskedValue("2600:1f18::1", "ipv6")
+
+	cfg := &Config{ReservedNamespaces: ReservedNamespacesConfig{CIDRs: []string{token + "/64"}}}
+	reserved, err := cfg.ReservedNamespaces.parse()
+	require.NoError(t, err)
+	m := &Masker{config: cfg, logger: zap.NewNop(), reserved: reserved}
+
+	regenerated := m.generateMaskedValue("2600:1f18::1", "ipv6")
+	assert.NotEqual(t, token, regenerated)
+	assert.False(t, reserved.contains(regenerated))
+	assert.True(t, syntheticIPv6Prefix.Contains(netip.MustParseAddr(regenerated)))
+}
+
+func TestIPv6DefaultPatterns(t *testing.T) {
+	cfg := NewDefaultConfig()
+	m, _ := newTestMasker(t, &cfg)
+
+	// Later patterns, e.g. hostname, never mask the token of an address again
+	fields := strings.Fields(m.MaskString(context.Background(), "peer 2600:1f18::7a01 via ::ffff:192.168.1.1 from 192.168.1.2"))
+	require.Len(t, fields, 6)
+	for _, token := range []string{fields[1], fields[3]} {
+		addr, err := netip.ParseAddr(token)
+		require.NoError(t, err)
+		assert.True(t, syntheticIPv6Prefix.Contains(addr), token)
+	}
+	addr, err := netip.ParseAddr(fields[5])
+	require.NoError(t, err)
+	assert.True(t, syntheticIPv4Prefix.Contains(addr), fields[5])
+}
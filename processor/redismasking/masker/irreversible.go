@@ -0,0 +1,41 @@
+package masker
+
+import (
+	"context"
+	"strconv"
+)
+
+// irreversibleSeed separates the derivation of irreversible tokens from the
+// derivation of reversible ones, whose mappings may be stored
+const irreversibleSeed = "irreversible\x00"
+
+// irreversibleKey marks a context whose values are masked irreversibly
+type irreversibleKey struct{}
+
+// withIrreversible returns a context masking values irreversibly
+func withIrreversible(ctx context.Context) context.Context {
+	return context.WithValue(ctx, irreversibleKey{}, true)
+}
+
+// isIrreversible reports whether values are masked irreversibly under ctx
+func isIrreversible(ctx context.Context) bool {
+	irreversible, _ := ctx.Value(irreversibleKey{}).(bool)
+	return irreversible
+}
+
+// irreversibleToken derives a token of originalValue whose mapping is never
+// stored, so neither the store nor the unmask API can reverse it. It differs
+// from the reversible token of the value, which could be looked up.
+func (m *Masker) irreversibleToken(originalValue, category string) string {
+	return m.deriveToken(irreversibleSeed+originalValue+m.namespaced(category), category)
+}
+
+// deriveToken formats the digest of seed as a token of category. Tokens
+// pointing at a reserved namespace are regenerated with a counter suffix.
+func (m *Masker) deriveToken(seed, category string) string {
+	token := m.formatToken(m.digest(seed), category)
+	for n := 1; n <= maxReservedRetries && m.reserved.contains(token); n++ {
+		token = m.formatToken(m.digest(seed+"#"+strconv.Itoa(n)), category)
+	}
+	return token
+}
@@ -0,0 +1,85 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyWatcher is implemented by stores that report changes of their keys
+type KeyWatcher interface {
+	// WatchKeys calls onChange with every key starting with one of prefixes that
+	// is written, deleted, renamed, evicted, or expires, until stop is called.
+	// onReset is called whenever changes may have been missed, i.e. when the
+	// watch starts, fails, and restarts.
+	WatchKeys(prefixes []string, onChange func(key string), onReset func()) (stop func())
+}
+
+var _ KeyWatcher = (*redisStore)(nil)
+
+// WatchKeys subscribes to the keyspace notifications of the keys. Redis only
+// publishes them with notify-keyspace-events enabled, e.g. set to "Kg$xe".
+func (s *redisStore) WatchKeys(prefixes []string, onChange func(key string), onReset func()) func() {
+	channelPrefix := fmt.Sprintf("__keyspace@%d__:", s.client.Options().DB)
+	patterns := make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		patterns = append(patterns, channelPrefix+escapeGlob(prefix)+"*")
+	}
+
+	sub := startSubscriber(func(ctx context.Context) *redis.PubSub {
+		return s.client.PSubscribe(ctx, patterns...)
+	}, func(msg any) {
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			onReset()
+		case *redis.Message:
+			onChange(strings.TrimPrefix(msg.Channel, channelPrefix))
+		}
+	}, onReset)
+	return sub.close
+}
+
+// watchedPrefixes are the prefixes of the keys whose changes invalidate the
+// local cache
+var watchedPrefixes = []string{"mask:", "unmask:"}
+
+// NewWatchedCachedStore wraps next with a local LRU like NewCachedStore, and
+// drops cached mappings as soon as they change in next, e.g. when they are
+// purged or expire
+func NewWatchedCachedStore(next Store, size int, ttl time.Duration) (Store, error) {
+	watcher, ok := next.(KeyWatcher)
+	if !ok {
+		return nil, errors.New("local_cache_invalidation requires a Redis store")
+	}
+	store := newCachedStore(next, size, ttl)
+	store.stopWatch = watcher.WatchKeys(watchedPrefixes, store.invalidate, store.purge)
+	return store, nil
+}
+
+// invalidate drops the cached value of key, and keeps the values of reads and
+// writes of key in flight from being cached
+func (s *cachedStore) invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending, ok := s.pending[key]; ok {
+		pending.generation++
+	}
+	s.cache.Remove(key)
+}
+
+// purge drops every cached value, and keeps the values of every read and write
+// in flight from being cached
+func (s *cachedStore) purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, pending := range s.pending {
+		pending.generation++
+	}
+	s.cache.Purge()
+}
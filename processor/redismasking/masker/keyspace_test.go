@@ -0,0 +1,91 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchedCachedStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	ctx := context.Background()
+
+	next, err := NewRedisStore(ctx, &cfg)
+	require.NoError(t, err)
+	store, err := NewWatchedCachedStore(next, 100, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	require.Eventually(t, func() bool { return server.PubSubNumPat() == len(watchedPrefixes) }, time.Second, 10*time.Millisecond)
+
+	key := MaskKey("ipv4", "10.0.0.1")
+	require.NoError(t, store.Set(ctx, key, "10.1.2.3", 0))
+
+	// The cached value is served until the key changes in Redis
+	require.NoError(t, server.Set(key, "10.4.5.6"))
+	value, _, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.2.3", value)
+
+	// miniredis does not publish keyspace notifications itself
+	server.Publish("__keyspace@0__:"+key, "set")
+	require.Eventually(t, func() bool {
+		value, _, err := store.Get(ctx, key)
+		return err == nil && value == "10.4.5.6"
+	}, time.Second, 10*time.Millisecond)
+
+	// Purged mappings are no longer served
+	server.Del(key)
+	server.Publish("__keyspace@0__:"+key, "del")
+	require.Eventually(t, func() bool {
+		_, found, err := store.Get(ctx, key)
+		return err == nil && !found
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWatchedCachedStoreUnsupported(t *testing.T) {
+	_, err := NewWatchedCachedStore(&countingStore{data: map[string]string{}}, 100, 0)
+	require.EqualError(t, err, "local_cache_invalidation requires a Redis store")
+}
+
+func TestCachedStoreInvalidation(t *testing.T) {
+	next := &countingStore{data: map[string]string{"a": "1"}}
+	store := newCachedStore(next, 10, 0)
+	ctx := context.Background()
+
+	_, _, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	store.invalidate("a")
+	_, _, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, next.reads)
+
+	// A value read before an invalidation of its key is not cached
+	generation := store.begin("a")
+	store.invalidate("a")
+	store.finish("a", "stale", generation, true)
+	_, ok := store.cache.Get("a")
+	assert.False(t, ok)
+
+	// An invalidation of another key keeps the value cacheable
+	generation = store.begin("a")
+	store.invalidate("b")
+	store.finish("a", "1", generation, true)
+	value, ok := store.cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "1", value)
+
+	// A purge keeps every value in flight from being cached
+	generation = store.begin("a")
+	store.purge()
+	store.finish("a", "stale", generation, true)
+	_, ok = store.cache.Get("a")
+	assert.False(t, ok)
+	assert.Empty(t, store.pending)
+}
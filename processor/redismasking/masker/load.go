@@ -0,0 +1,115 @@
+package masker
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"go.opentelemetry.io/collector/confmap"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfig reads the masking configuration of the processor with the given
+// component ID (e.g. "redismasking/pci") from a collector configuration file,
+// so tools outside the pipeline apply exactly the same rules.
+func LoadConfig(configPath, processorID string) (*Config, error) {
+	// #nosec G304 -- the config path is supplied by the operator
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var collectorConfig struct {
+		Extensions map[string]map[string]any `yaml:"extensions"`
+		Processors map[string]map[string]any `yaml:"processors"`
+	}
+	if err := yaml.Unmarshal(data, &collectorConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	processorConfig, ok := collectorConfig.Processors[processorID]
+	if !ok {
+		return nil, fmt.Errorf("processor '%s' not found in config", processorID)
+	}
+
+	cfg := NewDefaultConfig()
+	expanded, _ := expandEnv(processorConfig).(map[string]any)
+	if err := resolveStores(processorID, expanded, collectorConfig.Extensions); err != nil {
+		return nil, err
+	}
+	delete(expanded, "lazy_connect")
+	if err := confmap.NewFromStringMap(expanded).Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode processor '%s': %w", processorID, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid processor '%s': %w", processorID, err)
+	}
+	return &cfg, nil
+}
+
+// storeExtensionKeys are the settings of a redismasking_store extension that
+// connect to its Redis, named like those of Config
+var storeExtensionKeys = []string{
+	"redis_addr", "redis_password", "redis_db", "redis_password_file", "redis_network",
+	"redis_pool", "redis_timeouts", "redis_retry", "redis_auth", "redis_replicas",
+	"redis_client_cache", "tls",
+}
+
+// resolveStores replaces the store_extension of a processor configuration with
+// the Redis settings of the referenced extension, so the tools connect to the
+// store the processor shares. Mappings kept by a storage extension cannot be
+// read outside the collector.
+func resolveStores(processorID string, processorConfig map[string]any, extensions map[string]map[string]any) error {
+	if storage, ok := processorConfig["storage"]; ok && storage != nil {
+		return fmt.Errorf("processor '%s' stores its mappings through storage extension '%v', which cannot be read outside the collector", processorID, storage)
+	}
+	delete(processorConfig, "storage")
+
+	id, ok := processorConfig["store_extension"]
+	delete(processorConfig, "store_extension")
+	if !ok || id == nil {
+		return nil
+	}
+	extension, ok := extensions[fmt.Sprint(id)]
+	if !ok {
+		return fmt.Errorf("store extension '%v' of processor '%s' not found in config", id, processorID)
+	}
+	expanded, _ := expandEnv(extension).(map[string]any)
+	for _, key := range storeExtensionKeys {
+		delete(processorConfig, key)
+		if value, ok := expanded[key]; ok {
+			processorConfig[key] = value
+		}
+	}
+	return nil
+}
+
+// envReference matches the ${env:NAME} references of collector configurations
+var envReference = regexp.MustCompile(`\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces the ${env:NAME} references in the strings of value with
+// the environment variables, like the collector does, so the tools derive the
+// same tokens from a key such as ${env:MASKING_HMAC_KEY}
+func expandEnv(value any) any {
+	switch v := value.(type) {
+	case string:
+		return envReference.ReplaceAllStringFunc(v, func(ref string) string {
+			return os.Getenv(envReference.FindStringSubmatch(ref)[1])
+		})
+	case map[string]any:
+		expanded := make(map[string]any, len(v))
+		for key, item := range v {
+			expanded[key] = expandEnv(item)
+		}
+		return expanded
+	case []any:
+		expanded := make([]any, len(v))
+		for i, item := range v {
+			expanded[i] = expandEnv(item)
+		}
+		return expanded
+	default:
+		return value
+	}
+}
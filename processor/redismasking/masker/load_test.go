@@ -0,0 +1,47 @@
+package masker
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	configPath := filepath.Join("testdata", "config.yaml")
+
+	cfg, err := LoadConfig(configPath, "redismasking/edge")
+	require.NoError(t, err)
+	require.Equal(t, "lightweight", cfg.Mode)
+	require.Equal(t, "secret", cfg.HMACKey)
+	require.Equal(t, []string{"username"}, cfg.FieldsToMask)
+
+	// Environment references are expanded like in the collector
+	t.Setenv("TEST_MASKING_HMAC_KEY", "from-env")
+	cfg, err = LoadConfig(configPath, "redismasking/keyed")
+	require.NoError(t, err)
+	require.Equal(t, "from-env", cfg.HMACKey)
+
+	// Settings of the processor itself are ignored, but unknown keys are not
+	cfg, err = LoadConfig(configPath, "redismasking/lazy")
+	require.NoError(t, err)
+	require.Equal(t, "secret", cfg.HMACKey)
+	_, err = LoadConfig(configPath, "redismasking/typo")
+	require.ErrorContains(t, err, "hmac_kee")
+
+	// Shared stores are read from the extension
+	cfg, err = LoadConfig(configPath, "redismasking/shared")
+	require.NoError(t, err)
+	require.Equal(t, "redis.internal:6380", cfg.RedisAddr)
+	require.Equal(t, 2, cfg.RedisDB)
+	_, err = LoadConfig(configPath, "redismasking/unknown_extension")
+	require.EqualError(t, err, "store extension 'redismasking_store/missing' of processor 'redismasking/unknown_extension' not found in config")
+	_, err = LoadConfig(configPath, "redismasking/file_storage")
+	require.EqualError(t, err, "processor 'redismasking/file_storage' stores its mappings through storage extension 'file_storage', which cannot be read outside the collector")
+
+	_, err = LoadConfig(configPath, "redismasking/missing")
+	require.EqualError(t, err, "processor 'redismasking/missing' not found in config")
+
+	_, err = LoadConfig(filepath.Join("testdata", "missing.yaml"), "redismasking/edge")
+	require.ErrorContains(t, err, "failed to read config")
+}
@@ -0,0 +1,94 @@
+package masker
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// Supported token store backends
+const (
+	storeRedis     = "redis"
+	storeMemory    = "memory"
+	storeFile      = "file"
+	storeMemcached = "memcached"
+	storeDynamoDB  = "dynamodb"
+	storeSQL       = "sql"
+	storeFirestore = "firestore"
+	storeEtcd      = "etcd"
+)
+
+// validateStore checks the store backend and that the enabled settings work
+// with it. Access data and renewed mappings are only kept in Redis, and local
+// stores need no local cache in front of them.
+func (cfg *Config) validateStore() error {
+	switch cfg.Store {
+	case "", storeRedis:
+		return nil
+	case storeMemory:
+		if err := cfg.MemoryStore.Validate(); err != nil {
+			return err
+		}
+	case storeFile:
+		if err := cfg.FileStore.Validate(); err != nil {
+			return err
+		}
+	case storeMemcached:
+		if err := cfg.Memcached.Validate(); err != nil {
+			return err
+		}
+	case storeDynamoDB:
+		if err := cfg.DynamoDB.Validate(); err != nil {
+			return err
+		}
+	case storeSQL:
+		if err := cfg.SQLStore.Validate(); err != nil {
+			return err
+		}
+	case storeFirestore:
+		if err := cfg.Firestore.Validate(); err != nil {
+			return err
+		}
+	case storeEtcd:
+		if err := cfg.Etcd.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported store '%s'", cfg.Store)
+	}
+
+	if cfg.LocalStoreEnabled() && cfg.LocalCacheSize > 0 {
+		return fmt.Errorf("local_cache_size is not used with the %s store", cfg.Store)
+	}
+	if cfg.LocalCacheInvalidation {
+		return errors.New("local_cache_invalidation requires the redis store")
+	}
+	if cfg.TrackAccess || cfg.WarmupTopN > 0 {
+		return errors.New("track_access and warmup_top_n require the redis store")
+	}
+	if cfg.Watchlist.Enabled {
+		return errors.New("watchlist requires the redis store")
+	}
+	return nil
+}
+
+// NewStore creates the token store selected by cfg other than Redis, whose
+// store is created by NewRedisStore
+func (cfg *Config) NewStore(logger *zap.Logger) (Store, error) {
+	switch cfg.Store {
+	case storeFile:
+		return NewFileStore(&cfg.FileStore, logger)
+	case storeMemcached:
+		return NewMemcachedStore(&cfg.Memcached)
+	case storeDynamoDB:
+		return NewDynamoDBStore(&cfg.DynamoDB)
+	case storeSQL:
+		return NewSQLStore(&cfg.SQLStore)
+	case storeFirestore:
+		return NewFirestoreStore(&cfg.Firestore)
+	case storeEtcd:
+		return NewEtcdStore(&cfg.Etcd, logger)
+	}
+	return NewMemoryStore(&cfg.MemoryStore)
+}
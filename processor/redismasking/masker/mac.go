@@ -0,0 +1,77 @@
+package masker
+
+import "strings"
+
+const (
+	// macPattern is the name of the built-in MAC address pattern, whose tokens
+	// are surrogate MAC addresses
+	macPattern = "mac"
+
+	// macRegex matches MAC addresses grouped by colons or hyphens, e.g.
+	// 00:1a:2b:3c:4d:5e, or in the dotted form of Cisco devices, e.g. 001a.2b3c.4d5e
+	macRegex = `(?i)\b[0-9a-f]{2}(?::[0-9a-f]{2}){5}\b|\b[0-9a-f]{2}(?:-[0-9a-f]{2}){5}\b|\b[0-9a-f]{4}(?:\.[0-9a-f]{4}){2}\b`
+
+	// macDigits is the number of hex digits of a MAC address
+	macDigits = 12
+)
+
+// surrogatesMACs reports whether the tokens of category are surrogate MAC
+// addresses. Formats that replace the shape of every token take precedence.
+func (m *Masker) surrogatesMACs(category string) bool {
+	if category != macPattern {
+		return false
+	}
+	switch m.tokenFormat(category) {
+	case tokenFormatUUID, tokenFormatEnvelope:
+		return false
+	default:
+		return true
+	}
+}
+
+// macToken derives a surrogate MAC address of originalValue from the hash of
+// seed. The surrogate is a unicast, locally administered address, so it never
+// names the hardware of a real vendor, and it keeps the separators and letter
+// case of the original so downstream tooling still parses it. Values that are
+// not 12 hex digits long are reported as such.
+func (m *Masker) macToken(originalValue, seed string) (string, bool) {
+	digits := 0
+	for i := 0; i < len(originalValue); i++ {
+		if isHexDigit(originalValue[i]) {
+			digits++
+		}
+	}
+	if digits != macDigits {
+		return "", false
+	}
+
+	hash := m.digest(seed)
+	address := hash[:macDigits/2]
+	// Clear the multicast bit and set the locally administered bit
+	address[0] = address[0]&^0x01 | 0x02
+
+	hexDigits := "0123456789abcdef"
+	if strings.ToUpper(originalValue) == originalValue && strings.ContainsAny(originalValue, "ABCDEF") {
+		hexDigits = "0123456789ABCDEF"
+	}
+
+	token := []byte(originalValue)
+	next := 0
+	for i, c := range token {
+		if !isHexDigit(c) {
+			continue
+		}
+		nibble := address[next/2] >> 4
+		if next%2 == 1 {
+			nibble = address[next/2] & 0x0f
+		}
+		token[i] = hexDigits[nibble]
+		next++
+	}
+	return string(token), true
+}
+
+// isHexDigit reports whether c is a hex digit of either case
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
@@ -0,0 +1,75 @@
+package masker
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMACSurrogates(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{DefaultPatterns()[2]}
+	require.Equal(t, macPattern, cfg.Patterns[0].Name)
+	m, _ := newTestMasker(t, &cfg)
+	ctx := context.Background()
+
+	testCases := []struct {
+		name  string
+		value string
+		shape string
+	}{
+		{name: "colons", value: "00:1a:2b:3c:4d:5e", shape: `^[0-9a-f]{2}(:[0-9a-f]{2}){5}$`},
+		{name: "hyphens", value: "00-1A-2B-3C-4D-5E", shape: `^[0-9A-F]{2}(-[0-9A-F]{2}){5}$`},
+		{name: "dotted", value: "001a.2b3c.4d5e", shape: `^[0-9a-f]{4}(\.[0-9a-f]{4}){2}$`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			masked := m.MaskString(ctx, "lease "+tc.value+" renewed")
+			token := strings.TrimSuffix(strings.TrimPrefix(masked, "lease "), " renewed")
+			assert.Regexp(t, tc.shape, token)
+			assert.NotEqual(t, tc.value, token)
+
+			// The surrogate is a unicast, locally administered address
+			hw, err := net.ParseMAC(token)
+			require.NoError(t, err)
+			assert.Equal(t, byte(0x02), hw[0]&0x03)
+			assert.Equal(t, masked, m.MaskString(ctx, "lease "+tc.value+" renewed"))
+		})
+	}
+
+	// Mixed separators are not MAC addresses
+	assert.Equal(t, "lease 00:1a-2b:3c-4d:5e", m.MaskString(ctx, "lease 00:1a-2b:3c-4d:5e"))
+}
+
+func TestMACTokenFormat(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenFormat = tokenFormatUUID
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// Formats replacing the shape of every token win over surrogates
+	assert.Regexp(t, `^[0-9a-f]{8}-`, m.generateMaskedValue("00:1a:2b:3c:4d:5e", macPattern))
+
+	// Values of other lengths get a hashed token
+	cfg.TokenFormat = tokenFormatDefault
+	assert.Regexp(t, `^MAC-[0-9a-f]{12}$`, m.generateMaskedValue("00:1a:2b", macPattern))
+}
+
+func TestMACDefaultPatterns(t *testing.T) {
+	cfg := NewDefaultConfig()
+	m, _ := newTestMasker(t, &cfg)
+
+	// Later patterns, e.g. hostname, never mask the token of an address again
+	fields := strings.Fields(m.MaskString(context.Background(), "lease 00:1a:2b:3c:4d:5e 00-1A-2B-3C-4D-5F 001a.2b3c.4d60"))
+	require.Len(t, fields, 4)
+	for _, token := range fields[1:] {
+		hw, err := net.ParseMAC(token)
+		require.NoError(t, err, token)
+		assert.Equal(t, byte(0x02), hw[0]&0x03)
+	}
+}
@@ -0,0 +1,1114 @@
+package masker
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// Masker detects sensitive values and replaces them with deterministic tokens.
+// A Masker is safe for concurrent use.
+type Masker struct {
+	config           *Config
+	logger           *zap.Logger
+	store            Store
+	publisher        MappingPublisher
+	tracker          AccessTracker
+	policy           *policyHook
+	discovery        *discovery
+	degradation      *degradation
+	fieldsToMask     []string
+	structuredFields map[string]StructuredFieldConfig
+	compiledPatterns []*compiledPattern
+	secretKeyRegex   *regexp.Regexp
+	reserved         *reservedNamespaces
+	fpe              *ff3Cipher
+	hmacKey          []byte
+	newHash          func() hash.Hash
+	fingerprint      string
+	meterProvider    metric.MeterProvider
+	telemetry        *telemetry
+	errLog           *errorLog
+	watchSource      Watchlist
+	watcher          *watcher
+	distinct         *distinctCounter
+	writeBehind      *writeBehind
+
+	// storeCause is the fallback cause while the store is unavailable, see SetStoreError
+	storeCause atomic.Pointer[string]
+
+	// accessCounts buffers access counts until they are flushed to the tracker
+	accessMu     sync.Mutex
+	accessCounts map[string]int64
+}
+
+// Mapping is an association between an original value and its token
+type Mapping struct {
+	// Category is the pattern name or attribute category of the value
+	Category string
+
+	// Token is the masked replacement
+	Token string
+
+	// Original is the sensitive value
+	Original string
+
+	// TTL is how long the mapping is retained (0 = no expiration)
+	TTL time.Duration
+}
+
+// MappingPublisher is notified of every mapping newly created by a Masker,
+// e.g. to replicate the token store to another region
+type MappingPublisher interface {
+	Publish(ctx context.Context, mapping Mapping)
+}
+
+// Option configures optional Masker behavior
+type Option func(*Masker)
+
+// WithMappingPublisher publishes every newly created mapping to p
+func WithMappingPublisher(p MappingPublisher) Option {
+	return func(m *Masker) {
+		m.publisher = p
+	}
+}
+
+// WithAccessTracker counts every lookup of a mapping in t
+func WithAccessTracker(t AccessTracker) Option {
+	return func(m *Masker) {
+		m.tracker = t
+	}
+}
+
+type compiledPattern struct {
+	name         string
+	regex        *regexp.Regexp
+	maskedPrefix string
+	tokenFormat  string
+	tokenLength  int
+	strategy     StrategyConfig
+	lowPriority  bool
+	valid        func(string) bool
+	filter       MatchFilterConfig
+
+	// literal starts every match, so texts without it are skipped
+	literal string
+}
+
+// findAllIndex returns the locations of the non-empty matches of the pattern in
+// text that pass its match filter and validation, narrowed by the filter
+func (p *compiledPattern) findAllIndex(text string) [][]int {
+	if p.absent(text) {
+		return nil
+	}
+	locs := p.regex.FindAllStringIndex(text, -1)
+	return slices.DeleteFunc(locs, func(loc []int) bool {
+		if !p.filter.apply(text, loc) {
+			return true
+		}
+		return p.valid != nil && !p.valid(text[loc[0]:loc[1]])
+	})
+}
+
+// find returns the first kept match of the pattern in text, or "" when there is none
+func (p *compiledPattern) find(text string) string {
+	if p.absent(text) {
+		return ""
+	}
+	if p.valid == nil && !p.filter.Enabled() {
+		return p.regex.FindString(text)
+	}
+	if locs := p.findAllIndex(text); len(locs) > 0 {
+		return text[locs[0][0]:locs[0][1]]
+	}
+	return ""
+}
+
+// matches reports whether text contains a kept match of the pattern
+func (p *compiledPattern) matches(text string) bool {
+	return p.find(text) != ""
+}
+
+// New creates a Masker for cfg. The store may be nil when cfg does not require one.
+func New(cfg *Config, store Store, logger *zap.Logger, opts ...Option) (*Masker, error) {
+	// Compile regex patterns
+	patterns := cfg.effectivePatterns()
+	compiledPatterns := make([]*compiledPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile(pattern.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex pattern '%s': %w", pattern.Name, err)
+		}
+		if pattern.namesFile != "" {
+			if pattern.valid, err = patientNameValidator(pattern.namesFile); err != nil {
+				return nil, err
+			}
+		}
+		if pattern.valid == nil && pattern.Regex == ipv6Regex {
+			pattern.valid = validIPv6
+		}
+		literal := literalPrefix(pattern.Regex)
+		compiledPatterns = append(compiledPatterns, &compiledPattern{
+			name:         pattern.Name,
+			regex:        regex,
+			maskedPrefix: pattern.MaskedPrefix,
+			tokenFormat:  pattern.TokenFormat,
+			tokenLength:  pattern.TokenLength,
+			strategy:     pattern.strategy(),
+			lowPriority:  pattern.Priority == priorityLow,
+			valid:        pattern.valid,
+			filter:       cfg.MatchFilter,
+			literal:      literal,
+		})
+	}
+
+	hmacKey, err := cfg.loadHMACKey()
+	if err != nil {
+		return nil, err
+	}
+	newHash, err := cfg.hashFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Masker{
+		config:           cfg,
+		logger:           logger,
+		store:            store,
+		hmacKey:          hmacKey,
+		newHash:          newHash,
+		fieldsToMask:     cfg.effectiveFieldsToMask(),
+		structuredFields: cfg.structuredFieldsByKey(),
+		compiledPatterns: compiledPatterns,
+		errLog:           newErrorLog(logger, cfg.ErrorLogInterval),
+		accessCounts:     map[string]int64{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if cfg.SecretKeys.Enabled {
+		regex, err := regexp.Compile(cfg.SecretKeys.KeyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile secret_keys key_regex: %w", err)
+		}
+		m.secretKeyRegex = regex
+	}
+
+	reserved, err := cfg.ReservedNamespaces.parse()
+	if err != nil {
+		return nil, err
+	}
+	m.reserved = reserved
+
+	if cfg.FPEKey != "" {
+		key, err := cfg.fpeKey()
+		if err != nil {
+			return nil, err
+		}
+		if m.fpe, err = newFF3Cipher(key); err != nil {
+			return nil, fmt.Errorf("failed to create fpe cipher: %w", err)
+		}
+	}
+
+	if cfg.Watchlist.Enabled && m.watchSource != nil && store != nil {
+		m.watcher = newWatcher(m.watchSource, &cfg.Watchlist, func(err error) {
+			m.logWarn("Failed to load watched tokens", err)
+		})
+	}
+
+	if m.meterProvider != nil {
+		telemetry, err := newTelemetry(m.meterProvider)
+		if err != nil {
+			return nil, err
+		}
+		m.telemetry = telemetry
+
+		if cfg.DistinctCounts.Enabled {
+			m.distinct = newDistinctCounter(&cfg.DistinctCounts)
+		}
+	}
+
+	if cfg.LatencyBudget.Enabled() {
+		m.degradation = newDegradation(&cfg.LatencyBudget, logger)
+	}
+
+	if cfg.Discovery.Enabled {
+		m.discovery = newDiscovery(cfg.Discovery.Window, logger)
+	}
+
+	if cfg.WriteBehind.Enabled && store != nil {
+		if err := m.startWriteBehind(&cfg.WriteBehind); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.OPA.Enabled() {
+		policy, err := newPolicyHook(context.Background(), &cfg.OPA)
+		if err != nil {
+			return nil, err
+		}
+		m.policy = policy
+	}
+
+	fingerprint, err := cfg.Fingerprint()
+	if err != nil {
+		return nil, err
+	}
+	m.fingerprint = fingerprint
+	return m, nil
+}
+
+// Fingerprint returns the fingerprint of the configuration the Masker was created with
+func (m *Masker) Fingerprint() string {
+	return m.fingerprint
+}
+
+// MaskLogs masks every log record and resource in ld in place. When an OPA policy
+// is configured, records it skips are left unchanged and records it drops are
+// removed, as are records holding cardholder data when cardholder_data is enabled.
+// Records not selected by record_flags are left unchanged.
+func (m *Masker) MaskLogs(ctx context.Context, ld plog.Logs) {
+	defer m.observeSince(time.Now())
+
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+			if m.policy != nil || m.config.CardholderData.Enabled {
+				sl.LogRecords().RemoveIf(func(lr plog.LogRecord) bool {
+					return m.config.RecordFlags.matches(lr) && m.filterLogRecord(ctx, rl.Resource(), lr)
+				})
+				continue
+			}
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				if lr := sl.LogRecords().At(k); m.config.RecordFlags.matches(lr) {
+					m.MaskLogRecord(ctx, lr)
+				}
+			}
+		}
+
+		// The resource is masked after its records so the policy sees its original values
+		m.maskResource(ctx, rl.Resource())
+	}
+
+	if err := m.FlushAccess(ctx); err != nil {
+		m.logWarn("Failed to record mapping access counts", err)
+	}
+}
+
+// FlushAccess writes the buffered access counts to the tracker
+func (m *Masker) FlushAccess(ctx context.Context) error {
+	if m.tracker == nil {
+		return nil
+	}
+
+	m.accessMu.Lock()
+	counts := m.accessCounts
+	m.accessCounts = map[string]int64{}
+	m.accessMu.Unlock()
+
+	return m.tracker.RecordAccess(ctx, counts, time.Now())
+}
+
+// countAccess buffers an access of the mapping whose unmask key is key
+func (m *Masker) countAccess(key string) {
+	if m.tracker == nil {
+		return
+	}
+
+	m.accessMu.Lock()
+	m.accessCounts[key]++
+	m.accessMu.Unlock()
+}
+
+// Warmup loads both directions of the n most accessed mappings through the
+// store, which fills a local cache in front of it. It returns the number of
+// mappings loaded.
+func (m *Masker) Warmup(ctx context.Context, n int) (int, error) {
+	if m.tracker == nil || m.store == nil {
+		return 0, nil
+	}
+
+	stats, err := m.tracker.TopAccessed(ctx, n)
+	if err != nil {
+		return 0, err
+	}
+
+	loaded := 0
+	for _, stat := range stats {
+		category, _, ok := unmaskKeyParts(stat.Key)
+		if !ok {
+			continue
+		}
+		original, found, err := m.store.Get(ctx, stat.Key)
+		if err != nil {
+			return loaded, err
+		}
+		if !found {
+			continue
+		}
+		if _, found, err = m.store.Get(ctx, MaskKey(category, original)); err != nil {
+			return loaded, err
+		}
+		if found {
+			loaded++
+		}
+	}
+	return loaded, nil
+}
+
+// MaskLogRecord masks the configured attributes and body patterns of lr in place
+func (m *Masker) MaskLogRecord(ctx context.Context, lr plog.LogRecord) {
+	// Compute the routing hint before values are replaced
+	if m.config.RoutingKeyAttribute != "" {
+		if routingKey, ok := m.routingKey(lr); ok {
+			defer lr.Attributes().PutStr(m.config.RoutingKeyAttribute, routingKey)
+		}
+	}
+
+	if m.discovery != nil {
+		m.discovery.observe(lr, m.fieldsToMask, m.compiledPatterns)
+	}
+
+	if m.config.DetectOnly.Enabled {
+		m.detectLogRecord(ctx, lr)
+		return
+	}
+
+	// Under the detect_only step values are left unchanged and only flagged
+	if m.degradation.active(stepDetectOnly) {
+		_, detected := m.routingKey(lr)
+		lr.Attributes().PutBool(detectedAttribute, detected)
+		lr.Attributes().PutStr(degradationAttribute, stepDetectOnly)
+		return
+	}
+
+	// Collect deep links to the unmask API for pattern matches
+	lookups := &lookupCollector{masker: m}
+	onMask := lookups.onMask()
+
+	var matches int64
+	if m.telemetry != nil {
+		onMask = countMatches(onMask, &matches)
+	}
+	bodySize := len(lr.Body().Str())
+
+	maskedKeys := m.maskAttributes(ctx, lr.Attributes(), onMask)
+
+	// Mask patterns in log body
+	switch lr.Body().Type() {
+	case pcommon.ValueTypeStr:
+		originalBody := lr.Body().Str()
+		maskedBody, transcoded := m.maskEncodedBody(ctx, originalBody, onMask)
+		if !transcoded {
+			maskedBody = m.maskBody(ctx, originalBody, onMask)
+		}
+		if maskedBody != originalBody {
+			lr.Body().SetStr(maskedBody)
+		}
+	case pcommon.ValueTypeMap:
+		m.maskMapBody(ctx, lr.Body().Map(), onMask)
+	case pcommon.ValueTypeSlice:
+		m.maskSliceBody(ctx, lr.Body().Slice(), onMask)
+	}
+
+	m.telemetry.recordLog(ctx, bodySize, lr.Body().Type() == pcommon.ValueTypeStr, matches+int64(len(maskedKeys)))
+	if isIrreversible(ctx) {
+		// Irreversible tokens have no mapping to look up
+		maskedKeys, lookups.urls = nil, nil
+	}
+	m.annotate(lr.Attributes(), maskedKeys, lookups.urls)
+}
+
+// annotate adds the lookup URL companions of maskedKeys and the pattern matches,
+// and the provenance tag, to the attributes of a masked record. It is called
+// after scanning so the added attributes are never masked themselves.
+func (m *Masker) annotate(attrs pcommon.Map, maskedKeys, lookupURLs []string) {
+	if m.config.EnrichWithLookupURL != "" {
+		for _, k := range maskedKeys {
+			v, _ := attrs.Get(k)
+			if v.Type() != pcommon.ValueTypeStr || !m.config.FieldStrategies[k].tokenizes() {
+				// Typed replacements and other strategies do not hold the token to look up
+				continue
+			}
+			attrs.PutStr(k+".lookup_url", m.lookupURL(m.namespaced(attributeCategory(k)), v.Str()))
+		}
+		if len(lookupURLs) > 0 {
+			urls := attrs.PutEmptySlice(lookupURLsAttribute)
+			for _, link := range lookupURLs {
+				urls.AppendEmpty().SetStr(link)
+			}
+		}
+	}
+
+	if step := m.degradation.current(); step != "" {
+		attrs.PutStr(degradationAttribute, step)
+	}
+
+	if provenance := m.config.Provenance; provenance.Enabled() {
+		attrs.PutStr(provenance.Attribute, provenance.Policy)
+		if provenance.Profile != "" {
+			attrs.PutStr(provenance.Attribute+".profile", provenance.Profile)
+		}
+	}
+}
+
+// lookupCollector collects the distinct lookup URLs of the tokens created for a record
+type lookupCollector struct {
+	masker *Masker
+	urls   []string
+}
+
+// onMask returns the callback collecting lookup URLs, or nil when enrichment is disabled
+func (c *lookupCollector) onMask() func(category, token string) {
+	if c.masker.config.EnrichWithLookupURL == "" {
+		return nil
+	}
+	return func(category, token string) {
+		if !c.masker.tokenized(category) {
+			return
+		}
+		if link := c.masker.lookupURL(c.masker.namespaced(category), token); !slices.Contains(c.urls, link) {
+			c.urls = append(c.urls, link)
+		}
+	}
+}
+
+// maskAttributes masks the configured fields of attrs in place and scans the
+// remaining string values as configured. It returns the keys of masked fields.
+func (m *Masker) maskAttributes(ctx context.Context, attrs pcommon.Map, onMask func(category, token string)) []string {
+	return m.scanAttributes(ctx, attrs, m.config.ScanAllAttributes, onMask)
+}
+
+// scanAttributes is maskAttributes with scanAll deciding whether the remaining
+// string values are pattern-scanned
+func (m *Masker) scanAttributes(ctx context.Context, attrs pcommon.Map, scanAll bool, onMask func(category, token string)) []string {
+	attrs.RemoveIf(func(k string, _ pcommon.Value) bool {
+		return m.drops(m.fieldsToMask, k)
+	})
+
+	var maskedKeys []string
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if m.maskAttribute(ctx, k, v, scanAll, onMask) {
+			maskedKeys = append(maskedKeys, k)
+		}
+		return true
+	})
+	return maskedKeys
+}
+
+// maskAttribute masks the attribute value v of key k in place, pattern-scanning
+// other string values when scanAll is set. It reports whether v was a configured
+// field that was masked whole.
+func (m *Masker) maskAttribute(ctx context.Context, k string, v pcommon.Value, scanAll bool, onMask func(category, token string)) bool {
+	if slices.Contains(m.fieldsToMask, k) {
+		if err := m.maskField(ctx, k, v); err != nil {
+			m.logError("Failed to mask attribute", err, zap.String("key", k))
+			return false
+		}
+		return true
+	}
+
+	// Structured fields keep their format and only tokenize the sensitive parts
+	if field, ok := m.structuredFields[k]; ok {
+		m.maskStructuredValue(ctx, field, v, onMask)
+		return false
+	}
+
+	// Access log fields are capped and scanned in full
+	if m.config.AccessLogFields.Enabled && v.Type() == pcommon.ValueTypeStr && slices.Contains(m.config.AccessLogFields.Keys, k) {
+		originalValue := v.Str()
+		maskedValue := m.scanPatterns(ctx, truncate(originalValue, m.config.AccessLogFields.MaxBytes), onMask)
+		if maskedValue != originalValue {
+			v.SetStr(maskedValue)
+		}
+		return false
+	}
+
+	// Scan remaining string attributes for patterns unless excluded
+	if scanAll && v.Type() == pcommon.ValueTypeStr && !slices.Contains(m.config.ExcludeKeys, k) {
+		originalValue := v.Str()
+		maskedValue := m.maskString(ctx, originalValue, onMask)
+		if maskedValue != originalValue {
+			v.SetStr(maskedValue)
+		}
+	}
+	return false
+}
+
+// routingKey returns a hash of the first sensitive value in the record.
+// Configured fields take precedence over pattern matches in the body.
+func (m *Masker) routingKey(lr plog.LogRecord) (string, bool) {
+	for _, field := range m.fieldsToMask {
+		if v, ok := lr.Attributes().Get(field); ok {
+			return hex.EncodeToString(m.digest(v.AsString() + m.namespaced(attributeCategory(field))))[:16], true
+		}
+	}
+
+	if lr.Body().Type() == pcommon.ValueTypeStr {
+		for _, pattern := range m.compiledPatterns {
+			if match := pattern.find(lr.Body().Str()); match != "" {
+				return hex.EncodeToString(m.digest(match + m.namespaced(pattern.name)))[:16], true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// MaskString replaces every pattern match in text with its token
+func (m *Masker) MaskString(ctx context.Context, text string) string {
+	return m.maskString(ctx, text, nil)
+}
+
+// maskString replaces every pattern match in text with its token and calls
+// onMask, when set, for every token
+func (m *Masker) maskString(ctx context.Context, text string, onMask func(category, token string)) string {
+	// Only scan up to the configured limit. The remainder is dropped with
+	// scan_overflow truncate, and otherwise passed through and counted.
+	if limit := m.config.effectiveMaxScanBytes(); limit > 0 && len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		masked := m.scanPatterns(ctx, text[:cut], onMask)
+		if m.config.ScanOverflow == scanOverflowTruncate {
+			return masked
+		}
+		m.telemetry.recordFallback(ctx, fallbackUnscanned, causeScanLimit)
+		return masked + text[cut:]
+	}
+
+	return m.scanPatterns(ctx, text, onMask)
+}
+
+// scanPatterns replaces every pattern match in the whole of text with its token
+func (m *Masker) scanPatterns(ctx context.Context, text string, onMask func(category, token string)) string {
+	result := m.maskSecretKeys(ctx, text, onMask)
+	found := result != text
+	absent := 0
+	var tokens [][2]int
+	for _, pattern := range m.compiledPatterns {
+		if pattern.lowPriority && m.degradation.active(stepDisableLowPriority) {
+			continue
+		}
+		if pattern.absent(result) {
+			absent++
+			if m.config.ShortCircuit.Enabled() && absent >= m.config.ShortCircuit.AbsentCategories && !found {
+				break
+			}
+			continue
+		}
+
+		// Only the matched occurrences are replaced, so a short match is never
+		// replaced inside a longer word. Matches overlapping the token of an
+		// earlier pattern are skipped, so a token is never masked again, e.g. an
+		// ipv6 token by the hostname pattern.
+		var replacements []replacement
+		for _, loc := range pattern.findAllIndex(result) {
+			if overlapsToken(tokens, loc) {
+				continue
+			}
+			match := result[loc[0]:loc[1]]
+			maskedValue, err := m.maskMatch(ctx, pattern, match)
+			if err != nil {
+				m.logError("Failed to mask value", err,
+					zap.String("pattern", pattern.name),
+					zap.String("value", match))
+				continue
+			}
+			replacements = append(replacements, replacement{start: loc[0], end: loc[1], token: maskedValue})
+			if onMask != nil {
+				onMask(pattern.name, maskedValue)
+			}
+		}
+		if len(replacements) > 0 {
+			result, tokens = replace(result, replacements, tokens)
+			found = true
+		}
+	}
+	return result
+}
+
+// replacement replaces the match of a pattern between start and end with token
+type replacement struct {
+	start, end int
+	token      string
+}
+
+// replace applies the sorted replacements to text. tokens are the sorted spans
+// of the tokens of earlier patterns, which no replacement overlaps. It returns
+// the new text and the spans of all its tokens.
+func replace(text string, replacements []replacement, tokens [][2]int) (string, [][2]int) {
+	var masked strings.Builder
+	spans := make([][2]int, 0, len(tokens)+len(replacements))
+	last, shift, next := 0, 0, 0
+	for _, r := range replacements {
+		for ; next < len(tokens) && tokens[next][0] < r.start; next++ {
+			spans = append(spans, [2]int{tokens[next][0] + shift, tokens[next][1] + shift})
+		}
+		masked.WriteString(text[last:r.start])
+		spans = append(spans, [2]int{masked.Len(), masked.Len() + len(r.token)})
+		masked.WriteString(r.token)
+		shift += len(r.token) - (r.end - r.start)
+		last = r.end
+	}
+	for ; next < len(tokens); next++ {
+		spans = append(spans, [2]int{tokens[next][0] + shift, tokens[next][1] + shift})
+	}
+	masked.WriteString(text[last:])
+	return masked.String(), spans
+}
+
+// overlapsToken reports whether the match at loc overlaps one of the sorted
+// token spans
+func overlapsToken(tokens [][2]int, loc []int) bool {
+	i, _ := slices.BinarySearchFunc(tokens, loc[0], func(span [2]int, start int) int {
+		return cmp.Compare(span[1], start+1)
+	})
+	return i < len(tokens) && tokens[i][0] < loc[1]
+}
+
+// truncatedSuffix marks values cut by truncate
+const truncatedSuffix = "...[truncated]"
+
+// truncate cuts text to at most maxBytes at a UTF-8 boundary, marking the cut
+func truncate(text string, maxBytes int) string {
+	if maxBytes <= 0 || len(text) <= maxBytes {
+		return text
+	}
+
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + truncatedSuffix
+}
+
+// lookupURLsAttribute lists the lookup URLs of the tokens found by the patterns
+const lookupURLsAttribute = "masking.lookup_urls"
+
+// lookupURL returns the deep link resolving token through the unmask API
+func (m *Masker) lookupURL(category, token string) string {
+	return strings.TrimSuffix(m.config.EnrichWithLookupURL, "/") + "/v1/tokens/" + url.PathEscape(category) + "/" + url.PathEscape(token)
+}
+
+// MaskValue returns the token for originalValue within category, creating and
+// storing a new mapping when none exists yet
+func (m *Masker) MaskValue(ctx context.Context, originalValue, category string) (string, error) {
+	token, err := m.maskValue(ctx, originalValue, category)
+	if err != nil {
+		// Callers leave the value unmasked
+		m.recordFallback(ctx, fallbackUnmasked, err)
+		return "", err
+	}
+	m.observeWatched(ctx, originalValue, category, token)
+	m.observeDistinct(originalValue, category)
+	return token, nil
+}
+
+// SetStoreError marks the store as unavailable because of err, e.g. while it is
+// connected in the background, or as available again when err is nil. While it
+// is unavailable, tokens are derived without the store like under the
+// deterministic degradation step, and their mappings are stored once the values
+// are masked again after it recovered.
+func (m *Masker) SetStoreError(err error) {
+	if err == nil {
+		m.storeCause.Store(nil)
+		return
+	}
+	cause := fallbackCause(err)
+	m.storeCause.Store(&cause)
+}
+
+// maskValue returns the token of originalValue in category
+func (m *Masker) maskValue(ctx context.Context, originalValue, category string) (string, error) {
+	if isIrreversible(ctx) {
+		return m.irreversibleToken(originalValue, category), nil
+	}
+
+	// Lightweight mode relies solely on deterministic HMAC tokens, as does the
+	// deterministic step of the latency budget
+	if m.config.isLightweight() {
+		return m.generateMaskedValue(originalValue, category), nil
+	}
+	if m.degradation.active(stepDeterministic) {
+		m.telemetry.recordFallback(ctx, fallbackDeterministic, causeCircuitOpen)
+		return m.generateMaskedValue(originalValue, category), nil
+	}
+	if cause := m.storeCause.Load(); cause != nil {
+		m.telemetry.recordFallback(ctx, fallbackStoreUnavailable, *cause)
+		return m.generateMaskedValue(originalValue, category), nil
+	}
+
+	if m.store == nil {
+		return "", errors.New("token store is not initialized")
+	}
+
+	// Read-only mode never writes the store, not even access counts
+	if m.config.isReadOnly() {
+		return m.maskReadOnly(ctx, originalValue, category)
+	}
+
+	maskedValue, err := m.maskStored(ctx, originalValue, category)
+	if err != nil {
+		return "", err
+	}
+	m.countAccess(UnmaskKey(m.namespaced(category), maskedValue))
+	return maskedValue, nil
+}
+
+// maskStored returns the token of originalValue held by the store, creating
+// its mapping when there is none
+func (m *Masker) maskStored(ctx context.Context, originalValue, category string) (string, error) {
+	if m.config.isActiveActive() {
+		return m.maskDerived(ctx, originalValue, category), nil
+	}
+	if m.writeBehind != nil {
+		return m.maskWriteBehind(ctx, originalValue, category), nil
+	}
+
+	// Check if masked value already exists in the store
+	storeCategory := m.namespaced(category)
+	cachedValue, found, err := m.store.Get(ctx, MaskKey(storeCategory, originalValue))
+	if err != nil {
+		return "", err
+	}
+	m.telemetry.recordLookup(ctx, found)
+	if found {
+		return cachedValue, nil
+	}
+
+	// Not in the store, generate new masked value. Another collector may create
+	// the mapping at the same time, so the first one wins, and a token taken by
+	// another value in the meantime is derived again.
+	for range maxCollisionRetries {
+		maskedValue, err := m.freeToken(ctx, originalValue, category)
+		if err != nil {
+			return "", err
+		}
+		token, err := m.createMapping(ctx, originalValue, storeCategory, maskedValue)
+		if !errors.Is(err, errTokenTaken) {
+			return token, err
+		}
+	}
+	return "", errCollisionsExhausted(storeCategory)
+}
+
+// maskDerived returns the HMAC derived token for originalValue. The store is
+// only a cache of the mapping: store errors never fail masking and a cached
+// token that differs from the derived one, e.g. one written before a key
+// rotation, is overwritten rather than returned.
+func (m *Masker) maskDerived(ctx context.Context, originalValue, category string) string {
+	maskedValue := m.generateMaskedValue(originalValue, category)
+	storeCategory := m.namespaced(category)
+
+	cachedValue, found, err := m.store.Get(ctx, MaskKey(storeCategory, originalValue))
+	if err != nil {
+		m.logWarn("Failed to read cached mapping", err)
+		m.recordFallback(ctx, fallbackDerivedToken, err)
+		return maskedValue
+	}
+	m.telemetry.recordLookup(ctx, found && cachedValue == maskedValue)
+	if found && cachedValue == maskedValue {
+		return maskedValue
+	}
+	if found {
+		m.logger.Warn("Replacing cached token that differs from the derived token", zap.String("category", storeCategory))
+	}
+
+	// Derived tokens cannot be derived again, so a token whose reverse mapping
+	// holds another value is left without a mapping
+	if err := m.storeMapping(ctx, originalValue, storeCategory, maskedValue); err != nil {
+		m.logWarn("Failed to store derived token", err)
+		m.recordFallback(ctx, fallbackMappingNotStored, err)
+	}
+	return maskedValue
+}
+
+// storeMapping writes both directions of a new mapping within the namespaced
+// category and publishes it. It returns errTokenTaken without writing anything
+// when the reverse mapping holds another value.
+func (m *Masker) storeMapping(ctx context.Context, originalValue, category, maskedValue string) error {
+	holder, found, err := m.store.Get(ctx, UnmaskKey(category, maskedValue))
+	if err != nil {
+		m.logError("Failed to store masked value", err)
+		m.recordFallback(ctx, fallbackMappingNotStored, err)
+		// Continue anyway, we'll use the generated value
+		return nil
+	}
+	if found && holder != originalValue {
+		return errTokenTaken
+	}
+
+	ttl := m.mappingTTL()
+	if err := m.store.Set(ctx, MaskKey(category, originalValue), maskedValue, ttl); err != nil {
+		m.logError("Failed to store masked value", err)
+		m.recordFallback(ctx, fallbackMappingNotStored, err)
+		// Continue anyway, we'll use the generated value
+	}
+
+	// Also store reverse mapping for lookups
+	_ = m.store.Set(ctx, UnmaskKey(category, maskedValue), originalValue, ttl)
+
+	m.publishMapping(ctx, originalValue, category, maskedValue, ttl)
+	return nil
+}
+
+// mappingTTL returns how long new mappings are kept, or 0 to keep them forever
+func (m *Masker) mappingTTL() time.Duration {
+	if m.config.TokenTTL > 0 {
+		return time.Duration(m.config.TokenTTL) * time.Second
+	}
+	return 0
+}
+
+// publishMapping publishes a new mapping when replication is enabled
+func (m *Masker) publishMapping(ctx context.Context, originalValue, category, maskedValue string, ttl time.Duration) {
+	if m.publisher != nil {
+		m.publisher.Publish(ctx, Mapping{
+			Category: category,
+			Token:    maskedValue,
+			Original: originalValue,
+			TTL:      ttl,
+		})
+	}
+}
+
+// Prevalence is how often a token was seen in masked telemetry
+type Prevalence struct {
+	Category string
+	Token    string
+	Count    int64
+	LastSeen time.Time
+}
+
+// TopTokens returns the n most frequently masked tokens. Original values are
+// never returned, so the report is safe to share.
+func (m *Masker) TopTokens(ctx context.Context, n int) ([]Prevalence, error) {
+	if m.tracker == nil || m.store == nil {
+		return nil, errors.New("access tracking is not enabled")
+	}
+
+	stats, err := m.tracker.TopAccessed(ctx, n)
+	if err != nil {
+		return nil, err
+	}
+
+	prevalence := make([]Prevalence, 0, len(stats))
+	for _, stat := range stats {
+		category, token, ok := unmaskKeyParts(stat.Key)
+		if !ok {
+			continue
+		}
+
+		// Mappings that expired since they were counted are left out
+		_, found, err := m.store.Get(ctx, stat.Key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		prevalence = append(prevalence, Prevalence{
+			Category: category,
+			Token:    token,
+			Count:    stat.Count,
+			LastSeen: stat.LastSeen,
+		})
+	}
+	return prevalence, nil
+}
+
+// MaskKey returns the store key holding the token for originalValue
+func MaskKey(category, originalValue string) string {
+	return fmt.Sprintf("mask:%s:%s", category, originalValue)
+}
+
+// UnmaskKey returns the store key holding the original value for token
+func UnmaskKey(category, token string) string {
+	return fmt.Sprintf("unmask:%s:%s", category, token)
+}
+
+// unmaskKeyParts returns the category and token of a key created by UnmaskKey
+func unmaskKeyParts(key string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(key, "unmask:")
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
+
+// namespaced returns the category under which mappings of category are stored.
+// Each token namespace derives its own tokens, so datasets masked for different
+// destinations cannot be joined on shared tokens.
+func (m *Masker) namespaced(category string) string {
+	if m.config.TokenNamespace == "" {
+		return category
+	}
+	return m.config.TokenNamespace + "/" + category
+}
+
+// attributeCategory returns the category used for a masked attribute key
+func attributeCategory(key string) string {
+	return "attribute_" + key
+}
+
+// tokenFormat returns the token format of category. A pattern's own format
+// takes precedence over the configured default.
+func (m *Masker) tokenFormat(category string) string {
+	for _, pattern := range m.compiledPatterns {
+		if pattern.name == category && pattern.tokenFormat != "" {
+			return pattern.tokenFormat
+		}
+	}
+	return m.config.TokenFormat
+}
+
+// uuidToken formats the first 16 bytes of hash as a version 4 UUID
+func uuidToken(hash []byte) string {
+	var b [16]byte
+	copy(b[:], hash)
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// generateMaskedValue derives the token of originalValue in category. Tokens
+// pointing at a reserved namespace are regenerated with a counter suffix, which
+// keeps them deterministic for a given configuration.
+func (m *Masker) generateMaskedValue(originalValue, category string) string {
+	return m.generateAttempt(originalValue, category, 0)
+}
+
+// generateAttempt derives the token of originalValue in category like
+// generateMaskedValue. Attempts after the first derive another token from a
+// suffixed seed, for tokens whose reverse mapping holds another value.
+func (m *Masker) generateAttempt(originalValue, category string, attempt int) string {
+	seed := originalValue + m.namespaced(category)
+	if attempt > 0 {
+		seed += "~" + strconv.Itoa(attempt)
+	}
+
+	// The email strategy keeps the domain whatever the token format
+	if m.strategyOf(category).Strategy == maskingStrategyEmail {
+		if token, ok := m.emailToken(originalValue, seed, category); ok {
+			return token
+		}
+	}
+	// Values whose digits cannot be enciphered get a token of the default format.
+	// Enciphering is a permutation, so its tokens never collide.
+	if m.fpe != nil && m.tokenFormat(category) == tokenFormatFPE {
+		if token, ok := m.fpeToken(originalValue, category); ok {
+			return token
+		}
+	}
+	if m.surrogatesCards(category) {
+		if token, ok := m.cardToken(originalValue, seed); ok {
+			return token
+		}
+	}
+	if m.preservesSubnet(category) {
+		if token, ok := m.subnetToken(originalValue, seed); ok {
+			return token
+		}
+	}
+	if m.surrogatesMACs(category) {
+		if token, ok := m.macToken(originalValue, seed); ok {
+			return token
+		}
+	}
+	return m.deriveToken(seed, category)
+}
+
+// formatToken formats hash as a token of category
+func (m *Masker) formatToken(hash []byte, category string) string {
+	hashStr := hex.EncodeToString(hash)
+
+	switch m.tokenFormat(category) {
+	case tokenFormatUUID:
+		return uuidToken(hash)
+	case tokenFormatEnvelope:
+		return envelopeToken(hash, m.namespaced(category))
+	case tokenFormatFake:
+		// Categories without a kind keep their default shape
+		if kind, ok := m.fakeKind(category); ok {
+			return fakeToken(hash, kind)
+		}
+	}
+
+	// Create masked value based on category
+	// For IP addresses, generate a fake IP format
+	if category == "ipv4" {
+		return fmt.Sprintf("10.%d.%d.%d",
+			hash[0],
+			hash[1],
+			hash[2],
+		)
+	}
+
+	// For IPv6 addresses, generate an address in the documentation range
+	if category == "ipv6" {
+		return ipv6Token(hash)
+	}
+
+	// For hostnames, generate a fake hostname
+	if category == "hostname" {
+		return fmt.Sprintf("host-%s.%s", hashStr[:m.tokenLength(category, hostTokenLength)], syntheticHostDomain)
+	}
+
+	// For other fields, use prefix + hash
+	prefix := ""
+	for _, pattern := range m.compiledPatterns {
+		if pattern.name == category {
+			prefix = pattern.maskedPrefix
+			break
+		}
+	}
+
+	// Extract category from attribute fields
+	if key, ok := strings.CutPrefix(category, "attribute_"); ok && key != "" {
+		prefix = key + "-"
+	}
+
+	return fmt.Sprintf("%s%s", prefix, hashStr[:m.tokenLength(category, m.config.TokenLength)])
+}
+
+// digest hashes the salted data with HMAC when a key is configured, or the
+// plain hash otherwise, using the configured hash algorithm (SHA-256 by default)
+func (m *Masker) digest(data string) []byte {
+	newHash := m.newHash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+
+	var h hash.Hash
+	if m.hmacKey != nil {
+		h = hmac.New(newHash, m.hmacKey)
+	} else {
+		h = newHash()
+	}
+	h.Write([]byte(m.salted(data)))
+	return h.Sum(nil)
+}
@@ -0,0 +1,611 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+// newTestMasker creates a Masker backed by an in-process Redis server
+func newTestMasker(t *testing.T, cfg *Config) (*Masker, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	cfg.RedisAddr = server.Addr()
+
+	store, err := NewRedisStore(context.Background(), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	m, err := New(cfg, store, zap.NewNop())
+	require.NoError(t, err)
+	return m, server
+}
+
+// ipv4Patterns returns a pattern set containing only the ipv4 pattern
+func ipv4Patterns() []PatternConfig {
+	return []PatternConfig{
+		{
+			Name:  "ipv4",
+			Regex: `\b(?:\d{1,3}\.){3}\d{1,3}\b`,
+		},
+	}
+}
+
+func TestGenerateMaskedValue(t *testing.T) {
+	m := &Masker{
+		config: &Config{},
+		logger: zap.NewNop(),
+		compiledPatterns: []*compiledPattern{
+			{
+				name:         "ipv4",
+				maskedPrefix: "IP-",
+			},
+		},
+	}
+
+	// Test deterministic generation - same input should produce same output
+	value1 := m.generateMaskedValue("1.2.3.4", "ipv4")
+	value2 := m.generateMaskedValue("1.2.3.4", "ipv4")
+	assert.Equal(t, value1, value2, "Same input should produce same masked value")
+
+	// Different inputs should produce different outputs
+	value3 := m.generateMaskedValue("5.6.7.8", "ipv4")
+	assert.NotEqual(t, value1, value3, "Different inputs should produce different masked values")
+}
+
+func TestUUIDTokenFormat(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenFormat = tokenFormatUUID
+	cfg.Patterns = []PatternConfig{
+		{Name: "ipv4", Regex: `\b(?:\d{1,3}\.){3}\d{1,3}\b`, TokenFormat: tokenFormatDefault},
+		{Name: "email", Regex: `\S+@\S+`},
+	}
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	uuidShape := `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`
+	token := m.generateMaskedValue("jdoe@example.com", "email")
+	assert.Regexp(t, uuidShape, token)
+	assert.Equal(t, token, m.generateMaskedValue("jdoe@example.com", "email"))
+	assert.NotEqual(t, token, m.generateMaskedValue("other@example.com", "email"))
+	assert.Regexp(t, uuidShape, m.generateMaskedValue("testuser", "attribute_username"))
+
+	// Patterns can keep the default format
+	assert.Regexp(t, `^10\.\d+\.\d+\.\d+$`, m.generateMaskedValue("192.168.1.1", "ipv4"))
+}
+
+func TestMaskStringWithoutStore(t *testing.T) {
+	cfg := NewDefaultConfig()
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+
+	// Without a store values cannot be tokenized and are left as is
+	text := "User logged in from 192.168.1.1 on host server01.example.com"
+	assert.Equal(t, text, m.MaskString(context.Background(), text))
+}
+
+func TestMaskValue(t *testing.T) {
+	cfg := NewDefaultConfig()
+	m, server := newTestMasker(t, &cfg)
+
+	token, err := m.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, m.generateMaskedValue("192.168.1.1", "ipv4"), token)
+
+	// Both directions of the mapping are stored
+	stored, err := server.Get(MaskKey("ipv4", "192.168.1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, token, stored)
+
+	original, err := server.Get(UnmaskKey("ipv4", token))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", original)
+
+	// Existing mappings are returned from the store
+	server.Set(MaskKey("ipv4", "192.168.1.2"), "10.1.1.1")
+	token, err = m.MaskValue(context.Background(), "192.168.1.2", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.1.1", token)
+}
+
+// recordingPublisher collects published mappings
+type recordingPublisher struct {
+	mappings []Mapping
+}
+
+func (p *recordingPublisher) Publish(_ context.Context, mapping Mapping) {
+	p.mappings = append(p.mappings, mapping)
+}
+
+func TestMaskValuePublishesNewMappings(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TokenTTL = 60
+	m, _ := newTestMasker(t, &cfg)
+
+	publisher := &recordingPublisher{}
+	WithMappingPublisher(publisher)(m)
+
+	token, err := m.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+
+	// Cached mappings are not published again
+	_, err = m.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+
+	require.Equal(t, []Mapping{{
+		Category: "ipv4",
+		Token:    token,
+		Original: "192.168.1.1",
+		TTL:      time.Minute,
+	}}, publisher.mappings)
+}
+
+func TestMaskLogs(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"username"}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Body().SetStr("Test log message with IP 10.0.0.1")
+	lr.Attributes().PutStr("username", "testuser")
+
+	m.MaskLogs(context.Background(), ld)
+
+	assert.Equal(t, "Test log message with IP "+m.generateMaskedValue("10.0.0.1", "ipv4"), lr.Body().Str())
+	username, _ := lr.Attributes().Get("username")
+	assert.Equal(t, m.generateMaskedValue("testuser", "attribute_username"), username.Str())
+}
+
+func TestScanAllAttributes(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"username"}
+	cfg.ScanAllAttributes = true
+	cfg.ExcludeKeys = []string{"gateway"}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("username", "testuser")
+	lr.Attributes().PutStr("client", "connection from 192.168.1.1")
+	lr.Attributes().PutStr("gateway", "192.168.1.254")
+	lr.Attributes().PutInt("port", 443)
+
+	m.MaskLogs(context.Background(), ld)
+
+	username, _ := lr.Attributes().Get("username")
+	assert.Equal(t, m.generateMaskedValue("testuser", "attribute_username"), username.Str())
+
+	client, _ := lr.Attributes().Get("client")
+	assert.Equal(t, "connection from "+m.generateMaskedValue("192.168.1.1", "ipv4"), client.Str())
+
+	gateway, _ := lr.Attributes().Get("gateway")
+	assert.Equal(t, "192.168.1.254", gateway.Str())
+
+	port, _ := lr.Attributes().Get("port")
+	assert.Equal(t, int64(443), port.Int())
+}
+
+func TestScanAllAttributesDisabled(t *testing.T) {
+	cfg := NewDefaultConfig()
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("client", "connection from 192.168.1.1")
+
+	m.MaskLogs(context.Background(), ld)
+
+	client, _ := lr.Attributes().Get("client")
+	assert.Equal(t, "connection from 192.168.1.1", client.Str())
+}
+
+func TestTopTokensWithoutTracking(t *testing.T) {
+	cfg := NewDefaultConfig()
+	m, _ := newTestMasker(t, &cfg)
+
+	_, err := m.TopTokens(context.Background(), 10)
+	require.EqualError(t, err, "access tracking is not enabled")
+}
+
+func TestSemconvFieldsMasking(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{}
+	cfg.SemconvFieldsEnabled = true
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("enduser.id", "jdoe")
+	lr.Attributes().PutStr("client.address", "192.168.1.1")
+	lr.Attributes().PutStr("http.route", "/login")
+
+	m.MaskLogs(context.Background(), ld)
+
+	endUser, _ := lr.Attributes().Get("enduser.id")
+	assert.Equal(t, m.generateMaskedValue("jdoe", "attribute_enduser.id"), endUser.Str())
+	clientAddress, _ := lr.Attributes().Get("client.address")
+	assert.Equal(t, m.generateMaskedValue("192.168.1.1", "attribute_client.address"), clientAddress.Str())
+	route, _ := lr.Attributes().Get("http.route")
+	assert.Equal(t, "/login", route.Str())
+}
+
+func TestTokenNamespace(t *testing.T) {
+	internalCfg := NewDefaultConfig()
+	internal, server := newTestMasker(t, &internalCfg)
+
+	vendorCfg := NewDefaultConfig()
+	vendorCfg.TokenNamespace = "vendor_x"
+	vendorCfg.RedisAddr = server.Addr()
+	store, err := NewRedisStore(context.Background(), &vendorCfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	vendor, err := New(&vendorCfg, store, zap.NewNop())
+	require.NoError(t, err)
+
+	internalToken, err := internal.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	vendorToken, err := vendor.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+
+	// Each namespace gets its own alias in the same format
+	assert.NotEqual(t, internalToken, vendorToken)
+	assert.Regexp(t, `^10\.\d+\.\d+\.\d+$`, vendorToken)
+
+	// Both aliases resolve to the original
+	original, err := server.Get(UnmaskKey("ipv4", internalToken))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", original)
+
+	original, err = server.Get(UnmaskKey("vendor_x/ipv4", vendorToken))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1", original)
+}
+
+func TestAccessLogFields(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.MaxScanBytes = 8
+	cfg.AccessLogFields.Enabled = true
+	cfg.AccessLogFields.MaxBytes = 40
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("grpc.status_description", "upstream 192.168.1.1 refused connection")
+	lr.Attributes().PutStr("http.response.body", `{"error":"user 192.168.1.2 not found","trace":"..."}`)
+	lr.Attributes().PutStr("peer", "192.168.1.3")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// Values are scanned past max_scan_bytes and truncated to max_bytes
+	status, _ := lr.Attributes().Get("grpc.status_description")
+	assert.Equal(t, "upstream "+m.generateMaskedValue("192.168.1.1", "ipv4")+" refused connection", status.Str())
+
+	body, _ := lr.Attributes().Get("http.response.body")
+	assert.Equal(t, `{"error":"user `+m.generateMaskedValue("192.168.1.2", "ipv4")+` not found","t...[truncated]`, body.Str())
+
+	// Other attributes are left alone without scan_all_attributes
+	peer, _ := lr.Attributes().Get("peer")
+	assert.Equal(t, "192.168.1.3", peer.Str())
+}
+
+func TestScanOverflow(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	cfg := NewDefaultConfig()
+	cfg.Mode = modeLightweight
+	cfg.Patterns = ipv4Patterns()
+	cfg.MaxScanBytes = 22
+	m, err := New(&cfg, nil, zap.NewNop(), WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+
+	// The tail is passed through unscanned and counted by default
+	text := "login from 192.168.1.1 and later 192.168.1.2"
+	token := m.generateMaskedValue("192.168.1.1", "ipv4")
+	assert.Equal(t, "login from "+token+" and later 192.168.1.2", m.maskString(context.Background(), text, nil))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	var fallbacks int64
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		if metric.Name != "redismasking.fallbacks" {
+			continue
+		}
+		for _, dp := range metric.Data.(metricdata.Sum[int64]).DataPoints {
+			fallback, _ := dp.Attributes.Value(attribute.Key("fallback"))
+			cause, _ := dp.Attributes.Value(attribute.Key("cause"))
+			assert.Equal(t, fallbackUnscanned, fallback.AsString())
+			assert.Equal(t, causeScanLimit, cause.AsString())
+			fallbacks += dp.Value
+		}
+	}
+	assert.Equal(t, int64(1), fallbacks)
+
+	// With truncate, the tail is dropped
+	cfg.ScanOverflow = scanOverflowTruncate
+	assert.Equal(t, "login from "+token, m.maskString(context.Background(), text, nil))
+
+	// Values within the limit are unaffected
+	assert.Equal(t, "from "+token, m.maskString(context.Background(), "from 192.168.1.1", nil))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "unlimited", truncate("unlimited", 0))
+	assert.Equal(t, "ab...[truncated]", truncate("abcdef", 2))
+	// Multi-byte characters are never split
+	assert.Equal(t, "a...[truncated]", truncate("aéb", 2))
+}
+
+func TestReplace(t *testing.T) {
+	// Spans of earlier tokens move with the replacements before them
+	text, spans := replace("a 1.2.3.4 b c", []replacement{{start: 0, end: 1, token: "xyz"}, {start: 12, end: 13, token: ""}}, [][2]int{{2, 9}})
+	assert.Equal(t, "xyz 1.2.3.4 b ", text)
+	assert.Equal(t, [][2]int{{0, 3}, {4, 11}, {14, 14}}, spans)
+
+	assert.True(t, overlapsToken(spans, []int{10, 12}))
+	assert.False(t, overlapsToken(spans, []int{11, 13}))
+	assert.False(t, overlapsToken(nil, []int{0, 1}))
+}
+
+func TestLightweightMode(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Mode = modeLightweight
+	cfg.HMACKey = "secret"
+	cfg.MaxScanBytes = 32
+	require.False(t, cfg.StoreEnabled())
+
+	m, err := New(&cfg, nil, zap.NewNop())
+	require.NoError(t, err)
+	require.Len(t, m.compiledPatterns, 1)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Body().SetStr("login from 192.168.1.1 and later 192.168.1.2")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// Only the first 32 bytes are scanned
+	expected := "login from " + m.generateMaskedValue("192.168.1.1", "ipv4") + " and later 192.168.1.2"
+	assert.Equal(t, expected, lr.Body().Str())
+
+	// HMAC tokens differ from unkeyed tokens
+	unkeyed := &Masker{config: &Config{}}
+	assert.NotEqual(t, unkeyed.generateMaskedValue("testuser", "attribute_username"), m.generateMaskedValue("testuser", "attribute_username"))
+}
+
+func TestActiveActiveMode(t *testing.T) {
+	newRegion := func() (*Masker, *miniredis.Miniredis) {
+		cfg := NewDefaultConfig()
+		cfg.Mode = modeActiveActive
+		cfg.HMACKey = "secret"
+		return newTestMasker(t, &cfg)
+	}
+	east, eastServer := newRegion()
+	west, westServer := newRegion()
+
+	// A stale token cached in one region does not leak into masking
+	eastServer.Set(MaskKey("ipv4", "192.168.1.1"), "10.1.1.1")
+
+	eastToken, err := east.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	westToken, err := west.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, westToken, eastToken)
+	assert.Equal(t, east.generateMaskedValue("192.168.1.1", "ipv4"), eastToken)
+
+	// Both regions cache the derived mapping for reverse lookups
+	for _, server := range []*miniredis.Miniredis{eastServer, westServer} {
+		stored, err := server.Get(MaskKey("ipv4", "192.168.1.1"))
+		require.NoError(t, err)
+		assert.Equal(t, eastToken, stored)
+
+		original, err := server.Get(UnmaskKey("ipv4", eastToken))
+		require.NoError(t, err)
+		assert.Equal(t, "192.168.1.1", original)
+	}
+
+	// A derived token held by another value is not cached over its mapping
+	taken := east.generateMaskedValue("192.168.1.2", "ipv4")
+	eastServer.Set(UnmaskKey("ipv4", taken), "192.168.1.9")
+	token, err := east.MaskValue(context.Background(), "192.168.1.2", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, taken, token)
+	original, err := eastServer.Get(UnmaskKey("ipv4", taken))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.9", original)
+	assert.False(t, eastServer.Exists(MaskKey("ipv4", "192.168.1.2")))
+
+	// Masking keeps working while the cache is unavailable
+	eastServer.Close()
+	token, err = east.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, eastToken, token)
+}
+
+func TestReadOnlyMode(t *testing.T) {
+	for _, fallback := range []string{readOnlyFallbackHash, readOnlyFallbackDrop} {
+		t.Run(fallback, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.Mode = modeReadOnly
+			cfg.ReadOnly.Fallback = fallback
+			cfg.HMACKey = "secret"
+			cfg.WarmupTopN = 10
+			m, server := newTestMasker(t, &cfg)
+			server.Set(MaskKey("ipv4", "192.168.1.1"), "10.1.1.1")
+
+			// Curated mappings are used as they are
+			token, err := m.MaskValue(context.Background(), "192.168.1.1", "ipv4")
+			require.NoError(t, err)
+			assert.Equal(t, "10.1.1.1", token)
+
+			token, err = m.MaskValue(context.Background(), "192.168.1.2", "ipv4")
+			require.NoError(t, err)
+			if fallback == readOnlyFallbackHash {
+				assert.Equal(t, m.generateMaskedValue("192.168.1.2", "ipv4"), token)
+			} else {
+				assert.Empty(t, token)
+			}
+
+			// Unknown values are never stored, and neither are access counts
+			require.NoError(t, m.FlushAccess(context.Background()))
+			assert.Equal(t, []string{MaskKey("ipv4", "192.168.1.1")}, server.Keys())
+		})
+	}
+}
+
+func TestAccessTrackingAndWarmup(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	server := miniredis.RunT(t)
+	cfg.RedisAddr = server.Addr()
+
+	redisStore, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, redisStore.Close()) })
+	tracker := redisStore.(AccessTracker)
+
+	m, err := New(&cfg, redisStore, zap.NewNop(), WithAccessTracker(tracker))
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Body().SetStr("192.168.1.1 192.168.1.2")
+	m.MaskLogs(context.Background(), ld)
+	lr.Body().SetStr("192.168.1.2")
+	m.MaskLogs(context.Background(), ld)
+
+	// Counts are flushed once per batch, the busiest mapping first
+	top, err := tracker.TopAccessed(context.Background(), 1)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	require.Equal(t, UnmaskKey("ipv4", m.generateMaskedValue("192.168.1.2", "ipv4")), top[0].Key)
+	require.Equal(t, int64(2), top[0].Count)
+	require.WithinDuration(t, time.Now(), top[0].LastSeen, time.Minute)
+
+	// Reports list tokens, never original values
+	tokens, err := m.TopTokens(context.Background(), 10)
+	require.NoError(t, err)
+	require.Equal(t, []Prevalence{
+		{Category: "ipv4", Token: m.generateMaskedValue("192.168.1.2", "ipv4"), Count: 2, LastSeen: top[0].LastSeen},
+		{Category: "ipv4", Token: m.generateMaskedValue("192.168.1.1", "ipv4"), Count: 1, LastSeen: tokens[1].LastSeen},
+	}, tokens)
+
+	// Warm-up loads the busiest mappings through a local cache
+	cached := NewCachedStore(redisStore, 10, 0)
+	warm, err := New(&cfg, cached, zap.NewNop(), WithAccessTracker(tracker))
+	require.NoError(t, err)
+
+	loaded, err := warm.Warmup(context.Background(), 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, loaded)
+
+	server.Close()
+	token, err := warm.MaskValue(context.Background(), "192.168.1.2", "ipv4")
+	require.NoError(t, err)
+	require.Equal(t, m.generateMaskedValue("192.168.1.2", "ipv4"), token)
+}
+
+func TestProvenance(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.ScanAllAttributes = true
+	cfg.Provenance.Policy = "pci-v3"
+	cfg.Provenance.Profile = "strict"
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	masked := records.AppendEmpty()
+	masked.Body().SetStr("request from 192.168.1.1")
+	clean := records.AppendEmpty()
+	clean.Body().SetStr("nothing sensitive here")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// Every processed record is tagged, whether or not anything was masked
+	for _, lr := range []plog.LogRecord{masked, clean} {
+		policy, ok := lr.Attributes().Get("masking.policy")
+		require.True(t, ok)
+		assert.Equal(t, "pci-v3", policy.Str())
+
+		profile, ok := lr.Attributes().Get("masking.policy.profile")
+		require.True(t, ok)
+		assert.Equal(t, "strict", profile.Str())
+	}
+}
+
+func TestEnrichWithLookupURL(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"username"}
+	cfg.EnrichWithLookupURL = "https://unmask.example.com/"
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("username", "testuser")
+	lr.Body().SetStr("192.168.1.1 connected, 192.168.1.1 authenticated")
+
+	m.MaskLogs(context.Background(), ld)
+
+	username, _ := lr.Attributes().Get("username")
+	lookupURL, ok := lr.Attributes().Get("username.lookup_url")
+	require.True(t, ok)
+	assert.Equal(t, "https://unmask.example.com/v1/tokens/attribute_username/"+username.Str(), lookupURL.Str())
+
+	// Repeated matches are linked once
+	lookupURLs, ok := lr.Attributes().Get("masking.lookup_urls")
+	require.True(t, ok)
+	assert.Equal(t, []any{"https://unmask.example.com/v1/tokens/ipv4/" + m.generateMaskedValue("192.168.1.1", "ipv4")}, lookupURLs.Slice().AsRaw())
+}
+
+func TestRoutingKeyAttribute(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"username"}
+	cfg.RoutingKeyAttribute = "masking.routing_key"
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	first := records.AppendEmpty()
+	first.Body().SetStr("request from 192.168.1.1")
+	second := records.AppendEmpty()
+	second.Body().SetStr("192.168.1.1 disconnected")
+	third := records.AppendEmpty()
+	third.Body().SetStr("request from 192.168.1.1")
+	third.Attributes().PutStr("username", "testuser")
+	fourth := records.AppendEmpty()
+	fourth.Body().SetStr("nothing sensitive here")
+
+	m.MaskLogs(context.Background(), ld)
+
+	firstKey, ok := first.Attributes().Get("masking.routing_key")
+	require.True(t, ok)
+	require.Len(t, firstKey.Str(), 16)
+
+	secondKey, ok := second.Attributes().Get("masking.routing_key")
+	require.True(t, ok)
+	assert.Equal(t, firstKey.Str(), secondKey.Str())
+
+	// Configured fields take precedence over body matches
+	thirdKey, ok := third.Attributes().Get("masking.routing_key")
+	require.True(t, ok)
+	assert.NotEqual(t, firstKey.Str(), thirdKey.Str())
+
+	_, ok = fourth.Attributes().Get("masking.routing_key")
+	assert.False(t, ok)
+}
@@ -0,0 +1,55 @@
+package masker
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MatchFilterConfig is applied to every pattern match before it is resolved to
+// a token, so loose patterns such as hostname do not create useless tokens and
+// mappings for blank or trivially short matches. Empty matches are never masked.
+type MatchFilterConfig struct {
+	// Trim strips leading and trailing whitespace from matches. The stripped
+	// characters are kept unmasked in place.
+	Trim bool `mapstructure:"trim" json:"trim"`
+
+	// TrimChars are stripped from both ends of matches as well, e.g. quotes
+	TrimChars string `mapstructure:"trim_chars" json:"trim_chars"`
+
+	// MinLength is the number of characters a match must have after trimming.
+	// Shorter matches are left unmasked.
+	MinLength int `mapstructure:"min_length" json:"min_length"`
+}
+
+// Enabled reports whether matches are trimmed or need a minimum length
+func (cfg *MatchFilterConfig) Enabled() bool {
+	return cfg.Trim || cfg.TrimChars != "" || cfg.MinLength > 0
+}
+
+// Validate checks the minimum length
+func (cfg *MatchFilterConfig) Validate() error {
+	if cfg.MinLength < 0 {
+		return errors.New("match_filter min_length must be non-negative")
+	}
+	return nil
+}
+
+// trimmed reports whether r is stripped from the ends of matches
+func (cfg *MatchFilterConfig) trimmed(r rune) bool {
+	return (cfg.Trim && unicode.IsSpace(r)) || strings.ContainsRune(cfg.TrimChars, r)
+}
+
+// apply narrows the match at loc in text to its trimmed part, and reports
+// whether the match is kept
+func (cfg *MatchFilterConfig) apply(text string, loc []int) bool {
+	match := text[loc[0]:loc[1]]
+	if cfg.Trim || cfg.TrimChars != "" {
+		start := len(match) - len(strings.TrimLeftFunc(match, cfg.trimmed))
+		match = strings.TrimFunc(match, cfg.trimmed)
+		loc[0] += start
+		loc[1] = loc[0] + len(match)
+	}
+	return match != "" && utf8.RuneCountInString(match) >= cfg.MinLength
+}
@@ -0,0 +1,105 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchFilterApply(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      MatchFilterConfig
+		text     string
+		expected []int
+	}{
+		{
+			name:     "empty match",
+			text:     "",
+			expected: nil,
+		},
+		{
+			name:     "untrimmed",
+			text:     " abc ",
+			expected: []int{0, 5},
+		},
+		{
+			name:     "trimmed",
+			cfg:      MatchFilterConfig{Trim: true},
+			text:     "\t abc \n",
+			expected: []int{2, 5},
+		},
+		{
+			name:     "whitespace only",
+			cfg:      MatchFilterConfig{Trim: true},
+			text:     "  \t",
+			expected: nil,
+		},
+		{
+			name:     "trim chars",
+			cfg:      MatchFilterConfig{Trim: true, TrimChars: `"'`},
+			text:     ` "abc" `,
+			expected: []int{2, 5},
+		},
+		{
+			name:     "too short",
+			cfg:      MatchFilterConfig{MinLength: 4},
+			text:     "abc",
+			expected: nil,
+		},
+		{
+			name:     "length in characters",
+			cfg:      MatchFilterConfig{MinLength: 3},
+			text:     "äöü",
+			expected: []int{0, 6},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			loc := []int{0, len(tc.text)}
+			kept := tc.cfg.apply(tc.text, loc)
+			if tc.expected == nil {
+				assert.False(t, kept)
+				return
+			}
+			assert.True(t, kept)
+			assert.Equal(t, tc.expected, loc)
+		})
+	}
+}
+
+func TestMaskStringMatchFilter(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{
+		{
+			Name:  "word",
+			Regex: `\s*\w*\s*`,
+		},
+	}
+	cfg.MatchFilter = MatchFilterConfig{Trim: true, MinLength: 3}
+	m, server := newTestMasker(t, &cfg)
+
+	masked := m.MaskString(context.Background(), "id: ab  user42 ")
+
+	// Whitespace around matches and short matches are left in place, and only
+	// the trimmed match gets a mapping
+	token := m.generateMaskedValue("user42", "word")
+	assert.Equal(t, "id: ab  "+token+" ", masked)
+	keys := server.Keys()
+	require.Len(t, keys, 2)
+	assert.Contains(t, keys, MaskKey("word", "user42"))
+}
+
+func TestMatchFilterFingerprint(t *testing.T) {
+	cfg := NewDefaultConfig()
+	before, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+
+	cfg.MatchFilter.MinLength = 3
+	after, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.NotEqual(t, before.Fingerprint, after.Fingerprint)
+}
@@ -0,0 +1,252 @@
+package masker
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradfitz/gomemcache/memcache"
+)
+
+// MemcachedConfig lists the servers of the memcached store
+type MemcachedConfig struct {
+	// Servers are the host:port addresses, or socket paths, of the Memcached
+	// servers. Keys are spread across them by consistent hashing.
+	Servers []string `mapstructure:"servers"`
+
+	// Timeout bounds the dial, read, and write of every operation
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// MaxIdleConns is the number of idle connections kept per server
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+}
+
+// Validate checks that servers are listed and the bounds are positive
+func (cfg *MemcachedConfig) Validate() error {
+	if len(cfg.Servers) == 0 {
+		return errors.New("memcached servers are required")
+	}
+	if slices.Contains(cfg.Servers, "") {
+		return errors.New("memcached servers must not be empty")
+	}
+	if cfg.Timeout <= 0 {
+		return errors.New("memcached timeout must be positive")
+	}
+	if cfg.MaxIdleConns <= 0 {
+		return errors.New("memcached max_idle_conns must be positive")
+	}
+	return nil
+}
+
+// memcachedVirtualNodes is the number of points of every server on the hash
+// ring, so keys are spread evenly and adding or removing a server only moves
+// the keys of its own points
+const memcachedVirtualNodes = 160
+
+// memcachedMaxRelativeExpiry is the longest expiration Memcached reads as
+// relative. Longer ones are read as a Unix time.
+const memcachedMaxRelativeExpiry = 30 * 24 * time.Hour
+
+// memcachedRing selects the server of a key by consistent hashing
+type memcachedRing struct {
+	servers []net.Addr
+	points  []uint32
+	owners  []net.Addr
+}
+
+var _ memcache.ServerSelector = (*memcachedRing)(nil)
+
+// newMemcachedRing resolves servers and places them on the ring
+func newMemcachedRing(servers []string) (*memcachedRing, error) {
+	type point struct {
+		hash  uint32
+		owner net.Addr
+	}
+
+	ring := &memcachedRing{}
+	points := make([]point, 0, len(servers)*memcachedVirtualNodes)
+	for _, server := range servers {
+		var addr net.Addr
+		var err error
+		if strings.Contains(server, "/") {
+			addr, err = net.ResolveUnixAddr("unix", server)
+		} else {
+			addr, err = net.ResolveTCPAddr("tcp", server)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve memcached server '%s': %w", server, err)
+		}
+		ring.servers = append(ring.servers, addr)
+
+		// Points are placed by the configured address, so every collector builds
+		// the same ring regardless of how the address resolves
+		for i := 0; i < memcachedVirtualNodes; i++ {
+			points = append(points, point{hash: crc32.ChecksumIEEE([]byte(server + "-" + strconv.Itoa(i))), owner: addr})
+		}
+	}
+
+	slices.SortFunc(points, func(a, b point) int {
+		return cmp.Compare(a.hash, b.hash)
+	})
+	for _, p := range points {
+		ring.points = append(ring.points, p.hash)
+		ring.owners = append(ring.owners, p.owner)
+	}
+	return ring, nil
+}
+
+// PickServer returns the server owning the first point at or after the hash of key
+func (r *memcachedRing) PickServer(key string) (net.Addr, error) {
+	if len(r.points) == 0 {
+		return nil, memcache.ErrNoServers
+	}
+	i, _ := slices.BinarySearch(r.points, crc32.ChecksumIEEE([]byte(key)))
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[i], nil
+}
+
+// Each calls f for every server
+func (r *memcachedRing) Each(f func(net.Addr) error) error {
+	for _, addr := range r.servers {
+		if err := f(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memcachedStore is a Store that keeps mappings on Memcached servers. Memcached
+// evicts entries under memory pressure, so mappings may be lost before their TTL.
+type memcachedStore struct {
+	client *memcache.Client
+	now    func() time.Time
+}
+
+var (
+	_ Store          = (*memcachedStore)(nil)
+	_ MappingCreator = (*memcachedStore)(nil)
+	_ Pinger         = (*memcachedStore)(nil)
+)
+
+// NewMemcachedStore creates a store on the servers listed in cfg. Connections
+// are opened on first use.
+func NewMemcachedStore(cfg *MemcachedConfig) (Store, error) {
+	ring, err := newMemcachedRing(cfg.Servers)
+	if err != nil {
+		return nil, err
+	}
+	client := memcache.NewFromSelector(ring)
+	client.Timeout = cfg.Timeout
+	client.MaxIdleConns = cfg.MaxIdleConns
+	return &memcachedStore{client: client, now: time.Now}, nil
+}
+
+// Ping checks that every server is reachable
+func (s *memcachedStore) Ping(context.Context) error {
+	if err := s.client.Ping(); err != nil {
+		return fmt.Errorf("failed to connect to Memcached: %w", err)
+	}
+	return nil
+}
+
+// Get returns the value stored under key
+func (s *memcachedStore) Get(_ context.Context, key string) (string, bool, error) {
+	item, err := s.client.Get(memcachedKey(key))
+	switch {
+	case err == nil:
+		return string(item.Value), true, nil
+	case errors.Is(err, memcache.ErrCacheMiss):
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("memcached get error: %w", err)
+	}
+}
+
+// Set stores value under key
+func (s *memcachedStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	if err := s.client.Set(s.item(key, value, ttl)); err != nil {
+		return fmt.Errorf("memcached set error: %w", err)
+	}
+	return nil
+}
+
+// CreateMapping adds the token under maskKey unless one exists, and then adds
+// the original value under unmaskKey. The adds are atomic, so the first
+// collector to add a token wins, and the token is removed again when unmaskKey
+// holds another value.
+func (s *memcachedStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	err := s.client.Add(s.item(maskKey, token, ttl))
+	if errors.Is(err, memcache.ErrNotStored) {
+		winner, found, getErr := s.Get(ctx, maskKey)
+		if getErr != nil {
+			return "", false, getErr
+		}
+		if found {
+			return winner, false, nil
+		}
+		// The winner expired or was evicted in between, so the token replaces it
+		err = s.client.Set(s.item(maskKey, token, ttl))
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("memcached create mapping error: %w", err)
+	}
+
+	err = s.client.Add(s.item(unmaskKey, original, ttl))
+	if errors.Is(err, memcache.ErrNotStored) {
+		holder, found, getErr := s.Get(ctx, unmaskKey)
+		if getErr != nil {
+			return "", false, getErr
+		}
+		if found && holder != original {
+			if err := s.client.Delete(maskKey); err != nil && !errors.Is(err, memcache.ErrCacheMiss) {
+				return "", false, fmt.Errorf("memcached delete error: %w", err)
+			}
+			return "", false, errTokenTaken
+		}
+		// A leftover reverse mapping of the same value gets the new TTL
+		err = s.client.Set(s.item(unmaskKey, original, ttl))
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("memcached create mapping error: %w", err)
+	}
+	return token, true, nil
+}
+
+// Close closes the idle connections
+func (s *memcachedStore) Close() error {
+	return s.client.Close()
+}
+
+// item returns the Memcached item storing value under key until ttl has passed
+func (s *memcachedStore) item(key, value string, ttl time.Duration) *memcache.Item {
+	var expiration int32
+	if ttl > 0 {
+		seconds := int64((ttl + time.Second - 1) / time.Second)
+		if ttl > memcachedMaxRelativeExpiry {
+			seconds += s.now().Unix()
+		}
+		expiration = int32(seconds)
+	}
+	return &memcache.Item{Key: memcachedKey(key), Value: []byte(value), Expiration: expiration}
+}
+
+// memcachedKey returns key, or its hash when Memcached does not accept it,
+// e.g. because the original value it holds is long or contains spaces
+func memcachedKey(key string) string {
+	if len(key) <= 250 && !strings.ContainsFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return key
+	}
+	hash := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,257 @@
+package masker
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMemcached serves the part of the Memcached text protocol the store uses
+type fakeMemcached struct {
+	listener net.Listener
+
+	mu    sync.Mutex
+	items map[string]string
+}
+
+// newFakeMemcached starts a fake server that is stopped with the test
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &fakeMemcached{listener: listener, items: make(map[string]string)}
+	go server.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+func (f *fakeMemcached) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeMemcached) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.items)
+}
+
+func (f *fakeMemcached) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeMemcached) handle(conn net.Conn) {
+	defer conn.Close()
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		switch fields[0] {
+		case "version":
+			fmt.Fprint(rw, "VERSION fake\r\n")
+		case "get", "gets":
+			f.mu.Lock()
+			for _, key := range fields[1:] {
+				if value, ok := f.items[key]; ok {
+					fmt.Fprintf(rw, "VALUE %s 0 %d 1\r\n%s\r\n", key, len(value), value)
+				}
+			}
+			f.mu.Unlock()
+			fmt.Fprint(rw, "END\r\n")
+		case "set", "add":
+			size, err := strconv.Atoi(fields[4])
+			if err != nil {
+				return
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, data); err != nil {
+				return
+			}
+			f.mu.Lock()
+			if _, exists := f.items[fields[1]]; exists && fields[0] == "add" {
+				fmt.Fprint(rw, "NOT_STORED\r\n")
+			} else {
+				f.items[fields[1]] = string(data[:size])
+				fmt.Fprint(rw, "STORED\r\n")
+			}
+			f.mu.Unlock()
+		case "delete":
+			f.mu.Lock()
+			if _, exists := f.items[fields[1]]; exists {
+				delete(f.items, fields[1])
+				fmt.Fprint(rw, "DELETED\r\n")
+			} else {
+				fmt.Fprint(rw, "NOT_FOUND\r\n")
+			}
+			f.mu.Unlock()
+		default:
+			fmt.Fprint(rw, "ERROR\r\n")
+		}
+		if err := rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func TestMemcachedStore(t *testing.T) {
+	servers := []*fakeMemcached{newFakeMemcached(t), newFakeMemcached(t)}
+	store, err := NewMemcachedStore(&MemcachedConfig{
+		Servers:      []string{servers[0].addr(), servers[1].addr()},
+		Timeout:      time.Second,
+		MaxIdleConns: 2,
+	})
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+	require.NoError(t, store.(Pinger).Ping(ctx))
+
+	for i := 0; i < 50; i++ {
+		require.NoError(t, store.Set(ctx, fmt.Sprintf("key-%d", i), strconv.Itoa(i), time.Hour))
+	}
+	for i := 0; i < 50; i++ {
+		value, found, err := store.Get(ctx, fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, strconv.Itoa(i), value)
+	}
+	// Keys are spread across the servers
+	require.Positive(t, servers[0].len())
+	require.Positive(t, servers[1].len())
+
+	_, found, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Keys Memcached does not accept are stored under their hash
+	key := UnmaskKey("email", "john doe "+strings.Repeat("x", 300))
+	require.NoError(t, store.Set(ctx, key, "v", 0))
+	value, found, err := store.Get(ctx, key)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v", value)
+}
+
+func TestMemcachedStoreCreateMapping(t *testing.T) {
+	server := newFakeMemcached(t)
+	store, err := NewMemcachedStore(&MemcachedConfig{Servers: []string{server.addr()}, Timeout: time.Second, MaxIdleConns: 2})
+	require.NoError(t, err)
+	defer store.Close()
+	creator := store.(MappingCreator)
+	ctx := context.Background()
+
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+	winner, created, err := creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", time.Hour)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	// The first token wins
+	winner, created, err = creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", time.Hour)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+	_, found, err = store.Get(ctx, UnmaskKey("ipv4", "10.4.5.6"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// A token held by another value is refused
+	_, _, err = creator.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", time.Hour)
+	require.ErrorIs(t, err, errTokenTaken)
+	_, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.9"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMemcachedStoreUnreachable(t *testing.T) {
+	server := newFakeMemcached(t)
+	addr := server.addr()
+	require.NoError(t, server.listener.Close())
+
+	store, err := NewMemcachedStore(&MemcachedConfig{Servers: []string{addr}, Timeout: time.Second, MaxIdleConns: 2})
+	require.NoError(t, err)
+	defer store.Close()
+	require.ErrorContains(t, store.(Pinger).Ping(context.Background()), "failed to connect to Memcached")
+}
+
+func TestMemcachedRing(t *testing.T) {
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	ring, err := newMemcachedRing(servers)
+	require.NoError(t, err)
+	smaller, err := newMemcachedRing(servers[:2])
+	require.NoError(t, err)
+
+	const keys = 3000
+	counts := make(map[string]int)
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner, err := ring.PickServer(key)
+		require.NoError(t, err)
+		counts[owner.String()]++
+
+		// Removing a server only moves its own keys
+		if owner.String() != servers[2] {
+			remaining, err := smaller.PickServer(key)
+			require.NoError(t, err)
+			require.Equal(t, owner.String(), remaining.String(), key)
+		}
+	}
+
+	require.Len(t, counts, len(servers))
+	for server, count := range counts {
+		require.Greater(t, count, keys/5, server)
+	}
+
+	_, err = newMemcachedRing([]string{"not an address"})
+	require.ErrorContains(t, err, "failed to resolve memcached server")
+}
+
+func TestMemcachedItemExpiration(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	store := &memcachedStore{now: func() time.Time { return now }}
+
+	require.Zero(t, store.item("k", "v", 0).Expiration)
+	require.Equal(t, int32(1), store.item("k", "v", time.Millisecond).Expiration)
+	require.Equal(t, int32(3600), store.item("k", "v", time.Hour).Expiration)
+
+	// Expirations longer than 30 days are sent as a Unix time
+	ttl := 60 * 24 * time.Hour
+	require.Equal(t, int32(now.Add(ttl).Unix()), store.item("k", "v", ttl).Expiration)
+}
+
+func TestMemcachedKey(t *testing.T) {
+	require.Equal(t, "mask:ipv4:abc", memcachedKey("mask:ipv4:abc"))
+
+	for _, key := range []string{"has space", "has\nnewline", strings.Repeat("x", 251)} {
+		hashed := memcachedKey(key)
+		require.True(t, strings.HasPrefix(hashed, "sha256:"), key)
+		require.Len(t, hashed, len("sha256:")+64)
+		require.Equal(t, hashed, memcachedKey(key))
+	}
+}
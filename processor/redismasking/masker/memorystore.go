@@ -0,0 +1,137 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// MemoryStoreConfig bounds the in-memory token store
+type MemoryStoreConfig struct {
+	// MaxEntries is the number of store entries kept, evicting the least
+	// recently used ones. Each mapping takes two entries. 0 is unlimited.
+	MaxEntries int `mapstructure:"max_entries"`
+
+	// TTL bounds how long an entry is kept, in addition to token_ttl (0 = no bound)
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// Validate checks that the bounds are non-negative
+func (cfg *MemoryStoreConfig) Validate() error {
+	if cfg.MaxEntries < 0 {
+		return errors.New("memory_store max_entries must be non-negative")
+	}
+	if cfg.TTL < 0 {
+		return errors.New("memory_store ttl must be non-negative")
+	}
+	return nil
+}
+
+// memoryEntry is a value of the memory store and when it expires
+type memoryEntry struct {
+	value  string
+	expiry time.Time
+}
+
+// memoryStore is a Store that keeps mappings in the memory of the collector,
+// for single collector deployments and tests. Mappings are lost on restart.
+type memoryStore struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries *simplelru.LRU[string, memoryEntry]
+}
+
+var (
+	_ Store          = (*memoryStore)(nil)
+	_ MappingCreator = (*memoryStore)(nil)
+)
+
+// NewMemoryStore creates an in-memory store bounded by cfg
+func NewMemoryStore(cfg *MemoryStoreConfig) (Store, error) {
+	size := cfg.MaxEntries
+	if size == 0 {
+		size = math.MaxInt
+	}
+	entries, err := simplelru.NewLRU[string, memoryEntry](size, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create memory store: %w", err)
+	}
+	return &memoryStore{
+		ttl:     cfg.TTL,
+		now:     time.Now,
+		entries: entries,
+	}, nil
+}
+
+// Get returns the value stored under key, unless it expired
+func (s *memoryStore) Get(_ context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.getLocked(key)
+	return value, ok, nil
+}
+
+// Set stores value under key
+func (s *memoryStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setLocked(key, value, ttl)
+	return nil
+}
+
+// CreateMapping stores both directions of a mapping unless maskKey holds a token
+func (s *memoryStore) CreateMapping(_ context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if winner, ok := s.getLocked(maskKey); ok {
+		return winner, false, nil
+	}
+	if holder, ok := s.getLocked(unmaskKey); ok && holder != original {
+		return "", false, errTokenTaken
+	}
+	s.setLocked(maskKey, token, ttl)
+	s.setLocked(unmaskKey, original, ttl)
+	return token, true, nil
+}
+
+// Close drops every entry
+func (s *memoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries.Purge()
+	return nil
+}
+
+// getLocked returns the value of key, removing it once expired. The caller holds s.mu.
+func (s *memoryStore) getLocked(key string) (string, bool) {
+	entry, ok := s.entries.Get(key)
+	if !ok {
+		return "", false
+	}
+	if !entry.expiry.IsZero() && !s.now().Before(entry.expiry) {
+		s.entries.Remove(key)
+		return "", false
+	}
+	return entry.value, true
+}
+
+// setLocked stores value under key until the shorter of ttl and the store TTL
+// has passed. The caller holds s.mu.
+func (s *memoryStore) setLocked(key, value string, ttl time.Duration) {
+	if s.ttl > 0 && (ttl == 0 || s.ttl < ttl) {
+		ttl = s.ttl
+	}
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiry = s.now().Add(ttl)
+	}
+	s.entries.Add(key, entry)
+}
@@ -0,0 +1,120 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store, err := NewMemoryStore(&MemoryStoreConfig{MaxEntries: 2})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "a", "1", 0))
+	require.NoError(t, store.Set(ctx, "b", "2", 0))
+	value, found, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "1", value)
+
+	// The least recently used entry is evicted
+	require.NoError(t, store.Set(ctx, "c", "3", 0))
+	_, found, err = store.Get(ctx, "b")
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+
+	require.NoError(t, store.Close())
+	_, found, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	store, err := NewMemoryStore(&MemoryStoreConfig{TTL: time.Minute})
+	require.NoError(t, err)
+	now := time.Now()
+	store.(*memoryStore).now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, store.Set(ctx, "unbounded", "1", 0))
+	require.NoError(t, store.Set(ctx, "short", "2", time.Second))
+	require.NoError(t, store.Set(ctx, "long", "3", time.Hour))
+
+	now = now.Add(2 * time.Second)
+	_, found, err := store.Get(ctx, "short")
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, err = store.Get(ctx, "long")
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// The store TTL bounds entries without a TTL and with a longer one
+	now = now.Add(time.Minute)
+	for _, key := range []string{"unbounded", "long"} {
+		_, found, err = store.Get(ctx, key)
+		require.NoError(t, err)
+		require.False(t, found, key)
+	}
+}
+
+func TestMemoryStoreCreateMapping(t *testing.T) {
+	store, err := NewMemoryStore(&MemoryStoreConfig{})
+	require.NoError(t, err)
+	creator := store.(MappingCreator)
+	ctx := context.Background()
+
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+	winner, created, err := creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", 0)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	// The first token wins
+	winner, created, err = creator.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", 0)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+	_, found, err = store.Get(ctx, UnmaskKey("ipv4", "10.4.5.6"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// A token held by another value is refused
+	_, _, err = creator.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", 0)
+	require.ErrorIs(t, err, errTokenTaken)
+	_, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.9"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMemoryStoreTokens(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	redisMasker, _ := newTestMasker(t, &cfg)
+
+	memoryCfg := NewDefaultConfig()
+	memoryCfg.Patterns = ipv4Patterns()
+	memoryCfg.Store = storeMemory
+	require.NoError(t, memoryCfg.Validate())
+	store, err := NewMemoryStore(&memoryCfg.MemoryStore)
+	require.NoError(t, err)
+	memoryMasker, err := New(&memoryCfg, store, zap.NewNop())
+	require.NoError(t, err)
+
+	// Tokens do not depend on the store
+	ctx := context.Background()
+	text := "connection from 192.168.1.1"
+	require.Equal(t, redisMasker.MaskString(ctx, text), memoryMasker.MaskString(ctx, text))
+	require.NotEqual(t, text, memoryMasker.MaskString(ctx, text))
+}
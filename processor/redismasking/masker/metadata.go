@@ -0,0 +1,165 @@
+package masker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// jsonFrame tracks the object or array being walked by maskJSONSegment
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	key       string
+}
+
+// jsonReplacement replaces the bytes [start, end) of a JSON segment
+type jsonReplacement struct {
+	start, end int
+	value      string
+}
+
+// maskMetadataSegment masks a body ending in a metadata segment, e.g.
+// "login failed |meta={...}". The free-text prefix is pattern-scanned while the
+// JSON segment after the delimiter is masked member by member like attributes of
+// the same keys. It reports false when no delimiter is found or the segment is
+// not a JSON object.
+func (m *Masker) maskMetadataSegment(ctx context.Context, body string, onMask func(category, token string)) (string, bool) {
+	for _, delimiter := range m.config.BodyMetadataDelimiters {
+		index := strings.LastIndex(body, delimiter)
+		if index < 0 {
+			continue
+		}
+
+		segment, ok := m.maskJSONSegment(ctx, body[index+len(delimiter):], onMask)
+		if !ok {
+			continue
+		}
+		return m.maskString(ctx, body[:index], onMask) + delimiter + segment, true
+	}
+	return "", false
+}
+
+// maskJSONSegment masks the values of the JSON object at the start of segment.
+// Values are replaced in place rather than re-encoded, so member order and
+// formatting are kept. Text after the object is pattern-scanned.
+func (m *Masker) maskJSONSegment(ctx context.Context, segment string, onMask func(category, token string)) (string, bool) {
+	if !strings.HasPrefix(strings.TrimLeft(segment, " \t"), "{") {
+		return "", false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(segment))
+	decoder.UseNumber()
+
+	var stack []*jsonFrame
+	var replacements []jsonReplacement
+	end := 0
+
+	// valueDone marks the value of the current object member as consumed
+	valueDone := func() {
+		if len(stack) > 0 && stack[len(stack)-1].object {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+
+	for {
+		previous := int(decoder.InputOffset())
+		// A truncated or invalid object is not a metadata segment
+		token, err := decoder.Token()
+		if err != nil {
+			return "", false
+		}
+		offset := int(decoder.InputOffset())
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		switch t := token.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				frame := &jsonFrame{object: t == '{', expectKey: t == '{'}
+				// Array elements are masked like the member holding the array
+				if top != nil && !frame.object {
+					frame.key = top.key
+				}
+				stack = append(stack, frame)
+			default:
+				stack = stack[:len(stack)-1]
+				valueDone()
+			}
+		case string:
+			if top.object && top.expectKey {
+				top.key = t
+				top.expectKey = false
+				continue
+			}
+			if masked := m.maskSegmentValue(ctx, top.key, t, onMask); masked != t {
+				replacements = append(replacements, jsonReplacement{start: valueStart(segment, previous), end: offset, value: quoteJSON(masked)})
+			}
+			valueDone()
+		case json.Number:
+			if masked := m.maskSegmentValue(ctx, top.key, t.String(), onMask); masked != t.String() {
+				replacements = append(replacements, jsonReplacement{start: valueStart(segment, previous), end: offset, value: quoteJSON(masked)})
+			}
+			valueDone()
+		default:
+			valueDone()
+		}
+
+		if len(stack) == 0 {
+			end = offset
+			break
+		}
+	}
+
+	var result strings.Builder
+	last := 0
+	for _, replacement := range replacements {
+		result.WriteString(segment[last:replacement.start])
+		result.WriteString(replacement.value)
+		last = replacement.end
+	}
+	result.WriteString(segment[last:end])
+	result.WriteString(m.maskString(ctx, segment[end:], onMask))
+	return result.String(), true
+}
+
+// maskSegmentValue masks a string or number member of a metadata segment with the
+// handling of an attribute of the same key. Values of secret keys are always masked,
+// and other values are pattern-scanned.
+func (m *Masker) maskSegmentValue(ctx context.Context, key, value string, onMask func(category, token string)) string {
+	if m.secretKeyRegex != nil && m.secretKeyRegex.MatchString(key) {
+		if token, ok := m.secretToken(ctx, value, onMask); ok {
+			return token
+		}
+		return value
+	}
+
+	v := pcommon.NewValueStr(value)
+	m.maskAttribute(ctx, key, v, true, onMask)
+	return v.Str()
+}
+
+// valueStart returns the offset of the value following the previous token at
+// offset, skipping the whitespace and separators in between
+func valueStart(segment string, offset int) int {
+	for offset < len(segment) && strings.ContainsRune(" \t\r\n:,", rune(segment[offset])) {
+		offset++
+	}
+	return offset
+}
+
+// quoteJSON encodes value as a JSON string without escaping HTML characters
+func quoteJSON(value string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,67 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskMetadataSegment(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"user", "account"}
+	cfg.SecretKeys.Enabled = true
+	cfg.BodyMetadataDelimiters = []string{"|meta="}
+	m, _ := newTestMasker(t, &cfg)
+	ctx := context.Background()
+
+	ipToken := m.generateMaskedValue("192.168.1.1", "ipv4")
+	userToken := m.generateMaskedValue("alice", attributeCategory("user"))
+	accountToken := m.generateMaskedValue("1234", attributeCategory("account"))
+	secretToken := m.generateMaskedValue("hunter2", secretCategory)
+
+	testCases := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "fields and order are kept",
+			body:     `login from 192.168.1.1 |meta={"user": "alice", "src": "192.168.1.1", "account": 1234, "ok": true}`,
+			expected: `login from ` + ipToken + ` |meta={"user": "` + userToken + `", "src": "` + ipToken + `", "account": "` + accountToken + `", "ok": true}`,
+		},
+		{
+			name:     "nested objects and arrays",
+			body:     `request |meta={"ctx":{"user":["alice"],"password":"hunter2"},"shard":3}`,
+			expected: `request |meta={"ctx":{"user":["` + userToken + `"],"password":"` + secretToken + `"},"shard":3}`,
+		},
+		{
+			name:     "text after the segment is scanned",
+			body:     `done |meta={"user":"alice"} peer=192.168.1.1`,
+			expected: `done |meta={"user":"` + userToken + `"} peer=` + ipToken,
+		},
+		{
+			name:     "last delimiter is used",
+			body:     `quoted |meta= text |meta={"user":"alice"}`,
+			expected: `quoted |meta= text |meta={"user":"` + userToken + `"}`,
+		},
+		{
+			// A field name in free text is not masked without a segment
+			name:     "invalid segment is scanned as text",
+			body:     `user alice from 192.168.1.1 |meta={"user":`,
+			expected: `user alice from ` + ipToken + ` |meta={"user":`,
+		},
+		{
+			name:     "no segment",
+			body:     `user alice from 192.168.1.1`,
+			expected: `user alice from ` + ipToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, m.maskBody(ctx, tc.body, nil))
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package masker
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// MaskMetrics masks the resource and datapoint attributes of every metric in md
+// in place, using the same fields, patterns, and tokens as log records. Resources
+// are tagged with the configuration fingerprint when fingerprint_attribute is set.
+func (m *Masker) MaskMetrics(ctx context.Context, md pmetric.Metrics) {
+	defer m.observeSince(time.Now())
+
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		rm := md.ResourceMetrics().At(i)
+		for j := 0; j < rm.ScopeMetrics().Len(); j++ {
+			sm := rm.ScopeMetrics().At(j)
+			for k := 0; k < sm.Metrics().Len(); k++ {
+				m.maskMetric(ctx, sm.Metrics().At(k))
+			}
+		}
+		m.maskResource(ctx, rm.Resource())
+
+		// Tagged after masking so the fingerprint is never scanned itself
+		if m.config.FingerprintAttribute != "" {
+			rm.Resource().Attributes().PutStr(m.config.FingerprintAttribute, m.fingerprint)
+		}
+	}
+
+	if err := m.FlushAccess(ctx); err != nil {
+		m.logWarn("Failed to record mapping access counts", err)
+	}
+}
+
+// maskMetric masks the attributes of every datapoint of metric and of their exemplars
+func (m *Masker) maskMetric(ctx context.Context, metric pmetric.Metric) {
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < metric.Gauge().DataPoints().Len(); i++ {
+			dp := metric.Gauge().DataPoints().At(i)
+			m.maskDataPoint(ctx, dp.Attributes())
+			m.maskExemplars(ctx, dp.Exemplars())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < metric.Sum().DataPoints().Len(); i++ {
+			dp := metric.Sum().DataPoints().At(i)
+			m.maskDataPoint(ctx, dp.Attributes())
+			m.maskExemplars(ctx, dp.Exemplars())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < metric.Histogram().DataPoints().Len(); i++ {
+			dp := metric.Histogram().DataPoints().At(i)
+			m.maskDataPoint(ctx, dp.Attributes())
+			m.maskExemplars(ctx, dp.Exemplars())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for i := 0; i < metric.ExponentialHistogram().DataPoints().Len(); i++ {
+			dp := metric.ExponentialHistogram().DataPoints().At(i)
+			m.maskDataPoint(ctx, dp.Attributes())
+			m.maskExemplars(ctx, dp.Exemplars())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < metric.Summary().DataPoints().Len(); i++ {
+			m.maskDataPoint(ctx, metric.Summary().DataPoints().At(i).Attributes())
+		}
+	}
+}
+
+// maskDataPoint masks datapoint attributes. Unlike log records, datapoints get no
+// companion or provenance attributes, since every new attribute adds series.
+func (m *Masker) maskDataPoint(ctx context.Context, attrs pcommon.Map) {
+	if m.detectOnly() {
+		return
+	}
+	m.maskAttributes(ctx, attrs, nil)
+}
+
+// maskExemplars masks the filtered attributes of exemplars, which often hold the
+// request-level identifiers removed from the datapoint itself. Their trace
+// context is removed when configured.
+func (m *Masker) maskExemplars(ctx context.Context, exemplars pmetric.ExemplarSlice) {
+	if m.detectOnly() {
+		return
+	}
+
+	for i := 0; i < exemplars.Len(); i++ {
+		exemplar := exemplars.At(i)
+		if m.config.Metrics.Exemplars {
+			m.maskAttributes(ctx, exemplar.FilteredAttributes(), nil)
+		}
+		if m.config.Metrics.RemoveExemplarTraceContext {
+			exemplar.SetTraceID(pcommon.NewTraceIDEmpty())
+			exemplar.SetSpanID(pcommon.NewSpanIDEmpty())
+		}
+	}
+}
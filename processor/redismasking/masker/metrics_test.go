@@ -0,0 +1,85 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func TestMaskMetrics(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"user.id"}
+	cfg.ScanAllAttributes = true
+	cfg.Provenance.Policy = "pci-v3"
+	m, _ := newTestMasker(t, &cfg)
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	var attrs []pcommon.Map
+	attrs = append(attrs, metrics.AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().Attributes())
+	attrs = append(attrs, metrics.AppendEmpty().SetEmptySum().DataPoints().AppendEmpty().Attributes())
+	attrs = append(attrs, metrics.AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty().Attributes())
+	attrs = append(attrs, metrics.AppendEmpty().SetEmptyExponentialHistogram().DataPoints().AppendEmpty().Attributes())
+	attrs = append(attrs, metrics.AppendEmpty().SetEmptySummary().DataPoints().AppendEmpty().Attributes())
+	for _, a := range attrs {
+		a.PutStr("user.id", "alice")
+		a.PutStr("server", "upstream 192.168.1.1")
+	}
+
+	m.MaskMetrics(context.Background(), md)
+
+	userToken := m.generateMaskedValue("alice", attributeCategory("user.id"))
+	for _, a := range attrs {
+		userID, _ := a.Get("user.id")
+		assert.Equal(t, userToken, userID.Str())
+		server, _ := a.Get("server")
+		assert.Equal(t, "upstream "+m.generateMaskedValue("192.168.1.1", "ipv4"), server.Str())
+
+		// Datapoints are not tagged, since tags would add series
+		assert.Equal(t, 2, a.Len())
+	}
+}
+
+func TestMaskMetricsExemplars(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"user.id"}
+	m, _ := newTestMasker(t, &cfg)
+
+	md := pmetric.NewMetrics()
+	metrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics()
+	var exemplars []pmetric.Exemplar
+	exemplars = append(exemplars, metrics.AppendEmpty().SetEmptyGauge().DataPoints().AppendEmpty().Exemplars().AppendEmpty())
+	exemplars = append(exemplars, metrics.AppendEmpty().SetEmptySum().DataPoints().AppendEmpty().Exemplars().AppendEmpty())
+	exemplars = append(exemplars, metrics.AppendEmpty().SetEmptyHistogram().DataPoints().AppendEmpty().Exemplars().AppendEmpty())
+	exemplars = append(exemplars, metrics.AppendEmpty().SetEmptyExponentialHistogram().DataPoints().AppendEmpty().Exemplars().AppendEmpty())
+	traceID := pcommon.TraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	spanID := pcommon.SpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	for _, exemplar := range exemplars {
+		exemplar.FilteredAttributes().PutStr("user.id", "alice")
+		exemplar.SetTraceID(traceID)
+		exemplar.SetSpanID(spanID)
+	}
+
+	m.MaskMetrics(context.Background(), md)
+
+	userToken := m.generateMaskedValue("alice", attributeCategory("user.id"))
+	for _, exemplar := range exemplars {
+		userID, _ := exemplar.FilteredAttributes().Get("user.id")
+		assert.Equal(t, userToken, userID.Str())
+		assert.Equal(t, traceID, exemplar.TraceID())
+		assert.Equal(t, spanID, exemplar.SpanID())
+	}
+
+	// The trace context is removed when configured
+	cfg.Metrics.RemoveExemplarTraceContext = true
+	m.MaskMetrics(context.Background(), md)
+	for _, exemplar := range exemplars {
+		assert.True(t, exemplar.TraceID().IsEmpty())
+		assert.True(t, exemplar.SpanID().IsEmpty())
+	}
+}
@@ -0,0 +1,239 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// MigrationRule moves the mappings of one category to another, e.g. after a
+// category was renamed or moved into a token namespace
+type MigrationRule struct {
+	// From is the category the mappings are stored under, e.g. "ip"
+	From string
+
+	// To is the category the mappings are moved to, e.g. "ipv4" or "vendor_x/ipv4"
+	To string
+}
+
+// MigrationOptions tune a migration
+type MigrationOptions struct {
+	// Move deletes the migrated keys. By default they are copied, so agents
+	// that still use the old categories keep their mappings during a rollout.
+	Move bool
+
+	// Rate is the maximum number of keys migrated per second (0 = unlimited)
+	Rate int
+
+	// DryRun only counts the keys that would be migrated
+	DryRun bool
+}
+
+// MigrationStats summarizes a migration
+type MigrationStats struct {
+	// Migrated is the number of keys copied or moved
+	Migrated int64
+
+	// Conflicts is the number of keys whose new key already existed. The new key
+	// is kept, since the current agents already hand out its token. Copied keys
+	// that expired during the migration are counted as well.
+	Conflicts int64
+}
+
+// ValidateMigrationRules checks that every rule moves one category to another
+// and that no category is migrated twice
+func ValidateMigrationRules(rules []MigrationRule) error {
+	if len(rules) == 0 {
+		return errors.New("at least one migration rule is required")
+	}
+	from := map[string]struct{}{}
+	for _, rule := range rules {
+		if rule.From == "" || rule.To == "" {
+			return errors.New("migration rules require both categories")
+		}
+		if strings.Contains(rule.From, ":") || strings.Contains(rule.To, ":") {
+			return errors.New("migration categories must not contain ':'")
+		}
+		if rule.From == rule.To {
+			return fmt.Errorf("migration rule moves '%s' to itself", rule.From)
+		}
+		if _, ok := from[rule.From]; ok {
+			return fmt.Errorf("category '%s' is migrated twice", rule.From)
+		}
+		from[rule.From] = struct{}{}
+	}
+	for _, rule := range rules {
+		if _, ok := from[rule.To]; ok {
+			return fmt.Errorf("category '%s' is both migrated and a migration target", rule.To)
+		}
+	}
+	return nil
+}
+
+// Migrator is implemented by stores that can move mappings between categories
+type Migrator interface {
+	// Migrate copies or moves both directions of every mapping of the rules'
+	// categories, together with their access counts. progress, when set, is
+	// called with the running totals after each batch.
+	Migrate(ctx context.Context, rules []MigrationRule, opts MigrationOptions, progress func(MigrationStats)) (MigrationStats, error)
+}
+
+var _ Migrator = (*redisStore)(nil)
+
+// migrateBatchSize is the number of keys requested per SCAN call
+const migrateBatchSize = 100
+
+// Migrate migrates the mappings online, batch by batch, with COPY or RENAMENX
+// so existing keys are never overwritten and TTLs are kept. Running it again
+// migrates the mappings created by agents of the old release in the meantime.
+func (s *redisStore) Migrate(ctx context.Context, rules []MigrationRule, opts MigrationOptions, progress func(MigrationStats)) (MigrationStats, error) {
+	var stats MigrationStats
+	if err := ValidateMigrationRules(rules); err != nil {
+		return stats, err
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), max(opts.Rate, migrateBatchSize))
+	}
+
+	for _, rule := range rules {
+		for _, prefix := range []string{"mask:", "unmask:"} {
+			from, to := prefix+rule.From+":", prefix+rule.To+":"
+			iter := s.client.Scan(ctx, 0, escapeGlob(from)+"*", migrateBatchSize).Iterator()
+			var batch []string
+			flush := func() error {
+				if len(batch) == 0 {
+					return nil
+				}
+				if err := limiter.WaitN(ctx, len(batch)); err != nil {
+					return err
+				}
+				migrated, conflicts, err := s.migrateKeys(ctx, batch, from, to, opts)
+				if err != nil {
+					return err
+				}
+				stats.Migrated += migrated
+				stats.Conflicts += conflicts
+				batch = batch[:0]
+				if progress != nil {
+					progress(stats)
+				}
+				return nil
+			}
+
+			for iter.Next(ctx) {
+				batch = append(batch, iter.Val())
+				if len(batch) == migrateBatchSize {
+					if err := flush(); err != nil {
+						return stats, err
+					}
+				}
+			}
+			if err := iter.Err(); err != nil {
+				return stats, fmt.Errorf("redis scan error: %w", err)
+			}
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// migrateKeys copies or moves keys from the from prefix to the to prefix and
+// returns the number of migrated keys and of conflicts
+func (s *redisStore) migrateKeys(ctx context.Context, keys []string, from, to string, opts MigrationOptions) (int64, int64, error) {
+	results := make([]*redis.IntCmd, len(keys))
+	boolResults := make([]*redis.BoolCmd, len(keys))
+	var counts []*redis.FloatCmd
+	var lastSeen []*redis.StringCmd
+	unmaskKeys := strings.HasPrefix(from, "unmask:")
+	db := s.client.Options().DB
+
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			newKey := to + strings.TrimPrefix(key, from)
+			switch {
+			case opts.DryRun:
+				results[i] = pipe.Exists(ctx, newKey)
+			case opts.Move:
+				boolResults[i] = pipe.RenameNX(ctx, key, newKey)
+			default:
+				results[i] = pipe.Copy(ctx, key, newKey, db, false)
+			}
+			if unmaskKeys && !opts.DryRun {
+				counts = append(counts, pipe.ZScore(ctx, accessCountsKey, key))
+				lastSeen = append(lastSeen, pipe.HGet(ctx, lastSeenKey, key))
+			}
+		}
+		return nil
+	})
+	// Keys without access counts fail with redis.Nil, so the errors of the key
+	// commands are checked one by one
+	if err != nil && !errors.Is(err, redis.Nil) && !isNoSuchKey(err) {
+		return 0, 0, fmt.Errorf("redis migrate error: %w", err)
+	}
+
+	var migrated, conflicts int64
+	var migratedKeys []int
+	for i := range keys {
+		var ok bool
+		switch {
+		case opts.DryRun:
+			// Existing new keys would conflict
+			ok, err = results[i].Val() == 0, results[i].Err()
+		case opts.Move:
+			ok, err = boolResults[i].Result()
+		default:
+			ok, err = results[i].Val() == 1, results[i].Err()
+		}
+		if isNoSuchKey(err) {
+			// The key expired since it was scanned
+			continue
+		}
+		if err != nil {
+			return migrated, conflicts, fmt.Errorf("redis migrate error: %w", err)
+		}
+		if ok {
+			migrated++
+			migratedKeys = append(migratedKeys, i)
+		} else {
+			conflicts++
+		}
+	}
+	if !unmaskKeys || opts.DryRun {
+		return migrated, conflicts, nil
+	}
+
+	// Access counts follow their unmask keys
+	_, err = s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, i := range migratedKeys {
+			newKey := to + strings.TrimPrefix(keys[i], from)
+			if counts[i].Err() == nil {
+				pipe.ZAddNX(ctx, accessCountsKey, redis.Z{Score: counts[i].Val(), Member: newKey})
+			}
+			if lastSeen[i].Err() == nil {
+				pipe.HSetNX(ctx, lastSeenKey, newKey, lastSeen[i].Val())
+			}
+			if opts.Move {
+				pipe.ZRem(ctx, accessCountsKey, keys[i])
+				pipe.HDel(ctx, lastSeenKey, keys[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return migrated, conflicts, fmt.Errorf("redis migrate error: %w", err)
+	}
+	return migrated, conflicts, nil
+}
+
+// isNoSuchKey reports whether err is the reply of RENAMENX for a missing key
+func isNoSuchKey(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such key")
+}
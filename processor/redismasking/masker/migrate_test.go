@@ -0,0 +1,141 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMigrationRules(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rules       []MigrationRule
+		expectedErr string
+	}{
+		{name: "valid", rules: []MigrationRule{{From: "ip", To: "ipv4"}, {From: "host", To: "vendor_x/hostname"}}},
+		{name: "no rules", expectedErr: "at least one migration rule is required"},
+		{name: "missing category", rules: []MigrationRule{{From: "ip"}}, expectedErr: "migration rules require both categories"},
+		{name: "colon", rules: []MigrationRule{{From: "ip", To: "ip:v4"}}, expectedErr: "migration categories must not contain ':'"},
+		{name: "same category", rules: []MigrationRule{{From: "ip", To: "ip"}}, expectedErr: "migration rule moves 'ip' to itself"},
+		{
+			name:        "twice",
+			rules:       []MigrationRule{{From: "ip", To: "ipv4"}, {From: "ip", To: "ipv6"}},
+			expectedErr: "category 'ip' is migrated twice",
+		},
+		{
+			name:        "chained",
+			rules:       []MigrationRule{{From: "ip", To: "ipv4"}, {From: "ipv4", To: "vendor_x/ipv4"}},
+			expectedErr: "category 'ipv4' is both migrated and a migration target",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMigrationRules(tc.rules)
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// newMigrationStore returns a store with mappings in the "ip" category
+func newMigrationStore(t *testing.T) (Store, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	ctx := context.Background()
+
+	store, err := NewRedisStore(ctx, &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	put := func(category, original, token string, ttl time.Duration) {
+		require.NoError(t, store.Set(ctx, MaskKey(category, original), token, ttl))
+		require.NoError(t, store.Set(ctx, UnmaskKey(category, token), original, ttl))
+	}
+	put("ip", "192.168.1.1", "10.1.2.3", time.Hour)
+	put("ip", "192.168.1.2", "10.1.2.4", 0)
+	put("hostname", "web-01", "host-1.masked.local", 0)
+	// An agent of the new release already masked this value
+	put("ipv4", "192.168.1.2", "10.9.9.9", 0)
+
+	tracker := store.(AccessTracker)
+	require.NoError(t, tracker.RecordAccess(ctx, map[string]int64{UnmaskKey("ip", "10.1.2.3"): 3}, time.Unix(1700000000, 0)))
+	return store, server
+}
+
+func TestMigrate(t *testing.T) {
+	store, server := newMigrationStore(t)
+	ctx := context.Background()
+	migrator := store.(Migrator)
+	rules := []MigrationRule{{From: "ip", To: "ipv4"}}
+
+	// A dry run only counts
+	stats, err := migrator.Migrate(ctx, rules, MigrationOptions{DryRun: true}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStats{Migrated: 3, Conflicts: 1}, stats)
+	assert.False(t, server.Exists(MaskKey("ipv4", "192.168.1.1")))
+
+	var progress []MigrationStats
+	stats, err = migrator.Migrate(ctx, rules, MigrationOptions{}, func(stats MigrationStats) { progress = append(progress, stats) })
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStats{Migrated: 3, Conflicts: 1}, stats)
+	assert.Equal(t, []MigrationStats{{Migrated: 1, Conflicts: 1}, {Migrated: 3, Conflicts: 1}}, progress)
+
+	// Both directions are copied with their TTL, and existing keys are kept
+	value, _, err := store.Get(ctx, MaskKey("ipv4", "192.168.1.1"))
+	require.NoError(t, err)
+	assert.Equal(t, "10.1.2.3", value)
+	assert.Equal(t, time.Hour, server.TTL(MaskKey("ipv4", "192.168.1.1")))
+	value, _, err = store.Get(ctx, UnmaskKey("ipv4", "10.1.2.4"))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.2", value)
+	value, _, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.2"))
+	require.NoError(t, err)
+	assert.Equal(t, "10.9.9.9", value)
+
+	// The old keys stay for agents of the old release
+	assert.True(t, server.Exists(MaskKey("ip", "192.168.1.1")))
+	assert.True(t, server.Exists(MaskKey("hostname", "web-01")))
+
+	// Access counts follow the unmask keys
+	top, err := store.(AccessTracker).TopAccessed(ctx, 10)
+	require.NoError(t, err)
+	keys := []string{}
+	for _, stat := range top {
+		keys = append(keys, stat.Key)
+	}
+	assert.ElementsMatch(t, []string{UnmaskKey("ip", "10.1.2.3"), UnmaskKey("ipv4", "10.1.2.3")}, keys)
+
+	// Running it again migrates nothing new
+	stats, err = migrator.Migrate(ctx, rules, MigrationOptions{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStats{Conflicts: 4}, stats)
+}
+
+func TestMigrateMove(t *testing.T) {
+	store, server := newMigrationStore(t)
+	ctx := context.Background()
+
+	stats, err := store.(Migrator).Migrate(ctx, []MigrationRule{{From: "ip", To: "vendor_x/ipv4"}}, MigrationOptions{Move: true, Rate: 1000}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, MigrationStats{Migrated: 4}, stats)
+
+	assert.False(t, server.Exists(MaskKey("ip", "192.168.1.1")))
+	assert.False(t, server.Exists(UnmaskKey("ip", "10.1.2.4")))
+	assert.Equal(t, time.Hour, server.TTL(MaskKey("vendor_x/ipv4", "192.168.1.1")))
+
+	top, err := store.(AccessTracker).TopAccessed(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	assert.Equal(t, UnmaskKey("vendor_x/ipv4", "10.1.2.3"), top[0].Key)
+	assert.Equal(t, int64(3), top[0].Count)
+	assert.Equal(t, time.Unix(1700000000, 0), top[0].LastSeen)
+}
@@ -0,0 +1,151 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ValidateRedisPasswordFile checks that a password file replaces both the
+// static password and the token providers
+func ValidateRedisPasswordFile(passwordFile, password string, auth *AuthConfig) error {
+	if passwordFile == "" {
+		return nil
+	}
+	if password != "" {
+		return errors.New("redis_password and redis_password_file are mutually exclusive")
+	}
+	if auth.Enabled() {
+		return errors.New("redis_password_file is not used with a redis_auth provider")
+	}
+	return nil
+}
+
+// passwordFile provides the Redis password read from a file, e.g. one mounted
+// from a secret store. The file is read again once Redis rejects the password,
+// so new connections pick up a rotated password without a restart, while open
+// connections stay authenticated.
+type passwordFile struct {
+	path string
+
+	mu       sync.Mutex
+	password string
+	stale    bool
+}
+
+// newPasswordFile reads the password from path
+func newPasswordFile(path string) (*passwordFile, error) {
+	p := &passwordFile{path: path}
+	if err := p.read(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// read reads the password, ignoring a trailing newline. The caller holds p.mu
+// unless p is not shared yet.
+func (p *passwordFile) read() error {
+	// #nosec G304 -- the password file is provided by the collector configuration
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read redis_password_file: %w", err)
+	}
+	p.password = strings.TrimRight(string(data), "\r\n")
+	p.stale = false
+	return nil
+}
+
+// credentials returns the password of a new connection, reading the file again
+// when the password was rejected
+func (p *passwordFile) credentials(context.Context) (string, string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stale {
+		if err := p.read(); err != nil {
+			return "", "", err
+		}
+	}
+	return "", p.password, nil
+}
+
+// invalidate reads the file again for the next connection
+func (p *passwordFile) invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stale = true
+}
+
+// passwordFileHook invalidates the password whenever a command fails because
+// its connection could not authenticate
+type passwordFileHook struct {
+	file *passwordFile
+}
+
+var _ redis.Hook = passwordFileHook{}
+
+func (h passwordFileHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h passwordFileHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		h.check(err)
+		return err
+	}
+}
+
+func (h passwordFileHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		h.check(err)
+		return err
+	}
+}
+
+// check invalidates the password when err is an authentication failure
+func (h passwordFileHook) check(err error) {
+	if isAuthError(err) {
+		h.file.invalidate()
+	}
+}
+
+// isAuthError reports whether err is Redis rejecting the credentials, or
+// requiring them after the password was removed from the connection
+func isAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.HasPrefix(msg, "failed to authenticate") ||
+		strings.Contains(msg, "WRONGPASS") ||
+		strings.HasPrefix(msg, "NOAUTH")
+}
+
+// newRedisClient returns a client with options, reading passwords again after
+// authentication fails. passwords is nil without a redis_password_file.
+func newRedisClient(options *redis.Options, passwords *passwordFile) *redis.Client {
+	client := redis.NewClient(options)
+	if passwords != nil {
+		client.AddHook(passwordFileHook{file: passwords})
+	}
+	return client
+}
+
+// NewRedisClient returns a client of the Redis server described by cfg
+func (cfg *Config) NewRedisClient() (*redis.Client, error) {
+	options, passwords, err := cfg.redisOptions()
+	if err != nil {
+		return nil, err
+	}
+	return newRedisClient(options, passwords), nil
+}
@@ -0,0 +1,66 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRedisPasswordFile(t *testing.T) {
+	require.NoError(t, ValidateRedisPasswordFile("", "secret", &AuthConfig{}))
+	require.NoError(t, ValidateRedisPasswordFile("/run/secrets/redis", "", &AuthConfig{}))
+	require.EqualError(t, ValidateRedisPasswordFile("/run/secrets/redis", "secret", &AuthConfig{}), "redis_password and redis_password_file are mutually exclusive")
+	require.EqualError(t, ValidateRedisPasswordFile("/run/secrets/redis", "", &AuthConfig{Provider: authProviderEntra}), "redis_password_file is not used with a redis_auth provider")
+}
+
+func TestRedisPasswordFileRotation(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("first")
+	passwordFile := filepath.Join(t.TempDir(), "redis-password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	cfg.RedisPasswordFile = passwordFile
+	ctx := context.Background()
+	store, err := NewRedisStore(ctx, &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	require.NoError(t, store.Set(ctx, "key", "value", 0))
+
+	// The password is rotated and the connections are dropped
+	server.RequireAuth("second")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0o600))
+	server.Close()
+	require.NoError(t, server.Restart())
+
+	// The rejected password is replaced by the rotated one
+	var getErr error
+	for range 3 {
+		if _, _, getErr = store.Get(ctx, "key"); getErr == nil {
+			break
+		}
+	}
+	require.NoError(t, getErr)
+}
+
+func TestRedisPasswordFileMissing(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = "localhost:6379"
+	cfg.RedisPasswordFile = filepath.Join(t.TempDir(), "missing")
+	_, err := NewLazyRedisStore(&cfg)
+	require.ErrorContains(t, err, "failed to read redis_password_file")
+}
+
+func TestIsAuthError(t *testing.T) {
+	assert.True(t, isAuthError(errors.New("failed to authenticate: WRONGPASS invalid username-password pair")))
+	assert.True(t, isAuthError(errors.New("NOAUTH Authentication required.")))
+	assert.False(t, isAuthError(errors.New("dial tcp: connection refused")))
+	assert.False(t, isAuthError(nil))
+}
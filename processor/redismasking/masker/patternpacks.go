@@ -0,0 +1,160 @@
+package masker
+
+import "slices"
+
+// Built-in pattern packs
+const (
+	// patternPackAPIKeys detects credentials by the well-known prefixes of their providers
+	patternPackAPIKeys = "api_keys"
+
+	// patternPackVehicles detects VINs and license plates
+	patternPackVehicles = "vehicles"
+
+	// patternPackShipping detects parcel tracking numbers
+	patternPackShipping = "shipping"
+
+	// patternPackHealthcare detects medical record numbers, NPIs, ICD-10 codes,
+	// and patient names
+	patternPackHealthcare = "healthcare"
+
+	// patternPackPaymentCards detects card numbers and replaces them with
+	// surrogate card numbers
+	patternPackPaymentCards = "payment_cards"
+)
+
+// licensePlatePattern is the name of the license plate pattern of the vehicles
+// pack, whose regex is built from the configured formats
+const licensePlatePattern = "license_plate"
+
+// patternPacks are the built-in detector sets that can be enabled with pattern_packs.
+// Tokens keep the provider prefix so it remains visible what kind of secret leaked,
+// followed by a marker so the token is never mistaken for a live credential.
+var patternPacks = map[string][]PatternConfig{
+	patternPackAPIKeys: {
+		{
+			Name:         "stripe_secret_key",
+			Regex:        `\bsk_live_[0-9a-zA-Z]{24,99}\b`,
+			MaskedPrefix: "sk_live_MASKED_",
+		},
+		{
+			Name:         "github_token",
+			Regex:        `\bghp_[0-9a-zA-Z]{36}\b`,
+			MaskedPrefix: "ghp_MASKED_",
+		},
+		{
+			Name:         "slack_bot_token",
+			Regex:        `\bxoxb-[0-9]{10,13}-[0-9]{10,13}-[0-9a-zA-Z]{24}\b`,
+			MaskedPrefix: "xoxb-MASKED-",
+		},
+		{
+			Name:         "aws_access_key_id",
+			Regex:        `\bAKIA[0-9A-Z]{16}\b`,
+			MaskedPrefix: "AKIAMASKED",
+		},
+		{
+			Name:         "google_api_key",
+			Regex:        `\bAIza[0-9A-Za-z_\-]{35}`,
+			MaskedPrefix: "AIzaMASKED_",
+		},
+		{
+			Name:         "gitlab_token",
+			Regex:        `\bglpat-[0-9a-zA-Z_\-]{20}`,
+			MaskedPrefix: "glpat-MASKED-",
+		},
+	},
+	patternPackVehicles: {
+		{
+			Name:         "vin",
+			Regex:        `\b[A-HJ-NPR-Z0-9]{17}\b`,
+			MaskedPrefix: "VIN-",
+			valid:        validVIN,
+		},
+		{
+			Name:         licensePlatePattern,
+			MaskedPrefix: "PLATE-",
+		},
+	},
+	patternPackShipping: {
+		{
+			Name:         "ups_tracking",
+			Regex:        `\b1Z[0-9A-Z]{16}\b`,
+			MaskedPrefix: "1ZMASKED",
+			valid:        validUPSTracking,
+		},
+		{
+			Name:         "usps_tracking",
+			Regex:        `\b9[2-5][0-9]{20}\b`,
+			MaskedPrefix: "USPS-",
+			valid:        validUSPSTracking,
+		},
+		{
+			Name:         "s10_tracking",
+			Regex:        `\b[A-Z]{2}[0-9]{9}[A-Z]{2}\b`,
+			MaskedPrefix: "S10-",
+			valid:        validS10Tracking,
+		},
+	},
+	patternPackPaymentCards: {
+		{
+			Name:  paymentCardPattern,
+			Regex: panRegex.String(),
+			valid: validLuhn,
+		},
+	},
+	patternPackHealthcare: {
+		{
+			Name:         mrnPattern,
+			MaskedPrefix: "MRN-",
+		},
+		{
+			Name:         "npi",
+			Regex:        `\b[12][0-9]{9}\b`,
+			MaskedPrefix: "NPI-",
+			valid:        validNPI,
+		},
+		{
+			Name:         "icd10",
+			Regex:        `\b[A-TV-Z][0-9][0-9AB]\.[0-9A-TV-Z]{1,4}\b`,
+			MaskedPrefix: "ICD-",
+		},
+		{
+			Name:         patientNamePattern,
+			Regex:        patientNameRegex,
+			MaskedPrefix: "PATIENT-",
+		},
+	},
+}
+
+// packPatterns returns the patterns of the enabled pattern packs. A pattern
+// configured with the same name takes precedence over the built-in one.
+func (cfg *Config) packPatterns() []PatternConfig {
+	var patterns []PatternConfig
+	for _, pack := range cfg.PatternPacks {
+		for _, pattern := range patternPacks[pack] {
+			overridden := slices.ContainsFunc(cfg.Patterns, func(p PatternConfig) bool {
+				return p.Name == pattern.Name
+			})
+			if overridden {
+				continue
+			}
+			switch pattern.Name {
+			case licensePlatePattern:
+				// Plates are only detected in the configured formats
+				if pattern.Regex = cfg.LicensePlates.regex(); pattern.Regex == "" {
+					continue
+				}
+			case mrnPattern:
+				if pattern.Regex = wordAlternation(cfg.Healthcare.MRNFormats); pattern.Regex == "" {
+					continue
+				}
+			case patientNamePattern:
+				// Words are masked when they are found in the names file
+				if pattern.namesFile = cfg.Healthcare.PatientNamesFile; pattern.namesFile == "" {
+					continue
+				}
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
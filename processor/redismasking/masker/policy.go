@@ -0,0 +1,175 @@
+package masker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// Decisions returned by an OPA policy
+const (
+	decisionMask         = "mask"
+	decisionIrreversible = "irreversible"
+	decisionSkip         = "skip"
+	decisionDrop         = "drop"
+)
+
+// policyHook evaluates an OPA policy for each record
+type policyHook struct {
+	query          rego.PreparedEvalQuery
+	destination    string
+	authAttributes []string
+	metadataKeys   []string
+	now            func() time.Time
+}
+
+// newPolicyHook compiles the policy described by cfg
+func newPolicyHook(ctx context.Context, cfg *OPAConfig) (*policyHook, error) {
+	// #nosec G304 -- the policy file is provided by the collector configuration
+	module, err := os.ReadFile(cfg.PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OPA policy: %w", err)
+	}
+
+	query, err := rego.New(
+		rego.Query(cfg.Query),
+		rego.Module(cfg.PolicyFile, string(module)),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile OPA policy: %w", err)
+	}
+
+	return &policyHook{
+		query:          query,
+		destination:    cfg.Destination,
+		authAttributes: cfg.AuthAttributes,
+		metadataKeys:   cfg.MetadataKeys,
+		now:            time.Now,
+	}, nil
+}
+
+// decide evaluates the policy for a record. Records are masked when the policy
+// does not produce a decision.
+func (h *policyHook) decide(ctx context.Context, resource pcommon.Resource, lr plog.LogRecord, categories []string) (string, error) {
+	input := map[string]any{
+		"resource":    resource.Attributes().AsRaw(),
+		"attributes":  lr.Attributes().AsRaw(),
+		"categories":  categories,
+		"destination": h.destination,
+		"client":      h.clientInput(ctx),
+	}
+	if age, ok := h.recordAge(lr); ok {
+		input["age_seconds"] = age.Seconds()
+	}
+
+	results, err := h.query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return decisionMask, fmt.Errorf("failed to evaluate OPA policy: %w", err)
+	}
+	if len(results) == 0 || len(results[0].Expressions) == 0 {
+		return decisionMask, nil
+	}
+
+	decision, ok := results[0].Expressions[0].Value.(string)
+	if !ok || !slices.Contains([]string{decisionMask, decisionIrreversible, decisionSkip, decisionDrop}, decision) {
+		return decisionMask, fmt.Errorf("%w '%v'", errUnsupportedDecision, results[0].Expressions[0].Value)
+	}
+	return decision, nil
+}
+
+// recordAge returns how long ago lr occurred, or when it was observed when it
+// has no timestamp. Records without either have no age.
+func (h *policyHook) recordAge(lr plog.LogRecord) (time.Duration, bool) {
+	timestamp := lr.Timestamp()
+	if timestamp == 0 {
+		timestamp = lr.ObservedTimestamp()
+	}
+	if timestamp == 0 {
+		return 0, false
+	}
+	return h.now().Sub(timestamp.AsTime()), true
+}
+
+// clientInput describes the caller that sent the record from the client info
+// propagated by the receiver. Unlike resource attributes, the authenticated
+// attributes are set by the authenticator and cannot be spoofed by the caller.
+func (h *policyHook) clientInput(ctx context.Context) map[string]any {
+	info := client.FromContext(ctx)
+
+	addr := ""
+	if info.Addr != nil {
+		addr = info.Addr.String()
+	}
+
+	auth := map[string]any{}
+	if info.Auth != nil {
+		for _, name := range h.authAttributes {
+			if value := info.Auth.GetAttribute(name); value != nil {
+				auth[name] = value
+			}
+		}
+	}
+
+	metadata := map[string]any{}
+	for _, key := range h.metadataKeys {
+		if values := info.Metadata.Get(key); len(values) > 0 {
+			metadata[key] = values
+		}
+	}
+
+	return map[string]any{
+		"addr":     addr,
+		"auth":     auth,
+		"metadata": metadata,
+	}
+}
+
+// recordCategories returns the categories of the sensitive values found in lr
+func (m *Masker) recordCategories(lr plog.LogRecord) []string {
+	categories := []string{}
+	for _, field := range m.fieldsToMask {
+		if _, ok := lr.Attributes().Get(field); ok {
+			categories = append(categories, attributeCategory(field))
+		}
+	}
+
+	if lr.Body().Type() == pcommon.ValueTypeStr {
+		for _, pattern := range m.compiledPatterns {
+			if pattern.matches(lr.Body().Str()) {
+				categories = append(categories, pattern.name)
+			}
+		}
+	}
+	return categories
+}
+
+// shouldDrop applies the policy decision for lr, masking it unless the policy
+// skips it. It reports whether the record must be dropped.
+func (m *Masker) shouldDrop(ctx context.Context, resource pcommon.Resource, lr plog.LogRecord) bool {
+	decision, err := m.policy.decide(ctx, resource, lr, m.recordCategories(lr))
+	if err != nil {
+		// Fail closed to masking so a broken policy never leaks values
+		m.logError("Failed to apply masking policy", err)
+		m.recordFallback(ctx, fallbackPolicyMask, err)
+	}
+
+	switch decision {
+	case decisionDrop:
+		return true
+	case decisionSkip:
+		return false
+	case decisionIrreversible:
+		m.MaskLogRecord(withIrreversible(ctx), lr)
+		return false
+	default:
+		m.MaskLogRecord(ctx, lr)
+		return false
+	}
+}
@@ -0,0 +1,196 @@
+package masker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/client"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+func TestPolicyHook(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"password"}
+	cfg.OPA.PolicyFile = filepath.Join("testdata", "policy.rego")
+	cfg.OPA.Destination = "internal"
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	trusted := ld.ResourceLogs().AppendEmpty()
+	trusted.Resource().Attributes().PutStr("service.name", "trusted")
+	trustedRecords := trusted.ScopeLogs().AppendEmpty().LogRecords()
+	skipped := trustedRecords.AppendEmpty()
+	skipped.Body().SetStr("request from 192.168.1.1")
+	dropped := trustedRecords.AppendEmpty()
+	dropped.Attributes().PutStr("password", "hunter2")
+
+	other := ld.ResourceLogs().AppendEmpty()
+	other.Resource().Attributes().PutStr("service.name", "checkout")
+	masked := other.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	masked.Body().SetStr("request from 192.168.1.1")
+
+	m.MaskLogs(context.Background(), ld)
+
+	require.Equal(t, 1, trustedRecords.Len())
+	assert.Equal(t, "request from 192.168.1.1", trustedRecords.At(0).Body().Str())
+	assert.Equal(t, "request from "+m.generateMaskedValue("192.168.1.1", "ipv4"), masked.Body().Str())
+}
+
+func TestPolicyHookInvalidDecision(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policyFile, []byte("package redismasking\n\ndecision := \"redact\"\n"), 0o600))
+
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.OPA.PolicyFile = policyFile
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Body().SetStr("request from 192.168.1.1")
+
+	// Unsupported decisions fall back to masking
+	m.MaskLogs(context.Background(), ld)
+	assert.Equal(t, "request from "+m.generateMaskedValue("192.168.1.1", "ipv4"), lr.Body().Str())
+}
+
+func TestPolicyHookCompileError(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policyFile, []byte("package redismasking\n\ndecision :=\n"), 0o600))
+
+	cfg := NewDefaultConfig()
+	cfg.OPA.PolicyFile = policyFile
+	_, err := New(&cfg, nil, zap.NewNop())
+	require.ErrorContains(t, err, "failed to compile OPA policy")
+
+	cfg.OPA.PolicyFile = filepath.Join(t.TempDir(), "missing.rego")
+	_, err = New(&cfg, nil, zap.NewNop())
+	require.ErrorContains(t, err, "failed to read OPA policy")
+}
+
+// testAuthData is the auth data of an authenticated caller
+type testAuthData map[string]any
+
+func (a testAuthData) GetAttribute(name string) any {
+	return a[name]
+}
+
+func (a testAuthData) GetAttributeNames() []string {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestPolicyHookClient(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policyFile, []byte(`package redismasking
+
+decision := "skip" if {
+	input.client.auth.tenant == "trusted"
+	input.client.metadata["x-region"][0] == "eu"
+	not input.client.auth.token
+} else := "mask"
+`), 0o600))
+
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.OPA.PolicyFile = policyFile
+	cfg.OPA.AuthAttributes = []string{"tenant"}
+	cfg.OPA.MetadataKeys = []string{"x-region"}
+	m, _ := newTestMasker(t, &cfg)
+
+	newLogs := func() (plog.Logs, plog.LogRecord) {
+		ld := plog.NewLogs()
+		rl := ld.ResourceLogs().AppendEmpty()
+		rl.Resource().Attributes().PutStr("tenant", "trusted")
+		lr := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		lr.Body().SetStr("request from 192.168.1.1")
+		return ld, lr
+	}
+	masked := "request from " + m.generateMaskedValue("192.168.1.1", "ipv4")
+
+	// The authenticated tenant selects the policy
+	ctx := client.NewContext(context.Background(), client.Info{
+		Auth:     testAuthData{"tenant": "trusted", "token": "secret"},
+		Metadata: client.NewMetadata(map[string][]string{"x-region": {"eu"}}),
+	})
+	ld, lr := newLogs()
+	m.MaskLogs(ctx, ld)
+	assert.Equal(t, "request from 192.168.1.1", lr.Body().Str())
+
+	// Other callers cannot claim the tenant through resource attributes
+	ctx = client.NewContext(context.Background(), client.Info{
+		Auth:     testAuthData{"tenant": "other"},
+		Metadata: client.NewMetadata(map[string][]string{"x-region": {"eu"}}),
+	})
+	ld, lr = newLogs()
+	m.MaskLogs(ctx, ld)
+	assert.Equal(t, masked, lr.Body().Str())
+
+	// Unauthenticated callers are masked
+	ld, lr = newLogs()
+	m.MaskLogs(context.Background(), ld)
+	assert.Equal(t, masked, lr.Body().Str())
+}
+
+func TestPolicyHookRecordAge(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policyFile, []byte(`package redismasking
+
+decision := "irreversible" if {
+	input.age_seconds > 30 * 24 * 60 * 60
+} else := "mask"
+`), 0o600))
+
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"username"}
+	cfg.EnrichWithLookupURL = "https://unmask.internal"
+	cfg.OPA.PolicyFile = policyFile
+	m, server := newTestMasker(t, &cfg)
+
+	now := time.Now()
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	replayed := records.AppendEmpty()
+	replayed.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-60 * 24 * time.Hour)))
+	replayed.Body().SetStr("request from 192.168.1.2")
+	replayed.Attributes().PutStr("username", "alice")
+	fresh := records.AppendEmpty()
+	fresh.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Minute)))
+	fresh.Body().SetStr("request from 192.168.1.1")
+	observed := records.AppendEmpty()
+	observed.SetObservedTimestamp(pcommon.NewTimestampFromTime(now.Add(-60 * 24 * time.Hour)))
+	observed.Body().SetStr("request from 192.168.1.3")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// Old records get tokens whose mappings are never stored
+	assert.Equal(t, "request from "+m.irreversibleToken("192.168.1.2", "ipv4"), replayed.Body().Str())
+	assert.NotEqual(t, m.generateMaskedValue("192.168.1.2", "ipv4"), m.irreversibleToken("192.168.1.2", "ipv4"))
+	username, _ := replayed.Attributes().Get("username")
+	assert.Equal(t, m.irreversibleToken("alice", "attribute_username"), username.Str())
+	assert.False(t, server.Exists(MaskKey("ipv4", "192.168.1.2")))
+	assert.False(t, server.Exists(MaskKey("attribute_username", "alice")))
+	_, ok := replayed.Attributes().Get("username.lookup_url")
+	assert.False(t, ok)
+	_, ok = replayed.Attributes().Get(lookupURLsAttribute)
+	assert.False(t, ok)
+
+	// The observed timestamp stands in for a missing timestamp
+	assert.Equal(t, "request from "+m.irreversibleToken("192.168.1.3", "ipv4"), observed.Body().Str())
+
+	// Fresh records keep reversible tokens
+	assert.Equal(t, "request from "+m.generateMaskedValue("192.168.1.1", "ipv4"), fresh.Body().Str())
+	assert.True(t, server.Exists(MaskKey("ipv4", "192.168.1.1")))
+}
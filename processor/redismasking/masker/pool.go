@@ -0,0 +1,44 @@
+package masker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PoolConfig tunes the connection pool of the Redis client. At high throughput
+// the default pool of 10 connections per CPU can starve, so lookups queue for a
+// connection. Zero values keep the client defaults.
+type PoolConfig struct {
+	// Size is the maximum number of connections
+	Size int `mapstructure:"size"`
+
+	// MinIdleConns is the number of idle connections kept open
+	MinIdleConns int `mapstructure:"min_idle_conns"`
+
+	// MaxConnAge is how long a connection is used before it is replaced
+	MaxConnAge time.Duration `mapstructure:"max_conn_age"`
+
+	// Timeout is how long a command waits for a free connection
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks that no setting is negative and that the idle connections fit the pool
+func (cfg *PoolConfig) Validate() error {
+	if cfg.Size < 0 || cfg.MinIdleConns < 0 || cfg.MaxConnAge < 0 || cfg.Timeout < 0 {
+		return errors.New("redis_pool settings must be non-negative")
+	}
+	if cfg.Size > 0 && cfg.MinIdleConns > cfg.Size {
+		return errors.New("redis_pool min_idle_conns must not exceed size")
+	}
+	return nil
+}
+
+// apply sets the configured pool settings on options
+func (cfg *PoolConfig) apply(options *redis.Options) {
+	options.PoolSize = cfg.Size
+	options.MinIdleConns = cfg.MinIdleConns
+	options.ConnMaxLifetime = cfg.MaxConnAge
+	options.PoolTimeout = cfg.Timeout
+}
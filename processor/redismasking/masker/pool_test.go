@@ -0,0 +1,36 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisPool(t *testing.T) {
+	cfg := NewDefaultConfig()
+
+	// Zero values keep the client defaults
+	options, err := cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Zero(t, options.PoolSize)
+	assert.Zero(t, options.PoolTimeout)
+
+	cfg.RedisPool = PoolConfig{Size: 64, MinIdleConns: 16, MaxConnAge: 30 * time.Minute, Timeout: 2 * time.Second}
+	options, err = cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Equal(t, 64, options.PoolSize)
+	assert.Equal(t, 16, options.MinIdleConns)
+	assert.Equal(t, 30*time.Minute, options.ConnMaxLifetime)
+	assert.Equal(t, 2*time.Second, options.PoolTimeout)
+
+	server := miniredis.RunT(t)
+	cfg.RedisAddr = server.Addr()
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, store.Set(context.Background(), "key", "value", 0))
+}
@@ -0,0 +1,35 @@
+package masker
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pprofile"
+)
+
+// MaskProfiles masks the resource attributes and the shared attribute table of
+// pd in place, using the same fields, patterns, and tokens as log records.
+// Samples reference their attributes in the table, so each distinct attribute
+// is masked once for every sample using it.
+func (m *Masker) MaskProfiles(ctx context.Context, pd pprofile.Profiles) {
+	defer m.observeSince(time.Now())
+
+	if !m.detectOnly() {
+		for i := 0; i < pd.ResourceProfiles().Len(); i++ {
+			m.maskAttributes(ctx, pd.ResourceProfiles().At(i).Resource().Attributes(), nil)
+		}
+
+		dictionary := pd.Dictionary()
+		stringTable := dictionary.StringTable()
+		for i := 0; i < dictionary.AttributeTable().Len(); i++ {
+			attr := dictionary.AttributeTable().At(i)
+			if index := int(attr.KeyStrindex()); index >= 0 && index < stringTable.Len() {
+				m.maskAttribute(ctx, stringTable.At(index), attr.Value(), m.config.ScanAllAttributes, nil)
+			}
+		}
+	}
+
+	if err := m.FlushAccess(ctx); err != nil {
+		m.logWarn("Failed to record mapping access counts", err)
+	}
+}
@@ -0,0 +1,40 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pprofile"
+)
+
+func TestMaskProfiles(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"enduser.id"}
+	cfg.ScanAllAttributes = true
+	m, _ := newTestMasker(t, &cfg)
+
+	pd := pprofile.NewProfiles()
+	rp := pd.ResourceProfiles().AppendEmpty()
+	rp.Resource().Attributes().PutStr("host.ip", "192.168.1.1")
+
+	dictionary := pd.Dictionary()
+	dictionary.StringTable().Append("", "enduser.id", "thread.name")
+	user := dictionary.AttributeTable().AppendEmpty()
+	user.SetKeyStrindex(1)
+	user.Value().SetStr("alice")
+	thread := dictionary.AttributeTable().AppendEmpty()
+	thread.SetKeyStrindex(2)
+	thread.Value().SetStr("worker-192.168.1.2")
+
+	sample := rp.ScopeProfiles().AppendEmpty().Profiles().AppendEmpty().Sample().AppendEmpty()
+	sample.AttributeIndices().Append(0, 1)
+
+	m.MaskProfiles(context.Background(), pd)
+
+	hostIP, _ := rp.Resource().Attributes().Get("host.ip")
+	assert.Equal(t, m.generateMaskedValue("192.168.1.1", "ipv4"), hostIP.Str())
+	assert.Equal(t, m.generateMaskedValue("alice", attributeCategory("enduser.id")), user.Value().Str())
+	assert.Equal(t, "worker-"+m.generateMaskedValue("192.168.1.2", "ipv4"), thread.Value().Str())
+}
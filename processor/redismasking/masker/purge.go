@@ -0,0 +1,141 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PurgeScope selects the mappings deleted by a purge. Exactly one of its fields is set.
+type PurgeScope struct {
+	// Category selects the mappings of one category, e.g. "ipv4" or "vendor_x/ipv4"
+	Category string
+
+	// Namespace selects every mapping of a token namespace, e.g. "vendor_x"
+	Namespace string
+}
+
+// Validate checks that the scope selects a single category or namespace
+func (s PurgeScope) Validate() error {
+	if (s.Category == "") == (s.Namespace == "") {
+		return errors.New("exactly one of category or namespace is required")
+	}
+	if strings.ContainsAny(s.Namespace, ":/") {
+		return errors.New("namespace must not contain ':' or '/'")
+	}
+	return nil
+}
+
+// patterns returns the Redis key patterns matching the mappings of the scope
+func (s PurgeScope) patterns() []string {
+	if s.Namespace != "" {
+		return []string{"mask:" + escapeGlob(s.Namespace) + "/*", "unmask:" + escapeGlob(s.Namespace) + "/*"}
+	}
+	return []string{"mask:" + escapeGlob(s.Category) + ":*", "unmask:" + escapeGlob(s.Category) + ":*"}
+}
+
+// Purger is implemented by stores that can delete mappings in bulk
+type Purger interface {
+	// Purge deletes both directions of every mapping in scope, together with their
+	// access counts, and returns the number of deleted keys. progress, when set, is
+	// called with the running total after each batch. With dryRun set the keys are
+	// only counted.
+	Purge(ctx context.Context, scope PurgeScope, dryRun bool, progress func(keys int64)) (int64, error)
+}
+
+var _ Purger = (*redisStore)(nil)
+
+// purgeBatchSize is the number of keys requested per SCAN call
+const purgeBatchSize = 1000
+
+// Purge deletes the mappings in scope batch by batch, so the purge of a large
+// category never blocks Redis. A dry run may count a key twice when it moves
+// during the scan, while deleted keys are counted exactly.
+func (s *redisStore) Purge(ctx context.Context, scope PurgeScope, dryRun bool, progress func(keys int64)) (int64, error) {
+	if err := scope.Validate(); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, pattern := range scope.patterns() {
+		iter := s.client.Scan(ctx, 0, pattern, purgeBatchSize).Iterator()
+		var batch []string
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			deleted, err := s.purgeKeys(ctx, batch, dryRun)
+			if err != nil {
+				return err
+			}
+			total += deleted
+			batch = batch[:0]
+			if progress != nil {
+				progress(total)
+			}
+			return nil
+		}
+
+		for iter.Next(ctx) {
+			batch = append(batch, iter.Val())
+			if len(batch) == purgeBatchSize {
+				if err := flush(); err != nil {
+					return total, err
+				}
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return total, fmt.Errorf("redis scan error: %w", err)
+		}
+		if err := flush(); err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// purgeKeys deletes keys and the access counts of the unmask keys among them.
+// It returns the number of keys deleted, or of keys found in a dry run.
+func (s *redisStore) purgeKeys(ctx context.Context, keys []string, dryRun bool) (int64, error) {
+	if dryRun {
+		return int64(len(keys)), nil
+	}
+
+	var unmaskKeys []any
+	var unmaskFields []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, "unmask:") {
+			unmaskKeys = append(unmaskKeys, key)
+			unmaskFields = append(unmaskFields, key)
+		}
+	}
+
+	var deleted *redis.IntCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(ctx, keys...)
+		if len(unmaskKeys) > 0 {
+			pipe.ZRem(ctx, accessCountsKey, unmaskKeys...)
+			pipe.HDel(ctx, lastSeenKey, unmaskFields...)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("redis delete error: %w", err)
+	}
+	return deleted.Val(), nil
+}
+
+// escapeGlob escapes the characters with a special meaning in Redis key patterns
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurge(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	ctx := context.Background()
+
+	store, err := NewRedisStore(ctx, &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	put := func(category, original, token string) {
+		require.NoError(t, store.Set(ctx, MaskKey(category, original), token, 0))
+		require.NoError(t, store.Set(ctx, UnmaskKey(category, token), original, 0))
+	}
+	put("ipv4", "192.168.1.1", "10.1.2.3")
+	put("ipv4", "192.168.1.2", "10.1.2.4")
+	put("hostname", "web-01", "host-1.masked.local")
+	put("vendor_x/ipv4", "192.168.1.1", "10.9.9.9")
+	put("vendor_x/hostname", "web-01", "host-9.masked.local")
+	put("attribute_a*", "alice", "a-1")
+	tracker := store.(AccessTracker)
+	require.NoError(t, tracker.RecordAccess(ctx, map[string]int64{UnmaskKey("ipv4", "10.1.2.3"): 3, UnmaskKey("hostname", "host-1.masked.local"): 1}, time.Now()))
+
+	purger := store.(Purger)
+
+	// A dry run only counts
+	count, err := purger.Purge(ctx, PurgeScope{Category: "ipv4"}, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+	assert.True(t, server.Exists(MaskKey("ipv4", "192.168.1.1")))
+
+	var progress []int64
+	count, err = purger.Purge(ctx, PurgeScope{Category: "ipv4"}, false, func(keys int64) { progress = append(progress, keys) })
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+	assert.Equal(t, []int64{2, 4}, progress)
+	assert.False(t, server.Exists(MaskKey("ipv4", "192.168.1.1")))
+	assert.False(t, server.Exists(UnmaskKey("ipv4", "10.1.2.4")))
+
+	// Other categories, namespaces, and their access counts are kept
+	assert.True(t, server.Exists(MaskKey("vendor_x/ipv4", "192.168.1.1")))
+	top, err := tracker.TopAccessed(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	assert.Equal(t, UnmaskKey("hostname", "host-1.masked.local"), top[0].Key)
+
+	count, err = purger.Purge(ctx, PurgeScope{Namespace: "vendor_x"}, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+	assert.True(t, server.Exists(MaskKey("hostname", "web-01")))
+
+	// Categories are matched literally
+	count, err = purger.Purge(ctx, PurgeScope{Category: "attribute_*"}, false, nil)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+	count, err = purger.Purge(ctx, PurgeScope{Category: "attribute_a*"}, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestPurgeScopeValidate(t *testing.T) {
+	require.EqualError(t, PurgeScope{}.Validate(), "exactly one of category or namespace is required")
+	require.EqualError(t, PurgeScope{Category: "ipv4", Namespace: "vendor_x"}.Validate(), "exactly one of category or namespace is required")
+	require.EqualError(t, PurgeScope{Namespace: "vendor_x/ipv4"}.Validate(), "namespace must not contain ':' or '/'")
+	require.NoError(t, PurgeScope{Category: "vendor_x/ipv4"}.Validate())
+}
@@ -0,0 +1,49 @@
+package masker
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// readOnlyFallbackHash replaces unknown values with their derived token
+	readOnlyFallbackHash = "hash"
+
+	// readOnlyFallbackDrop replaces unknown values with an empty string
+	readOnlyFallbackDrop = "drop"
+)
+
+// ReadOnlyConfig defines how read_only mode masks values without a mapping
+type ReadOnlyConfig struct {
+	// Fallback is "hash" to replace unknown values with a token derived with the
+	// hmac_key, or "drop" to remove them. Either way nothing is stored.
+	Fallback string `mapstructure:"fallback"`
+}
+
+// Validate checks that the fallback is supported
+func (cfg *ReadOnlyConfig) Validate() error {
+	switch cfg.Fallback {
+	case readOnlyFallbackHash, readOnlyFallbackDrop:
+		return nil
+	default:
+		return fmt.Errorf("unsupported read_only fallback '%s'", cfg.Fallback)
+	}
+}
+
+// maskReadOnly returns the stored token of originalValue, or the fallback of
+// unknown values. The store is never written.
+func (m *Masker) maskReadOnly(ctx context.Context, originalValue, category string) (string, error) {
+	token, found, err := m.store.Get(ctx, MaskKey(m.namespaced(category), originalValue))
+	if err != nil {
+		return "", err
+	}
+	m.telemetry.recordLookup(ctx, found)
+	if found {
+		return token, nil
+	}
+
+	if m.config.ReadOnly.Fallback == readOnlyFallbackDrop {
+		return "", nil
+	}
+	return m.generateMaskedValue(originalValue, category), nil
+}
@@ -0,0 +1,42 @@
+package masker
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// RecordFlagsConfig scopes the processing of log records to those with
+// matching flags, so earlier pipeline stages can cheaply select the records
+// that need masking, e.g. by setting a flag bit
+type RecordFlagsConfig struct {
+	// Sampled only processes records whose sampled trace flag is set
+	Sampled bool `mapstructure:"sampled" json:"sampled"`
+
+	// Mask selects the flag bits that must equal those of Value
+	Mask uint32 `mapstructure:"mask" json:"mask"`
+
+	// Value is compared with the flag bits selected by Mask
+	Value uint32 `mapstructure:"value" json:"value"`
+}
+
+// Enabled reports whether records are selected by their flags
+func (cfg *RecordFlagsConfig) Enabled() bool {
+	return cfg.Sampled || cfg.Mask != 0
+}
+
+// Validate checks that value only sets bits selected by mask
+func (cfg *RecordFlagsConfig) Validate() error {
+	if cfg.Value&^cfg.Mask != 0 {
+		return errors.New("record_flags value must only set bits of mask")
+	}
+	return nil
+}
+
+// matches reports whether the flags of lr select it for processing
+func (cfg *RecordFlagsConfig) matches(lr plog.LogRecord) bool {
+	if cfg.Sampled && !lr.Flags().IsSampled() {
+		return false
+	}
+	return uint32(lr.Flags())&cfg.Mask == cfg.Value
+}
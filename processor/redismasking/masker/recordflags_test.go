@@ -0,0 +1,53 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestRecordFlags(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfg      RecordFlagsConfig
+		flags    plog.LogRecordFlags
+		expected bool
+	}{
+		{name: "disabled", cfg: RecordFlagsConfig{}, flags: 0, expected: true},
+		{name: "sampled", cfg: RecordFlagsConfig{Sampled: true}, flags: plog.DefaultLogRecordFlags.WithIsSampled(true), expected: true},
+		{name: "not sampled", cfg: RecordFlagsConfig{Sampled: true}, flags: 0, expected: false},
+		{name: "flag bit set", cfg: RecordFlagsConfig{Mask: 0x100, Value: 0x100}, flags: 0x101, expected: true},
+		{name: "flag bit unset", cfg: RecordFlagsConfig{Mask: 0x100, Value: 0x100}, flags: 0x1, expected: false},
+		{name: "flag bit required unset", cfg: RecordFlagsConfig{Mask: 0x100}, flags: 0x1, expected: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lr := plog.NewLogRecord()
+			lr.SetFlags(tc.flags)
+			assert.Equal(t, tc.expected, tc.cfg.matches(lr))
+		})
+	}
+
+	require.EqualError(t, (&RecordFlagsConfig{Mask: 0x100, Value: 0x101}).Validate(), "record_flags value must only set bits of mask")
+}
+
+func TestMaskLogsRecordFlags(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.RecordFlags.Sampled = true
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	sampled := records.AppendEmpty()
+	sampled.SetFlags(plog.DefaultLogRecordFlags.WithIsSampled(true))
+	sampled.Body().SetStr("connection from 192.168.1.1")
+	records.AppendEmpty().Body().SetStr("connection from 192.168.1.2")
+	m.MaskLogs(context.Background(), ld)
+
+	assert.NotContains(t, records.At(0).Body().Str(), "192.168.1.1")
+	assert.Equal(t, "connection from 192.168.1.2", records.At(1).Body().Str())
+}
@@ -0,0 +1,65 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ReplicaConfig routes lookups to read replicas of the Redis server, so a read
+// mostly masking workload does not load the primary. New mappings are always
+// written to the primary.
+type ReplicaConfig struct {
+	// Addrs are the addresses of the replicas. Lookups are spread over them
+	// round robin. Replicas share the connection settings of the primary.
+	Addrs []string `mapstructure:"addrs"`
+}
+
+// Validate checks that no replica address is empty
+func (cfg *ReplicaConfig) Validate() error {
+	for _, addr := range cfg.Addrs {
+		if addr == "" {
+			return errors.New("redis_replicas addrs must not be empty")
+		}
+	}
+	return nil
+}
+
+// newReplicaClients returns a client per replica with the options and password
+// file of the primary
+func newReplicaClients(cfg *ReplicaConfig, options *redis.Options, passwords *passwordFile) []*redis.Client {
+	clients := make([]*redis.Client, 0, len(cfg.Addrs))
+	for _, addr := range cfg.Addrs {
+		replicaOptions := *options
+		replicaOptions.Addr = addr
+		clients = append(clients, newRedisClient(&replicaOptions, passwords))
+	}
+	return clients
+}
+
+// replicaGet looks key up on the next replica. A miss is confirmed on the
+// primary, since a lagging replica may not have a new mapping yet and the new
+// token derived for it would overwrite the mapping on the primary. Failed
+// replica reads fall back to the primary.
+func (s *redisStore) replicaGet(ctx context.Context, key string) (string, bool, error) {
+	replica := s.replicas[s.nextReplica.Add(1)%uint64(len(s.replicas))]
+	value, err := replica.Get(ctx, key).Result()
+	if err == nil {
+		return value, true, nil
+	}
+	if ctx.Err() != nil {
+		return "", false, fmt.Errorf("redis get error: %w", err)
+	}
+	return s.primaryGet(ctx, key)
+}
+
+// closeReplicas closes the replica clients
+func (s *redisStore) closeReplicas() error {
+	var errs []error
+	for _, replica := range s.replicas {
+		errs = append(errs, replica.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,105 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestReplicaStore returns a store with a primary and two replicas. The
+// servers do not replicate, so tests can tell where a lookup was served from.
+func newTestReplicaStore(t *testing.T) (Store, *miniredis.Miniredis, []*miniredis.Miniredis) {
+	t.Helper()
+
+	primary := miniredis.RunT(t)
+	replicas := []*miniredis.Miniredis{miniredis.RunT(t), miniredis.RunT(t)}
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = primary.Addr()
+	cfg.RedisReplicas.Addrs = []string{replicas[0].Addr(), replicas[1].Addr()}
+
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	return store, primary, replicas
+}
+
+func TestReplicaReads(t *testing.T) {
+	store, primary, replicas := newTestReplicaStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, primary.Set("key", "primary"))
+	require.NoError(t, replicas[0].Set("key", "replica-0"))
+	require.NoError(t, replicas[1].Set("key", "replica-1"))
+
+	// Lookups are spread over the replicas
+	seen := map[string]bool{}
+	for range 4 {
+		value, ok, err := store.Get(ctx, "key")
+		require.NoError(t, err)
+		require.True(t, ok)
+		seen[value] = true
+	}
+	assert.Equal(t, map[string]bool{"replica-0": true, "replica-1": true}, seen)
+
+	// Writes go to the primary only
+	require.NoError(t, store.Set(ctx, "new", "value", 0))
+	value, err := primary.Get("new")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.False(t, replicas[0].Exists("new"))
+	assert.False(t, replicas[1].Exists("new"))
+}
+
+func TestReplicaMissFallsBackToPrimary(t *testing.T) {
+	store, primary, _ := newTestReplicaStore(t)
+	ctx := context.Background()
+
+	// A mapping that has not reached the replicas yet is found on the primary
+	require.NoError(t, primary.Set("key", "primary"))
+	for range 2 {
+		value, ok, err := store.Get(ctx, "key")
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "primary", value)
+	}
+
+	_, ok, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestReplicaErrorFallsBackToPrimary(t *testing.T) {
+	store, primary, replicas := newTestReplicaStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, primary.Set("key", "primary"))
+	replicas[0].Close()
+	replicas[1].Close()
+
+	value, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "primary", value)
+}
+
+func TestReplicaMasking(t *testing.T) {
+	replica := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.RedisReplicas.Addrs = []string{replica.Addr()}
+	m, server := newTestMasker(t, &cfg)
+
+	// New mappings are written to the primary
+	masked := m.MaskString(context.Background(), "client 10.0.0.1 connected")
+	assert.NotContains(t, masked, "10.0.0.1")
+	assert.Contains(t, server.Keys(), MaskKey("ipv4", "10.0.0.1"))
+	assert.Empty(t, replica.Keys())
+
+	// Replicated mappings are read from the replica
+	require.NoError(t, replica.Set(MaskKey("ipv4", "10.0.0.2"), "replicated-token"))
+	masked = m.MaskString(context.Background(), "client 10.0.0.2 connected")
+	assert.Equal(t, "client replicated-token connected", masked)
+}
@@ -0,0 +1,106 @@
+package masker
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+const (
+	// syntheticHostDomain is the domain of synthetic hostname tokens
+	syntheticHostDomain = "masked.local"
+
+	// maxReservedRetries caps how often a token pointing at a reserved namespace is
+	// regenerated. Validation rejects namespaces covering every synthetic value, so
+	// the cap is only reached with vanishing probability.
+	maxReservedRetries = 32
+)
+
+var (
+	// syntheticIPv4Prefix is the network of synthetic IPv4 tokens
+	syntheticIPv4Prefix = netip.MustParsePrefix("10.0.0.0/8")
+
+	// syntheticIPv6Prefix is the network of synthetic IPv6 tokens, the
+	// documentation range of RFC 3849
+	syntheticIPv6Prefix = netip.MustParsePrefix("2001:db8::/32")
+)
+
+// ReservedNamespacesConfig defines the networks and domains of real assets that
+// synthetic tokens must never point at
+type ReservedNamespacesConfig struct {
+	// CIDRs are networks of real assets, e.g. "10.20.0.0/16"
+	CIDRs []string `mapstructure:"cidrs" json:"cidrs"`
+
+	// Domains are domains of real assets, e.g. "corp.example.com". Their subdomains
+	// are reserved as well.
+	Domains []string `mapstructure:"domains" json:"domains"`
+}
+
+// reservedNamespaces is the parsed form of ReservedNamespacesConfig
+type reservedNamespaces struct {
+	prefixes []netip.Prefix
+	domains  []string
+}
+
+// parse parses and validates the reserved namespaces. It returns nil when none are configured.
+func (cfg *ReservedNamespacesConfig) parse() (*reservedNamespaces, error) {
+	if len(cfg.CIDRs) == 0 && len(cfg.Domains) == 0 {
+		return nil, nil
+	}
+
+	reserved := &reservedNamespaces{}
+	for _, cidr := range cfg.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reserved_namespaces cidr '%s': %w", cidr, err)
+		}
+		if prefix.Bits() <= syntheticIPv4Prefix.Bits() && prefix.Contains(syntheticIPv4Prefix.Addr()) {
+			return nil, fmt.Errorf("reserved_namespaces cidr '%s' covers every synthetic IPv4 token", cidr)
+		}
+		if prefix.Bits() <= syntheticIPv6Prefix.Bits() && prefix.Contains(syntheticIPv6Prefix.Addr()) {
+			return nil, fmt.Errorf("reserved_namespaces cidr '%s' covers every synthetic IPv6 token", cidr)
+		}
+		reserved.prefixes = append(reserved.prefixes, prefix.Masked())
+	}
+
+	for _, domain := range cfg.Domains {
+		domain = strings.ToLower(strings.Trim(domain, "."))
+		if domain == "" {
+			return nil, errors.New("reserved_namespaces domains must not be empty")
+		}
+		if inDomain(syntheticHostDomain, domain) {
+			return nil, fmt.Errorf("reserved_namespaces domain '%s' covers every synthetic hostname token", domain)
+		}
+		reserved.domains = append(reserved.domains, domain)
+	}
+	return reserved, nil
+}
+
+// contains reports whether token is an IP address or hostname inside a reserved namespace
+func (r *reservedNamespaces) contains(token string) bool {
+	if r == nil {
+		return false
+	}
+
+	if addr, err := netip.ParseAddr(token); err == nil {
+		for _, prefix := range r.prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, domain := range r.domains {
+		if inDomain(strings.ToLower(token), domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDomain reports whether host is domain or one of its subdomains
+func inDomain(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
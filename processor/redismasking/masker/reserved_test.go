@@ -0,0 +1,91 @@
+package masker
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReservedNamespaces(t *testing.T) {
+	plain := &Masker{config: &Config{}, logger: zap.NewNop()}
+	ipToken := plain.generateMaskedValue("192.168.1.1", "ipv4")
+	hostToken := plain.generateMaskedValue("web-01.corp.example.com", "hostname")
+
+	// Reserve exactly the networks and hosts the plain tokens point at
+	ipNetwork := netip.MustParsePrefix(ipToken + "/24").Masked()
+	cfg := &Config{ReservedNamespaces: ReservedNamespacesConfig{
+		CIDRs:   []string{ipNetwork.String()},
+		Domains: []string{strings.ToUpper(hostToken)},
+	}}
+	reserved, err := cfg.ReservedNamespaces.parse()
+	require.NoError(t, err)
+	m := &Masker{config: cfg, logger: zap.NewNop(), reserved: reserved}
+
+	token := m.generateMaskedValue("192.168.1.1", "ipv4")
+	assert.NotEqual(t, ipToken, token)
+	assert.False(t, ipNetwork.Contains(netip.MustParseAddr(token)))
+	assert.True(t, syntheticIPv4Prefix.Contains(netip.MustParseAddr(token)))
+	assert.Equal(t, token, m.generateMaskedValue("192.168.1.1", "ipv4"))
+
+	token = m.generateMaskedValue("web-01.corp.example.com", "hostname")
+	assert.NotEqual(t, hostToken, token)
+	assert.True(t, strings.HasSuffix(token, "."+syntheticHostDomain))
+
+	// Tokens outside the reserved namespaces are unchanged
+	assert.Equal(t, plain.generateMaskedValue("alice", attributeCategory("user")), m.generateMaskedValue("alice", attributeCategory("user")))
+}
+
+func TestReservedNamespacesValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		reserved    ReservedNamespacesConfig
+		expectedErr string
+	}{
+		{
+			name:     "valid",
+			reserved: ReservedNamespacesConfig{CIDRs: []string{"10.20.0.0/16", "192.168.0.0/16"}, Domains: []string{".corp.example.com"}},
+		},
+		{
+			name:        "invalid cidr",
+			reserved:    ReservedNamespacesConfig{CIDRs: []string{"10.20.0.0"}},
+			expectedErr: `invalid reserved_namespaces cidr '10.20.0.0': netip.ParsePrefix("10.20.0.0"): no '/'`,
+		},
+		{
+			name:        "cidr covering every synthetic address",
+			reserved:    ReservedNamespacesConfig{CIDRs: []string{"0.0.0.0/0"}},
+			expectedErr: "reserved_namespaces cidr '0.0.0.0/0' covers every synthetic IPv4 token",
+		},
+		{
+			name:        "cidr covering every synthetic IPv6 address",
+			reserved:    ReservedNamespacesConfig{CIDRs: []string{"2001::/16"}},
+			expectedErr: "reserved_namespaces cidr '2001::/16' covers every synthetic IPv6 token",
+		},
+		{
+			name:        "empty domain",
+			reserved:    ReservedNamespacesConfig{Domains: []string{"."}},
+			expectedErr: "reserved_namespaces domains must not be empty",
+		},
+		{
+			name:        "domain covering every synthetic hostname",
+			reserved:    ReservedNamespacesConfig{Domains: []string{"Local"}},
+			expectedErr: "reserved_namespaces domain 'local' covers every synthetic hostname token",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.ReservedNamespaces = tc.reserved
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package masker
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// maskResource masks resource_fields_to_mask of resource in place and, when
+// scan_resource_attributes is enabled, scans its other string attributes. Fields
+// are masked with the same category as record attributes of the same key, so a
+// host.name token matches whether it was set on the resource or the record.
+func (m *Masker) maskResource(ctx context.Context, resource pcommon.Resource) {
+	if len(m.config.ResourceFieldsToMask) == 0 && !m.config.ScanResourceAttributes {
+		return
+	}
+	if m.detectOnly() {
+		return
+	}
+
+	resource.Attributes().RemoveIf(func(k string, _ pcommon.Value) bool {
+		return m.drops(m.config.ResourceFieldsToMask, k)
+	})
+	resource.Attributes().Range(func(k string, v pcommon.Value) bool {
+		if slices.Contains(m.config.ResourceFieldsToMask, k) {
+			if err := m.maskField(ctx, k, v); err != nil {
+				m.logError("Failed to mask resource attribute", err, zap.String("key", k))
+			}
+			return true
+		}
+
+		if m.config.ScanResourceAttributes && v.Type() == pcommon.ValueTypeStr && !slices.Contains(m.config.ExcludeKeys, k) {
+			originalValue := v.Str()
+			maskedValue := m.maskString(ctx, originalValue, nil)
+			if maskedValue != originalValue {
+				v.SetStr(maskedValue)
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,68 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestMaskResource(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"host.name"}
+	cfg.ResourceFieldsToMask = []string{"host.name", "k8s.pod.name"}
+	cfg.ScanResourceAttributes = true
+	cfg.ExcludeKeys = []string{"service.address"}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	resource := rl.Resource().Attributes()
+	resource.PutStr("host.name", "web-01")
+	resource.PutStr("k8s.pod.name", "checkout-7d9f")
+	resource.PutStr("host.ip", "192.168.1.1")
+	resource.PutStr("service.address", "10.0.0.1")
+	resource.PutInt("process.pid", 42)
+	lr := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("host.name", "web-01")
+
+	m.MaskLogs(context.Background(), ld)
+
+	// A field has the same token on the resource and the record
+	hostToken := m.generateMaskedValue("web-01", attributeCategory("host.name"))
+	hostName, _ := resource.Get("host.name")
+	assert.Equal(t, hostToken, hostName.Str())
+	recordHost, _ := lr.Attributes().Get("host.name")
+	assert.Equal(t, hostToken, recordHost.Str())
+
+	podName, _ := resource.Get("k8s.pod.name")
+	assert.Equal(t, m.generateMaskedValue("checkout-7d9f", attributeCategory("k8s.pod.name")), podName.Str())
+	hostIP, _ := resource.Get("host.ip")
+	assert.Equal(t, m.generateMaskedValue("192.168.1.1", "ipv4"), hostIP.Str())
+	address, _ := resource.Get("service.address")
+	assert.Equal(t, "10.0.0.1", address.Str())
+	pid, _ := resource.Get("process.pid")
+	assert.Equal(t, int64(42), pid.Int())
+}
+
+func TestMaskResourceDisabled(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"host.name"}
+	cfg.ScanAllAttributes = true
+	m, _ := newTestMasker(t, &cfg)
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("host.name", "web-01")
+	rs.Resource().Attributes().PutStr("host.ip", "192.168.1.1")
+	rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+
+	m.MaskTraces(context.Background(), td)
+
+	// Record level settings do not apply to resources
+	assert.Equal(t, map[string]any{"host.name": "web-01", "host.ip": "192.168.1.1"}, rs.Resource().Attributes().AsRaw())
+}
@@ -0,0 +1,49 @@
+package masker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryConfig retries Redis commands that fail with a transient error, e.g. a
+// dropped connection or a replica that is still loading, with exponential
+// backoff and jitter. Transient blips then neither fail the lookup nor lose the
+// reverse mapping of a new token. Zero values keep the client defaults.
+type RetryConfig struct {
+	// MaxAttempts is the number of attempts of a command, including the first.
+	// 1 disables retries.
+	MaxAttempts int `mapstructure:"max_attempts"`
+
+	// InitialInterval is the backoff before the first retry, which doubles up to
+	// MaxInterval with every further retry
+	InitialInterval time.Duration `mapstructure:"initial_interval"`
+
+	// MaxInterval is the longest backoff between retries
+	MaxInterval time.Duration `mapstructure:"max_interval"`
+}
+
+// Validate checks that no setting is negative and that the intervals are ordered
+func (cfg *RetryConfig) Validate() error {
+	if cfg.MaxAttempts < 0 || cfg.InitialInterval < 0 || cfg.MaxInterval < 0 {
+		return errors.New("redis_retry settings must be non-negative")
+	}
+	if cfg.InitialInterval > 0 && cfg.MaxInterval > 0 && cfg.InitialInterval > cfg.MaxInterval {
+		return errors.New("redis_retry initial_interval must not exceed max_interval")
+	}
+	return nil
+}
+
+// apply sets the configured retry policy on options
+func (cfg *RetryConfig) apply(options *redis.Options) {
+	switch {
+	case cfg.MaxAttempts == 1:
+		// The client takes 0 for its default and -1 for no retries
+		options.MaxRetries = -1
+	case cfg.MaxAttempts > 1:
+		options.MaxRetries = cfg.MaxAttempts - 1
+	}
+	options.MinRetryBackoff = cfg.InitialInterval
+	options.MaxRetryBackoff = cfg.MaxInterval
+}
@@ -0,0 +1,60 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisRetry(t *testing.T) {
+	cfg := NewDefaultConfig()
+
+	// Zero values keep the client defaults
+	options, err := cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Zero(t, options.MaxRetries)
+	assert.Zero(t, options.MinRetryBackoff)
+
+	cfg.RedisRetry = RetryConfig{MaxAttempts: 5, InitialInterval: 10 * time.Millisecond, MaxInterval: time.Second}
+	options, err = cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Equal(t, 4, options.MaxRetries)
+	assert.Equal(t, 10*time.Millisecond, options.MinRetryBackoff)
+	assert.Equal(t, time.Second, options.MaxRetryBackoff)
+
+	cfg.RedisRetry = RetryConfig{MaxAttempts: 1}
+	options, err = cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Equal(t, -1, options.MaxRetries)
+}
+
+func TestRedisRetryTransientError(t *testing.T) {
+	server := miniredis.RunT(t)
+	require.NoError(t, server.Set("key", "value"))
+	newStore := func(retry RetryConfig) Store {
+		cfg := NewDefaultConfig()
+		cfg.RedisAddr = server.Addr()
+		cfg.RedisRetry = retry
+		store, err := NewRedisStore(context.Background(), &cfg)
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+	noRetries := newStore(RetryConfig{MaxAttempts: 1})
+	retries := newStore(RetryConfig{MaxAttempts: 20, InitialInterval: 20 * time.Millisecond, MaxInterval: 50 * time.Millisecond})
+
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	_, _, err := noRetries.Get(context.Background(), "key")
+	require.ErrorContains(t, err, "LOADING")
+
+	// The error clears while the command is retried
+	time.AfterFunc(100*time.Millisecond, func() { server.SetError("") })
+	value, found, err := retries.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+}
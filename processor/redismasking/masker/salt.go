@@ -0,0 +1,79 @@
+package masker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// saltCheckKey is the store key holding the check value of the salt the stored
+// mappings were created with. It is outside the mask prefix, so scans of the
+// mappings skip it.
+const saltCheckKey = "meta:salt_check"
+
+// saltCheck returns a value identifying salt without revealing it. No salt is
+// recorded as well, so adding a salt later counts as a change.
+func saltCheck(salt string) string {
+	return checkValue("salt", salt)
+}
+
+// checkValue returns a value identifying the secret of kind without revealing
+// it, or "none" without a secret
+func checkValue(kind, secret string) string {
+	if secret == "" {
+		return "none"
+	}
+	sum := sha256.Sum256([]byte("redismasking " + kind + "\x00" + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// salted prefixes data with the configured salt, so deployments with different
+// salts derive different tokens for the same value. Without a salt, data is
+// returned unchanged and tokens stay as before.
+func (m *Masker) salted(data string) string {
+	if m.config.Salt == "" {
+		return data
+	}
+	return m.config.Salt + "\x00" + data
+}
+
+// CheckSalt compares the salt with the one recorded in the store and records it
+// when the store has none. A changed salt gives new values tokens that never
+// match the tokens of other deployments sharing the store, so it fails unless
+// allow_salt_change is set, in which case the new salt is recorded. Read-only
+// mode only compares the salt.
+func (m *Masker) CheckSalt(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+
+	check := saltCheck(m.config.Salt)
+	recorded, found, err := m.store.Get(ctx, saltCheckKey)
+	if err != nil {
+		return fmt.Errorf("failed to read salt check: %w", err)
+	}
+	if found && recorded == check {
+		return nil
+	}
+	if m.config.isReadOnly() {
+		// The store is never written in read-only mode, so the salt is recorded
+		// by the writer of the mappings
+		if found {
+			return errors.New("salt differs from the salt of the stored mappings")
+		}
+		return nil
+	}
+	if found && !m.config.AllowSaltChange {
+		return errors.New("salt differs from the salt of the stored mappings, set allow_salt_change to change it")
+	}
+	if found {
+		m.logger.Warn("Salt changed, new values get tokens of the new salt")
+	}
+
+	if err := m.store.Set(ctx, saltCheckKey, check, 0); err != nil {
+		return fmt.Errorf("failed to record salt check: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package masker
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSalt(t *testing.T) {
+	unsalted := &Masker{config: &Config{}, logger: zap.NewNop()}
+	customerA := &Masker{config: &Config{Salt: "customer-a"}, logger: zap.NewNop()}
+	customerB := &Masker{config: &Config{Salt: "customer-b"}, logger: zap.NewNop()}
+
+	token := customerA.generateMaskedValue("jdoe", "attribute_username")
+	assert.Equal(t, token, customerA.generateMaskedValue("jdoe", "attribute_username"))
+	assert.NotEqual(t, token, customerB.generateMaskedValue("jdoe", "attribute_username"))
+	assert.NotEqual(t, token, unsalted.generateMaskedValue("jdoe", "attribute_username"))
+
+	// The salt changes the fingerprint without revealing it
+	cfg := NewDefaultConfig()
+	before, err := cfg.Fingerprint()
+	require.NoError(t, err)
+	cfg.Salt = "customer-a"
+	policy, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+	assert.NotEqual(t, before, policy.Fingerprint)
+	assert.NotContains(t, policy.SaltCheck, "customer-a")
+}
+
+func TestCheckSalt(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewDefaultConfig()
+	m, server := newTestMasker(t, &cfg)
+
+	// The first start records the salt, and later starts with the same salt pass
+	require.NoError(t, m.CheckSalt(ctx))
+	require.NoError(t, m.CheckSalt(ctx))
+	recorded, err := server.Get(saltCheckKey)
+	require.NoError(t, err)
+	assert.Equal(t, "none", recorded)
+
+	// Adding a salt is a change too
+	cfg.Salt = "customer-a"
+	assert.EqualError(t, m.CheckSalt(ctx), "salt differs from the salt of the stored mappings, set allow_salt_change to change it")
+
+	cfg.AllowSaltChange = true
+	require.NoError(t, m.CheckSalt(ctx))
+	recorded, err = server.Get(saltCheckKey)
+	require.NoError(t, err)
+	assert.Equal(t, saltCheck("customer-a"), recorded)
+
+	cfg.AllowSaltChange = false
+	require.NoError(t, m.CheckSalt(ctx))
+
+	// Without a store there is nothing to compare
+	require.NoError(t, (&Masker{config: &cfg}).CheckSalt(ctx))
+}
+
+func TestCheckSaltReadOnly(t *testing.T) {
+	ctx := context.Background()
+	cfg := NewDefaultConfig()
+	cfg.Mode = modeReadOnly
+	m, server := newTestMasker(t, &cfg)
+
+	// The salt is only compared, so the store is never written
+	require.NoError(t, m.CheckSalt(ctx))
+	assert.Empty(t, server.Keys())
+
+	require.NoError(t, server.Set(saltCheckKey, saltCheck("customer-a")))
+	assert.EqualError(t, m.CheckSalt(ctx), "salt differs from the salt of the stored mappings")
+}
+
+func TestCheckSaltSQLStore(t *testing.T) {
+	ctx := context.Background()
+	store, mock := newTestSQLStore(t, sqlDriverPostgres)
+	cfg := NewDefaultConfig()
+	m, err := New(&cfg, store, zap.NewNop())
+	require.NoError(t, err)
+
+	// The salt check is a row of the meta category
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT token FROM redismasking_mappings WHERE category = $1")).
+		WithArgs(sqlMetaCategory, sqlHash("salt_check"), int64(1_700_000_000)).
+		WillReturnRows(sqlmock.NewRows([]string{"token"}))
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (category, original_hash) DO UPDATE SET")).
+		WithArgs([]driver.Value{sqlMetaCategory, sqlHash("salt_check"), "salt_check", sqlHash("none"), "none", nil}...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, m.CheckSalt(ctx))
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT token FROM redismasking_mappings WHERE category = $1")).
+		WithArgs(sqlMetaCategory, sqlHash("salt_check"), int64(1_700_000_000)).
+		WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow("none"))
+	require.NoError(t, m.CheckSalt(ctx))
+}
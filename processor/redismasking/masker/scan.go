@@ -0,0 +1,99 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MappingScanner is implemented by stores that can enumerate their mappings
+type MappingScanner interface {
+	// ScanMappings calls fn with every live mapping and its remaining TTL, until
+	// fn returns an error. Mappings created or deleted during the scan may be
+	// missed.
+	ScanMappings(ctx context.Context, fn func(Mapping) error) error
+}
+
+var _ MappingScanner = (*redisStore)(nil)
+
+// scanBatchSize is the number of keys requested per SCAN call
+const scanBatchSize = 1000
+
+// ScanMappings scans the mask keys batch by batch and reads their tokens and
+// TTLs in a pipeline, so a scan of a large keyspace never blocks Redis.
+func (s *redisStore) ScanMappings(ctx context.Context, fn func(Mapping) error) error {
+	iter := s.client.Scan(ctx, 0, "mask:*", scanBatchSize).Iterator()
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		mappings, err := s.readMappings(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, mapping := range mappings {
+			if err := fn(mapping); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == scanBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("redis scan error: %w", err)
+	}
+	return flush()
+}
+
+// readMappings returns the mappings of the mask keys that still exist
+func (s *redisStore) readMappings(ctx context.Context, keys []string) ([]Mapping, error) {
+	tokens := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			tokens[i] = pipe.Get(ctx, key)
+			ttls[i] = pipe.PTTL(ctx, key)
+		}
+		return nil
+	})
+	// Keys that expired since they were scanned fail with redis.Nil
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("redis get error: %w", err)
+	}
+
+	mappings := make([]Mapping, 0, len(keys))
+	for i, key := range keys {
+		token, err := tokens[i].Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redis get error: %w", err)
+		}
+		category, original, ok := strings.Cut(strings.TrimPrefix(key, "mask:"), ":")
+		if !ok {
+			continue
+		}
+
+		// PTTL returns -1 for keys without a TTL and -2 for keys that just expired
+		ttl := ttls[i].Val()
+		if ttl == -2 {
+			continue
+		}
+		mappings = append(mappings, Mapping{Category: category, Token: token, Original: original, TTL: max(ttl, 0)})
+	}
+	return mappings, nil
+}
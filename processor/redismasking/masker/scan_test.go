@@ -0,0 +1,39 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanMappings(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	ctx := context.Background()
+
+	store, err := NewRedisStore(ctx, &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	require.NoError(t, store.Set(ctx, MaskKey("ipv6", "2001:db8::1"), "fd00::1", time.Hour))
+	require.NoError(t, store.Set(ctx, UnmaskKey("ipv6", "fd00::1"), "2001:db8::1", time.Hour))
+	require.NoError(t, store.Set(ctx, MaskKey("vendor_x/hostname", "web-01"), "host-1.masked.local", 0))
+	tracker := store.(AccessTracker)
+	require.NoError(t, tracker.RecordAccess(ctx, map[string]int64{UnmaskKey("hostname", "host-1.masked.local"): 1}, time.Now()))
+
+	var mappings []Mapping
+	err = store.(MappingScanner).ScanMappings(ctx, func(mapping Mapping) error {
+		mappings = append(mappings, mapping)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Mapping{
+		{Category: "ipv6", Token: "fd00::1", Original: "2001:db8::1", TTL: time.Hour},
+		{Category: "vendor_x/hostname", Token: "host-1.masked.local", Original: "web-01"},
+	}, mappings)
+}
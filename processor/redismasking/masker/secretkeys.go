@@ -0,0 +1,77 @@
+package masker
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// secretCategory is the category of values masked by the secret key heuristic
+const secretCategory = "secret"
+
+var (
+	// jsonMemberRegex matches JSON members with a string or number value.
+	// The groups are the key and the value including its quotes.
+	jsonMemberRegex = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"(\s*:\s*)("(?:[^"\\]|\\.)*"|-?\d[\d.eE+-]*)`)
+
+	// formPairRegex matches form-encoded and query string pairs. The groups are the
+	// separator, the key, and the value.
+	formPairRegex = regexp.MustCompile(`(^|[&?;\s])([^&=?;\s"]+)=([^&\s"]*)`)
+)
+
+// maskSecretKeys masks the values of JSON members and form-encoded pairs whose key
+// matches the secret key regex, e.g. {"password":"hunter2"} or user=bob&pwd=hunter2.
+// Passwords have no recognizable shape, so the key is the only signal. The text is
+// edited in place rather than re-encoded, so its formatting is kept.
+func (m *Masker) maskSecretKeys(ctx context.Context, text string, onMask func(category, token string)) string {
+	if m.secretKeyRegex == nil {
+		return text
+	}
+
+	text = jsonMemberRegex.ReplaceAllStringFunc(text, func(member string) string {
+		groups := jsonMemberRegex.FindStringSubmatch(member)
+		key, separator, value := groups[1], groups[2], groups[3]
+		if !m.secretKeyRegex.MatchString(key) {
+			return member
+		}
+
+		token, ok := m.secretToken(ctx, strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`), onMask)
+		if !ok {
+			return member
+		}
+		return `"` + key + `"` + separator + `"` + token + `"`
+	})
+
+	return formPairRegex.ReplaceAllStringFunc(text, func(pair string) string {
+		groups := formPairRegex.FindStringSubmatch(pair)
+		separator, key, value := groups[1], groups[2], groups[3]
+		if value == "" || !m.secretKeyRegex.MatchString(key) {
+			return pair
+		}
+
+		// Form values are percent-encoded, so they are decoded to map the same value consistently
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+
+		token, ok := m.secretToken(ctx, value, onMask)
+		if !ok {
+			return pair
+		}
+		return separator + key + "=" + token
+	})
+}
+
+// secretToken returns the token of a value found by the secret key heuristic
+func (m *Masker) secretToken(ctx context.Context, value string, onMask func(category, token string)) (string, bool) {
+	token, err := m.MaskValue(ctx, value, secretCategory)
+	if err != nil {
+		m.logError("Failed to mask secret value", err)
+		return "", false
+	}
+	if onMask != nil {
+		onMask(secretCategory, token)
+	}
+	return token, true
+}
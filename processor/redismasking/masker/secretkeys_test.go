@@ -0,0 +1,64 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretKeys(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.SecretKeys.Enabled = true
+	m, _ := newTestMasker(t, &cfg)
+	token := func(value string) string {
+		return m.generateMaskedValue(value, secretCategory)
+	}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "json",
+			input:    `{"user":"bob","Password" : "hunter2","pin":1234,"client_secret":"s3cr3t"}`,
+			expected: `{"user":"bob","Password" : "` + token("hunter2") + `","pin":1234,"client_secret":"` + token("s3cr3t") + `"}`,
+		},
+		{
+			name:     "json number",
+			input:    `{"pwd":1234}`,
+			expected: `{"pwd":"` + token("1234") + `"}`,
+		},
+		{
+			name:     "form",
+			input:    "user=bob&password=hunter%3F2&remember=1",
+			expected: "user=bob&password=" + token("hunter?2") + "&remember=1",
+		},
+		{
+			name:     "query string in message",
+			input:    "POST /login?pwd=hunter2 from 192.168.1.1",
+			expected: "POST /login?pwd=" + token("hunter2") + " from " + m.generateMaskedValue("192.168.1.1", "ipv4"),
+		},
+		{
+			name:     "no secret keys",
+			input:    `{"user":"bob"} theme=dark`,
+			expected: `{"user":"bob"} theme=dark`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, m.MaskString(context.Background(), tc.input))
+		})
+	}
+}
+
+func TestSecretKeysDisabled(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	m, _ := newTestMasker(t, &cfg)
+
+	assert.Equal(t, `{"password":"hunter2"}`, m.MaskString(context.Background(), `{"password":"hunter2"}`))
+}
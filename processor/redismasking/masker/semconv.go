@@ -0,0 +1,44 @@
+package masker
+
+import "slices"
+
+// semconvSensitiveAttributes are OpenTelemetry semantic convention attributes
+// that identify a person or the network location of a client. Deprecated names
+// are kept because older instrumentation still emits them.
+var semconvSensitiveAttributes = []string{
+	"client.address",
+	"db.user",
+	"enduser.id",
+	"http.client_ip",
+	"net.peer.ip",
+	"net.sock.peer.addr",
+	"network.peer.address",
+	"source.address",
+	"user.email",
+	"user.full_name",
+	"user.hash",
+	"user.id",
+	"user.name",
+}
+
+// SemconvFields returns the semantic convention attributes that are masked in
+// addition to fields_to_mask when semconv_fields is enabled
+func (cfg *Config) SemconvFields() []string {
+	if !cfg.SemconvFieldsEnabled {
+		return nil
+	}
+
+	fields := make([]string, 0, len(semconvSensitiveAttributes))
+	for _, attr := range semconvSensitiveAttributes {
+		if !slices.Contains(cfg.FieldsToMask, attr) {
+			fields = append(fields, attr)
+		}
+	}
+	return fields
+}
+
+// effectiveFieldsToMask returns the configured fields followed by the
+// semantic convention fields covered automatically
+func (cfg *Config) effectiveFieldsToMask() []string {
+	return append(slices.Clone(cfg.FieldsToMask), cfg.SemconvFields()...)
+}
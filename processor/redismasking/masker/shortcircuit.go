@@ -0,0 +1,62 @@
+package masker
+
+import (
+	"errors"
+	"regexp/syntax"
+	"strings"
+)
+
+// ShortCircuitConfig stops scanning a value once enough patterns were ruled out
+// by the literal prefilter while nothing in it was masked. Most bodies match
+// nothing, so this skips the regular expressions of the remaining patterns at
+// the cost of missing their matches in such values.
+type ShortCircuitConfig struct {
+	// AbsentCategories is how many patterns must be ruled out before the
+	// remaining ones are skipped (0 = disabled)
+	AbsentCategories int `mapstructure:"absent_categories" json:"absent_categories"`
+}
+
+// Enabled reports whether scans are stopped early
+func (cfg *ShortCircuitConfig) Enabled() bool {
+	return cfg.AbsentCategories > 0
+}
+
+// Validate checks the number of categories
+func (cfg *ShortCircuitConfig) Validate() error {
+	if cfg.AbsentCategories < 0 {
+		return errors.New("short_circuit absent_categories must be non-negative")
+	}
+	return nil
+}
+
+// absent reports whether the literal prefilter rules out any match of the
+// pattern in text, because text lacks the prefix every match starts with
+func (p *compiledPattern) absent(text string) bool {
+	return p.literal != "" && !strings.Contains(text, p.literal)
+}
+
+// literalPrefix returns the case-sensitive literal every match of expr starts
+// with after its leading word boundaries and anchors, or "" when there is none
+func literalPrefix(expr string) string {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return ""
+	}
+	re = re.Simplify()
+
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	var prefix strings.Builder
+	for _, sub := range subs {
+		switch {
+		case prefix.Len() == 0 && (sub.Op == syntax.OpWordBoundary || sub.Op == syntax.OpBeginLine || sub.Op == syntax.OpBeginText):
+		case sub.Op == syntax.OpLiteral && sub.Flags&syntax.FoldCase == 0:
+			prefix.WriteString(string(sub.Rune))
+		default:
+			return prefix.String()
+		}
+	}
+	return prefix.String()
+}
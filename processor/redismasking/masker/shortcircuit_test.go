@@ -0,0 +1,72 @@
+package masker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiteralPrefix(t *testing.T) {
+	testCases := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: `\bsk_live_[0-9a-zA-Z]{24,99}\b`, expected: "sk_live_"},
+		{expr: `^AKIA[0-9A-Z]{16}`, expected: "AKIA"},
+		{expr: `\b1Z[0-9A-Z]{16}\b`, expected: "1Z"},
+		{expr: `\b9[2-5][0-9]{20}\b`, expected: "9"},
+		{expr: `\b(?:\d{1,3}\.){3}\d{1,3}\b`, expected: ""},
+		{expr: `(?i)token=\w+`, expected: ""},
+		{expr: `ab|ac`, expected: "a"},
+		{expr: `(`, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			assert.Equal(t, tc.expected, literalPrefix(tc.expr))
+		})
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{"api_keys"}
+	cfg.Patterns = ipv4Patterns()
+	cfg.ShortCircuit.AbsentCategories = 3
+	m, _ := newTestMasker(t, &cfg)
+	ctx := context.Background()
+
+	// The api keys are ruled out, so the ip address is not scanned for
+	text := "connection from 192.168.1.1"
+	assert.Equal(t, text, m.MaskString(ctx, text))
+
+	// Once something was masked the remaining patterns are scanned
+	key := "sk_live_" + strings.Repeat("a", 24)
+	masked := m.MaskString(ctx, key+" from 192.168.1.1")
+	assert.NotContains(t, masked, key)
+	assert.NotContains(t, masked, "192.168.1.1")
+}
+
+func TestLiteralPrefilter(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PatternPacks = []string{"api_keys"}
+	cfg.Patterns = ipv4Patterns()
+	m, _ := newTestMasker(t, &cfg)
+
+	// Without a short circuit every pattern is scanned
+	masked := m.MaskString(context.Background(), "connection from 192.168.1.1")
+	assert.NotContains(t, masked, "192.168.1.1")
+
+	// Patterns are ruled out without running their regular expression
+	for _, pattern := range m.compiledPatterns {
+		if pattern.name == "ipv4" {
+			require.Empty(t, pattern.literal)
+			continue
+		}
+		require.NotEmpty(t, pattern.literal, pattern.name)
+		require.True(t, pattern.absent("connection from 192.168.1.1"), pattern.name)
+	}
+}
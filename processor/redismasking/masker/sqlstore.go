@@ -0,0 +1,443 @@
+package masker
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	// Registers the mysql driver
+	_ "github.com/go-sql-driver/mysql"
+	// Registers the postgres driver
+	_ "github.com/lib/pq"
+)
+
+// Supported SQL drivers
+const (
+	sqlDriverPostgres = "postgres"
+	sqlDriverMySQL    = "mysql"
+)
+
+// sqlTableName matches the table names that are safe to put in a statement unquoted
+var sqlTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,47}$`)
+
+// SQLStoreConfig locates the database of the sql store
+type SQLStoreConfig struct {
+	// Driver is "postgres" or "mysql"
+	Driver string `mapstructure:"driver"`
+
+	// DSN is the data source name of the database, in the format of the driver
+	DSN string `mapstructure:"dsn"`
+
+	// Table is the name of the mapping table
+	Table string `mapstructure:"table"`
+
+	// Migrate creates or upgrades the schema at startup. Without it, the
+	// schema must already be at the version of the collector.
+	Migrate bool `mapstructure:"migrate"`
+
+	// MaxOpenConns bounds the connections to the database
+	MaxOpenConns int `mapstructure:"max_open_conns"`
+
+	// Timeout bounds every statement
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks the driver, the table name, and the bounds
+func (cfg *SQLStoreConfig) Validate() error {
+	switch cfg.Driver {
+	case sqlDriverPostgres, sqlDriverMySQL:
+	case "":
+		return errors.New("sql_store driver is required")
+	default:
+		return fmt.Errorf("unsupported sql_store driver '%s'", cfg.Driver)
+	}
+	if cfg.DSN == "" {
+		return errors.New("sql_store dsn is required")
+	}
+	if !sqlTableName.MatchString(cfg.Table) {
+		return fmt.Errorf("sql_store table '%s' must be a plain identifier of at most 48 characters", cfg.Table)
+	}
+	if cfg.MaxOpenConns <= 0 {
+		return errors.New("sql_store max_open_conns must be positive")
+	}
+	if cfg.Timeout <= 0 {
+		return errors.New("sql_store timeout must be positive")
+	}
+	return nil
+}
+
+// sqlDialect holds the statements that differ between the drivers
+type sqlDialect struct {
+	// placeholder returns the placeholder of the nth argument, counted from 1
+	placeholder func(n int) string
+
+	// lock and unlock serialize migrations across collectors
+	lock   string
+	unlock string
+
+	// migrations are the statements of every schema version, in order. %[1]s is
+	// the table name.
+	migrations [][]string
+
+	// upsert writes a mapping, %[2]s is the condition under which an existing
+	// mapping of the value is replaced
+	upsert string
+}
+
+// sqlDialects are the dialects of the supported drivers. A mapping is a row
+// keyed by the category and the hash of the original value, with an index on
+// the hash of the token for reverse lookups.
+var sqlDialects = map[string]*sqlDialect{
+	sqlDriverPostgres: {
+		placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+		lock:        "SELECT pg_advisory_lock(hashtext($1))",
+		unlock:      "SELECT pg_advisory_unlock(hashtext($1))",
+		migrations: [][]string{
+			{
+				`CREATE TABLE IF NOT EXISTS %[1]s (
+					category VARCHAR(255) NOT NULL,
+					original_hash CHAR(64) NOT NULL,
+					original TEXT NOT NULL,
+					token_hash CHAR(64) NOT NULL,
+					token TEXT NOT NULL,
+					expires_at BIGINT,
+					PRIMARY KEY (category, original_hash)
+				)`,
+				`CREATE INDEX IF NOT EXISTS %[1]s_token ON %[1]s (category, token_hash)`,
+			},
+		},
+		upsert: `INSERT INTO %[1]s (category, original_hash, original, token_hash, token, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (category, original_hash) DO UPDATE SET
+				original = EXCLUDED.original,
+				token_hash = EXCLUDED.token_hash,
+				token = EXCLUDED.token,
+				expires_at = EXCLUDED.expires_at
+			WHERE %[2]s`,
+	},
+	sqlDriverMySQL: {
+		placeholder: func(int) string { return "?" },
+		lock:        "SELECT GET_LOCK(?, 60)",
+		unlock:      "SELECT RELEASE_LOCK(?)",
+		migrations: [][]string{
+			{
+				`CREATE TABLE IF NOT EXISTS %[1]s (
+					category VARCHAR(255) NOT NULL,
+					original_hash CHAR(64) NOT NULL,
+					original TEXT NOT NULL,
+					token_hash CHAR(64) NOT NULL,
+					token TEXT NOT NULL,
+					expires_at BIGINT NULL,
+					PRIMARY KEY (category, original_hash),
+					INDEX %[1]s_token (category, token_hash)
+				) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin`,
+			},
+		},
+		// The columns are assigned in order, so the condition reads the
+		// expiry of the existing row until expires_at is assigned last
+		upsert: `INSERT INTO %[1]s (category, original_hash, original, token_hash, token, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+				original = IF(%[2]s, VALUES(original), original),
+				token_hash = IF(%[2]s, VALUES(token_hash), token_hash),
+				token = IF(%[2]s, VALUES(token), token),
+				expires_at = IF(%[2]s, VALUES(expires_at), expires_at)`,
+	},
+}
+
+// sqlMigrationTimeout bounds connecting to the database and migrating its schema
+const sqlMigrationTimeout = time.Minute
+
+// sqlStore is a Store that keeps mappings in a PostgreSQL or MySQL table
+type sqlStore struct {
+	db      *sql.DB
+	dialect *sqlDialect
+	table   string
+	timeout time.Duration
+	now     func() time.Time
+
+	getToken    string
+	getOriginal string
+	set         string
+	create      string
+	winner      string
+
+	// createNowArgs is how often the current time is passed to create
+	createNowArgs int
+}
+
+var (
+	_ Store          = (*sqlStore)(nil)
+	_ MappingCreator = (*sqlStore)(nil)
+)
+
+// NewSQLStore connects to the database of cfg, and migrates or checks its schema
+func NewSQLStore(cfg *SQLStoreConfig) (Store, error) {
+	db, err := sql.Open(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", cfg.Driver, err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxOpenConns)
+	store := newSQLStore(db, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sqlMigrationTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to connect to the %s database: %w", cfg.Driver, err)
+	}
+	if cfg.Migrate {
+		err = store.migrate(ctx)
+	} else {
+		err = store.checkSchema(ctx)
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// newSQLStore builds the statements of the dialect of cfg on db
+func newSQLStore(db *sql.DB, cfg *SQLStoreConfig) *sqlStore {
+	d := sqlDialects[cfg.Driver]
+	live := fmt.Sprintf("(expires_at IS NULL OR expires_at > %s)", d.placeholder(3))
+	// Postgres refers to the existing row by the table name, MySQL by the
+	// columns, and repeats the condition for every column
+	expired := fmt.Sprintf("%[1]s.expires_at IS NOT NULL AND %[1]s.expires_at <= %[2]s", cfg.Table, d.placeholder(7))
+	createNowArgs := 1
+	if cfg.Driver == sqlDriverMySQL {
+		expired = "expires_at IS NOT NULL AND expires_at <= ?"
+		createNowArgs = 4
+	}
+
+	return &sqlStore{
+		db:      db,
+		dialect: d,
+		table:   cfg.Table,
+		timeout: cfg.Timeout,
+		now:     time.Now,
+		getToken: fmt.Sprintf("SELECT token FROM %s WHERE category = %s AND original_hash = %s AND %s",
+			cfg.Table, d.placeholder(1), d.placeholder(2), live),
+		getOriginal: fmt.Sprintf("SELECT original FROM %s WHERE category = %s AND token_hash = %s AND %s LIMIT 1",
+			cfg.Table, d.placeholder(1), d.placeholder(2), live),
+		set:    fmt.Sprintf(d.upsert, cfg.Table, "TRUE"),
+		create: fmt.Sprintf(d.upsert, cfg.Table, expired),
+		winner: fmt.Sprintf("SELECT token FROM %s WHERE category = %s AND original_hash = %s",
+			cfg.Table, d.placeholder(1), d.placeholder(2)),
+		createNowArgs: createNowArgs,
+	}
+}
+
+// migrate applies the migrations the schema is missing under a lock, so
+// collectors starting at once migrate it only once
+func (s *sqlStore) migrate(ctx context.Context) error {
+	// Locks are held by a session, so every statement runs on one connection
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	defer conn.Close()
+
+	var ignored any
+	lockName := s.table + "_schema"
+	if err := conn.QueryRowContext(ctx, s.dialect.lock, lockName).Scan(&ignored); err != nil {
+		return fmt.Errorf("failed to lock the schema of table '%s': %w", s.table, err)
+	}
+	defer func() {
+		_ = conn.QueryRowContext(context.WithoutCancel(ctx), s.dialect.unlock, lockName).Scan(&ignored)
+	}()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_schema (version INT NOT NULL)", s.table)); err != nil {
+		return fmt.Errorf("failed to create the schema version table: %w", err)
+	}
+	version, err := s.schemaVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	for ; version < len(s.dialect.migrations); version++ {
+		for _, statement := range s.dialect.migrations[version] {
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf(statement, s.table)); err != nil {
+				return fmt.Errorf("failed to migrate table '%s' to version %d: %w", s.table, version+1, err)
+			}
+		}
+		insert := fmt.Sprintf("INSERT INTO %s_schema (version) VALUES (%s)", s.table, s.dialect.placeholder(1))
+		if _, err := conn.ExecContext(ctx, insert, version+1); err != nil {
+			return fmt.Errorf("failed to record schema version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+// checkSchema checks that the schema was migrated to the current version
+func (s *sqlStore) checkSchema(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the database: %w", err)
+	}
+	defer conn.Close()
+
+	version, err := s.schemaVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if version != len(s.dialect.migrations) {
+		return fmt.Errorf("table '%s' is at schema version %d instead of %d, enable sql_store migrate to migrate it", s.table, version, len(s.dialect.migrations))
+	}
+	return nil
+}
+
+// schemaVersion returns the latest migration applied to the schema
+func (s *sqlStore) schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	query := fmt.Sprintf("SELECT COALESCE(MAX(version), 0) FROM %s_schema", s.table)
+	if err := conn.QueryRowContext(ctx, query).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read the schema version of table '%s': %w", s.table, err)
+	}
+	return version, nil
+}
+
+// Get returns the token under a mask key, or the original value under an
+// unmask key, unless the mapping expired
+func (s *sqlStore) Get(ctx context.Context, key string) (string, bool, error) {
+	unmask, category, value, err := parseSQLKey(key)
+	if err != nil {
+		return "", false, err
+	}
+	query := s.getToken
+	if unmask {
+		query = s.getOriginal
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var result string
+	err = s.db.QueryRowContext(ctx, query, category, sqlHash(value), s.now().Unix()).Scan(&result)
+	switch {
+	case err == nil:
+		return result, true, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("sql get error: %w", err)
+	}
+}
+
+// Set stores the mapping of a mask or unmask key. Both keys of a mapping
+// write the same row.
+func (s *sqlStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	unmask, category, original, err := parseSQLKey(key)
+	if err != nil {
+		return err
+	}
+	token := value
+	if unmask {
+		original, token = value, original
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, s.set, s.mappingArgs(category, original, token, ttl)...); err != nil {
+		return fmt.Errorf("sql set error: %w", err)
+	}
+	return nil
+}
+
+// CreateMapping inserts the mapping unless a live mapping of the value exists,
+// in a single upsert, so the first collector to map a value wins. Tokens are
+// not unique in the table, so a token held by another value is checked before
+// the upsert, and collectors inserting colliding tokens at once can both win.
+func (s *sqlStore) CreateMapping(ctx context.Context, maskKey, _, original, token string, ttl time.Duration) (string, bool, error) {
+	_, category, _, err := parseSQLKey(maskKey)
+	if err != nil {
+		return "", false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var holder string
+	err = s.db.QueryRowContext(ctx, s.getOriginal, category, sqlHash(token), s.now().Unix()).Scan(&holder)
+	switch {
+	case err == nil && holder != original:
+		return "", false, errTokenTaken
+	case err != nil && !errors.Is(err, sql.ErrNoRows):
+		return "", false, fmt.Errorf("sql create mapping error: %w", err)
+	}
+
+	args := s.mappingArgs(category, original, token, ttl)
+	for i := 0; i < s.createNowArgs; i++ {
+		args = append(args, s.now().Unix())
+	}
+	result, err := s.db.ExecContext(ctx, s.create, args...)
+	if err != nil {
+		return "", false, fmt.Errorf("sql create mapping error: %w", err)
+	}
+	// Inserted and replaced rows are affected, kept rows are not
+	if affected, err := result.RowsAffected(); err == nil && affected > 0 {
+		return token, true, nil
+	}
+
+	var winner string
+	if err := s.db.QueryRowContext(ctx, s.winner, category, sqlHash(original)).Scan(&winner); err != nil {
+		return "", false, fmt.Errorf("sql create mapping error: %w", err)
+	}
+	return winner, false, nil
+}
+
+// Close closes the connections to the database
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// sqlMetaCategory is the category of the rows holding meta keys, e.g. the salt
+// check. Categories of mapping keys end at the first colon, so no mapping key
+// refers to it.
+const sqlMetaCategory = "meta:"
+
+// parseSQLKey splits a key created by MaskKey or UnmaskKey into its direction,
+// category and value. Meta keys are stored as mappings of the name of the key
+// to its value in sqlMetaCategory.
+func parseSQLKey(key string) (bool, string, string, error) {
+	if name, ok := strings.CutPrefix(key, "meta:"); ok && name != "" {
+		return false, sqlMetaCategory, name, nil
+	}
+	rest, unmask := strings.CutPrefix(key, "unmask:")
+	if !unmask {
+		var ok bool
+		if rest, ok = strings.CutPrefix(key, "mask:"); !ok {
+			return false, "", "", fmt.Errorf("sql store key '%s' is not a mapping key", key)
+		}
+	}
+	category, value, ok := strings.Cut(rest, ":")
+	if !ok {
+		return false, "", "", fmt.Errorf("sql store key '%s' is not a mapping key", key)
+	}
+	return unmask, category, value, nil
+}
+
+// mappingArgs returns the arguments of the upsert of a mapping
+func (s *sqlStore) mappingArgs(category, original, token string, ttl time.Duration) []any {
+	var expiresAt sql.NullInt64
+	if ttl > 0 {
+		expiresAt = sql.NullInt64{Int64: s.now().Add(ttl + time.Second - 1).Unix(), Valid: true}
+	}
+	return []any{category, sqlHash(original), original, sqlHash(token), token, expiresAt}
+}
+
+// sqlHash returns the hex SHA-256 hash of value, which keys and indexes values
+// of any length
+func sqlHash(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
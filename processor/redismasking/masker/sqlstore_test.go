@@ -0,0 +1,161 @@
+package masker
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSQLStore returns a store of driver on a mock database and a fixed clock
+func newTestSQLStore(t *testing.T, driver string) (*sqlStore, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+		_ = db.Close()
+	})
+
+	cfg := NewDefaultConfig().SQLStore
+	cfg.Driver = driver
+	store := newSQLStore(db, &cfg)
+	store.now = func() time.Time { return time.Unix(1_700_000_000, 0) }
+	return store, mock
+}
+
+func TestSQLStoreGet(t *testing.T) {
+	store, mock := newTestSQLStore(t, sqlDriverPostgres)
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT token FROM redismasking_mappings WHERE category = $1 AND original_hash = $2 AND (expires_at IS NULL OR expires_at > $3)")).
+		WithArgs("ipv4", sqlHash("192.168.1.1"), int64(1_700_000_000)).
+		WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow("10.1.2.3"))
+	value, found, err := store.Get(ctx, MaskKey("ipv4", "192.168.1.1"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "10.1.2.3", value)
+
+	// Reverse lookups use the token index
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT original FROM redismasking_mappings WHERE category = $1 AND token_hash = $2")).
+		WithArgs("ipv4", sqlHash("10.1.2.3"), int64(1_700_000_000)).
+		WillReturnRows(sqlmock.NewRows([]string{"original"}))
+	_, found, err = store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	_, _, err = store.Get(ctx, "mask:access_counts")
+	require.EqualError(t, err, "sql store key 'mask:access_counts' is not a mapping key")
+}
+
+func TestSQLStoreSet(t *testing.T) {
+	store, mock := newTestSQLStore(t, sqlDriverPostgres)
+	ctx := context.Background()
+
+	// Both directions of a mapping write the same row
+	args := []driver.Value{"ipv4", sqlHash("192.168.1.1"), "192.168.1.1", sqlHash("10.1.2.3"), "10.1.2.3", int64(1_700_000_060)}
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (category, original_hash) DO UPDATE SET")).
+			WithArgs(args...).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	require.NoError(t, store.Set(ctx, MaskKey("ipv4", "192.168.1.1"), "10.1.2.3", time.Minute))
+	require.NoError(t, store.Set(ctx, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", time.Minute))
+
+	require.ErrorContains(t, store.Set(ctx, "other", "v", 0), "is not a mapping key")
+}
+
+func TestSQLStoreCreateMapping(t *testing.T) {
+	testCases := []struct {
+		driver string
+		now    []driver.Value
+	}{
+		{driver: sqlDriverPostgres, now: []driver.Value{int64(1_700_000_000)}},
+		// MySQL repeats the condition for every column
+		{driver: sqlDriverMySQL, now: []driver.Value{int64(1_700_000_000), int64(1_700_000_000), int64(1_700_000_000), int64(1_700_000_000)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.driver, func(t *testing.T) {
+			store, mock := newTestSQLStore(t, tc.driver)
+			ctx := context.Background()
+			maskKey := MaskKey("ipv4", "192.168.1.1")
+
+			expectFreeToken := func(token string) {
+				mock.ExpectQuery(regexp.QuoteMeta("SELECT original FROM redismasking_mappings")).
+					WithArgs("ipv4", sqlHash(token), int64(1_700_000_000)).
+					WillReturnRows(sqlmock.NewRows([]string{"original"}))
+			}
+			expectFreeToken("10.1.2.3")
+			args := append([]driver.Value{"ipv4", sqlHash("192.168.1.1"), "192.168.1.1", sqlHash("10.1.2.3"), "10.1.2.3", nil}, tc.now...)
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO redismasking_mappings")).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, 1))
+			winner, created, err := store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", 0)
+			require.NoError(t, err)
+			require.True(t, created)
+			require.Equal(t, "10.1.2.3", winner)
+
+			// A live mapping is kept and its token wins
+			expectFreeToken("10.4.5.6")
+			args = append([]driver.Value{"ipv4", sqlHash("192.168.1.1"), "192.168.1.1", sqlHash("10.4.5.6"), "10.4.5.6", nil}, tc.now...)
+			mock.ExpectExec(regexp.QuoteMeta("INSERT INTO redismasking_mappings")).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT token FROM redismasking_mappings")).
+				WithArgs("ipv4", sqlHash("192.168.1.1")).
+				WillReturnRows(sqlmock.NewRows([]string{"token"}).AddRow("10.1.2.3"))
+			winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", 0)
+			require.NoError(t, err)
+			require.False(t, created)
+			require.Equal(t, "10.1.2.3", winner)
+
+			// A token held by another value is refused without an insert
+			mock.ExpectQuery(regexp.QuoteMeta("SELECT original FROM redismasking_mappings")).
+				WithArgs("ipv4", sqlHash("10.1.2.3"), int64(1_700_000_000)).
+				WillReturnRows(sqlmock.NewRows([]string{"original"}).AddRow("192.168.1.1"))
+			_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", 0)
+			require.ErrorIs(t, err, errTokenTaken)
+		})
+	}
+}
+
+func TestSQLStoreMigrate(t *testing.T) {
+	store, mock := newTestSQLStore(t, sqlDriverPostgres)
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_lock(hashtext($1))")).
+		WithArgs("redismasking_mappings_schema").
+		WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(""))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS redismasking_mappings_schema")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM redismasking_mappings_schema")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS redismasking_mappings (")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX IF NOT EXISTS redismasking_mappings_token ON redismasking_mappings (category, token_hash)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO redismasking_mappings_schema (version) VALUES ($1)")).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock(hashtext($1))")).
+		WithArgs("redismasking_mappings_schema").
+		WillReturnRows(sqlmock.NewRows([]string{"unlock"}).AddRow(true))
+	require.NoError(t, store.migrate(ctx))
+
+	// A migrated schema is left unchanged
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_lock")).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(""))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS redismasking_mappings_schema")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0)")).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_advisory_unlock")).WillReturnRows(sqlmock.NewRows([]string{"unlock"}).AddRow(true))
+	require.NoError(t, store.migrate(ctx))
+}
+
+func TestSQLStoreCheckSchema(t *testing.T) {
+	store, mock := newTestSQLStore(t, sqlDriverMySQL)
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM redismasking_mappings_schema")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(0))
+	require.EqualError(t, store.checkSchema(ctx), "table 'redismasking_mappings' is at schema version 0 instead of 1, enable sql_store migrate to migrate it")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(version), 0) FROM redismasking_mappings_schema")).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	require.NoError(t, store.checkSchema(ctx))
+}
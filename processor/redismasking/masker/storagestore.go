@@ -0,0 +1,155 @@
+package masker
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/extension/xextension/storage"
+)
+
+// storageExpiryLen is the size of the expiry prefix of every stored value
+const storageExpiryLen = 8
+
+// storageStore is a Store that persists mappings through a client of a
+// collector storage extension, such as file_storage. Storage clients have no
+// expiry, so every value is prefixed with the unix nanoseconds at which it
+// expires (0 = never) and expired values are reported as missing and deleted.
+type storageStore struct {
+	client storage.Client
+	now    func() time.Time
+
+	// mu serializes mapping creation, which storage clients cannot do atomically
+	mu sync.Mutex
+}
+
+var (
+	_ Store          = (*storageStore)(nil)
+	_ MappingCreator = (*storageStore)(nil)
+)
+
+// NewStorageStore creates a store on client. Closing the store closes the client.
+func NewStorageStore(client storage.Client) Store {
+	return &storageStore{
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// Get returns the value stored under key, unless it expired. Expired values
+// are deleted.
+func (s *storageStore) Get(ctx context.Context, key string) (string, bool, error) {
+	value, found, expired, err := s.read(ctx, key)
+	if expired {
+		return "", false, s.deleteExpired(ctx, key)
+	}
+	return value, found, err
+}
+
+// read returns the value stored under key and whether it expired
+func (s *storageStore) read(ctx context.Context, key string) (string, bool, bool, error) {
+	data, err := s.client.Get(ctx, key)
+	if err != nil {
+		return "", false, false, fmt.Errorf("storage get error: %w", err)
+	}
+	return s.decode(key, data)
+}
+
+// deleteExpired deletes key unless it was stored again since it was read
+func (s *storageStore) deleteExpired(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _, expired, err := s.read(ctx, key)
+	if err != nil || !expired {
+		return err
+	}
+	if err := s.client.Delete(ctx, key); err != nil {
+		return fmt.Errorf("storage delete error: %w", err)
+	}
+	return nil
+}
+
+// Set stores value under key
+func (s *storageStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.client.Set(ctx, key, s.encode(value, ttl)); err != nil {
+		return fmt.Errorf("storage set error: %w", err)
+	}
+	return nil
+}
+
+// CreateMapping stores both directions of a mapping unless maskKey holds a token.
+// Storage clients belong to a single processor, so a lock makes it atomic. The
+// reverse mapping of an expired token is deleted along with it.
+func (s *storageStore) CreateMapping(ctx context.Context, maskKey, unmaskKey, original, token string, ttl time.Duration) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	winner, found, expired, err := s.read(ctx, maskKey)
+	if err != nil {
+		return "", false, err
+	}
+	if found {
+		return winner, false, nil
+	}
+	holder, found, _, err := s.read(ctx, unmaskKey)
+	if err != nil {
+		return "", false, err
+	}
+	if found && holder != original {
+		return "", false, errTokenTaken
+	}
+
+	ops := []*storage.Operation{
+		storage.SetOperation(maskKey, s.encode(token, ttl)),
+		storage.SetOperation(unmaskKey, s.encode(original, ttl)),
+	}
+	if expiredKey := strings.TrimSuffix(unmaskKey, token) + winner; expired && expiredKey != unmaskKey {
+		ops = append(ops, storage.DeleteOperation(expiredKey))
+	}
+	if err := s.client.Batch(ctx, ops...); err != nil {
+		return "", false, fmt.Errorf("storage batch error: %w", err)
+	}
+	return token, true, nil
+}
+
+// Close closes the storage client
+func (s *storageStore) Close() error {
+	return s.client.Close(context.Background())
+}
+
+// encode prefixes value with its expiry
+func (s *storageStore) encode(value string, ttl time.Duration) []byte {
+	var expiry int64
+	if ttl > 0 {
+		expiry = s.now().Add(ttl).UnixNano()
+	}
+	data := make([]byte, storageExpiryLen, storageExpiryLen+len(value))
+	// #nosec G115 -- the expiry is only compared after converting it back
+	binary.BigEndian.PutUint64(data, uint64(expiry))
+	return append(data, value...)
+}
+
+// decode returns the value of data unless it is missing or expired. The value
+// of expired data is returned as well.
+func (s *storageStore) decode(key string, data []byte) (string, bool, bool, error) {
+	if data == nil {
+		return "", false, false, nil
+	}
+	if len(data) < storageExpiryLen {
+		return "", false, false, fmt.Errorf("storage value of '%s' is malformed", key)
+	}
+	value := string(data[storageExpiryLen:])
+	// #nosec G115 -- the expiry was encoded from an int64
+	expiry := int64(binary.BigEndian.Uint64(data))
+	if expiry != 0 && s.now().UnixNano() >= expiry {
+		return value, false, true, nil
+	}
+	return value, true, false, nil
+}
@@ -0,0 +1,142 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/extension/xextension/storage"
+)
+
+// fakeStorageClient is an in-memory storage.Client
+type fakeStorageClient struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	err     error
+	closed  bool
+}
+
+func newFakeStorageClient() *fakeStorageClient {
+	return &fakeStorageClient{entries: make(map[string][]byte)}
+}
+
+func (c *fakeStorageClient) Get(ctx context.Context, key string) ([]byte, error) {
+	op := storage.GetOperation(key)
+	err := c.Batch(ctx, op)
+	return op.Value, err
+}
+
+func (c *fakeStorageClient) Set(ctx context.Context, key string, value []byte) error {
+	return c.Batch(ctx, storage.SetOperation(key, value))
+}
+
+func (c *fakeStorageClient) Delete(ctx context.Context, key string) error {
+	return c.Batch(ctx, storage.DeleteOperation(key))
+}
+
+func (c *fakeStorageClient) Batch(_ context.Context, ops ...*storage.Operation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	for _, op := range ops {
+		switch op.Type {
+		case storage.Get:
+			op.Value = c.entries[op.Key]
+		case storage.Set:
+			c.entries[op.Key] = op.Value
+		case storage.Delete:
+			delete(c.entries, op.Key)
+		}
+	}
+	return nil
+}
+
+func (c *fakeStorageClient) Close(context.Context) error {
+	c.closed = true
+	return nil
+}
+
+func TestStorageStore(t *testing.T) {
+	client := newFakeStorageClient()
+	store := NewStorageStore(client).(*storageStore)
+	now := time.Unix(1_700_000_000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, found, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, store.Set(ctx, "forever", "value", 0))
+	require.NoError(t, store.Set(ctx, "minute", "value", time.Minute))
+
+	now = now.Add(time.Minute)
+	value, found, err := store.Get(ctx, "forever")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", value)
+
+	// Expired values are deleted once read
+	_, found, err = store.Get(ctx, "minute")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NotContains(t, client.entries, "minute")
+
+	client.entries["short"] = []byte{1, 2}
+	_, _, err = store.Get(ctx, "short")
+	require.EqualError(t, err, "storage value of 'short' is malformed")
+
+	client.err = errors.New("closed")
+	_, _, err = store.Get(ctx, "forever")
+	require.EqualError(t, err, "storage get error: closed")
+
+	require.NoError(t, store.Close())
+	require.True(t, client.closed)
+}
+
+func TestStorageStoreCreateMapping(t *testing.T) {
+	client := newFakeStorageClient()
+	store := NewStorageStore(client).(*storageStore)
+	now := time.Unix(1_700_000_000, 0)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+	maskKey := MaskKey("ipv4", "192.168.1.1")
+
+	winner, created, err := store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", "10.1.2.3", time.Minute)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+
+	// A live mapping keeps its token
+	winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", time.Minute)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, "10.1.2.3", winner)
+
+	// A token held by another value is refused
+	_, _, err = store.CreateMapping(ctx, MaskKey("ipv4", "192.168.1.9"), UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.9", "10.1.2.3", time.Minute)
+	require.ErrorIs(t, err, errTokenTaken)
+	require.NotContains(t, client.entries, MaskKey("ipv4", "192.168.1.9"))
+
+	// An expired mapping is replaced
+	now = now.Add(time.Minute)
+	winner, created, err = store.CreateMapping(ctx, maskKey, UnmaskKey("ipv4", "10.4.5.6"), "192.168.1.1", "10.4.5.6", time.Minute)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, "10.4.5.6", winner)
+	require.NotContains(t, client.entries, UnmaskKey("ipv4", "10.1.2.3"))
+	original, found, err = store.Get(ctx, UnmaskKey("ipv4", "10.4.5.6"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+}
@@ -0,0 +1,280 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store persists the mappings between original values and their tokens
+type Store interface {
+	// Get returns the value stored under key and whether it was found
+	Get(ctx context.Context, key string) (string, bool, error)
+
+	// Set stores value under key. A zero ttl means the entry never expires.
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// AccessStat is the usage of the mapping stored under Key
+type AccessStat struct {
+	Key      string
+	Count    int64
+	LastSeen time.Time
+}
+
+// AccessTracker counts how often mappings are used
+type AccessTracker interface {
+	// RecordAccess adds counts to the access counts of the given store keys and
+	// marks them as last seen at seen
+	RecordAccess(ctx context.Context, counts map[string]int64, seen time.Time) error
+
+	// TopAccessed returns up to n mappings ordered from most to least accessed
+	TopAccessed(ctx context.Context, n int) ([]AccessStat, error)
+}
+
+const (
+	// accessCountsKey is the sorted set holding the access count of each
+	// unmask key, which holds a token rather than the original value. Like
+	// saltCheckKey, it is kept outside the mask and unmask prefixes.
+	accessCountsKey = "meta:token_access_counts"
+
+	// lastSeenKey is the hash holding the last access of each unmask key in Unix milliseconds
+	lastSeenKey = "meta:token_access_last_seen"
+
+	// maxTrackedAccess caps the number of mappings with access counts
+	maxTrackedAccess = 100_000
+)
+
+const (
+	// redisNetworkTCP connects to redis_addr as host:port
+	redisNetworkTCP = "tcp"
+
+	// redisNetworkUnix connects to redis_addr as the path of a Unix domain socket
+	redisNetworkUnix = "unix"
+)
+
+// ValidateRedisNetwork checks that network is a supported Redis network and
+// that a socket path is set for Unix domain sockets
+func ValidateRedisNetwork(network, addr string) error {
+	switch network {
+	case "", redisNetworkTCP:
+		return nil
+	case redisNetworkUnix:
+		if addr == "" {
+			return errors.New("redis_addr must be the socket path when redis_network is unix")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported redis_network '%s'", network)
+	}
+}
+
+// redisStore is a Store backed by Redis
+type redisStore struct {
+	client *redis.Client
+
+	// replicas serve lookups when read replicas are configured
+	replicas    []*redis.Client
+	nextReplica atomic.Uint64
+
+	// clientCache serves mask keys from memory when client-side caching is enabled
+	clientCache *clientCache
+
+	// maxTracked caps the access counts, the least accessed are trimmed first
+	maxTracked int64
+
+	// crossSlot is set once the server rejected the create mapping script
+	// because its keys hash to different cluster slots
+	crossSlot atomic.Bool
+}
+
+var _ AccessTracker = (*redisStore)(nil)
+
+// NewRedisStore connects to the Redis server described by cfg
+func NewRedisStore(ctx context.Context, cfg *Config) (Store, error) {
+	store, err := NewLazyRedisStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Test connection
+	if err := store.(Pinger).Ping(ctx); err != nil {
+		_ = store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewLazyRedisStore creates a store for the Redis server described by cfg
+// without connecting to it. Connections are opened on first use, so commands
+// fail until the server is reachable.
+func NewLazyRedisStore(cfg *Config) (Store, error) {
+	options, passwords, err := cfg.redisOptions()
+	if err != nil {
+		return nil, err
+	}
+	store := &redisStore{
+		client:     newRedisClient(options, passwords),
+		replicas:   newReplicaClients(&cfg.RedisReplicas, options, passwords),
+		maxTracked: maxTrackedAccess,
+	}
+	if cfg.RedisClientCache.Enabled() {
+		store.clientCache = newClientCache(&cfg.RedisClientCache, options, passwords)
+	}
+	return store, nil
+}
+
+// Pinger is implemented by stores that can check their connection
+type Pinger interface {
+	// Ping checks that the backing server is reachable
+	Ping(ctx context.Context) error
+}
+
+var _ Pinger = (*redisStore)(nil)
+
+// Ping checks that Redis is reachable
+func (s *redisStore) Ping(ctx context.Context) error {
+	if err := s.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return nil
+}
+
+// Get returns the value stored under key
+func (s *redisStore) Get(ctx context.Context, key string) (string, bool, error) {
+	switch {
+	case len(s.replicas) > 0:
+		return s.replicaGet(ctx, key)
+	case s.clientCache != nil && strings.HasPrefix(key, clientCacheKeyPrefix):
+		return s.cachedGet(ctx, key)
+	}
+	return s.primaryGet(ctx, key)
+}
+
+// primaryGet returns the value stored under key on the primary
+func (s *redisStore) primaryGet(ctx context.Context, key string) (string, bool, error) {
+	value, err := s.client.Get(ctx, key).Result()
+	switch {
+	case err == nil:
+		return value, true, nil
+	case errors.Is(err, redis.Nil):
+		return "", false, nil
+	default:
+		return "", false, fmt.Errorf("redis get error: %w", err)
+	}
+}
+
+// Set stores value under key
+func (s *redisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := s.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set error: %w", err)
+	}
+	return nil
+}
+
+// RecordAccess updates the access counts and last seen times in a single round
+// trip. Once more than maxTracked mappings are counted, the least accessed ones
+// are trimmed in a second one.
+func (s *redisStore) RecordAccess(ctx context.Context, counts map[string]int64, seen time.Time) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	var tracked *redis.IntCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		lastSeen := make(map[string]any, len(counts))
+		for key, count := range counts {
+			pipe.ZIncrBy(ctx, accessCountsKey, float64(count), key)
+			lastSeen[key] = seen.UnixMilli()
+		}
+		pipe.HSet(ctx, lastSeenKey, lastSeen)
+		tracked = pipe.ZCard(ctx, accessCountsKey)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis access count error: %w", err)
+	}
+
+	excess := tracked.Val() - s.maxTracked
+	if excess <= 0 {
+		return nil
+	}
+	trimmed, err := s.client.ZRange(ctx, accessCountsKey, 0, excess-1).Result()
+	if err != nil {
+		return fmt.Errorf("redis access count error: %w", err)
+	}
+	return s.removeAccess(ctx, trimmed)
+}
+
+// removeAccess deletes the access counts and last seen times of keys
+func (s *redisStore) removeAccess(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	members := make([]any, 0, len(keys))
+	for _, key := range keys {
+		members = append(members, key)
+	}
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, accessCountsKey, members...)
+		pipe.HDel(ctx, lastSeenKey, keys...)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis access count error: %w", err)
+	}
+	return nil
+}
+
+// TopAccessed returns the n most accessed mappings
+func (s *redisStore) TopAccessed(ctx context.Context, n int) ([]AccessStat, error) {
+	members, err := s.client.ZRevRangeWithScores(ctx, accessCountsKey, 0, int64(n-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis access count error: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(members))
+	for _, member := range members {
+		keys = append(keys, member.Member.(string))
+	}
+
+	lastSeen, err := s.client.HMGet(ctx, lastSeenKey, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis access count error: %w", err)
+	}
+
+	stats := make([]AccessStat, 0, len(members))
+	for i, member := range members {
+		stat := AccessStat{Key: keys[i], Count: int64(member.Score)}
+		// Counts recorded before last seen times were tracked have no entry
+		if value, ok := lastSeen[i].(string); ok {
+			if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+				stat.LastSeen = time.UnixMilli(millis)
+			}
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// Close closes the Redis clients
+func (s *redisStore) Close() error {
+	var cacheErr error
+	if s.clientCache != nil {
+		cacheErr = s.clientCache.close()
+	}
+	return errors.Join(s.client.Close(), s.closeReplicas(), cacheErr)
+}
@@ -0,0 +1,96 @@
+package masker
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// serveUnixSocket forwards connections to a Unix domain socket to the TCP
+// address of server, since miniredis only listens on TCP. It returns the socket path.
+func serveUnixSocket(t *testing.T, server *miniredis.Miniredis) string {
+	t.Helper()
+
+	// Socket paths are limited to about 100 bytes, which t.TempDir may exceed
+	dir, err := os.MkdirTemp("", "redis")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "redis.sock")
+
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			upstream, err := net.Dial("tcp", server.Addr())
+			if err != nil {
+				_ = conn.Close()
+				continue
+			}
+			go func() {
+				_, _ = io.Copy(upstream, conn)
+				_ = upstream.Close()
+			}()
+			go func() {
+				_, _ = io.Copy(conn, upstream)
+				_ = conn.Close()
+			}()
+		}
+	}()
+	return path
+}
+
+func TestRedisUnixSocket(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	cfg := NewDefaultConfig()
+	cfg.RedisNetwork = redisNetworkUnix
+	cfg.RedisAddr = serveUnixSocket(t, server)
+	require.NoError(t, cfg.Validate())
+
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	defer store.Close()
+
+	require.NoError(t, store.Set(context.Background(), "key", "value", 0))
+	value, err := server.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+}
+
+func TestRecordAccessTrim(t *testing.T) {
+	server := miniredis.RunT(t)
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+	store, err := NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+	store.(*redisStore).maxTracked = 2
+	tracker := store.(AccessTracker)
+	ctx := context.Background()
+
+	require.NoError(t, tracker.RecordAccess(ctx, map[string]int64{UnmaskKey("ipv4", "10.1.2.3"): 3, UnmaskKey("ipv4", "10.1.2.4"): 2}, time.Now()))
+	require.NoError(t, tracker.RecordAccess(ctx, map[string]int64{UnmaskKey("ipv4", "10.1.2.5"): 1}, time.Now()))
+
+	// The least accessed mapping is trimmed with its last seen time
+	top, err := tracker.TopAccessed(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	require.Equal(t, UnmaskKey("ipv4", "10.1.2.3"), top[0].Key)
+	require.Equal(t, UnmaskKey("ipv4", "10.1.2.4"), top[1].Key)
+	fields, err := server.HKeys(lastSeenKey)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{UnmaskKey("ipv4", "10.1.2.3"), UnmaskKey("ipv4", "10.1.2.4")}, fields)
+}
@@ -0,0 +1,198 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+const (
+	// maskingStrategyTokenize replaces a value with its reversible token
+	maskingStrategyTokenize = "tokenize"
+
+	// maskingStrategyPartial keeps the first and last characters of a value and
+	// hides the others, without a token or a stored mapping
+	maskingStrategyPartial = "partial"
+
+	// maskingStrategyRedact replaces a value with a fixed string, without the
+	// store or a token
+	maskingStrategyRedact = "redact"
+
+	// maskingStrategyEmail tokenizes the local part of an email address and
+	// keeps its domain
+	maskingStrategyEmail = "email"
+
+	// maskingStrategyDrop removes a field entirely. It only applies to fields.
+	maskingStrategyDrop = "drop"
+
+	// defaultRedaction replaces values masked with the redact strategy
+	defaultRedaction = "[REDACTED]"
+
+	// defaultPartialMaskChar replaces the hidden characters of partial masking
+	defaultPartialMaskChar = "*"
+)
+
+// StrategyConfig selects how the values of a pattern or field are masked
+type StrategyConfig struct {
+	// Strategy is "tokenize" (the default), "email", "partial", "redact", or, for
+	// fields only, "drop"
+	Strategy string `mapstructure:"strategy" json:"strategy"`
+
+	// Partial defines the characters kept by the partial strategy
+	Partial PartialConfig `mapstructure:"partial" json:"partial"`
+
+	// Redaction replaces values masked with the redact strategy, "[REDACTED]"
+	// by default
+	Redaction string `mapstructure:"redaction" json:"redaction"`
+}
+
+// PartialConfig defines the characters kept by the partial strategy, e.g.
+// keep_last: 4 renders a card number as ************1234
+type PartialConfig struct {
+	// KeepFirst is the number of leading characters kept
+	KeepFirst int `mapstructure:"keep_first" json:"keep_first"`
+
+	// KeepLast is the number of trailing characters kept
+	KeepLast int `mapstructure:"keep_last" json:"keep_last"`
+
+	// MaskChar replaces every hidden character, "*" by default
+	MaskChar string `mapstructure:"mask_char" json:"mask_char"`
+}
+
+// Validate checks the strategy configuration
+func (cfg *StrategyConfig) Validate() error {
+	switch cfg.Strategy {
+	case "", maskingStrategyTokenize, maskingStrategyEmail, maskingStrategyRedact, maskingStrategyDrop:
+		return nil
+	case maskingStrategyPartial:
+		return cfg.Partial.Validate()
+	default:
+		return fmt.Errorf("unsupported strategy '%s'", cfg.Strategy)
+	}
+}
+
+// Validate checks the partial strategy configuration
+func (cfg *PartialConfig) Validate() error {
+	if cfg.KeepFirst < 0 || cfg.KeepLast < 0 {
+		return errors.New("partial keep_first and keep_last must be non-negative")
+	}
+	if cfg.KeepFirst == 0 && cfg.KeepLast == 0 {
+		return errors.New("partial requires keep_first or keep_last")
+	}
+	if cfg.MaskChar != "" && utf8.RuneCountInString(cfg.MaskChar) != 1 {
+		return errors.New("partial mask_char must be a single character")
+	}
+	return nil
+}
+
+// tokenizes reports whether values are replaced by their reversible tokens
+func (cfg StrategyConfig) tokenizes() bool {
+	switch cfg.Strategy {
+	case "", maskingStrategyTokenize, maskingStrategyEmail:
+		return true
+	default:
+		return false
+	}
+}
+
+// mask masks value with a strategy other than tokenize
+func (cfg StrategyConfig) mask(value string) string {
+	switch cfg.Strategy {
+	case maskingStrategyPartial:
+		return cfg.Partial.mask(value)
+	case maskingStrategyRedact:
+		if cfg.Redaction == "" {
+			return defaultRedaction
+		}
+		return cfg.Redaction
+	case maskingStrategyDrop:
+		// Values that cannot be removed, e.g. slice elements, are emptied
+		return ""
+	default:
+		return value
+	}
+}
+
+// mask hides the characters of value between the kept ones. Values too short to
+// hide anything are hidden entirely, so they are never passed through.
+func (cfg PartialConfig) mask(value string) string {
+	maskChar := cfg.MaskChar
+	if maskChar == "" {
+		maskChar = defaultPartialMaskChar
+	}
+
+	runes := []rune(value)
+	if len(runes) <= cfg.KeepFirst+cfg.KeepLast {
+		return strings.Repeat(maskChar, len(runes))
+	}
+	hidden := len(runes) - cfg.KeepFirst - cfg.KeepLast
+	return string(runes[:cfg.KeepFirst]) + strings.Repeat(maskChar, hidden) + string(runes[len(runes)-cfg.KeepLast:])
+}
+
+// strategy returns the masking strategy of the pattern
+func (p *PatternConfig) strategy() StrategyConfig {
+	return StrategyConfig{Strategy: p.Strategy, Partial: p.Partial, Redaction: p.Redaction}
+}
+
+// validateFieldStrategies checks that every strategy is supported and applies to
+// a field that is masked whole
+func (cfg *Config) validateFieldStrategies() error {
+	fields := append(cfg.effectiveFieldsToMask(), cfg.ResourceFieldsToMask...)
+	for field, strategy := range cfg.FieldStrategies {
+		if err := strategy.Validate(); err != nil {
+			return fmt.Errorf("field_strategies '%s': %w", field, err)
+		}
+		if !slices.Contains(fields, field) {
+			return fmt.Errorf("field_strategies key '%s' is not in fields_to_mask or resource_fields_to_mask", field)
+		}
+		if _, ok := cfg.MaskedFieldTypes[field]; ok && !strategy.tokenizes() {
+			return fmt.Errorf("masked_field_types key '%s' only applies to tokenized fields", field)
+		}
+	}
+	return nil
+}
+
+// maskField masks the value v of field k, which is masked whole, with the
+// strategy of the field
+func (m *Masker) maskField(ctx context.Context, k string, v pcommon.Value) error {
+	if strategy := m.config.FieldStrategies[k]; !strategy.tokenizes() {
+		v.SetStr(strategy.mask(v.AsString()))
+		return nil
+	}
+
+	maskedValue, err := m.MaskValue(ctx, v.AsString(), attributeCategory(k))
+	if err != nil {
+		return err
+	}
+	m.setMasked(k, v, maskedValue)
+	return nil
+}
+
+// drops reports whether the field k of fields is removed by the drop strategy
+func (m *Masker) drops(fields []string, k string) bool {
+	return m.config.FieldStrategies[k].Strategy == maskingStrategyDrop && slices.Contains(fields, k)
+}
+
+// maskMatch masks a match of pattern with the strategy of the pattern
+func (m *Masker) maskMatch(ctx context.Context, pattern *compiledPattern, match string) (string, error) {
+	if !pattern.strategy.tokenizes() {
+		return pattern.strategy.mask(match), nil
+	}
+	return m.MaskValue(ctx, match, pattern.name)
+}
+
+// tokenized reports whether the matches of the pattern named category are
+// replaced by tokens. Categories of other sources are always tokenized.
+func (m *Masker) tokenized(category string) bool {
+	for _, pattern := range m.compiledPatterns {
+		if pattern.name == category {
+			return pattern.strategy.tokenizes()
+		}
+	}
+	return true
+}
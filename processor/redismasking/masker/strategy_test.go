@@ -0,0 +1,174 @@
+package masker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestPartialMask(t *testing.T) {
+	testCases := []struct {
+		name     string
+		partial  PartialConfig
+		value    string
+		expected string
+	}{
+		{name: "keep last", partial: PartialConfig{KeepLast: 4}, value: "4111111111111234", expected: "************1234"},
+		{name: "keep first and last", partial: PartialConfig{KeepFirst: 3, KeepLast: 2, MaskChar: "#"}, value: "+15551234567", expected: "+15#######67"},
+		{name: "multibyte", partial: PartialConfig{KeepFirst: 1, MaskChar: "•"}, value: "Zoë", expected: "Z••"},
+		{name: "too short", partial: PartialConfig{KeepLast: 4}, value: "1234", expected: "****"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.partial.mask(tc.value))
+		})
+	}
+}
+
+func TestPartialStrategy(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{
+		{Name: "phone", Regex: `\+\d{11}`, Strategy: maskingStrategyPartial, Partial: PartialConfig{KeepLast: 4}},
+		{Name: "ipv4", Regex: `\b(?:\d{1,3}\.){3}\d{1,3}\b`},
+	}
+	cfg.FieldsToMask = []string{"card_number", "user_id"}
+	cfg.ResourceFieldsToMask = []string{"account_id"}
+	cfg.FieldStrategies = map[string]StrategyConfig{
+		"card_number": {Strategy: maskingStrategyPartial, Partial: PartialConfig{KeepLast: 4}},
+		"account_id":  {Strategy: maskingStrategyPartial, Partial: PartialConfig{KeepFirst: 2}},
+	}
+	cfg.EnrichWithLookupURL = "https://unmask.example.com/"
+	m, server := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("account_id", "AC-998877")
+	lr := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("card_number", "4111-1111-1111-1234")
+	lr.Attributes().PutStr("user_id", "u-42")
+	lr.Body().SetStr("call +15551234567 from 192.168.1.1")
+
+	m.MaskLogs(context.Background(), ld)
+
+	ctx := context.Background()
+	userToken, _ := m.MaskValue(ctx, "u-42", attributeCategory("user_id"))
+	ipToken, _ := m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	assert.Equal(t, map[string]any{
+		"card_number": "***************1234",
+		"user_id":     userToken,
+		// Only tokens are looked up
+		"user_id.lookup_url": m.lookupURL(attributeCategory("user_id"), userToken),
+		lookupURLsAttribute:  []any{m.lookupURL("ipv4", ipToken)},
+	}, lr.Attributes().AsRaw())
+	assert.Equal(t, "call ********4567 from "+ipToken, lr.Body().Str())
+	assert.Equal(t, map[string]any{"account_id": "AC*******"}, rl.Resource().Attributes().AsRaw())
+
+	// Partially masked values have no mapping
+	for _, key := range server.Keys() {
+		assert.NotContains(t, key, "1234")
+		assert.NotContains(t, key, "998877")
+	}
+}
+
+func TestRedactStrategy(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{
+		{Name: "ssn", Regex: `\b\d{3}-\d{2}-\d{4}\b`, Strategy: maskingStrategyRedact},
+		{Name: "api_key", Regex: `\bsk_[a-z0-9]+\b`, Strategy: maskingStrategyRedact, Redaction: "<secret>"},
+	}
+	cfg.FieldsToMask = []string{"password"}
+	cfg.FieldStrategies = map[string]StrategyConfig{"password": {Strategy: maskingStrategyRedact}}
+
+	m, server := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutInt("password", 123456)
+	lr.Body().SetStr("ssn 123-45-6789 key sk_live42")
+
+	m.MaskLogs(context.Background(), ld)
+
+	assert.Equal(t, map[string]any{"password": "[REDACTED]"}, lr.Attributes().AsRaw())
+	assert.Equal(t, "ssn [REDACTED] key <secret>", lr.Body().Str())
+
+	// Redaction never reaches the store
+	assert.Empty(t, server.Keys())
+}
+
+func TestDropStrategy(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{}
+	cfg.FieldsToMask = []string{"ssn", "user_id"}
+	cfg.ResourceFieldsToMask = []string{"host.ip"}
+	cfg.FieldStrategies = map[string]StrategyConfig{
+		"ssn":     {Strategy: maskingStrategyDrop},
+		"host.ip": {Strategy: maskingStrategyDrop},
+	}
+	m, _ := newTestMasker(t, &cfg)
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("host.ip", "10.1.2.3")
+	rl.Resource().Attributes().PutStr("ssn", "123-45-6789")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	first := records.AppendEmpty()
+	first.Attributes().PutStr("ssn", "123-45-6789")
+	first.Attributes().PutStr("user_id", "u-42")
+	second := records.AppendEmpty()
+	body := second.Body().SetEmptyMap()
+	body.PutEmptyMap("patient").PutStr("ssn", "123-45-6789")
+	ssns := body.PutEmptySlice("ssns")
+	ssns.AppendEmpty().SetEmptyMap().PutStr("ssn", "987-65-4321")
+	body.PutStr("note", "checked in")
+
+	m.MaskLogs(context.Background(), ld)
+
+	userToken, _ := m.MaskValue(context.Background(), "u-42", attributeCategory("user_id"))
+	assert.Equal(t, map[string]any{"user_id": userToken}, first.Attributes().AsRaw())
+	assert.Equal(t, map[string]any{
+		"patient": map[string]any{},
+		"ssns":    []any{map[string]any{}},
+		"note":    "checked in",
+	}, second.Body().AsRaw())
+
+	// Only resource_fields_to_mask are dropped from the resource
+	assert.Equal(t, map[string]any{"ssn": "123-45-6789"}, rl.Resource().Attributes().AsRaw())
+}
+
+func TestEmailStrategy(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = []PatternConfig{
+		{Name: "email", Regex: `[\w.+-]+@[\w-]+\.[\w.]+`, Strategy: maskingStrategyEmail},
+	}
+	cfg.FieldsToMask = []string{"contact"}
+	cfg.FieldStrategies = map[string]StrategyConfig{"contact": {Strategy: maskingStrategyEmail}}
+	cfg.TokenLength = 6
+	m, _ := newTestMasker(t, &cfg)
+	ctx := context.Background()
+
+	acme := m.MaskString(ctx, "j.smith@acme.com")
+	example := m.MaskString(ctx, "j.smith@example.org")
+	assert.Regexp(t, `^user-[0-9a-f]{6}@acme\.com$`, acme)
+	assert.Regexp(t, `^user-[0-9a-f]{6}@example\.org$`, example)
+	// Equal local parts at different domains get different tokens
+	assert.NotEqual(t, strings.TrimSuffix(acme, "@acme.com"), strings.TrimSuffix(example, "@example.org"))
+
+	contact, err := m.MaskValue(ctx, "ops@acme.com", attributeCategory("contact"))
+	require.NoError(t, err)
+	assert.Regexp(t, `^user-[0-9a-f]{6}@acme\.com$`, contact)
+
+	// The token still unmasks to the address
+	original, found, err := m.store.Get(ctx, UnmaskKey(attributeCategory("contact"), contact))
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "ops@acme.com", original)
+
+	// Values without a domain get a hashed token
+	noDomain, err := m.MaskValue(ctx, "ops", attributeCategory("contact"))
+	require.NoError(t, err)
+	assert.Regexp(t, `^contact-[0-9a-f]{6}$`, noDomain)
+}
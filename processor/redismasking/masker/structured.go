@@ -0,0 +1,56 @@
+package masker
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// maskStructured masks text with the strategy of field. The sensitive parts
+// are tokenized while the surrounding format is kept intact.
+func (m *Masker) maskStructured(ctx context.Context, field StructuredFieldConfig, text string, onMask func(category, token string)) string {
+	switch field.Strategy {
+	case strategyGraphQL:
+		return m.maskGraphQL(ctx, text, onMask)
+	case strategyBaggage:
+		return m.maskListMembers(ctx, text, field.Members, true, onMask)
+	case strategyTraceState:
+		return m.maskListMembers(ctx, text, field.Members, false, onMask)
+	case strategyCookie:
+		return m.maskCookies(ctx, text, field.Members, onMask)
+	case strategyIdentity:
+		return m.maskIdentity(ctx, text, onMask)
+	default:
+		return m.maskString(ctx, text, onMask)
+	}
+}
+
+// maskStructuredValue masks a string attribute, or each string of a slice
+// attribute such as a list of captured Set-Cookie headers, in place
+func (m *Masker) maskStructuredValue(ctx context.Context, field StructuredFieldConfig, v pcommon.Value, onMask func(category, token string)) {
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		originalValue := v.Str()
+		if maskedValue := m.maskStructured(ctx, field, originalValue, onMask); maskedValue != originalValue {
+			v.SetStr(maskedValue)
+		}
+	case pcommon.ValueTypeSlice:
+		for i := 0; i < v.Slice().Len(); i++ {
+			if element := v.Slice().At(i); element.Type() == pcommon.ValueTypeStr {
+				m.maskStructuredValue(ctx, field, element, onMask)
+			}
+		}
+	}
+}
+
+// maskBody masks a string log body, using its metadata segment or structured field
+// strategy when one is configured
+func (m *Masker) maskBody(ctx context.Context, body string, onMask func(category, token string)) string {
+	if masked, ok := m.maskMetadataSegment(ctx, body, onMask); ok {
+		return masked
+	}
+	if field, ok := m.structuredFields[bodyKey]; ok {
+		return m.maskStructured(ctx, field, body, onMask)
+	}
+	return m.maskString(ctx, body, onMask)
+}
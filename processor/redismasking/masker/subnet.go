@@ -0,0 +1,64 @@
+package masker
+
+import (
+	"errors"
+	"net/netip"
+	"strconv"
+)
+
+// validatePreserveIPPrefix checks that preserve_ip_prefix keeps a /16 or /24
+// network, or is disabled
+func (cfg *Config) validatePreserveIPPrefix() error {
+	switch cfg.PreserveIPPrefix {
+	case 0, 16, 24:
+		return nil
+	default:
+		return errors.New("preserve_ip_prefix must be 0, 16, or 24")
+	}
+}
+
+// preservesSubnet reports whether the tokens of category keep the network of
+// the original IPv4 address. Formats that replace the shape of every token take
+// precedence.
+func (m *Masker) preservesSubnet(category string) bool {
+	if category != "ipv4" || m.config.PreserveIPPrefix == 0 {
+		return false
+	}
+	switch m.tokenFormat(category) {
+	case tokenFormatUUID, tokenFormatEnvelope:
+		return false
+	default:
+		return true
+	}
+}
+
+// subnetToken derives a token of the IPv4 address originalValue that keeps its
+// /16 or /24 network and replaces the host part with bytes of the hash of
+// seed. The last byte is never 0 or 255, so the token is not taken for a
+// network or broadcast address. A token equal to the address or inside a
+// reserved namespace is derived again with a counter suffix. Values that are
+// not IPv4 addresses are reported as such.
+func (m *Masker) subnetToken(originalValue, seed string) (string, bool) {
+	addr, err := netip.ParseAddr(originalValue)
+	if err != nil || !addr.Is4() {
+		return "", false
+	}
+
+	var token string
+	for n := 0; n <= maxReservedRetries; n++ {
+		hash := m.digest(seed)
+		if n > 0 {
+			hash = m.digest(seed + "#" + strconv.Itoa(n))
+		}
+
+		octets := addr.As4()
+		prefixBytes := m.config.PreserveIPPrefix / 8
+		copy(octets[prefixBytes:], hash)
+		octets[3] = 1 + hash[3]%254
+		token = netip.AddrFrom4(octets).String()
+		if token != originalValue && !m.reserved.contains(token) {
+			break
+		}
+	}
+	return token, true
+}
@@ -0,0 +1,88 @@
+package masker
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPreserveIPPrefix(t *testing.T) {
+	ctx := context.Background()
+	for _, bits := range []int{16, 24} {
+		cfg := NewDefaultConfig()
+		cfg.Patterns = []PatternConfig{DefaultPatterns()[1]}
+		cfg.PreserveIPPrefix = bits
+		require.Equal(t, "ipv4", cfg.Patterns[0].Name)
+		require.NoError(t, cfg.Validate())
+		m, _ := newTestMasker(t, &cfg)
+
+		network := netip.PrefixFrom(netip.MustParseAddr("192.168.1.20"), bits).Masked()
+		for _, value := range []string{"192.168.1.20", "192.168.1.21"} {
+			masked := m.MaskString(ctx, "from "+value)
+			token := netip.MustParseAddr(strings.TrimPrefix(masked, "from "))
+			assert.True(t, network.Contains(token), "token %s of %s", token, value)
+			assert.NotEqual(t, value, token.String())
+			assert.NotContains(t, []byte{0, 255}, token.As4()[3])
+			assert.Equal(t, masked, m.MaskString(ctx, "from "+value))
+		}
+
+		// Addresses of other networks keep their own prefix
+		token := netip.MustParseAddr(strings.TrimPrefix(m.MaskString(ctx, "from 172.16.5.9"), "from "))
+		assert.True(t, netip.PrefixFrom(netip.MustParseAddr("172.16.5.9"), bits).Masked().Contains(token))
+	}
+}
+
+func TestPreserveIPPrefixReservedNamespaces(t *testing.T) {
+	plain := &Masker{config: &Config{PreserveIPPrefix: 24}, logger: zap.NewNop()}
+	token := plain.generateMaskedValue("192.168.1.20", "ipv4")
+
+	cfg := &Config{PreserveIPPrefix: 24, ReservedNamespaces: ReservedNamespacesConfig{CIDRs: []string{token + "/32"}}}
+	reserved, err := cfg.ReservedNamespaces.parse()
+	require.NoError(t, err)
+	m := &Masker{config: cfg, logger: zap.NewNop(), reserved: reserved}
+
+	regenerated := m.generateMaskedValue("192.168.1.20", "ipv4")
+	assert.NotEqual(t, token, regenerated)
+	assert.True(t, netip.MustParsePrefix("192.168.1.0/24").Contains(netip.MustParseAddr(regenerated)))
+
+	// Formats replacing the shape of every token win over the kept prefix
+	cfg.TokenFormat = tokenFormatUUID
+	assert.Regexp(t, `^[0-9a-f]{8}-`, m.generateMaskedValue("192.168.1.20", "ipv4"))
+}
+
+func TestPreserveIPPrefixDefaultPatterns(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PreserveIPPrefix = 24
+	m, _ := newTestMasker(t, &cfg)
+
+	// Later patterns, e.g. hostname, never mask the token of an address again
+	masked := m.MaskString(context.Background(), "from 192.168.1.20 port 443")
+	fields := strings.Fields(masked)
+	require.Len(t, fields, 4)
+	token, err := netip.ParseAddr(fields[1])
+	require.NoError(t, err)
+	assert.True(t, netip.MustParsePrefix("192.168.1.0/24").Contains(token))
+}
+
+func TestPreserveIPPrefixCollision(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.PreserveIPPrefix = 24
+	m, server := newTestMasker(t, &cfg)
+
+	// Another host of the network already holds the token of the address
+	taken := m.generateMaskedValue("192.168.1.20", "ipv4")
+	require.NoError(t, server.Set(UnmaskKey("ipv4", taken), "192.168.1.21"))
+
+	token, err := m.MaskValue(context.Background(), "192.168.1.20", "ipv4")
+	require.NoError(t, err)
+	assert.NotEqual(t, taken, token)
+	assert.True(t, netip.MustParsePrefix("192.168.1.0/24").Contains(netip.MustParseAddr(token)))
+	original, err := server.Get(UnmaskKey("ipv4", taken))
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.21", original)
+}
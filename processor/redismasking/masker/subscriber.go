@@ -0,0 +1,103 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// subscriberHealthCheck is how long a subscription may be idle before it is
+// pinged, and how long a failed subscription waits to resubscribe. The
+// subscription is considered failed when the ping is not answered in time.
+const subscriberHealthCheck = 5 * time.Second
+
+// subscriber keeps a pub/sub subscription open until it is closed,
+// resubscribing after failures. Messages published while it is down are lost,
+// so disconnect is called whenever the subscription fails.
+type subscriber struct {
+	subscribe  func(ctx context.Context) *redis.PubSub
+	handle     func(msg any)
+	disconnect func()
+
+	// pubsub is the current subscription, closed to stop receiving
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startSubscriber subscribes with subscribe and passes every subscription
+// confirmation and message to handle
+func startSubscriber(subscribe func(ctx context.Context) *redis.PubSub, handle func(msg any), disconnect func()) *subscriber {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &subscriber{
+		subscribe:  subscribe,
+		handle:     handle,
+		disconnect: disconnect,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+	go s.run(ctx)
+	return s
+}
+
+// run receives messages until the subscriber is closed, resubscribing after
+// failures
+func (s *subscriber) run(ctx context.Context) {
+	defer close(s.done)
+	for ctx.Err() == nil {
+		s.receive(ctx)
+		select {
+		case <-ctx.Done():
+		case <-time.After(subscriberHealthCheck):
+		}
+	}
+}
+
+// receive subscribes and handles messages until the subscription fails
+func (s *subscriber) receive(ctx context.Context) {
+	s.mu.Lock()
+	if ctx.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	pubsub := s.subscribe(ctx)
+	s.pubsub = pubsub
+	s.mu.Unlock()
+	defer func() { _ = pubsub.Close() }()
+	defer s.disconnect()
+
+	pinged := false
+	for {
+		msg, err := pubsub.ReceiveTimeout(ctx, subscriberHealthCheck)
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() && !pinged {
+			pinged = true
+			if err := pubsub.Ping(ctx); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return
+		}
+		pinged = false
+		s.handle(msg)
+	}
+}
+
+// close stops receiving and waits for the subscriber to exit
+func (s *subscriber) close() {
+	s.cancel()
+	s.mu.Lock()
+	if s.pubsub != nil {
+		// Interrupts a blocked receive
+		_ = s.pubsub.Close()
+	}
+	s.mu.Unlock()
+	<-s.done
+}
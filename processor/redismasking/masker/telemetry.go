@@ -0,0 +1,238 @@
+package masker
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// meterName is the instrumentation scope of the masking metrics
+const meterName = "github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+
+var (
+	// bodySizeBuckets are the bucket boundaries of the body size histogram in
+	// bytes, chosen around typical max_scan_bytes values
+	bodySizeBuckets = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576}
+
+	// matchBuckets are the bucket boundaries of the matches per record histogram
+	matchBuckets = []float64{0, 1, 2, 5, 10, 20, 50, 100}
+)
+
+// telemetry records distributions of the masked data for tuning max_scan_bytes
+// and worker pools. Its histograms carry no attributes to keep cardinality low,
+// while sightings of watched tokens, which are few, are reported per token.
+type telemetry struct {
+	bodySize           metric.Int64Histogram
+	matches            metric.Int64Histogram
+	watchedSightings   metric.Int64Counter
+	distinctIdentities metric.Int64Gauge
+	fallbacks          metric.Int64Counter
+	detected           metric.Int64Counter
+	cardholderDropped  metric.Int64Counter
+	lookups            metric.Int64Counter
+	tokensCreated      metric.Int64Counter
+}
+
+// WithMeterProvider records the body size and match distributions of masked
+// log records, the sightings of watched tokens, the distinct counts, the
+// fallbacks by cause, the records detected in detect_only mode, the records
+// dropped for holding cardholder data, and the token lookups and creations with mp
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(m *Masker) {
+		m.meterProvider = mp
+	}
+}
+
+func newTelemetry(mp metric.MeterProvider) (*telemetry, error) {
+	meter := mp.Meter(meterName)
+
+	bodySize, err := meter.Int64Histogram(
+		"redismasking.log.body_size",
+		metric.WithDescription("Size of the string bodies of masked log records"),
+		metric.WithUnit("By"),
+		metric.WithExplicitBucketBoundaries(bodySizeBuckets...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create body size histogram: %w", err)
+	}
+
+	matches, err := meter.Int64Histogram(
+		"redismasking.log.matches",
+		metric.WithDescription("Number of values masked per log record"),
+		metric.WithUnit("{matches}"),
+		metric.WithExplicitBucketBoundaries(matchBuckets...),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create matches histogram: %w", err)
+	}
+
+	watchedSightings, err := meter.Int64Counter(
+		"redismasking.watched_token.sightings",
+		metric.WithDescription("Number of times a watched token was seen in masked telemetry"),
+		metric.WithUnit("{sightings}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watched token sightings counter: %w", err)
+	}
+
+	distinctIdentities, err := meter.Int64Gauge(
+		"redismasking.distinct_identities",
+		metric.WithDescription("Differentially private count of the distinct values masked per category in the last interval"),
+		metric.WithUnit("{identities}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create distinct identities gauge: %w", err)
+	}
+
+	fallbacks, err := meter.Int64Counter(
+		"redismasking.fallbacks",
+		metric.WithDescription("Number of times a fallback was taken instead of masking a value as configured"),
+		metric.WithUnit("{fallbacks}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fallbacks counter: %w", err)
+	}
+
+	detected, err := meter.Int64Counter(
+		"redismasking.log.detected",
+		metric.WithDescription("Number of log records holding sensitive data in detect_only mode"),
+		metric.WithUnit("{records}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create detected records counter: %w", err)
+	}
+
+	cardholderDropped, err := meter.Int64Counter(
+		"redismasking.log.cardholder_data_dropped",
+		metric.WithDescription("Number of log records dropped for holding a card number next to its expiry date or CVV"),
+		metric.WithUnit("{records}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cardholder data counter: %w", err)
+	}
+
+	lookups, err := meter.Int64Counter(
+		"redismasking.token.lookups",
+		metric.WithDescription("Number of token lookups in the store by result, hit or miss"),
+		metric.WithUnit("{lookups}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token lookups counter: %w", err)
+	}
+
+	tokensCreated, err := meter.Int64Counter(
+		"redismasking.token.created",
+		metric.WithDescription("Number of new tokens whose mapping was created"),
+		metric.WithUnit("{tokens}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tokens created counter: %w", err)
+	}
+
+	return &telemetry{
+		bodySize:           bodySize,
+		matches:            matches,
+		watchedSightings:   watchedSightings,
+		distinctIdentities: distinctIdentities,
+		fallbacks:          fallbacks,
+		detected:           detected,
+		cardholderDropped:  cardholderDropped,
+		lookups:            lookups,
+		tokensCreated:      tokensCreated,
+	}, nil
+}
+
+// countMatches returns a callback counting every token created into matches
+// before passing it on to onMask, which may be nil
+func countMatches(onMask func(category, token string), matches *int64) func(category, token string) {
+	return func(category, token string) {
+		*matches++
+		if onMask != nil {
+			onMask(category, token)
+		}
+	}
+}
+
+// recordLog records the body size, when the body is a string, and the matches
+// of a masked log record. A nil telemetry records nothing.
+func (t *telemetry) recordLog(ctx context.Context, bodySize int, isString bool, matches int64) {
+	if t == nil {
+		return
+	}
+	if isString {
+		t.bodySize.Record(ctx, int64(bodySize))
+	}
+	t.matches.Record(ctx, matches)
+}
+
+// recordWatchedSighting counts a sighting of a watched token. A nil telemetry records nothing.
+func (t *telemetry) recordWatchedSighting(ctx context.Context, category, token string) {
+	if t == nil {
+		return
+	}
+	t.watchedSightings.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("category", category),
+		attribute.String("token", token),
+	))
+}
+
+// recordDistinct records the noised distinct count of category. A nil telemetry records nothing.
+func (t *telemetry) recordDistinct(category string, count int64) {
+	if t == nil {
+		return
+	}
+	t.distinctIdentities.Record(context.Background(), count, metric.WithAttributes(
+		attribute.String("category", category),
+	))
+}
+
+// recordFallback counts a fallback by its path and cause. A nil telemetry records nothing.
+func (t *telemetry) recordFallback(ctx context.Context, fallback, cause string) {
+	if t == nil {
+		return
+	}
+	t.fallbacks.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("fallback", fallback),
+		attribute.String("cause", cause),
+	))
+}
+
+// recordDetected counts a log record holding sensitive data. A nil telemetry records nothing.
+func (t *telemetry) recordDetected(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.detected.Add(ctx, 1)
+}
+
+// recordCardholderDataDropped counts a log record dropped for holding cardholder
+// data. A nil telemetry records nothing.
+func (t *telemetry) recordCardholderDataDropped(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.cardholderDropped.Add(ctx, 1)
+}
+
+// recordLookup counts a token lookup as a hit when the token was found. A nil
+// telemetry records nothing.
+func (t *telemetry) recordLookup(ctx context.Context, found bool) {
+	if t == nil {
+		return
+	}
+	result := "miss"
+	if found {
+		result = "hit"
+	}
+	t.lookups.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+// recordTokenCreated counts a new token. A nil telemetry records nothing.
+func (t *telemetry) recordTokenCreated(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.tokensCreated.Add(ctx, 1)
+}
@@ -0,0 +1,83 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestTelemetry(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	cfg := NewDefaultConfig()
+	cfg.Mode = modeLightweight
+	cfg.FieldsToMask = []string{"user_id"}
+	cfg.Patterns = ipv4Patterns()
+	m, err := New(&cfg, nil, zap.NewNop(), WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	first := records.AppendEmpty()
+	first.Body().SetStr("connection from 192.168.1.1 to 192.168.1.2")
+	first.Attributes().PutStr("user_id", "jdoe")
+	second := records.AppendEmpty()
+	second.Body().SetEmptyMap().PutStr("message", "no matches")
+	m.MaskLogs(context.Background(), ld)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	histograms := map[string]metricdata.HistogramDataPoint[int64]{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		data := metric.Data.(metricdata.Histogram[int64])
+		require.Len(t, data.DataPoints, 1)
+		histograms[metric.Name] = data.DataPoints[0]
+	}
+
+	// Only string bodies have a size
+	bodySize := histograms["redismasking.log.body_size"]
+	assert.Equal(t, uint64(1), bodySize.Count)
+	assert.Equal(t, int64(len("connection from 192.168.1.1 to 192.168.1.2")), bodySize.Sum)
+	assert.Equal(t, bodySizeBuckets, bodySize.Bounds)
+
+	// Two body matches and one masked field in the first record, none in the second
+	matches := histograms["redismasking.log.matches"]
+	assert.Equal(t, uint64(2), matches.Count)
+	assert.Equal(t, int64(3), matches.Sum)
+	assert.Equal(t, matchBuckets, matches.Bounds)
+}
+
+func TestTokenTelemetry(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	base, _ := newTestMasker(t, &cfg)
+	m, err := New(&cfg, base.store, zap.NewNop(), WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+
+	// The first address is created, then found again
+	m.MaskString(context.Background(), "connection from 192.168.1.1 to 192.168.1.1")
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	sums := map[string]map[string]int64{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		data, ok := metric.Data.(metricdata.Sum[int64])
+		if !ok {
+			continue
+		}
+		sums[metric.Name] = map[string]int64{}
+		for _, point := range data.DataPoints {
+			result, _ := point.Attributes.Value("result")
+			sums[metric.Name][result.AsString()] = point.Value
+		}
+	}
+	assert.Equal(t, map[string]int64{"hit": 1, "miss": 1}, sums["redismasking.token.lookups"])
+	assert.Equal(t, map[string]int64{"": 1}, sums["redismasking.token.created"])
+}
@@ -0,0 +1,8 @@
+package redismasking
+
+decision := "drop" if {
+	"attribute_password" in input.categories
+} else := "skip" if {
+	input.resource["service.name"] == "trusted"
+	input.destination == "internal"
+} else := "mask"
@@ -0,0 +1,37 @@
+package masker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TimeoutConfig bounds the Redis operations, so a slow or hung Redis fails the
+// affected lookups instead of stalling the pipeline. Zero values keep the client
+// defaults.
+type TimeoutConfig struct {
+	// Dial is how long establishing a new connection may take
+	Dial time.Duration `mapstructure:"dial"`
+
+	// Read is how long a command waits for its reply
+	Read time.Duration `mapstructure:"read"`
+
+	// Write is how long writing a command may take
+	Write time.Duration `mapstructure:"write"`
+}
+
+// Validate checks that no timeout is negative
+func (cfg *TimeoutConfig) Validate() error {
+	if cfg.Dial < 0 || cfg.Read < 0 || cfg.Write < 0 {
+		return errors.New("redis_timeouts must be non-negative")
+	}
+	return nil
+}
+
+// apply sets the configured timeouts on options
+func (cfg *TimeoutConfig) apply(options *redis.Options) {
+	options.DialTimeout = cfg.Dial
+	options.ReadTimeout = cfg.Read
+	options.WriteTimeout = cfg.Write
+}
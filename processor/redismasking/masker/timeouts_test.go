@@ -0,0 +1,58 @@
+package masker
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisTimeouts(t *testing.T) {
+	cfg := NewDefaultConfig()
+
+	// Zero values keep the client defaults
+	options, err := cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Zero(t, options.ReadTimeout)
+
+	cfg.RedisTimeouts = TimeoutConfig{Dial: time.Second, Read: 200 * time.Millisecond, Write: 300 * time.Millisecond}
+	options, err = cfg.RedisOptions()
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, options.DialTimeout)
+	assert.Equal(t, 200*time.Millisecond, options.ReadTimeout)
+	assert.Equal(t, 300*time.Millisecond, options.WriteTimeout)
+}
+
+func TestRedisReadTimeout(t *testing.T) {
+	// A server that accepts connections but never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	cfg := NewDefaultConfig()
+	cfg.RedisAddr = listener.Addr().String()
+	cfg.RedisTimeouts.Read = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err = NewRedisStore(context.Background(), &cfg)
+	require.ErrorContains(t, err, "failed to connect to Redis")
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
@@ -0,0 +1,176 @@
+package masker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TLSConfig defines the TLS settings of the Redis connection, e.g. for managed
+// Redis offerings that only accept TLS
+type TLSConfig struct {
+	// Enabled connects to Redis over TLS
+	Enabled bool `mapstructure:"enabled"`
+
+	// CAFile is a PEM file of the certificate authorities trusted to sign the
+	// server certificate. The system pool is used when empty.
+	CAFile string `mapstructure:"ca_file"`
+
+	// InsecureSkipVerify disables the verification of the server certificate
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+
+	// ServerName overrides the name the server certificate is verified against
+	ServerName string `mapstructure:"server_name"`
+
+	// CertFile is a PEM file of the client certificate presented to Redis
+	CertFile string `mapstructure:"cert_file"`
+
+	// KeyFile is a PEM file of the private key of CertFile
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// Load returns the TLS configuration of the connection, or nil when TLS is disabled
+func (cfg *TLSConfig) Load() (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	// #nosec G402 -- skipping verification is an explicit opt-in of the operator
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		// #nosec G304 -- the CA file is provided by the collector configuration
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tls ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("tls ca_file contains no PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, errors.New("tls cert_file and key_file must be set together")
+		}
+		reloader := &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+		if err := reloader.reload(); err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = reloader.getClientCertificate
+	}
+	return tlsConfig, nil
+}
+
+// certReloader serves the client certificate, reloading it when the files change
+// so rotated certificates are picked up by new connections without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// getClientCertificate returns the current client certificate. A certificate
+// that fails to load, e.g. while its files are being replaced, keeps the
+// previous one in use.
+func (r *certReloader) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if modTime, err := r.latestModTime(); err == nil && !modTime.Equal(r.modTime) {
+		_ = r.reloadLocked()
+	}
+	return r.cert, nil
+}
+
+// reload loads the certificate and key files
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reloadLocked()
+}
+
+// reloadLocked loads the certificate and key files. The caller holds r.mu.
+func (r *certReloader) reloadLocked() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return fmt.Errorf("failed to load tls client certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls client certificate: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// latestModTime returns the latest modification time of the certificate and key files
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// RedisOptions returns the options of a client connecting to the Redis server
+// of cfg. Clients created from them read a redis_password_file only once, see
+// NewRedisClient.
+func (cfg *Config) RedisOptions() (*redis.Options, error) {
+	options, _, err := cfg.redisOptions()
+	return options, err
+}
+
+// redisOptions returns the options of a client connecting to the Redis server
+// of cfg, and the password file they read the password from, if any
+func (cfg *Config) redisOptions() (*redis.Options, *passwordFile, error) {
+	tlsConfig, err := cfg.TLS.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := &redis.Options{
+		Network:   cfg.RedisNetwork,
+		Addr:      cfg.RedisAddr,
+		Password:  cfg.RedisPassword,
+		DB:        cfg.RedisDB,
+		TLSConfig: tlsConfig,
+	}
+	cfg.RedisPool.apply(options)
+	cfg.RedisTimeouts.apply(options)
+	cfg.RedisRetry.apply(options)
+	if err := cfg.RedisAuth.apply(options); err != nil {
+		return nil, nil, err
+	}
+
+	var passwords *passwordFile
+	if cfg.RedisPasswordFile != "" {
+		passwords, err = newPasswordFile(cfg.RedisPasswordFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		options.CredentialsProviderContext = passwords.credentials
+	}
+	return options, passwords, nil
+}
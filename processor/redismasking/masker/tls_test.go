@@ -0,0 +1,239 @@
+package masker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns a self-signed certificate for redis.internal and its PEM encoding
+func newTestCertificate(t *testing.T) (tls.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.internal"},
+		DNSNames:              []string{"redis.internal"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// writeTestClientCertificate writes a self-signed client certificate and its key
+// to dir and returns the certificate and the paths of both files
+func writeTestClientCertificate(t *testing.T, dir, name string) (*x509.Certificate, string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return cert, certFile, keyFile
+}
+
+func TestRedisTLS(t *testing.T) {
+	cert, certPEM := newTestCertificate(t)
+	server, err := miniredis.RunTLS(&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0o600))
+
+	testCases := []struct {
+		name        string
+		tls         TLSConfig
+		expectedErr string
+	}{
+		{
+			name: "trusted ca",
+			tls:  TLSConfig{Enabled: true, CAFile: caFile},
+		},
+		{
+			name: "server name",
+			tls:  TLSConfig{Enabled: true, CAFile: caFile, ServerName: "redis.internal"},
+		},
+		{
+			name: "insecure skip verify",
+			tls:  TLSConfig{Enabled: true, InsecureSkipVerify: true},
+		},
+		{
+			name:        "untrusted certificate",
+			tls:         TLSConfig{Enabled: true},
+			expectedErr: "failed to connect to Redis",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.RedisAddr = server.Addr()
+			cfg.TLS = tc.tls
+
+			store, err := NewRedisStore(context.Background(), &cfg)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			defer store.Close()
+			require.NoError(t, store.Set(context.Background(), "key", "value", 0))
+		})
+	}
+}
+
+func TestTLSConfigLoad(t *testing.T) {
+	tlsConfig, err := (&TLSConfig{CAFile: "ignored.pem"}).Load()
+	require.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	_, err = (&TLSConfig{Enabled: true, CAFile: filepath.Join(t.TempDir(), "missing.pem")}).Load()
+	require.ErrorContains(t, err, "failed to read tls ca_file")
+
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(t, os.WriteFile(invalid, []byte("not a certificate"), 0o600))
+	_, err = (&TLSConfig{Enabled: true, CAFile: invalid}).Load()
+	require.EqualError(t, err, "tls ca_file contains no PEM certificates")
+
+	_, err = (&TLSConfig{Enabled: true, CertFile: "client.crt"}).Load()
+	require.EqualError(t, err, "tls cert_file and key_file must be set together")
+
+	_, err = (&TLSConfig{Enabled: true, CertFile: invalid, KeyFile: invalid}).Load()
+	require.ErrorContains(t, err, "failed to load tls client certificate")
+}
+
+func TestRedisMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeTestClientCertificate(t, dir, "client")
+	_, untrustedCertFile, untrustedKeyFile := writeTestClientCertificate(t, dir, "untrusted")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	cert, certPEM := newTestCertificate(t)
+	server, err := miniredis.RunTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, certPEM, 0o600))
+
+	testCases := []struct {
+		name        string
+		tls         TLSConfig
+		expectedErr string
+	}{
+		{
+			name: "trusted client certificate",
+			tls:  TLSConfig{Enabled: true, CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+		},
+		{
+			name:        "untrusted client certificate",
+			tls:         TLSConfig{Enabled: true, CAFile: caFile, CertFile: untrustedCertFile, KeyFile: untrustedKeyFile},
+			expectedErr: "failed to connect to Redis",
+		},
+		{
+			name:        "no client certificate",
+			tls:         TLSConfig{Enabled: true, CAFile: caFile},
+			expectedErr: "failed to connect to Redis",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewDefaultConfig()
+			cfg.RedisAddr = server.Addr()
+			cfg.TLS = tc.tls
+
+			store, err := NewRedisStore(context.Background(), &cfg)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			defer store.Close()
+			require.NoError(t, store.Set(context.Background(), "key", "value", 0))
+		})
+	}
+}
+
+func TestClientCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	first, certFile, keyFile := writeTestClientCertificate(t, dir, "client")
+
+	tlsConfig, err := (&TLSConfig{Enabled: true, CertFile: certFile, KeyFile: keyFile}).Load()
+	require.NoError(t, err)
+	cert, err := tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.Raw, cert.Certificate[0])
+
+	// Rotate the files in place
+	second, rotatedCertFile, rotatedKeyFile := writeTestClientCertificate(t, t.TempDir(), "client")
+	for src, dst := range map[string]string{rotatedCertFile: certFile, rotatedKeyFile: keyFile} {
+		data, err := os.ReadFile(src)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(dst, data, 0o600))
+		later := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(dst, later, later))
+	}
+	cert, err = tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second.Raw, cert.Certificate[0])
+
+	// A broken rotation keeps the previous certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("partial"), 0o600))
+	later := time.Now().Add(2 * time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	cert, err = tlsConfig.GetClientCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second.Raw, cert.Certificate[0])
+}
@@ -0,0 +1,63 @@
+package masker
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// MaskTraces masks the attributes of every resource, span, and span event in td in place,
+// using the same fields, patterns, and tokens as log records so masked values
+// still correlate across signals
+func (m *Masker) MaskTraces(ctx context.Context, td ptrace.Traces) {
+	defer m.observeSince(time.Now())
+
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+			for k := 0; k < ss.Spans().Len(); k++ {
+				m.MaskSpan(ctx, ss.Spans().At(k))
+			}
+		}
+		m.maskResource(ctx, rs.Resource())
+	}
+
+	if err := m.FlushAccess(ctx); err != nil {
+		m.logWarn("Failed to record mapping access counts", err)
+	}
+}
+
+// MaskSpan masks the attributes of span, and of its events and links as
+// configured, in place. Companion and provenance attributes are added to the
+// span itself.
+func (m *Masker) MaskSpan(ctx context.Context, span ptrace.Span) {
+	if m.config.DetectOnly.Enabled {
+		return
+	}
+	if m.degradation.active(stepDetectOnly) {
+		span.Attributes().PutStr(degradationAttribute, stepDetectOnly)
+		return
+	}
+
+	lookups := &lookupCollector{masker: m}
+	onMask := lookups.onMask()
+
+	maskedKeys := m.maskAttributes(ctx, span.Attributes(), onMask)
+
+	// Exception events in particular carry messages and stack traces
+	scanAll := m.config.ScanAllAttributes || m.config.Spans.ScanAll
+	if m.config.Spans.Events {
+		for i := 0; i < span.Events().Len(); i++ {
+			m.scanAttributes(ctx, span.Events().At(i).Attributes(), scanAll, onMask)
+		}
+	}
+	if m.config.Spans.Links {
+		for i := 0; i < span.Links().Len(); i++ {
+			m.scanAttributes(ctx, span.Links().At(i).Attributes(), scanAll, onMask)
+		}
+	}
+
+	m.annotate(span.Attributes(), maskedKeys, lookups.urls)
+}
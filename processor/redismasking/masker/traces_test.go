@@ -0,0 +1,105 @@
+package masker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestMaskTraces(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.FieldsToMask = []string{"enduser.id"}
+	cfg.ScanAllAttributes = true
+	cfg.Provenance.Policy = "pci-v3"
+	m, _ := newTestMasker(t, &cfg)
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("enduser.id", "alice")
+	span.Attributes().PutStr("net.peer.ip", "192.168.1.1")
+	event := span.Events().AppendEmpty()
+	event.Attributes().PutStr("exception.message", "connection to 192.168.1.2 refused")
+
+	m.MaskTraces(context.Background(), td)
+
+	// Span tokens match the tokens of the same values in logs
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("enduser.id", "alice")
+	m.MaskLogs(context.Background(), ld)
+
+	logUser, _ := lr.Attributes().Get("enduser.id")
+	spanUser, _ := span.Attributes().Get("enduser.id")
+	assert.Equal(t, logUser.Str(), spanUser.Str())
+
+	peer, _ := span.Attributes().Get("net.peer.ip")
+	assert.Equal(t, m.generateMaskedValue("192.168.1.1", "ipv4"), peer.Str())
+
+	message, _ := event.Attributes().Get("exception.message")
+	assert.Equal(t, "connection to "+m.generateMaskedValue("192.168.1.2", "ipv4")+" refused", message.Str())
+
+	policy, _ := span.Attributes().Get("masking.policy")
+	assert.Equal(t, "pci-v3", policy.Str())
+}
+
+func TestMaskSpanEventsAndLinks(t *testing.T) {
+	newSpan := func() ptrace.Span {
+		span := ptrace.NewSpan()
+		span.Events().AppendEmpty().Attributes().PutStr("exception.message", "user alice@example.com not found on 192.168.1.1")
+		span.Links().AppendEmpty().Attributes().PutStr("peer", "192.168.1.2")
+		return span
+	}
+
+	t.Run("default", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Patterns = ipv4Patterns()
+		cfg.ScanAllAttributes = true
+		m, _ := newTestMasker(t, &cfg)
+
+		span := newSpan()
+		m.MaskSpan(context.Background(), span)
+
+		message, _ := span.Events().At(0).Attributes().Get("exception.message")
+		assert.Equal(t, "user alice@example.com not found on "+m.generateMaskedValue("192.168.1.1", "ipv4"), message.Str())
+		peer, _ := span.Links().At(0).Attributes().Get("peer")
+		assert.Equal(t, "192.168.1.2", peer.Str())
+	})
+
+	t.Run("scan events and links", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Patterns = ipv4Patterns()
+		cfg.Spans = SpanConfig{Events: true, Links: true, ScanAll: true}
+		m, _ := newTestMasker(t, &cfg)
+
+		span := newSpan()
+		span.Attributes().PutStr("net.peer.ip", "192.168.1.3")
+		m.MaskSpan(context.Background(), span)
+
+		message, _ := span.Events().At(0).Attributes().Get("exception.message")
+		assert.Equal(t, "user alice@example.com not found on "+m.generateMaskedValue("192.168.1.1", "ipv4"), message.Str())
+		peer, _ := span.Links().At(0).Attributes().Get("peer")
+		assert.Equal(t, m.generateMaskedValue("192.168.1.2", "ipv4"), peer.Str())
+
+		// Span attributes still follow scan_all_attributes
+		spanPeer, _ := span.Attributes().Get("net.peer.ip")
+		assert.Equal(t, "192.168.1.3", spanPeer.Str())
+	})
+
+	t.Run("events disabled", func(t *testing.T) {
+		cfg := NewDefaultConfig()
+		cfg.Patterns = ipv4Patterns()
+		cfg.ScanAllAttributes = true
+		cfg.Spans.Events = false
+		m, _ := newTestMasker(t, &cfg)
+
+		span := newSpan()
+		m.MaskSpan(context.Background(), span)
+
+		message, _ := span.Events().At(0).Attributes().Get("exception.message")
+		assert.Equal(t, "user alice@example.com not found on 192.168.1.1", message.Str())
+	})
+}
@@ -0,0 +1,243 @@
+package masker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// watchedKey is the hash holding the watched tokens by their unmask key
+const watchedKey = "mask:watched"
+
+// WatchlistConfig defines the handling of tokens flagged as of interest, e.g.
+// because they are part of an active investigation
+type WatchlistConfig struct {
+	// Enabled loads the watched tokens and reports when they are seen
+	Enabled bool `mapstructure:"enabled"`
+
+	// RefreshInterval is how often the watched tokens are reloaded from Redis
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+
+	// TTL is the retention of the mappings of watched tokens, which is renewed
+	// whenever they are seen (0 = no expiration)
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// Validate checks the refresh interval and TTL
+func (cfg *WatchlistConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.RefreshInterval <= 0 {
+		return errors.New("watchlist refresh_interval must be positive")
+	}
+	if cfg.TTL < 0 {
+		return errors.New("watchlist ttl must be non-negative")
+	}
+	return nil
+}
+
+// WatchedToken is a token flagged as of interest
+type WatchedToken struct {
+	// Category is the namespaced category of the token, e.g. "vendor_x/ipv4"
+	Category string `json:"category"`
+
+	// Token is the flagged token
+	Token string `json:"token"`
+
+	// Operator is who flagged the token
+	Operator string `json:"operator"`
+
+	// Reason is why the token was flagged, e.g. an incident number
+	Reason string `json:"reason,omitempty"`
+
+	// FlaggedAt is when the token was flagged
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// Watchlist is implemented by stores that keep the tokens of interest
+type Watchlist interface {
+	// Watch flags a token, replacing an earlier flag of the same token
+	Watch(ctx context.Context, token WatchedToken) error
+
+	// Unwatch removes the flag of a token and reports whether it was flagged
+	Unwatch(ctx context.Context, category, token string) (bool, error)
+
+	// Watched returns every flagged token
+	Watched(ctx context.Context) ([]WatchedToken, error)
+}
+
+var _ Watchlist = (*redisStore)(nil)
+
+// Watch stores the flag under the unmask key of the token
+func (s *redisStore) Watch(ctx context.Context, token WatchedToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to encode watched token: %w", err)
+	}
+	if err := s.client.HSet(ctx, watchedKey, UnmaskKey(token.Category, token.Token), data).Err(); err != nil {
+		return fmt.Errorf("redis watch error: %w", err)
+	}
+	return nil
+}
+
+// Unwatch deletes the flag of the token
+func (s *redisStore) Unwatch(ctx context.Context, category, token string) (bool, error) {
+	deleted, err := s.client.HDel(ctx, watchedKey, UnmaskKey(category, token)).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis unwatch error: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+// Watched loads every flag
+func (s *redisStore) Watched(ctx context.Context) ([]WatchedToken, error) {
+	flags, err := s.client.HGetAll(ctx, watchedKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("redis watched error: %w", err)
+	}
+
+	tokens := make([]WatchedToken, 0, len(flags))
+	for _, data := range flags {
+		var token WatchedToken
+		if err := json.Unmarshal([]byte(data), &token); err != nil {
+			return nil, fmt.Errorf("failed to decode watched token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// WithWatchlist renews the mappings of the tokens flagged in w and reports
+// whenever they are seen, when the watchlist is enabled
+func WithWatchlist(w Watchlist) Option {
+	return func(m *Masker) {
+		m.watchSource = w
+	}
+}
+
+// watcher keeps a local copy of the watched tokens, so checking a token never
+// waits on Redis. The copy is reloaded in the background and swapped whole.
+type watcher struct {
+	source  Watchlist
+	cfg     *WatchlistConfig
+	now     func() time.Time
+	onError func(error)
+
+	tokens    atomic.Pointer[map[string]struct{}]
+	loadedAt  atomic.Int64
+	reloading atomic.Bool
+	reloads   sync.WaitGroup
+
+	// renewedAt is when the mapping of each watched token was last renewed
+	mu        sync.Mutex
+	renewedAt map[string]time.Time
+}
+
+func newWatcher(source Watchlist, cfg *WatchlistConfig, onError func(error)) *watcher {
+	w := &watcher{
+		source:    source,
+		cfg:       cfg,
+		now:       time.Now,
+		onError:   onError,
+		renewedAt: map[string]time.Time{},
+	}
+	w.tokens.Store(&map[string]struct{}{})
+	return w
+}
+
+// check reports whether the token stored under unmaskKey is watched and whether
+// its mapping is due for renewal. Renewals are limited to one per refresh
+// interval per token. Once the interval has passed, a single reload is started
+// in the background and the current tokens are checked meanwhile.
+func (w *watcher) check(ctx context.Context, unmaskKey string) (watched, renew bool) {
+	now := w.now()
+
+	if now.Sub(time.Unix(0, w.loadedAt.Load())) >= w.cfg.RefreshInterval && w.reloading.CompareAndSwap(false, true) {
+		// The load time is advanced on failure as well, so an outage does not
+		// cause a reload attempt per value
+		w.loadedAt.Store(now.UnixNano())
+		w.reloads.Add(1)
+		go func() {
+			defer w.reloads.Done()
+			defer w.reloading.Store(false)
+			if err := w.reload(context.WithoutCancel(ctx)); err != nil {
+				w.onError(err)
+			}
+		}()
+	}
+
+	if _, watched = (*w.tokens.Load())[unmaskKey]; !watched {
+		return false, false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if renewedAt, ok := w.renewedAt[unmaskKey]; ok && now.Sub(renewedAt) < w.cfg.RefreshInterval {
+		return true, false
+	}
+	w.renewedAt[unmaskKey] = now
+	return true, true
+}
+
+// reload replaces the watched tokens with those of the source. A failed reload
+// keeps the previous tokens. Reloads taking longer than the refresh interval
+// are abandoned.
+func (w *watcher) reload(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.RefreshInterval)
+	defer cancel()
+
+	watched, err := w.source.Watched(ctx)
+	if err != nil {
+		return err
+	}
+
+	tokens := make(map[string]struct{}, len(watched))
+	for _, token := range watched {
+		tokens[UnmaskKey(token.Category, token.Token)] = struct{}{}
+	}
+
+	w.mu.Lock()
+	for key := range w.renewedAt {
+		if _, ok := tokens[key]; !ok {
+			delete(w.renewedAt, key)
+		}
+	}
+	w.mu.Unlock()
+	w.tokens.Store(&tokens)
+	return nil
+}
+
+// observeWatched reports a sighting of token when it is watched and renews its
+// mapping, without ever exposing originalValue. Nothing is reported under the
+// deterministic degradation step, which must not access Redis, or while the
+// store is unavailable.
+func (m *Masker) observeWatched(ctx context.Context, originalValue, category, token string) {
+	if m.watcher == nil || m.degradation.active(stepDeterministic) || m.storeCause.Load() != nil {
+		return
+	}
+
+	storeCategory := m.namespaced(category)
+	watched, renew := m.watcher.check(ctx, UnmaskKey(storeCategory, token))
+	if !watched {
+		return
+	}
+
+	m.telemetry.recordWatchedSighting(ctx, storeCategory, token)
+	if renew {
+		ttl := m.config.Watchlist.TTL
+		if err := m.store.Set(ctx, MaskKey(storeCategory, originalValue), token, ttl); err != nil {
+			m.logError("Failed to renew watched mapping", err)
+			return
+		}
+		if err := m.store.Set(ctx, UnmaskKey(storeCategory, token), originalValue, ttl); err != nil {
+			m.logError("Failed to renew watched mapping", err)
+		}
+	}
+}
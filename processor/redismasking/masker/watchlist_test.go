@@ -0,0 +1,134 @@
+package masker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestWatchlist(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Patterns = ipv4Patterns()
+	cfg.TokenTTL = 3600
+	cfg.Watchlist = WatchlistConfig{Enabled: true, RefreshInterval: time.Minute, TTL: 90 * 24 * time.Hour}
+	plain, server := newTestMasker(t, &cfg)
+
+	reader := sdkmetric.NewManualReader()
+	m, err := New(&cfg, plain.store, zap.NewNop(),
+		WithWatchlist(plain.store.(Watchlist)),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+	now := time.Now()
+	m.watcher.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	token, err := m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	m.watcher.reloads.Wait()
+	assert.Equal(t, time.Hour, server.TTL(MaskKey("ipv4", "192.168.1.1")))
+
+	// A value checked once the interval has passed starts a reload
+	reload := func() {
+		now = now.Add(time.Minute)
+		_, err := m.MaskValue(ctx, "192.168.1.3", "ipv4")
+		require.NoError(t, err)
+		m.watcher.reloads.Wait()
+	}
+
+	// Flags are picked up with the next reload
+	watchlist := m.store.(Watchlist)
+	require.NoError(t, watchlist.Watch(ctx, WatchedToken{Category: "ipv4", Token: token, Operator: "jdoe", FlaggedAt: now}))
+	_, err = m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, server.TTL(MaskKey("ipv4", "192.168.1.1")))
+
+	reload()
+	for i := 0; i < 3; i++ {
+		_, err = m.MaskValue(ctx, "192.168.1.1", "ipv4")
+		require.NoError(t, err)
+	}
+	_, err = m.MaskValue(ctx, "192.168.1.2", "ipv4")
+	require.NoError(t, err)
+
+	// Both directions of the mapping are renewed with the watchlist TTL
+	assert.Equal(t, 90*24*time.Hour, server.TTL(MaskKey("ipv4", "192.168.1.1")))
+	assert.Equal(t, 90*24*time.Hour, server.TTL(UnmaskKey("ipv4", token)))
+	assert.Equal(t, time.Hour, server.TTL(MaskKey("ipv4", "192.168.1.2")))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	// Token lookups and creations are reported too
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 3)
+	sightings := rm.ScopeMetrics[0].Metrics[0]
+	assert.Equal(t, "redismasking.watched_token.sightings", sightings.Name)
+	points := sightings.Data.(metricdata.Sum[int64]).DataPoints
+	require.Len(t, points, 1)
+	assert.Equal(t, int64(3), points[0].Value)
+	tokenAttr, _ := points[0].Attributes.Value("token")
+	assert.Equal(t, token, tokenAttr.AsString())
+
+	// Unflagged tokens are no longer reported after the next reload
+	found, err := watchlist.Unwatch(ctx, "ipv4", token)
+	require.NoError(t, err)
+	assert.True(t, found)
+	reload()
+	_, err = m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	require.NoError(t, reader.Collect(ctx, &rm))
+	assert.Equal(t, int64(3), rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64]).DataPoints[0].Value)
+}
+
+func TestWatchlistValidate(t *testing.T) {
+	cfg := WatchlistConfig{RefreshInterval: -1}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Enabled = true
+	require.EqualError(t, cfg.Validate(), "watchlist refresh_interval must be positive")
+
+	cfg.RefreshInterval = time.Minute
+	cfg.TTL = -1
+	require.EqualError(t, cfg.Validate(), "watchlist ttl must be non-negative")
+}
+
+// blockingWatchlist holds every load until release is closed
+type blockingWatchlist struct {
+	Watchlist
+	release chan struct{}
+}
+
+func (w *blockingWatchlist) Watched(ctx context.Context) ([]WatchedToken, error) {
+	<-w.release
+	return w.Watchlist.Watched(ctx)
+}
+
+func TestWatchlistReloadInBackground(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Watchlist = WatchlistConfig{Enabled: true, RefreshInterval: time.Minute}
+	plain, _ := newTestMasker(t, &cfg)
+	ctx := context.Background()
+	require.NoError(t, plain.store.(Watchlist).Watch(ctx, WatchedToken{Category: "ipv4", Token: "10.1.2.3"}))
+
+	source := &blockingWatchlist{Watchlist: plain.store.(Watchlist), release: make(chan struct{})}
+	var loadErrs []error
+	w := newWatcher(source, &cfg.Watchlist, func(err error) { loadErrs = append(loadErrs, err) })
+
+	// Checks use the current tokens while the reload waits on the source
+	for i := 0; i < 3; i++ {
+		watched, _ := w.check(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+		assert.False(t, watched)
+	}
+
+	close(source.release)
+	w.reloads.Wait()
+	watched, renew := w.check(ctx, UnmaskKey("ipv4", "10.1.2.3"))
+	assert.True(t, watched)
+	assert.True(t, renew)
+	assert.Empty(t, loadErrs)
+}
@@ -0,0 +1,166 @@
+package masker
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+)
+
+// WriteBehindConfig defines the asynchronous persistence of new mappings
+type WriteBehindConfig struct {
+	// Enabled returns derived tokens without waiting for the store and
+	// persists their mappings in the background
+	Enabled bool `mapstructure:"enabled"`
+
+	// QueueSize bounds the mappings waiting to be persisted. Mappings arriving
+	// while the queue is full are not persisted until their value is seen again.
+	QueueSize int `mapstructure:"queue_size"`
+
+	// Workers is the number of mappings persisted concurrently
+	Workers int `mapstructure:"workers"`
+}
+
+// Validate checks that the queue and workers are positive
+func (cfg *WriteBehindConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.QueueSize <= 0 {
+		return errors.New("write_behind queue_size must be positive")
+	}
+	if cfg.Workers <= 0 {
+		return errors.New("write_behind workers must be positive")
+	}
+	return nil
+}
+
+var (
+	// errWriteBehindQueueFull is the cause of mappings dropped from a full queue
+	errWriteBehindQueueFull = errors.New("write-behind queue is full")
+
+	// errWriteBehindConflict is the cause of tokens that lost to a stored mapping
+	errWriteBehindConflict = errors.New("stored mapping differs from the derived token")
+)
+
+// pendingMapping is a mapping waiting to be persisted
+type pendingMapping struct {
+	original string
+	category string
+	token    string
+}
+
+// writeBehind persists new mappings from a bounded queue. Values seen again
+// while their mapping is queued, or after it was persisted, are not queued
+// again until they are evicted from the recently queued values.
+type writeBehind struct {
+	queue chan pendingMapping
+	done  sync.WaitGroup
+
+	// mu guards recent and closed, and is held by sends, so the queue is only
+	// closed once no send is in progress
+	mu     sync.Mutex
+	recent *simplelru.LRU[string, struct{}]
+	closed bool
+}
+
+// startWriteBehind starts the workers persisting the mappings of m
+func (m *Masker) startWriteBehind(cfg *WriteBehindConfig) error {
+	recent, err := simplelru.NewLRU[string, struct{}](cfg.QueueSize, nil)
+	if err != nil {
+		return err
+	}
+	w := &writeBehind{
+		queue:  make(chan pendingMapping, cfg.QueueSize),
+		recent: recent,
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		w.done.Add(1)
+		go func() {
+			defer w.done.Done()
+			for mapping := range w.queue {
+				m.persistMapping(context.Background(), mapping)
+			}
+		}()
+	}
+	m.writeBehind = w
+	return nil
+}
+
+// maskWriteBehind returns the derived token of originalValue and queues its
+// mapping within the namespaced category
+func (m *Masker) maskWriteBehind(ctx context.Context, originalValue, category string) string {
+	mapping := pendingMapping{
+		original: originalValue,
+		category: m.namespaced(category),
+		token:    m.generateMaskedValue(originalValue, category),
+	}
+	key := MaskKey(mapping.category, originalValue)
+	w := m.writeBehind
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		// Values masked after the flush are persisted right away
+		m.persistMapping(ctx, mapping)
+		return mapping.token
+	}
+	if _, queued := w.recent.Get(key); queued {
+		w.mu.Unlock()
+		return mapping.token
+	}
+	w.recent.Add(key, struct{}{})
+
+	select {
+	case w.queue <- mapping:
+	default:
+		w.recent.Remove(key)
+		m.logWarn("Dropped mapping from the write-behind queue", errWriteBehindQueueFull)
+		m.recordFallback(ctx, fallbackMappingNotStored, errWriteBehindQueueFull)
+	}
+	w.mu.Unlock()
+	return mapping.token
+}
+
+// persistMapping stores a queued mapping, keeping the first mapping of the
+// value when another collector stored one already. The token was handed out
+// already, so one whose reverse mapping holds another value is not persisted.
+func (m *Masker) persistMapping(ctx context.Context, mapping pendingMapping) {
+	winner, err := m.createMapping(ctx, mapping.original, mapping.category, mapping.token)
+	if err == nil && winner != mapping.token {
+		err = errWriteBehindConflict
+	}
+	if err != nil {
+		m.logWarn("Failed to persist write-behind token", err)
+		m.recordFallback(ctx, fallbackMappingNotStored, err)
+	}
+}
+
+// FlushWrites persists the queued mappings and stops the write-behind workers.
+// It is called on shutdown, and returns the error of ctx when it ends first.
+func (m *Masker) FlushWrites(ctx context.Context) error {
+	w := m.writeBehind
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
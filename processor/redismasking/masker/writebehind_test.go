@@ -0,0 +1,171 @@
+package masker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// gatedStore is an in-memory Store whose writes wait until release is closed
+type gatedStore struct {
+	mu      sync.Mutex
+	data    map[string]string
+	writes  int
+	started chan struct{}
+	release chan struct{}
+}
+
+func newGatedStore() *gatedStore {
+	return &gatedStore{
+		data:    make(map[string]string),
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (s *gatedStore) Get(_ context.Context, key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data[key]
+	return value, ok, nil
+}
+
+func (s *gatedStore) Set(_ context.Context, key, value string, _ time.Duration) error {
+	s.mu.Lock()
+	if s.writes == 0 {
+		close(s.started)
+	}
+	s.writes++
+	s.mu.Unlock()
+	<-s.release
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *gatedStore) Close() error {
+	return nil
+}
+
+// newWriteBehindMasker creates a Masker persisting to store from a queue of queueSize
+func newWriteBehindMasker(t *testing.T, store Store, queueSize int) *Masker {
+	t.Helper()
+
+	cfg := NewDefaultConfig()
+	cfg.HMACKey = "secret"
+	cfg.WriteBehind = WriteBehindConfig{Enabled: true, QueueSize: queueSize, Workers: 1}
+	require.NoError(t, cfg.Validate())
+
+	m, err := New(&cfg, store, zap.NewNop())
+	require.NoError(t, err)
+	return m
+}
+
+func TestWriteBehind(t *testing.T) {
+	store := newGatedStore()
+	m := newWriteBehindMasker(t, store, 10)
+	ctx := context.Background()
+
+	// Tokens are returned while the store blocks
+	token, err := m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	require.Equal(t, m.generateMaskedValue("192.168.1.1", "ipv4"), token)
+	<-store.started
+
+	// Values seen again while queued are not queued twice
+	again, err := m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	require.Equal(t, token, again)
+	require.Empty(t, m.writeBehind.queue)
+
+	close(store.release)
+	require.NoError(t, m.FlushWrites(ctx))
+	require.Equal(t, 2, store.writes)
+
+	value, found, err := store.Get(ctx, MaskKey("ipv4", "192.168.1.1"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, token, value)
+	original, found, err := store.Get(ctx, UnmaskKey("ipv4", token))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "192.168.1.1", original)
+
+	// Values masked after the flush are persisted right away
+	token, err = m.MaskValue(ctx, "192.168.1.2", "ipv4")
+	require.NoError(t, err)
+	value, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.2"))
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, token, value)
+}
+
+func TestWriteBehindQueueFull(t *testing.T) {
+	store := newGatedStore()
+	m := newWriteBehindMasker(t, store, 1)
+	ctx := context.Background()
+
+	// The worker blocks on the first mapping and the second fills the queue
+	for _, value := range []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"} {
+		_, err := m.MaskValue(ctx, value, "ipv4")
+		require.NoError(t, err)
+		if value == "192.168.1.1" {
+			<-store.started
+		}
+	}
+
+	close(store.release)
+	require.NoError(t, m.FlushWrites(ctx))
+	_, found, err := store.Get(ctx, MaskKey("ipv4", "192.168.1.2"))
+	require.NoError(t, err)
+	require.True(t, found)
+	_, found, err = store.Get(ctx, MaskKey("ipv4", "192.168.1.3"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestWriteBehindFlushTimeout(t *testing.T) {
+	store := newGatedStore()
+	m := newWriteBehindMasker(t, store, 10)
+	ctx := context.Background()
+
+	_, err := m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	<-store.started
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, m.FlushWrites(canceled), context.Canceled)
+
+	close(store.release)
+	require.NoError(t, m.FlushWrites(ctx))
+}
+
+func TestWriteBehindTokenTaken(t *testing.T) {
+	store, err := NewMemoryStore(&MemoryStoreConfig{})
+	require.NoError(t, err)
+	m := newWriteBehindMasker(t, store, 10)
+	ctx := context.Background()
+
+	// The token was handed out already, so it is not persisted over the
+	// reverse mapping of another value
+	token := m.generateMaskedValue("192.168.1.1", "ipv4")
+	require.NoError(t, store.Set(ctx, UnmaskKey("ipv4", token), "192.168.1.9", 0))
+	masked, err := m.MaskValue(ctx, "192.168.1.1", "ipv4")
+	require.NoError(t, err)
+	require.Equal(t, token, masked)
+	require.NoError(t, m.FlushWrites(ctx))
+
+	original, _, err := store.Get(ctx, UnmaskKey("ipv4", token))
+	require.NoError(t, err)
+	require.Equal(t, "192.168.1.9", original)
+	_, found, err := store.Get(ctx, MaskKey("ipv4", "192.168.1.1"))
+	require.NoError(t, err)
+	require.False(t, found)
+}
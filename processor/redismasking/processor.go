@@ -2,212 +2,294 @@ package redismasking
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"regexp"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/replication"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/storeextension"
 	"go.opentelemetry.io/collector/component"
-	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/extension/xextension/storage"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pprofile"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/collector/processor/processorhelper"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
 )
 
 type maskingProcessor struct {
-	config       *Config
-	logger       *zap.Logger
-	redisClient  *redis.Client
-	compiledPatterns []*compiledPattern
+	config        *Config
+	logger        *zap.Logger
+	meterProvider metric.MeterProvider
+	store         masker.Store
+	publisher     *replication.Publisher
+	masker        *masker.Masker
+
+	// sharedStore is set when the store is owned by a store extension
+	sharedStore bool
+
+	// id and signal name the storage client of the processor, so every
+	// pipeline of the processor keeps its own mappings in a storage extension
+	id     component.ID
+	signal pipeline.Signal
+
+	// stopConnect stops the background connection to Redis, which closes
+	// connected once it has ended
+	stopConnect context.CancelFunc
+	connected   chan struct{}
 }
 
-type compiledPattern struct {
-	name         string
-	regex        *regexp.Regexp
-	maskedPrefix string
+func newMaskingProcessor(config *Config, set component.TelemetrySettings) *maskingProcessor {
+	return &maskingProcessor{
+		config:        config,
+		logger:        set.Logger,
+		meterProvider: set.MeterProvider,
+	}
 }
 
-func newMaskingProcessor(config *Config, logger *zap.Logger) (*maskingProcessor, error) {
-	// Compile regex patterns
-	compiledPatterns := make([]*compiledPattern, 0, len(config.Patterns))
-	for _, pattern := range config.Patterns {
-		regex, err := regexp.Compile(pattern.Regex)
+func (mp *maskingProcessor) start(ctx context.Context, host component.Host) error {
+	opts := []masker.Option{masker.WithMeterProvider(mp.meterProvider)}
+	var pinger masker.Pinger
+	var connectErr error
+	if mp.config.StoreExtension != nil {
+		provider, err := storeextension.GetProvider(host, *mp.config.StoreExtension)
+		if err != nil {
+			return err
+		}
+		mp.logger.Info("Using shared token store", zap.String("extension", mp.config.StoreExtension.String()))
+
+		if tracker := provider.AccessTracker(); tracker != nil && mp.config.AccessTrackingEnabled() {
+			opts = append(opts, masker.WithAccessTracker(tracker))
+		}
+		if watchlist := provider.Watchlist(); watchlist != nil && mp.config.Watchlist.Enabled {
+			opts = append(opts, masker.WithWatchlist(watchlist))
+		}
+		mp.store = provider.Store()
+		mp.sharedStore = true
+	} else if mp.config.Storage != nil {
+		store, err := mp.newStorageStore(ctx, host)
+		if err != nil {
+			return err
+		}
+		mp.logger.Info("Using storage extension", zap.String("extension", mp.config.Storage.String()))
+
+		if mp.config.LocalCacheSize > 0 {
+			store = masker.NewCachedStore(store, mp.config.LocalCacheSize, time.Duration(mp.config.TokenTTL)*time.Second)
+		}
+		mp.store = store
+	} else if mp.config.StoreEnabled() && !mp.config.RedisStoreEnabled() {
+		store, err := mp.config.NewStore(mp.logger)
+		if err != nil {
+			return err
+		}
+		if server, ok := store.(masker.Pinger); ok {
+			if err := server.Ping(ctx); err != nil {
+				_ = store.Close()
+				return err
+			}
+		}
+		mp.logger.Info("Using token store", zap.String("store", mp.config.Store))
+
+		if mp.config.LocalCacheSize > 0 {
+			store = masker.NewCachedStore(store, mp.config.LocalCacheSize, time.Duration(mp.config.TokenTTL)*time.Second)
+		}
+		mp.store = store
+	} else if mp.config.StoreEnabled() {
+		store, err := masker.NewLazyRedisStore(&mp.config.Config)
+		if err != nil {
+			return err
+		}
+		pinger = store.(masker.Pinger)
+		if connectErr = pinger.Ping(ctx); connectErr != nil {
+			if !mp.config.LazyConnect.Enabled {
+				_ = store.Close()
+				return connectErr
+			}
+			mp.logger.Warn("Starting without Redis, connecting in the background", zap.Error(connectErr))
+		} else {
+			mp.logger.Info("Connected to Redis successfully", zap.String("addr", mp.config.RedisAddr))
+		}
+
+		if tracker, ok := store.(masker.AccessTracker); ok && mp.config.AccessTrackingEnabled() {
+			opts = append(opts, masker.WithAccessTracker(tracker))
+		}
+		if watchlist, ok := store.(masker.Watchlist); ok && mp.config.Watchlist.Enabled {
+			opts = append(opts, masker.WithWatchlist(watchlist))
+		}
+		cacheTTL := time.Duration(mp.config.TokenTTL) * time.Second
+		switch {
+		case mp.config.LocalCacheInvalidation:
+			watched, err := masker.NewWatchedCachedStore(store, mp.config.LocalCacheSize, cacheTTL)
+			if err != nil {
+				_ = store.Close()
+				return err
+			}
+			store = watched
+		case mp.config.LocalCacheSize > 0:
+			store = masker.NewCachedStore(store, mp.config.LocalCacheSize, cacheTTL)
+		}
+		mp.store = store
+	} else {
+		mp.logger.Info("Running in lightweight mode, Redis is disabled")
+	}
+
+	if mp.config.Replication.Enabled() {
+		publisher, err := replication.NewPublisher(&mp.config.Replication, mp.logger, mp.meterProvider)
 		if err != nil {
-			return nil, fmt.Errorf("failed to compile regex pattern '%s': %w", pattern.Name, err)
+			return fmt.Errorf("failed to create replication publisher: %w", err)
 		}
-		compiledPatterns = append(compiledPatterns, &compiledPattern{
-			name:         pattern.Name,
-			regex:        regex,
-			maskedPrefix: pattern.MaskedPrefix,
-		})
+		mp.publisher = publisher
+		opts = append(opts, masker.WithMappingPublisher(publisher))
 	}
-	
-	return &maskingProcessor{
-		config:           config,
-		logger:           logger,
-		compiledPatterns: compiledPatterns,
-	}, nil
-}
 
-func (mp *maskingProcessor) start(ctx context.Context, host component.Host) error {
-	// Initialize Redis client
-	mp.redisClient = redis.NewClient(&redis.Options{
-		Addr:     mp.config.RedisAddr,
-		Password: mp.config.RedisPassword,
-		DB:       mp.config.RedisDB,
-	})
-	
-	// Test connection
-	_, err := mp.redisClient.Ping(ctx).Result()
+	if fields := mp.config.SemconvFields(); len(fields) > 0 {
+		mp.logger.Info("Masking semantic convention fields", zap.Strings("fields", fields))
+	}
+
+	m, err := masker.New(&mp.config.Config, mp.store, mp.logger, opts...)
 	if err != nil {
-		return fmt.Errorf("failed to connect to Redis: %w", err)
+		return fmt.Errorf("failed to create masker: %w", err)
 	}
-	
-	mp.logger.Info("Connected to Redis successfully", zap.String("addr", mp.config.RedisAddr))
-	return nil
-}
+	mp.masker = m
+	mp.logger.Info("Loaded masking configuration", zap.String("fingerprint", m.Fingerprint()))
 
-func (mp *maskingProcessor) shutdown(ctx context.Context) error {
-	if mp.redisClient != nil {
-		return mp.redisClient.Close()
+	if connectErr != nil {
+		m.SetStoreError(connectErr)
+		componentstatus.ReportStatus(host, componentstatus.NewRecoverableErrorEvent(connectErr))
+		mp.connect(pinger, host)
+		return nil
 	}
+
+	if err := m.CheckSalt(ctx); err != nil {
+		return err
+	}
+
+	mp.warmup(ctx)
 	return nil
 }
 
-func (mp *maskingProcessor) processLogs(ctx context.Context, ld plog.Logs) (plog.Logs, error) {
-	for i := 0; i < ld.ResourceLogs().Len(); i++ {
-		rl := ld.ResourceLogs().At(i)
-		for j := 0; j < rl.ScopeLogs().Len(); j++ {
-			sl := rl.ScopeLogs().At(j)
-			for k := 0; k < sl.LogRecords().Len(); k++ {
-				lr := sl.LogRecords().At(k)
-				if err := mp.maskLogRecord(ctx, lr); err != nil {
-					mp.logger.Error("Failed to mask log record", zap.Error(err))
-				}
-			}
-		}
+// warmup loads the most used mappings into the local cache when warmup_top_n is
+// set. A failed warm-up only costs latency, so it does not prevent startup.
+func (mp *maskingProcessor) warmup(ctx context.Context) {
+	if mp.config.WarmupTopN <= 0 {
+		return
+	}
+	loaded, err := mp.masker.Warmup(ctx, mp.config.WarmupTopN)
+	if err != nil {
+		mp.logger.Warn("Failed to warm up local cache", zap.Error(err))
+	} else {
+		mp.logger.Info("Warmed up local cache", zap.Int("mappings", loaded))
 	}
-	return ld, nil
 }
 
-func (mp *maskingProcessor) maskLogRecord(ctx context.Context, lr plog.LogRecord) error {
-	// Mask specific attributes
-	lr.Attributes().Range(func(k string, v pcommon.Value) bool {
-		for _, fieldToMask := range mp.config.FieldsToMask {
-			if k == fieldToMask {
-				maskedValue, err := mp.getMaskedValue(ctx, v.AsString(), "attribute_"+k)
-				if err != nil {
-					mp.logger.Error("Failed to mask attribute", zap.String("key", k), zap.Error(err))
-				} else {
-					v.SetStr(maskedValue)
-				}
-			}
-		}
-		return true
-	})
-	
-	// Mask patterns in log body
-	if lr.Body().Type() == pcommon.ValueTypeStr {
-		originalBody := lr.Body().Str()
-		maskedBody := mp.maskPatternsInString(ctx, originalBody)
-		if maskedBody != originalBody {
-			lr.Body().SetStr(maskedBody)
-		}
+// newStorageStore creates a store on a client of the configured storage extension
+func (mp *maskingProcessor) newStorageStore(ctx context.Context, host component.Host) (masker.Store, error) {
+	ext, ok := host.GetExtensions()[*mp.config.Storage]
+	if !ok {
+		return nil, fmt.Errorf("storage extension '%s' not found", mp.config.Storage)
 	}
-	
-	return nil
+	storageExt, ok := ext.(storage.Extension)
+	if !ok {
+		return nil, fmt.Errorf("non-storage extension '%s' found", mp.config.Storage)
+	}
+
+	client, err := storageExt.GetClient(ctx, component.KindProcessor, mp.id, mp.signal.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage client: %w", err)
+	}
+	return masker.NewStorageStore(client), nil
 }
 
-func (mp *maskingProcessor) maskPatternsInString(ctx context.Context, text string) string {
-	result := text
-	for _, pattern := range mp.compiledPatterns {
-		matches := pattern.regex.FindAllString(result, -1)
-		for _, match := range matches {
-			maskedValue, err := mp.getMaskedValue(ctx, match, pattern.name)
-			if err != nil {
-				mp.logger.Error("Failed to mask value", 
-					zap.String("pattern", pattern.name), 
-					zap.String("value", match),
-					zap.Error(err))
+// connect retries the connection to Redis every retry_interval until it
+// succeeds and the salt check passes, and then marks the store as available,
+// warms up the local cache and reports the processor as healthy
+func (mp *maskingProcessor) connect(pinger masker.Pinger, host component.Host) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mp.stopConnect = cancel
+	mp.connected = make(chan struct{})
+
+	go func() {
+		defer close(mp.connected)
+		ticker := time.NewTicker(mp.config.LazyConnect.RetryInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := pinger.Ping(ctx); err != nil {
+				mp.masker.SetStoreError(err)
+				mp.logger.Debug("Failed to connect to Redis", zap.Error(err))
 				continue
 			}
-			result = regexp.MustCompile(regexp.QuoteMeta(match)).ReplaceAllString(result, maskedValue)
+			// Mappings of another salt must never be mixed into the store, so it
+			// stays unavailable until the salt matches
+			if err := mp.masker.CheckSalt(ctx); err != nil {
+				mp.masker.SetStoreError(err)
+				mp.logger.Warn("Failed to check salt", zap.Error(err))
+				continue
+			}
+			mp.masker.SetStoreError(nil)
+			mp.logger.Info("Connected to Redis successfully", zap.String("addr", mp.config.RedisAddr))
+			mp.warmup(ctx)
+			componentstatus.ReportStatus(host, componentstatus.NewEvent(componentstatus.StatusOK))
+			return
 		}
-	}
-	return result
+	}()
 }
 
-func (mp *maskingProcessor) getMaskedValue(ctx context.Context, originalValue, category string) (string, error) {
-	// Create a unique key for Redis
-	redisKey := fmt.Sprintf("mask:%s:%s", category, originalValue)
-	
-	// Check if masked value already exists in Redis
-	cachedValue, err := mp.redisClient.Get(ctx, redisKey).Result()
-	if err == nil {
-		// Found in cache, return it
-		return cachedValue, nil
-	} else if err != redis.Nil {
-		// Real error occurred
-		return "", fmt.Errorf("redis get error: %w", err)
-	}
-	
-	// Not in cache, generate new masked value
-	maskedValue := mp.generateMaskedValue(originalValue, category)
-	
-	// Store in Redis
-	ttl := time.Duration(0)
-	if mp.config.TokenTTL > 0 {
-		ttl = time.Duration(mp.config.TokenTTL) * time.Second
-	}
-	
-	err = mp.redisClient.Set(ctx, redisKey, maskedValue, ttl).Err()
-	if err != nil {
-		mp.logger.Error("Failed to store masked value in Redis", zap.Error(err))
-		// Continue anyway, we'll use the generated value
-	}
-	
-	// Also store reverse mapping for lookups
-	reverseKey := fmt.Sprintf("unmask:%s:%s", category, maskedValue)
-	_ = mp.redisClient.Set(ctx, reverseKey, originalValue, ttl)
-	
-	return maskedValue, nil
+func (mp *maskingProcessor) shutdown(ctx context.Context) error {
+	var errs error
+	if mp.stopConnect != nil {
+		mp.stopConnect()
+		<-mp.connected
+	}
+	if mp.masker != nil {
+		// Queued mappings are persisted and published before anything closes
+		errs = errors.Join(errs, mp.masker.FlushWrites(ctx))
+	}
+	if mp.publisher != nil {
+		errs = errors.Join(errs, mp.publisher.Close(ctx))
+	}
+	if mp.masker != nil {
+		errs = errors.Join(errs, mp.masker.FlushAccess(ctx))
+		mp.masker.FlushErrors()
+	}
+	if mp.store != nil && !mp.sharedStore {
+		errs = errors.Join(errs, mp.store.Close())
+	}
+	return errs
 }
 
-func (mp *maskingProcessor) generateMaskedValue(originalValue, category string) string {
-	// Generate deterministic hash
-	hash := sha256.Sum256([]byte(originalValue + category))
-	hashStr := hex.EncodeToString(hash[:])
-	
-	// Create masked value based on category
-	// For IP addresses, generate a fake IP format
-	if category == "ipv4" {
-		return fmt.Sprintf("10.%d.%d.%d",
-			hash[0]%256,
-			hash[1]%256,
-			hash[2]%256,
-		)
-	}
-	
-	// For hostnames, generate a fake hostname
-	if category == "hostname" {
-		return fmt.Sprintf("host-%s.masked.local", hashStr[:8])
-	}
-	
-	// For other fields, use prefix + hash
-	prefix := ""
-	for _, pattern := range mp.compiledPatterns {
-		if pattern.name == category {
-			prefix = pattern.maskedPrefix
-			break
-		}
-	}
-	
-	// Extract category from attribute fields
-	if len(category) > 10 && category[:10] == "attribute_" {
-		prefix = category[10:] + "-"
+func (mp *maskingProcessor) processLogs(ctx context.Context, ld plog.Logs) (plog.Logs, error) {
+	mp.masker.MaskLogs(ctx, ld)
+
+	// Stop the pipeline when the policy or the cardholder data rule dropped every record
+	if (mp.config.OPA.Enabled() || mp.config.CardholderData.Enabled) && ld.LogRecordCount() == 0 {
+		return ld, processorhelper.ErrSkipProcessingData
 	}
-	
-	return fmt.Sprintf("%s%s", prefix, hashStr[:12])
+	return ld, nil
 }
 
+func (mp *maskingProcessor) processMetrics(ctx context.Context, md pmetric.Metrics) (pmetric.Metrics, error) {
+	mp.masker.MaskMetrics(ctx, md)
+	return md, nil
+}
+
+func (mp *maskingProcessor) processTraces(ctx context.Context, td ptrace.Traces) (ptrace.Traces, error) {
+	mp.masker.MaskTraces(ctx, td)
+	return td, nil
+}
+
+func (mp *maskingProcessor) processProfiles(ctx context.Context, pd pprofile.Profiles) (pprofile.Profiles, error) {
+	mp.masker.MaskProfiles(ctx, pd)
+	return pd, nil
+}
@@ -2,71 +2,541 @@ package redismasking
 
 import (
 	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/storeextension"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/extension/storage/filestorage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componentstatus"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pprofile"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pipeline"
+	"go.opentelemetry.io/collector/processor/processorhelper"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
-func TestGenerateMaskedValue(t *testing.T) {
-	mp := &maskingProcessor{
-		config: &Config{},
-		logger: zap.NewNop(),
-		compiledPatterns: []*compiledPattern{
-			{
-				name:         "ipv4",
-				maskedPrefix: "IP-",
-			},
-		},
-	}
-	
-	// Test deterministic generation - same input should produce same output
-	value1 := mp.generateMaskedValue("1.2.3.4", "ipv4")
-	value2 := mp.generateMaskedValue("1.2.3.4", "ipv4")
-	assert.Equal(t, value1, value2, "Same input should produce same masked value")
-	
-	// Different inputs should produce different outputs
-	value3 := mp.generateMaskedValue("5.6.7.8", "ipv4")
-	assert.NotEqual(t, value1, value3, "Different inputs should produce different masked values")
-}
-
-func TestMaskPatternsInString(t *testing.T) {
-	mp := &maskingProcessor{
-		config: &Config{},
-		logger: zap.NewNop(),
-	}
-	
-	// Note: This test won't actually mask without Redis, but tests the structure
-	text := "User logged in from 192.168.1.1 on host server01.example.com"
-	result := mp.maskPatternsInString(context.Background(), text)
-	
-	// Without Redis running, it should attempt to mask but may fail gracefully
-	assert.NotEmpty(t, result)
+// newTestProcessor creates a masking processor backed by an in-process Redis server
+func newTestProcessor(t *testing.T, cfg *Config) *maskingProcessor {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	cfg.RedisAddr = server.Addr()
+
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	require.NoError(t, mp.start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, mp.shutdown(context.Background())) })
+
+	return mp
 }
 
 func TestProcessLogs(t *testing.T) {
-	mp := &maskingProcessor{
-		config: &Config{
-			FieldsToMask: []string{"username", "ip_address"},
-		},
-		logger: zap.NewNop(),
-	}
-	
+	cfg := createDefaultConfig().(*Config)
+	cfg.FieldsToMask = []string{"username", "ip_address"}
+	mp := newTestProcessor(t, cfg)
+
 	// Create test log data
 	ld := plog.NewLogs()
 	rl := ld.ResourceLogs().AppendEmpty()
 	sl := rl.ScopeLogs().AppendEmpty()
 	lr := sl.LogRecords().AppendEmpty()
-	
-	lr.Body().SetStr("Test log message with IP 10.0.0.1")
+
 	lr.Attributes().PutStr("username", "testuser")
 	lr.Attributes().PutStr("ip_address", "10.0.0.1")
-	
-	// Process logs (will fail without Redis, but tests structure)
+
 	_, err := mp.processLogs(context.Background(), ld)
 	assert.NoError(t, err)
+
+	username, _ := lr.Attributes().Get("username")
+	assert.NotEqual(t, "testuser", username.Str())
+	ipAddress, _ := lr.Attributes().Get("ip_address")
+	assert.NotEqual(t, "10.0.0.1", ipAddress.Str())
+}
+
+func TestProcessMetrics(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.FieldsToMask = []string{"username"}
+	mp := newTestProcessor(t, cfg)
+
+	md := pmetric.NewMetrics()
+	dp := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty().SetEmptySum().DataPoints().AppendEmpty()
+	dp.Attributes().PutStr("username", "testuser")
+
+	_, err := mp.processMetrics(context.Background(), md)
+	require.NoError(t, err)
+
+	username, _ := dp.Attributes().Get("username")
+	assert.NotEqual(t, "testuser", username.Str())
 }
 
+func TestProcessTraces(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.FieldsToMask = []string{"enduser.id"}
+	mp := newTestProcessor(t, cfg)
+
+	td := ptrace.NewTraces()
+	span := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.Attributes().PutStr("enduser.id", "testuser")
+
+	_, err := mp.processTraces(context.Background(), td)
+	require.NoError(t, err)
+
+	user, _ := span.Attributes().Get("enduser.id")
+	assert.NotEqual(t, "testuser", user.Str())
+}
+
+func TestProcessProfiles(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.FieldsToMask = []string{"host.name"}
+	mp := newTestProcessor(t, cfg)
+
+	pd := pprofile.NewProfiles()
+	resource := pd.ResourceProfiles().AppendEmpty().Resource()
+	resource.Attributes().PutStr("host.name", "db-01")
+
+	_, err := mp.processProfiles(context.Background(), pd)
+	require.NoError(t, err)
+
+	hostName, _ := resource.Attributes().Get("host.name")
+	assert.NotEqual(t, "db-01", hostName.Str())
+}
+
+func TestStartWarmup(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.FieldsToMask = []string{"username"}
+	cfg.LocalCacheSize = 10
+	cfg.WarmupTopN = 5
+	mp := newTestProcessor(t, cfg)
+
+	ld := plog.NewLogs()
+	lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	lr.Attributes().PutStr("username", "testuser")
+
+	_, err := mp.processLogs(context.Background(), ld)
+	require.NoError(t, err)
+
+	username, _ := lr.Attributes().Get("username")
+	assert.NotEqual(t, "testuser", username.Str())
+
+	// A restarted processor warms up from the recorded access counts
+	require.NoError(t, mp.shutdown(context.Background()))
+	require.NoError(t, mp.start(context.Background(), componenttest.NewNopHost()))
+}
+
+func TestProcessLogsAllDropped(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policyFile, []byte("package redismasking\n\ndecision := \"drop\"\n"), 0o600))
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.OPA.PolicyFile = policyFile
+	mp := newTestProcessor(t, cfg)
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("debug output")
+
+	_, err := mp.processLogs(context.Background(), ld)
+	require.ErrorIs(t, err, processorhelper.ErrSkipProcessingData)
+}
+
+func TestStartRedisUnavailable(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.RedisAddr = "127.0.0.1:1"
+
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	err := mp.start(context.Background(), componenttest.NewNopHost())
+	require.ErrorContains(t, err, "failed to connect to Redis")
+	require.NoError(t, mp.shutdown(context.Background()))
+}
+
+// statusHost is a host recording the reported component status
+type statusHost struct {
+	component.Host
+
+	mu       sync.Mutex
+	statuses []componentstatus.Status
+}
+
+func (h *statusHost) Report(event *componentstatus.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statuses = append(h.statuses, event.Status())
+}
+
+func (h *statusHost) lastStatus() componentstatus.Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.statuses) == 0 {
+		return componentstatus.StatusNone
+	}
+	return h.statuses[len(h.statuses)-1]
+}
+
+func TestStartLazyConnect(t *testing.T) {
+	// Reserve an address for a Redis server that is started later
+	server := miniredis.NewMiniRedis()
+	require.NoError(t, server.Start())
+	addr := server.Addr()
+	server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.RedisAddr = addr
+	cfg.FieldsToMask = []string{"username"}
+	cfg.RedisRetry.MaxAttempts = 1
+	cfg.LazyConnect = LazyConnectConfig{Enabled: true, RetryInterval: 10 * time.Millisecond}
+	require.NoError(t, cfg.Validate())
+
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	require.NoError(t, mp.start(context.Background(), host))
+	defer func() { require.NoError(t, mp.shutdown(context.Background())) }()
+	assert.Equal(t, componentstatus.StatusRecoverableError, host.lastStatus())
+
+	processUser := func() string {
+		ld := plog.NewLogs()
+		lr := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+		lr.Attributes().PutStr("username", "testuser")
+		_, err := mp.processLogs(context.Background(), ld)
+		require.NoError(t, err)
+		username, _ := lr.Attributes().Get("username")
+		return username.Str()
+	}
+
+	// Values are masked without Redis while it is unreachable
+	token := processUser()
+	assert.NotEqual(t, "testuser", token)
+
+	require.NoError(t, server.StartAddr(addr))
+	defer server.Close()
+	require.Eventually(t, func() bool {
+		return host.lastStatus() == componentstatus.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The mapping of the token derived earlier is stored once Redis is reachable
+	assert.Equal(t, token, processUser())
+	stored, err := server.Get("mask:attribute_username:testuser")
+	require.NoError(t, err)
+	assert.Equal(t, token, stored)
+
+	// The salt is checked once Redis is reachable
+	recorded, err := server.Get("meta:salt_check")
+	require.NoError(t, err)
+	assert.Equal(t, "none", recorded)
+}
+
+func TestStartLazyConnectWarmup(t *testing.T) {
+	server := miniredis.NewMiniRedis()
+	require.NoError(t, server.Start())
+	addr := server.Addr()
+	server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.RedisAddr = addr
+	cfg.RedisRetry.MaxAttempts = 1
+	cfg.LocalCacheSize = 10
+	cfg.WarmupTopN = 5
+	cfg.LazyConnect = LazyConnectConfig{Enabled: true, RetryInterval: 10 * time.Millisecond}
+	require.NoError(t, cfg.Validate())
+
+	core, logs := observer.New(zap.InfoLevel)
+	set := componenttest.NewNopTelemetrySettings()
+	set.Logger = zap.New(core)
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	mp := newMaskingProcessor(cfg, set)
+	require.NoError(t, mp.start(context.Background(), host))
+	defer func() { require.NoError(t, mp.shutdown(context.Background())) }()
+
+	// The warm-up runs once Redis is reachable, from the counts recorded by
+	// earlier runs
+	require.NoError(t, server.Set("mask:attribute_username:testuser", "user_1"))
+	require.NoError(t, server.Set("unmask:attribute_username:user_1", "testuser"))
+	_, err := server.ZAdd("meta:token_access_counts", 3, "unmask:attribute_username:user_1")
+	require.NoError(t, err)
+	require.NoError(t, server.StartAddr(addr))
+	defer server.Close()
+	require.Eventually(t, func() bool {
+		return host.lastStatus() == componentstatus.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	warmed := logs.FilterMessage("Warmed up local cache").All()
+	require.Len(t, warmed, 1)
+	assert.Equal(t, int64(1), warmed[0].ContextMap()["mappings"])
+}
+
+func TestStartLazyConnectSaltChanged(t *testing.T) {
+	server := miniredis.NewMiniRedis()
+	require.NoError(t, server.Start())
+	addr := server.Addr()
+	require.NoError(t, server.Set("meta:salt_check", "none"))
+	server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.RedisAddr = addr
+	cfg.Salt = "customer-a"
+	cfg.FieldsToMask = []string{"username"}
+	cfg.RedisRetry.MaxAttempts = 1
+	cfg.LazyConnect = LazyConnectConfig{Enabled: true, RetryInterval: 10 * time.Millisecond}
+	require.NoError(t, cfg.Validate())
+
+	host := &statusHost{Host: componenttest.NewNopHost()}
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	require.NoError(t, mp.start(context.Background(), host))
+	defer func() { require.NoError(t, mp.shutdown(context.Background())) }()
+
+	require.NoError(t, server.StartAddr(addr))
+	defer server.Close()
+	require.Eventually(t, func() bool {
+		return server.CommandCount() >= 4
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The store stays unavailable, so no mapping of the new salt is stored
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes().PutStr("username", "testuser")
+	_, err := mp.processLogs(context.Background(), ld)
+	require.NoError(t, err)
+	assert.False(t, server.Exists("mask:attribute_username:testuser"))
+	assert.Equal(t, componentstatus.StatusRecoverableError, host.lastStatus())
+}
+
+func TestStartLightweight(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Mode = "lightweight"
+	cfg.HMACKey = "secret"
+	cfg.RedisAddr = "127.0.0.1:1"
+
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	require.NoError(t, mp.start(context.Background(), componenttest.NewNopHost()))
+	require.Nil(t, mp.store)
+	require.NoError(t, mp.shutdown(context.Background()))
+}
+
+func TestStartMemoryStore(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Store = "memory"
+	cfg.FieldsToMask = []string{"username"}
+	cfg.RedisAddr = "127.0.0.1:1"
+	require.NoError(t, cfg.Validate())
+
+	ctx := context.Background()
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	require.NoError(t, mp.start(ctx, componenttest.NewNopHost()))
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes().PutStr("username", "testuser")
+	_, err := mp.processLogs(ctx, ld)
+	require.NoError(t, err)
+
+	_, found, err := mp.store.Get(ctx, "mask:attribute_username:testuser")
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.NoError(t, mp.shutdown(ctx))
+}
+
+func TestStartFileStore(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Store = "file"
+	cfg.FileStore.Path = filepath.Join(t.TempDir(), "tokens.db")
+	cfg.FieldsToMask = []string{"username"}
+	require.NoError(t, cfg.Validate())
+	ctx := context.Background()
+
+	// The mapping stored by the first processor is found after a restart
+	for i := 0; i < 2; i++ {
+		mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+		require.NoError(t, mp.start(ctx, componenttest.NewNopHost()))
+		if i == 0 {
+			ld := plog.NewLogs()
+			ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes().PutStr("username", "testuser")
+			_, err := mp.processLogs(ctx, ld)
+			require.NoError(t, err)
+		} else {
+			_, found, err := mp.store.Get(ctx, "mask:attribute_username:testuser")
+			require.NoError(t, err)
+			assert.True(t, found)
+		}
+		require.NoError(t, mp.shutdown(ctx))
+	}
+}
+
+func TestStartMemcachedStoreUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, listener.Close())
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Store = "memcached"
+	cfg.Memcached.Servers = []string{listener.Addr().String()}
+	cfg.FieldsToMask = []string{"username"}
+	require.NoError(t, cfg.Validate())
+
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	require.ErrorContains(t, mp.start(context.Background(), componenttest.NewNopHost()), "failed to connect to Memcached")
+}
+
+// testHost is a host exposing a fixed set of extensions
+type testHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h *testHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+func TestStartSharedStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	factory := storeextension.NewFactory()
+	extCfg := factory.CreateDefaultConfig().(*storeextension.Config)
+	extCfg.RedisAddr = server.Addr()
+	extCfg.LocalCacheSize = 10
+	ext, err := factory.Create(ctx, extensiontest.NewNopSettings(factory.Type()), extCfg)
+	require.NoError(t, err)
+
+	id := component.MustNewID("redismasking_store")
+	host := &testHost{Host: componenttest.NewNopHost(), extensions: map[component.ID]component.Component{id: ext}}
+	require.NoError(t, ext.Start(ctx, host))
+
+	// Both processors use the same store and leave it open on shutdown
+	var processors []*maskingProcessor
+	for i := 0; i < 2; i++ {
+		cfg := createDefaultConfig().(*Config)
+		cfg.FieldsToMask = []string{"username"}
+		cfg.StoreExtension = &id
+		require.NoError(t, cfg.Validate())
+
+		mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+		require.NoError(t, mp.start(ctx, host))
+		processors = append(processors, mp)
+	}
+	assert.Same(t, processors[0].store, processors[1].store)
+
+	for _, mp := range processors {
+		ld := plog.NewLogs()
+		ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes().PutStr("username", "testuser")
+		_, err := mp.processLogs(ctx, ld)
+		require.NoError(t, err)
+		require.NoError(t, mp.shutdown(ctx))
+	}
+
+	_, found, err := processors[0].store.Get(ctx, "mask:attribute_username:testuser")
+	require.NoError(t, err)
+	assert.True(t, found)
+	require.NoError(t, ext.Shutdown(ctx))
+}
+
+func TestStartStorage(t *testing.T) {
+	ctx := context.Background()
+
+	factory := filestorage.NewFactory()
+	extCfg := factory.CreateDefaultConfig().(*filestorage.Config)
+	extCfg.Directory = t.TempDir()
+	ext, err := factory.Create(ctx, extensiontest.NewNopSettings(factory.Type()), extCfg)
+	require.NoError(t, err)
+
+	id := component.MustNewID("file_storage")
+	host := &testHost{Host: componenttest.NewNopHost(), extensions: map[component.ID]component.Component{id: ext}}
+	require.NoError(t, ext.Start(ctx, host))
+	defer func() { require.NoError(t, ext.Shutdown(ctx)) }()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.FieldsToMask = []string{"username"}
+	cfg.Storage = &id
+	require.NoError(t, cfg.Validate())
+
+	// Mappings outlive a restart of the processor
+	var masked []string
+	for i := 0; i < 2; i++ {
+		mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+		mp.id, mp.signal = component.MustNewID(typeStr), pipeline.SignalLogs
+		require.NoError(t, mp.start(ctx, host))
+
+		ld := plog.NewLogs()
+		attrs := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Attributes()
+		attrs.PutStr("username", "testuser")
+		_, err := mp.processLogs(ctx, ld)
+		require.NoError(t, err)
+		username, _ := attrs.Get("username")
+		masked = append(masked, username.Str())
+		require.NoError(t, mp.shutdown(ctx))
+	}
+	assert.NotEqual(t, "testuser", masked[0])
+	assert.Equal(t, masked[0], masked[1])
+
+	missing := component.MustNewID("missing")
+	cfg.Storage = &missing
+	mp := newMaskingProcessor(cfg, componenttest.NewNopTelemetrySettings())
+	require.EqualError(t, mp.start(ctx, host), "storage extension 'missing' not found")
+}
+
+func TestValidateStorage(t *testing.T) {
+	id := component.MustNewID("file_storage")
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Storage = &id
+	cfg.LocalCacheSize = 10
+	require.NoError(t, cfg.Validate())
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.Storage = &id
+	cfg.StoreExtension = &id
+	require.EqualError(t, cfg.Validate(), "storage and store_extension are mutually exclusive")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.Storage = &id
+	cfg.Store = "memory"
+	require.EqualError(t, cfg.Validate(), "storage is not used with the memory store")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.Storage = &id
+	cfg.LazyConnect.Enabled = true
+	require.EqualError(t, cfg.Validate(), "lazy_connect is not used with storage")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.Storage = &id
+	cfg.TrackAccess = true
+	require.EqualError(t, cfg.Validate(), "track_access and warmup_top_n require the redis store")
+}
+
+func TestValidateStoreExtension(t *testing.T) {
+	id := component.MustNewID("redismasking_store")
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.StoreExtension = &id
+	cfg.LocalCacheSize = 10
+	require.EqualError(t, cfg.Validate(), "local_cache_size must be configured on the store extension when store_extension is set")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.StoreExtension = &id
+	cfg.Mode = "lightweight"
+	cfg.HMACKey = "secret"
+	require.EqualError(t, cfg.Validate(), "store_extension is not used in lightweight mode")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.StoreExtension = &id
+	cfg.LazyConnect.Enabled = true
+	require.EqualError(t, cfg.Validate(), "lazy_connect is not used with store_extension")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.StoreExtension = &id
+	cfg.Store = "memory"
+	require.EqualError(t, cfg.Validate(), "store_extension is not used with the memory store")
+
+	cfg = createDefaultConfig().(*Config)
+	cfg.Store = "file"
+	cfg.FileStore.Path = "tokens.db"
+	cfg.LazyConnect.Enabled = true
+	require.EqualError(t, cfg.Validate(), "lazy_connect is not used with the file store")
+}
@@ -0,0 +1,132 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+)
+
+// Consumer rebuilds a token store from a replication topic.
+//
+// Offsets are committed only after records are applied, so delivery is
+// at-least-once. Applying a mapping is idempotent: a replayed record either
+// rewrites identical values or is ignored when the store already holds a
+// different token for the original, which keeps the first writer authoritative.
+type Consumer struct {
+	client *kgo.Client
+	codec  *codec
+	store  masker.Store
+	logger *zap.Logger
+}
+
+// NewConsumer creates a Consumer that applies records from cfg's topic to store
+// as part of the given consumer group
+func NewConsumer(cfg *masker.ReplicationConfig, group string, store masker.Store, logger *zap.Logger) (*Consumer, error) {
+	key, err := cfg.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := newCodec(key)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ConsumeTopics(cfg.Topic),
+		kgo.ConsumerGroup(group),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.DisableAutoCommit(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	return &Consumer{
+		client: client,
+		codec:  codec,
+		store:  store,
+		logger: logger,
+	}, nil
+}
+
+// Run applies records until ctx is canceled
+func (c *Consumer) Run(ctx context.Context) error {
+	defer c.client.Close()
+
+	for {
+		fetches := c.client.PollFetches(ctx)
+		if fetches.IsClientClosed() || ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		fetches.EachError(func(topic string, partition int32, err error) {
+			c.logger.Error("Failed to fetch mappings", zap.String("topic", topic), zap.Int32("partition", partition), zap.Error(err))
+		})
+
+		var applyErr error
+		fetches.EachRecord(func(record *kgo.Record) {
+			if applyErr != nil {
+				return
+			}
+			applyErr = c.applyRecord(ctx, record.Value)
+		})
+
+		// Stop without committing so the failed batch is redelivered
+		if applyErr != nil {
+			return applyErr
+		}
+
+		if err := c.client.CommitUncommittedOffsets(ctx); err != nil {
+			return fmt.Errorf("failed to commit offsets: %w", err)
+		}
+	}
+}
+
+// applyRecord decodes a record and applies it to the store. Records that can
+// never be applied are logged and skipped rather than blocking the partition.
+func (c *Consumer) applyRecord(ctx context.Context, value []byte) error {
+	mapping, createdAt, err := c.codec.decodeAt(value)
+	if err != nil {
+		c.logger.Error("Skipping invalid replicated mapping", zap.Error(err))
+		return nil
+	}
+
+	// Shorten the TTL by the replication lag so the mapping expires together
+	// with the source one, and skip mappings that expired on the way
+	if mapping.TTL > 0 {
+		mapping.TTL -= time.Since(createdAt)
+		if mapping.TTL <= 0 {
+			return nil
+		}
+	}
+
+	return Apply(ctx, c.store, mapping)
+}
+
+// Apply idempotently writes mapping to store. An existing token for the same
+// original value wins over the replicated one.
+func Apply(ctx context.Context, store masker.Store, mapping masker.Mapping) error {
+	if mapping.Category == "" || mapping.Token == "" {
+		return errors.New("mapping is missing category or token")
+	}
+
+	existing, found, err := store.Get(ctx, masker.MaskKey(mapping.Category, mapping.Original))
+	if err != nil {
+		return err
+	}
+	if found && existing != mapping.Token {
+		return nil
+	}
+
+	if err := store.Set(ctx, masker.MaskKey(mapping.Category, mapping.Original), mapping.Token, mapping.TTL); err != nil {
+		return err
+	}
+	return store.Set(ctx, masker.UnmaskKey(mapping.Category, mapping.Token), mapping.Original, mapping.TTL)
+}
@@ -0,0 +1,116 @@
+// Package replication publishes newly created token mappings to Kafka and
+// rebuilds a token store from that stream, so a disaster recovery region can
+// restore masking continuity independently of Redis replication. Snapshots of
+// a whole token store use the same encrypted messages.
+package replication
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+)
+
+// messageVersion is the schema version of replicated messages
+const messageVersion = 1
+
+// message is the JSON payload of a replicated mapping. The original value is
+// encrypted so the topic never holds sensitive data in clear text.
+type message struct {
+	Version   int    `json:"v"`
+	Category  string `json:"category"`
+	Token     string `json:"token"`
+	Nonce     []byte `json:"nonce"`
+	Original  []byte `json:"original"`
+	TTLMillis int64  `json:"ttl_ms,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// codec encrypts and encodes mappings as messages
+type codec struct {
+	aead cipher.AEAD
+}
+
+// newCodec creates a codec using an AES-GCM key
+func newCodec(key []byte) (*codec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return &codec{aead: aead}, nil
+}
+
+// recordKey is the Kafka record key of a mapping. Keying on the token keeps
+// every update of a mapping in one partition and allows log compaction.
+func recordKey(mapping masker.Mapping) []byte {
+	return []byte(masker.UnmaskKey(mapping.Category, mapping.Token))
+}
+
+// encode encrypts the original value of mapping and marshals it
+func (c *codec) encode(mapping masker.Mapping, now time.Time) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	// Bind the ciphertext to its key so it cannot be replayed under another token
+	aad := recordKey(mapping)
+
+	return json.Marshal(message{
+		Version:   messageVersion,
+		Category:  mapping.Category,
+		Token:     mapping.Token,
+		Nonce:     nonce,
+		Original:  c.aead.Seal(nil, nonce, []byte(mapping.Original), aad),
+		TTLMillis: mapping.TTL.Milliseconds(),
+		CreatedAt: now.UnixMilli(),
+	})
+}
+
+// decode unmarshals and decrypts a message into a mapping
+func (c *codec) decode(data []byte) (masker.Mapping, error) {
+	mapping, _, err := c.decodeAt(data)
+	return mapping, err
+}
+
+// decodeAt unmarshals and decrypts a message into a mapping and returns the
+// time it was encoded
+func (c *codec) decodeAt(data []byte) (masker.Mapping, time.Time, error) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return masker.Mapping{}, time.Time{}, fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	if msg.Version != messageVersion {
+		return masker.Mapping{}, time.Time{}, fmt.Errorf("unsupported message version %d", msg.Version)
+	}
+
+	mapping := masker.Mapping{
+		Category: msg.Category,
+		Token:    msg.Token,
+		TTL:      time.Duration(msg.TTLMillis) * time.Millisecond,
+	}
+
+	if len(msg.Nonce) != c.aead.NonceSize() {
+		return masker.Mapping{}, time.Time{}, errors.New("invalid nonce")
+	}
+
+	original, err := c.aead.Open(nil, msg.Nonce, msg.Original, recordKey(mapping))
+	if err != nil {
+		return masker.Mapping{}, time.Time{}, fmt.Errorf("failed to decrypt original value: %w", err)
+	}
+	mapping.Original = string(original)
+
+	return mapping, time.UnixMilli(msg.CreatedAt), nil
+}
@@ -0,0 +1,110 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// meterName is the instrumentation scope of the replication metrics
+const meterName = "github.com/observiq/bindplane-otel-collector/processor/redismasking/replication"
+
+// producer is the subset of the Kafka client used by the Publisher
+type producer interface {
+	TryProduce(ctx context.Context, r *kgo.Record, promise func(*kgo.Record, error))
+	Flush(ctx context.Context) error
+	Close()
+}
+
+// Publisher publishes newly created mappings to a Kafka topic
+type Publisher struct {
+	client  producer
+	codec   *codec
+	topic   string
+	logger  *zap.Logger
+	dropped metric.Int64Counter
+}
+
+var _ masker.MappingPublisher = (*Publisher)(nil)
+
+// NewPublisher creates a Publisher for cfg that counts the mappings it fails
+// to replicate with mp
+func NewPublisher(cfg *masker.ReplicationConfig, logger *zap.Logger, mp metric.MeterProvider) (*Publisher, error) {
+	key, err := cfg.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DefaultProduceTopic(cfg.Topic),
+		// Acks from all in-sync replicas keep the idempotent producer enabled
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka client: %w", err)
+	}
+
+	return newPublisher(client, key, cfg.Topic, logger, mp)
+}
+
+// newPublisher creates a Publisher around an existing producer
+func newPublisher(client producer, key []byte, topic string, logger *zap.Logger, mp metric.MeterProvider) (*Publisher, error) {
+	codec, err := newCodec(key)
+	if err != nil {
+		return nil, err
+	}
+
+	dropped, err := mp.Meter(meterName).Int64Counter(
+		"redismasking.replication.dropped",
+		metric.WithDescription("Number of new mappings that failed to replicate, including those dropped while the producer buffer was full"),
+		metric.WithUnit("{mappings}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replication dropped counter: %w", err)
+	}
+
+	return &Publisher{
+		client:  client,
+		codec:   codec,
+		topic:   topic,
+		logger:  logger,
+		dropped: dropped,
+	}, nil
+}
+
+// Publish asynchronously produces a record for mapping. Failures are logged
+// and counted rather than returned so replication never blocks the pipeline,
+// and a record is dropped rather than waiting while the producer buffer is full.
+func (p *Publisher) Publish(ctx context.Context, mapping masker.Mapping) {
+	value, err := p.codec.encode(mapping, time.Now())
+	if err != nil {
+		p.logger.Error("Failed to encode mapping for replication", zap.String("category", mapping.Category), zap.Error(err))
+		return
+	}
+
+	record := &kgo.Record{
+		Topic: p.topic,
+		Key:   recordKey(mapping),
+		Value: value,
+	}
+
+	// Detach from the request context so the record outlives the batch
+	p.client.TryProduce(context.WithoutCancel(ctx), record, func(_ *kgo.Record, err error) {
+		if err != nil {
+			p.dropped.Add(context.Background(), 1)
+			p.logger.Error("Failed to replicate mapping", zap.String("category", mapping.Category), zap.Error(err))
+		}
+	})
+}
+
+// Close flushes buffered records and closes the client
+func (p *Publisher) Close(ctx context.Context) error {
+	defer p.client.Close()
+	return p.client.Flush(ctx)
+}
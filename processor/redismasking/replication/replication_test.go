@@ -0,0 +1,274 @@
+package replication
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+// fakeStore is an in-memory masker.Store recording TTLs when ttls is set
+type fakeStore struct {
+	data map[string]string
+	ttls map[string]time.Duration
+}
+
+func (s *fakeStore) Get(_ context.Context, key string) (string, bool, error) {
+	value, ok := s.data[key]
+	return value, ok, nil
+}
+
+func (s *fakeStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	s.data[key] = value
+	if s.ttls != nil {
+		s.ttls[key] = ttl
+	}
+	return nil
+}
+
+func (s *fakeStore) Close() error {
+	return nil
+}
+
+// fakeProducer records produced records, failing them once full
+type fakeProducer struct {
+	mu      sync.Mutex
+	records []*kgo.Record
+	limit   int
+	closed  bool
+}
+
+func (p *fakeProducer) TryProduce(_ context.Context, r *kgo.Record, promise func(*kgo.Record, error)) {
+	p.mu.Lock()
+	if p.limit > 0 && len(p.records) >= p.limit {
+		p.mu.Unlock()
+		promise(r, kgo.ErrMaxBuffered)
+		return
+	}
+	p.records = append(p.records, r)
+	p.mu.Unlock()
+	promise(r, nil)
+}
+
+func (p *fakeProducer) Flush(context.Context) error {
+	return nil
+}
+
+func (p *fakeProducer) Close() {
+	p.closed = true
+}
+
+func testMapping() masker.Mapping {
+	return masker.Mapping{
+		Category: "ipv4",
+		Token:    "10.1.2.3",
+		Original: "192.168.1.1",
+		TTL:      time.Hour,
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	c, err := newCodec(testKey)
+	require.NoError(t, err)
+
+	data, err := c.encode(testMapping(), time.Now())
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "192.168.1.1")
+
+	mapping, err := c.decode(data)
+	require.NoError(t, err)
+	require.Equal(t, testMapping(), mapping)
+
+	// A different key cannot decrypt the original
+	other, err := newCodec([]byte("fedcba9876543210fedcba9876543210"))
+	require.NoError(t, err)
+	_, err = other.decode(data)
+	require.ErrorContains(t, err, "failed to decrypt original value")
+}
+
+func TestCodecRejectsMovedCiphertext(t *testing.T) {
+	c, err := newCodec(testKey)
+	require.NoError(t, err)
+
+	data, err := c.encode(testMapping(), time.Now())
+	require.NoError(t, err)
+
+	// Reusing the ciphertext under another token fails authentication
+	moved := []byte(strings.Replace(string(data), `"token":"10.1.2.3"`, `"token":"10.9.9.9"`, 1))
+	_, err = c.decode(moved)
+	require.ErrorContains(t, err, "failed to decrypt original value")
+}
+
+func TestPublisher(t *testing.T) {
+	producer := &fakeProducer{}
+	publisher, err := newPublisher(producer, testKey, "mappings", zap.NewNop(), noop.NewMeterProvider())
+	require.NoError(t, err)
+
+	publisher.Publish(context.Background(), testMapping())
+	require.Len(t, producer.records, 1)
+	require.Equal(t, "mappings", producer.records[0].Topic)
+	require.Equal(t, masker.UnmaskKey("ipv4", "10.1.2.3"), string(producer.records[0].Key))
+
+	mapping, err := publisher.codec.decode(producer.records[0].Value)
+	require.NoError(t, err)
+	require.Equal(t, testMapping(), mapping)
+
+	require.NoError(t, publisher.Close(context.Background()))
+	require.True(t, producer.closed)
+}
+
+func TestPublisherDropsWhenBufferFull(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	producer := &fakeProducer{limit: 1}
+	publisher, err := newPublisher(producer, testKey, "mappings", zap.NewNop(), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	require.NoError(t, err)
+
+	other := testMapping()
+	other.Token = "10.1.2.4"
+	other.Original = "192.168.1.2"
+	publisher.Publish(context.Background(), testMapping())
+	publisher.Publish(context.Background(), other)
+	require.Len(t, producer.records, 1)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	require.Equal(t, "redismasking.replication.dropped", rm.ScopeMetrics[0].Metrics[0].Name)
+	data := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Sum[int64])
+	require.Len(t, data.DataPoints, 1)
+	require.Equal(t, int64(1), data.DataPoints[0].Value)
+}
+
+func TestApplyIsIdempotent(t *testing.T) {
+	store := &fakeStore{data: map[string]string{}}
+	mapping := testMapping()
+
+	require.NoError(t, Apply(context.Background(), store, mapping))
+	require.NoError(t, Apply(context.Background(), store, mapping))
+	require.Equal(t, map[string]string{
+		masker.MaskKey("ipv4", "192.168.1.1"): "10.1.2.3",
+		masker.UnmaskKey("ipv4", "10.1.2.3"):  "192.168.1.1",
+	}, store.data)
+
+	// An existing token for the original is kept
+	conflicting := mapping
+	conflicting.Token = "10.9.9.9"
+	require.NoError(t, Apply(context.Background(), store, conflicting))
+	require.Equal(t, "10.1.2.3", store.data[masker.MaskKey("ipv4", "192.168.1.1")])
+	require.NotContains(t, store.data, masker.UnmaskKey("ipv4", "10.9.9.9"))
+
+	require.EqualError(t, Apply(context.Background(), store, masker.Mapping{}), "mapping is missing category or token")
+}
+
+func TestConsumerSkipsInvalidRecords(t *testing.T) {
+	c, err := newCodec(testKey)
+	require.NoError(t, err)
+
+	store := &fakeStore{data: map[string]string{}}
+	consumer := &Consumer{codec: c, store: store, logger: zap.NewNop()}
+
+	require.NoError(t, consumer.applyRecord(context.Background(), []byte("not json")))
+	require.Empty(t, store.data)
+
+	data, err := c.encode(testMapping(), time.Now())
+	require.NoError(t, err)
+	require.NoError(t, consumer.applyRecord(context.Background(), data))
+	require.Equal(t, "10.1.2.3", store.data[masker.MaskKey("ipv4", "192.168.1.1")])
+}
+
+func TestConsumerShortensTTLByLag(t *testing.T) {
+	c, err := newCodec(testKey)
+	require.NoError(t, err)
+
+	store := &fakeStore{data: map[string]string{}, ttls: map[string]time.Duration{}}
+	consumer := &Consumer{codec: c, store: store, logger: zap.NewNop()}
+
+	data, err := c.encode(testMapping(), time.Now().Add(-20*time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, consumer.applyRecord(context.Background(), data))
+	ttl := store.ttls[masker.MaskKey("ipv4", "192.168.1.1")]
+	require.Greater(t, ttl, 39*time.Minute)
+	require.LessOrEqual(t, ttl, 40*time.Minute)
+
+	// A mapping that expired before it was consumed is skipped
+	expired := testMapping()
+	expired.Original = "192.168.1.2"
+	expired.Token = "10.1.2.4"
+	data, err = c.encode(expired, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, consumer.applyRecord(context.Background(), data))
+	require.NotContains(t, store.data, masker.MaskKey("ipv4", "192.168.1.2"))
+}
+
+// fakeScanner is a masker.MappingScanner of fixed mappings
+type fakeScanner []masker.Mapping
+
+func (s fakeScanner) ScanMappings(_ context.Context, fn func(masker.Mapping) error) error {
+	for _, mapping := range s {
+		if err := fn(mapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	snapshot, err := NewSnapshot(&masker.ReplicationConfig{EncryptionKey: "MDEyMzQ1Njc4OWFiY2RlZg=="})
+	require.NoError(t, err)
+	forever := masker.Mapping{Category: "hostname", Token: "host-1.masked.local", Original: "web-01"}
+
+	var buf bytes.Buffer
+	exported, err := snapshot.Export(context.Background(), fakeScanner{testMapping(), forever}, &buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), exported)
+
+	zr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	var plain bytes.Buffer
+	_, err = plain.ReadFrom(zr)
+	require.NoError(t, err)
+	require.NotContains(t, plain.String(), "192.168.1.1")
+
+	// A token already held by the store wins
+	store := &fakeStore{data: map[string]string{masker.MaskKey("hostname", "web-01"): "host-2.masked.local"}}
+	stats, err := snapshot.Import(context.Background(), store, &buf)
+	require.NoError(t, err)
+	require.Equal(t, SnapshotStats{Applied: 2}, stats)
+	require.Equal(t, "192.168.1.1", store.data[masker.UnmaskKey("ipv4", "10.1.2.3")])
+	require.Equal(t, "host-2.masked.local", store.data[masker.MaskKey("hostname", "web-01")])
+	require.NotContains(t, store.data, masker.UnmaskKey("hostname", "host-1.masked.local"))
+}
+
+func TestSnapshotSkipsExpired(t *testing.T) {
+	c, err := newCodec(testKey)
+	require.NoError(t, err)
+
+	// The mapping had an hour left when it was exported two hours ago
+	data, err := c.encode(testMapping(), time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(append(data, '\n'))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	store := &fakeStore{data: map[string]string{}}
+	stats, err := (&Snapshot{codec: c}).Import(context.Background(), store, &buf)
+	require.NoError(t, err)
+	require.Equal(t, SnapshotStats{Expired: 1}, stats)
+	require.Empty(t, store.data)
+}
@@ -0,0 +1,109 @@
+package replication
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+)
+
+// maxSnapshotLine bounds a single message of a snapshot
+const maxSnapshotLine = 1 << 20
+
+// SnapshotStats summarizes an import of a snapshot
+type SnapshotStats struct {
+	// Applied is the number of mappings written or already held by the store
+	Applied int64
+
+	// Expired is the number of mappings whose TTL ran out since the export
+	Expired int64
+}
+
+// Snapshot is a point-in-time backup of a token store. It is a gzip compressed
+// stream of replication messages, one per line, so original values are
+// encrypted like on the replication topic.
+type Snapshot struct {
+	codec *codec
+}
+
+// NewSnapshot creates a Snapshot encrypting original values with the
+// replication encryption key of cfg
+func NewSnapshot(cfg *masker.ReplicationConfig) (*Snapshot, error) {
+	key, err := cfg.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	codec, err := newCodec(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{codec: codec}, nil
+}
+
+// Export writes every mapping of scanner to w and returns their number. Each
+// mapping keeps its remaining TTL.
+func (s *Snapshot) Export(ctx context.Context, scanner masker.MappingScanner, w io.Writer) (int64, error) {
+	zw := gzip.NewWriter(w)
+	var exported int64
+	err := scanner.ScanMappings(ctx, func(mapping masker.Mapping) error {
+		data, err := s.codec.encode(mapping, time.Now())
+		if err != nil {
+			return err
+		}
+		if _, err := zw.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+		exported++
+		return nil
+	})
+	if err != nil {
+		return exported, err
+	}
+	if err := zw.Close(); err != nil {
+		return exported, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return exported, nil
+}
+
+// Import applies every mapping of the snapshot in r to store. As with the
+// replication topic, a token already held by the store for an original value
+// wins. The TTL of a mapping is shortened by the age of the snapshot, and
+// mappings that expired since the export are skipped.
+func (s *Snapshot) Import(ctx context.Context, store masker.Store, r io.Reader) (SnapshotStats, error) {
+	var stats SnapshotStats
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return stats, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	defer zr.Close()
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, maxSnapshotLine)
+	for line := 1; scanner.Scan(); line++ {
+		mapping, exportedAt, err := s.codec.decodeAt(scanner.Bytes())
+		if err != nil {
+			return stats, fmt.Errorf("snapshot line %d: %w", line, err)
+		}
+		if mapping.TTL > 0 {
+			mapping.TTL -= time.Since(exportedAt)
+			if mapping.TTL <= 0 {
+				stats.Expired++
+				continue
+			}
+		}
+
+		if err := Apply(ctx, store, mapping); err != nil {
+			return stats, err
+		}
+		stats.Applied++
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return stats, nil
+}
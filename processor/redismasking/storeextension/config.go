@@ -0,0 +1,115 @@
+package storeextension
+
+import (
+	"errors"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+
+	"go.opentelemetry.io/collector/component"
+)
+
+// Config defines configuration for the shared token store extension
+type Config struct {
+	// Redis connection settings
+	RedisAddr     string `mapstructure:"redis_addr"`
+	RedisPassword string `mapstructure:"redis_password"`
+	RedisDB       int    `mapstructure:"redis_db"`
+
+	// RedisPasswordFile is a file holding the password instead of RedisPassword.
+	// It is read again when Redis rejects the password.
+	RedisPasswordFile string `mapstructure:"redis_password_file"`
+
+	// RedisNetwork is "tcp" or "unix". With "unix", RedisAddr is the path of the socket.
+	RedisNetwork string `mapstructure:"redis_network"`
+
+	// RedisPool tunes the connection pool shared by every processor using the extension
+	RedisPool masker.PoolConfig `mapstructure:"redis_pool"`
+
+	// RedisTimeouts bounds the dial, read, and write of Redis operations
+	RedisTimeouts masker.TimeoutConfig `mapstructure:"redis_timeouts"`
+
+	// RedisRetry retries Redis commands that fail with a transient error
+	RedisRetry masker.RetryConfig `mapstructure:"redis_retry"`
+
+	// RedisAuth authenticates with short-lived tokens instead of RedisPassword
+	RedisAuth masker.AuthConfig `mapstructure:"redis_auth"`
+
+	// RedisReplicas routes lookups of the shared store to read replicas
+	RedisReplicas masker.ReplicaConfig `mapstructure:"redis_replicas"`
+
+	// RedisClientCache caches the mask keys of the shared store in memory
+	RedisClientCache masker.ClientCacheConfig `mapstructure:"redis_client_cache"`
+
+	// TLS secures the Redis connection
+	TLS masker.TLSConfig `mapstructure:"tls"`
+
+	// LocalCacheSize is the number of store entries kept in a local LRU shared by
+	// every processor using the extension (0 = disabled)
+	LocalCacheSize int `mapstructure:"local_cache_size"`
+
+	// LocalCacheTTL is how long entries stay in the local LRU (0 = until evicted by size)
+	LocalCacheTTL time.Duration `mapstructure:"local_cache_ttl"`
+
+	// LocalCacheInvalidation drops mappings from the local LRU as soon as they
+	// change in Redis, using keyspace notifications
+	LocalCacheInvalidation bool `mapstructure:"local_cache_invalidation"`
+}
+
+var _ component.Config = (*Config)(nil)
+
+// Validate checks if the extension configuration is valid
+func (cfg *Config) Validate() error {
+	if cfg.RedisAddr == "" {
+		return errors.New("redis_addr is required")
+	}
+
+	if err := masker.ValidateRedisNetwork(cfg.RedisNetwork, cfg.RedisAddr); err != nil {
+		return err
+	}
+
+	if err := cfg.RedisPool.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RedisTimeouts.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.RedisRetry.Validate(); err != nil {
+		return err
+	}
+
+	if err := masker.ValidateRedisAuth(&cfg.RedisAuth, cfg.RedisPassword, &cfg.TLS); err != nil {
+		return err
+	}
+
+	if err := masker.ValidateRedisPasswordFile(cfg.RedisPasswordFile, cfg.RedisPassword, &cfg.RedisAuth); err != nil {
+		return err
+	}
+
+	if err := cfg.RedisReplicas.Validate(); err != nil {
+		return err
+	}
+
+	if err := masker.ValidateClientCache(&cfg.RedisClientCache, &cfg.RedisReplicas); err != nil {
+		return err
+	}
+
+	if _, err := cfg.TLS.Load(); err != nil {
+		return err
+	}
+
+	if cfg.LocalCacheSize < 0 {
+		return errors.New("local_cache_size must be non-negative")
+	}
+
+	if cfg.LocalCacheTTL < 0 {
+		return errors.New("local_cache_ttl must be non-negative")
+	}
+
+	if cfg.LocalCacheInvalidation && cfg.LocalCacheSize == 0 {
+		return errors.New("local_cache_invalidation requires local_cache_size")
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package storeextension provides an extension that hosts a single token store
+// shared by every redismasking processor referencing it. Pipelines of one
+// collector then share one Redis connection pool and one local cache instead
+// of each processor instance keeping its own.
+package storeextension
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+)
+
+// Provider is implemented by extensions that provide a shared token store
+type Provider interface {
+	// Store returns the shared store. It is owned by the extension and must not be closed.
+	Store() masker.Store
+
+	// AccessTracker returns the tracker of the shared store
+	AccessTracker() masker.AccessTracker
+
+	// Watchlist returns the watched tokens of the shared store
+	Watchlist() masker.Watchlist
+}
+
+// storeExtension hosts the shared store between Start and Shutdown
+type storeExtension struct {
+	config    *Config
+	logger    *zap.Logger
+	store     masker.Store
+	tracker   masker.AccessTracker
+	watchlist masker.Watchlist
+}
+
+var _ Provider = (*storeExtension)(nil)
+
+func newStoreExtension(config *Config, logger *zap.Logger) *storeExtension {
+	return &storeExtension{
+		config: config,
+		logger: logger,
+	}
+}
+
+// Start connects to Redis
+func (e *storeExtension) Start(ctx context.Context, _ component.Host) error {
+	store, err := masker.NewRedisStore(ctx, &masker.Config{
+		RedisAddr:         e.config.RedisAddr,
+		RedisPassword:     e.config.RedisPassword,
+		RedisPasswordFile: e.config.RedisPasswordFile,
+		RedisDB:           e.config.RedisDB,
+		RedisNetwork:      e.config.RedisNetwork,
+		RedisPool:         e.config.RedisPool,
+		RedisTimeouts:     e.config.RedisTimeouts,
+		RedisRetry:        e.config.RedisRetry,
+		RedisAuth:         e.config.RedisAuth,
+		RedisReplicas:     e.config.RedisReplicas,
+		RedisClientCache:  e.config.RedisClientCache,
+		TLS:               e.config.TLS,
+	})
+	if err != nil {
+		return err
+	}
+	e.logger.Info("Connected to Redis successfully", zap.String("addr", e.config.RedisAddr))
+
+	// Access is tracked on Redis itself, so cached lookups are still counted
+	e.tracker, _ = store.(masker.AccessTracker)
+	e.watchlist, _ = store.(masker.Watchlist)
+	switch {
+	case e.config.LocalCacheInvalidation:
+		watched, err := masker.NewWatchedCachedStore(store, e.config.LocalCacheSize, e.config.LocalCacheTTL)
+		if err != nil {
+			_ = store.Close()
+			return err
+		}
+		store = watched
+	case e.config.LocalCacheSize > 0:
+		store = masker.NewCachedStore(store, e.config.LocalCacheSize, e.config.LocalCacheTTL)
+	}
+	e.store = store
+	return nil
+}
+
+// Shutdown closes the shared store
+func (e *storeExtension) Shutdown(context.Context) error {
+	if e.store == nil {
+		return nil
+	}
+	return e.store.Close()
+}
+
+// Store returns the shared store
+func (e *storeExtension) Store() masker.Store {
+	return e.store
+}
+
+// AccessTracker returns the tracker of the shared store
+func (e *storeExtension) AccessTracker() masker.AccessTracker {
+	return e.tracker
+}
+
+// Watchlist returns the watched tokens of the shared store
+func (e *storeExtension) Watchlist() masker.Watchlist {
+	return e.watchlist
+}
+
+// GetProvider returns the store provider registered on host as id
+func GetProvider(host component.Host, id component.ID) (Provider, error) {
+	ext, ok := host.GetExtensions()[id]
+	if !ok {
+		return nil, fmt.Errorf("store extension '%s' not found", id)
+	}
+
+	provider, ok := ext.(Provider)
+	if !ok {
+		return nil, fmt.Errorf("extension '%s' is not a redismasking store extension", id)
+	}
+
+	if provider.Store() == nil {
+		return nil, errors.New("store extension is not started")
+	}
+	return provider, nil
+}
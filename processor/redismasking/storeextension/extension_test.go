@@ -0,0 +1,117 @@
+package storeextension
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+)
+
+// testHost is a host exposing a fixed set of extensions
+type testHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h *testHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+func TestExtension(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.RedisAddr = server.Addr()
+	cfg.LocalCacheSize = 10
+	require.NoError(t, cfg.Validate())
+
+	id := component.MustNewID(typeStr)
+	ext, err := factory.Create(context.Background(), extensiontest.NewNopSettings(factory.Type()), cfg)
+	require.NoError(t, err)
+
+	host := &testHost{Host: componenttest.NewNopHost(), extensions: map[component.ID]component.Component{id: ext}}
+
+	// The provider is unavailable until the extension is started
+	_, err = GetProvider(host, id)
+	require.EqualError(t, err, "store extension is not started")
+
+	require.NoError(t, ext.Start(context.Background(), host))
+
+	provider, err := GetProvider(host, id)
+	require.NoError(t, err)
+	require.NotNil(t, provider.AccessTracker())
+
+	ctx := context.Background()
+	require.NoError(t, provider.Store().Set(ctx, "mask:ipv4:10.0.0.1", "10.1.2.3", 0))
+	value, found, err := provider.Store().Get(ctx, "mask:ipv4:10.0.0.1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "10.1.2.3", value)
+
+	require.NoError(t, ext.Shutdown(ctx))
+}
+
+func TestGetProviderErrors(t *testing.T) {
+	id := component.MustNewID(typeStr)
+	host := &testHost{Host: componenttest.NewNopHost(), extensions: map[component.ID]component.Component{}}
+
+	_, err := GetProvider(host, id)
+	require.EqualError(t, err, "store extension 'redismasking_store' not found")
+
+	host.extensions[id] = struct {
+		component.StartFunc
+		component.ShutdownFunc
+	}{}
+	_, err = GetProvider(host, id)
+	require.EqualError(t, err, "extension 'redismasking_store' is not a redismasking store extension")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	require.NoError(t, cfg.Validate())
+
+	cfg.RedisNetwork = "udp"
+	require.EqualError(t, cfg.Validate(), "unsupported redis_network 'udp'")
+	cfg.RedisNetwork = ""
+
+	cfg.RedisPool.MinIdleConns = -1
+	require.EqualError(t, cfg.Validate(), "redis_pool settings must be non-negative")
+	cfg.RedisPool.MinIdleConns = 0
+
+	cfg.RedisTimeouts.Dial = -time.Second
+	require.EqualError(t, cfg.Validate(), "redis_timeouts must be non-negative")
+	cfg.RedisTimeouts.Dial = 0
+
+	cfg.RedisRetry.MaxAttempts = -1
+	require.EqualError(t, cfg.Validate(), "redis_retry settings must be non-negative")
+	cfg.RedisRetry.MaxAttempts = 0
+
+	cfg.RedisAuth.Provider = "kerberos"
+	require.EqualError(t, cfg.Validate(), "unsupported redis_auth provider 'kerberos'")
+	cfg.RedisAuth.Provider = ""
+
+	cfg.RedisPassword = "secret"
+	cfg.RedisPasswordFile = "/run/secrets/redis"
+	require.EqualError(t, cfg.Validate(), "redis_password and redis_password_file are mutually exclusive")
+	cfg.RedisPassword = ""
+	cfg.RedisPasswordFile = ""
+
+	cfg.LocalCacheInvalidation = true
+	require.EqualError(t, cfg.Validate(), "local_cache_invalidation requires local_cache_size")
+
+	cfg.LocalCacheTTL = -time.Second
+	require.EqualError(t, cfg.Validate(), "local_cache_ttl must be non-negative")
+
+	cfg.LocalCacheSize = -1
+	require.EqualError(t, cfg.Validate(), "local_cache_size must be non-negative")
+
+	cfg.RedisAddr = ""
+	require.EqualError(t, cfg.Validate(), "redis_addr is required")
+}
@@ -0,0 +1,35 @@
+package storeextension
+
+import (
+	"context"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/extension"
+)
+
+const (
+	typeStr   = "redismasking_store"
+	stability = component.StabilityLevelAlpha
+)
+
+// NewFactory creates a new extension factory
+func NewFactory() extension.Factory {
+	return extension.NewFactory(
+		component.MustNewType(typeStr),
+		createDefaultConfig,
+		createExtension,
+		stability,
+	)
+}
+
+// createDefaultConfig creates the default configuration
+func createDefaultConfig() component.Config {
+	return &Config{
+		RedisAddr: "localhost:6379",
+	}
+}
+
+// createExtension creates the shared token store extension
+func createExtension(_ context.Context, set extension.Settings, cfg component.Config) (extension.Extension, error) {
+	return newStoreExtension(cfg.(*Config), set.Logger), nil
+}
@@ -0,0 +1,263 @@
+package unmask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultAuditFlushInterval is how often buffered audit records are written
+	DefaultAuditFlushInterval = time.Second
+
+	// DefaultAuditMaxBatchSize is the number of audit records written per entry
+	DefaultAuditMaxBatchSize = 500
+
+	// auditWriteTimeout bounds the write of one batch
+	auditWriteTimeout = 5 * time.Second
+
+	// auditEncoding identifies the encoding of the batch field of stream entries
+	auditEncoding = "zstd+ndjson"
+)
+
+// Audit actions of the unmask API
+const (
+	AuditGrantIssued    = "grant_issued"
+	AuditGrantRedeemed  = "grant_redeemed"
+	AuditPurgeStarted   = "purge_started"
+	AuditPurgeFinished  = "purge_finished"
+	AuditPurgeFailed    = "purge_failed"
+	AuditTokenWatched   = "token_watched"
+	AuditTokenUnwatched = "token_unwatched"
+)
+
+// AuditRecord is one audited action of the unmask API. Original values are
+// never recorded.
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+
+	// Actor is the analyst of a grant or the operator of any other action
+	Actor     string    `json:"actor"`
+	Category  string    `json:"category,omitempty"`
+	Token     string    `json:"token,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	DryRun    bool      `json:"dry_run,omitempty"`
+	Keys      int64     `json:"keys,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+
+	// Result is "found" or "not_found" for a redeemed grant
+	Result string `json:"result,omitempty"`
+}
+
+// AuditConfig defines how audit records are streamed to Redis
+type AuditConfig struct {
+	// Stream is the Redis stream receiving the batches
+	Stream string
+
+	// FlushInterval is how often buffered records are written
+	FlushInterval time.Duration
+
+	// MaxBatchSize is the number of records that are written at once without
+	// waiting for the flush interval
+	MaxBatchSize int
+}
+
+// Validate checks the audit configuration
+func (cfg *AuditConfig) Validate() error {
+	if cfg.Stream == "" {
+		return errors.New("audit stream is required")
+	}
+	if cfg.FlushInterval <= 0 {
+		return errors.New("audit flush interval must be positive")
+	}
+	if cfg.MaxBatchSize <= 0 {
+		return errors.New("audit max batch size must be positive")
+	}
+	return nil
+}
+
+// AuditEmitter buffers audit records and writes them to a Redis stream in
+// zstd compressed batches, so auditing costs one write per batch rather than
+// one per record. Batches that fail to be written are dropped and logged; the
+// records are still written to the log by the Server.
+type AuditEmitter struct {
+	client       *redis.Client
+	stream       string
+	maxBatchSize int
+	encoder      *zstd.Encoder
+	logger       *zap.Logger
+
+	mu      sync.Mutex
+	pending []AuditRecord
+
+	// full wakes the flush loop when a batch is full
+	full    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewRedisAuditEmitter connects to the Redis server of cfg to stream audit
+// records as configured by audit
+func NewRedisAuditEmitter(ctx context.Context, cfg *masker.Config, audit AuditConfig, logger *zap.Logger) (*AuditEmitter, error) {
+	if err := audit.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := cfg.NewRedisClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	emitter, err := newAuditEmitter(client, audit, logger)
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return emitter, nil
+}
+
+// newAuditEmitter starts an AuditEmitter around an existing client
+func newAuditEmitter(client *redis.Client, audit AuditConfig, logger *zap.Logger) (*AuditEmitter, error) {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+
+	e := &AuditEmitter{
+		client:       client,
+		stream:       audit.Stream,
+		maxBatchSize: audit.MaxBatchSize,
+		encoder:      encoder,
+		logger:       logger,
+		full:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	go e.run(audit.FlushInterval)
+	return e, nil
+}
+
+// Emit buffers record until the next flush. It never blocks on Redis.
+func (e *AuditEmitter) Emit(record AuditRecord) {
+	e.mu.Lock()
+	e.pending = append(e.pending, record)
+	full := len(e.pending) >= e.maxBatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Close writes the buffered records and closes the connection to Redis
+func (e *AuditEmitter) Close() error {
+	close(e.done)
+	<-e.stopped
+	e.encoder.Close()
+	return e.client.Close()
+}
+
+// run flushes the buffered records every interval, when a batch is full, and
+// once more when the emitter is closed
+func (e *AuditEmitter) run(interval time.Duration) {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.full:
+		case <-e.done:
+			e.flush()
+			return
+		}
+		e.flush()
+	}
+}
+
+// flush writes the buffered records in batches of at most maxBatchSize records
+func (e *AuditEmitter) flush() {
+	e.mu.Lock()
+	records := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	for len(records) > 0 {
+		n := min(len(records), e.maxBatchSize)
+		if err := e.write(records[:n]); err != nil {
+			e.logger.Error("Failed to write audit batch", zap.Int("records", n), zap.Error(err))
+		}
+		records = records[n:]
+	}
+}
+
+// write adds records to the stream as one compressed entry
+func (e *AuditEmitter) write(records []AuditRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to encode audit record: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), auditWriteTimeout)
+	defer cancel()
+	err := e.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: e.stream,
+		Values: map[string]any{
+			"encoding": auditEncoding,
+			"records":  len(records),
+			"batch":    e.encoder.EncodeAll(buf.Bytes(), nil),
+		},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("redis xadd error: %w", err)
+	}
+	return nil
+}
+
+// DecodeAuditBatch returns the records of the batch field of an audit stream entry
+func DecodeAuditBatch(batch []byte) ([]AuditRecord, error) {
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+	defer decoder.Close()
+
+	data, err := decoder.DecodeAll(batch, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress audit batch: %w", err)
+	}
+
+	var records []AuditRecord
+	jsonDecoder := json.NewDecoder(bytes.NewReader(data))
+	for jsonDecoder.More() {
+		var record AuditRecord
+		if err := jsonDecoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("failed to decode audit record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
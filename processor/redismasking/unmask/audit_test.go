@@ -0,0 +1,142 @@
+package unmask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testAuditStream = "unmask_audit"
+
+// newTestEmitter creates an AuditEmitter writing to the stream of server
+func newTestEmitter(t *testing.T, server *miniredis.Miniredis, flushInterval time.Duration, maxBatchSize int) *AuditEmitter {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	emitter, err := newAuditEmitter(client, AuditConfig{
+		Stream:        testAuditStream,
+		FlushInterval: flushInterval,
+		MaxBatchSize:  maxBatchSize,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	return emitter
+}
+
+// readAuditStream returns the records of every entry of the audit stream
+func readAuditStream(t *testing.T, server *miniredis.Miniredis) ([]int, []AuditRecord) {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	entries, err := client.XRange(context.Background(), testAuditStream, "-", "+").Result()
+	require.NoError(t, err)
+
+	var sizes []int
+	var records []AuditRecord
+	for _, entry := range entries {
+		require.Equal(t, auditEncoding, entry.Values["encoding"])
+		batch, err := DecodeAuditBatch([]byte(entry.Values["batch"].(string)))
+		require.NoError(t, err)
+		sizes = append(sizes, len(batch))
+		records = append(records, batch...)
+	}
+	return sizes, records
+}
+
+func TestAuditEmitterBatches(t *testing.T) {
+	server := miniredis.RunT(t)
+	emitter := newTestEmitter(t, server, time.Hour, 2)
+
+	for _, token := range []string{"a", "b", "c"} {
+		emitter.Emit(AuditRecord{Action: AuditGrantIssued, Actor: "alice", Category: "ipv4", Token: token})
+	}
+
+	// A full batch is written without waiting for the flush interval
+	require.Eventually(t, func() bool {
+		sizes, _ := readAuditStream(t, server)
+		return len(sizes) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	// Closing writes the rest
+	require.NoError(t, emitter.Close())
+	sizes, records := readAuditStream(t, server)
+	assert.Equal(t, []int{2, 1}, sizes)
+	tokens := make([]string, 0, len(records))
+	for _, record := range records {
+		tokens = append(tokens, record.Token)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, tokens)
+}
+
+func TestAuditEmitterFlushInterval(t *testing.T) {
+	server := miniredis.RunT(t)
+	emitter := newTestEmitter(t, server, 10*time.Millisecond, 100)
+	t.Cleanup(func() { require.NoError(t, emitter.Close()) })
+
+	emitter.Emit(AuditRecord{Action: AuditTokenUnwatched, Actor: "bob", Category: "ipv4", Token: "10.1.2.3"})
+	require.Eventually(t, func() bool {
+		_, records := readAuditStream(t, server)
+		return len(records) == 1 && records[0].Actor == "bob"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestAuditConfigValidate(t *testing.T) {
+	cfg := AuditConfig{Stream: testAuditStream, FlushInterval: time.Second, MaxBatchSize: 1}
+	require.NoError(t, cfg.Validate())
+
+	cfg.MaxBatchSize = 0
+	require.EqualError(t, cfg.Validate(), "audit max batch size must be positive")
+	cfg.MaxBatchSize = 1
+	cfg.FlushInterval = 0
+	require.EqualError(t, cfg.Validate(), "audit flush interval must be positive")
+	cfg.Stream = ""
+	require.EqualError(t, cfg.Validate(), "audit stream is required")
+}
+
+func TestServerAudit(t *testing.T) {
+	s, server := newTestServer(t)
+	emitter := newTestEmitter(t, server, time.Hour, 100)
+	s.emitter = emitter
+
+	status, body := post(t, s, "/v1/grants", testAdminKey, issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "alice"})
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = post(t, s, "/v1/unmask", "", unmaskRequest{Grant: body["grant"].(string)})
+	require.Equal(t, http.StatusOK, status)
+
+	data, err := json.Marshal(purgeRequest{Category: "ipv4", Operator: "jdoe", Reason: "contract ended", DryRun: true})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/v1/purge", bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.NoError(t, emitter.Close())
+	_, records := readAuditStream(t, server)
+	require.Len(t, records, 4)
+	assert.Equal(t, AuditGrantIssued, records[0].Action)
+	assert.Equal(t, "alice", records[0].Actor)
+	assert.False(t, records[0].ExpiresAt.IsZero())
+	assert.Equal(t, AuditGrantRedeemed, records[1].Action)
+	assert.Equal(t, "found", records[1].Result)
+	assert.Equal(t, AuditRecord{
+		Time:     records[3].Time,
+		Action:   AuditPurgeFinished,
+		Actor:    "jdoe",
+		Category: "ipv4",
+		Reason:   "contract ended",
+		DryRun:   true,
+		Keys:     1,
+	}, records[3])
+	assert.Equal(t, AuditPurgeStarted, records[2].Action)
+}
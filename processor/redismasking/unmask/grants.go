@@ -0,0 +1,151 @@
+// Package unmask provides the API used to reverse masked tokens. Reverse
+// lookups require a short-lived, single-use grant issued by an administrator
+// rather than a standing credential.
+package unmask
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/redis/go-redis/v9"
+)
+
+// Grant authorizes a single reverse lookup of one token
+type Grant struct {
+	// Category is the category of the token
+	Category string `json:"category"`
+
+	// Token is the masked value that may be reversed
+	Token string `json:"token"`
+
+	// Analyst identifies who the grant was issued to, for auditing
+	Analyst string `json:"analyst"`
+
+	// ExpiresAt is when the grant can no longer be used
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Grants issues and consumes single-use grants
+type Grants interface {
+	// Issue stores grant and returns the secret used to redeem it
+	Issue(ctx context.Context, grant Grant) (string, error)
+
+	// Redeem atomically consumes the grant identified by secret once accept, when
+	// set, accepts it. It reports false when the grant does not exist, was already
+	// used, has expired, or was not accepted, which leaves it unused.
+	Redeem(ctx context.Context, secret string, accept func(Grant) bool) (Grant, bool, error)
+
+	// Close releases any resources held by the grants
+	Close() error
+}
+
+// redisGrants stores grants in Redis, where they expire with their TTL
+type redisGrants struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+// NewRedisGrants connects to the Redis server of cfg to store grants
+func NewRedisGrants(ctx context.Context, cfg *masker.Config) (Grants, error) {
+	client, err := cfg.NewRedisClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &redisGrants{client: client, now: time.Now}, nil
+}
+
+// grantKey returns the Redis key of a grant. Only a hash of the secret is
+// stored so the keyspace never holds redeemable grants.
+func grantKey(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return "unmask_grant:" + hex.EncodeToString(hash[:])
+}
+
+// Issue stores grant until it expires
+func (g *redisGrants) Issue(ctx context.Context, grant Grant) (string, error) {
+	ttl := grant.ExpiresAt.Sub(g.now())
+	if ttl <= 0 {
+		return "", errors.New("grant is already expired")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate grant: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+
+	value, err := json.Marshal(grant)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode grant: %w", err)
+	}
+
+	if err := g.client.Set(ctx, grantKey(encoded), value, ttl).Err(); err != nil {
+		return "", fmt.Errorf("redis set error: %w", err)
+	}
+	return encoded, nil
+}
+
+// Redeem checks the grant and deletes it in a transaction watching its key,
+// so concurrent redemptions of the same secret cannot both succeed and a
+// rejected redemption does not use up the grant
+func (g *redisGrants) Redeem(ctx context.Context, secret string, accept func(Grant) bool) (Grant, bool, error) {
+	key := grantKey(secret)
+	var grant Grant
+	redeemed := false
+	err := g.client.Watch(ctx, func(tx *redis.Tx) error {
+		value, err := tx.Get(ctx, key).Bytes()
+		switch {
+		case errors.Is(err, redis.Nil):
+			return nil
+		case err != nil:
+			return fmt.Errorf("redis get error: %w", err)
+		}
+
+		if err := json.Unmarshal(value, &grant); err != nil {
+			return fmt.Errorf("failed to decode grant: %w", err)
+		}
+
+		// Redis expiry is the primary guard, this covers clock skew and persisted TTLs
+		if !g.now().Before(grant.ExpiresAt) || (accept != nil && !accept(grant)) {
+			return nil
+		}
+
+		if _, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			return nil
+		}); err != nil {
+			return err
+		}
+		redeemed = true
+		return nil
+	}, key)
+	switch {
+	case errors.Is(err, redis.TxFailedErr):
+		// Another redemption consumed the grant since it was read
+		return Grant{}, false, nil
+	case err != nil:
+		return Grant{}, false, fmt.Errorf("redis transaction error: %w", err)
+	case !redeemed:
+		return Grant{}, false, nil
+	}
+	return grant, true, nil
+}
+
+// Close closes the Redis client
+func (g *redisGrants) Close() error {
+	return g.client.Close()
+}
@@ -0,0 +1,526 @@
+package unmask
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultGrantTTL is how long a grant is valid when the request sets no TTL
+	defaultGrantTTL = 15 * time.Minute
+
+	// maxGrantTTL caps how long a grant can be valid
+	maxGrantTTL = time.Hour
+)
+
+// Server serves the unmask API
+type Server struct {
+	store    masker.Store
+	grants   Grants
+	adminKey string
+	policy   *masker.EffectivePolicy
+	logger   *zap.Logger
+	emitter  *AuditEmitter
+	now      func() time.Time
+}
+
+// ServerOption configures optional behavior of a Server
+type ServerOption func(*Server)
+
+// WithAuditEmitter streams the audit records of the Server through e, in
+// addition to logging them
+func WithAuditEmitter(e *AuditEmitter) ServerOption {
+	return func(s *Server) {
+		s.emitter = e
+	}
+}
+
+// NewServer creates a Server that reverses tokens from store. Grants can only be
+// issued by requests authenticated with adminKey. The effective masking policy
+// and its fingerprint are served to administrators for debugging and drift detection.
+func NewServer(store masker.Store, grants Grants, adminKey string, policy *masker.EffectivePolicy, logger *zap.Logger, opts ...ServerOption) *Server {
+	s := &Server{
+		store:    store,
+		grants:   grants,
+		adminKey: adminKey,
+		policy:   policy,
+		logger:   logger,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/grants", s.handleIssue)
+	mux.HandleFunc("POST /v1/unmask", s.handleUnmask)
+	mux.HandleFunc("GET /v1/tokens/{category}/{token}", s.handleLookup)
+	mux.HandleFunc("GET /v1/fingerprint", s.handleFingerprint)
+	mux.HandleFunc("GET /v1/policy", s.handlePolicy)
+	mux.HandleFunc("POST /v1/purge", s.handlePurge)
+	mux.HandleFunc("POST /v1/watched", s.handleWatch)
+	mux.HandleFunc("GET /v1/watched", s.handleWatched)
+	mux.HandleFunc("DELETE /v1/watched/{category}/{token}", s.handleUnwatch)
+	return mux
+}
+
+// grantHeader carries the grant of a lookup URL request
+const grantHeader = "X-Unmask-Grant"
+
+// issueRequest is the body of a grant request
+type issueRequest struct {
+	Category string `json:"category"`
+	Token    string `json:"token"`
+	Analyst  string `json:"analyst"`
+	TTL      string `json:"ttl"`
+}
+
+// issueResponse is returned for an issued grant
+type issueResponse struct {
+	Grant     string    `json:"grant"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// unmaskRequest is the body of a reverse lookup
+type unmaskRequest struct {
+	Grant string `json:"grant"`
+}
+
+// unmaskResponse is returned for a reversed token
+type unmaskResponse struct {
+	Category string `json:"category"`
+	Token    string `json:"token"`
+	Original string `json:"original"`
+}
+
+// purgeRequest is the body of a purge of a category or token namespace
+type purgeRequest struct {
+	Category  string `json:"category"`
+	Namespace string `json:"namespace"`
+	Operator  string `json:"operator"`
+	Reason    string `json:"reason"`
+	DryRun    bool   `json:"dry_run"`
+}
+
+// purgeProgress is streamed while a purge runs, one JSON object per line
+type purgeProgress struct {
+	Keys   int64  `json:"keys"`
+	DryRun bool   `json:"dry_run,omitempty"`
+	Done   bool   `json:"done,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// watchRequest is the body of a request flagging a token as of interest
+type watchRequest struct {
+	Category string `json:"category"`
+	Token    string `json:"token"`
+	Operator string `json:"operator"`
+	Reason   string `json:"reason"`
+}
+
+// watchedResponse is returned for the watched tokens
+type watchedResponse struct {
+	Tokens []masker.WatchedToken `json:"tokens"`
+}
+
+// fingerprintResponse is returned for the configuration fingerprint
+type fingerprintResponse struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
+// handleIssue issues a grant for one token to an analyst
+func (s *Server) handleIssue(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		writeError(w, http.StatusUnauthorized, "admin authorization required")
+		return
+	}
+
+	var req issueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Category == "" || req.Token == "" || req.Analyst == "" {
+		writeError(w, http.StatusBadRequest, "category, token, and analyst are required")
+		return
+	}
+
+	ttl := defaultGrantTTL
+	if req.TTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 || ttl > maxGrantTTL {
+			writeError(w, http.StatusBadRequest, "ttl must be a positive duration of at most "+maxGrantTTL.String())
+			return
+		}
+	}
+
+	grant := Grant{
+		Category:  req.Category,
+		Token:     req.Token,
+		Analyst:   req.Analyst,
+		ExpiresAt: s.now().Add(ttl),
+	}
+	secret, err := s.grants.Issue(r.Context(), grant)
+	if err != nil {
+		s.logger.Error("Failed to issue unmask grant", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to issue grant")
+		return
+	}
+
+	s.logger.Info("Issued unmask grant",
+		zap.String("analyst", grant.Analyst),
+		zap.String("category", grant.Category),
+		zap.String("token", grant.Token),
+		zap.Time("expires_at", grant.ExpiresAt),
+	)
+	s.emitAudit(AuditRecord{
+		Action:    AuditGrantIssued,
+		Actor:     grant.Analyst,
+		Category:  grant.Category,
+		Token:     grant.Token,
+		ExpiresAt: grant.ExpiresAt,
+	})
+	writeJSON(w, http.StatusCreated, issueResponse{Grant: secret, ExpiresAt: grant.ExpiresAt})
+}
+
+// handleUnmask redeems a grant and returns the original value of its token
+func (s *Server) handleUnmask(w http.ResponseWriter, r *http.Request) {
+	var req unmaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Grant == "" {
+		writeError(w, http.StatusBadRequest, "grant is required")
+		return
+	}
+
+	s.redeem(w, r, req.Grant, nil)
+}
+
+// handleLookup serves the lookup URLs attached to masked records. The grant is
+// passed in the grant header, never in the URL where proxies and browsers would
+// record it, and must have been issued for the token in the path.
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	category, token := r.PathValue("category"), r.PathValue("token")
+
+	if r.URL.Query().Has("grant") {
+		writeError(w, http.StatusBadRequest, "pass the grant in the "+grantHeader+" header, not the query string")
+		return
+	}
+
+	secret := r.Header.Get(grantHeader)
+	if secret == "" {
+		writeError(w, http.StatusUnauthorized, "a grant for this token is required")
+		return
+	}
+
+	s.redeem(w, r, secret, func(grant Grant) bool {
+		return grant.Category == category && grant.Token == token
+	})
+}
+
+// handleFingerprint returns the fingerprint of the masking configuration
+func (s *Server) handleFingerprint(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		writeError(w, http.StatusUnauthorized, "admin authorization required")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, fingerprintResponse{Fingerprint: s.policy.Fingerprint})
+}
+
+// handlePolicy returns the fully resolved masking policy, so which rule masked a
+// value can be read from one place instead of every configuration source
+func (s *Server) handlePolicy(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		writeError(w, http.StatusUnauthorized, "admin authorization required")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.policy)
+}
+
+// handlePurge deletes every mapping of a category or token namespace, e.g. after
+// a contract ends. The running number of deleted keys is streamed as newline
+// delimited JSON, and the purge is logged for auditing when it starts and ends.
+func (s *Server) handlePurge(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		writeError(w, http.StatusUnauthorized, "admin authorization required")
+		return
+	}
+
+	var req purgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Operator == "" {
+		writeError(w, http.StatusBadRequest, "operator is required")
+		return
+	}
+	scope := masker.PurgeScope{Category: req.Category, Namespace: req.Namespace}
+	if err := scope.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	purger, ok := s.store.(masker.Purger)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "the token store does not support purges")
+		return
+	}
+
+	audit := []zap.Field{
+		zap.String("operator", req.Operator),
+		zap.String("reason", req.Reason),
+		zap.String("category", req.Category),
+		zap.String("namespace", req.Namespace),
+		zap.Bool("dry_run", req.DryRun),
+	}
+	s.logger.Info("Started mapping purge", audit...)
+	record := AuditRecord{
+		Action:    AuditPurgeStarted,
+		Actor:     req.Operator,
+		Category:  req.Category,
+		Namespace: req.Namespace,
+		Reason:    req.Reason,
+		DryRun:    req.DryRun,
+	}
+	s.emitAudit(record)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	report := func(progress purgeProgress) {
+		_ = encoder.Encode(progress)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	keys, err := purger.Purge(r.Context(), scope, req.DryRun, func(keys int64) {
+		if !req.DryRun {
+			report(purgeProgress{Keys: keys})
+		}
+	})
+	if err != nil {
+		s.logger.Error("Mapping purge failed", append(audit, zap.Int64("keys", keys), zap.Error(err))...)
+		record.Action, record.Keys = AuditPurgeFailed, keys
+		s.emitAudit(record)
+		report(purgeProgress{Keys: keys, DryRun: req.DryRun, Error: "purge failed"})
+		return
+	}
+
+	s.logger.Info("Finished mapping purge", append(audit, zap.Int64("keys", keys))...)
+	record.Action, record.Keys = AuditPurgeFinished, keys
+	s.emitAudit(record)
+	report(purgeProgress{Keys: keys, DryRun: req.DryRun, Done: true})
+}
+
+// handleWatch flags a token as of interest, so processors renew its mapping and
+// report whenever it is seen. The original value is never returned.
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		writeError(w, http.StatusUnauthorized, "admin authorization required")
+		return
+	}
+
+	var req watchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Category == "" || req.Token == "" || req.Operator == "" {
+		writeError(w, http.StatusBadRequest, "category, token, and operator are required")
+		return
+	}
+
+	watchlist, ok := s.store.(masker.Watchlist)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "the token store does not support watched tokens")
+		return
+	}
+
+	token := masker.WatchedToken{
+		Category:  req.Category,
+		Token:     req.Token,
+		Operator:  req.Operator,
+		Reason:    req.Reason,
+		FlaggedAt: s.now(),
+	}
+	if err := watchlist.Watch(r.Context(), token); err != nil {
+		s.logger.Error("Failed to flag watched token", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to flag token")
+		return
+	}
+
+	s.logger.Info("Flagged watched token",
+		zap.String("operator", token.Operator),
+		zap.String("reason", token.Reason),
+		zap.String("category", token.Category),
+		zap.String("token", token.Token),
+	)
+	s.emitAudit(AuditRecord{
+		Action:   AuditTokenWatched,
+		Actor:    token.Operator,
+		Category: token.Category,
+		Token:    token.Token,
+		Reason:   token.Reason,
+	})
+	writeJSON(w, http.StatusCreated, token)
+}
+
+// handleWatched lists the watched tokens ordered by category and token
+func (s *Server) handleWatched(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		writeError(w, http.StatusUnauthorized, "admin authorization required")
+		return
+	}
+
+	watchlist, ok := s.store.(masker.Watchlist)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "the token store does not support watched tokens")
+		return
+	}
+
+	tokens, err := watchlist.Watched(r.Context())
+	if err != nil {
+		s.logger.Error("Failed to list watched tokens", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to list watched tokens")
+		return
+	}
+	slices.SortFunc(tokens, func(a, b masker.WatchedToken) int {
+		return cmp.Or(cmp.Compare(a.Category, b.Category), cmp.Compare(a.Token, b.Token))
+	})
+	writeJSON(w, http.StatusOK, watchedResponse{Tokens: tokens})
+}
+
+// handleUnwatch removes the flag of a token. The operator is passed as a query
+// parameter for the audit log.
+func (s *Server) handleUnwatch(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		writeError(w, http.StatusUnauthorized, "admin authorization required")
+		return
+	}
+
+	category, token := r.PathValue("category"), r.PathValue("token")
+	operator := r.URL.Query().Get("operator")
+	if operator == "" {
+		writeError(w, http.StatusBadRequest, "operator is required")
+		return
+	}
+
+	watchlist, ok := s.store.(masker.Watchlist)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "the token store does not support watched tokens")
+		return
+	}
+
+	found, err := watchlist.Unwatch(r.Context(), category, token)
+	if err != nil {
+		s.logger.Error("Failed to unflag watched token", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to unflag token")
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "token is not watched")
+		return
+	}
+
+	s.logger.Info("Unflagged watched token",
+		zap.String("operator", operator),
+		zap.String("category", category),
+		zap.String("token", token),
+	)
+	s.emitAudit(AuditRecord{
+		Action:   AuditTokenUnwatched,
+		Actor:    operator,
+		Category: category,
+		Token:    token,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// redeem consumes the grant identified by secret and writes the original value
+// of its token. When set, matches must accept the grant before it is consumed.
+func (s *Server) redeem(w http.ResponseWriter, r *http.Request, secret string, matches func(Grant) bool) {
+	grant, ok, err := s.grants.Redeem(r.Context(), secret, matches)
+	if err != nil {
+		s.logger.Error("Failed to redeem unmask grant", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to redeem grant")
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusForbidden, "grant is invalid, expired, or already used")
+		return
+	}
+
+	original, found, err := s.store.Get(r.Context(), masker.UnmaskKey(grant.Category, grant.Token))
+	if err != nil {
+		s.logger.Error("Failed to look up token", zap.Error(err))
+		writeError(w, http.StatusInternalServerError, "failed to look up token")
+		return
+	}
+
+	s.logger.Info("Redeemed unmask grant",
+		zap.String("analyst", grant.Analyst),
+		zap.String("category", grant.Category),
+		zap.String("token", grant.Token),
+		zap.Bool("found", found),
+	)
+	result := "found"
+	if !found {
+		result = "not_found"
+	}
+	s.emitAudit(AuditRecord{
+		Action:   AuditGrantRedeemed,
+		Actor:    grant.Analyst,
+		Category: grant.Category,
+		Token:    grant.Token,
+		Result:   result,
+	})
+
+	if !found {
+		writeError(w, http.StatusNotFound, "token not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, unmaskResponse{Category: grant.Category, Token: grant.Token, Original: original})
+}
+
+// emitAudit streams record at the current time when an audit emitter is set
+func (s *Server) emitAudit(record AuditRecord) {
+	if s.emitter == nil {
+		return
+	}
+	record.Time = s.now()
+	s.emitter.Emit(record)
+}
+
+// isAdmin reports whether r carries the admin key as a bearer token
+func (s *Server) isAdmin(r *http.Request) bool {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && s.adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(s.adminKey)) == 1
+}
+
+// writeJSON writes body as a JSON response
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,368 @@
+package unmask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/observiq/bindplane-otel-collector/processor/redismasking/masker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testAdminKey = "admin-secret"
+
+// newTestServer creates a Server backed by an in-process Redis server
+func newTestServer(t *testing.T) (*Server, *miniredis.Miniredis) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	cfg := masker.NewDefaultConfig()
+	cfg.RedisAddr = server.Addr()
+
+	store, err := masker.NewRedisStore(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, store.Close()) })
+
+	grants, err := NewRedisGrants(context.Background(), &cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, grants.Close()) })
+
+	policy, err := cfg.EffectivePolicy()
+	require.NoError(t, err)
+
+	require.NoError(t, store.Set(context.Background(), masker.UnmaskKey("ipv4", "10.1.2.3"), "192.168.1.1", 0))
+	return NewServer(store, grants, testAdminKey, policy, zap.NewNop()), server
+}
+
+// post sends body to path and decodes the JSON response
+func post(t *testing.T, s *Server, path, auth string, body any) (int, map[string]any) {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	if auth != "" {
+		req.Header.Set("Authorization", "Bearer "+auth)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec.Code, resp
+}
+
+func TestGrantIsSingleUse(t *testing.T) {
+	s, server := newTestServer(t)
+
+	status, resp := post(t, s, "/v1/grants", testAdminKey, issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "jdoe"})
+	require.Equal(t, http.StatusCreated, status)
+	secret := resp["grant"].(string)
+
+	// Only a hash of the secret is stored
+	require.Len(t, server.Keys(), 2)
+	require.False(t, server.Exists("unmask_grant:"+secret))
+
+	status, resp = post(t, s, "/v1/unmask", "", unmaskRequest{Grant: secret})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "192.168.1.1", resp["original"])
+
+	// Replaying the grant fails
+	status, resp = post(t, s, "/v1/unmask", "", unmaskRequest{Grant: secret})
+	require.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, "grant is invalid, expired, or already used", resp["error"])
+}
+
+func TestGrantConcurrentRedeem(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	status, resp := post(t, s, "/v1/grants", testAdminKey, issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "jdoe"})
+	require.Equal(t, http.StatusCreated, status)
+	secret := resp["grant"].(string)
+
+	var redeemed atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := s.grants.Redeem(context.Background(), secret, nil)
+			assert.NoError(t, err)
+			if ok {
+				redeemed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), redeemed.Load())
+}
+
+func TestGrantExpires(t *testing.T) {
+	s, server := newTestServer(t)
+
+	status, resp := post(t, s, "/v1/grants", testAdminKey, issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "jdoe", TTL: "1m"})
+	require.Equal(t, http.StatusCreated, status)
+
+	server.FastForward(time.Minute)
+	status, _ = post(t, s, "/v1/unmask", "", unmaskRequest{Grant: resp["grant"].(string)})
+	require.Equal(t, http.StatusForbidden, status)
+}
+
+func TestIssueGrant(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	testCases := []struct {
+		name     string
+		auth     string
+		request  issueRequest
+		status   int
+		errorMsg string
+	}{
+		{
+			name:     "missing admin key",
+			request:  issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "jdoe"},
+			status:   http.StatusUnauthorized,
+			errorMsg: "admin authorization required",
+		},
+		{
+			name:     "wrong admin key",
+			auth:     "guess",
+			request:  issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "jdoe"},
+			status:   http.StatusUnauthorized,
+			errorMsg: "admin authorization required",
+		},
+		{
+			name:     "missing analyst",
+			auth:     testAdminKey,
+			request:  issueRequest{Category: "ipv4", Token: "10.1.2.3"},
+			status:   http.StatusBadRequest,
+			errorMsg: "category, token, and analyst are required",
+		},
+		{
+			name:     "ttl too long",
+			auth:     testAdminKey,
+			request:  issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "jdoe", TTL: "2h"},
+			status:   http.StatusBadRequest,
+			errorMsg: "ttl must be a positive duration of at most 1h0m0s",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp := post(t, s, "/v1/grants", tc.auth, tc.request)
+			require.Equal(t, tc.status, status)
+			assert.Equal(t, tc.errorMsg, resp["error"])
+		})
+	}
+}
+
+func TestUnmaskUnknownToken(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	status, resp := post(t, s, "/v1/grants", testAdminKey, issueRequest{Category: "ipv4", Token: "10.9.9.9", Analyst: "jdoe"})
+	require.Equal(t, http.StatusCreated, status)
+
+	status, resp = post(t, s, "/v1/unmask", "", unmaskRequest{Grant: resp["grant"].(string)})
+	require.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "token not found", resp["error"])
+}
+
+func TestLookupURL(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	get := func(path, grant string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if grant != "" {
+			req.Header.Set(grantHeader, grant)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	status, resp := get("/v1/tokens/ipv4/10.1.2.3", "")
+	require.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "a grant for this token is required", resp["error"])
+
+	status, resp = post(t, s, "/v1/grants", testAdminKey, issueRequest{Category: "ipv4", Token: "10.1.2.3", Analyst: "jdoe"})
+	require.Equal(t, http.StatusCreated, status)
+	secret := resp["grant"].(string)
+
+	// Grants are never accepted in the URL
+	status, resp = get("/v1/tokens/ipv4/10.1.2.3?grant="+secret, "")
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "pass the grant in the X-Unmask-Grant header, not the query string", resp["error"])
+
+	// A grant only resolves the token it was issued for, and is not used up by
+	// a request for another token
+	status, _ = get("/v1/tokens/ipv4/10.9.9.9", secret)
+	require.Equal(t, http.StatusForbidden, status)
+	status, resp = get("/v1/tokens/ipv4/10.1.2.3", secret)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "192.168.1.1", resp["original"])
+
+	// A redeemed grant is rejected
+	status, _ = get("/v1/tokens/ipv4/10.1.2.3", secret)
+	require.Equal(t, http.StatusForbidden, status)
+}
+
+func TestPolicy(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	get := func(path, auth string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return rec.Code, resp
+	}
+
+	for _, path := range []string{"/v1/fingerprint", "/v1/policy"} {
+		status, resp := get(path, "")
+		require.Equal(t, http.StatusUnauthorized, status)
+		assert.Equal(t, "admin authorization required", resp["error"])
+	}
+
+	status, resp := get("/v1/fingerprint", testAdminKey)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, s.policy.Fingerprint, resp["fingerprint"])
+
+	status, resp = get("/v1/policy", testAdminKey)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, s.policy.Fingerprint, resp["fingerprint"])
+	assert.Equal(t, "standard", resp["mode"])
+	patterns := resp["patterns"].([]any)
+	require.Len(t, patterns, 4)
+	assert.Equal(t, "ipv6", patterns[0].(map[string]any)["name"])
+	assert.Equal(t, "ipv4", patterns[1].(map[string]any)["name"])
+}
+
+func TestPurge(t *testing.T) {
+	s, server := newTestServer(t)
+
+	purge := func(auth string, body any) (int, []map[string]any) {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/purge", bytes.NewReader(data))
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+
+		var lines []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n") {
+			var resp map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &resp))
+			lines = append(lines, resp)
+		}
+		return rec.Code, lines
+	}
+
+	status, lines := purge("", purgeRequest{Category: "ipv4", Operator: "jdoe"})
+	require.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "admin authorization required", lines[0]["error"])
+
+	status, lines = purge(testAdminKey, purgeRequest{Category: "ipv4"})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "operator is required", lines[0]["error"])
+
+	status, lines = purge(testAdminKey, purgeRequest{Operator: "jdoe"})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "exactly one of category or namespace is required", lines[0]["error"])
+
+	// A dry run reports the count without deleting
+	status, lines = purge(testAdminKey, purgeRequest{Category: "ipv4", Operator: "jdoe", DryRun: true})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []map[string]any{{"keys": float64(1), "dry_run": true, "done": true}}, lines)
+	require.True(t, server.Exists(masker.UnmaskKey("ipv4", "10.1.2.3")))
+
+	status, lines = purge(testAdminKey, purgeRequest{Category: "ipv4", Operator: "jdoe", Reason: "contract ended"})
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []map[string]any{{"keys": float64(1)}, {"keys": float64(1), "done": true}}, lines)
+	require.False(t, server.Exists(masker.UnmaskKey("ipv4", "10.1.2.3")))
+}
+
+func TestWatchedTokens(t *testing.T) {
+	s, _ := newTestServer(t)
+	flaggedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return flaggedAt }
+
+	request := func(method, path, auth string) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, req)
+
+		var resp map[string]any
+		if rec.Body.Len() > 0 {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		}
+		return rec.Code, resp
+	}
+
+	status, resp := post(t, s, "/v1/watched", "", watchRequest{Category: "ipv4", Token: "10.1.2.3", Operator: "jdoe"})
+	require.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, "admin authorization required", resp["error"])
+
+	status, resp = post(t, s, "/v1/watched", testAdminKey, watchRequest{Category: "ipv4", Token: "10.1.2.3"})
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "category, token, and operator are required", resp["error"])
+
+	status, resp = post(t, s, "/v1/watched", testAdminKey, watchRequest{Category: "ipv4", Token: "10.1.2.3", Operator: "jdoe", Reason: "INC-42"})
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, map[string]any{
+		"category":   "ipv4",
+		"token":      "10.1.2.3",
+		"operator":   "jdoe",
+		"reason":     "INC-42",
+		"flagged_at": "2026-10-01T12:00:00Z",
+	}, resp)
+	status, _ = post(t, s, "/v1/watched", testAdminKey, watchRequest{Category: "email", Token: "EMAIL-1", Operator: "jdoe"})
+	require.Equal(t, http.StatusCreated, status)
+
+	// The original value is never part of the list
+	status, resp = request(http.MethodGet, "/v1/watched", testAdminKey)
+	require.Equal(t, http.StatusOK, status)
+	tokens := resp["tokens"].([]any)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "email", tokens[0].(map[string]any)["category"])
+	assert.Equal(t, "10.1.2.3", tokens[1].(map[string]any)["token"])
+	assert.NotContains(t, tokens[1], "original")
+
+	status, resp = request(http.MethodDelete, "/v1/watched/ipv4/10.1.2.3", testAdminKey)
+	require.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "operator is required", resp["error"])
+
+	status, _ = request(http.MethodDelete, "/v1/watched/ipv4/10.1.2.3?operator=jdoe", testAdminKey)
+	require.Equal(t, http.StatusNoContent, status)
+	status, resp = request(http.MethodDelete, "/v1/watched/ipv4/10.1.2.3?operator=jdoe", testAdminKey)
+	require.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "token is not watched", resp["error"])
+
+	status, resp = request(http.MethodGet, "/v1/watched", testAdminKey)
+	require.Equal(t, http.StatusOK, status)
+	assert.Len(t, resp["tokens"], 1)
+}